@@ -0,0 +1,138 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// DefaultEvaluationInterval is how often the rules are evaluated, besides on every log entry,
+// so they stop firing (or start, e.g. rate() < 1/5m) when no log entries are written.
+const DefaultEvaluationInterval = 10 * time.Second
+
+// Source calls the given function for each log entry to evaluate the rules against, e.g. logging.Logs.Follow.
+type Source func(ctx context.Context, fn func(logging.LogEntry) error) error
+
+// Agent evaluates alerting rules against the log entries of a Source, notifying the alerts
+// when the rules start and stop firing.
+type Agent struct {
+	rules     []*Rule
+	notifiers []Notifier
+	interval  time.Duration
+	onError   func(error)
+	now       func() time.Time
+}
+
+// Option configures the Agent created by NewAgent.
+type Option func(a *Agent)
+
+// WithEvaluationInterval sets how often the rules are evaluated, DefaultEvaluationInterval by default.
+func WithEvaluationInterval(interval time.Duration) Option {
+	return func(a *Agent) {
+		a.interval = interval
+	}
+}
+
+// WithErrorHandler sets the function called when an alert couldn't be delivered, ignoring the failures by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(a *Agent) {
+		a.onError = fn
+	}
+}
+
+// NewAgent creates an Agent evaluating given rules and delivering their alerts to given notifiers.
+func NewAgent(rules []*Rule, notifiers []Notifier, opts ...Option) *Agent {
+	a := &Agent{
+		rules:     rules,
+		notifiers: notifiers,
+		interval:  DefaultEvaluationInterval,
+		onError:   func(error) {},
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.interval <= 0 {
+		a.interval = DefaultEvaluationInterval
+	}
+	return a
+}
+
+// Run evaluates the rules against the log entries of a given source until the source is done or the context is done,
+// returning the error of the source. No files within the time window (logging.ErrNoFilesInWindow) is no error.
+// The alerts are delivered as soon as the rules start or stop firing, holding the source back meanwhile.
+// The rules are evaluated on the clock of the logs (see logClock), on every log entry as on every tick.
+// The source is always stopped and done once Run returns.
+func (a *Agent) Run(ctx context.Context, source Source) error {
+	pipe := logging.NewPipe(ctx, source, 100)
+	defer func() { _ = pipe.Close() }()
+
+	var clock logClock
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry, ok := <-pipe.Entries():
+			if !ok {
+				err := pipe.Err()
+				if errors.Is(err, logging.ErrNoFilesInWindow) {
+					return nil
+				}
+				return err
+			}
+			clock.observe(entry.Time, a.now())
+			now := clock.at(a.now())
+			for _, rule := range a.rules {
+				rule.observe(entry)
+				if alert, ok := rule.evaluate(now); ok {
+					a.notify(ctx, alert)
+				}
+			}
+		case <-ticker.C:
+			now := clock.at(a.now())
+			for _, rule := range a.rules {
+				if alert, ok := rule.evaluate(now); ok {
+					a.notify(ctx, alert)
+				}
+			}
+		}
+	}
+}
+
+// logClock is the time of the logs the rules are evaluated at, so the log entries lagging the wall clock
+// (e.g. skewed or buffered) are evaluated the same way on every entry and on every tick, instead of being evicted
+// by a tick only to fire again on the next entry. It's the time of the latest log entry, moved forward by the time
+// elapsed since it was read until a later entry is read, so the rules still stop firing when no log entries are written.
+// It never goes back, the entries older than it being counted without moving it.
+type logClock struct {
+	// latest is the time of the latest log entry moving the clock, read the wall-clock time it was read at
+	latest, read time.Time
+}
+
+// observe moves the clock to the time of a given log entry read at a given wall-clock time, when later.
+func (c *logClock) observe(t, now time.Time) {
+	if c.latest.IsZero() || t.After(c.at(now)) {
+		c.latest, c.read = t, now
+	}
+}
+
+// at returns the time of the logs at a given wall-clock time, the wall-clock time itself until a log entry is read.
+func (c *logClock) at(now time.Time) time.Time {
+	if c.latest.IsZero() {
+		return now
+	}
+	return c.latest.Add(now.Sub(c.read))
+}
+
+// notify delivers a given alert to every notifier.
+func (a *Agent) notify(ctx context.Context, alert Alert) {
+	for _, notifier := range a.notifiers {
+		ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		if err := notifier.Notify(ctx, alert); err != nil {
+			a.onError(err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,164 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type agentSuite struct {
+	suite.Suite
+}
+
+// webhookServer starts a server answering with a given status, sending the alerts it receives to a channel.
+func (s *agentSuite) webhookServer(status int, alerts chan<- Alert) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPost, r.Method)
+		s.Equal("application/json", r.Header.Get("Content-Type"))
+		var alert Alert
+		s.NoError(json.NewDecoder(r.Body).Decode(&alert))
+		alerts <- alert
+		w.WriteHeader(status)
+	}))
+}
+
+func (s *agentSuite) Test_Run() {
+	alerts := make(chan Alert, 10)
+	server := s.webhookServer(http.StatusOK, alerts)
+	defer server.Close()
+	webhook, err := NewWebhook(server.URL)
+	s.Require().NoError(err)
+	rule, err := ParseRule("rate(status>=500) > 1/1m")
+	s.Require().NoError(err)
+	now := time.Date(2022, 3, 3, 2, 45, 0, 0, time.UTC)
+	agent := NewAgent([]*Rule{rule}, []Notifier{webhook}, WithEvaluationInterval(10*time.Millisecond))
+	// the logs lag the wall clock by 2 minutes, which moves on by 10 seconds every time it's read
+	var calls int64
+	agent.now = func() time.Time {
+		return now.Add(2*time.Minute + time.Duration(atomic.AddInt64(&calls, 1))*10*time.Second)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		for i := 0; i < 2; i++ {
+			if err := fn(logging.LogEntry{Line: "error", IP: "10.0.0.1", Status: 500, Time: now}); err != nil {
+				return err
+			}
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	done := make(chan error, 1)
+	go func() { done <- agent.Run(ctx, source) }()
+
+	// the rule fires on the second error, then resolves once the errors left the window, no other error being written
+	for _, state := range []State{StateFiring, StateResolved} {
+		select {
+		case alert := <-alerts:
+			s.Equal(state, alert.State)
+			s.Equal("rate(status>=500) > 1/1m", alert.Rule)
+		case <-time.After(time.Second):
+			s.FailNow("no alert", state)
+		}
+	}
+	cancel()
+	s.ErrorIs(<-done, context.Canceled)
+}
+
+func (s *agentSuite) Test_Run_LaggingLogs() {
+	alerts := make(chan Alert, 100)
+	server := s.webhookServer(http.StatusOK, alerts)
+	defer server.Close()
+	webhook, err := NewWebhook(server.URL)
+	s.Require().NoError(err)
+	rule, err := ParseRule("rate(status>=500) > 1/1m")
+	s.Require().NoError(err)
+	start := time.Date(2022, 3, 3, 2, 45, 0, 0, time.UTC)
+	agent := NewAgent([]*Rule{rule}, []Notifier{webhook}, WithEvaluationInterval(time.Millisecond))
+	// the wall clock is 2 minutes ahead of the logs, an error being written every second
+	var wall int64
+	agent.now = func() time.Time { return start.Add(2*time.Minute + time.Duration(atomic.LoadInt64(&wall))*time.Second) }
+
+	err = agent.Run(context.Background(), func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		for i := 0; i < 50; i++ {
+			atomic.StoreInt64(&wall, int64(i))
+			if err := fn(logging.LogEntry{Line: "error", IP: "10.0.0.1", Status: 500, Time: start.Add(time.Duration(i) * time.Second)}); err != nil {
+				return err
+			}
+			// leaving room to the ticks
+			time.Sleep(2 * time.Millisecond)
+		}
+		return nil
+	})
+
+	// the ticks don't evict the errors the entries keep counting, so the rule fires once without flapping
+	s.Require().NoError(err)
+	s.Require().Len(alerts, 1)
+	s.Equal(StateFiring, (<-alerts).State)
+}
+
+func (s *agentSuite) Test_logClock() {
+	var clock logClock
+	wall := time.Date(2022, 3, 3, 2, 47, 0, 0, time.UTC)
+	s.Equal(wall, clock.at(wall))
+
+	logTime := wall.Add(-2 * time.Minute)
+	clock.observe(logTime, wall)
+	s.Equal(logTime, clock.at(wall))
+	// moved forward by the wall clock until a later log entry is read
+	s.Equal(logTime.Add(10*time.Second), clock.at(wall.Add(10*time.Second)))
+	// never back
+	clock.observe(logTime.Add(5*time.Second), wall.Add(10*time.Second))
+	s.Equal(logTime.Add(10*time.Second), clock.at(wall.Add(10*time.Second)))
+	clock.observe(logTime.Add(30*time.Second), wall.Add(10*time.Second))
+	s.Equal(logTime.Add(30*time.Second), clock.at(wall.Add(10*time.Second)))
+}
+
+func (s *agentSuite) Test_Run_NotifyError() {
+	alerts := make(chan Alert, 10)
+	server := s.webhookServer(http.StatusInternalServerError, alerts)
+	defer server.Close()
+	webhook, err := NewWebhook(server.URL)
+	s.Require().NoError(err)
+	rule, err := ParseRule("rate() > 0")
+	s.Require().NoError(err)
+	var errs []error
+	agent := NewAgent([]*Rule{rule}, []Notifier{webhook}, WithErrorHandler(func(err error) { errs = append(errs, err) }))
+
+	err = agent.Run(context.Background(), func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		_ = fn(logging.LogEntry{IP: "10.0.0.1", Status: 200, Time: time.Now()})
+		return logging.ErrNoFilesInWindow
+	})
+
+	s.NoError(err)
+	s.Len(alerts, 1)
+	s.Require().Len(errs, 1)
+	s.EqualError(errs[0], "webhook failed: 500 Internal Server Error: ")
+}
+
+func (s *agentSuite) Test_Run_SourceError() {
+	agent := NewAgent(nil, nil)
+	err := agent.Run(context.Background(), func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		return errors.New("boom")
+	})
+	s.EqualError(err, "boom")
+}
+
+func (s *agentSuite) Test_NewWebhook_Invalid() {
+	_, err := NewWebhook("ftp://example.com")
+	s.EqualError(err, "invalid webhook url 'ftp://example.com': must be an http or https url")
+}
+
+func TestAgentSuite(t *testing.T) {
+	suite.Run(t, new(agentSuite))
+}
@@ -0,0 +1,154 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The default templates of the chat messages.
+const (
+	// DefaultAlertTemplate is the template of the alert messages, executed with an Alert.
+	DefaultAlertTemplate = `{{if eq .State "firing"}}[FIRING]{{else}}[RESOLVED]{{end}} {{.Rule}}
+value {{.Value}} (threshold {{.Threshold}}): {{.Matched}} of {{.Requests}} requests within {{.Window}}{{range .Examples}}
+> {{.}}{{end}}`
+	// DefaultSummaryTemplate is the template of the summary messages, executed with a Summary.
+	DefaultSummaryTemplate = `{{.Command}} of the logs of {{.Directory}} within the last {{.Window}} {{if .Error}}failed: {{.Error}}{{else}}completed{{end}}
+{{.Entries}} log entries in {{.Duration}}`
+)
+
+// The colors of the Teams messages.
+const (
+	teamsColorBad  = "d9534f"
+	teamsColorGood = "5cb85c"
+)
+
+// Summary is the summary of an extraction of the logs of a window once complete, e.g. shipping them.
+type Summary struct {
+	// Command is what was done with the logs, e.g. ship.
+	Command   string `json:"command"`
+	Directory string `json:"directory"`
+	// Window is the time window of the logs, e.g. 1h0m0s.
+	Window string `json:"window"`
+	// Entries is the number of log entries extracted.
+	Entries int64 `json:"entries"`
+	// Duration is the time taken by the extraction, e.g. 1.5s.
+	Duration string `json:"duration"`
+	// Error is why the extraction failed, empty when it succeeded.
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// ChatConfig configures a Slack or Teams notifier.
+type ChatConfig struct {
+	// URL is the URL of the incoming webhook of the channel.
+	URL string
+	// AlertTemplate is the text/template of the alert messages, executed with an Alert, DefaultAlertTemplate when empty.
+	AlertTemplate string
+	// SummaryTemplate is the text/template of the summary messages, executed with a Summary, DefaultSummaryTemplate when empty.
+	SummaryTemplate string
+}
+
+// Chat is a Notifier posting the alerts, and the summaries of extractions, as messages to a Slack
+// or Microsoft Teams channel through its incoming webhook, rendered by templates (see ChatConfig).
+type Chat struct {
+	url      string
+	teams    bool
+	alert    *template.Template
+	summary  *template.Template
+	client   *http.Client
+	platform string
+}
+
+// NewSlack creates a Chat notifier posting to a Slack incoming webhook.
+func NewSlack(cfg ChatConfig) (*Chat, error) {
+	return newChat(cfg, "slack")
+}
+
+// NewTeams creates a Chat notifier posting to a Microsoft Teams incoming webhook.
+func NewTeams(cfg ChatConfig) (*Chat, error) {
+	return newChat(cfg, "teams")
+}
+
+// newChat creates a Chat notifier for a given platform, parsing its templates.
+func newChat(cfg ChatConfig, platform string) (*Chat, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s webhook url '%s': must be an http or https url", platform, cfg.URL)
+	}
+	if cfg.AlertTemplate == "" {
+		cfg.AlertTemplate = DefaultAlertTemplate
+	}
+	if cfg.SummaryTemplate == "" {
+		cfg.SummaryTemplate = DefaultSummaryTemplate
+	}
+	alertTmpl, err := template.New("alert").Parse(cfg.AlertTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s alert template: %v", platform, err)
+	}
+	summaryTmpl, err := template.New("summary").Parse(cfg.SummaryTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s summary template: %v", platform, err)
+	}
+
+	return &Chat{
+		url:      cfg.URL,
+		teams:    platform == "teams",
+		alert:    alertTmpl,
+		summary:  summaryTmpl,
+		client:   &http.Client{Timeout: notifyTimeout},
+		platform: platform,
+	}, nil
+}
+
+// Notify posts a message for a given alert.
+func (c *Chat) Notify(ctx context.Context, alert Alert) error {
+	return c.post(ctx, c.alert, alert, alert.State == StateFiring)
+}
+
+// NotifySummary posts a message for a given summary.
+func (c *Chat) NotifySummary(ctx context.Context, summary Summary) error {
+	return c.post(ctx, c.summary, summary, summary.Error != "")
+}
+
+// post posts the message rendered by a given template, highlighted as bad news (e.g. in red on Teams) when told so.
+func (c *Chat) post(ctx context.Context, tmpl *template.Template, data interface{}, bad bool) error {
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, data); err != nil {
+		return fmt.Errorf("could not render %s message: %v", c.platform, err)
+	}
+
+	var payload interface{}
+	if c.teams {
+		color := teamsColorGood
+		if bad {
+			color = teamsColorBad
+		}
+		lines := strings.Split(text.String(), "\n")
+		payload = map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    lines[0],
+			"themeColor": color,
+			// the lines of a Teams message are paragraphs
+			"text": strings.Join(lines, "\n\n"),
+		}
+	} else {
+		payload = map[string]string{"text": text.String()}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if err := postJSON(ctx, c.client, c.url, body); err != nil {
+		return fmt.Errorf("%s %v", c.platform, err)
+	}
+	return nil
+}
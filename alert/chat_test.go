@@ -0,0 +1,106 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type chatSuite struct {
+	suite.Suite
+	alert Alert
+}
+
+func (s *chatSuite) SetupSuite() {
+	s.alert = Alert{
+		Rule:      "rate(status>=500) > 1/1m",
+		State:     StateFiring,
+		Value:     2,
+		Threshold: 1,
+		Window:    "1m0s",
+		Requests:  10,
+		Matched:   2,
+		Time:      time.Date(2022, 3, 3, 2, 45, 0, 0, time.UTC),
+		Examples:  []string{"a", "b"},
+	}
+}
+
+// chatServer starts a server answering with a given status, recording the payloads it receives.
+func (s *chatSuite) chatServer(status int, payloads *[]map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("application/json", r.Header.Get("Content-Type"))
+		var payload map[string]string
+		s.NoError(json.NewDecoder(r.Body).Decode(&payload))
+		*payloads = append(*payloads, payload)
+		w.WriteHeader(status)
+	}))
+}
+
+func (s *chatSuite) Test_Slack() {
+	var payloads []map[string]string
+	server := s.chatServer(http.StatusOK, &payloads)
+	defer server.Close()
+	slack, err := NewSlack(ChatConfig{URL: server.URL})
+	s.Require().NoError(err)
+
+	s.Require().NoError(slack.Notify(context.Background(), s.alert))
+	s.Require().NoError(slack.NotifySummary(context.Background(), Summary{Command: "ship", Directory: "/var/log/apache2", Window: "1h0m0s", Entries: 42, Duration: "1.5s"}))
+
+	s.Equal([]map[string]string{
+		{"text": "[FIRING] rate(status>=500) > 1/1m\nvalue 2 (threshold 1): 2 of 10 requests within 1m0s\n> a\n> b"},
+		{"text": "ship of the logs of /var/log/apache2 within the last 1h0m0s completed\n42 log entries in 1.5s"},
+	}, payloads)
+}
+
+func (s *chatSuite) Test_Teams() {
+	var payloads []map[string]string
+	server := s.chatServer(http.StatusOK, &payloads)
+	defer server.Close()
+	teams, err := NewTeams(ChatConfig{URL: server.URL, AlertTemplate: "{{.State}}: {{.Rule}}\n{{.Matched}} errors"})
+	s.Require().NoError(err)
+
+	s.Require().NoError(teams.Notify(context.Background(), s.alert))
+	resolved := s.alert
+	resolved.State = StateResolved
+	s.Require().NoError(teams.Notify(context.Background(), resolved))
+
+	s.Require().Len(payloads, 2)
+	s.Equal(map[string]string{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    "firing: rate(status>=500) > 1/1m",
+		"themeColor": teamsColorBad,
+		"text":       "firing: rate(status>=500) > 1/1m\n\n2 errors",
+	}, payloads[0])
+	s.Equal(teamsColorGood, payloads[1]["themeColor"])
+}
+
+func (s *chatSuite) Test_Errors() {
+	var payloads []map[string]string
+	server := s.chatServer(http.StatusNotFound, &payloads)
+	defer server.Close()
+
+	slack, err := NewSlack(ChatConfig{URL: server.URL})
+	s.Require().NoError(err)
+	s.EqualError(slack.Notify(context.Background(), s.alert), "slack webhook failed: 404 Not Found: ")
+
+	_, err = NewTeams(ChatConfig{URL: server.URL, SummaryTemplate: "{{.Entries"})
+	s.EqualError(err, `invalid teams summary template: template: summary:1: unclosed action`)
+	_, err = NewSlack(ChatConfig{URL: "hooks.slack.com/services/x"})
+	s.EqualError(err, "invalid slack webhook url 'hooks.slack.com/services/x': must be an http or https url")
+
+	slack, err = NewSlack(ChatConfig{URL: server.URL, AlertTemplate: "{{.Unknown}}"})
+	s.Require().NoError(err)
+	err = slack.Notify(context.Background(), s.alert)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "could not render slack message")
+}
+
+func TestChatSuite(t *testing.T) {
+	suite.Run(t, new(chatSuite))
+}
@@ -0,0 +1,65 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// notifyTimeout bounds the delivery of an alert to a notifier.
+const notifyTimeout = 10 * time.Second
+
+// Notifier delivers the alerts, e.g. to a webhook.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Webhook is a Notifier posting every alert as JSON (see Alert) to a URL, e.g. an incident management tool.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a Webhook notifier posting to a given http(s) URL.
+func NewWebhook(rawURL string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url '%s': must be an http or https url", rawURL)
+	}
+	return &Webhook{url: rawURL, client: &http.Client{Timeout: notifyTimeout}}, nil
+}
+
+// Notify posts a given alert, failing unless the webhook answers with a 2xx status.
+func (w *Webhook) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, w.client, w.url, body)
+}
+
+// postJSON posts a given JSON body to a URL, failing unless answered with a 2xx status.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
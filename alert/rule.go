@@ -0,0 +1,237 @@
+// Package alert evaluates alerting rules over the log entries written from now on (see logging.Logs.Follow),
+// notifying when they start and stop firing, e.g. more than 10 server errors within a minute,
+// and delivers the reports of the logs, e.g. to chat channels or by mail.
+package alert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// maxExamples is the number of the latest matching log lines an alert comes with.
+const maxExamples = 5
+
+// ruleRegEx matches a rule: FUNC(CONDITION) OP THRESHOLD[/WINDOW].
+var ruleRegEx = regexp.MustCompile(`^\s*(rate|ratio)\s*\((.*)\)\s*(>=|<=|>|<)\s*([0-9]*\.?[0-9]+%?)\s*(?:/\s*([0-9a-z.]+))?\s*$`)
+
+// State is the state of a rule an alert is notified for.
+type State string
+
+// The states of a rule.
+const (
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Alert is the notification of a rule starting (firing) or stopping (resolved) to fire.
+type Alert struct {
+	// Rule is the expression of the rule.
+	Rule  string `json:"rule"`
+	State State  `json:"state"`
+	// Value is the value of the rule when evaluated, compared with its threshold.
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// Window is the sliding window the rule is evaluated over, e.g. 1m0s.
+	Window string `json:"window"`
+	// Requests and Matched are the numbers of requests within the window, and of the ones matching the condition.
+	Requests int64     `json:"requests"`
+	Matched  int64     `json:"matched"`
+	Time     time.Time `json:"time"`
+	// Examples are the latest log lines within the window matching the condition.
+	Examples []string `json:"examples,omitempty"`
+}
+
+// Rule is an alerting rule over the requests of a sliding window, written FUNC(CONDITION) OP THRESHOLD[/WINDOW]:
+//   - FUNC is rate, the number of requests matching the condition within the window,
+//     or ratio, their fraction of all the requests (the threshold may then be a percentage, e.g. 5%).
+//   - CONDITION is a conjunction (&&) of comparisons of the fields of the requests, possibly none,
+//     e.g. status>=500 && path=~^/api: status (or a class e.g. 5xx), size and duration (e.g. 1s) are compared
+//     with ==, !=, <, <=, > and >=, while method, path, ip, vhost, bot, cache, tls_protocol, tls_cipher, host and the parameters
+//     of the query string (e.g. param("utm_source")) are compared with ==, != and regular expressions (=~ and !~), see logging.ParseCondition.
+//   - OP is >, >=, < or <=, and WINDOW is a duration, 1m by default, the unit alone meaning 1 of it (e.g. /m).
+//
+// For instance, rate(status>=500) > 10/1m fires when more than 10 requests failed within the last minute,
+// ratio(status==5xx) > 5%/5m when more than 5% of the requests of the last 5 minutes did,
+// and rate() < 1/5m when there was no request at all for 5 minutes.
+// A rule firing below its threshold is not evaluated until it has observed a full window.
+// A rule isn't safe for concurrent use.
+type Rule struct {
+	expr      string
+	ratio     bool
+	match     logging.Filter
+	op        string
+	threshold float64
+	window    time.Duration
+
+	start    time.Time
+	buckets  []bucket
+	examples []example
+	firing   bool
+}
+
+// bucket counts the requests of a second.
+type bucket struct {
+	second          int64
+	total, matching int64
+}
+
+// example is a log line matching the condition of a rule.
+type example struct {
+	time time.Time
+	line string
+}
+
+// ParseRule parses an alerting rule, see Rule.
+func ParseRule(expr string) (*Rule, error) {
+	groups := ruleRegEx.FindStringSubmatch(expr)
+	if groups == nil {
+		return nil, fmt.Errorf("invalid alert rule '%s': must be rate(CONDITION) or ratio(CONDITION) compared with a threshold, e.g. rate(status>=500) > 10/1m", expr)
+	}
+
+	rule := &Rule{expr: strings.TrimSpace(expr), ratio: groups[1] == "ratio", op: groups[3], window: time.Minute}
+	match, err := logging.ParseCondition(groups[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert rule '%s': %v", expr, err)
+	}
+	rule.match = match
+
+	threshold := groups[4]
+	percent := strings.HasSuffix(threshold, "%")
+	if percent && !rule.ratio {
+		return nil, fmt.Errorf("invalid alert rule '%s': only the threshold of a ratio may be a percentage", expr)
+	}
+	rule.threshold, err = strconv.ParseFloat(strings.TrimSuffix(threshold, "%"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert rule '%s': invalid threshold '%s'", expr, threshold)
+	}
+	if percent {
+		rule.threshold /= 100
+	}
+
+	if window := groups[5]; window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			d, err = time.ParseDuration("1" + window)
+		}
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("invalid alert rule '%s': invalid window '%s', must be at least 1s", expr, window)
+		}
+		rule.window = d
+	}
+	return rule, nil
+}
+
+// String returns the expression of the rule.
+func (r *Rule) String() string {
+	return r.expr
+}
+
+// observe counts a given log entry within the window of the rule, unless it isn't a request.
+func (r *Rule) observe(entry logging.LogEntry) {
+	if entry.IP == "" {
+		return
+	}
+	if r.start.IsZero() {
+		r.start = entry.Time
+	}
+
+	second := entry.Time.Unix()
+	n := len(r.buckets)
+	if n == 0 || r.buckets[n-1].second < second {
+		r.buckets = append(r.buckets, bucket{second: second})
+		n++
+	}
+	// the log entries written slightly out of order are counted in the latest second
+	b := &r.buckets[n-1]
+	b.total++
+	if r.match(entry) {
+		b.matching++
+		r.examples = append(r.examples, example{time: entry.Time, line: entry.Line})
+		if len(r.examples) > maxExamples {
+			r.examples = r.examples[1:]
+		}
+	}
+}
+
+// evaluate evaluates the rule at a given time, returning the alert to notify when it starts or stops firing.
+func (r *Rule) evaluate(now time.Time) (Alert, bool) {
+	if r.start.IsZero() {
+		r.start = now
+	}
+	// too few requests could only be told once a full window is observed
+	if (r.op == "<" || r.op == "<=") && now.Sub(r.start) < r.window {
+		return Alert{}, false
+	}
+
+	// the window covers the seconds after now - window, up to now
+	oldest := now.Add(-r.window).Unix()
+	evicted := 0
+	for evicted < len(r.buckets) && r.buckets[evicted].second <= oldest {
+		evicted++
+	}
+	r.buckets = r.buckets[evicted:]
+	evicted = 0
+	for evicted < len(r.examples) && r.examples[evicted].time.Unix() <= oldest {
+		evicted++
+	}
+	r.examples = r.examples[evicted:]
+
+	var total, matching int64
+	for _, b := range r.buckets {
+		total += b.total
+		matching += b.matching
+	}
+	value := float64(matching)
+	if r.ratio {
+		value = 0
+		if total > 0 {
+			value = float64(matching) / float64(total)
+		}
+	}
+
+	firing := compare(value, r.op, r.threshold)
+	if firing == r.firing {
+		return Alert{}, false
+	}
+	r.firing = firing
+	alert := Alert{
+		Rule:      r.expr,
+		State:     StateResolved,
+		Value:     value,
+		Threshold: r.threshold,
+		Window:    r.window.String(),
+		Requests:  total,
+		Matched:   matching,
+		Time:      now,
+	}
+	if firing {
+		alert.State = StateFiring
+		for _, ex := range r.examples {
+			alert.Examples = append(alert.Examples, ex.line)
+		}
+	}
+	return alert, true
+}
+
+// compare compares two values with a given operator.
+func compare(a float64, op string, b float64) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	default:
+		return a <= b
+	}
+}
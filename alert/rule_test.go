@@ -0,0 +1,140 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type ruleSuite struct {
+	suite.Suite
+	t time.Time
+}
+
+func (s *ruleSuite) SetupSuite() {
+	s.t = time.Date(2022, 3, 3, 2, 45, 0, 0, time.UTC)
+}
+
+func (s *ruleSuite) Test_ParseRule() {
+	tests := []struct {
+		expr      string
+		ratio     bool
+		op        string
+		threshold float64
+		window    time.Duration
+		err       string
+	}{
+		{expr: "rate(status>=500) > 10/1m", op: ">", threshold: 10, window: time.Minute},
+		{expr: " ratio( status==5xx && path=~^/api ) >= 5%/5m ", ratio: true, op: ">=", threshold: 0.05, window: 5 * time.Minute},
+		{expr: "rate() < 1/h", op: "<", threshold: 1, window: time.Hour},
+		{expr: "ratio(duration>1s) > 0.1", ratio: true, op: ">", threshold: 0.1, window: time.Minute},
+		{expr: "count(status>=500) > 10/1m", err: "invalid alert rule 'count(status>=500) > 10/1m': must be rate(CONDITION) or ratio(CONDITION) compared with a threshold, e.g. rate(status>=500) > 10/1m"},
+		{expr: "rate(status>=500) > 10%/1m", err: "invalid alert rule 'rate(status>=500) > 10%/1m': only the threshold of a ratio may be a percentage"},
+		{expr: "rate(status>=500) > 10/1ms", err: "invalid alert rule 'rate(status>=500) > 10/1ms': invalid window '1ms', must be at least 1s"},
+		{expr: "rate(status>=5o0) > 10", err: "invalid alert rule 'rate(status>=5o0) > 10': invalid comparison 'status>=5o0': invalid status '5o0'"},
+		{expr: "rate(agent==curl) > 10", err: "invalid alert rule 'rate(agent==curl) > 10': invalid comparison 'agent==curl': unknown field 'agent'"},
+		{expr: "rate(path>/api) > 10", err: "invalid alert rule 'rate(path>/api) > 10': invalid comparison 'path>/api': path can only be compared with ==, !=, =~ and !~"},
+		{expr: "rate(status=~5) > 10", err: "invalid alert rule 'rate(status=~5) > 10': invalid comparison 'status=~5': status can't be matched with a regular expression"},
+		{expr: "rate(status) > 10", err: "invalid alert rule 'rate(status) > 10': invalid comparison 'status': must be a field compared with a value, e.g. status>=500"},
+	}
+	for _, test := range tests {
+		s.Run(test.expr, func() {
+			rule, err := ParseRule(test.expr)
+			if test.err != "" {
+				s.EqualError(err, test.err)
+				return
+			}
+			s.Require().NoError(err)
+			s.Equal(test.ratio, rule.ratio)
+			s.Equal(test.op, rule.op)
+			s.InDelta(test.threshold, rule.threshold, 1e-9)
+			s.Equal(test.window, rule.window)
+		})
+	}
+}
+
+func (s *ruleSuite) Test_Rate() {
+	rule, err := ParseRule("rate(status>=500) > 2/1m")
+	s.Require().NoError(err)
+
+	// 3 errors within a minute fire the rule, along with their lines
+	for i, status := range []int{500, 200, 502, 503} {
+		rule.observe(logging.LogEntry{Line: "line", IP: "10.0.0.1", Status: status, Time: s.t.Add(time.Duration(i) * time.Second)})
+		_, ok := rule.evaluate(s.t.Add(time.Duration(i) * time.Second))
+		s.Equal(i == 3, ok)
+	}
+	// non requests are ignored
+	rule.observe(logging.LogEntry{Line: "not a request", Status: 500, Time: s.t.Add(3 * time.Second)})
+	_, ok := rule.evaluate(s.t.Add(30 * time.Second))
+	s.False(ok, "still firing")
+
+	// the first error leaves the window
+	alert, ok := rule.evaluate(s.t.Add(time.Minute))
+	s.Require().True(ok)
+	s.Equal(Alert{
+		Rule:      "rate(status>=500) > 2/1m",
+		State:     StateResolved,
+		Value:     2,
+		Threshold: 2,
+		Window:    "1m0s",
+		Requests:  3,
+		Matched:   2,
+		Time:      s.t.Add(time.Minute),
+	}, alert)
+}
+
+func (s *ruleSuite) Test_Rate_Firing() {
+	rule, err := ParseRule("rate(status>=500) > 1/1m")
+	s.Require().NoError(err)
+	rule.observe(logging.LogEntry{Line: "a", IP: "10.0.0.1", Status: 500, Time: s.t})
+	rule.observe(logging.LogEntry{Line: "b", IP: "10.0.0.1", Status: 500, Time: s.t.Add(time.Second)})
+
+	alert, ok := rule.evaluate(s.t.Add(time.Second))
+
+	s.Require().True(ok)
+	s.Equal(StateFiring, alert.State)
+	s.Equal(float64(2), alert.Value)
+	s.Equal([]string{"a", "b"}, alert.Examples)
+}
+
+func (s *ruleSuite) Test_Ratio() {
+	rule, err := ParseRule("ratio(status==5xx) > 50%/10s")
+	s.Require().NoError(err)
+	rule.observe(logging.LogEntry{IP: "10.0.0.1", Status: 200, Time: s.t})
+	rule.observe(logging.LogEntry{IP: "10.0.0.1", Status: 500, Time: s.t})
+	_, ok := rule.evaluate(s.t)
+	s.False(ok, "50% is not above 50%")
+
+	rule.observe(logging.LogEntry{IP: "10.0.0.1", Status: 500, Time: s.t.Add(time.Second)})
+	alert, ok := rule.evaluate(s.t.Add(time.Second))
+
+	s.Require().True(ok)
+	s.Equal(StateFiring, alert.State)
+	s.InDelta(2.0/3, alert.Value, 1e-9)
+}
+
+func (s *ruleSuite) Test_NoTraffic() {
+	rule, err := ParseRule("rate() < 1/1m")
+	s.Require().NoError(err)
+
+	// a full window is observed first
+	_, ok := rule.evaluate(s.t)
+	s.False(ok)
+	_, ok = rule.evaluate(s.t.Add(59 * time.Second))
+	s.False(ok)
+	alert, ok := rule.evaluate(s.t.Add(time.Minute))
+	s.Require().True(ok)
+	s.Equal(StateFiring, alert.State)
+
+	rule.observe(logging.LogEntry{IP: "10.0.0.1", Status: 200, Time: s.t.Add(70 * time.Second)})
+	alert, ok = rule.evaluate(s.t.Add(70 * time.Second))
+	s.Require().True(ok)
+	s.Equal(StateResolved, alert.State)
+}
+
+func TestRuleSuite(t *testing.T) {
+	suite.Run(t, new(ruleSuite))
+}
@@ -0,0 +1,140 @@
+// Command liblogreader is built as a C shared library (see the shared target of the Makefile), exposing the query
+// of a time window and the stats of the logs to other languages, e.g. Python through ctypes or Ruby through FFI,
+// so their tooling reuses the binary search of the log files without shelling out to log-reader:
+//
+//	go build -buildmode=c-shared -o bin/liblogreader.so ./cmd/liblogreader
+//
+// The queries are JSON objects (see query), and the strings returned are allocated by C: they are freed
+// by LogReaderFree, as well as the errors.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// query selects the logs to read, e.g. {"directory": "/var/log/apache2", "minutes": 60, "where": "status>=500"}.
+type query struct {
+	Directory string `json:"directory"`
+	Minutes   int    `json:"minutes"`
+	Format    string `json:"format"`
+	// End is the end of the window as RFC 3339, now when empty.
+	End        string `json:"end"`
+	IP         string `json:"ip"`
+	PathPrefix string `json:"path_prefix"`
+	Where      string `json:"where"`
+	// Top is the number of most frequent status codes of the stats, 5 by default.
+	Top int `json:"top"`
+}
+
+// newLogs creates the logs selected by a JSON query.
+func newLogs(jsonQuery string) (*logging.Logs, query, error) {
+	var q query
+	if err := json.Unmarshal([]byte(jsonQuery), &q); err != nil {
+		return nil, q, fmt.Errorf("invalid query: %w", err)
+	}
+	cfg := logging.LogsConfig{
+		Directory:    q.Directory,
+		LastNMinutes: q.Minutes,
+		Format:       logging.Format(q.Format),
+		IP:           q.IP,
+		PathPrefix:   q.PathPrefix,
+	}
+	if q.End != "" {
+		end, err := time.Parse(time.RFC3339, q.End)
+		if err != nil {
+			return nil, q, fmt.Errorf("invalid end: %w", err)
+		}
+		cfg.End = end
+	}
+	if q.Where != "" {
+		filter, err := logging.ParseCondition(q.Where)
+		if err != nil {
+			return nil, q, err
+		}
+		cfg.Filters = append(cfg.Filters, filter)
+	}
+	logs, err := logging.NewLogs(cfg)
+	return logs, q, err
+}
+
+// queryWindow returns the log lines of the window of a JSON query.
+func queryWindow(jsonQuery string) (string, error) {
+	logs, _, err := newLogs(jsonQuery)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := logs.Print(context.Background(), &buf); err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// stats returns the stats of the window of a JSON query as JSON.
+func stats(jsonQuery string) (string, error) {
+	logs, q, err := newLogs(jsonQuery)
+	if err != nil {
+		return "", err
+	}
+	stats, err := logs.Stats(context.Background())
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		return "", err
+	}
+	top := q.Top
+	if top <= 0 {
+		top = 5
+	}
+	report, err := json.Marshal(stats.Report(top))
+	return string(report), err
+}
+
+// result returns the result of a call to C, or NULL along with its error when it failed.
+func result(s string, err error, errOut **C.char) *C.char {
+	if err != nil {
+		if errOut != nil {
+			*errOut = C.CString(err.Error())
+		}
+		return nil
+	}
+	return C.CString(s)
+}
+
+// LogReaderQuery returns the log lines of the window of a JSON query, one per line,
+// or NULL when failing, the error being set to errOut unless NULL.
+//
+//export LogReaderQuery
+func LogReaderQuery(jsonQuery *C.char, errOut **C.char) *C.char {
+	lines, err := queryWindow(C.GoString(jsonQuery))
+	return result(lines, err, errOut)
+}
+
+// LogReaderStats returns the stats of the window of a JSON query as a JSON object,
+// or NULL when failing, the error being set to errOut unless NULL.
+//
+//export LogReaderStats
+func LogReaderStats(jsonQuery *C.char, errOut **C.char) *C.char {
+	report, err := stats(C.GoString(jsonQuery))
+	return result(report, err, errOut)
+}
+
+// LogReaderFree frees a string returned by the library, or an error.
+//
+//export LogReaderFree
+func LogReaderFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func main() {}
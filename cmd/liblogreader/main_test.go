@@ -0,0 +1,117 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type liblogreaderSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *liblogreaderSuite) SetupSuite() {
+	s.dir = s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "access.log"), []byte(`10.0.0.1 - - [03/Mar/2022:02:40:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:42:00 +0000] "GET /b HTTP/1.1" 404 20
+10.0.0.1 - - [03/Mar/2022:02:44:00 +0000] "GET /c HTTP/1.1" 500 30
+10.0.0.3 - - [03/Mar/2022:02:46:00 +0000] "GET /a HTTP/1.1" 200 40
+`), 0666))
+}
+
+// query returns a JSON query of the test logs ending at 02:45, along with the given fields.
+func (s *liblogreaderSuite) query(minutes int, fields string) string {
+	return fmt.Sprintf(`{"directory": %q, "minutes": %d, "end": "2022-03-03T02:45:00Z"%s}`, s.dir, minutes, fields)
+}
+
+func (s *liblogreaderSuite) Test_queryWindow() {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:  "window",
+			query: s.query(4, ""),
+			expected: `10.0.0.2 - - [03/Mar/2022:02:42:00 +0000] "GET /b HTTP/1.1" 404 20
+10.0.0.1 - - [03/Mar/2022:02:44:00 +0000] "GET /c HTTP/1.1" 500 30
+`,
+		},
+		{
+			name:  "where",
+			query: s.query(10, `, "where": "status>=500"`),
+			expected: `10.0.0.1 - - [03/Mar/2022:02:44:00 +0000] "GET /c HTTP/1.1" 500 30
+`,
+		},
+		{
+			name:  "ip",
+			query: s.query(10, `, "ip": "10.0.0.1"`),
+			expected: `10.0.0.1 - - [03/Mar/2022:02:40:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:02:44:00 +0000] "GET /c HTTP/1.1" 500 30
+`,
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			lines, err := queryWindow(test.query)
+
+			s.Require().NoError(err)
+			s.Equal(test.expected, lines)
+		})
+	}
+}
+
+func (s *liblogreaderSuite) Test_queryWindow_Invalid() {
+	for _, query := range []string{`{"directory": `, s.query(10, `, "end": "yesterday"`), s.query(10, `, "where": "status>="`)} {
+		_, err := queryWindow(query)
+
+		s.Error(err, query)
+	}
+}
+
+func (s *liblogreaderSuite) Test_stats() {
+	tests := []struct {
+		name     string
+		query    string
+		statuses []logging.StatusCount
+	}{
+		{
+			name:     "default top",
+			query:    s.query(10, ""),
+			statuses: []logging.StatusCount{{Status: 200, Count: 1}, {Status: 404, Count: 1}, {Status: 500, Count: 1}},
+		},
+		{
+			name:     "top",
+			query:    s.query(10, `, "top": 1`),
+			statuses: []logging.StatusCount{{Status: 200, Count: 1}},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			out, err := stats(test.query)
+			s.Require().NoError(err)
+
+			var report logging.StatsReport
+			s.Require().NoError(json.Unmarshal([]byte(out), &report))
+			s.Equal(3, report.Requests)
+			s.Equal(2, report.UniqueIPs)
+			s.Equal(1, report.Errors)
+			s.Equal(int64(60), report.Bytes)
+			s.Equal(test.statuses, report.TopStatuses)
+		})
+	}
+}
+
+func TestLiblogreader(t *testing.T) {
+	suite.Run(t, new(liblogreaderSuite))
+}
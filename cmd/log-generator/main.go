@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path"
+	"path/filepath"
 	"time"
 )
 
@@ -29,12 +29,12 @@ func main() {
 		fmt.Println("elapsed", time.Since(now))
 	}()
 
-	err := os.MkdirAll(path.Join(*dirFlag, dataDir), 0777)
+	err := os.MkdirAll(filepath.Join(*dirFlag, dataDir), 0777)
 	if err != nil && os.IsNotExist(err) {
 		log.Fatalf("could not create data directory: %v", err)
 	}
 
-	file, err := os.Create(path.Join(*dirFlag, dataDir, "http-1.log"))
+	file, err := os.Create(filepath.Join(*dirFlag, dataDir, "http-1.log"))
 	if err != nil {
 		log.Fatalf("could not create the first file: %v", err)
 	}
@@ -65,7 +65,7 @@ func main() {
 			}
 		}
 	}
-	err = os.Chtimes(path.Join(*dirFlag, dataDir, "http-1.log"), timeRange, timeRange)
+	err = os.Chtimes(filepath.Join(*dirFlag, dataDir, "http-1.log"), timeRange, timeRange)
 	if err != nil {
 		log.Fatalf("could set modified time for file: %s: %v", file.Name(), err)
 	}
@@ -75,7 +75,7 @@ func main() {
 	maxLogsPerFile := *minLinesFlag
 	timeRange = timeRange.Add(time.Duration(maxLogsPerFile)*interval + interval)
 	for i := 1; i < maxFiles; i++ {
-		f, err := os.Create(path.Join(*dirFlag, dataDir, fmt.Sprintf("http-%d.log", i+1)))
+		f, err := os.Create(filepath.Join(*dirFlag, dataDir, fmt.Sprintf("http-%d.log", i+1)))
 		if err != nil {
 			log.Fatalf("could not create file %d: %v", i+1, err)
 		}
@@ -94,7 +94,7 @@ func main() {
 			}
 		}
 
-		err = os.Chtimes(path.Join(*dirFlag, dataDir, fmt.Sprintf("http-%d.log", i+1)), timeRange, timeRange)
+		err = os.Chtimes(filepath.Join(*dirFlag, dataDir, fmt.Sprintf("http-%d.log", i+1)), timeRange, timeRange)
 		if err != nil {
 			log.Fatalf("could set modified time for file: %s: %v", f.Name(), err)
 		}
@@ -0,0 +1,255 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command log-reader-wasm is built for WebAssembly (see the wasm target of the Makefile), exposing the parsing
+// and the filtering of the logs to JavaScript, e.g. for a browser-based log inspector reusing the code of log-reader:
+//
+//	GOOS=js GOARCH=wasm go build -o bin/log-reader.wasm ./cmd/log-reader-wasm
+//
+// Once run (see wasm_exec.js of the Go distribution), it defines a global logReader object with the functions:
+//
+//	logReader.window(text, {format, start, end, minutes, where}) // the log entries of a time window of the text
+//	logReader.parse(line, format)                                // the log entry of a single log line
+//
+// both returning the log entries as JSON objects (see logging.LogEntry), or an object of the error when failing,
+// e.g. {error: "unsupported log format 'nginx'"}.
+// The window ends at end (RFC 3339) or the last log of the text, and lasts minutes or starts at start, the first log
+// of the text by default. The text is read the way log-reader reads a log file (see logging.Logs), e.g. its invalid
+// lines are kept with the time of the previous ones.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// windowOptions are the options of logReader.window.
+type windowOptions struct {
+	Format  string `json:"format"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Minutes int    `json:"minutes"`
+	Where   string `json:"where"`
+}
+
+// window returns the log entries of a text within the time window of some options, read by logging.Logs
+// as the single log file of an in-memory file system.
+func window(text string, opts windowOptions) ([]logging.LogEntry, error) {
+	format := logging.Format(opts.Format)
+	var start, end time.Time
+	var err error
+	if opts.End != "" {
+		if end, err = time.Parse(time.RFC3339, opts.End); err != nil {
+			return nil, fmt.Errorf("invalid end: %w", err)
+		}
+	}
+	if opts.Start != "" {
+		if start, err = time.Parse(time.RFC3339, opts.Start); err != nil {
+			return nil, fmt.Errorf("invalid start: %w", err)
+		}
+	}
+	if end.IsZero() || start.IsZero() && opts.Minutes == 0 {
+		first, last, err := boundTimes(text, format)
+		if err != nil {
+			return nil, err
+		}
+		if end.IsZero() {
+			// the last log is part of the window
+			end = last.Add(time.Nanosecond)
+		}
+		if start.IsZero() && opts.Minutes == 0 {
+			start = first
+		}
+	}
+	if start.IsZero() {
+		start = end.Add(-time.Duration(opts.Minutes) * time.Minute)
+	}
+	entries := []logging.LogEntry{}
+	if !start.Before(end) {
+		return entries, nil
+	}
+	options := []logging.Option{
+		logging.WithFS(textFS{text: text, modTime: end}), logging.WithDirectory("."), logging.WithFormat(format),
+		logging.WithEnd(end), logging.WithWindow(end.Sub(start)),
+	}
+	if opts.Where != "" {
+		filter, err := logging.ParseCondition(opts.Where)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, logging.WithFilter(filter))
+	}
+	logs, err := logging.New(options...)
+	if err != nil {
+		return nil, err
+	}
+	err = logs.ForEach(context.Background(), func(entry logging.LogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if errors.Is(err, logging.ErrNoFilesInWindow) {
+		return entries, nil
+	}
+	return entries, err
+}
+
+// boundTimes returns the times of the first and the last valid log lines of a text.
+func boundTimes(text string, format logging.Format) (first, last time.Time, err error) {
+	lines := strings.Split(strings.TrimRight(text, "\r\n"), "\n")
+	for _, line := range lines {
+		if first, err = format.ParseTime(strings.TrimRight(line, "\r")); err == nil {
+			break
+		}
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if last, err = format.ParseTime(strings.TrimRight(lines[i], "\r")); err == nil {
+			return first, last, nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("no log line of the %s format", format)
+}
+
+// textName is the name of the log file of a textFS.
+const textName = "access.log"
+
+// textFS is an in-memory file system of a single log file, named textName, of a given text.
+type textFS struct {
+	text    string
+	modTime time.Time
+}
+
+func (t textFS) Open(name string) (fs.File, error) {
+	if name != textName {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return textFile{Reader: strings.NewReader(t.text), fs: t}, nil
+}
+
+func (t textFS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return textInfo{fs: t, dir: true}, nil
+	}
+	if name != textName {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return textInfo{fs: t}, nil
+}
+
+func (t textFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return []fs.DirEntry{fs.FileInfoToDirEntry(textInfo{fs: t})}, nil
+}
+
+// textFile is the open log file of a textFS, which can be read at offsets and seeked (see logging.LogFile).
+type textFile struct {
+	*strings.Reader
+	fs textFS
+}
+
+func (f textFile) Stat() (fs.FileInfo, error) {
+	return textInfo{fs: f.fs}, nil
+}
+
+func (textFile) Close() error {
+	return nil
+}
+
+// textInfo describes the log file of a textFS, or its root directory.
+type textInfo struct {
+	fs  textFS
+	dir bool
+}
+
+func (i textInfo) Name() string {
+	if i.dir {
+		return "."
+	}
+	return textName
+}
+
+func (i textInfo) Size() int64 {
+	if i.dir {
+		return 0
+	}
+	return int64(len(i.fs.text))
+}
+
+func (i textInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i textInfo) ModTime() time.Time {
+	return i.fs.modTime
+}
+
+func (i textInfo) IsDir() bool {
+	return i.dir
+}
+
+func (textInfo) Sys() interface{} {
+	return nil
+}
+
+// jsValue converts a given value to a JavaScript value through JSON.
+func jsValue(v interface{}) (js.Value, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return js.Undefined(), err
+	}
+	return js.Global().Get("JSON").Call("parse", buf.String()), nil
+}
+
+// function wraps a given function as a JavaScript function, returning its error as {error: message}.
+func function(fn func(args []js.Value) (interface{}, error)) js.Func {
+	return js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		result, err := fn(args)
+		if err == nil {
+			var value js.Value
+			if value, err = jsValue(result); err == nil {
+				return value
+			}
+		}
+		return map[string]interface{}{"error": err.Error()}
+	})
+}
+
+// arg returns the string of the argument at a given index, empty when missing.
+func arg(args []js.Value, i int) string {
+	if i >= len(args) || args[i].Type() != js.TypeString {
+		return ""
+	}
+	return args[i].String()
+}
+
+func main() {
+	js.Global().Set("logReader", map[string]interface{}{
+		"window": function(func(args []js.Value) (interface{}, error) {
+			var opts windowOptions
+			if len(args) > 1 && args[1].Type() == js.TypeObject {
+				if err := json.Unmarshal([]byte(js.Global().Get("JSON").Call("stringify", args[1]).String()), &opts); err != nil {
+					return nil, fmt.Errorf("invalid options: %w", err)
+				}
+			}
+			return window(arg(args, 0), opts)
+		}),
+		"parse": function(func(args []js.Value) (interface{}, error) {
+			return logging.Format(arg(args, 1)).ParseLine(arg(args, 0))
+		}),
+	})
+	// the functions are called for as long as the page lives
+	select {}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/chill-and-code/apache-log-reader/alert"
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// runAlert runs the alert subcommand, following the logs written from now on and evaluating
+// the -alert rules against them until interrupted, e.g. rate(status>=500) > 10/1m.
+// The alerts are logged, and posted as JSON to the -alert-webhook when given.
+func runAlert(args []string) {
+	fs := flag.NewFlagSet("alert", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	var rulesFlag stringsFlag
+	fs.Var(&rulesFlag, "alert", "an alerting rule, repeatable: rate(CONDITION) or ratio(CONDITION) compared with a threshold per window, e.g. 'rate(status>=500) > 10/1m' or 'ratio(status==5xx && path=~^/api) > 5%/5m'")
+	webhookFlag := fs.String("alert-webhook", "", "the URL to post the alerts to as JSON when the rules start and stop firing")
+	newChats := chatFlags(fs, "alert", "the alerts", false)
+	intervalFlag := fs.Duration("interval", alert.DefaultEvaluationInterval, "how often the rules are evaluated when no log entries are written")
+	parseFlags(fs, "alert", args)
+
+	if len(rulesFlag) == 0 {
+		exit(exitUsage, "invalid configuration: at least one -alert rule is required")
+	}
+	rules := make([]*alert.Rule, 0, len(rulesFlag))
+	for _, expr := range rulesFlag {
+		rule, err := alert.ParseRule(expr)
+		if err != nil {
+			exit(exitUsage, "invalid configuration: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+	notifiers := []alert.Notifier{logNotifier{}}
+	if *webhookFlag != "" {
+		webhook, err := alert.NewWebhook(*webhookFlag)
+		if err != nil {
+			exit(exitUsage, "invalid configuration: %v", err)
+		}
+		notifiers = append(notifiers, webhook)
+	}
+	chats, err := newChats()
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	for _, chat := range chats {
+		notifiers = append(notifiers, chat)
+	}
+
+	// classify the bots so the rules can compare the bot field
+	cfg, err := logsConfig(true)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	logs, err := logging.NewLogs(cfg)
+	if err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+
+	agent := alert.NewAgent(rules, notifiers,
+		alert.WithEvaluationInterval(*intervalFlag),
+		alert.WithErrorHandler(func(err error) {
+			log.Printf("could not deliver alert: %v", err)
+		}),
+	)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("evaluating %d alerting rules against the logs of %s", len(rules), logsSource(cfg))
+	// following the logs only stops once interrupted
+	if err := agent.Run(ctx, logs.Follow); err != nil && ctx.Err() == nil {
+		exit(exitIOFailure, "could not follow logs: %v", err)
+	}
+}
+
+// chatFlags defines the flags of the Slack and Teams notifiers on a given flag set, prefixed by a given name
+// (e.g. -alert-slack), returning a function creating the notifiers given by the flags once they are parsed.
+// The template flag sets the template of the summaries when told so, the one of the alerts otherwise.
+func chatFlags(fs *flag.FlagSet, prefix, what string, summary bool) func() ([]*alert.Chat, error) {
+	slackFlag := fs.String(prefix+"-slack", "", "the URL of the Slack incoming webhook to post "+what+" to")
+	teamsFlag := fs.String(prefix+"-teams", "", "the URL of the Microsoft Teams incoming webhook to post "+what+" to")
+	defaultTemplate := alert.DefaultAlertTemplate
+	if summary {
+		defaultTemplate = alert.DefaultSummaryTemplate
+	}
+	templateFlag := fs.String(prefix+"-template", "", "the Go template of the Slack and Teams messages, or @file to read it from a file, by default:\n"+defaultTemplate)
+
+	return func() ([]*alert.Chat, error) {
+		tmpl := *templateFlag
+		if strings.HasPrefix(tmpl, "@") {
+			b, err := os.ReadFile(tmpl[1:])
+			if err != nil {
+				return nil, err
+			}
+			tmpl = string(b)
+		}
+		cfg := alert.ChatConfig{AlertTemplate: tmpl}
+		if summary {
+			cfg = alert.ChatConfig{SummaryTemplate: tmpl}
+		}
+
+		var chats []*alert.Chat
+		if *slackFlag != "" {
+			cfg.URL = *slackFlag
+			slack, err := alert.NewSlack(cfg)
+			if err != nil {
+				return nil, err
+			}
+			chats = append(chats, slack)
+		}
+		if *teamsFlag != "" {
+			cfg.URL = *teamsFlag
+			teams, err := alert.NewTeams(cfg)
+			if err != nil {
+				return nil, err
+			}
+			chats = append(chats, teams)
+		}
+		return chats, nil
+	}
+}
+
+// logNotifier is a Notifier logging the alerts.
+type logNotifier struct{}
+
+func (logNotifier) Notify(_ context.Context, a alert.Alert) error {
+	log.Printf("alert %s: %s (value %g, threshold %g, %d of %d requests within %s)",
+		a.State, a.Rule, a.Value, a.Threshold, a.Matched, a.Requests, a.Window)
+	return nil
+}
+
+// stringsFlag is a flag which can be given several times, collecting its values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// archiveManifest is the name of the manifest inside the archives, see runArchive.
+const archiveManifest = "manifest.json"
+
+// runArchive runs the archive subcommand, packaging the log lines of a time range into a tar.gz bundle,
+// e.g. to hand an incident over: the lines of every log file, compressed rotations included (see logging.Logs.Backfill),
+// are written to a file of the same name holding only the byte range of the time range, rather than the whole file,
+// along with a manifest of the byte ranges and the checksums of the files (see -manifest).
+// The files are laid out under a directory named after the archive, the ones of the hosts (see -hosts) under their names.
+func runArchive(args []string) {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	fromFlag := fs.String("from", "", "the start of the time range to archive, as RFC 3339 (e.g. 2022-03-01T00:00:00Z) or a UTC date (e.g. 2022-03-01)")
+	toFlag := fs.String("to", "", "the end of the time range to archive, excluded, in the same layouts as -from, now by default")
+	outputFlag := fs.String("o", "", "the archive to write, e.g. incident-123.tar.gz")
+	parseFlags(fs, "archive", args)
+	if *outputFlag == "" {
+		exit(exitUsage, "invalid configuration: -o must be given")
+	}
+	from, to := parseTimeRange(*fromFlag, *toFlag)
+
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	cfg.LastNMinutes, cfg.Window, cfg.End = 0, to.Sub(from), to
+	logs, err := logging.NewLogs(cfg)
+	var configErr *logging.ConfigError
+	if errors.As(err, &configErr) {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "log-reader-archive")
+	if err != nil {
+		exit(exitIOFailure, "could not create archive: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	split := &splitter{dir: dir, key: sourceKey, files: make(map[string]*outputFile)}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = split.run(ctx, logs.Backfill)
+	if ctx.Err() != nil {
+		_ = os.RemoveAll(dir)
+		exit(exitInterrupted, "interrupted")
+	}
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		_ = os.RemoveAll(dir)
+		exit(exitIOFailure, "could not read logs: %v", err)
+	}
+
+	m := newManifest(cfg, time.Now(), split.outputs())
+	for i := range m.Files {
+		m.Files[i].Path = archivePath(dir, m.Files[i].Path)
+	}
+	if err := writeArchive(*outputFlag, dir, m); err != nil {
+		_ = os.RemoveAll(dir)
+		exit(exitIOFailure, "could not write archive: %v", err)
+	}
+	log.Printf("archived %d bytes of %d log files from %s to %s into %s",
+		split.written, len(m.Files), from.Format(time.RFC3339), to.Format(time.RFC3339), *outputFlag)
+}
+
+// sourceKey returns the name of the log file of a log entry, without its compression suffix (e.g. access.log.2
+// for access.log.2.gz, the lines being written uncompressed), under the name of its host, if any.
+func sourceKey(entry logging.LogEntry) string {
+	name := strings.TrimSuffix(filepath.Base(entry.File), ".gz")
+	if entry.Host != "" {
+		return filepath.Join(vhostKey(logging.LogEntry{VHost: entry.Host}), name)
+	}
+	return name
+}
+
+// archivePath returns the path of a file of a given directory relative to it, with forward slashes.
+func archivePath(dir, name string) string {
+	rel, err := filepath.Rel(dir, name)
+	if err != nil {
+		return name
+	}
+	return filepath.ToSlash(rel)
+}
+
+// writeArchive writes the files of a given directory to a tar.gz archive, along with their manifest,
+// under a directory named after the archive, e.g. incident-123/ for incident-123.tar.gz.
+// The archive is written under a temporary name first, so it's complete once it exists.
+func writeArchive(name, dir string, m manifest) error {
+	root := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(name), ".tar.gz"), ".tgz")
+	var manifestJSON bytes.Buffer
+	if err := printJSON(&manifestJSON, m); err != nil {
+		return err
+	}
+
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = tw.WriteHeader(&tar.Header{
+		Name: path.Join(root, archiveManifest), Mode: 0644, Size: int64(manifestJSON.Len()), ModTime: m.Created,
+	})
+	if err == nil {
+		_, err = tw.Write(manifestJSON.Bytes())
+	}
+	paths := make([]string, 0, len(m.Files))
+	for _, file := range m.Files {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err != nil {
+			break
+		}
+		err = addArchiveFile(tw, path.Join(root, p), filepath.Join(dir, filepath.FromSlash(p)))
+	}
+	for _, c := range []io.Closer{tw, gz} {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+// addArchiveFile writes a given file to a tar archive under a given name.
+func addArchiveFile(tw *tar.Writer, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	// the owner of the temporary files means nothing to the recipient of the archive
+	header.Name, header.Uid, header.Gid, header.Uname, header.Gname = name, 0, 0, "", ""
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// auditLog appends a JSON line per query to a file (see -audit-log): a line per request of the API of serve, e.g.
+//
+//	{"time":"2024-05-12T10:00:00Z","remote":"10.0.0.1:51234","client":"ops","method":"GET","path":"/logs","query":"minutes=5","status":200,"duration_ms":12,"files":["/var/log/apache2/access.log"],"bytes":5120}
+//
+// the tokens given by the query being left out, or a line per run of the jobs of daemon (see daemonAuditEntry).
+// The file is only ever appended to, and rotated once it reaches a maximum size, if any: it's renamed to <file>.1,
+// the previous rotations being shifted to <file>.2 and so on, and the oldest ones past a number of them removed.
+// A nil auditLog logs nothing.
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+	// f is the file written to, nil for stderr
+	f    *os.File
+	path string
+	// size is the size of the file written to, rotated once past maxBytes (no rotation when 0), keep rotations being kept
+	size, maxBytes int64
+	keep           int
+}
+
+// auditEntry is a line of the audit log of serve.
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	Remote     string    `json:"remote"`
+	Client     string    `json:"client"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Query      string    `json:"query,omitempty"`
+	Status     int       `json:"status"`
+	DurationMS int64     `json:"duration_ms"`
+	// Files are the log files read to answer the request, none when answered from the cache (see -cache-ttl)
+	Files []string `json:"files,omitempty"`
+	// Bytes counts the bytes of the body of the response
+	Bytes int64 `json:"bytes"`
+}
+
+// daemonAuditEntry is a line of the audit log of daemon, about a run of its job.
+type daemonAuditEntry struct {
+	Time time.Time `json:"time"`
+	// Source is the named source of the configuration file the job was run for, if any.
+	Source     string `json:"source,omitempty"`
+	Job        string `json:"job"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// openAuditLog opens the audit log of a given path, appending to it, or stderr for -, rotating it once it reaches
+// a given number of megabytes (MiB), if any, and keeping a given number of rotations.
+func openAuditLog(path string, maxMB float64, keep int) (*auditLog, error) {
+	if path == "-" {
+		return &auditLog{w: os.Stderr}, nil
+	}
+	if maxMB < 0 || keep < 1 {
+		return nil, fmt.Errorf("invalid rotation of %s: the maximum size must not be negative and a rotation at least must be kept", path)
+	}
+	a := &auditLog{path: path, maxBytes: int64(maxMB * (1 << 20)), keep: keep}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// open opens the file of the audit log for appending, creating it if needed.
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	a.f, a.w, a.size = f, f, info.Size()
+	return nil
+}
+
+// rotate renames the file of the audit log to <file>.1, shifting the previous rotations and removing the oldest one,
+// and opens a new file. The file is opened again even when it could not be renamed, the entries being appended to it.
+func (a *auditLog) rotate() error {
+	_ = a.f.Close()
+	err := a.shift()
+	if openErr := a.open(); openErr != nil {
+		a.w = io.Discard
+		return openErr
+	}
+	return err
+}
+
+// shift shifts the rotations of the audit log by one, <file> becoming <file>.1, the oldest one being removed.
+func (a *auditLog) shift() error {
+	_ = os.Remove(fmt.Sprintf("%s.%d", a.path, a.keep))
+	for i := a.keep - 1; i >= 1; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(a.path, a.path+".1")
+}
+
+// write appends a given entry to the audit log as a JSON line, rotating the file first when the line would take it
+// past its maximum size. The lines which cannot be written are reported to stderr, the query being answered all the same.
+func (a *auditLog) write(entry interface{}) {
+	if a == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f != nil && a.maxBytes > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxBytes {
+		if err := a.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "could not rotate the audit log: %v\n", err)
+		}
+	}
+	n, err := a.w.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not write the audit log: %v\n", err)
+	}
+}
+
+// log logs a given request of a given client, answered with a given status and number of bytes in a given time
+// by reading given log files.
+func (a *auditLog) log(r *http.Request, client string, status int, bytes int64, files []string, took time.Duration) {
+	if a == nil {
+		return
+	}
+	query := r.URL.Query()
+	query.Del(accessTokenParam)
+	a.write(auditEntry{
+		Time:       time.Now().UTC(),
+		Remote:     r.RemoteAddr,
+		Client:     client,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      query.Encode(),
+		Status:     status,
+		DurationMS: took.Milliseconds(),
+		Files:      files,
+		Bytes:      bytes,
+	})
+}
+
+// auditFiles records the log files read to answer a request, for the audit log, through the diagnostics of its logs
+// (see logging.LogsConfig.Logger): the files selected within the time range and the ones searched or read.
+type auditFiles struct {
+	mu    sync.Mutex
+	files map[string]bool
+}
+
+// auditFilesKey is the key of the auditFiles of a request inside its context, see auditFilesFrom.
+type auditFilesKey struct{}
+
+// auditFilesFrom returns the auditFiles of a request from its context, if it is audited.
+func auditFilesFrom(ctx context.Context) (*auditFiles, bool) {
+	files, ok := ctx.Value(auditFilesKey{}).(*auditFiles)
+	return files, ok
+}
+
+// logger returns a logging.Logger recording the files reported by the diagnostics of the logs, passing them on
+// to a given Logger, if any (see -verbose).
+func (a *auditFiles) logger(next logging.Logger) logging.Logger {
+	return &auditFilesLogger{files: a, next: next}
+}
+
+// add records the files of the arguments of a diagnostic message: the file of the ones about a file,
+// and the files of the ones about several of them.
+func (a *auditFiles) add(args []interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		switch value := args[i+1].(type) {
+		case string:
+			if args[i] == "file" {
+				a.files[value] = true
+			}
+		case []string:
+			if args[i] == "files" {
+				for _, file := range value {
+					a.files[file] = true
+				}
+			}
+		}
+	}
+}
+
+// list returns the files recorded, sorted.
+func (a *auditFiles) list() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	files := make([]string, 0, len(a.files))
+	for file := range a.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// auditFilesLogger is the logging.Logger of auditFiles.
+type auditFilesLogger struct {
+	files *auditFiles
+	next  logging.Logger
+}
+
+func (l *auditFilesLogger) Info(msg string, args ...interface{}) {
+	l.files.add(args)
+	if l.next != nil {
+		l.next.Info(msg, args...)
+	}
+}
+
+func (l *auditFilesLogger) Debug(msg string, args ...interface{}) {
+	if l.next != nil {
+		l.next.Debug(msg, args...)
+	}
+}
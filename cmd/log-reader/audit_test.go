@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type auditSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *auditSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+}
+
+// entry returns the entry of the audit log of a run of a given job, e.g. job-1.
+func (s *auditSuite) entry(job int) daemonAuditEntry {
+	return daemonAuditEntry{Time: time.Date(2022, time.March, 3, 10, 0, 0, 0, time.UTC), Job: fmt.Sprintf("job-%d", job), Status: "ok"}
+}
+
+// jobs returns the jobs of the lines of the files of the test directory by their names, e.g. audit.log.1.
+func (s *auditSuite) jobs() map[string][]string {
+	names, err := os.ReadDir(s.dir)
+	s.Require().NoError(err)
+	jobs := make(map[string][]string)
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(s.dir, name.Name()))
+		s.Require().NoError(err)
+		for _, line := range strings.SplitAfter(string(content), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry daemonAuditEntry
+			s.Require().NoError(json.Unmarshal([]byte(line), &entry))
+			jobs[name.Name()] = append(jobs[name.Name()], entry.Job)
+		}
+	}
+	return jobs
+}
+
+func (s *auditSuite) Test_auditLog_Rotation() {
+	line, err := json.Marshal(s.entry(0))
+	s.Require().NoError(err)
+	lineBytes := float64(len(line) + 1)
+	tests := []struct {
+		name string
+		// existing are the jobs already in the audit log
+		existing []int
+		// maxLines are the lines of a file before it's rotated, no rotation when 0
+		maxLines     float64
+		keep         int
+		writes       int
+		expectedJobs map[string][]string
+	}{
+		{
+			name:         "No Rotation",
+			keep:         10,
+			writes:       5,
+			expectedJobs: map[string][]string{"audit.log": {"job-1", "job-2", "job-3", "job-4", "job-5"}},
+		},
+		{
+			name:     "Rotated",
+			maxLines: 2,
+			keep:     10,
+			writes:   5,
+			expectedJobs: map[string][]string{
+				"audit.log":   {"job-5"},
+				"audit.log.1": {"job-3", "job-4"},
+				"audit.log.2": {"job-1", "job-2"},
+			},
+		},
+		{
+			name:     "Oldest Rotations Removed",
+			maxLines: 1,
+			keep:     2,
+			writes:   5,
+			expectedJobs: map[string][]string{
+				"audit.log":   {"job-5"},
+				"audit.log.1": {"job-4"},
+				"audit.log.2": {"job-3"},
+			},
+		},
+		{
+			name:     "Existing Lines",
+			existing: []int{-1, 0},
+			maxLines: 2,
+			keep:     10,
+			writes:   1,
+			expectedJobs: map[string][]string{
+				"audit.log":   {"job-1"},
+				"audit.log.1": {"job--1", "job-0"},
+			},
+		},
+		{
+			name:     "Lines Past The Maximum",
+			maxLines: 0.5,
+			keep:     10,
+			writes:   3,
+			expectedJobs: map[string][]string{
+				"audit.log":   {"job-3"},
+				"audit.log.1": {"job-2"},
+				"audit.log.2": {"job-1"},
+			},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.dir = s.T().TempDir()
+			path := filepath.Join(s.dir, "audit.log")
+			var existing strings.Builder
+			for _, job := range test.existing {
+				line, err := json.Marshal(s.entry(job))
+				s.Require().NoError(err)
+				existing.Write(append(line, '\n'))
+			}
+			if existing.Len() > 0 {
+				s.Require().NoError(os.WriteFile(path, []byte(existing.String()), 0600))
+			}
+
+			audit, err := openAuditLog(path, test.maxLines*lineBytes/(1<<20), test.keep)
+			s.Require().NoError(err)
+			for job := 1; job <= test.writes; job++ {
+				audit.write(s.entry(job))
+			}
+			s.Require().NoError(audit.f.Close())
+
+			s.Equal(test.expectedJobs, s.jobs())
+		})
+	}
+}
+
+func (s *auditSuite) Test_openAuditLog() {
+	tests := []struct {
+		name        string
+		path        string
+		maxMB       float64
+		keep        int
+		expectedErr string
+	}{
+		{
+			name: "File",
+			path: "audit.log",
+			keep: 1,
+		},
+		{
+			name:  "Stderr",
+			path:  "-",
+			maxMB: -1,
+		},
+		{
+			name:        "Negative Maximum Size",
+			path:        "audit.log",
+			maxMB:       -1,
+			keep:        1,
+			expectedErr: "the maximum size must not be negative",
+		},
+		{
+			name:        "No Rotation Kept",
+			path:        "audit.log",
+			maxMB:       1,
+			expectedErr: "a rotation at least must be kept",
+		},
+		{
+			name:        "Missing Directory",
+			path:        filepath.Join("missing", "audit.log"),
+			keep:        1,
+			expectedErr: filepath.Join("missing", "audit.log"),
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			path := test.path
+			if path != "-" {
+				path = filepath.Join(s.dir, path)
+			}
+			audit, err := openAuditLog(path, test.maxMB, test.keep)
+			if test.expectedErr != "" {
+				s.Require().Error(err)
+				s.Contains(err.Error(), test.expectedErr)
+				return
+			}
+			s.Require().NoError(err)
+			if audit.f != nil {
+				s.FileExists(path)
+				s.Require().NoError(audit.f.Close())
+			} else {
+				s.Equal(os.Stderr, audit.w)
+			}
+		})
+	}
+}
+
+func (s *auditSuite) Test_auditLog_Nil() {
+	var audit *auditLog
+	s.NotPanics(func() {
+		audit.write(s.entry(1))
+	})
+}
+
+func TestAudit(t *testing.T) {
+	suite.Run(t, new(auditSuite))
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// staticTokenName is the name of the token given by -token in the audit log, see apiToken.
+const staticTokenName = "static"
+
+// accessTokenParam is the query parameter the API tokens can be given by instead of the Authorization header,
+// e.g. by the Server-Sent Events of the dashboard which cannot set headers (see RFC 6750).
+const accessTokenParam = "access_token"
+
+// apiToken is a bearer token of the API of the serve subcommand, named for the audit log.
+type apiToken struct {
+	name, token string
+	// directory is the directory the requests of the token read instead of the one of the server, if any
+	directory string
+}
+
+// authenticator authenticates the requests of the API of the serve subcommand by their bearer tokens.
+// A nil authenticator lets every request through.
+type authenticator struct {
+	tokens []apiToken
+}
+
+// newAuthenticator creates the authenticator of a given static token (see -token) and the tokens of a given file
+// (see parseTokens), their relative directories being inside a given base directory, or returns nil without tokens.
+func newAuthenticator(static, file, baseDir string) (*authenticator, error) {
+	var tokens []apiToken
+	if static != "" {
+		tokens = append(tokens, apiToken{name: staticTokenName, token: static})
+	}
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		parsed, err := parseTokens(f, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tokens file %s: %w", file, err)
+		}
+		if len(parsed) == 0 {
+			// an empty file would open the API to everyone
+			return nil, fmt.Errorf("invalid tokens file %s: no tokens", file)
+		}
+		tokens = append(tokens, parsed...)
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return &authenticator{tokens: tokens}, nil
+}
+
+// parseTokens parses the API tokens of a tokens file, one per line made of its name, the token and optionally
+// the directory it is scoped to, relative to a given base directory unless absolute, e.g.
+//
+//	# name token [directory]
+//	ops   3f9a...c2
+//	shop  77b1...e0  shop
+//
+// Blank lines and lines starting with # are ignored.
+func parseTokens(r io.Reader, baseDir string) ([]apiToken, error) {
+	var tokens []apiToken
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: must be a name, a token and optionally a directory", line)
+		}
+		if names[fields[0]] {
+			return nil, fmt.Errorf("line %d: duplicate name '%s'", line, fields[0])
+		}
+		names[fields[0]] = true
+		token := apiToken{name: fields[0], token: fields[1]}
+		if len(fields) == 3 {
+			token.directory = fields[2]
+			if !filepath.IsAbs(token.directory) {
+				token.directory = filepath.Join(baseDir, token.directory)
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, scanner.Err()
+}
+
+// authenticate returns the token of a given request, given by its Authorization header (Bearer) or its access_token
+// query parameter, or false when missing or unknown. The tokens are compared in constant time.
+func (a *authenticator) authenticate(r *http.Request) (apiToken, bool) {
+	given := r.URL.Query().Get(accessTokenParam)
+	if auth := r.Header.Get("Authorization"); len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		given = auth[len("Bearer "):]
+	}
+	if given == "" {
+		return apiToken{}, false
+	}
+	found, ok := apiToken{}, false
+	for _, token := range a.tokens {
+		// every token is compared, so the time taken doesn't tell which one almost matched
+		if subtle.ConstantTimeCompare([]byte(token.token), []byte(given)) == 1 {
+			found, ok = token, true
+		}
+	}
+	return found, ok
+}
+
+// tokenKey is the key of the token of an authenticated request inside its context, see tokenFrom.
+type tokenKey struct{}
+
+// tokenFrom returns the token a request was authenticated with from its context, if any.
+func tokenFrom(ctx context.Context) (apiToken, bool) {
+	token, ok := ctx.Value(tokenKey{}).(apiToken)
+	return token, ok
+}
+
+// unauthenticatedPaths are the paths answered without a token: the probes of orchestration platforms
+// and the page of the dashboard, which data is requested with the token given in the fragment of its URL.
+var unauthenticatedPaths = map[string]bool{"/healthz": true, "/readyz": true, "/": true}
+
+// probePaths are the paths of the probes of orchestration platforms, which requests are not rate limited.
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// protect makes sure the requests of a given handler are authenticated by the authenticator of the server, if any
+// (see authenticator), answering 401 Unauthorized otherwise, limits their rate per client (see clientLimiter)
+// and logs them to a given audit log, if any.
+func (srv *server) protect(next http.Handler, audit *auditLog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		client := clientName(r)
+		var files *auditFiles
+		if audit != nil {
+			files = &auditFiles{files: make(map[string]bool)}
+			r = r.WithContext(context.WithValue(r.Context(), auditFilesKey{}, files))
+		}
+		if auth := srv.authenticator(); auth == nil || unauthenticatedPaths[r.URL.Path] || sourceDashboard(r.URL.Path) {
+			if probePaths[r.URL.Path] || srv.limiter.limit(rec, r) {
+				next.ServeHTTP(rec, r)
+			}
+		} else if token, ok := auth.authenticate(r); !ok {
+			rec.Header().Set("WWW-Authenticate", `Bearer realm="log-reader"`)
+			http.Error(rec, "unauthorized", http.StatusUnauthorized)
+		} else {
+			client = token.name
+			r = r.WithContext(context.WithValue(r.Context(), tokenKey{}, token))
+			if srv.limiter.limit(rec, r) {
+				next.ServeHTTP(rec, r)
+			}
+		}
+		if audit != nil {
+			audit.log(r, client, rec.status, rec.bytes, files.list(), time.Since(start))
+		}
+	})
+}
+
+// authenticator returns the current authenticator of the server, see server.reload.
+func (srv *server) authenticator() *authenticator {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	return srv.auth
+}
+
+// clientName returns the name of the client certificate of a request (mTLS), if any, or "-".
+func clientName(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return "cn:" + r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return "-"
+}
+
+// statusRecorder records the status and the size of a response for the audit log, streaming the responses it flushes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(data []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(data)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying response, so the Server-Sent Events (e.g. /tail) are streamed through the recorder.
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// serverTLS returns the TLS configuration of the server of a given certificate and key (PEM files), requiring
+// the clients to present a certificate signed by the authorities of a given PEM file (mTLS) when given.
+func serverTLS(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cas := x509.NewCertPool()
+		if !cas.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", clientCAFile)
+		}
+		cfg.ClientCAs = cas
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
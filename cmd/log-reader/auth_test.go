@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type authSuite struct {
+	suite.Suite
+	dir, shopDir string
+	handler      http.Handler
+}
+
+func (s *authSuite) SetupTest() {
+	s.dir = writeLogDir(s.T(), "/home")
+	s.shopDir = writeLogDir(s.T(), "/cart")
+	auth := &authenticator{tokens: []apiToken{
+		{name: staticTokenName, token: "static-token"},
+		{name: "ops", token: "ops-token"},
+		{name: "shop", token: "shop-token", directory: s.shopDir},
+	}}
+	named := map[string]*logSource{"shop": {name: "shop", cfg: logging.LogsConfig{Directory: s.shopDir, LastNMinutes: 60}}}
+	s.handler = newTestServer(s.dir, auth, named).handler(nil, true)
+}
+
+func (s *authSuite) Test_protect() {
+	tests := []struct {
+		name, target, token string
+		status              int
+		// body is a part of the body of the response, if any
+		body string
+	}{
+		{name: "no token", target: "/logs", status: http.StatusUnauthorized},
+		{name: "unknown token", target: "/logs", token: "wrong-token", status: http.StatusUnauthorized},
+		{name: "prefix of a token", target: "/logs", token: "ops", status: http.StatusUnauthorized},
+		{name: "static token", target: "/logs", token: "static-token", status: http.StatusOK, body: "/home"},
+		{name: "token of the tokens file", target: "/logs", token: "ops-token", status: http.StatusOK, body: "/home"},
+		{name: "token scoped to a directory", target: "/logs", token: "shop-token", status: http.StatusOK, body: "/cart"},
+		{name: "query parameter", target: "/logs?access_token=ops-token", status: http.StatusOK, body: "/home"},
+		{name: "unknown query parameter", target: "/logs?access_token=wrong-token", status: http.StatusUnauthorized},
+		{name: "stats without a token", target: "/stats", status: http.StatusUnauthorized},
+		{name: "top without a token", target: "/top", status: http.StatusUnauthorized},
+		{name: "timeseries without a token", target: "/timeseries", status: http.StatusUnauthorized},
+		{name: "grafana without a token", target: "/grafana/search", status: http.StatusUnauthorized},
+		{name: "metrics without a token", target: "/metrics", status: http.StatusUnauthorized},
+		{name: "sources without a token", target: "/sources", status: http.StatusUnauthorized},
+		{name: "logs of a source without a token", target: "/sources/shop/logs", status: http.StatusUnauthorized},
+		{name: "liveness probe", target: "/healthz", status: http.StatusOK},
+		{name: "readiness probe", target: "/readyz", status: http.StatusOK},
+		{name: "dashboard", target: "/", status: http.StatusOK},
+		{name: "dashboard of a source", target: "/sources/shop/", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			w := serveRequest(s.handler, test.target, test.token)
+
+			s.Equal(test.status, w.Code, w.Body.String())
+			if test.status == http.StatusUnauthorized {
+				s.Equal(`Bearer realm="log-reader"`, w.Header().Get("WWW-Authenticate"))
+			}
+			s.Contains(w.Body.String(), test.body)
+		})
+	}
+}
+
+func (s *authSuite) Test_protect_ScopedToken() {
+	// the token scoped to a directory never reads the one of the server
+	w := serveRequest(s.handler, "/logs", "shop-token")
+
+	s.Equal(http.StatusOK, w.Code)
+	s.NotContains(w.Body.String(), "/home")
+}
+
+func (s *authSuite) Test_protect_NoAuthenticator() {
+	handler := newTestServer(s.dir, nil, nil).handler(nil, false)
+
+	w := serveRequest(handler, "/logs", "")
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Contains(w.Body.String(), "/home")
+}
+
+func (s *authSuite) Test_authenticate() {
+	auth := &authenticator{tokens: []apiToken{{name: "ops", token: "ops-token"}, {name: "shop", token: "shop-token", directory: s.shopDir}}}
+	tests := []struct {
+		name, header, target string
+		expected             string
+		ok                   bool
+	}{
+		{name: "bearer", header: "Bearer ops-token", target: "/logs", expected: "ops", ok: true},
+		{name: "case insensitive scheme", header: "bearer shop-token", target: "/logs", expected: "shop", ok: true},
+		{name: "query parameter", target: "/logs?access_token=shop-token", expected: "shop", ok: true},
+		{name: "header over query parameter", header: "Bearer ops-token", target: "/logs?access_token=shop-token", expected: "ops", ok: true},
+		{name: "basic scheme", header: "Basic ops-token", target: "/logs"},
+		{name: "empty bearer", header: "Bearer ", target: "/logs"},
+		{name: "none", target: "/logs"},
+		{name: "unknown", header: "Bearer other-token", target: "/logs"},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			r := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+
+			token, ok := auth.authenticate(r)
+
+			s.Equal(test.ok, ok)
+			s.Equal(test.expected, token.name)
+		})
+	}
+}
+
+func (s *authSuite) Test_parseTokens() {
+	tokens, err := parseTokens(strings.NewReader(`# name token [directory]
+ops   ops-token
+
+shop  shop-token  shop
+  # indented comment
+blog  blog-token  /var/log/blog
+`), "/var/log/apache2")
+
+	s.Require().NoError(err)
+	s.Equal([]apiToken{
+		{name: "ops", token: "ops-token"},
+		{name: "shop", token: "shop-token", directory: "/var/log/apache2/shop"},
+		{name: "blog", token: "blog-token", directory: "/var/log/blog"},
+	}, tokens)
+}
+
+func (s *authSuite) Test_parseTokens_Invalid() {
+	tests := []struct {
+		name, content, expected string
+	}{
+		{name: "no token", content: "ops\n", expected: "line 1: must be a name, a token and optionally a directory"},
+		{name: "too many fields", content: "# comment\nops ops-token shop extra\n", expected: "line 2: must be a name, a token and optionally a directory"},
+		{name: "duplicate name", content: "ops ops-token\nops other-token\n", expected: "line 2: duplicate name 'ops'"},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			_, err := parseTokens(strings.NewReader(test.content), "/var/log/apache2")
+
+			s.EqualError(err, test.expected)
+		})
+	}
+}
+
+func (s *authSuite) Test_newAuthenticator() {
+	empty := filepath.Join(s.T().TempDir(), "tokens")
+	s.Require().NoError(os.WriteFile(empty, []byte("# no tokens yet\n"), 0600))
+
+	auth, err := newAuthenticator("", "", s.dir)
+	s.NoError(err)
+	s.Nil(auth)
+
+	// an empty file would open the API to everyone
+	_, err = newAuthenticator("", empty, s.dir)
+	s.EqualError(err, "invalid tokens file "+empty+": no tokens")
+}
+
+func (s *authSuite) Test_sourceDashboard() {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "/sources/shop/", expected: true},
+		{path: "/sources/shop", expected: true},
+		{path: "/sources/", expected: false},
+		{path: "/sources", expected: false},
+		{path: "/sources/shop/logs", expected: false},
+		{path: "/sources/shop/stats/stream", expected: false},
+		{path: "/logs", expected: false},
+	}
+	for _, test := range tests {
+		s.Run(test.path, func() {
+			s.Equal(test.expected, sourceDashboard(test.path))
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	suite.Run(t, new(authSuite))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+	"github.com/chill-and-code/apache-log-reader/ship"
+)
+
+// backfillTimeLayouts are the layouts of the times of -from and -to, the ones without time zone being UTC.
+var backfillTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// runBackfill runs the backfill subcommand, shipping the log entries of a historical time range to the sink given
+// by the flags, e.g. to populate a new analytics store from old logs. All the files covering the time range are read,
+// including the compressed rotations (see logging.Logs.Backfill), at the rates given by -max-rate and -max-read-mbps.
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	newSink := sinkFlags(fs)
+	fromFlag := fs.String("from", "", "the start of the time range to ship, as RFC 3339 (e.g. 2022-03-01T00:00:00Z) or a UTC date (e.g. 2022-03-01)")
+	toFlag := fs.String("to", "", "the end of the time range to ship, excluded, in the same layouts as -from, now by default")
+	maxRateFlag := fs.Float64("max-rate", 0, "the maximum number of log entries shipped per second, so the sink isn't overwhelmed, 0 for no limit")
+	batchSizeFlag := fs.Int("batch-size", ship.DefaultBatchSize, "the maximum number of log entries shipped at once")
+	retriesFlag := fs.Int("retries", ship.DefaultRetries, "the number of times a failed batch is retried, with an exponential backoff")
+	parseFlags(fs, "backfill", args)
+
+	from, to := parseTimeRange(*fromFlag, *toFlag)
+
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	cfg.LastNMinutes, cfg.Window, cfg.End = 0, to.Sub(from), to
+	cfg.Metrics = &logging.Metrics{}
+	logs, err := logging.NewLogs(cfg)
+	var configErr *logging.ConfigError
+	if errors.As(err, &configErr) {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+	sink, err := newSink()
+	if err != nil {
+		exit(exitUsage, "invalid sink: %v", err)
+	}
+
+	shipper := ship.NewShipper(sink,
+		ship.WithBatchSize(*batchSizeFlag),
+		ship.WithRetries(*retriesFlag, ship.DefaultBackoff),
+		ship.WithMaxRate(*maxRateFlag),
+	)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	start := time.Now()
+	err = shipper.Ship(ctx, logs.Backfill)
+	if closeErr := sink.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("could not close sink: %w", closeErr)
+	}
+	log.Printf("backfilled %d log entries from %s to %s in %s", shipper.Shipped(), from.Format(time.RFC3339), to.Format(time.RFC3339), time.Since(start).Round(time.Millisecond))
+	if ctx.Err() != nil {
+		exit(exitInterrupted, "interrupted")
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not backfill logs: %v", err)
+	}
+}
+
+// parseTimeRange parses the time range given by -from and -to, the latter being now by default,
+// exiting when invalid.
+func parseTimeRange(fromFlag, toFlag string) (time.Time, time.Time) {
+	if fromFlag == "" {
+		exit(exitUsage, "invalid configuration: -from must be given")
+	}
+	from, err := parseBackfillTime(fromFlag)
+	if err != nil {
+		exit(exitUsage, "invalid -from: %v", err)
+	}
+	to := time.Now().UTC()
+	if toFlag != "" {
+		if to, err = parseBackfillTime(toFlag); err != nil {
+			exit(exitUsage, "invalid -to: %v", err)
+		}
+	}
+	if !from.Before(to) {
+		exit(exitUsage, "invalid configuration: -from must be before -to")
+	}
+	return from, to
+}
+
+// parseBackfillTime parses the time of -from or -to in one of backfillTimeLayouts.
+func parseBackfillTime(s string) (time.Time, error) {
+	for _, layout := range backfillTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("'%s' must be RFC 3339, e.g. 2022-03-01T00:00:00Z, or a date, e.g. 2022-03-01", s)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// The output formats of the ban lists.
+const (
+	outputFail2ban = "fail2ban"
+	outputIPSet    = "ipset"
+)
+
+// banOptions configures the ban lists of the offending clients.
+type banOptions struct {
+	// ipset is the name of the ipset of the IPv4 clients, the IPv6 ones being in the one suffixed by 6.
+	ipset string
+	// timeout is how long the clients stay in the ipset, forever when 0.
+	timeout time.Duration
+	// command is the command run for every offending client, which IP replaces {ip}, e.g. fail2ban-client set apache banip {ip}.
+	command string
+}
+
+// ban is a client to ban, along with the reason why.
+type ban struct {
+	ip     string
+	reason string
+}
+
+// rateLimitBans returns the bans of the clients exceeding a rate.
+func rateLimitBans(offenders []logging.RateLimitOffender, rate logging.Rate) []ban {
+	bans := make([]ban, 0, len(offenders))
+	for _, o := range offenders {
+		bans = append(bans, ban{ip: o.IP, reason: fmt.Sprintf("exceeded %s with a peak of %d requests", rate, o.Peak)})
+	}
+	return bans
+}
+
+// suspiciousBans returns the bans of the suspicious clients.
+func suspiciousBans(clients []logging.SuspiciousClient) []ban {
+	bans := make([]ban, 0, len(clients))
+	for _, client := range clients {
+		reasons := make([]string, 0, len(client.Reasons))
+		for _, reason := range client.Reasons {
+			reasons = append(reasons, fmt.Sprintf("%s (%d)", reason.Value, reason.Count))
+		}
+		bans = append(bans, ban{ip: client.IP, reason: "suspicious: " + strings.Join(reasons, ", ")})
+	}
+	return bans
+}
+
+// validBans returns the bans of valid IPs only, the logs possibly holding hostnames (HostnameLookups)
+// or anything forged, which must not reach a firewall or a command.
+func validBans(bans []ban) []ban {
+	valid := make([]ban, 0, len(bans))
+	for _, b := range bans {
+		if net.ParseIP(b.ip) == nil {
+			log.Printf("skipping the ban of '%s': not an ip", b.ip)
+			continue
+		}
+		valid = append(valid, b)
+	}
+	return valid
+}
+
+// printBans prints the bans in the given output format, fail2ban or ipset, and runs the ban command
+// for each of them when given.
+func printBans(w io.Writer, bans []ban, output string, opts banOptions) error {
+	bans = validBans(bans)
+	var err error
+	switch output {
+	case outputFail2ban:
+		err = printFail2ban(w, bans, time.Now())
+	case outputIPSet:
+		err = printIPSet(w, bans, opts.ipset, opts.timeout)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.command != "" {
+		runBanCommand(opts.command, bans)
+	}
+	return nil
+}
+
+// printFail2ban prints a log line per ban, for fail2ban to watch with the log-reader filter (see contrib/fail2ban):
+//
+//	2022-03-03 02:45:00 log-reader: ban 10.0.0.1: exceeded 100/1m0s with a peak of 523 requests
+func printFail2ban(w io.Writer, bans []ban, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	for _, b := range bans {
+		fmt.Fprintf(bw, "%s log-reader: ban %s: %s\n", now.Format("2006-01-02 15:04:05"), b.ip, b.reason)
+	}
+	return bw.Flush()
+}
+
+// printIPSet prints the bans in the format of ipset restore, creating the sets if needed:
+// the IPv4 clients in the given set, the IPv6 ones in the set suffixed by 6, with the given timeout if any.
+func printIPSet(w io.Writer, bans []ban, name string, timeout time.Duration) error {
+	option := ""
+	if timeout > 0 {
+		option = fmt.Sprintf(" timeout %d", int64(timeout/time.Second))
+	}
+
+	bw := bufio.NewWriter(w)
+	created := make(map[string]bool)
+	for _, b := range bans {
+		set, family := name, "inet"
+		if net.ParseIP(b.ip).To4() == nil {
+			set, family = name+"6", "inet6"
+		}
+		if !created[set] {
+			fmt.Fprintf(bw, "create %s hash:ip family %s%s -exist\n", set, family, option)
+			created[set] = true
+		}
+		fmt.Fprintf(bw, "add %s %s%s -exist\n", set, b.ip, option)
+	}
+	return bw.Flush()
+}
+
+// runBanCommand runs a given command with sh for every ban, replacing {ip} with its IP,
+// which is also given as the LOG_READER_IP environment variable. Failures are logged.
+func runBanCommand(command string, bans []ban) {
+	for _, b := range bans {
+		cmd := exec.Command("sh", "-c", strings.ReplaceAll(command, "{ip}", b.ip))
+		cmd.Env = append(os.Environ(), "LOG_READER_IP="+b.ip)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("could not ban %s: %v", b.ip, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxCachedResults is the number of results kept by a queryCache, the oldest one being evicted first.
+const maxCachedResults = 256
+
+// queryCache caches the results of the queries of the server (see -cache-ttl), so the dashboards repeating the same
+// query every few seconds don't have the log files read over and over. A result is served until it is older than
+// the TTL, the window sliding meanwhile, or until the log files change (see logging.Logs.Version).
+// It is safe for concurrent use. A nil queryCache caches nothing.
+type queryCache struct {
+	ttl time.Duration
+	// hits and misses count the requests served from the cache or not, see server.metrics
+	hits, misses int64
+
+	mu      sync.Mutex
+	results map[string]cachedResult
+	// now is the clock of the cache, time.Now unless faked by the tests
+	now func() time.Time
+}
+
+// cachedResult is a response cached for a version of the log files.
+type cachedResult struct {
+	version     string
+	stored      time.Time
+	contentType string
+	body        []byte
+}
+
+// newQueryCache creates an empty queryCache keeping the results for a given TTL.
+func newQueryCache(ttl time.Duration) *queryCache {
+	return &queryCache{ttl: ttl, results: make(map[string]cachedResult), now: time.Now}
+}
+
+// get returns the result of a given key, unless expired or computed from another version of the log files.
+func (c *queryCache) get(key, version string) (cachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[key]
+	if !ok || result.version != version || c.now().Sub(result.stored) >= c.ttl {
+		return cachedResult{}, false
+	}
+	return result, true
+}
+
+// put stores the result of a given key, evicting the oldest result when full.
+func (c *queryCache) put(key string, result cachedResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result.stored = c.now()
+	if _, ok := c.results[key]; !ok && len(c.results) >= maxCachedResults {
+		oldest := ""
+		for k, r := range c.results {
+			if oldest == "" || r.stored.Before(c.results[oldest].stored) {
+				oldest = k
+			}
+		}
+		delete(c.results, oldest)
+	}
+	c.results[key] = result
+}
+
+// clear forgets all the results, e.g. once the configuration is reloaded.
+func (c *queryCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = make(map[string]cachedResult)
+}
+
+// cacheKey returns the key of the result of a request: its source if any (see sourceFrom), its path and its query,
+// normalized so the order of the parameters doesn't matter and left out of the access token, along with the token
+// it is scoped to (see tokenFrom).
+func cacheKey(r *http.Request) string {
+	query := url.Values{}
+	for name, values := range r.URL.Query() {
+		if name != accessTokenParam {
+			query[name] = values
+		}
+	}
+	key := r.URL.Path + "?" + query.Encode()
+	if src, ok := sourceFrom(r.Context()); ok {
+		key = sourcesPath + src.name + key
+	}
+	if token, ok := tokenFrom(r.Context()); ok {
+		key += "\x00" + token.name
+	}
+	return key
+}
+
+// cached serves the results of a given handler from the cache of the server, if any (see queryCache), telling
+// whether they were with the X-Cache header (HIT or MISS). Only the successful responses are cached.
+func (srv *server) cached(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if srv.cache == nil || r.Method != http.MethodGet {
+			next(w, r)
+			return
+		}
+		logs, err := srv.logs(r.Context(), r.URL.Query())
+		if err != nil {
+			// the handler answers the invalid queries
+			next(w, r)
+			return
+		}
+		version, err := logs.Version()
+		if err != nil {
+			next(w, r)
+			return
+		}
+		key := cacheKey(r)
+		if result, ok := srv.cache.get(key, version); ok {
+			atomic.AddInt64(&srv.cache.hits, 1)
+			w.Header().Set("Content-Type", result.contentType)
+			w.Header().Set("X-Cache", "HIT")
+			_, _ = w.Write(result.body)
+			return
+		}
+		atomic.AddInt64(&srv.cache.misses, 1)
+		w.Header().Set("X-Cache", "MISS")
+		rec := &cacheRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		if rec.status == http.StatusOK {
+			// the version is the one before the logs were read, so a change meanwhile is a miss next time
+			srv.cache.put(key, cachedResult{version: version, contentType: w.Header().Get("Content-Type"), body: rec.body.Bytes()})
+		}
+	}
+}
+
+// cacheRecorder records a response for the cache while writing it.
+type cacheRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *cacheRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *cacheRecorder) Write(p []byte) (int, error) {
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type cacheSuite struct {
+	suite.Suite
+	now   time.Time
+	cache *queryCache
+}
+
+func (s *cacheSuite) SetupTest() {
+	s.now = time.Date(2022, time.March, 3, 10, 0, 0, 0, time.UTC)
+	s.cache = newQueryCache(5 * time.Second)
+	s.cache.now = func() time.Time { return s.now }
+}
+
+func (s *cacheSuite) Test_get_TTL() {
+	s.cache.put("/stats?", cachedResult{version: "v1", body: []byte("{}")})
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		ok      bool
+	}{
+		{name: "fresh", elapsed: 0, ok: true},
+		{name: "before the TTL", elapsed: 5*time.Second - time.Nanosecond, ok: true},
+		{name: "at the TTL", elapsed: 5 * time.Second, ok: false},
+		{name: "after the TTL", elapsed: time.Minute, ok: false},
+	}
+	start := s.now
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.now = start.Add(test.elapsed)
+
+			result, ok := s.cache.get("/stats?", "v1")
+
+			s.Equal(test.ok, ok)
+			if ok {
+				s.Equal([]byte("{}"), result.body)
+			}
+		})
+	}
+}
+
+func (s *cacheSuite) Test_get_Version() {
+	s.cache.put("/stats?", cachedResult{version: "v1"})
+
+	_, ok := s.cache.get("/stats?", "v2")
+	s.False(ok)
+
+	s.cache.put("/stats?", cachedResult{version: "v2"})
+	_, ok = s.cache.get("/stats?", "v2")
+	s.True(ok)
+	_, ok = s.cache.get("/stats?", "v1")
+	s.False(ok)
+}
+
+func (s *cacheSuite) Test_put_Eviction() {
+	for i := 0; i < maxCachedResults; i++ {
+		s.cache.put("/stats?top="+strconv.Itoa(i), cachedResult{version: "v1"})
+		s.now = s.now.Add(time.Millisecond)
+	}
+	// storing a result again doesn't evict another one
+	s.cache.put("/stats?top=0", cachedResult{version: "v1"})
+	s.Len(s.cache.results, maxCachedResults)
+
+	s.cache.put("/top?", cachedResult{version: "v1"})
+
+	s.Len(s.cache.results, maxCachedResults)
+	_, ok := s.cache.get("/stats?top=1", "v1")
+	s.False(ok, "the oldest result is evicted")
+	for _, key := range []string{"/stats?top=0", "/stats?top=2", "/top?"} {
+		_, ok := s.cache.get(key, "v1")
+		s.True(ok, key)
+	}
+}
+
+func (s *cacheSuite) Test_clear() {
+	s.cache.put("/stats?", cachedResult{version: "v1"})
+
+	s.cache.clear()
+
+	_, ok := s.cache.get("/stats?", "v1")
+	s.False(ok)
+	var none *queryCache
+	s.NotPanics(none.clear)
+}
+
+func (s *cacheSuite) Test_cacheKey() {
+	shop := &logSource{name: "shop"}
+	tests := []struct {
+		name   string
+		target string
+		source *logSource
+		token  *apiToken
+		// same is the target of another request of the same key
+		same     string
+		expected string
+	}{
+		{name: "path and query", target: "/stats?minutes=5&top=3", expected: "/stats?minutes=5&top=3"},
+		{name: "order of the parameters", target: "/stats?top=3&minutes=5", expected: "/stats?minutes=5&top=3"},
+		{name: "access token", target: "/stats?access_token=ops-token&top=3", expected: "/stats?top=3"},
+		{name: "source", target: "/stats?top=3", source: shop, expected: "/sources/shop/stats?top=3"},
+		{name: "token", target: "/stats?top=3", token: &apiToken{name: "ops"}, expected: "/stats?top=3\x00ops"},
+		{name: "source and token", target: "/stats", source: shop, token: &apiToken{name: "shop"}, expected: "/sources/shop/stats?\x00shop"},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			r := httptest.NewRequest(http.MethodGet, test.target, nil)
+			ctx := r.Context()
+			if test.source != nil {
+				ctx = context.WithValue(ctx, namedSourceKey{}, test.source)
+			}
+			if test.token != nil {
+				ctx = context.WithValue(ctx, tokenKey{}, *test.token)
+			}
+
+			s.Equal(test.expected, cacheKey(r.WithContext(ctx)))
+		})
+	}
+}
+
+func (s *cacheSuite) Test_cached() {
+	dir := writeLogDir(s.T(), "/home")
+	otherDir := writeLogDir(s.T(), "/cart")
+	auth := &authenticator{tokens: []apiToken{{name: "ops", token: "ops-token"}, {name: "shop", token: "shop-token", directory: otherDir}}}
+	srv := newTestServer(dir, auth, nil)
+	srv.cache = s.cache
+	handler := srv.handler(nil, false)
+
+	s.Equal("MISS", serveRequest(handler, "/stats", "ops-token").Header().Get("X-Cache"))
+	s.Equal("HIT", serveRequest(handler, "/stats", "ops-token").Header().Get("X-Cache"))
+	s.Equal("HIT", serveRequest(handler, "/stats?access_token=ops-token", "").Header().Get("X-Cache"))
+
+	// the result of a token is not the one of another token
+	s.Equal("MISS", serveRequest(handler, "/stats", "shop-token").Header().Get("X-Cache"))
+
+	// nor the one of another query
+	s.Equal("MISS", serveRequest(handler, "/stats?top=3", "ops-token").Header().Get("X-Cache"))
+
+	// nor once the log files change
+	logFile := filepath.Join(dir, "access.log")
+	modTime := time.Now().Add(time.Second)
+	s.Require().NoError(os.Chtimes(logFile, modTime, modTime))
+	s.Equal("MISS", serveRequest(handler, "/stats", "ops-token").Header().Get("X-Cache"))
+
+	// the invalid queries are not cached
+	s.Equal(http.StatusBadRequest, serveRequest(handler, "/stats?top=-1", "ops-token").Code)
+	s.Equal("MISS", serveRequest(handler, "/stats?top=-1", "ops-token").Header().Get("X-Cache"))
+
+	s.EqualValues(2, srv.cache.hits)
+	s.EqualValues(6, srv.cache.misses)
+}
+
+func TestCache(t *testing.T) {
+	suite.Run(t, new(cacheSuite))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+const (
+	// chartWidth is the width in characters of the longest bar of the chart
+	chartWidth = 50
+	// requestBar and errorBar draw the requests and the errors (5xx) of a bar
+	requestBar = "█"
+	errorBar   = "▒"
+)
+
+// sparkBars are the bars of a sparkline, from the lowest to the highest
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// printChart prints the given time series as a sparkline of the requests and of the errors (5xx),
+// followed by a horizontal bar chart, one bar per interval, with the errors drawn at the end of each bar.
+func printChart(w io.Writer, ts *logging.TimeSeries) error {
+	requests := make([]int, len(ts.Buckets))
+	serverErrors := make([]int, len(ts.Buckets))
+	max := 0
+	for i, bucket := range ts.Buckets {
+		requests[i] = bucket.Requests
+		serverErrors[i] = bucket.Errors
+		if bucket.Requests > max {
+			max = bucket.Requests
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "requests %s\n", sparkline(requests))
+	fmt.Fprintf(&b, "5xx      %s\n\n", sparkline(serverErrors))
+
+	layout := "15:04"
+	if ts.Interval < time.Minute {
+		layout = "15:04:05"
+	}
+	for _, bucket := range ts.Buckets {
+		requestWidth, errorWidth := 0, 0
+		if max > 0 {
+			requestWidth = (bucket.Requests*chartWidth + max - 1) / max
+			errorWidth = (bucket.Errors*chartWidth + max - 1) / max
+		}
+		bar := strings.Repeat(requestBar, requestWidth-errorWidth) +
+			strings.Repeat(errorBar, errorWidth) +
+			strings.Repeat(" ", chartWidth-requestWidth)
+		fmt.Fprintf(&b, "%s %s %d", bucket.Time.Format(layout), bar, bucket.Requests)
+		if bucket.Errors > 0 {
+			fmt.Fprintf(&b, " (5xx: %d)", bucket.Errors)
+		}
+		b.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sparkline draws the given values as a line of bars, scaled to the highest value.
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	line := make([]rune, len(values))
+	for i, v := range values {
+		idx := 0
+		if max > 0 {
+			idx = v * (len(sparkBars) - 1) / max
+		}
+		line[i] = sparkBars[idx]
+	}
+	return string(line)
+}
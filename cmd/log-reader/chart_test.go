@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type chartSuite struct {
+	suite.Suite
+}
+
+func (s *chartSuite) Test_sparkline() {
+	tests := []struct {
+		name     string
+		values   []int
+		expected string
+	}{
+		{name: "empty", values: nil, expected: ""},
+		{name: "all zero", values: []int{0, 0, 0}, expected: "▁▁▁"},
+		{name: "single value", values: []int{5}, expected: "█"},
+		{name: "scaled to the max value", values: []int{0, 1, 7, 14}, expected: "▁▁▄█"},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.Equal(test.expected, sparkline(test.values))
+		})
+	}
+}
+
+func (s *chartSuite) Test_printChart() {
+	start := time.Date(2022, time.March, 3, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		series   *logging.TimeSeries
+		expected string
+	}{
+		{
+			name: "all zero",
+			series: &logging.TimeSeries{Start: start, Interval: time.Minute, Buckets: []logging.Bucket{
+				{Time: start},
+				{Time: start.Add(time.Minute)},
+			}},
+			expected: "requests ▁▁\n" +
+				"5xx      ▁▁\n\n" +
+				"10:00 " + strings.Repeat(" ", chartWidth) + " 0\n" +
+				"10:01 " + strings.Repeat(" ", chartWidth) + " 0\n",
+		},
+		{
+			name: "single bucket",
+			series: &logging.TimeSeries{Start: start, Interval: 30 * time.Second, Buckets: []logging.Bucket{
+				{Time: start, Requests: 4, Errors: 1},
+			}},
+			expected: "requests █\n" +
+				"5xx      █\n\n" +
+				"10:00:00 " + strings.Repeat(requestBar, 37) + strings.Repeat(errorBar, 13) + " 4 (5xx: 1)\n",
+		},
+		{
+			name: "scaled to the max value",
+			series: &logging.TimeSeries{Start: start, Interval: time.Minute, Buckets: []logging.Bucket{
+				{Time: start, Requests: 10},
+				{Time: start.Add(time.Minute), Requests: 3},
+			}},
+			expected: "requests █▃\n" +
+				"5xx      ▁▁\n\n" +
+				"10:00 " + strings.Repeat(requestBar, chartWidth) + " 10\n" +
+				"10:01 " + strings.Repeat(requestBar, 15) + strings.Repeat(" ", chartWidth-15) + " 3\n",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			var out bytes.Buffer
+			s.Require().NoError(printChart(&out, test.series))
+			s.Equal(test.expected, out.String())
+		})
+	}
+}
+
+func TestChart(t *testing.T) {
+	suite.Run(t, new(chartSuite))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+const (
+	baselinePrevious = "previous"
+	baselineWeek     = "week"
+)
+
+// runCompare runs the compare subcommand, printing how the requests logged within the last N minutes
+// changed compared to the preceding N minutes or to the same N minutes a week ago.
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	baselineFlag := fs.String("baseline", baselinePrevious, "the window to compare with: previous (the preceding window), week (the same window a week ago)")
+	topFlag := fs.Int("top", 5, "number of paths which requests went up and down the most to print")
+	outputFlag := fs.String("o", outputTable, "the output format: table, json")
+	parseFlags(fs, "compare", args)
+	if *outputFlag != outputTable && *outputFlag != outputJSON {
+		exit(exitUsage, "unsupported output format '%s'", *outputFlag)
+	}
+
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	var shift time.Duration
+	switch *baselineFlag {
+	case baselinePrevious:
+		shift = time.Duration(cfg.LastNMinutes) * time.Minute
+	case baselineWeek:
+		shift = 7 * 24 * time.Hour
+	default:
+		exit(exitUsage, "unsupported baseline '%s'", *baselineFlag)
+	}
+
+	cfg.End = time.Now().UTC()
+	current, err := logging.NewLogs(cfg)
+	if err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+	cfg.End = cfg.End.Add(-shift)
+	previous, err := logging.NewLogs(cfg)
+	if err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+
+	comparison, err := logging.Compare(context.Background(), current, previous)
+	if err != nil {
+		exit(exitIOFailure, "could not read logs: %v", err)
+	}
+	if *outputFlag == outputJSON {
+		err = printJSON(os.Stdout, newCompareReport(comparison, *topFlag))
+	} else {
+		err = printComparison(os.Stdout, comparison, *topFlag, "current", "previous")
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not print comparison: %v", err)
+	}
+}
+
+// compareReport is the JSON representation of a comparison.
+type compareReport struct {
+	Current        logging.StatsReport `json:"current"`
+	Previous       logging.StatsReport `json:"previous"`
+	RequestsChange float64             `json:"requests_change"`
+	ErrorRateDelta float64             `json:"error_rate_delta"`
+	PathsUp        []logging.PathDelta `json:"paths_up"`
+	PathsDown      []logging.PathDelta `json:"paths_down"`
+}
+
+func newCompareReport(comparison *logging.Comparison, top int) compareReport {
+	up, down := comparison.Paths(top)
+	return compareReport{
+		Current:        comparison.Current.Report(top),
+		Previous:       comparison.Previous.Report(top),
+		RequestsChange: comparison.RequestsChange(),
+		ErrorRateDelta: comparison.ErrorRateDelta(),
+		PathsUp:        up,
+		PathsDown:      down,
+	}
+}
+
+// printComparison prints the given comparison as an aligned table, its columns named after the given windows.
+func printComparison(w io.Writer, comparison *logging.Comparison, top int, currentName, previousName string) error {
+	current, previous := comparison.Current, comparison.Previous
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\t%s\t%s\tchange\n", currentName, previousName)
+	fmt.Fprintf(tw, "requests:\t%d\t%d\t%+.2f%%\n", current.Requests, previous.Requests, comparison.RequestsChange()*100)
+	fmt.Fprintf(tw, "unique ips:\t%d\t%d\t%+d\n", current.UniqueIPs(), previous.UniqueIPs(), current.UniqueIPs()-previous.UniqueIPs())
+	fmt.Fprintf(tw, "errors (5xx):\t%d\t%d\t%+d\n", current.Errors, previous.Errors, current.Errors-previous.Errors)
+	fmt.Fprintf(tw, "error rate:\t%.2f%%\t%.2f%%\t%+.2f pts\n", current.ErrorRate()*100, previous.ErrorRate()*100, comparison.ErrorRateDelta()*100)
+	fmt.Fprintf(tw, "total bytes:\t%d\t%d\t%+d\n", current.Bytes, previous.Bytes, current.Bytes-previous.Bytes)
+
+	up, down := comparison.Paths(top)
+	fmt.Fprintln(tw, "paths up:")
+	for _, p := range up {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%+d\n", p.Path, p.Current, p.Previous, p.Delta)
+	}
+	fmt.Fprintln(tw, "paths down:")
+	for _, p := range down {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%+d\n", p.Path, p.Current, p.Previous, p.Delta)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// configCommands are the names of the sections of a configuration file holding the flags of a single command,
+// print being the one of the main command printing the logs.
+var configCommands = map[string]bool{
+	"print": true, "stats": true, "compare": true, "serve": true, "grpc": true, "ship": true, "alert": true, "daemon": true,
+	"validate": true, "generate": true, "replay": true, "correlate": true, "backfill": true, "prune": true, "archive": true,
+	"diff": true,
+}
+
+// configPollInterval is how often the configuration file is checked for changes by the long-running commands, see onReload.
+const configPollInterval = 5 * time.Second
+
+// sourcesKey is the key of the named sources of a configuration file, see parseFlags.
+const sourcesKey = "sources"
+
+// parseFlags parses the flags of a given command from the given arguments, along with the -config flag
+// naming a YAML configuration file giving the values of the flags not set on the command line, e.g.
+//
+//	d: /var/log/apache2
+//	f: combined
+//	exclude-bots: true
+//	ship:
+//	  elasticsearch: http://localhost:9200
+//	  status: 5xx
+//	sources:
+//	  shop:
+//	    d: /var/log/apache2/shop
+//	    ship:
+//	      elasticsearch-index: shop
+//
+// The top-level keys are the flags selecting the logs, shared by all the commands (see logsFlags),
+// while the sections named after the commands hold their own flags. Lists are given as comma separated values.
+// The named sources hold their own values of the same flags, the ones of the source given by -source
+// taking precedence over the top-level ones, e.g. to cover several directories from a single file (see runDaemon).
+// The configuration file of the log directory, if any, gives the values of the flags set by neither (see applyDirConfig).
+// It exits on invalid configuration files the way the flag set does on invalid flags, returning the configuration file otherwise.
+func parseFlags(fs *flag.FlagSet, command string, args []string) string {
+	configFlag, sourceFlag, dirConfigFlag := configFlags(fs)
+	_ = fs.Parse(args)
+	if *configFlag == "" && *sourceFlag != "" {
+		exit(exitUsage, "invalid configuration: -source takes -config")
+	}
+	if *configFlag != "" {
+		if err := applyConfig(fs, command, *configFlag, *sourceFlag); err != nil {
+			exit(exitUsage, "invalid configuration file: %v", err)
+		}
+	}
+	if *dirConfigFlag {
+		if err := applyDirConfig(fs); err != nil {
+			exit(exitUsage, "invalid configuration file: %v", err)
+		}
+	}
+	return *configFlag
+}
+
+// reloadFlags parses the flags of a given command again from the given arguments the way parseFlags does,
+// e.g. once the configuration file changed, returning the errors instead of exiting so the command can keep its current flags.
+func reloadFlags(fs *flag.FlagSet, command string, args []string) error {
+	configFlag, sourceFlag, dirConfigFlag := configFlags(fs)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configFlag == "" && *sourceFlag != "" {
+		return errors.New("-source takes -config")
+	}
+	if *configFlag != "" {
+		if err := applyConfig(fs, command, *configFlag, *sourceFlag); err != nil {
+			return fmt.Errorf("invalid configuration file: %w", err)
+		}
+	}
+	if *dirConfigFlag {
+		if err := applyDirConfig(fs); err != nil {
+			return fmt.Errorf("invalid configuration file: %w", err)
+		}
+	}
+	return nil
+}
+
+// configFlags defines the -config and -source flags on a given flag set, see parseFlags, along with the -dir-config flag
+// when the commands of the flag set read a log directory (-d), false otherwise.
+func configFlags(fs *flag.FlagSet) (*string, *string, *bool) {
+	configFlag := fs.String("config", "", "the YAML configuration file giving the values of the flags not set on the command line, e.g. /etc/log-reader.yaml")
+	sourceFlag := fs.String("source", "", "the named source of the configuration file (sources section) giving its own values of the flags, e.g. shop")
+	if fs.Lookup("d") == nil {
+		return configFlag, sourceFlag, new(bool)
+	}
+	dirConfigFlag := fs.Bool("dir-config", true, "load the "+logging.DirConfigName+" file of the log directory (-d), if any, giving how its logs are parsed (f, formats, time-zone, filename-time-pattern) unless set on the command line or by -config")
+	return configFlag, sourceFlag, dirConfigFlag
+}
+
+// dirConfigKeys are the flags the configuration file of a log directory may give (see logging.DirConfigName):
+// the ones telling how its logs are parsed, rather than which files to read or what to do with them. Whoever can write
+// the log directory can write the file, so none of them may make the reader (often run as root) look outside of it,
+// e.g. by following symlinks.
+var dirConfigKeys = map[string]bool{
+	"f": true, "formats": true, "time-zone": true, "filename-time-pattern": true,
+}
+
+// applyDirConfig sets the flags of a given flag set from the configuration file of the log directory given by -d, if any
+// (see logging.DirConfigName), e.g.
+//
+//	f: error
+//	time-zone: Europe/Berlin
+//	filename-time-pattern: error-%Y%m%d.log
+//
+// so the teams owning the logs ship the settings reading them correctly along with them. The flags already set,
+// on the command line or by -config, are left alone, and so are the directories of -hosts.
+func applyDirConfig(fs *flag.FlagSet) error {
+	dir, hosts := fs.Lookup("d"), fs.Lookup("hosts")
+	if dir == nil || (hosts != nil && hosts.Value.String() != "") {
+		return nil
+	}
+	name := filepath.Join(dir.Value.String(), logging.DirConfigName)
+	config, err := readConfig(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, key := range sortedKeys(config) {
+		if !dirConfigKeys[key] {
+			keys := make([]string, 0, len(dirConfigKeys))
+			for key := range dirConfigKeys {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			return fmt.Errorf("%s: '%s' cannot be set by a log directory: must be one of %s", name, key, strings.Join(keys, ", "))
+		}
+		if err := setFlag(fs, set, key, config[key]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// onReload calls a given function on every SIGHUP, and whenever a given configuration file (if any) is modified,
+// until the context is done. The file is polled every configPollInterval.
+func onReload(ctx context.Context, configFile string, fn func()) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	// modTime returns the modified time of the configuration file, zero when there's none
+	modTime := func() time.Time {
+		if info, err := os.Stat(configFile); configFile != "" && err == nil {
+			return info.ModTime()
+		}
+		return time.Time{}
+	}
+	// loaded is the modified time of the configuration file as of the last reload
+	loaded := modTime()
+	ticker := time.NewTicker(configPollInterval)
+	go func() {
+		defer signal.Stop(hangup)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				loaded = modTime()
+				fn()
+			case <-ticker.C:
+				// a file being replaced (e.g. by configuration management) may be missing for a moment
+				if t := modTime(); !t.IsZero() && !t.Equal(loaded) {
+					loaded = t
+					fn()
+				}
+			}
+		}
+	}()
+}
+
+// withConfig adds a given configuration file and source to the arguments of a command run by the daemon (see runDaemon),
+// after the name of its subcommand if any, unless the arguments already give a configuration file.
+func withConfig(job []string, config, source string) []string {
+	if config == "" {
+		return job
+	}
+	for _, arg := range job {
+		if arg == "-config" || arg == "--config" || strings.HasPrefix(arg, "-config=") || strings.HasPrefix(arg, "--config=") {
+			return job
+		}
+	}
+	flags := []string{"-config", config}
+	if source != "" {
+		flags = append(flags, "-source", source)
+	}
+	if len(job) > 0 && !strings.HasPrefix(job[0], "-") {
+		return append(append([]string{job[0]}, flags...), job[1:]...)
+	}
+	return append(flags, job...)
+}
+
+// configSources returns the names of the sources of a given configuration file, sorted.
+func configSources(name string) ([]string, error) {
+	config, err := readConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := decodeSources(name, config)
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(sources), nil
+}
+
+// readConfig reads the top-level mapping of a given configuration file.
+func readConfig(name string) (map[string]yaml.Node, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var config map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return config, nil
+}
+
+// decodeSources returns the named sources of a given configuration, if any.
+func decodeSources(name string, config map[string]yaml.Node) (map[string]yaml.Node, error) {
+	node, ok := config[sourcesKey]
+	if !ok {
+		return nil, nil
+	}
+	var sources map[string]yaml.Node
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: %s must be a mapping of sources", name, sourcesKey)
+	}
+	if err := node.Decode(&sources); err != nil {
+		return nil, fmt.Errorf("%s: %s: %v", name, sourcesKey, err)
+	}
+	return sources, nil
+}
+
+// applyConfig sets the flags of a given command from a given configuration file and source, if any (see parseFlags),
+// leaving alone the ones set on the command line.
+func applyConfig(fs *flag.FlagSet, command, name, source string) error {
+	config, err := readConfig(name)
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// the flags of the source are applied first, taking precedence over the top-level ones
+	sources, err := decodeSources(name, config)
+	if err != nil {
+		return err
+	}
+	if source != "" {
+		node, ok := sources[source]
+		if !ok {
+			return fmt.Errorf("%s: unknown source '%s'", name, source)
+		}
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: %s: %s must be a mapping of flags", name, sourcesKey, source)
+		}
+		var values map[string]yaml.Node
+		if err := node.Decode(&values); err != nil {
+			return fmt.Errorf("%s: %s: %s: %v", name, sourcesKey, source, err)
+		}
+		if err := applyValues(fs, set, command, fmt.Sprintf("%s: %s: %s", name, sourcesKey, source), values); err != nil {
+			return err
+		}
+	}
+	delete(config, sourcesKey)
+	return applyValues(fs, set, command, name, config)
+}
+
+// applyValues sets the flags of a given command from the values of a configuration mapping (see parseFlags),
+// leaving alone the ones already set, the errors being prefixed by where the values come from.
+func applyValues(fs *flag.FlagSet, set map[string]bool, command, where string, config map[string]yaml.Node) error {
+	shared := flag.NewFlagSet("", flag.ContinueOnError)
+	logsFlags(shared)
+
+	// the section of the command is applied first, taking precedence over the shared flags
+	if section, ok := config[command]; ok {
+		if section.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: %s must be a mapping of flags", where, command)
+		}
+		var values map[string]yaml.Node
+		if err := section.Decode(&values); err != nil {
+			return fmt.Errorf("%s: %s: %v", where, command, err)
+		}
+		for _, key := range sortedKeys(values) {
+			if fs.Lookup(key) == nil {
+				return fmt.Errorf("%s: %s: unknown flag '%s'", where, command, key)
+			}
+			if err := setFlag(fs, set, key, values[key]); err != nil {
+				return fmt.Errorf("%s: %s: %v", where, command, err)
+			}
+		}
+	}
+	for _, key := range sortedKeys(config) {
+		if configCommands[key] {
+			continue
+		}
+		if shared.Lookup(key) == nil {
+			return fmt.Errorf("%s: unknown flag or command '%s'", where, key)
+		}
+		// the commands without logs to select (e.g. daemon) ignore the shared flags
+		if fs.Lookup(key) == nil {
+			continue
+		}
+		if err := setFlag(fs, set, key, config[key]); err != nil {
+			return fmt.Errorf("%s: %v", where, err)
+		}
+	}
+	return nil
+}
+
+// setFlag sets a given flag to the value of a given YAML node, unless the flag is already set,
+// marking it as set so the next values of the same flag are ignored.
+func setFlag(fs *flag.FlagSet, set map[string]bool, key string, node yaml.Node) error {
+	if set[key] {
+		return nil
+	}
+	var value string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value = node.Value
+	case yaml.SequenceNode:
+		values := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("invalid value of '%s': lists must hold plain values", key)
+			}
+			values = append(values, item.Value)
+		}
+		value = strings.Join(values, ",")
+	default:
+		return fmt.Errorf("invalid value of '%s': must be a value or a list", key)
+	}
+	if err := fs.Set(key, value); err != nil {
+		return fmt.Errorf("invalid value '%s' of '%s': %v", value, key, err)
+	}
+	set[key] = true
+	return nil
+}
+
+// sortedKeys returns the keys of a given configuration mapping sorted, so the errors are reported in a stable order.
+func sortedKeys(values map[string]yaml.Node) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
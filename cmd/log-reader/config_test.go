@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type configSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *configSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+}
+
+// writeFile writes a given file of the test directory.
+func (s *configSuite) writeFile(name, content string) string {
+	name = filepath.Join(s.dir, name)
+	s.Require().NoError(os.WriteFile(name, []byte(content), 0666))
+	return name
+}
+
+// serveFlagSet returns the flag set of the serve command, which reads the test directory, parsed from the given arguments.
+func (s *configSuite) serveFlagSet(args ...string) *flag.FlagSet {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serveFlags(fs)
+	configFlags(fs)
+	s.Require().NoError(fs.Parse(append([]string{"-d", s.dir}, args...)))
+	return fs
+}
+
+func (s *configSuite) Test_applyDirConfig() {
+	s.writeFile(logging.DirConfigName, `f: combined
+time-zone: Europe/Berlin
+filename-time-pattern: access-%Y%m%d.log
+`)
+	fs := s.serveFlagSet("-time-zone", "UTC")
+
+	s.Require().NoError(applyDirConfig(fs))
+
+	s.Equal("combined", fs.Lookup("f").Value.String())
+	s.Equal("access-%Y%m%d.log", fs.Lookup("filename-time-pattern").Value.String())
+	// set on the command line
+	s.Equal("UTC", fs.Lookup("time-zone").Value.String())
+}
+
+func (s *configSuite) Test_applyDirConfig_None() {
+	fs := s.serveFlagSet()
+
+	s.Require().NoError(applyDirConfig(fs))
+
+	s.Equal(fs.Lookup("f").DefValue, fs.Lookup("f").Value.String())
+}
+
+func (s *configSuite) Test_applyDirConfig_Hosts() {
+	// the directories of -hosts are left alone, invalid as the file is
+	s.writeFile(logging.DirConfigName, "addr: :9090\n")
+	fs := s.serveFlagSet("-hosts", "web1="+s.dir)
+
+	s.Require().NoError(applyDirConfig(fs))
+}
+
+// Test_applyDirConfig_Untrusted checks that the file of a log directory, written by whoever can write the directory,
+// can only tell how its logs are parsed: neither which files are read, nor the output, the server or its authentication.
+func (s *configSuite) Test_applyDirConfig_Untrusted() {
+	tests := []struct {
+		name    string
+		content string
+		flag    string
+	}{
+		{name: "directory", content: "d: /etc\n", flag: "d"},
+		{name: "hosts", content: "hosts: web1=/etc\n", flag: "hosts"},
+		{name: "symlinks", content: "follow-symlinks: true\n", flag: "follow-symlinks"},
+		{name: "merge", content: "merge: true\n", flag: "merge"},
+		{name: "copy", content: "copy-to: /tmp/copy.log\n", flag: "copy-to"},
+		{name: "plugins", content: "plugins: /tmp/plugin.so\n", flag: "plugins"},
+		{name: "bot patterns", content: "bot-patterns: /etc/shadow\n", flag: "bot-patterns"},
+		{name: "filter", content: "where: status>=500\n", flag: "where"},
+		{name: "address", content: "addr: :9090\n", flag: "addr"},
+		{name: "token", content: "token: secret\n", flag: "token"},
+		{name: "tokens file", content: "tokens-file: /tmp/tokens\n", flag: "tokens-file"},
+		{name: "allowed tokens", content: "allow-tokens: admin\n", flag: "allow-tokens"},
+		{name: "tls", content: "tls-client-ca: /tmp/ca.pem\n", flag: "tls-client-ca"},
+		{name: "audit log", content: "audit-log: /tmp/audit.log\n", flag: "audit-log"},
+		{name: "rate limit", content: "rate-limit: 1000/1s\n", flag: "rate-limit"},
+		{name: "serve section", content: "serve:\n  addr: :9090\n", flag: "addr"},
+		{name: "parsing key first", content: "f: combined\ntoken: secret\n", flag: "token"},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.writeFile(logging.DirConfigName, test.content)
+			fs := s.serveFlagSet()
+			before := fs.Lookup(test.flag).Value.String()
+
+			err := applyDirConfig(fs)
+
+			s.Require().Error(err)
+			s.Contains(err.Error(), "cannot be set by a log directory")
+			s.Equal(before, fs.Lookup(test.flag).Value.String())
+		})
+	}
+}
+
+func (s *configSuite) Test_dirConfigKeys() {
+	// only the flags telling how the logs are parsed, all of them selecting the logs
+	s.Equal(map[string]bool{"f": true, "formats": true, "time-zone": true, "filename-time-pattern": true}, dirConfigKeys)
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	logsFlags(fs)
+	for key := range dirConfigKeys {
+		s.NotNil(fs.Lookup(key), key)
+	}
+}
+
+func (s *configSuite) Test_configFlags_DirConfig() {
+	withDir := flag.NewFlagSet("stats", flag.ContinueOnError)
+	logsFlags(withDir)
+	configFlags(withDir)
+	s.NotNil(withDir.Lookup("dir-config"))
+
+	// e.g. generate, which writes logs rather than reading a log directory
+	withoutDir := flag.NewFlagSet("generate", flag.ContinueOnError)
+	_, _, dirConfig := configFlags(withoutDir)
+	s.Nil(withoutDir.Lookup("dir-config"))
+	s.False(*dirConfig)
+}
+
+// Test_reloadFlags_Precedence checks where the values of the flags come from: the command line first, then the source
+// given by -source, the configuration file, the configuration file of the log directory and the defaults last,
+// the section of the command taking precedence over the top-level flags within the file and the source.
+func (s *configSuite) Test_reloadFlags_Precedence() {
+	tests := []struct {
+		name           string
+		config         string
+		dirConfig      string
+		args           []string
+		expectedFormat string
+	}{
+		{
+			name:           "Defaults",
+			expectedFormat: string(logging.FormatCommon),
+		},
+		{
+			name:           "Log Directory",
+			dirConfig:      "f: dir\n",
+			expectedFormat: "dir",
+		},
+		{
+			name:           "Log Directory Ignored",
+			dirConfig:      "f: dir\n",
+			args:           []string{"-dir-config=false"},
+			expectedFormat: string(logging.FormatCommon),
+		},
+		{
+			name:           "Configuration File Over Log Directory",
+			config:         "f: top-level\n",
+			dirConfig:      "f: dir\n",
+			expectedFormat: "top-level",
+		},
+		{
+			name:           "Section Of The Command Over Top-Level",
+			config:         "f: top-level\nserve:\n  f: serve\n",
+			expectedFormat: "serve",
+		},
+		{
+			name:           "Section Of Another Command Ignored",
+			config:         "f: top-level\nstats:\n  f: stats\n",
+			expectedFormat: "top-level",
+		},
+		{
+			name:           "Source Over Configuration File",
+			config:         "f: top-level\nserve:\n  f: serve\nsources:\n  shop:\n    f: source\n",
+			args:           []string{"-source", "shop"},
+			expectedFormat: "source",
+		},
+		{
+			name:           "Section Of The Command Over Source",
+			config:         "sources:\n  shop:\n    f: source\n    serve:\n      f: source-serve\n",
+			args:           []string{"-source", "shop"},
+			expectedFormat: "source-serve",
+		},
+		{
+			name:           "Other Source Ignored",
+			config:         "f: top-level\nsources:\n  blog:\n    f: blog\n  shop: {}\n",
+			args:           []string{"-source", "shop"},
+			expectedFormat: "top-level",
+		},
+		{
+			name:           "Command Line Over All",
+			config:         "f: top-level\nserve:\n  f: serve\nsources:\n  shop:\n    f: source\n",
+			dirConfig:      "f: dir\n",
+			args:           []string{"-source", "shop", "-f", "command-line"},
+			expectedFormat: "command-line",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.dir = s.T().TempDir()
+			args := []string{"-d", s.dir}
+			if test.config != "" {
+				args = append(args, "-config", s.writeFile("config.yaml", test.config))
+			}
+			if test.dirConfig != "" {
+				s.writeFile(logging.DirConfigName, test.dirConfig)
+			}
+			fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+			serveFlags(fs)
+
+			s.Require().NoError(reloadFlags(fs, "serve", append(args, test.args...)))
+
+			s.Equal(test.expectedFormat, fs.Lookup("f").Value.String())
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	suite.Run(t, new(configSuite))
+}
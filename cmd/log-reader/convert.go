@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// runConvert runs the convert subcommand, rewriting log lines in another format (see logging.OutputFormat) to stdout,
+// e.g. to normalize legacy logs before ingesting them elsewhere: the logs of the window of the directory,
+// or every line of the files given as arguments whatever their times, e.g. log-reader convert old.log -out-format csv.
+// The lines not matching the input format are left out and counted on stderr, along with the entries
+// which are not requests, which Common and Combined Log lines cannot hold.
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	inFormatFlag := fs.String("in-format", "", "format of the log lines read, clf standing for common, instead of -f")
+	outFormatFlag := fs.String("out-format", string(logging.OutputJSON), "format the log lines are rewritten in: clf, combined, json or csv")
+	files, args := leadingArgs(args)
+	parseFlags(fs, "convert", args)
+	files = append(files, fs.Args()...)
+
+	out, err := logging.ParseOutputFormat(*outFormatFlag)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if *inFormatFlag == string(logging.OutputCommon) {
+		cfg.Format = logging.FormatCommon
+	} else if *inFormatFlag != "" {
+		cfg.Format = logging.Format(*inFormatFlag)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	sink, err := logging.NewConvertSink(os.Stdout, out)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if len(files) == 0 {
+		var logs *logging.Logs
+		if logs, err = logging.NewLogs(cfg); err == nil {
+			err = logs.Route(ctx, sink)
+		}
+	} else {
+		for _, file := range files {
+			if err = convertFile(ctx, file, cfg.Format, sink); err != nil {
+				break
+			}
+		}
+		if flushErr := sink.Flush(ctx); err == nil {
+			err = flushErr
+		}
+	}
+	if ctx.Err() != nil {
+		exit(exitInterrupted, "interrupted")
+	}
+	var configErr *logging.ConfigError
+	if errors.As(err, &configErr) || errors.Is(err, logging.ErrUnsupportedFormat) {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		exit(exitIOFailure, "could not convert logs: %v", err)
+	}
+	if skipped := sink.Skipped(); skipped > 0 {
+		fmt.Fprintf(os.Stderr, "left out %d log lines, invalid or not requests\n", skipped)
+	}
+}
+
+// convertFile rewrites every log line of a given file of a given format to a given sink, whatever its time.
+func convertFile(ctx context.Context, path string, format logging.Format, sink *logging.ConvertSink) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, err := r.ReadString('\n')
+		if line != "" {
+			// the invalid lines are left out by the sink
+			entry, parseErr := format.ParseLine(strings.TrimRight(line, "\r\n"))
+			if errors.Is(parseErr, logging.ErrUnsupportedFormat) {
+				return parseErr
+			}
+			entry.File, entry.Offset = path, offset
+			if err := sink.Write(ctx, entry); err != nil {
+				return err
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// runCorrelate runs the correlate subcommand, printing the requests of the access logs of -d
+// along with the lines of the error logs of -error-dir they caused, e.g. a 500 along with its stack trace.
+func runCorrelate(args []string) {
+	fs := flag.NewFlagSet("correlate", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	errorDirFlag := fs.String("error-dir", "", "the directory of the error logs (error format) to correlate with the access logs of -d")
+	errorOffsetFlag := fs.Duration("error-offset", 0, "the offset of the local time zone the error logs are written in from UTC, e.g. 1h for UTC+1")
+	withinFlag := fs.Duration("within", logging.DefaultCorrelateTolerance, "how long after the start of a request its errors may be logged")
+	linkedFlag := fs.Bool("linked", false, "only print the requests linked with lines of the error logs")
+	outputFlag := fs.String("o", outputText, "the output format: text, the access log lines each followed by their error log lines indented, or json, one object per request")
+	parseFlags(fs, "correlate", args)
+	if *outputFlag != outputText && *outputFlag != outputJSON {
+		exit(exitUsage, "unsupported output format '%s'", *outputFlag)
+	}
+	if *errorDirFlag == "" {
+		exit(exitUsage, "invalid configuration: -error-dir must be given")
+	}
+
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if cfg.End.IsZero() {
+		cfg.End = time.Now().UTC()
+	}
+	// the error logs of the window are read, along with the ones the last requests may cause after it,
+	// their files being selected by their modified times while their lines are in their local time zone
+	window := cfg.Window
+	if window == 0 {
+		window = time.Duration(cfg.LastNMinutes) * time.Minute
+	}
+	window += *withinFlag
+	if *errorOffsetFlag > 0 {
+		window += *errorOffsetFlag
+	}
+	errorCfg := logging.LogsConfig{
+		Directory:      *errorDirFlag,
+		Window:         window,
+		End:            cfg.End.Add(*errorOffsetFlag + *withinFlag),
+		Format:         logging.FormatError,
+		FollowSymlinks: cfg.FollowSymlinks,
+		Sharded:        cfg.Sharded,
+		OrderByContent: cfg.OrderByContent,
+		Merge:          cfg.Merge,
+		MemoryMap:      cfg.MemoryMap,
+		MaxReadRate:    cfg.MaxReadRate,
+		Logger:         cfg.Logger,
+	}
+	access, err := logging.NewLogs(cfg)
+	var configErr *logging.ConfigError
+	if errors.As(err, &configErr) {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not create access logs: %v", err)
+	}
+	errorLogs, err := logging.NewLogs(errorCfg)
+	if err != nil {
+		exit(exitIOFailure, "could not create error logs: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	bw := bufio.NewWriter(os.Stdout)
+	correlateCfg := logging.CorrelateConfig{Tolerance: *withinFlag, ErrorOffset: *errorOffsetFlag}
+	err = logging.Correlate(ctx, access, errorLogs, correlateCfg, func(c logging.Correlation) error {
+		if *linkedFlag && len(c.Errors) == 0 {
+			return nil
+		}
+		return printCorrelation(bw, c, *outputFlag)
+	})
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+	if ctx.Err() != nil {
+		exit(exitInterrupted, "interrupted")
+	}
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		exit(exitIOFailure, "could not correlate logs: %v", err)
+	}
+}
+
+// printCorrelation prints a request along with its error log lines in the given output format,
+// i.e. its log line followed by theirs indented, or a JSON object on a single line.
+func printCorrelation(w io.Writer, c logging.Correlation, output string) error {
+	if output == outputJSON {
+		return json.NewEncoder(w).Encode(c)
+	}
+	if _, err := io.WriteString(w, c.Request.Line+"\n"); err != nil {
+		return err
+	}
+	for _, entry := range c.Errors {
+		if _, err := io.WriteString(w, "    "+entry.Line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
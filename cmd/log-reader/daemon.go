@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// runDaemon runs the daemon subcommand, running a job on a cron schedule until interrupted, the job being
+// the subcommand given after the daemon flags, e.g. log-reader daemon -schedule '*/5 * * * *' stats -t 5 -mail-to ops@example.com.
+// A run is skipped while the previous one is still running, and the state of the runs is served on -health-addr when given.
+// The configuration file of the daemon (-config) is the one of the job too, unless the job gives its own.
+// When the configuration file has named sources (see parseFlags) and no -source is given, the job is run for every source
+// on its own schedule, each source giving its own directory, format, filters and sinks, and its own daemon section if needed.
+// The configuration is reloaded on SIGHUP and whenever the configuration file changes, see daemonSet.reload.
+// Along with GET /health, -health-addr answers the liveness and readiness probes of orchestration platforms
+// (GET /healthz and GET /readyz, not ready while the last run of a job failed), and serves the self-metrics
+// of the runs with -self-metrics (GET /metrics, Prometheus text format), e.g. the failures of the sinks of ship jobs.
+// Every run is appended to -audit-log when given (see auditLog).
+func runDaemon(args []string) {
+	flags := parseDaemonFlags(args)
+	daemons, err := loadDaemons(args, flags)
+	if err != nil {
+		exit(exitUsage, "%v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	set := &daemonSet{args: args}
+	if flags.auditLog != "" {
+		if set.audit, err = openAuditLog(flags.auditLog, flags.auditLogMaxMB, flags.auditLogKeep); err != nil {
+			exit(exitIOFailure, "could not open the audit log: %v", err)
+		}
+	}
+	if flags.healthAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/health", daemonsHealthHandler(set.list))
+		mux.HandleFunc("/healthz", handleHealthz)
+		mux.Handle("/readyz", readinessHandler(set.ready))
+		if flags.selfMetrics {
+			mux.Handle("/metrics", metricsHandler(set.metrics))
+		}
+		httpServer := &http.Server{
+			Addr:              flags.healthAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+			BaseContext:       func(net.Listener) context.Context { return ctx },
+		}
+		go func() {
+			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				exit(exitIOFailure, "could not serve health: %v", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			_ = httpServer.Shutdown(shutdownCtx)
+		}()
+	}
+
+	set.replace(ctx, daemons)
+	onReload(ctx, flags.config, func() {
+		if err := set.reload(ctx); err != nil {
+			log.Printf("could not reload the configuration, keeping the current one: %v", err)
+			return
+		}
+		log.Printf("configuration reloaded")
+	})
+	<-ctx.Done()
+	set.wait()
+}
+
+// daemonFlags are the flags of the daemon subcommand, along with its job.
+type daemonFlags struct {
+	schedule, healthAddr string
+	timeout              time.Duration
+	selfMetrics          bool
+	// auditLog is the file the runs are logged to, rotated past auditLogMaxMB, see openAuditLog
+	auditLog       string
+	auditLogMaxMB  float64
+	auditLogKeep   int
+	config, source string
+	job            []string
+}
+
+// parseDaemonFlags parses the flags of the daemon subcommand, the configuration file and source included (see parseFlags).
+func parseDaemonFlags(args []string) daemonFlags {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	defineFlags := daemonFlagSet(fs)
+	return defineFlags(parseFlags(fs, "daemon", args))
+}
+
+// reloadDaemonFlags parses the flags of the daemon subcommand again, see reloadFlags.
+func reloadDaemonFlags(args []string) (daemonFlags, error) {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	defineFlags := daemonFlagSet(fs)
+	if err := reloadFlags(fs, "daemon", args); err != nil {
+		return daemonFlags{}, err
+	}
+	return defineFlags(fs.Lookup("config").Value.String()), nil
+}
+
+// daemonFlagSet defines the flags of the daemon subcommand on a given flag set,
+// returning a function returning their values once parsed, along with the configuration file given.
+func daemonFlagSet(fs *flag.FlagSet) func(config string) daemonFlags {
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s daemon [flags] [stats|compare|ship] [job flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	scheduleFlag := fs.String("schedule", "", "the cron expression of the job (minute hour day-of-month month day-of-week, e.g. '*/5 * * * *') or a descriptor such as @hourly or @every 10m")
+	healthAddrFlag := fs.String("health-addr", "", "the address to serve the health of the daemon on (GET /health), e.g. :8081")
+	timeoutFlag := fs.Duration("timeout", 0, "how long a run may take before being killed (e.g. 10m), no limit when 0")
+	selfMetricsFlag := fs.Bool("self-metrics", false, "serve the metrics of the runs on -health-addr (GET /metrics, Prometheus text format): runs, failures, skipped runs and last success of every source")
+	auditLogFlag := fs.String("audit-log", "", "the file to append a JSON line per run of the job to (- for stderr): time, source, job, status, error and duration; read on start only")
+	auditLogMaxMBFlag := fs.Float64("audit-log-max-mb", 100, "rotate the audit log once it reaches the given megabytes (MiB), renaming it to <file>.1, 0 to never rotate it")
+	auditLogKeepFlag := fs.Int("audit-log-keep", 10, "the number of rotations of the audit log kept, <file>.1 being the latest")
+	return func(config string) daemonFlags {
+		return daemonFlags{
+			schedule:      *scheduleFlag,
+			healthAddr:    *healthAddrFlag,
+			timeout:       *timeoutFlag,
+			selfMetrics:   *selfMetricsFlag,
+			auditLog:      *auditLogFlag,
+			auditLogMaxMB: *auditLogMaxMBFlag,
+			auditLogKeep:  *auditLogKeepFlag,
+			config:        config,
+			source:        fs.Lookup("source").Value.String(),
+			job:           fs.Args(),
+		}
+	}
+}
+
+// loadDaemons returns the daemons of the given arguments and their flags: one per named source of the configuration file
+// when no -source is given, a single one otherwise. The flags of every source are reloaded from the arguments.
+func loadDaemons(args []string, flags daemonFlags) ([]*daemon, error) {
+	if flags.config == "" || flags.source != "" {
+		d, err := newDaemon(flags)
+		if err != nil {
+			return nil, err
+		}
+		return []*daemon{d}, nil
+	}
+	sources, err := configSources(flags.config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration file: %w", err)
+	}
+	if len(sources) == 0 {
+		d, err := newDaemon(flags)
+		if err != nil {
+			return nil, err
+		}
+		return []*daemon{d}, nil
+	}
+	daemons := make([]*daemon, 0, len(sources))
+	for _, source := range sources {
+		sourceFlags, err := reloadDaemonFlags(append([]string{"-source", source}, args...))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		d, err := newDaemon(sourceFlags)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		daemons = append(daemons, d)
+	}
+	return daemons, nil
+}
+
+// newDaemon returns the daemon running the job of given flags.
+func newDaemon(flags daemonFlags) (*daemon, error) {
+	if flags.schedule == "" {
+		return nil, errors.New("invalid configuration: -schedule is required")
+	}
+	schedule, err := cron.ParseStandard(flags.schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule '%s': %w", flags.schedule, err)
+	}
+	if flags.timeout < 0 {
+		return nil, fmt.Errorf("invalid timeout '%s'", flags.timeout)
+	}
+	job := flags.job
+	if len(job) > 0 {
+		switch job[0] {
+		case "stats", "compare", "ship":
+		default:
+			// the logs are extracted when the job starts with the flags of the main command
+			if !strings.HasPrefix(job[0], "-") {
+				return nil, fmt.Errorf("unsupported job '%s': must be stats, compare, ship or the flags extracting the logs", job[0])
+			}
+		}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("could not find executable: %w", err)
+	}
+
+	return &daemon{
+		schedule: schedule,
+		command:  append([]string{executable}, withConfig(job, flags.config, flags.source)...),
+		timeout:  flags.timeout,
+		health:   daemonHealth{Source: flags.source, Schedule: flags.schedule, Job: strings.Join(job, " ")},
+		reloaded: make(chan struct{}, 1),
+		stopped:  make(chan struct{}),
+	}, nil
+}
+
+// daemonSet runs the daemons of the sources of a configuration file, see runDaemon.
+type daemonSet struct {
+	args []string
+	// audit is the audit log of the runs of the daemons, if any
+	audit *auditLog
+
+	mu      sync.Mutex
+	daemons []*daemon
+	wg      sync.WaitGroup
+}
+
+// reload reads the flags of the daemons again from the arguments and the configuration file they give (see reloadFlags),
+// keeping the current daemons when invalid. The daemons of the sources still configured keep their state (runs in flight,
+// failures, last success, ...) and run on their new schedule, the ones of the new sources are started,
+// and the ones of the sources removed stop scheduling runs, their run in flight completing on its own.
+// The address of the health endpoint is only read on start.
+func (set *daemonSet) reload(ctx context.Context) error {
+	flags, err := reloadDaemonFlags(set.args)
+	if err != nil {
+		return err
+	}
+	daemons, err := loadDaemons(set.args, flags)
+	if err != nil {
+		return err
+	}
+	set.replace(ctx, daemons)
+	return nil
+}
+
+// replace replaces the daemons of the set by the given ones, updating the ones of the same sources in place.
+func (set *daemonSet) replace(ctx context.Context, daemons []*daemon) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	current := make(map[string]*daemon, len(set.daemons))
+	for _, d := range set.daemons {
+		current[d.health.Source] = d
+	}
+
+	running := make([]*daemon, 0, len(daemons))
+	for _, d := range daemons {
+		if old, ok := current[d.health.Source]; ok {
+			old.update(d)
+			delete(current, d.health.Source)
+			running = append(running, old)
+			continue
+		}
+		d.logf("running '%s' on schedule '%s'", d.health.Job, d.health.Schedule)
+		d.audit = set.audit
+		set.wg.Add(1)
+		go func(d *daemon) {
+			defer set.wg.Done()
+			d.run(ctx)
+		}(d)
+		running = append(running, d)
+	}
+	for _, old := range current {
+		old.logf("no longer configured, stopping")
+		close(old.stopped)
+	}
+	set.daemons = running
+}
+
+// list returns the daemons of the set.
+func (set *daemonSet) list() []*daemon {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return append([]*daemon(nil), set.daemons...)
+}
+
+// ready checks whether the last run of every daemon of the set succeeded, answering the readiness probes (see readinessHandler).
+func (set *daemonSet) ready() error {
+	for _, d := range set.list() {
+		if health := d.healthOf(); health.LastError != "" {
+			if health.Source != "" {
+				return fmt.Errorf("%s: last run failed: %s", health.Source, health.LastError)
+			}
+			return fmt.Errorf("last run failed: %s", health.LastError)
+		}
+	}
+	return nil
+}
+
+// metrics returns the self-metrics of the runs of the daemons of the set, labeled by source if any.
+func (set *daemonSet) metrics() []metric {
+	metrics := []metric{
+		{name: "log_reader_daemon_runs_total", kind: "counter", help: "The runs of the job completed."},
+		{name: "log_reader_daemon_run_failures_total", kind: "counter", help: "The runs of the job which failed, e.g. as their sink failed."},
+		{name: "log_reader_daemon_runs_skipped_total", kind: "counter", help: "The runs of the job skipped as the previous one was still running."},
+		{name: "log_reader_daemon_running", kind: "gauge", help: "Whether the job is running."},
+		{name: "log_reader_daemon_last_success_timestamp_seconds", kind: "gauge", help: "The time the last successful run of the job ended."},
+	}
+	for _, d := range set.list() {
+		health := d.healthOf()
+		var labels map[string]string
+		if health.Source != "" {
+			labels = map[string]string{"source": health.Source}
+		}
+		running, lastSuccess := 0.0, 0.0
+		if health.Running {
+			running = 1
+		}
+		if health.LastSuccess != nil {
+			lastSuccess = float64(health.LastSuccess.UnixNano()) / float64(time.Second)
+		}
+		for i, value := range []float64{float64(health.Runs), float64(health.Failures), float64(health.Skipped), running, lastSuccess} {
+			metrics[i].samples = append(metrics[i].samples, metricSample{labels: labels, value: value})
+		}
+	}
+	return metrics
+}
+
+// wait waits for the daemons of the set to stop, along with their runs in flight.
+func (set *daemonSet) wait() {
+	set.wg.Wait()
+}
+
+// daemon runs a command on a schedule, one run at a time.
+type daemon struct {
+	mu       sync.Mutex
+	schedule cron.Schedule
+	command  []string
+	timeout  time.Duration
+	health   daemonHealth
+	wg       sync.WaitGroup
+	// audit is the audit log the runs are logged to, if any
+	audit *auditLog
+
+	// reloaded is signaled once the schedule changed, stopped is closed once the daemon is no longer configured (see daemonSet)
+	reloaded chan struct{}
+	stopped  chan struct{}
+}
+
+// daemonHealth is the state of the runs of a daemon.
+type daemonHealth struct {
+	// Status is ok unless the last run failed.
+	Status string `json:"status"`
+	// Source is the named source of the configuration file the job is run for, if any.
+	Source   string    `json:"source,omitempty"`
+	Schedule string    `json:"schedule"`
+	Job      string    `json:"job"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run"`
+	Runs     int       `json:"runs"`
+	Failures int       `json:"failures"`
+	// Skipped counts the runs skipped as the previous one was still running.
+	Skipped      int        `json:"skipped"`
+	LastStart    *time.Time `json:"last_start,omitempty"`
+	LastEnd      *time.Time `json:"last_end,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+}
+
+// run starts the command on schedule until the context is done or the daemon is stopped,
+// waiting for the last run to complete then.
+func (d *daemon) run(ctx context.Context) {
+	defer d.wg.Wait()
+	for {
+		d.mu.Lock()
+		next := d.schedule.Next(time.Now())
+		d.health.NextRun = next
+		d.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-d.stopped:
+			timer.Stop()
+			return
+		case <-d.reloaded:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+
+		d.mu.Lock()
+		if d.health.Running {
+			d.health.Skipped++
+			d.mu.Unlock()
+			d.logf("skipping the run of %s: the previous run is still running", next.Format(time.RFC3339))
+			continue
+		}
+		d.health.Running = true
+		d.mu.Unlock()
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.runOnce(ctx)
+		}()
+	}
+}
+
+// update replaces the schedule, command and timeout of the daemon by the ones of another daemon of the same source,
+// keeping the state of its runs. The run in flight, if any, completes with the command it started with.
+func (d *daemon) update(other *daemon) {
+	d.mu.Lock()
+	d.schedule, d.command, d.timeout = other.schedule, other.command, other.timeout
+	changed := d.health.Schedule != other.health.Schedule
+	d.health.Schedule, d.health.Job = other.health.Schedule, other.health.Job
+	d.mu.Unlock()
+	if changed {
+		d.logf("running '%s' on schedule '%s'", other.health.Job, other.health.Schedule)
+	}
+	select {
+	case d.reloaded <- struct{}{}:
+	default:
+	}
+}
+
+// runOnce runs the command, recording the outcome of the run. The command is asked to stop (SIGTERM)
+// once the context is done, e.g. to ship its last batch, and killed once it times out.
+func (d *daemon) runOnce(ctx context.Context) {
+	start := time.Now()
+	d.mu.Lock()
+	d.health.LastStart = &start
+	command, timeout := d.command, d.timeout
+	d.mu.Unlock()
+
+	runCtx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(runCtx, command[0], command[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	err := cmd.Start()
+	if err == nil {
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = cmd.Process.Signal(syscall.SIGTERM)
+			case <-done:
+			}
+		}()
+		err = cmd.Wait()
+		close(done)
+		if runCtx.Err() != nil {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+	}
+
+	end := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry := daemonAuditEntry{
+		Time:       start.UTC(),
+		Source:     d.health.Source,
+		Job:        strings.Join(command[1:], " "),
+		Status:     "ok",
+		DurationMS: end.Sub(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Status, entry.Error = "failed", err.Error()
+	}
+	d.audit.write(entry)
+	d.health.Running = false
+	d.health.Runs++
+	d.health.LastEnd = &end
+	d.health.LastDuration = end.Sub(start).Round(time.Millisecond).String()
+	if err != nil {
+		d.health.Failures++
+		d.health.LastError = err.Error()
+		d.logf("run failed after %s: %v", d.health.LastDuration, err)
+		return
+	}
+	d.health.LastError = ""
+	d.health.LastSuccess = &end
+}
+
+// logf logs a message about the runs of the daemon, along with its source if any.
+func (d *daemon) logf(format string, v ...interface{}) {
+	if d.health.Source != "" {
+		format = d.health.Source + ": " + format
+	}
+	log.Printf(format, v...)
+}
+
+// healthOf returns the health of the daemon, its status being ok unless the last run failed.
+func (d *daemon) healthOf() daemonHealth {
+	d.mu.Lock()
+	health := d.health
+	d.mu.Unlock()
+
+	health.Status = "ok"
+	if health.LastError != "" {
+		health.Status = "failing"
+	}
+	return health
+}
+
+// daemonsHealthHandler returns the health of the given daemons as JSON (see daemonHealth),
+// with 503 Service Unavailable when the last run of one of them failed. The health of a single daemon is returned as is,
+// while the ones of several sources are returned as {"status": ..., "sources": [...]}.
+func daemonsHealthHandler(daemons func() []*daemon) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var healths []daemonHealth
+		status := http.StatusOK
+		for _, d := range daemons() {
+			health := d.healthOf()
+			if health.LastError != "" {
+				status = http.StatusServiceUnavailable
+			}
+			healths = append(healths, health)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if len(healths) == 1 {
+			_ = printJSON(w, healths[0])
+			return
+		}
+		overall := "ok"
+		if status != http.StatusOK {
+			overall = "failing"
+		}
+		_ = printJSON(w, struct {
+			Status  string         `json:"status"`
+			Sources []daemonHealth `json:"sources"`
+		}{Status: overall, Sources: healths})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// dashboardPage is the web dashboard, a single page using the endpoints of the server:
+// the stats (/stats), the requests and errors over time (/timeseries), the most frequent values (/top)
+// and the live tail (/tail) of the logs matching its filters.
+//
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// maxTimeSeriesBuckets bounds the number of buckets of a time series, so a small interval over a large window
+// doesn't exhaust the memory.
+const maxTimeSeriesBuckets = 10000
+
+// handleDashboard serves the web dashboard on / only, any other path not being found.
+func (srv *server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(dashboardPage)
+}
+
+// handleTimeSeries returns the number of requests and errors matching the query per interval (1m by default) as JSON.
+func (srv *server) handleTimeSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg, err := srv.config(r.Context(), r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	interval := time.Minute
+	if value := r.URL.Query().Get("interval"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil || interval <= 0 {
+			http.Error(w, "invalid interval '"+value+"'", http.StatusBadRequest)
+			return
+		}
+	}
+	if time.Duration(cfg.LastNMinutes)*time.Minute/interval > maxTimeSeriesBuckets {
+		http.Error(w, "invalid interval '"+interval.String()+"': too small for the window", http.StatusBadRequest)
+		return
+	}
+	logs, err := logging.NewLogs(cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ts, err := logs.TimeSeries(r.Context(), interval)
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		readFailed(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = printJSON(w, ts.Buckets)
+}
+
+// handleTop returns the most frequent values of the comma separated fields of the query (ips and paths by default)
+// among the log entries matching the query as a JSON object by field, ranking the given number of values (top, 10 by default).
+func (srv *server) handleTop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	n, err := queryInt(query, "top", defaultTopN)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	names := "ips,paths"
+	if value := query.Get("fields"); value != "" {
+		names = value
+	}
+	var fields []logging.TopField
+	for _, name := range strings.Split(names, ",") {
+		field, err := logging.ParseTopField(strings.TrimSpace(name))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fields = append(fields, field)
+	}
+	logs, err := srv.logs(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	top, err := logs.Top(r.Context(), fields...)
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		readFailed(w, err)
+		return
+	}
+	ranked := make(map[logging.TopField][]logging.Count, len(fields))
+	for _, field := range fields {
+		// an empty array rather than null when there are no values
+		ranked[field] = append([]logging.Count{}, top.Ranked(field, n)...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = printJSON(w, ranked)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// runDiff runs the diff subcommand, comparing the logs of two directories within the same window,
+// e.g. a primary server and its replica, or the logs before and after a deploy, to debug the gaps of a log shipping:
+// the log lines found in one of the directories but not the other, and how their requests differ (see runCompare).
+// The directories are given as arguments, before or after the flags, e.g. log-reader diff /mnt/a /mnt/b -t 60,
+// the other flags selecting the logs of both.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	topFlag := fs.Int("top", 5, "number of paths which requests went up and down the most to print")
+	linesFlag := fs.Int("lines", 10, "number of log lines found in only one of the directories to print for each, 0 for all")
+	outputFlag := fs.String("o", outputTable, "the output format: table, json")
+	dirs, args := leadingArgs(args)
+	parseFlags(fs, "diff", args)
+	dirs = append(dirs, fs.Args()...)
+	if len(dirs) != 2 {
+		exit(exitUsage, "invalid configuration: diff takes two directories, e.g. log-reader diff /mnt/a /mnt/b -t 60")
+	}
+	if *outputFlag != outputTable && *outputFlag != outputJSON {
+		exit(exitUsage, "unsupported output format '%s'", *outputFlag)
+	}
+
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if cfg.End.IsZero() {
+		// both directories are read within the very same window
+		cfg.End = time.Now().UTC()
+	}
+	var logs [2]*logging.Logs
+	for i, dir := range dirs {
+		cfg.Directory = dir
+		logs[i], err = logging.NewLogs(cfg)
+		var configErr *logging.ConfigError
+		if errors.As(err, &configErr) {
+			exit(exitUsage, "invalid configuration: %v", err)
+		}
+		if err != nil {
+			exit(exitIOFailure, "could not create logs of %s: %v", dir, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	d, err := logging.Diff(ctx, logs[0], logs[1])
+	if ctx.Err() != nil {
+		exit(exitInterrupted, "interrupted")
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not read logs: %v", err)
+	}
+	bw := bufio.NewWriter(os.Stdout)
+	if *outputFlag == outputJSON {
+		err = printJSON(bw, newDiffReport(d, dirs, *topFlag, *linesFlag))
+	} else {
+		err = printDiff(bw, d, dirs, *topFlag, *linesFlag)
+	}
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not print diff: %v", err)
+	}
+}
+
+// leadingArgs splits the arguments given before the flags from the flags, which the flag package stops at.
+func leadingArgs(args []string) ([]string, []string) {
+	i := 0
+	for i < len(args) && !strings.HasPrefix(args[i], "-") {
+		i++
+	}
+	return args[:i:i], args[i:]
+}
+
+// diffReport is the JSON representation of a diff, B being compared with A.
+type diffReport struct {
+	A          string             `json:"a"`
+	B          string             `json:"b"`
+	Comparison compareReport      `json:"comparison"`
+	Common     int64              `json:"common"`
+	OnlyA      int                `json:"only_a"`
+	OnlyB      int                `json:"only_b"`
+	OnlyALines []logging.LogEntry `json:"only_a_lines"`
+	OnlyBLines []logging.LogEntry `json:"only_b_lines"`
+}
+
+func newDiffReport(d *logging.LogDiff, dirs []string, top, lines int) diffReport {
+	return diffReport{
+		A:          dirs[0],
+		B:          dirs[1],
+		Comparison: newCompareReport(d.Comparison, top),
+		Common:     d.Common,
+		OnlyA:      len(d.OnlyA),
+		OnlyB:      len(d.OnlyB),
+		OnlyALines: firstEntries(d.OnlyA, lines),
+		OnlyBLines: firstEntries(d.OnlyB, lines),
+	}
+}
+
+// printDiff prints how the requests of B differ from the ones of A as an aligned table (see printComparison),
+// followed by the first log lines found in only one of them.
+func printDiff(w io.Writer, d *logging.LogDiff, dirs []string, top, lines int) error {
+	if err := printComparison(w, d.Comparison, top, "b", "a"); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "common lines: %d\n", d.Common)
+	for i, only := range [][]logging.LogEntry{d.OnlyA, d.OnlyB} {
+		fmt.Fprintf(w, "only in %s (%d):\n", dirs[i], len(only))
+		for _, entry := range firstEntries(only, lines) {
+			if _, err := fmt.Fprintf(w, "  %s\n", entry.Line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// firstEntries returns the n first log entries, or all of them when n is not positive.
+func firstEntries(entries []logging.LogEntry, n int) []logging.LogEntry {
+	if n > 0 && n < len(entries) {
+		return entries[:n]
+	}
+	return entries
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// The exit statuses of the main command, so the scripts and cron jobs wrapping it can tell the failures apart
+// (see also anomaliesExitCode of the stats subcommand). The subcommands exit with exitUsage and exitIOFailure as well.
+const (
+	// exitIOFailure is the exit status when the logs could not be read or printed.
+	exitIOFailure = 1
+	// exitUsage is the exit status of invalid flags or configurations, the same as the flag package's.
+	exitUsage = 2
+	// exitNoFilesInWindow is the exit status when none of the log files was modified within the window, with -fail-if-empty.
+	exitNoFilesInWindow = 4
+	// exitEmpty is the exit status when none of the logs of the window was printed, with -fail-if-empty.
+	exitEmpty = 5
+	// exitInvalidLines is the exit status when log lines not matching the log format were read, with -fail-on-invalid.
+	exitInvalidLines = 6
+	// exitRefused is the exit status when the run would read past -max-scan-mb or -max-duration and wasn't confirmed.
+	exitRefused = 7
+	// exitInterrupted is the exit status once interrupted (SIGINT or SIGTERM), the one of shells (128+SIGINT).
+	exitInterrupted = 130
+)
+
+// exit logs a given message the way log.Fatalf does, exiting with a given status.
+func exit(status int, format string, v ...interface{}) {
+	log.Printf(format, v...)
+	os.Exit(status)
+}
+
+// countingWriter counts the bytes written through it, e.g. to tell whether anything was printed.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// ReadFrom lets the system copy the bytes to the underlying writer where it can, the way Logs.Print does.
+func (cw *countingWriter) ReadFrom(r io.Reader) (int64, error) {
+	n, err := io.Copy(cw.w, r)
+	cw.n += n
+	return n, err
+}
+
+// invalidCounter returns a filter counting the log lines not matching the log format, keeping all of them,
+// which is safe for concurrent use so the files can be parsed in parallel.
+func invalidCounter(count *int64) func(logging.LogEntry) bool {
+	return func(entry logging.LogEntry) bool {
+		if entry.Invalid {
+			atomic.AddInt64(count, 1)
+		}
+		return true
+	}
+}
+
+// errorReport is the summary of the errors of a run printed by -error-summary json.
+type errorReport struct {
+	// Complete tells the run went through, the invalid log lines aside, so the output is whole.
+	Complete bool `json:"complete"`
+	// Error is the failure of the run, if any.
+	Error string `json:"error,omitempty"`
+	// Total counts the errors of all the files.
+	Total int64                `json:"total"`
+	Files []logging.FileErrors `json:"files"`
+}
+
+// printErrorSummary prints the errors of a given summary once done in a given format (see -error-summary),
+// along with the failure of the run, if any: as a JSON errorReport, or as text with a line per file
+// (e.g. "access.log: 2 invalid_format, 1 invalid_time, first: ...") when there were errors.
+func printErrorSummary(w io.Writer, format string, errs *logging.ErrorSummary, runErr error) {
+	if errs == nil {
+		return
+	}
+	files := errs.Files()
+	if format == outputJSON {
+		report := errorReport{Complete: runErr == nil, Total: errs.Total(), Files: files}
+		if runErr != nil {
+			report.Error = runErr.Error()
+		}
+		if report.Files == nil {
+			report.Files = []logging.FileErrors{}
+		}
+		_ = json.NewEncoder(w).Encode(report)
+		return
+	}
+	if len(files) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "errors: %d\n", errs.Total())
+	for _, fe := range files {
+		types := make([]string, 0, len(fe.Counts))
+		for typ := range fe.Counts {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		name := fe.File
+		if name == "" {
+			name = "(no file)"
+		}
+		_, _ = fmt.Fprintf(w, "%s:", name)
+		for i, typ := range types {
+			sep := ","
+			if i == 0 {
+				sep = ""
+			}
+			_, _ = fmt.Fprintf(w, "%s %d %s", sep, fe.Counts[typ], typ)
+		}
+		_, _ = fmt.Fprintf(w, ", first: %s\n", fe.First)
+	}
+}
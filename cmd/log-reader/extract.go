@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// extractSummary counts what a run extracted (see -summary): the log entries kept, the time range they actually cover
+// and the log files they were read from, along with the bytes written and the log files opened once done.
+// Its filter is safe for concurrent use, so the files can be parsed in parallel.
+type extractSummary struct {
+	mu      sync.Mutex
+	entries int64
+	from    time.Time
+	to      time.Time
+	files   map[string]int64
+}
+
+// newExtractSummary creates an empty extractSummary.
+func newExtractSummary() *extractSummary {
+	return &extractSummary{files: make(map[string]int64)}
+}
+
+// count is the filter counting the log entries kept, the last one so the entries filtered out aren't counted.
+func (s *extractSummary) count(entry logging.LogEntry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries++
+	if !entry.Time.IsZero() {
+		if s.from.IsZero() || entry.Time.Before(s.from) {
+			s.from = entry.Time
+		}
+		if entry.Time.After(s.to) {
+			s.to = entry.Time
+		}
+	}
+	s.files[entry.File]++
+	return true
+}
+
+// extractReport is the JSON trailer of -summary.
+type extractReport struct {
+	// Complete tells the run went through, so the output is whole.
+	Complete bool `json:"complete"`
+	// Error is the failure of the run, if any.
+	Error string `json:"error,omitempty"`
+	// Entries counts the log entries kept, printed or ranked (see -top).
+	Entries int64 `json:"entries"`
+	// Bytes counts the bytes written, to stdout, -o or the files of -split.
+	Bytes int64 `json:"bytes"`
+	// From and To are the times of the earliest and the latest log entries kept, if any.
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+	// FilesScanned counts the log files opened to be read or searched.
+	FilesScanned int64 `json:"files_scanned"`
+	// FilesVanished counts the log files skipped as removed since the directory was scanned (see -skip-vanished).
+	FilesVanished int64 `json:"files_vanished"`
+	// Files are the log files the entries kept were read from, with how many of them each.
+	Files []extractFile `json:"files"`
+}
+
+// extractFile counts the log entries kept of a log file.
+type extractFile struct {
+	File    string `json:"file"`
+	Entries int64  `json:"entries"`
+}
+
+// report returns the extractReport of the run once done, given the bytes written, the metrics of the logs
+// and the failure of the run, if any. The files are sorted by path.
+func (s *extractSummary) report(bytes int64, metrics *logging.Metrics, runErr error) extractReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := metrics.Snapshot()
+	r := extractReport{
+		Complete:      runErr == nil,
+		Entries:       s.entries,
+		Bytes:         bytes,
+		FilesScanned:  snapshot.FilesScanned,
+		FilesVanished: snapshot.FilesVanished,
+		Files:         make([]extractFile, 0, len(s.files)),
+	}
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	if s.entries > 0 && !s.from.IsZero() {
+		from, to := s.from.UTC(), s.to.UTC()
+		r.From, r.To = &from, &to
+	}
+	for file, entries := range s.files {
+		r.Files = append(r.Files, extractFile{File: file, Entries: entries})
+	}
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].File < r.Files[j].File })
+	return r
+}
+
+// printExtractSummary prints the summary of the run once done in a given format (see -summary): as a JSON extractReport,
+// or as a text line, e.g. "summary: 1200 entries, 345.6 KiB written, from ... to ..., 2 files (3 scanned)",
+// the files skipped as removed since scanned being counted when any, e.g. "(3 scanned, 1 vanished)".
+func printExtractSummary(w io.Writer, format string, s *extractSummary, bytes int64, metrics *logging.Metrics, runErr error) {
+	if s == nil {
+		return
+	}
+	r := s.report(bytes, metrics, runErr)
+	if format == outputJSON {
+		_ = json.NewEncoder(w).Encode(r)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "summary: %d entries, %s written", r.Entries, formatBytes(r.Bytes))
+	if r.From != nil {
+		_, _ = fmt.Fprintf(w, ", from %s to %s", r.From.Format(time.RFC3339), r.To.Format(time.RFC3339))
+	}
+	_, _ = fmt.Fprintf(w, ", %d files (%d scanned", len(r.Files), r.FilesScanned)
+	if r.FilesVanished > 0 {
+		_, _ = fmt.Fprintf(w, ", %d vanished", r.FilesVanished)
+	}
+	_, _ = fmt.Fprint(w, ")")
+	if runErr != nil {
+		_, _ = fmt.Fprintf(w, ", incomplete: %v", runErr)
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/generate"
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// runGenerate runs the generate subcommand, writing synthetic log files rotated the way logrotate does
+// (access.log, access.log.1, ...) which logs span the -duration before -end, e.g. to benchmark the log reader
+// or to reproduce a bug without sharing the actual logs.
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	// not -d, so the directory of the logs given by a configuration file is never written to
+	dirFlag := fs.String("dir", "testdata", "the directory to write the log files to, created if needed")
+	formatFlag := fs.String("f", string(generate.DefaultFormat), "the format of the log lines: common, combined")
+	filesFlag := fs.Int("files", 1, "the number of rotated log files the logs are spread over")
+	rateFlag := fs.String("rate", "10/s", "the mean number of log lines per second (/s), minute (/m) or hour (/h), e.g. 100/s")
+	durationFlag := fs.Duration("duration", time.Hour, "the time spanned by the logs")
+	endFlag := fs.String("end", "", "the time the logs end at, as RFC 3339 (e.g. 2022-03-04T05:30:00Z), now by default")
+	statusesFlag := fs.String("statuses", "", "the weights of the statuses, e.g. 200:90,404:7,500:3, mostly 200 by default")
+	methodsFlag := fs.String("methods", "", "the weights of the methods, e.g. GET:90,POST:10, mostly GET by default")
+	pathsFlag := fs.Int("paths", generate.DefaultPaths, "the number of distinct paths, a few of them making most of the requests")
+	ipsFlag := fs.Int("ips", generate.DefaultIPs, "the number of distinct client IPs, a few of them making most of the requests")
+	seedFlag := fs.Int64("seed", 1, "the seed of the random numbers, the same seed generating the same logs")
+	parseFlags(fs, "generate", args)
+
+	rate, err := parseRate(*rateFlag)
+	if err != nil {
+		exit(exitUsage, "invalid rate: %v", err)
+	}
+	end := time.Now().UTC()
+	if *endFlag != "" {
+		if end, err = time.Parse(time.RFC3339, *endFlag); err != nil {
+			exit(exitUsage, "invalid end: %v", err)
+		}
+	}
+	opts := []generate.Option{
+		generate.WithFormat(logging.Format(*formatFlag)),
+		generate.WithRate(rate),
+		generate.WithPaths(*pathsFlag),
+		generate.WithIPs(*ipsFlag),
+		generate.WithSeed(*seedFlag),
+	}
+	if *statusesFlag != "" {
+		weights, err := parseWeights(*statusesFlag)
+		if err != nil {
+			exit(exitUsage, "invalid statuses: %v", err)
+		}
+		statuses := make(map[int]float64, len(weights))
+		for status, weight := range weights {
+			code, err := strconv.Atoi(status)
+			if err != nil {
+				exit(exitUsage, "invalid statuses: invalid status '%s'", status)
+			}
+			statuses[code] = weight
+		}
+		opts = append(opts, generate.WithStatuses(statuses))
+	}
+	if *methodsFlag != "" {
+		methods, err := parseWeights(*methodsFlag)
+		if err != nil {
+			exit(exitUsage, "invalid methods: %v", err)
+		}
+		opts = append(opts, generate.WithMethods(methods))
+	}
+	g, err := generate.New(opts...)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+
+	start := time.Now()
+	paths, err := g.WriteFiles(*dirFlag, *filesFlag, end.Add(-*durationFlag), end)
+	if err != nil {
+		exit(exitIOFailure, "could not generate logs: %v", err)
+	}
+	log.Printf("generated %d log lines into %d files of %s in %s", g.Lines(), len(paths), *dirFlag, time.Since(start).Round(time.Millisecond))
+}
+
+// parseRate parses a number of events per second (/s), minute (/m) or hour (/h), e.g. 100/s, into a number per second.
+// A number without unit is per second.
+func parseRate(s string) (float64, error) {
+	per := time.Second
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		switch s[i+1:] {
+		case "s":
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return 0, fmt.Errorf("unsupported unit '%s', expected s, m or h", s[i+1:])
+		}
+		s = s[:i]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n / per.Seconds(), nil
+}
+
+// parseWeights parses comma separated values weighted by numbers, e.g. 200:90,404:7,500:3.
+func parseWeights(s string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.LastIndex(pair, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("missing weight of '%s', expected value:weight", pair)
+		}
+		weight, err := strconv.ParseFloat(pair[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight of '%s': %v", pair[:i], err)
+		}
+		weights[strings.TrimSpace(pair[:i])] = weight
+	}
+	return weights, nil
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+const (
+	// grafanaTopPrefix prefixes the table targets ranking the values of a field, e.g. top:paths
+	grafanaTopPrefix = "top:"
+	// grafanaMaxDataPoints caps the number of points of a time series, widening its interval when needed
+	grafanaMaxDataPoints = 10000
+	// grafanaDefaultTop is the number of rows of a table target unless given, e.g. top:paths?n=20
+	grafanaDefaultTop = 10
+)
+
+// The time series targets.
+const (
+	grafanaRequests  = "requests"
+	grafanaErrors    = "errors"
+	grafanaErrorRate = "error_rate"
+)
+
+// grafanaRange is the time range of a Grafana query.
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// grafanaQueryRequest is the body of a /query request.
+type grafanaQueryRequest struct {
+	Range      grafanaRange `json:"range"`
+	IntervalMs int64        `json:"intervalMs"`
+	Targets    []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+	} `json:"targets"`
+}
+
+// grafanaTimeSeries is the response to a time series target: [value, unix milliseconds] pairs.
+type grafanaTimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// grafanaTable is the response to a table target.
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// grafanaColumn is a column of a grafanaTable.
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// grafanaAnnotationRequest is the body of an /annotations request.
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+// grafanaAnnotation is an annotation of the response to an /annotations request.
+type grafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation"`
+	Time       int64           `json:"time"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// grafanaHandler implements the Grafana JSON datasource (simpod-json-datasource, formerly SimpleJSON),
+// so Grafana can chart the logs of the directory within the time range of its dashboards:
+//
+//	GET / answers the connection test
+//	POST /search lists the targets: requests, errors and error_rate (time series) and top:<field> (tables, e.g. top:paths)
+//	POST /query returns the time series (by the interval of the panel) and tables of the targets
+//	POST /annotations marks the spikes of requests or error rate, see runAnomalies
+//
+// The targets are filtered by their query, the same way as the /logs one, e.g. requests?status=5xx&path=/api,
+// and the tables have the given number of rows, e.g. top:ips?n=20. The query of an annotation is
+// anomalies, along with the interval and number of standard deviations if needed, e.g. anomalies?interval=5m&sigma=3.
+func (srv *server) grafanaHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintln(w, "OK")
+	})
+	mux.HandleFunc("/search", srv.handleGrafanaSearch)
+	mux.HandleFunc("/query", srv.handleGrafanaQuery)
+	mux.HandleFunc("/annotations", srv.handleGrafanaAnnotations)
+	return mux
+}
+
+// handleGrafanaSearch lists the targets containing the searched text.
+func (srv *server) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid search: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	targets := []string{grafanaRequests, grafanaErrors, grafanaErrorRate}
+	for _, field := range []logging.TopField{
+		logging.TopIPs, logging.TopPaths, logging.TopMethods, logging.TopProtocols, logging.TopUserAgents,
+		logging.TopReferers, logging.TopVHosts, logging.TopBots, logging.TopBytesByPath, logging.TopBytesByIP,
+	} {
+		targets = append(targets, grafanaTopPrefix+string(field))
+	}
+	matching := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if strings.Contains(target, req.Target) {
+			matching = append(matching, target)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(matching)
+}
+
+// handleGrafanaQuery returns the time series or table of every target, within the time range of the query.
+// The targets sharing the same filters are read once.
+func (srv *server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req grafanaQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !req.Range.From.Before(req.Range.To) {
+		http.Error(w, "invalid query: the range must not be empty", http.StatusBadRequest)
+		return
+	}
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if min := req.Range.To.Sub(req.Range.From) / grafanaMaxDataPoints; interval < min {
+		interval = min
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	series := make(map[string]*logging.TimeSeries)
+	results := make([]interface{}, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		name, query, err := parseGrafanaTarget(target.Target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logs, err := srv.rangeLogs(r.Context(), query, req.Range)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if strings.HasPrefix(name, grafanaTopPrefix) {
+			field, err := logging.ParseTopField(strings.TrimPrefix(name, grafanaTopPrefix))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			n, err := queryInt(query, "n", grafanaDefaultTop)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			top, err := logs.Top(r.Context(), field)
+			if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+				readFailed(w, err)
+				return
+			}
+			results = append(results, newGrafanaTable(top, field, n))
+			continue
+		}
+
+		if name != grafanaRequests && name != grafanaErrors && name != grafanaErrorRate {
+			http.Error(w, fmt.Sprintf("unsupported target '%s'", target.Target), http.StatusBadRequest)
+			return
+		}
+		key := query.Encode()
+		ts, ok := series[key]
+		if !ok {
+			ts, err = logs.TimeSeries(r.Context(), interval)
+			if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+				readFailed(w, err)
+				return
+			}
+			series[key] = ts
+		}
+		results = append(results, newGrafanaTimeSeries(target.Target, name, ts))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
+
+// handleGrafanaAnnotations marks the spikes of requests or error rate within the time range of the query.
+func (srv *server) handleGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req grafanaAnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid annotation query: %v", err), http.StatusBadRequest)
+		return
+	}
+	var annotation struct {
+		Query string `json:"query"`
+	}
+	_ = json.Unmarshal(req.Annotation, &annotation)
+	if annotation.Query == "" {
+		annotation.Query = "anomalies"
+	}
+	name, query, err := parseGrafanaTarget(annotation.Query)
+	if err == nil && name != "anomalies" {
+		err = fmt.Errorf("unsupported annotation query '%s', must be anomalies", annotation.Query)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	interval := time.Minute
+	if value := query.Get("interval"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil || interval < time.Second {
+			http.Error(w, fmt.Sprintf("invalid interval '%s'", value), http.StatusBadRequest)
+			return
+		}
+	}
+	sigma := 3.0
+	if value := query.Get("sigma"); value != "" {
+		if sigma, err = strconv.ParseFloat(value, 64); err != nil || sigma <= 0 {
+			http.Error(w, fmt.Sprintf("invalid sigma '%s'", value), http.StatusBadRequest)
+			return
+		}
+	}
+	if !req.Range.From.Before(req.Range.To) {
+		http.Error(w, "invalid annotation query: the range must not be empty", http.StatusBadRequest)
+		return
+	}
+	logs, err := srv.rangeLogs(r.Context(), query, req.Range)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ts, err := logs.TimeSeries(r.Context(), interval)
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		readFailed(w, err)
+		return
+	}
+	annotations := make([]grafanaAnnotation, 0)
+	for _, anomaly := range ts.Anomalies(sigma, anomalyHistory) {
+		annotations = append(annotations, grafanaAnnotation{
+			Annotation: req.Annotation,
+			Time:       anomaly.Time.UnixNano() / int64(time.Millisecond),
+			Title:      anomaly.Metric + " spike",
+			Text:       fmt.Sprintf("%s %.4g, %.1f standard deviations above the mean %.4g", anomaly.Metric, anomaly.Value, anomaly.Sigma, anomaly.Mean),
+			Tags:       []string{anomaly.Metric},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(annotations)
+}
+
+// rangeLogs creates the logs of the directory within a given time range, filtered by a given query (see server.config).
+func (srv *server) rangeLogs(ctx context.Context, query url.Values, rng grafanaRange) (*logging.Logs, error) {
+	cfg, err := srv.config(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	cfg.End = rng.To
+	cfg.Window = rng.To.Sub(rng.From)
+	return logging.NewLogs(cfg)
+}
+
+// parseGrafanaTarget splits a target into its name and query, e.g. requests?status=5xx.
+func parseGrafanaTarget(target string) (string, url.Values, error) {
+	name, rawQuery := target, ""
+	if i := strings.Index(target, "?"); i >= 0 {
+		name, rawQuery = target[:i], target[i+1:]
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid target '%s': %v", target, err)
+	}
+	return strings.TrimSpace(name), query, nil
+}
+
+// newGrafanaTimeSeries returns the time series of a given metric of the target.
+func newGrafanaTimeSeries(target, metric string, ts *logging.TimeSeries) grafanaTimeSeries {
+	result := grafanaTimeSeries{Target: target, Datapoints: make([][2]float64, 0, len(ts.Buckets))}
+	for _, bucket := range ts.Buckets {
+		var value float64
+		switch metric {
+		case grafanaRequests:
+			value = float64(bucket.Requests)
+		case grafanaErrors:
+			value = float64(bucket.Errors)
+		default:
+			if bucket.Requests > 0 {
+				value = float64(bucket.Errors) / float64(bucket.Requests)
+			}
+		}
+		result.Datapoints = append(result.Datapoints, [2]float64{value, float64(bucket.Time.UnixNano() / int64(time.Millisecond))})
+	}
+	return result
+}
+
+// newGrafanaTable returns the table ranking the n most frequent values of a given field.
+func newGrafanaTable(top *logging.Top, field logging.TopField, n int) grafanaTable {
+	count := "count"
+	if field.SumsBytes() {
+		count = "bytes"
+	}
+	table := grafanaTable{
+		Type:    "table",
+		Columns: []grafanaColumn{{Text: string(field), Type: "string"}, {Text: count, Type: "number"}},
+		Rows:    make([][]interface{}, 0, n),
+	}
+	for _, c := range top.Ranked(field, n) {
+		table.Rows = append(table.Rows, []interface{}{c.Value, c.Count})
+	}
+	return table
+}
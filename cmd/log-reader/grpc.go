@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+	"github.com/chill-and-code/apache-log-reader/logrpc"
+)
+
+// runGRPC runs the grpc subcommand, exposing the logs of the directory over the LogReader gRPC service
+// (see logrpc/logreader.proto): QueryWindow, StreamTail and GetStats.
+// The logs are looked for within the last -t minutes unless the minutes are given by the request.
+func runGRPC(args []string) {
+	fs := flag.NewFlagSet("grpc", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	addrFlag := fs.String("addr", ":9090", "the address to listen on")
+	parseFlags(fs, "grpc", args)
+
+	// classify the bots to split the traffic between bots and humans
+	cfg, err := logsConfig(true)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if _, err := logging.NewLogs(cfg); err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", *addrFlag)
+	if err != nil {
+		exit(exitIOFailure, "could not listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	logrpc.RegisterLogReaderServer(grpcServer, logrpc.NewServer(cfg))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// the calls in flight are given some time to complete, but the endless ones (StreamTail) are canceled
+		timer := time.AfterFunc(shutdownTimeout, grpcServer.Stop)
+		defer timer.Stop()
+		grpcServer.GracefulStop()
+	}()
+
+	log.Printf("serving the logs of %s over gRPC on %s", logsSource(cfg), *addrFlag)
+	if err := grpcServer.Serve(listener); err != nil {
+		exit(exitIOFailure, "could not serve: %v", err)
+	}
+}
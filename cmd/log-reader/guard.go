@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// scanGuard refuses the runs reading more of the log files than a maximum of bytes (see -max-scan-mb) or over a window
+// longer than a maximum (see -max-duration), e.g. an accidental -t 525600 on a production server, unless confirmed.
+type scanGuard struct {
+	// maxBytes is the maximum of bytes read from the log files, 0 for no limit
+	maxBytes int64
+	// maxDuration is the maximum of the window, 0 for no limit
+	maxDuration time.Duration
+	// yes confirms the runs past the maximums without asking (see -yes)
+	yes bool
+	// in and out ask for the confirmation when in is a terminal, the runs past the maximums being refused otherwise
+	in       io.Reader
+	out      io.Writer
+	terminal bool
+}
+
+// newScanGuard creates the scanGuard of given maximums, asking for the confirmation on the terminal, if any.
+func newScanGuard(maxMB float64, maxDuration time.Duration, yes bool) *scanGuard {
+	terminal := false
+	if info, err := os.Stdin.Stat(); err == nil {
+		terminal = info.Mode()&os.ModeCharDevice != 0
+	}
+	return &scanGuard{
+		maxBytes:    int64(maxMB * (1 << 20)),
+		maxDuration: maxDuration,
+		yes:         yes,
+		in:          os.Stdin,
+		out:         os.Stderr,
+		terminal:    terminal,
+	}
+}
+
+// check returns an error when a run of given logs over a given window goes past the maximums and isn't confirmed.
+// How much is read is estimated from the sizes of the byte ranges of the window, found by searching the log files
+// (or reading their indexes) without reading their log lines (see logging.Logs.EstimateBytes).
+func (g *scanGuard) check(ctx context.Context, logs *logging.Logs, window time.Duration) error {
+	if g.maxBytes <= 0 && g.maxDuration <= 0 {
+		return nil
+	}
+	var reasons []string
+	if g.maxDuration > 0 && window > g.maxDuration {
+		reasons = append(reasons, fmt.Sprintf("the window of %s is longer than -max-duration %s", window, g.maxDuration))
+	}
+	if g.maxBytes > 0 {
+		n, err := logs.EstimateBytes(ctx)
+		if err != nil {
+			return fmt.Errorf("could not estimate the bytes read: %w", err)
+		}
+		if n > g.maxBytes {
+			reasons = append(reasons, fmt.Sprintf("about %s would be read, more than -max-scan-mb %s", formatBytes(n), formatBytes(g.maxBytes)))
+		}
+	}
+	if len(reasons) == 0 || g.yes {
+		return nil
+	}
+	reason := strings.Join(reasons, " and ")
+	if !g.terminal {
+		return fmt.Errorf("%s, run with -yes to read it all the same", reason)
+	}
+	fmt.Fprintf(g.out, "%s, continue? [y/N] ", reason)
+	answer, _ := bufio.NewReader(g.in).ReadString('\n')
+	if answer := strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return fmt.Errorf("%s, not confirmed", reason)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// writeLogDir writes an access.log of a request per given path, logged a minute ago, to a temporary directory of the test.
+func writeLogDir(t testing.TB, paths ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	logged := time.Now().Add(-time.Minute).Format("02/Jan/2006:15:04:05 -0700")
+	var content strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&content, "127.0.0.1 - - [%s] \"GET %s HTTP/1.1\" 200 123\n", logged, path)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "access.log"), []byte(content.String()), 0666); err != nil {
+		t.Fatalf("could not write the log file: %v", err)
+	}
+	return dir
+}
+
+// newTestServer creates the server of the logs of a given directory within the last hour, along with given named sources.
+func newTestServer(dir string, auth *authenticator, named map[string]*logSource) *server {
+	return &server{cfg: logging.LogsConfig{Directory: dir, LastNMinutes: 60}, auth: auth, named: named}
+}
+
+// serveRequest answers a GET request of a given URL by a given handler, with a given bearer token unless empty.
+func serveRequest(handler http.Handler, target, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// entries returns a forEach calling its function with the given log entries.
+func entries(all ...logging.LogEntry) forEach {
+	return func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		for _, entry := range all {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
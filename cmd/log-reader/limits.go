@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// maxClientBuckets is the number of clients which buckets are kept before the full ones are forgotten, see clientLimiter.
+const maxClientBuckets = 10000
+
+// clientLimiter limits the rate of the requests of every client of the server (see -rate-limit) by a token bucket
+// per client, holding as many requests as the limit of the rate and refilled at the rate. It is safe for concurrent use.
+type clientLimiter struct {
+	rate logging.Rate
+
+	mu      sync.Mutex
+	buckets map[string]*clientBucket
+	// now is the clock of the limiter, time.Now unless faked by the tests
+	now func() time.Time
+}
+
+// clientBucket is the bucket of the requests of a client, as of its last request.
+type clientBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newClientLimiter creates a clientLimiter of a given rate per client.
+func newClientLimiter(rate logging.Rate) *clientLimiter {
+	return &clientLimiter{rate: rate, buckets: make(map[string]*clientBucket), now: time.Now}
+}
+
+// allow takes a request of a given client out of its bucket, or returns false along with how long until the client
+// is allowed a request again when its bucket is empty.
+func (l *clientLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	perToken := l.rate.Per / time.Duration(l.rate.Limit)
+	capacity := float64(l.rate.Limit)
+	b, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= maxClientBuckets {
+			l.forgetFull(now, capacity)
+		}
+		b = &clientBucket{tokens: capacity, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// forgetFull forgets the buckets refilled by now, their clients being as good as new ones.
+func (l *clientLimiter) forgetFull(now time.Time, capacity float64) {
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= l.rate.Per || b.tokens >= capacity {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// limit answers 429 Too Many Requests to the clients exceeding the rate of the limiter, if any, telling them when
+// to retry (Retry-After), returning whether the request is allowed. The clients are the tokens of the requests
+// when authenticated (see authenticator), and their IPs otherwise.
+func (l *clientLimiter) limit(w http.ResponseWriter, r *http.Request) bool {
+	if l == nil {
+		return true
+	}
+	client := ""
+	if token, ok := tokenFrom(r.Context()); ok {
+		client = "token:" + token.name
+	} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		client = "ip:" + host
+	} else {
+		client = "ip:" + r.RemoteAddr
+	}
+	ok, wait := l.allow(client)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "too many requests, the limit is "+l.rate.String(), http.StatusTooManyRequests)
+	}
+	return ok
+}
+
+// readFailed answers a request which logs could not be read: 422 Unprocessable Entity when the query read more
+// than the maximum of bytes (see -max-query-mb), so it has to be narrowed, 500 Internal Server Error otherwise.
+func readFailed(w http.ResponseWriter, err error) {
+	if errors.Is(err, logging.ErrBudgetExceeded) {
+		http.Error(w, "query too expensive, narrow its window or its filters: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	log.Printf("could not read logs: %v", err)
+	http.Error(w, "could not read logs", http.StatusInternalServerError)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type limitsSuite struct {
+	suite.Suite
+	now     time.Time
+	limiter *clientLimiter
+}
+
+func (s *limitsSuite) SetupTest() {
+	s.now = time.Date(2022, time.March, 3, 10, 0, 0, 0, time.UTC)
+	s.limiter = newClientLimiter(logging.Rate{Limit: 3, Per: 3 * time.Second})
+	s.limiter.now = func() time.Time { return s.now }
+}
+
+// allowed returns how many of a given number of requests of a client are allowed right away.
+func (s *limitsSuite) allowed(client string, requests int) int {
+	n := 0
+	for i := 0; i < requests; i++ {
+		if ok, _ := s.limiter.allow(client); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *limitsSuite) Test_allow_Burst() {
+	s.Equal(3, s.allowed("ip:10.0.0.1", 3))
+
+	ok, wait := s.limiter.allow("ip:10.0.0.1")
+
+	s.False(ok)
+	s.Equal(time.Second, wait)
+}
+
+func (s *limitsSuite) Test_allow_Refill() {
+	tests := []struct {
+		name     string
+		elapsed  time.Duration
+		expected int
+	}{
+		{name: "not yet", elapsed: 999 * time.Millisecond, expected: 0},
+		{name: "a request", elapsed: time.Second, expected: 1},
+		{name: "two requests", elapsed: 2500 * time.Millisecond, expected: 2},
+		{name: "up to the limit", elapsed: time.Hour, expected: 3},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.SetupTest()
+			s.Require().Equal(3, s.allowed("ip:10.0.0.1", 4))
+
+			s.now = s.now.Add(test.elapsed)
+
+			s.Equal(test.expected, s.allowed("ip:10.0.0.1", 4))
+		})
+	}
+}
+
+func (s *limitsSuite) Test_allow_Clients() {
+	s.Require().Equal(3, s.allowed("token:ops", 4))
+
+	// every client has a bucket of its own
+	s.Equal(3, s.allowed("token:shop", 4))
+	s.Equal(3, s.allowed("ip:10.0.0.1", 4))
+	s.Equal(0, s.allowed("token:ops", 1))
+}
+
+func (s *limitsSuite) Test_allow_Eviction() {
+	for i := 0; i < maxClientBuckets-1; i++ {
+		s.limiter.allow("ip:" + strconv.Itoa(i))
+	}
+	s.now = s.now.Add(time.Second)
+	s.Require().Equal(3, s.allowed("token:ops", 4))
+	s.Require().Len(s.limiter.buckets, maxClientBuckets)
+
+	// full, but no bucket refilled yet
+	s.limiter.allow("token:shop")
+	s.Len(s.limiter.buckets, maxClientBuckets+1)
+
+	// the buckets refilled are forgotten, as good as new ones, while the others are kept
+	s.now = s.now.Add(2 * time.Second)
+	s.limiter.allow("token:blog")
+	s.Len(s.limiter.buckets, 3)
+	s.Equal(2, s.allowed("token:ops", 3))
+}
+
+func (s *limitsSuite) Test_limit() {
+	s.Require().Equal(3, s.allowed("ip:10.0.0.1", 3))
+	tests := []struct {
+		name   string
+		remote string
+		token  string
+		status int
+	}{
+		{name: "limited IP", remote: "10.0.0.1:51234", status: http.StatusTooManyRequests},
+		{name: "other port of the IP", remote: "10.0.0.1:51235", status: http.StatusTooManyRequests},
+		{name: "other IP", remote: "10.0.0.2:51234", status: http.StatusOK},
+		{name: "token of the IP", remote: "10.0.0.1:51234", token: "ops", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			r := httptest.NewRequest(http.MethodGet, "/logs", nil)
+			r.RemoteAddr = test.remote
+			if test.token != "" {
+				r = r.WithContext(context.WithValue(r.Context(), tokenKey{}, apiToken{name: test.token}))
+			}
+			w := httptest.NewRecorder()
+
+			ok := s.limiter.limit(w, r)
+
+			s.Equal(test.status == http.StatusOK, ok)
+			s.Equal(test.status, w.Code)
+			if !ok {
+				s.Equal("1", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func (s *limitsSuite) Test_limit_None() {
+	var limiter *clientLimiter
+
+	s.True(limiter.limit(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/logs", nil)))
+}
+
+func TestLimits(t *testing.T) {
+	suite.Run(t, new(limitsSuite))
+}
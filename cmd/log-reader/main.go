@@ -1,30 +1,557 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	// the time zones of -rewrite-time-zone are known even without a database of the system, e.g. on Windows
+	_ "time/tzdata"
 
 	"github.com/chill-and-code/apache-log-reader/logging"
 )
 
 func main() {
-	directoryFlag := flag.String("d", ".", "the directory where all the logs are stored")
-	minutesFlag := flag.Int("t", 1, "last n minutes worth of logs to read")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "compare":
+			runCompare(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "grpc":
+			runGRPC(os.Args[2:])
+			return
+		case "ship":
+			runShip(os.Args[2:])
+			return
+		case "alert":
+			runAlert(os.Args[2:])
+			return
+		case "daemon":
+			runDaemon(os.Args[2:])
+			return
+		case "validate":
+			runValidate(os.Args[2:])
+			return
+		case "generate":
+			runGenerate(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "correlate":
+			runCorrelate(os.Args[2:])
+			return
+		case "backfill":
+			runBackfill(os.Args[2:])
+			return
+		case "prune":
+			runPrune(os.Args[2:])
+			return
+		case "archive":
+			runArchive(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "convert":
+			runConvert(os.Args[2:])
+			return
+		}
+	}
 
-	flag.Parse()
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	showSourceFlag := fs.Bool("show-source", false, "prefix every log line with the file and the byte offset it was read from")
+	progressFlag := fs.Bool("progress", false, "render the bytes of the log files processed out of the total and the time left to stderr, e.g. for multi-GB extracts")
+	topFlag := fs.String("top", "", "print the most frequent values instead of the log lines, e.g. ips=10,paths=10,methods=5,protocols=5,agents=5,referers=5,vhosts=5,bots=5,tls-protocols=5,tls-ciphers=5,hosts=5,bytes-by-path=10,bytes-by-ip=10, or of a parameter of the query strings, e.g. param:utm_source=10")
+	failIfEmptyFlag := fs.Bool("fail-if-empty", false, "exit with status 4 when no log file was modified within the window, or 5 when no log of the window was printed")
+	failOnInvalidFlag := fs.Bool("fail-on-invalid", false, "exit with status 6 when log lines not matching the log format were read, every log line being parsed")
+	errorSummaryFlag := fs.String("error-summary", "", "print a summary of the errors to stderr once done, the invalid log lines and the failure of the run counted per file and per type, as text (only when there were errors) or json (always), every log line being parsed")
+	summaryFlag := fs.String("summary", "", "print a summary of the extract to stderr once done, the entries kept, the bytes written, the time range the entries actually cover and the files they were read from, as text or json, every log line being parsed")
+	splitFlag := fs.String("split", "", "write the log lines to separate files of -output-dir instead of stdout, one per hour (hourly), day (daily) or virtual host (vhost), e.g. 2022-03-04T05.log")
+	outputDirFlag := fs.String("output-dir", "out", "the directory of the files written by -split, created if needed")
+	outputFlag := fs.String("o", "", "write the log lines to the given file instead of stdout")
+	reverseFlag := fs.Bool("reverse", false, "print the newest log lines first, reading the log files backwards")
+	manifestFlag := fs.String("manifest", "", "write a JSON manifest of the files written by -o or -split to the given file: their SHA-256 checksums, the byte ranges of the log files their lines were read from and the query used")
+	colorFlag := fs.Bool("color", false, "color the log lines by severity (see -severity), e.g. the 5xx in red and the 4xx in yellow")
+	maxScanFlag := fs.Float64("max-scan-mb", 0, "refuse to read more than the given megabytes (MiB) of the log files, estimated beforehand from their sizes and indexes without reading them, asking for a confirmation on a terminal, 0 for no limit")
+	maxDurationFlag := fs.Duration("max-duration", 0, "refuse a window longer than the given duration, e.g. 24h against an accidental -t 525600, asking for a confirmation on a terminal, 0 for no limit")
+	yesFlag := fs.Bool("yes", false, "read past -max-scan-mb and -max-duration without asking for a confirmation")
+	versionFlag := fs.Bool("version", false, "print the version, commit and build date of the binary, and exit")
+	startProfiling := profileFlags(fs)
+	parseFlags(fs, "print", os.Args[1:])
+	if *versionFlag {
+		fmt.Println("log-reader " + logging.Build().String())
+		return
+	}
 
-	cfg := logging.LogsConfig{
-		Directory:    *directoryFlag,
-		LastNMinutes: *minutesFlag,
+	specs, err := parseTop(*topFlag)
+	if err != nil {
+		exit(exitUsage, "invalid top: %v", err)
+	}
+	if len(specs) > 0 && *splitFlag != "" {
+		exit(exitUsage, "invalid configuration: -top and -split cannot be combined")
+	}
+	if *reverseFlag && len(specs) > 0 {
+		exit(exitUsage, "invalid configuration: -top and -reverse cannot be combined")
+	}
+	if *outputFlag != "" && (len(specs) > 0 || *splitFlag != "") {
+		exit(exitUsage, "invalid configuration: -o cannot be combined with -top or -split")
+	}
+	if *manifestFlag != "" && *outputFlag == "" && *splitFlag == "" {
+		exit(exitUsage, "invalid configuration: -manifest takes -o or -split")
+	}
+	if *maxScanFlag < 0 || *maxDurationFlag < 0 {
+		exit(exitUsage, "invalid configuration: -max-scan-mb and -max-duration must not be negative")
+	}
+	if *errorSummaryFlag != "" && *errorSummaryFlag != outputText && *errorSummaryFlag != outputJSON {
+		exit(exitUsage, "invalid error summary: must be %s or %s", outputText, outputJSON)
+	}
+	if *summaryFlag != "" && *summaryFlag != outputText && *summaryFlag != outputJSON {
+		exit(exitUsage, "invalid summary: must be %s or %s", outputText, outputJSON)
+	}
+	classifyBots := false
+	for _, spec := range specs {
+		classifyBots = classifyBots || spec.field == logging.TopBots
+	}
+
+	cfg, err := logsConfig(classifyBots)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if *progressFlag {
+		cfg.Progress = progressLine(os.Stderr)
+	}
+	if *colorFlag {
+		cfg.Middlewares = append(cfg.Middlewares, logging.ColorBySeverity)
+	}
+	if *reverseFlag && cfg.Merge {
+		exit(exitUsage, "invalid configuration: -merge and -reverse cannot be combined")
+	}
+	var split *splitter
+	if *splitFlag != "" {
+		if split, err = newSplitter(*splitFlag, *outputDirFlag, cfg.Format, *showSourceFlag); err != nil {
+			exit(exitUsage, "invalid split: %v", err)
+		}
+	}
+	if *manifestFlag != "" && cfg.End.IsZero() {
+		// the window is pinned, so the manifest reports the exact time range the logs were read from
+		cfg.End = time.Now()
+	}
+	var errs *logging.ErrorSummary
+	if *errorSummaryFlag != "" {
+		errs = &logging.ErrorSummary{}
+		cfg.Errors = errs
+	}
+	var invalid, entries int64
+	if *failOnInvalidFlag {
+		// first, so every log line read is counted, the ones filtered out too
+		cfg.Filters = append([]logging.Filter{invalidCounter(&invalid)}, cfg.Filters...)
+	}
+	if *failIfEmptyFlag && len(specs) > 0 {
+		// last, so only the log entries kept are counted, the ranking being printed either way
+		cfg.Filters = append(cfg.Filters, func(logging.LogEntry) bool {
+			atomic.AddInt64(&entries, 1)
+			return true
+		})
+	}
+	var summary *extractSummary
+	if *summaryFlag != "" {
+		// last, so only the log entries kept are counted
+		summary = newExtractSummary()
+		cfg.Filters = append(cfg.Filters, summary.count)
+		if cfg.Metrics == nil {
+			cfg.Metrics = &logging.Metrics{}
+		}
 	}
 	logs, err := logging.NewLogs(cfg)
+	var configErr *logging.ConfigError
+	if errors.As(err, &configErr) {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
 	if err != nil {
-		log.Fatalf("could not create logs: %v", err)
+		exit(exitIOFailure, "could not create logs: %v", err)
 	}
 
-	err = logs.Print(os.Stdout)
-	if err != nil {
-		log.Fatalf("could not print logs: %v", err)
+	// once interrupted, the logs read so far are printed and the profiles written before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	window := cfg.Window
+	if window == 0 {
+		window = time.Duration(cfg.LastNMinutes) * time.Minute
+	}
+	if err := newScanGuard(*maxScanFlag, *maxDurationFlag, *yesFlag).check(ctx, logs, window); err != nil {
+		exit(exitRefused, "refused: %v", err)
+	}
+	// the output is only created once the run isn't refused
+	var output *outputFile
+	if *outputFlag != "" {
+		if output, err = createOutput(*outputFlag); err != nil {
+			exit(exitIOFailure, "could not create output: %v", err)
+		}
+	}
+	stopProfiling := startProfiling()
+	out := &countingWriter{w: os.Stdout}
+	each := logs.ForEach
+	if *reverseFlag {
+		each = logs.ForEachReverse
+	}
+	switch {
+	case len(specs) > 0:
+		err = runTop(ctx, logs, specs, out)
+	case split != nil:
+		err = split.run(ctx, each)
+		out.n = split.written
+	case output != nil:
+		err = output.run(ctx, each, *showSourceFlag)
+		out.n = output.bytes
+	case *showSourceFlag || *reverseFlag || len(cfg.Hosts) > 0:
+		err = printEntries(ctx, each, out, *showSourceFlag)
+	default:
+		err = logs.Print(ctx, out)
+	}
+	stopProfiling()
+	if ctx.Err() != nil {
+		exit(exitInterrupted, "interrupted")
+	}
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		errs.Add(err)
+		printErrorSummary(os.Stderr, *errorSummaryFlag, errs, err)
+		printExtractSummary(os.Stderr, *summaryFlag, summary, out.n, cfg.Metrics, err)
+		exit(exitIOFailure, "could not print logs: %v", err)
+	}
+	printErrorSummary(os.Stderr, *errorSummaryFlag, errs, nil)
+	printExtractSummary(os.Stderr, *summaryFlag, summary, out.n, cfg.Metrics, nil)
+	if *manifestFlag != "" {
+		files := []*outputFile{output}
+		if split != nil {
+			files = split.outputs()
+		}
+		if err := writeManifest(*manifestFlag, newManifest(cfg, time.Now(), files)); err != nil {
+			exit(exitIOFailure, "could not write manifest: %v", err)
+		}
+	}
+
+	switch {
+	case *failIfEmptyFlag && errors.Is(err, logging.ErrNoFilesInWindow):
+		exit(exitNoFilesInWindow, "no log file was modified within the window")
+	case *failIfEmptyFlag && (len(specs) > 0 && entries == 0 || len(specs) == 0 && out.n == 0):
+		exit(exitEmpty, "no log within the window")
+	case *failOnInvalidFlag && invalid > 0:
+		exit(exitInvalidLines, "log lines not matching the log format: %d", invalid)
+	}
+}
+
+// logsFlags defines the flags selecting the logs to read on a given flag set,
+// returning a function building the logs configuration once the flags are parsed.
+// The log entries are classified as bots or humans when required (e.g. to rank the bots),
+// or when the bots are excluded or their patterns are given.
+func logsFlags(fs *flag.FlagSet) func(classifyBots bool) (logging.LogsConfig, error) {
+	directoryFlag := fs.String("d", ".", "the directory where all the logs are stored")
+	hostsFlag := fs.String("hosts", "", "read the logs of several hosts instead of -d, merged by time and labelled with their hosts, as comma separated name=directory pairs, e.g. web1=/var/log/web1,web2=/mnt/web2 (remote hosts being mounted, e.g. with sshfs or s3fs), each possibly followed by how far ahead its clock is, e.g. web2=/mnt/web2@-1.5s, or @auto to estimate it from the modified time of its latest file")
+	minutesFlag := fs.Int("t", 1, "last n minutes worth of logs to read")
+	formatFlag := fs.String("f", string(logging.FormatCommon), "the format of the log lines: common, combined, vhost_combined, combined_cache, combined_xff, combined_request_id, ssl_request, cri, error, or a custom one of a plugin (see -plugins)")
+	formatsFlag := fs.String("formats", "", "the formats of the log files which names match comma separated patterns, the first match winning over -f, e.g. 'access*: combined, error*: error' (usually along with -merge)")
+	followSymlinksFlag := fs.Bool("follow-symlinks", false, "read the log files symlinked inside the directory")
+	filenameTimePatternFlag := fs.String("filename-time-pattern", "", "select the log files by the time embedded in their names (in UTC or -time-zone) rather than their modified time, e.g. 'access-%Y%m%d-%H.log' (%Y, %m, %d, %H, %M, %S)")
+	timeZoneFlag := fs.String("time-zone", "", "the time zone of the log times which don't give theirs (error format) and of the times of the file names (-filename-time-pattern), e.g. Europe/Berlin or Local, UTC by default")
+	shardedFlag := fs.Bool("sharded", false, "read the log files of the subdirectories dated by year, month, day and optionally hour (e.g. 2024/05/12/access.log), only descending into the ones within a day of the time range")
+	orderByContentFlag := fs.Bool("order-by-content", false, "order the log files by their first/last log times instead of their modified time")
+	mergeFlag := fs.Bool("merge", false, "interleave the logs of files with overlapping time ranges by their times")
+	deterministicOrderFlag := fs.Bool("deterministic-order", false, "order the merged logs with the same time by the paths of their files, then their lines, so repeated runs print the same output to diff or checksum")
+	toleranceFlag := fs.Int64("tolerance", 0, "number of bytes to rewind and check for logs written out of order")
+	workersFlag := fs.String("workers", "1", "number of log files parsed in parallel, the logs being printed in order all the same, or auto for one per CPU (GOMAXPROCS)")
+	readAheadBatchFlag := fs.Int("read-ahead-batch", 0, "number of log lines a file read in parallel (see -workers) parses at a time, 0 for 512")
+	readAheadDepthFlag := fs.Int("read-ahead-depth", 0, "number of batches of log lines a file read in parallel (see -workers) parses ahead of the output, e.g. 8 on network mounts of high latency, 0 for 1")
+	readBufferFlag := fs.Int("read-buffer-kb", 0, "size of the buffer in KB every log file is read through, 0 for 4KB or 64KB for the files of 64MB or more")
+	writeBufferFlag := fs.Int("write-buffer-kb", 0, "size of the buffer in KB the log lines are printed to, 0 for 64KB")
+	indexFlag := fs.Duration("index", 0, "search the log files using sidecar index files (.logidx) storing an offset every given interval of log time (e.g. 10s), built on first use and reused by the next runs")
+	indexBloomFlag := fs.Bool("index-bloom", false, "store Bloom filters of the IPs and path prefixes inside the index files (-index), skipping the intervals without any log of -ip or -path")
+	ipFlag := fs.String("ip", "", "only read the logs of the given IP")
+	xffFlag := fs.String("xff", "", "take the client IPs from the X-Forwarded-For headers (combined_xff format) for -ip, the filters and the reports: first, the first address, or last-trusted, the last one which isn't a trusted proxy (see -trusted-proxies)")
+	trustedProxiesFlag := fs.String("trusted-proxies", "", "comma separated CIDRs or IPs of the proxies and load balancers which X-Forwarded-For headers are believed, e.g. 10.0.0.0/8,172.16.0.0/12, making -xff default to last-trusted")
+	pathFlag := fs.String("path", "", "only read the logs which path starts with the given prefix")
+	mmapFlag := fs.Bool("mmap", false, "read the log files through memory mappings, sparing the syscalls of seeking and reading large files")
+	verboseFlag := fs.Bool("verbose", false, "report to stderr which log files are selected, the offsets found inside them, how long every phase takes and the statistics of every file read (bytes, lines emitted and filtered out, time range, parse time), e.g. to understand why nothing is printed or to tune the filters")
+	debugFlag := fs.Bool("debug", false, "report every invalid log line too, along with what -verbose reports")
+	maxReadFlag := fs.Float64("max-read-mbps", 0, "maximum megabytes (MiB) read from the log files per second, so a large extract doesn't starve a busy server of disk I/O, 0 for no limit")
+	excludeBotsFlag := fs.Bool("exclude-bots", false, "leave out the logs of bots and crawlers, recognized by their user agents (combined format)")
+	vhostsFlag := fs.String("vhosts", "", "only read the logs of the given comma separated virtual hosts (vhost_combined format)")
+	botPatternsFlag := fs.String("bot-patterns", "", "file of additional user agent patterns recognizing bots, one per line")
+	whereFlag := fs.String("where", "", "only read the logs matching a condition, comparisons of their fields joined by &&, e.g. 'status>=500 && path=~^/api' or 'param(\"utm_source\") == \"newsletter\"'")
+	weakTLSFlag := fs.Bool("weak-tls", false, "only read the logs of requests negotiating a deprecated TLS protocol or a weak cipher, e.g. TLSv1 or RC4 (ssl_request format)")
+	normalizePathsFlag := fs.Bool("normalize-paths", false, "replace the numeric ids, UUIDs and hashes of the paths with {id}, {uuid} and {hash}, so the paths are grouped by endpoint")
+	duplicatesFlag := fs.String("duplicates", "", "detect the log lines duplicated across files by a misconfigured rotation, the same line read from another file within -duplicate-window: report (to stderr) or suppress (leave out and report)")
+	duplicateWindowFlag := fs.Duration("duplicate-window", logging.DefaultDuplicateWindow, "how far apart in log time a log line and its duplicate are looked for (see -duplicates)")
+	copyToFlag := fs.String("copy-to", "", "copy the log files modified within the time range into an empty directory first and read the copies, e.g. for forensics: the sources are read once and the index files are written next to the copies")
+	maxOpenFilesFlag := fs.Int("max-open-files", 0, "maximum number of log files kept open at once, e.g. 256 to merge or follow thousands of rotated files under the ulimit of open files, 0 for no limit")
+	skipVanishedFlag := fs.Bool("skip-vanished", false, "skip the log files removed between the scan of the directory and their opening, e.g. by an aggressive logrotate, reading the remaining ones: the files skipped are reported with -verbose and counted by -summary")
+	maxTopValuesFlag := fs.Int("max-top-values", 0, "maximum number of distinct values of every field counted by -top (and the top of serve), counting the most frequent ones approximately past it so a month of IPs or paths fits in memory, e.g. 100000, 0 to count them all")
+	pollIntervalFlag := fs.Duration("poll-interval", time.Second, "how often the log files are checked for new lines when following them (ship -follow, alert, serve), e.g. 10s to spare the round trips to an NFS/SMB mount")
+	assertSortedFlag := fs.Bool("assert-sorted", false, "guarantee the log lines are printed in non-decreasing time order, putting the ones slightly out of order back in order within -reorder-buffer and failing on the first one still out of order")
+	reorderBufferFlag := fs.Int("reorder-buffer", 0, "number of log lines held back to put them in order (see -assert-sorted), 0 to only verify the order")
+	rewriteTimeZoneFlag := fs.String("rewrite-time-zone", "", "rewrite the times of the log lines printed in a time zone, e.g. Europe/Berlin or Local, the logs being searched and filtered by their original times all the same")
+	timeFormatFlag := fs.String("time-format", "", "rewrite the times of the log lines printed in a layout: clf, rfc3339, rfc3339nano or a layout of Go, e.g. '2006-01-02 15:04:05 MST'")
+	pluginsFlag := fs.String("plugins", "", "comma separated Go plugins (glob patterns) to load first, registering custom formats (-f), filters (-filter) and sinks (ship -sink), e.g. /etc/log-reader/plugins/*.so")
+	var filterFlags stringsFlag
+	fs.Var(&filterFlags, "filter", "only read the logs kept by a filter registered by a plugin, as name or name:argument, e.g. internal or slower-than:500ms, several times to apply several filters")
+	severityFlag := fs.Bool("severity", false, "map the logs to severities by the status of their requests, 5xx to error, 4xx to warn and the others to info, passed on as the priorities of syslog and the level label of Loki")
+	severityMapFlag := fs.String("severity-map", "", "rules mapping the logs to severities taking precedence over the ones of -severity (implied), by status, status class or * for the others, e.g. 404=info,3xx=notice,*=debug")
+	sampleFlag := fs.String("sample", "", "keep a share of the logs by the status of their requests, as a fraction or a percentage per status, status class or * for the others (all of them by default), e.g. 5xx=1,4xx=10%,2xx=0.01 to keep every error, the same lines being kept by every run")
+	requestIDFlag := fs.String("request-id", "", "comma separated request ids to only read the logs of (combined_request_id format), either as logged or as the trace ids they link to, e.g. the trace id of an APM to find all of its requests")
+	linkTracesFlag := fs.Bool("link-traces", false, "link the logs to W3C trace context ids by their request ids (combined_request_id format), writing trace_id and span_id along with them as JSON (convert -out-format json or the sinks of ship) to join them with the traces of an APM")
+	pathRulesFlag := fs.String("path-rules", "", "file of additional rules normalizing the paths (see -normalize-paths), one regular expression and its template per line, e.g. '^/users/[^/]+ /users/{name}'")
+
+	return func(classifyBots bool) (logging.LogsConfig, error) {
+		if *pluginsFlag != "" {
+			if err := logging.LoadPlugins(strings.Split(*pluginsFlag, ",")...); err != nil {
+				return logging.LogsConfig{}, err
+			}
+		}
+		cfg := logging.LogsConfig{
+			Directory:           *directoryFlag,
+			LastNMinutes:        *minutesFlag,
+			Format:              logging.Format(*formatFlag),
+			FollowSymlinks:      *followSymlinksFlag,
+			Sharded:             *shardedFlag,
+			FilenameTimePattern: *filenameTimePatternFlag,
+			OrderByContent:      *orderByContentFlag,
+			Merge:               *mergeFlag,
+			DeterministicOrder:  *deterministicOrderFlag,
+			Tolerance:           *toleranceFlag,
+			IndexInterval:       *indexFlag,
+			IndexBloom:          *indexBloomFlag,
+			IP:                  *ipFlag,
+			PathPrefix:          *pathFlag,
+			ReadAheadBatch:      *readAheadBatchFlag,
+			ReadAheadDepth:      *readAheadDepthFlag,
+			ReadBufferSize:      *readBufferFlag << 10,
+			WriteBufferSize:     *writeBufferFlag << 10,
+			MemoryMap:           *mmapFlag,
+			MaxReadRate:         int64(*maxReadFlag * (1 << 20)),
+			MaxOpenFiles:        *maxOpenFilesFlag,
+			SkipVanished:        *skipVanishedFlag,
+			MaxTopValues:        *maxTopValuesFlag,
+			CopyDir:             *copyToFlag,
+			PollInterval:        *pollIntervalFlag,
+			AssertSorted:        *assertSortedFlag,
+			ReorderBuffer:       *reorderBufferFlag,
+		}
+
+		if *timeZoneFlag != "" {
+			loc, err := time.LoadLocation(*timeZoneFlag)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid -time-zone: %w", err)
+			}
+			cfg.TimeZone = loc
+		}
+
+		cfg.Workers = logging.DefaultWorkers()
+		if *workersFlag != "auto" {
+			workers, err := strconv.Atoi(*workersFlag)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid -workers '%s': must be a number or auto", *workersFlag)
+			}
+			cfg.Workers = workers
+		}
+
+		if *verboseFlag || *debugFlag {
+			cfg.Logger = &textLogger{w: os.Stderr, debug: *debugFlag}
+		}
+
+		if *duplicatesFlag != "" {
+			duplicates, err := logging.ParseDuplicates(*duplicatesFlag)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Duplicates, cfg.DuplicateWindow = duplicates, *duplicateWindowFlag
+			if cfg.Logger == nil {
+				// the duplicates are reported even without -verbose
+				cfg.Logger = &textLogger{w: os.Stderr, only: "duplicate lines"}
+			}
+		}
+		if *hostsFlag != "" {
+			hosts, err := logging.ParseHosts(*hostsFlag)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Hosts, cfg.Directory = hosts, ""
+		}
+		if *xffFlag != "" {
+			xff, err := logging.ParseXFF(*xffFlag)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.XFF = xff
+		}
+		if *trustedProxiesFlag != "" {
+			proxies, err := logging.ParseTrustedProxies(*trustedProxiesFlag)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.TrustedProxies = proxies
+			if cfg.XFF == "" {
+				cfg.XFF = logging.XFFLastTrusted
+			}
+		}
+		if *formatsFlag != "" {
+			formats, err := logging.ParseFormatPatterns(*formatsFlag)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Formats = formats
+		}
+		var patterns []string
+		if *botPatternsFlag != "" {
+			var err error
+			patterns, err = logging.LoadBotPatterns(*botPatternsFlag)
+			if err != nil {
+				return cfg, err
+			}
+		}
+		if classifyBots || *excludeBotsFlag || *botPatternsFlag != "" {
+			cfg.Bots = logging.NewBotClassifier(patterns...)
+		}
+		if *excludeBotsFlag {
+			cfg.Filters = append(cfg.Filters, logging.ExcludeBots)
+		}
+		if *normalizePathsFlag || *pathRulesFlag != "" {
+			var rules []logging.PathRule
+			if *pathRulesFlag != "" {
+				var err error
+				rules, err = logging.LoadPathRules(*pathRulesFlag)
+				if err != nil {
+					return cfg, err
+				}
+			}
+			cfg.Middlewares = append(cfg.Middlewares, logging.NewPathNormalizer(rules...).Transform)
+		}
+		if *severityFlag || *severityMapFlag != "" {
+			severities, err := logging.ParseSeverityMap(*severityMapFlag)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Middlewares = append(cfg.Middlewares, severities.Transform)
+		}
+		if *rewriteTimeZoneFlag != "" || *timeFormatFlag != "" {
+			var loc *time.Location
+			if *rewriteTimeZoneFlag != "" {
+				var err error
+				if loc, err = time.LoadLocation(*rewriteTimeZoneFlag); err != nil {
+					return cfg, fmt.Errorf("invalid -rewrite-time-zone: %w", err)
+				}
+			}
+			// the last middleware, so the others see the lines as logged
+			cfg.Middlewares = append(cfg.Middlewares, logging.NewTimeRewriter(cfg.Format, loc, logging.ParseTimeLayout(*timeFormatFlag)).Transform)
+		}
+		if *linkTracesFlag {
+			cfg.Middlewares = append(cfg.Middlewares, logging.LinkTrace)
+		}
+		if *vhostsFlag != "" {
+			cfg.Filters = append(cfg.Filters, logging.InVHosts(strings.Split(*vhostsFlag, ",")...))
+		}
+		if *requestIDFlag != "" {
+			cfg.Filters = append(cfg.Filters, logging.RequestIDFilter(strings.Split(*requestIDFlag, ",")...))
+		}
+		if *weakTLSFlag {
+			cfg.Filters = append(cfg.Filters, logging.WeakTLS)
+		}
+		if *whereFlag != "" {
+			filter, err := logging.ParseCondition(*whereFlag)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Filters = append(cfg.Filters, filter)
+		}
+		for _, name := range filterFlags {
+			filter, err := logging.ParseFilter(name)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Filters = append(cfg.Filters, filter)
+		}
+		if *sampleFlag != "" {
+			sampler, err := logging.ParseSampler(*sampleFlag)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Filters = append(cfg.Filters, sampler.Keep)
+		}
+		return cfg, nil
+	}
+}
+
+// runTop ranks the values of the given fields, printing a table for each of them.
+func runTop(ctx context.Context, logs *logging.Logs, specs []topSpec, w io.Writer) error {
+	fields := make([]logging.TopField, 0, len(specs))
+	for _, spec := range specs {
+		fields = append(fields, spec.field)
+	}
+	top, err := logs.Top(ctx, fields...)
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		return err
+	}
+	if printErr := printTop(w, top, specs); printErr != nil {
+		return printErr
+	}
+	// the ranking is empty when there are no files within the window, which is up to the caller
+	return err
+}
+
+// logsSource describes where the logs of a given configuration are read from for the messages,
+// i.e. their directory or the names of their hosts (see -hosts).
+func logsSource(cfg logging.LogsConfig) string {
+	if len(cfg.Hosts) == 0 {
+		return cfg.Directory
+	}
+	names := make([]string, len(cfg.Hosts))
+	for i, host := range cfg.Hosts {
+		names[i] = host.Name
+	}
+	return "hosts " + strings.Join(names, ", ")
+}
+
+// forEach calls a given function for each log entry to print, i.e. Logs.ForEach, or Logs.ForEachReverse (see -reverse).
+type forEach func(ctx context.Context, fn func(logging.LogEntry) error) error
+
+// printEntries prints the log lines of the log entries the same way Logs.Print does,
+// prefixing each of them with its host and its source when told so, see entryLine.
+func printEntries(ctx context.Context, each forEach, w io.Writer, showSource bool) error {
+	bw := bufio.NewWriter(w)
+	err := each(ctx, func(entry logging.LogEntry) error {
+		_, err := bw.WriteString(entryLine(entry, showSource))
+		return err
+	})
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// entryLine returns the log line of a given log entry as printed, ending with a newline: prefixed with its host
+// when reading several hosts (see -hosts), then with the file and the byte offset it was read from when told so.
+func entryLine(entry logging.LogEntry, showSource bool) string {
+	line := entry.Line
+	if showSource {
+		line = fmt.Sprintf("%s:%d: %s", entry.File, entry.Offset, line)
+	}
+	if entry.Host != "" {
+		line = entry.Host + " " + line
 	}
+	return line + "\n"
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// manifest describes the files of an extract (see -manifest), so they can serve as audit or forensic evidence:
+// the query which selected the logs, and the checksum of every file along with the byte ranges
+// of the log files its lines were read from.
+type manifest struct {
+	Created time.Time      `json:"created"`
+	Version string         `json:"version"`
+	Query   manifestQuery  `json:"query"`
+	Files   []manifestFile `json:"files"`
+}
+
+// manifestQuery are the parameters of the query which selected the logs of an extract.
+type manifestQuery struct {
+	// Args are the command line arguments, the configuration file included when given.
+	Args       []string  `json:"args"`
+	Directory  string    `json:"directory"`
+	Format     string    `json:"format"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	IP         string    `json:"ip,omitempty"`
+	PathPrefix string    `json:"path_prefix,omitempty"`
+}
+
+// manifestFile describes a file of an extract.
+type manifestFile struct {
+	Path    string           `json:"path"`
+	SHA256  string           `json:"sha256"`
+	Bytes   int64            `json:"bytes"`
+	Lines   int64            `json:"lines"`
+	Sources []manifestSource `json:"sources"`
+}
+
+// manifestSource is the byte range [From, To) of a log file the lines of a file of an extract were read from,
+// from the start of the first line to the end of the last one, its line ending excluded.
+// The lines in between were filtered out when fewer Lines than the range holds were written.
+type manifestSource struct {
+	File  string `json:"file"`
+	From  int64  `json:"from"`
+	To    int64  `json:"to"`
+	Lines int64  `json:"lines"`
+}
+
+// newManifest creates the manifest of an extract of the logs selected by a given configuration,
+// up to a given time unless the configuration ends earlier, describing the given files sorted by path.
+func newManifest(cfg logging.LogsConfig, now time.Time, files []*outputFile) manifest {
+	to := now.UTC()
+	if !cfg.End.IsZero() {
+		to = cfg.End.UTC()
+	}
+	window := cfg.Window
+	if window == 0 {
+		window = time.Duration(cfg.LastNMinutes) * time.Minute
+	}
+	format := cfg.Format
+	if format == "" {
+		format = logging.FormatCommon
+	}
+	m := manifest{
+		Created: now.UTC(),
+		Version: logging.Build().String(),
+		Query: manifestQuery{
+			Args:       os.Args[1:],
+			Directory:  cfg.Directory,
+			Format:     string(format),
+			From:       to.Add(-window),
+			To:         to,
+			IP:         cfg.IP,
+			PathPrefix: cfg.PathPrefix,
+		},
+		Files: make([]manifestFile, 0, len(files)),
+	}
+	for _, file := range files {
+		mf := manifestFile{
+			Path: file.name, SHA256: hex.EncodeToString(file.hash.Sum(nil)), Bytes: file.bytes, Lines: file.lines,
+			Sources: make([]manifestSource, 0, len(file.sources)),
+		}
+		for _, source := range file.sources {
+			mf.Sources = append(mf.Sources, *source)
+		}
+		m.Files = append(m.Files, mf)
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m
+}
+
+// writeManifest writes a given manifest as JSON to a given file.
+func writeManifest(name string, m manifest) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(f, m); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// outputFile is a file the log lines of an extract are written to (see -o and -split), through a buffer,
+// keeping track of what its manifest reports: its checksum and the byte ranges of the log files its lines were read from.
+type outputFile struct {
+	name         string
+	f            *os.File
+	w            *bufio.Writer
+	hash         hash.Hash
+	bytes, lines int64
+	// sources are the byte ranges of the log files, in the order they were read
+	sources     []*manifestSource
+	sourceIndex map[string]*manifestSource
+}
+
+// createOutput creates the output file of a given name, truncating it if it exists.
+func createOutput(name string) (*outputFile, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	return &outputFile{
+		name:        name,
+		f:           f,
+		w:           bufio.NewWriterSize(io.MultiWriter(f, h), 64<<10),
+		hash:        h,
+		sourceIndex: make(map[string]*manifestSource),
+	}, nil
+}
+
+// write writes the line of a given log entry, prefixed with its host and its source when told so, see entryLine.
+func (o *outputFile) write(entry logging.LogEntry, showSource bool) error {
+	n, err := o.w.WriteString(entryLine(entry, showSource))
+	o.bytes += int64(n)
+	o.lines++
+
+	end := entry.Offset + int64(len(entry.Line))
+	source, ok := o.sourceIndex[entry.File]
+	if !ok {
+		source = &manifestSource{File: entry.File, From: entry.Offset, To: end}
+		o.sourceIndex[entry.File] = source
+		o.sources = append(o.sources, source)
+	}
+	if entry.Offset < source.From {
+		source.From = entry.Offset
+	}
+	if end > source.To {
+		source.To = end
+	}
+	source.Lines++
+	return err
+}
+
+// run writes the log entries to the file, until done or the context is done.
+// The file is flushed and closed either way.
+func (o *outputFile) run(ctx context.Context, each forEach, showSource bool) error {
+	err := each(ctx, func(entry logging.LogEntry) error {
+		return o.write(entry, showSource)
+	})
+	if closeErr := o.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// close flushes and closes the file.
+func (o *outputFile) close() error {
+	err := o.w.Flush()
+	if closeErr := o.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type manifestSuite struct {
+	suite.Suite
+}
+
+func (s *manifestSuite) Test_outputFile() {
+	tests := []struct {
+		name            string
+		entries         []logging.LogEntry
+		expectedContent string
+		expectedSources []manifestSource
+	}{
+		{
+			name:            "No Entries",
+			expectedSources: []manifestSource{},
+		},
+		{
+			name: "Contiguous Lines",
+			entries: []logging.LogEntry{
+				{Line: "first", File: "access.log", Offset: 10},
+				{Line: "second", File: "access.log", Offset: 16},
+			},
+			expectedContent: "first\nsecond\n",
+			expectedSources: []manifestSource{{File: "access.log", From: 10, To: 22, Lines: 2}},
+		},
+		{
+			name: "Filtered Lines In Between",
+			entries: []logging.LogEntry{
+				{Line: "first", File: "access.log", Offset: 0},
+				{Line: "third", File: "access.log", Offset: 100},
+			},
+			expectedContent: "first\nthird\n",
+			expectedSources: []manifestSource{{File: "access.log", From: 0, To: 105, Lines: 2}},
+		},
+		{
+			name: "Files In The Order They Were Read",
+			entries: []logging.LogEntry{
+				{Line: "old", File: "access.log.1", Offset: 50},
+				{Line: "new", File: "access.log", Offset: 0},
+				{Line: "older", File: "access.log.1", Offset: 20},
+			},
+			expectedContent: "old\nnew\nolder\n",
+			expectedSources: []manifestSource{
+				{File: "access.log.1", From: 20, To: 53, Lines: 2},
+				{File: "access.log", From: 0, To: 3, Lines: 1},
+			},
+		},
+		{
+			name: "Hosts",
+			entries: []logging.LogEntry{
+				{Line: "line", File: "web1/access.log", Offset: 0, Host: "web1"},
+			},
+			expectedContent: "web1 line\n",
+			expectedSources: []manifestSource{{File: "web1/access.log", From: 0, To: 4, Lines: 1}},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			name := filepath.Join(s.T().TempDir(), "extract.log")
+			file, err := createOutput(name)
+			s.Require().NoError(err)
+			s.Require().NoError(file.run(context.Background(), entries(test.entries...), false))
+
+			content, err := os.ReadFile(name)
+			s.Require().NoError(err)
+			s.Equal(test.expectedContent, string(content))
+			sum := sha256.Sum256(content)
+
+			m := newManifest(logging.LogsConfig{}, time.Now(), []*outputFile{file})
+			s.Require().Len(m.Files, 1)
+			s.Equal(manifestFile{
+				Path:    name,
+				SHA256:  hex.EncodeToString(sum[:]),
+				Bytes:   int64(len(content)),
+				Lines:   int64(len(test.entries)),
+				Sources: test.expectedSources,
+			}, m.Files[0])
+		})
+	}
+}
+
+func (s *manifestSuite) Test_newManifest() {
+	now := time.Date(2022, time.March, 3, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name          string
+		cfg           logging.LogsConfig
+		expectedQuery manifestQuery
+	}{
+		{
+			name: "Last Minutes Up To Now",
+			cfg:  logging.LogsConfig{Directory: "logs", LastNMinutes: 5},
+			expectedQuery: manifestQuery{
+				Directory: "logs",
+				Format:    string(logging.FormatCommon),
+				From:      now.UTC().Add(-5 * time.Minute),
+				To:        now.UTC(),
+			},
+		},
+		{
+			name: "Window Up To The End",
+			cfg: logging.LogsConfig{
+				Directory: "logs", Format: logging.FormatCombined, Window: time.Hour, LastNMinutes: 5,
+				End: now.Add(-time.Hour), IP: "10.0.0.1", PathPrefix: "/api",
+			},
+			expectedQuery: manifestQuery{
+				Directory:  "logs",
+				Format:     string(logging.FormatCombined),
+				From:       now.UTC().Add(-2 * time.Hour),
+				To:         now.UTC().Add(-time.Hour),
+				IP:         "10.0.0.1",
+				PathPrefix: "/api",
+			},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			m := newManifest(test.cfg, now, nil)
+			s.Equal(now.UTC(), m.Created)
+			s.Equal(logging.Build().String(), m.Version)
+			test.expectedQuery.Args = os.Args[1:]
+			s.Equal(test.expectedQuery, m.Query)
+			s.Empty(m.Files)
+		})
+	}
+}
+
+func (s *manifestSuite) Test_newManifest_Files() {
+	dir := s.T().TempDir()
+	var files []*outputFile
+	for _, name := range []string{"b.log", "c.log", "a.log"} {
+		file, err := createOutput(filepath.Join(dir, name))
+		s.Require().NoError(err)
+		s.Require().NoError(file.close())
+		files = append(files, file)
+	}
+
+	m := newManifest(logging.LogsConfig{}, time.Now(), files)
+	var paths []string
+	for _, file := range m.Files {
+		paths = append(paths, filepath.Base(file.Path))
+	}
+	s.Equal([]string{"a.log", "b.log", "c.log"}, paths)
+}
+
+func (s *manifestSuite) Test_writeManifest() {
+	name := filepath.Join(s.T().TempDir(), "manifest.json")
+	now := time.Date(2022, time.March, 3, 10, 0, 0, 0, time.UTC)
+	m := newManifest(logging.LogsConfig{Directory: "logs", LastNMinutes: 5}, now, nil)
+	m.Files = append(m.Files, manifestFile{
+		Path: "extract.log", SHA256: "abc", Bytes: 6, Lines: 1,
+		Sources: []manifestSource{{File: "access.log", From: 0, To: 5, Lines: 1}},
+	})
+	s.Require().NoError(writeManifest(name, m))
+
+	content, err := os.ReadFile(name)
+	s.Require().NoError(err)
+	var written manifest
+	s.Require().NoError(json.Unmarshal(content, &written))
+	s.Equal(m, written)
+	// the IP and the path prefix are omitted when empty
+	s.NotContains(string(content), `"ip"`)
+	s.NotContains(string(content), `"path_prefix"`)
+}
+
+func TestManifest(t *testing.T) {
+	suite.Run(t, new(manifestSuite))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// handleHealthz answers the liveness probes of orchestration platforms (e.g. Kubernetes): the process is up
+// as long as it answers, so the probe never fails otherwise.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = printJSON(w, map[string]string{"status": "ok"})
+}
+
+// readinessHandler answers the readiness probes of orchestration platforms: 200 OK while a given check passes,
+// 503 Service Unavailable along with the error of the check otherwise.
+func readinessHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = printJSON(w, map[string]string{"status": "not ready", "error": err.Error()})
+			return
+		}
+		_ = printJSON(w, map[string]string{"status": "ready"})
+	}
+}
+
+// metric is a self-metric of a long-running command, written in the Prometheus text exposition format (see writeMetrics).
+type metric struct {
+	name, help string
+	// kind is the type of the metric: counter or gauge
+	kind    string
+	samples []metricSample
+}
+
+// metricSample is a value of a metric, along with its labels if any.
+type metricSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// writeMetrics writes given metrics in the Prometheus text exposition format, e.g.
+//
+//	# HELP log_reader_invalid_lines_total The log lines read not matching the log format.
+//	# TYPE log_reader_invalid_lines_total counter
+//	log_reader_invalid_lines_total 3
+func writeMetrics(w io.Writer, metrics []metric) error {
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+			return err
+		}
+		for _, sample := range m.samples {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(sample.labels), strconv.FormatFloat(sample.value, 'f', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatLabels formats the labels of a metric sample sorted by name, e.g. {source="shop"}, or nothing without labels.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.Quote(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// readerMetrics returns the self-metrics of the reader of the logs, see logging.Metrics.
+func readerMetrics(m logging.MetricsSnapshot) []metric {
+	return []metric{
+		{
+			name: "log_reader_files_scanned_total", kind: "counter", help: "The log files opened to be read or searched.",
+			samples: []metricSample{{value: float64(m.FilesScanned)}},
+		},
+		{
+			name: "log_reader_bytes_read_total", kind: "counter", help: "The bytes read from the log files.",
+			samples: []metricSample{{value: float64(m.BytesRead)}},
+		},
+		{
+			name: "log_reader_search_probes_total", kind: "counter", help: "The log lines read by the binary searches of the log files.",
+			samples: []metricSample{{value: float64(m.SearchProbes)}},
+		},
+		{
+			name: "log_reader_lines_skipped_total", kind: "counter", help: "The log lines read but left out, e.g. before the time range or filtered out.",
+			samples: []metricSample{{value: float64(m.LinesSkipped)}},
+		},
+		{
+			name: "log_reader_duplicate_lines_total", kind: "counter", help: "The log lines duplicated across files, suppressed or not (see -duplicates).",
+			samples: []metricSample{{value: float64(m.DuplicateLines)}},
+		},
+		{
+			name: "log_reader_files_vanished_total", kind: "counter", help: "The log files skipped as removed since the directory was scanned (see -skip-vanished).",
+			samples: []metricSample{{value: float64(m.FilesVanished)}},
+		},
+	}
+}
+
+// metricsHandler serves the metrics returned by a given function in the Prometheus text exposition format.
+func metricsHandler(metrics func() []metric) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = writeMetrics(w, metrics())
+	}
+}
+
+// redMetrics returns the RED metrics of the requests followed over rolling windows, labelled by window (e.g. 5m),
+// see logging.REDMetrics.
+func redMetrics(snapshots []logging.REDSnapshot) []metric {
+	rates := metric{name: "log_reader_requests_per_second", kind: "gauge", help: "The requests per second over the window."}
+	errorRatios := metric{name: "log_reader_error_ratio", kind: "gauge", help: "The share of the requests of the window answered with a server error (5xx)."}
+	latencies := metric{name: "log_reader_latency_seconds", kind: "gauge", help: "The latency percentiles of the requests of the window logging the time taken to serve them (%D)."}
+	for _, snapshot := range snapshots {
+		window := windowLabel(snapshot.Window)
+		rates.samples = append(rates.samples, metricSample{labels: map[string]string{"window": window}, value: snapshot.RequestRate})
+		errorRatios.samples = append(errorRatios.samples, metricSample{labels: map[string]string{"window": window}, value: snapshot.ErrorRate})
+		for _, q := range []struct {
+			quantile string
+			value    time.Duration
+		}{{"0.5", snapshot.Latency.P50}, {"0.9", snapshot.Latency.P90}, {"0.99", snapshot.Latency.P99}} {
+			latencies.samples = append(latencies.samples, metricSample{
+				labels: map[string]string{"window": window, "quantile": q.quantile},
+				value:  q.value.Seconds(),
+			})
+		}
+	}
+	return []metric{rates, errorRatios, latencies}
+}
+
+// windowLabel formats a window without its zero units, e.g. 5m rather than 5m0s.
+func windowLabel(window time.Duration) string {
+	label := window.String()
+	if strings.HasSuffix(label, "m0s") {
+		label = strings.TrimSuffix(label, "0s")
+	}
+	if strings.HasSuffix(label, "h0m") {
+		label = strings.TrimSuffix(label, "0m")
+	}
+	return label
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profileFlags defines the flags profiling a command on a given flag set, returning a function
+// starting the profiling once the flags are parsed, which returns the function stopping it
+// and writing the profiles, to be called once the command is done (see go tool pprof).
+func profileFlags(fs *flag.FlagSet) func() func() {
+	cpuProfileFlag := fs.String("cpuprofile", "", "write a CPU profile of the command to the given file")
+	memProfileFlag := fs.String("memprofile", "", "write a heap profile to the given file once the command is done")
+
+	return func() func() {
+		var cpuProfile *os.File
+		if *cpuProfileFlag != "" {
+			var err error
+			if cpuProfile, err = os.Create(*cpuProfileFlag); err != nil {
+				exit(exitIOFailure, "could not create CPU profile: %v", err)
+			}
+			if err := pprof.StartCPUProfile(cpuProfile); err != nil {
+				exit(exitIOFailure, "could not start CPU profile: %v", err)
+			}
+		}
+
+		return func() {
+			if cpuProfile != nil {
+				pprof.StopCPUProfile()
+				if err := cpuProfile.Close(); err != nil {
+					log.Printf("could not write CPU profile: %v", err)
+				}
+			}
+			if *memProfileFlag != "" {
+				writeHeapProfile(*memProfileFlag)
+			}
+		}
+	}
+}
+
+// writeHeapProfile writes a heap profile of the allocations made so far to a given file.
+func writeHeapProfile(name string) {
+	f, err := os.Create(name)
+	if err != nil {
+		log.Printf("could not create heap profile: %v", err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	// get up-to-date statistics
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		log.Printf("could not write heap profile: %v", err)
+	}
+}
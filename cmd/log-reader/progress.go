@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// progressRefresh is how often the progress line is rendered at most.
+const progressRefresh = 200 * time.Millisecond
+
+// progressLine renders the progress of an extract (see logging.LogsConfig.Progress) on a single line of a given writer,
+// e.g. 1.2 GiB / 4.5 GiB (27%) ETA 1m20s, rewritten in place and ended once done.
+func progressLine(w io.Writer) func(logging.Progress) {
+	var rendered time.Time
+	return func(p logging.Progress) {
+		done := p.Done >= p.Total
+		if !done && time.Since(rendered) < progressRefresh {
+			return
+		}
+		rendered = time.Now()
+
+		percent := 100.0
+		if p.Total > 0 {
+			percent = float64(p.Done) * 100 / float64(p.Total)
+		}
+		eta := "ETA unknown"
+		switch d := p.ETA(); {
+		case done:
+			eta = "in " + p.Elapsed.Round(time.Millisecond).String()
+		case d >= 0:
+			eta = "ETA " + d.Round(time.Second).String()
+		}
+		// the spaces at the end erase what's left of a longer previous line
+		fmt.Fprintf(w, "\r%s / %s (%.0f%%) %s    ", formatBytes(p.Done), formatBytes(p.Total), percent, eta)
+		if done {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// formatBytes formats a number of bytes using binary units, e.g. 1.2 GiB.
+func formatBytes(n int64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// runPrune runs the prune subcommand, compressing and deleting the rotated log files of the directory
+// by the age of their last log line (see logging.Logs.Prune), e.g. from a daily cron job.
+func runPrune(args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	var keepFlag, compressAfterFlag ageFlag
+	fs.Var(&keepFlag, "keep", "delete the rotated log files which last log line is older than the given age, in days (e.g. 30d) or as a duration (e.g. 36h)")
+	fs.Var(&compressAfterFlag, "compress-after", "compress (gzip) the rotated log files which last log line is older than the given age, in the same units as -keep")
+	activeFlag := fs.Duration("active", logging.DefaultPruneActive, "leave out the files modified within the given duration as still written to, e.g. the live logs of rotatelogs or of other sites")
+	activePatternFlag := fs.String("active-pattern", "", "leave out the files which names match the given pattern as still written to whatever their modified time, e.g. 'app-*.log.1'")
+	dryRunFlag := fs.Bool("dry-run", false, "only print the files which would be compressed and deleted")
+	outputFlag := fs.String("o", outputTable, "the output format: table, json")
+	parseFlags(fs, "prune", args)
+	if *outputFlag != outputTable && *outputFlag != outputJSON {
+		exit(exitUsage, "unsupported output format '%s'", *outputFlag)
+	}
+	if keepFlag == 0 && compressAfterFlag == 0 {
+		exit(exitUsage, "invalid configuration: -keep or -compress-after must be given")
+	}
+	if *activeFlag < 0 {
+		exit(exitUsage, "invalid configuration: -active must not be negative")
+	}
+	if _, err := filepath.Match(*activePatternFlag, ""); err != nil {
+		exit(exitUsage, "invalid active pattern: %v", err)
+	}
+	if keepFlag > 0 && compressAfterFlag >= keepFlag {
+		exit(exitUsage, "invalid configuration: -compress-after must be shorter than -keep")
+	}
+
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	logs, err := logging.NewLogs(cfg)
+	var configErr *logging.ConfigError
+	if errors.As(err, &configErr) {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	pruneCfg := logging.PruneConfig{
+		Keep:          time.Duration(keepFlag),
+		CompressAfter: time.Duration(compressAfterFlag),
+		DryRun:        *dryRunFlag,
+		Active:        *activeFlag,
+		ActivePattern: *activePatternFlag,
+	}
+	actions, pruneErr := logs.Prune(ctx, pruneCfg)
+	// the actions taken are printed even when the pruning stopped halfway
+	if *outputFlag == outputJSON {
+		err = printJSON(os.Stdout, actions)
+	} else {
+		err = printPruneActions(os.Stdout, actions, *dryRunFlag)
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not print actions: %v", err)
+	}
+	if ctx.Err() != nil {
+		exit(exitInterrupted, "interrupted")
+	}
+	if pruneErr != nil {
+		exit(exitIOFailure, "could not prune logs: %v", pruneErr)
+	}
+}
+
+// printPruneActions prints the actions taken on the rotated log files as the rows of an aligned table,
+// followed by the number of bytes freed by the deletions.
+func printPruneActions(w io.Writer, actions []logging.PruneAction, dryRun bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tFILE\tLAST\tAGE\tSIZE")
+	var freed int64
+	for _, action := range actions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", action.Action, action.File,
+			action.Last.UTC().Format(time.RFC3339), formatAge(action.Age), action.Size)
+		if action.Action == logging.PruneDelete {
+			freed += action.Size
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	verb := "deleted"
+	if dryRun {
+		verb = "would be deleted"
+	}
+	_, err := fmt.Fprintf(w, "%d files, %d bytes %s\n", len(actions), freed, verb)
+	return err
+}
+
+// formatAge formats an age in days and hours, e.g. 31d4h, or as a duration below a day.
+func formatAge(age time.Duration) string {
+	age = age.Truncate(time.Second)
+	if age < 24*time.Hour {
+		return age.String()
+	}
+	days := age / (24 * time.Hour)
+	return fmt.Sprintf("%dd%dh", days, (age-days*24*time.Hour)/time.Hour)
+}
+
+// ageFlag is a flag giving an age, either in days (e.g. 30d) or as a duration (e.g. 36h).
+type ageFlag time.Duration
+
+func (f *ageFlag) String() string {
+	return time.Duration(*f).String()
+}
+
+func (f *ageFlag) Set(value string) error {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(value, "d"), 64)
+		if err != nil || days < 0 {
+			return fmt.Errorf("invalid age '%s': must be a number of days, e.g. 30d, or a duration, e.g. 36h", value)
+		}
+		*f = ageFlag(days * float64(24*time.Hour))
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid age '%s': must be a number of days, e.g. 30d, or a duration, e.g. 36h", value)
+	}
+	*f = ageFlag(d)
+	return nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type reloadSuite struct {
+	suite.Suite
+	dir string
+	// logDir is a log directory the configuration files can read
+	logDir string
+}
+
+func (s *reloadSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.logDir = writeLogDir(s.T(), "/")
+}
+
+// writeFile writes a given file of the test directory.
+func (s *reloadSuite) writeFile(name, content string) string {
+	name = filepath.Join(s.dir, name)
+	s.Require().NoError(os.WriteFile(name, []byte(content), 0666))
+	return name
+}
+
+func (s *reloadSuite) Test_reloadFlags() {
+	tests := []struct {
+		name           string
+		config         string
+		args           []string
+		expectedFormat string
+		expectedErr    string
+	}{
+		{
+			name:           "No Configuration File",
+			args:           []string{"-f", "combined"},
+			expectedFormat: "combined",
+		},
+		{
+			name:           "Configuration File",
+			config:         "f: combined\n",
+			args:           []string{"-config", "config.yaml"},
+			expectedFormat: "combined",
+		},
+		{
+			name:           "Command Line Over Configuration File",
+			config:         "f: combined\n",
+			args:           []string{"-config", "config.yaml", "-f", "vhost_combined"},
+			expectedFormat: "vhost_combined",
+		},
+		{
+			name:           "Source Over Configuration File",
+			config:         "f: combined\nsources:\n  shop:\n    f: vhost_combined\n",
+			args:           []string{"-config", "config.yaml", "-source", "shop"},
+			expectedFormat: "vhost_combined",
+		},
+		{
+			name:        "Unknown Flag",
+			args:        []string{"-unknown"},
+			expectedErr: "flag provided but not defined: -unknown",
+		},
+		{
+			name:        "Source Without Configuration File",
+			args:        []string{"-source", "shop"},
+			expectedErr: "-source takes -config",
+		},
+		{
+			name:        "Unknown Source",
+			config:      "f: combined\n",
+			args:        []string{"-config", "config.yaml", "-source", "shop"},
+			expectedErr: "unknown source 'shop'",
+		},
+		{
+			name:        "Invalid Configuration File",
+			config:      "f: [combined\n",
+			args:        []string{"-config", "config.yaml"},
+			expectedErr: "invalid configuration file",
+		},
+		{
+			name:        "Unknown Key",
+			config:      "color: blue\n",
+			args:        []string{"-config", "config.yaml"},
+			expectedErr: "unknown flag or command 'color'",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			config := s.writeFile("config.yaml", test.config)
+			args := append([]string{"-d", s.logDir}, test.args...)
+			for i, arg := range args {
+				if arg == "config.yaml" {
+					args[i] = config
+				}
+			}
+			fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+			serveFlags(fs)
+
+			err := reloadFlags(fs, "serve", args)
+
+			if test.expectedErr != "" {
+				s.Require().Error(err)
+				s.Contains(err.Error(), test.expectedErr)
+				return
+			}
+			s.Require().NoError(err)
+			s.Equal(test.expectedFormat, fs.Lookup("f").Value.String())
+		})
+	}
+}
+
+func (s *reloadSuite) Test_server_reload() {
+	otherDir := writeLogDir(s.T(), "/other")
+	tokensFile := filepath.Join(s.dir, "tokens")
+	base := "d: " + s.logDir + "\n"
+	tests := []struct {
+		name              string
+		config            string
+		tokens            string
+		expectedDirectory string
+		expectedTokens    []string
+		expectedSources   []string
+		expectedMaxBytes  int64
+		expectedErr       string
+	}{
+		{
+			name:              "Directory",
+			config:            "d: " + otherDir + "\n",
+			expectedDirectory: otherDir,
+		},
+		{
+			name:              "Tokens And Maximum Of Bytes Read",
+			config:            base + "serve:\n  tokens-file: " + tokensFile + "\n  max-query-mb: 1\n",
+			tokens:            "ops secret\n",
+			expectedDirectory: s.logDir,
+			expectedTokens:    []string{"ops"},
+			expectedMaxBytes:  1 << 20,
+		},
+		{
+			name:              "Named Sources",
+			config:            base + "sources:\n  shop:\n    d: " + otherDir + "\n  blog: {}\n",
+			expectedDirectory: s.logDir,
+			expectedSources:   []string{"blog", "shop"},
+		},
+		{
+			name:        "Invalid Configuration File",
+			config:      "d: [" + otherDir + "\n",
+			expectedErr: "invalid configuration file",
+		},
+		{
+			name:        "Invalid Logs",
+			config:      "d: " + otherDir + "\nworkers: -1\n",
+			expectedErr: "workers",
+		},
+		{
+			name:        "Empty Tokens File",
+			config:      base + "serve:\n  tokens-file: " + tokensFile + "\n",
+			expectedErr: "no tokens",
+		},
+		{
+			name:        "Negative Maximum Of Bytes Read",
+			config:      base + "serve:\n  max-query-mb: -1\n",
+			expectedErr: "-max-query-mb must not be negative",
+		},
+		{
+			name:        "Invalid Source",
+			config:      base + "sources:\n  shop:\n    workers: -1\n",
+			expectedErr: "source shop",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			config := s.writeFile("config.yaml", test.config)
+			s.writeFile("tokens", test.tokens)
+			srv := newTestServer(s.logDir, nil, nil)
+			srv.cache = newQueryCache(time.Minute)
+			srv.cache.put("key", cachedResult{})
+
+			err := srv.reload([]string{"-config", config})
+
+			if test.expectedErr != "" {
+				s.Require().Error(err)
+				s.Contains(err.Error(), test.expectedErr)
+				// the current configuration is kept, along with the results of the cache
+				s.Equal(s.logDir, srv.baseConfig().Directory)
+				s.Nil(srv.authenticator())
+				s.Len(srv.cache.results, 1)
+				return
+			}
+			s.Require().NoError(err)
+			s.Equal(test.expectedDirectory, srv.baseConfig().Directory)
+			var tokens []string
+			if auth := srv.authenticator(); auth != nil {
+				for _, token := range auth.tokens {
+					tokens = append(tokens, token.name)
+				}
+			}
+			s.Equal(test.expectedTokens, tokens)
+			var sources []string
+			for name := range srv.sources() {
+				sources = append(sources, name)
+			}
+			sort.Strings(sources)
+			s.Equal(test.expectedSources, sources)
+			s.Equal(test.expectedMaxBytes, srv.maxQueryBytes)
+			// the results of the previous configuration are forgotten
+			s.Empty(srv.cache.results)
+		})
+	}
+}
+
+// daemonSources returns the sources of the daemons of a given set along with their schedules.
+func daemonSources(set *daemonSet) map[string]string {
+	schedules := make(map[string]string)
+	for _, d := range set.list() {
+		schedules[d.healthOf().Source] = d.healthOf().Schedule
+	}
+	return schedules
+}
+
+func (s *reloadSuite) Test_daemonSet_reload() {
+	config := s.writeFile("config.yaml", "daemon:\n  schedule: '@every 1h'\nsources:\n  shop: {}\n  blog: {}\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	set := &daemonSet{args: []string{"-config", config, "stats"}}
+	s.Require().NoError(set.reload(ctx))
+	s.Equal(map[string]string{"shop": "@every 1h", "blog": "@every 1h"}, daemonSources(set))
+	daemons := make(map[string]*daemon)
+	for _, d := range set.list() {
+		daemons[d.health.Source] = d
+	}
+
+	tests := []struct {
+		name              string
+		config            string
+		expectedSchedules map[string]string
+		expectedErr       string
+	}{
+		{
+			name:              "Schedule Of A Source",
+			config:            "daemon:\n  schedule: '@every 1h'\nsources:\n  shop:\n    daemon:\n      schedule: '@every 2h'\n  blog: {}\n",
+			expectedSchedules: map[string]string{"shop": "@every 2h", "blog": "@every 1h"},
+		},
+		{
+			name:              "Source Removed And Added",
+			config:            "daemon:\n  schedule: '@every 1h'\nsources:\n  shop: {}\n  wiki: {}\n",
+			expectedSchedules: map[string]string{"shop": "@every 1h", "wiki": "@every 1h"},
+		},
+		{
+			name:        "Invalid Schedule",
+			config:      "daemon:\n  schedule: 'every hour'\nsources:\n  shop: {}\n",
+			expectedErr: "invalid schedule 'every hour'",
+		},
+		{
+			name:        "Invalid Configuration File",
+			config:      "daemon: [\n",
+			expectedErr: "invalid configuration file",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			current := daemonSources(set)
+			s.writeFile("config.yaml", test.config)
+
+			err := set.reload(ctx)
+
+			if test.expectedErr != "" {
+				s.Require().Error(err)
+				s.Contains(err.Error(), test.expectedErr)
+				// the current daemons are kept
+				s.Equal(current, daemonSources(set))
+				return
+			}
+			s.Require().NoError(err)
+			s.Equal(test.expectedSchedules, daemonSources(set))
+			// the daemons of the sources still configured keep running, the ones of the sources removed are stopped
+			for _, d := range set.list() {
+				if old, ok := daemons[d.health.Source]; ok {
+					s.Same(old, d)
+				}
+				daemons[d.health.Source] = d
+			}
+			for source, d := range daemons {
+				if _, ok := test.expectedSchedules[source]; !ok {
+					s.Require().NotNil(d.stopped)
+					_, open := <-d.stopped
+					s.False(open)
+				}
+			}
+		})
+	}
+
+	cancel()
+	set.wait()
+}
+
+func TestReload(t *testing.T) {
+	suite.Run(t, new(reloadSuite))
+}
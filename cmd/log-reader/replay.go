@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+	"github.com/chill-and-code/apache-log-reader/replay"
+)
+
+// runReplay runs the replay subcommand, replaying the GET requests of the last -t minutes against -target,
+// preserving the times between them divided by -speed, until done or interrupted.
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	targetFlag := fs.String("target", "", "the URL of the server to replay the GET requests against, e.g. http://staging:8080")
+	speedFlag := fs.String("speed", "1x", "how many times faster than logged the requests are replayed, e.g. 2x, or max to send them as fast as -concurrency allows")
+	concurrencyFlag := fs.Int("concurrency", replay.DefaultConcurrency, "the maximum number of requests in flight")
+	var headersFlag stringsFlag
+	fs.Var(&headersFlag, "header", "a header sent along with every request, repeatable, e.g. 'X-Replayed-By: log-reader'")
+	parseFlags(fs, "replay", args)
+
+	speed, err := parseSpeed(*speedFlag)
+	if err != nil {
+		exit(exitUsage, "invalid speed: %v", err)
+	}
+	opts := []replay.Option{replay.WithSpeed(speed), replay.WithConcurrency(*concurrencyFlag)}
+	for _, header := range headersFlag {
+		i := strings.Index(header, ":")
+		if i <= 0 {
+			exit(exitUsage, "invalid header '%s', expected 'Key: value'", header)
+		}
+		opts = append(opts, replay.WithHeader(strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:])))
+	}
+	replayer, err := replay.New(*targetFlag, opts...)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	logs, err := logging.NewLogs(cfg)
+	if err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := replayer.Replay(ctx, logs.ForEach)
+	printReplay(os.Stdout, result)
+	if err != nil && ctx.Err() == nil {
+		exit(exitIOFailure, "could not replay logs: %v", err)
+	}
+}
+
+// parseSpeed parses a speed such as 2x (or 2), 0.5x, or max for as fast as possible (0).
+func parseSpeed(s string) (float64, error) {
+	if s == "max" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if !(speed > 0) {
+		return 0, errors.New("must be positive, or max")
+	}
+	return speed, nil
+}
+
+// printReplay prints the result of a replay, the responses being counted by status.
+func printReplay(w io.Writer, result replay.Result) {
+	fmt.Fprintf(w, "sent %d requests in %s (%d failed, %d log entries skipped, lagging %s at most)\n",
+		result.Sent, result.Duration.Round(time.Millisecond), result.Failed, result.Skipped, result.MaxLag.Round(time.Millisecond))
+	statuses := make([]int, 0, len(result.Statuses))
+	for status := range result.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "  %d: %d\n", status, result.Statuses[status])
+	}
+}
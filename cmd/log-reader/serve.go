@@ -0,0 +1,686 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// shutdownTimeout is how long the requests in flight are given to complete once the server is stopped
+const shutdownTimeout = 10 * time.Second
+
+// runServe runs the serve subcommand, exposing the logs of the directory over an HTTP API:
+//
+//	GET /logs?minutes=5&status=500&path=/api streams the matching log entries as NDJSON, by pages given a limit (see handleLogsPage)
+//	GET /stats?minutes=60 returns the summary of the requests as JSON
+//	GET /tail?status=5xx streams the matching log entries written from now on as Server-Sent Events
+//	GET /stats/stream?minutes=5&interval=10s pushes a snapshot of the requests of the window every interval as Server-Sent Events
+//	GET /timeseries?minutes=60&interval=1m returns the number of requests and errors per interval as JSON
+//	GET /top?fields=ips,paths&top=10 returns the most frequent values of the fields as JSON
+//	GET / serves the web dashboard of the logs, see dashboardPage
+//	/grafana/ implements the Grafana JSON datasource (search, query and annotations), see grafanaHandler
+//	GET /healthz and GET /readyz answer the liveness and readiness probes, the server being ready while its directory can be read
+//	GET /metrics returns the self-metrics of the server in the Prometheus text format with -self-metrics, see server.metrics
+//
+// The logs are looked for within the last -t minutes unless the minutes are given,
+// and can be filtered by status (e.g. 500 or 5xx), path prefix, method and ip.
+// With -token or -tokens-file, the requests but the probes and the dashboard page take a bearer token, which can be
+// scoped to a directory of its own (see parseTokens). The requests are logged to -audit-log, and served over TLS
+// with -tls-cert and -tls-key, the clients presenting a certificate signed by -tls-client-ca when given (mTLS).
+// The clients are limited to -rate-limit requests (see clientLimiter), answered 429 Too Many Requests past it,
+// and every query to -max-query-mb read from the log files, answered 422 Unprocessable Entity past it (see readFailed).
+// The named sources of the configuration file (see parseFlags) are served under /sources/{name}/ with their own
+// directory, format and filters, for the tokens of their access control lists (see handleSources and -allow-tokens).
+// With -cache-ttl, the results of /stats, /timeseries and /top are cached until the log files change (see queryCache).
+// The configuration is reloaded on SIGHUP and whenever the configuration file changes (see server.reload).
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	logsConfig, opts := serveFlags(fs)
+	configFile := parseFlags(fs, "serve", args)
+
+	// classify the bots to split the traffic between bots and humans
+	cfg, err := logsConfig(true)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	if _, err := logging.NewLogs(cfg); err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+	auth, err := newAuthenticator(opts.token, opts.tokensFile, cfg.Directory)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	var tlsConfig *tls.Config
+	switch {
+	case (opts.tlsCert == "") != (opts.tlsKey == ""):
+		exit(exitUsage, "invalid configuration: -tls-cert and -tls-key go together")
+	case opts.tlsClientCA != "" && opts.tlsCert == "":
+		exit(exitUsage, "invalid configuration: -tls-client-ca takes -tls-cert and -tls-key")
+	case opts.tlsCert != "":
+		if tlsConfig, err = serverTLS(opts.tlsCert, opts.tlsKey, opts.tlsClientCA); err != nil {
+			exit(exitUsage, "invalid configuration: %v", err)
+		}
+	}
+	var limiter *clientLimiter
+	if opts.rateLimit != "" {
+		rate, err := logging.ParseRate(opts.rateLimit)
+		if err != nil {
+			exit(exitUsage, "invalid configuration: %v", err)
+		}
+		limiter = newClientLimiter(rate)
+	}
+	if opts.maxQueryMB < 0 {
+		exit(exitUsage, "invalid configuration: -max-query-mb must not be negative")
+	}
+	var cache *queryCache
+	if opts.cacheTTL > 0 {
+		cache = newQueryCache(opts.cacheTTL)
+	}
+	var audit *auditLog
+	if opts.auditLog != "" {
+		if audit, err = openAuditLog(opts.auditLog, opts.auditLogMaxMB, opts.auditLogKeep); err != nil {
+			exit(exitIOFailure, "could not open the audit log: %v", err)
+		}
+	}
+
+	named, err := loadSources(args, configFile, fs.Lookup("source").Value.String())
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+
+	srv := &server{cfg: cfg, auth: auth, limiter: limiter, cache: cache, maxQueryBytes: opts.maxQueryBytes(), named: named}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	httpServer := &http.Server{
+		Addr:              opts.addr,
+		Handler:           srv.handler(audit, opts.selfMetrics),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		// the requests are canceled once stopped, so the endless ones (e.g. /tail) don't hold the shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	onReload(ctx, configFile, func() {
+		if err := srv.reload(args); err != nil {
+			log.Printf("could not reload the configuration, keeping the current one: %v", err)
+			return
+		}
+		log.Printf("configuration reloaded, serving the logs of %s", logsSource(srv.baseConfig()))
+	})
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("serving the logs of %s on %s", logsSource(cfg), opts.addr)
+	for _, name := range sortedSources(named) {
+		log.Printf("serving the logs of source %s (%s) under %s%s/", name, logsSource(named[name].cfg), sourcesPath, name)
+	}
+	if tlsConfig != nil {
+		// the certificate is the one of the TLS configuration
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		exit(exitIOFailure, "could not serve: %v", err)
+	}
+}
+
+// handler returns the handler of the server: the API of the logs (see routes) along with the named sources,
+// the Grafana datasource, the probes and the self-metrics when given, protected by the tokens of the server
+// and logged to a given audit log, if any (see protect).
+func (srv *server) handler(audit *auditLog, selfMetrics bool) http.Handler {
+	mux := srv.routes()
+	mux.Handle("/sources", srv.handleSources(srv.routes()))
+	mux.Handle(sourcesPath, srv.handleSources(srv.routes()))
+	mux.Handle("/grafana/", http.StripPrefix("/grafana", srv.grafanaHandler()))
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.Handle("/readyz", readinessHandler(srv.ready))
+	if selfMetrics {
+		mux.Handle("/metrics", metricsHandler(srv.metrics))
+	}
+	return srv.protect(mux, audit)
+}
+
+// routes returns the routes of the API of the logs, served for the directory on / and for every named source
+// under /sources/{name}/ (see handleSources).
+func (srv *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/logs", srv.handleLogs)
+	mux.HandleFunc("/stats", srv.cached(srv.handleStats))
+	mux.HandleFunc("/tail", srv.handleTail)
+	mux.HandleFunc("/stats/stream", srv.handleStatsStream)
+	mux.HandleFunc("/timeseries", srv.cached(srv.handleTimeSeries))
+	mux.HandleFunc("/top", srv.cached(srv.handleTop))
+	mux.HandleFunc("/", srv.handleDashboard)
+	return mux
+}
+
+// serveOptions are the flags of the serve subcommand besides the ones selecting the logs, see serveFlags.
+type serveOptions struct {
+	addr        string
+	selfMetrics bool
+	// token and tokensFile give the tokens of the API, see newAuthenticator
+	token, tokensFile string
+	// tlsCert, tlsKey and tlsClientCA are the PEM files of the TLS configuration, see serverTLS
+	tlsCert, tlsKey, tlsClientCA string
+	auditLog                     string
+	// auditLogMaxMB and auditLogKeep rotate the audit log, see openAuditLog
+	auditLogMaxMB float64
+	auditLogKeep  int
+	// rateLimit is the rate of the requests of every client, see clientLimiter
+	rateLimit string
+	// maxQueryMB is the maximum of megabytes (MiB) read by a query, see maxQueryBytes
+	maxQueryMB float64
+	// cacheTTL is how long the results of the queries are cached, see queryCache
+	cacheTTL time.Duration
+	// allowTokens are the comma separated names of the tokens allowed to read a named source, see logSource
+	allowTokens string
+}
+
+// maxQueryBytes returns the maximum of bytes read by a query, 0 for no limit (see logging.LogsConfig.MaxBytesRead).
+func (opts *serveOptions) maxQueryBytes() int64 {
+	return int64(opts.maxQueryMB * (1 << 20))
+}
+
+// serveFlags defines the flags of the serve subcommand on a given flag set, returning the function building
+// the logs configuration (see logsFlags) along with the options of the server once parsed.
+func serveFlags(fs *flag.FlagSet) (func(classifyBots bool) (logging.LogsConfig, error), *serveOptions) {
+	logsConfig := logsFlags(fs)
+	opts := &serveOptions{}
+	fs.StringVar(&opts.addr, "addr", ":8080", "the address to listen on")
+	fs.BoolVar(&opts.selfMetrics, "self-metrics", false, "serve the metrics of the server itself on GET /metrics (Prometheus text format): invalid log lines read, tails and their lag, files scanned, bytes read, search probes and lines skipped")
+	fs.StringVar(&opts.token, "token", "", "a static token the requests must give (Authorization: Bearer <token>, or the access_token parameter), but the probes and the dashboard page; it's visible to the other users of the host, so prefer -tokens-file")
+	fs.StringVar(&opts.tokensFile, "tokens-file", "", "the file of the tokens the requests must give, reloaded along with the configuration: a line per token made of its name (for the audit log), the token and optionally the directory it's scoped to, relative to -d unless absolute")
+	fs.StringVar(&opts.tlsCert, "tls-cert", "", "the PEM file of the certificate to serve over TLS, along with -tls-key")
+	fs.StringVar(&opts.tlsKey, "tls-key", "", "the PEM file of the private key of -tls-cert")
+	fs.StringVar(&opts.tlsClientCA, "tls-client-ca", "", "the PEM file of the certificate authorities the clients must present a certificate of (mTLS)")
+	fs.StringVar(&opts.auditLog, "audit-log", "", "the file to append a JSON line per request to (- for stderr): time, remote address, client (token name or certificate), method, path, query, status, duration, log files read and bytes returned")
+	fs.Float64Var(&opts.auditLogMaxMB, "audit-log-max-mb", 100, "rotate the audit log once it reaches the given megabytes (MiB), renaming it to <file>.1, 0 to never rotate it (e.g. rotated by logrotate with copytruncate)")
+	fs.IntVar(&opts.auditLogKeep, "audit-log-keep", 10, "the number of rotations of the audit log kept, <file>.1 being the latest")
+	fs.StringVar(&opts.rateLimit, "rate-limit", "", "the maximum rate of the requests of every client (its token, or its IP without tokens) but the probes, e.g. 60/1m, answering 429 Too Many Requests past it; read on start only")
+	fs.Float64Var(&opts.maxQueryMB, "max-query-mb", 0, "maximum megabytes (MiB) read from the log files by a query, answering 422 Unprocessable Entity past it so a too wide window or a too loose filter doesn't hog the server, 0 for no limit; /tail is not limited")
+	fs.DurationVar(&opts.cacheTTL, "cache-ttl", 0, "cache the results of GET /stats, /timeseries and /top for the given time, e.g. 5s, unless the log files change meanwhile, so the dashboards repeating the same query don't have the files read over and over; 0 for no cache, read on start only")
+	fs.StringVar(&opts.allowTokens, "allow-tokens", "", "the comma separated names of the tokens (see -tokens-file) allowed to read the named sources of the configuration file, served under /sources/{name}/, given in the serve section of a source for its own access control list; by default, the tokens not scoped to a directory")
+	return logsConfig, opts
+}
+
+// server serves the logs of a directory, read using a base configuration
+// completed by the query of every request (see server.logs).
+type server struct {
+	// invalidLines counts the log lines read not matching the log format, tails the tails in flight (see handleTail)
+	// and tailLag is the delay of the last log entry tailed in nanoseconds, see server.metrics
+	invalidLines, tails, tailLag int64
+	// reader counts the work of the reader of the logs of all the requests
+	reader logging.Metrics
+
+	// limiter limits the rate of the requests of every client, if any
+	limiter *clientLimiter
+	// cache caches the results of the queries, if any
+	cache *queryCache
+
+	mu   sync.RWMutex
+	cfg  logging.LogsConfig
+	auth *authenticator
+	// maxQueryBytes is the maximum of bytes read by a query, see serveOptions.maxQueryBytes
+	maxQueryBytes int64
+	// named are the named sources of the configuration file, see loadSources
+	named map[string]*logSource
+}
+
+// reload reads the base configuration again from the given arguments and the configuration file they give,
+// keeping the current one when invalid. The requests in flight (e.g. /tail) keep reading the logs they started with,
+// while the next ones use the new configuration, along with the tokens, the named sources and the maximum of bytes
+// read by a query.
+// The address to listen on, the TLS configuration, the rate limit, the cache and the audit log are only read on start.
+func (srv *server) reload(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	logsConfig, opts := serveFlags(fs)
+	if err := reloadFlags(fs, "serve", args); err != nil {
+		return err
+	}
+	cfg, err := logsConfig(true)
+	if err != nil {
+		return err
+	}
+	if _, err := logging.NewLogs(cfg); err != nil {
+		return err
+	}
+	auth, err := newAuthenticator(opts.token, opts.tokensFile, cfg.Directory)
+	if err != nil {
+		return err
+	}
+	if opts.maxQueryMB < 0 {
+		return errors.New("-max-query-mb must not be negative")
+	}
+	named, err := loadSources(args, fs.Lookup("config").Value.String(), fs.Lookup("source").Value.String())
+	if err != nil {
+		return err
+	}
+	srv.mu.Lock()
+	srv.cfg, srv.auth, srv.maxQueryBytes, srv.named = cfg, auth, opts.maxQueryBytes(), named
+	srv.mu.Unlock()
+	// the results of the previous configuration may not be the ones of the new one
+	srv.cache.clear()
+	return nil
+}
+
+// handleLogs streams the log entries matching the query as NDJSON, one JSON object per line.
+func (srv *server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	logs, err := srv.logs(r.Context(), r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("limit") != "" || r.URL.Query().Get("cursor") != "" {
+		srv.handleLogsPage(w, r, logs)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	written := false
+	err = logs.ForEach(r.Context(), func(entry logging.LogEntry) error {
+		written = true
+		return enc.Encode(entry)
+	})
+	if !written && errors.Is(err, logging.ErrBudgetExceeded) {
+		readFailed(w, err)
+		return
+	}
+	// the response has most likely been sent in part already, so errors can only be logged
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) && r.Context().Err() == nil {
+		log.Printf("could not stream logs: %v", err)
+	}
+}
+
+// maxPageSize is the maximum number of log entries of a page of /logs, see handleLogsPage.
+const maxPageSize = 10000
+
+// defaultPageSize is the number of log entries of a page of /logs when not given.
+const defaultPageSize = 1000
+
+// handleLogsPage answers a page of the log entries of the query as NDJSON, of at most limit entries (1000 by default,
+// 10000 at most) starting after the given cursor, if any. The cursor of the next page is given by the X-Next-Cursor
+// header along with a Link header (rel="next") of its URL, unless it is the last page (see logging.Logs.Page).
+func (srv *server) handleLogsPage(w http.ResponseWriter, r *http.Request, logs *logging.Logs) {
+	query := r.URL.Query()
+	limit, err := queryInt(query, "limit", defaultPageSize)
+	if err == nil && limit > maxPageSize {
+		err = fmt.Errorf("invalid limit '%d': must be at most %d", limit, maxPageSize)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var after *logging.Cursor
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := logging.ParseCursor(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		after = &cursor
+	}
+
+	// the page is held until read, so the headers give the cursor of the next one
+	var page bytes.Buffer
+	enc := json.NewEncoder(&page)
+	next, err := logs.Page(r.Context(), after, limit, func(entry logging.LogEntry) error {
+		return enc.Encode(entry)
+	})
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		readFailed(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if next != nil {
+		query.Set("cursor", next.String())
+		query.Del(accessTokenParam)
+		w.Header().Set("X-Next-Cursor", next.String())
+		path := r.URL.Path
+		if src, ok := sourceFrom(r.Context()); ok {
+			// the path is the one within the source, see handleSources
+			path = sourcesPath + src.name + path
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, path, query.Encode()))
+	}
+	_, _ = page.WriteTo(w)
+}
+
+// handleStats returns the summary of the requests matching the query as JSON,
+// along with the given number of most frequent status codes (top, 5 by default).
+func (srv *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	logs, err := srv.logs(r.Context(), r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	top, err := queryInt(r.URL.Query(), "top", 5)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := logs.Stats(r.Context())
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		readFailed(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = printJSON(w, stats.Report(top))
+}
+
+// handleTail streams the log entries matching the query as they are written, as Server-Sent Events
+// which data is the JSON log entry, until the client disconnects or the server is stopped.
+func (srv *server) handleTail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	logs, err := srv.logs(r.Context(), r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	atomic.AddInt64(&srv.tails, 1)
+	defer atomic.AddInt64(&srv.tails, -1)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	err = logs.Follow(r.Context(), func(entry logging.LogEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		atomic.StoreInt64(&srv.tailLag, int64(time.Since(entry.Time)))
+		return nil
+	})
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("could not tail logs: %v", err)
+	}
+}
+
+// The intervals between the snapshots pushed by /stats/stream, see handleStatsStream.
+const (
+	defaultStatsStreamInterval = 10 * time.Second
+	// minStatsStreamInterval keeps the clients from having the logs read over and over
+	minStatsStreamInterval = time.Second
+)
+
+// statsSnapshot is a snapshot of the requests within the last N minutes, pushed by /stats/stream.
+type statsSnapshot struct {
+	Time              time.Time       `json:"time"`
+	Requests          int             `json:"requests"`
+	RequestsPerMinute float64         `json:"requests_per_minute"`
+	Errors            int             `json:"errors"`
+	ErrorRate         float64         `json:"error_rate"`
+	TopIPs            []logging.Count `json:"top_ips"`
+}
+
+// handleStatsStream pushes a snapshot of the requests matching the query (see statsSnapshot) right away, then every
+// interval (10s by default, at least 1s) as Server-Sent Events which data is the JSON snapshot, ranking the given
+// number of most frequent IPs (top, 10 by default), until the client disconnects or the server is stopped.
+// The logs of the window are read again for every snapshot, so dashboards refresh without polling full queries.
+func (srv *server) handleStatsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query()
+	interval := defaultStatsStreamInterval
+	if value := query.Get("interval"); value != "" {
+		var err error
+		if interval, err = time.ParseDuration(value); err != nil || interval < minStatsStreamInterval {
+			http.Error(w, "invalid interval '"+value+"': must be at least "+minStatsStreamInterval.String(), http.StatusBadRequest)
+			return
+		}
+	}
+	top, err := queryInt(query, "top", defaultTopN)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg, err := srv.config(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := logging.NewLogs(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	window := cfg.Window
+	if window == 0 {
+		window = time.Duration(cfg.LastNMinutes) * time.Minute
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		snapshot, err := newStatsSnapshot(r.Context(), cfg, window, top)
+		if errors.Is(err, logging.ErrBudgetExceeded) {
+			// the status is already sent, so the client is told by an event of its own
+			_, _ = fmt.Fprintf(w, "event: error\ndata: query too expensive, narrow its window or its filters: %s\n\n", err)
+			flusher.Flush()
+			return
+		}
+		if err != nil {
+			if r.Context().Err() == nil {
+				log.Printf("could not read logs: %v", err)
+			}
+			return
+		}
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// newStatsSnapshot reads the log entries of given logs within a given window once, summarizing them
+// along with the given number of most frequent IPs.
+func newStatsSnapshot(ctx context.Context, cfg logging.LogsConfig, window time.Duration, top int) (statsSnapshot, error) {
+	now := time.Now()
+	// the logs are created for every snapshot, so the maximum of bytes read is the one of a query (see -max-query-mb)
+	logs, err := logging.NewLogs(cfg)
+	if err != nil {
+		return statsSnapshot{}, err
+	}
+	stats := logging.NewStats(window)
+	ips := logging.NewTop(logging.TopIPs)
+	err = logs.ForEach(ctx, func(entry logging.LogEntry) error {
+		stats.Add(entry)
+		ips.Add(entry)
+		return nil
+	})
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		return statsSnapshot{}, err
+	}
+	return statsSnapshot{
+		Time:              now,
+		Requests:          stats.Requests,
+		RequestsPerMinute: stats.RequestsPerSecond() * 60,
+		Errors:            stats.Errors,
+		ErrorRate:         stats.ErrorRate(),
+		// an empty array rather than null when there are no requests
+		TopIPs: append([]logging.Count{}, ips.Ranked(logging.TopIPs, top)...),
+	}, nil
+}
+
+// logs creates the logs of the directory for the query of a request, see server.config.
+func (srv *server) logs(ctx context.Context, query url.Values) (*logging.Logs, error) {
+	cfg, err := srv.config(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return logging.NewLogs(cfg)
+}
+
+// config returns the configuration of the logs of the directory for the query of a request, within its minutes,
+// keeping the log entries matching its status, path (prefix), method and ip only. The logs of the named source
+// of the request (see sourceFrom), or else of the directory its token is scoped to (see tokenFrom), if any,
+// are read instead, given the context of the request.
+func (srv *server) config(ctx context.Context, query url.Values) (logging.LogsConfig, error) {
+	cfg := srv.baseConfig()
+	if src, ok := sourceFrom(ctx); ok {
+		cfg = src.cfg
+	} else if token, ok := tokenFrom(ctx); ok && token.directory != "" {
+		cfg.Directory, cfg.Hosts = token.directory, nil
+	}
+	srv.mu.RLock()
+	cfg.MaxBytesRead = srv.maxQueryBytes
+	srv.mu.RUnlock()
+	if files, ok := auditFilesFrom(ctx); ok {
+		cfg.Logger = files.logger(cfg.Logger)
+	}
+	// the invalid lines are counted before the filters of the query leave them out
+	cfg.Filters = append([]logging.Filter{invalidCounter(&srv.invalidLines)}, cfg.Filters...)
+	cfg.Metrics = &srv.reader
+
+	minutes, err := queryInt(query, "minutes", cfg.LastNMinutes)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.LastNMinutes = minutes
+
+	if status := query.Get("status"); status != "" {
+		filter, err := logging.StatusFilter(status)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Filters = append(cfg.Filters, filter)
+	}
+	if path := query.Get("path"); path != "" {
+		cfg.Filters = append(cfg.Filters, func(entry logging.LogEntry) bool {
+			return strings.HasPrefix(entry.Path, path)
+		})
+	}
+	if method := query.Get("method"); method != "" {
+		cfg.Filters = append(cfg.Filters, func(entry logging.LogEntry) bool {
+			return strings.EqualFold(entry.Method, method)
+		})
+	}
+	if ip := query.Get("ip"); ip != "" {
+		cfg.Filters = append(cfg.Filters, func(entry logging.LogEntry) bool {
+			return entry.IP == ip
+		})
+	}
+
+	return cfg, nil
+}
+
+// baseConfig returns the base configuration of the logs, see server.reload.
+func (srv *server) baseConfig() logging.LogsConfig {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	return srv.cfg
+}
+
+// ready checks whether the logs of the directory can be read, answering the readiness probes (see readinessHandler).
+func (srv *server) ready() error {
+	_, err := logging.NewLogs(srv.baseConfig())
+	return err
+}
+
+// metrics returns the self-metrics of the server: the invalid log lines read by the requests,
+// the tails in flight and the delay between the time of the last log entry tailed and when it was streamed,
+// the requests served from the cache or not (see queryCache) along with the work of the reader (see readerMetrics).
+func (srv *server) metrics() []metric {
+	var hits, misses int64
+	if srv.cache != nil {
+		hits, misses = atomic.LoadInt64(&srv.cache.hits), atomic.LoadInt64(&srv.cache.misses)
+	}
+	return append([]metric{
+		{
+			name: "log_reader_invalid_lines_total", kind: "counter", help: "The log lines read not matching the log format.",
+			samples: []metricSample{{value: float64(atomic.LoadInt64(&srv.invalidLines))}},
+		},
+		{
+			name: "log_reader_tails", kind: "gauge", help: "The tails in flight.",
+			samples: []metricSample{{value: float64(atomic.LoadInt64(&srv.tails))}},
+		},
+		{
+			name: "log_reader_tail_lag_seconds", kind: "gauge", help: "The delay between the time of the last log entry tailed and when it was streamed.",
+			samples: []metricSample{{value: time.Duration(atomic.LoadInt64(&srv.tailLag)).Seconds()}},
+		},
+		{
+			name: "log_reader_cache_requests_total", kind: "counter", help: "The requests of the cached queries, served from the cache (hit) or not (miss).",
+			samples: []metricSample{
+				{labels: map[string]string{"result": "hit"}, value: float64(hits)},
+				{labels: map[string]string{"result": "miss"}, value: float64(misses)},
+			},
+		},
+	}, readerMetrics(srv.reader.Snapshot())...)
+}
+
+// queryInt returns the positive integer of a given query parameter, or a default value when not given.
+func queryInt(query url.Values, name string, defaultValue int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s '%s'", name, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// sourcesPath is the path of the API of the named sources of the configuration file, see server.handleSources.
+const sourcesPath = "/sources/"
+
+// logSource is a named source of the configuration file (see parseFlags) served under /sources/{name}/,
+// reading its own logs (directory, format, filters, ...) for the tokens of its access control list.
+type logSource struct {
+	name string
+	cfg  logging.LogsConfig
+	// allowed are the names of the tokens allowed to read the source (see -allow-tokens), if any
+	allowed map[string]bool
+}
+
+// loadSources returns the named sources of a given configuration file, each one configured by the given arguments
+// completed by its own values of the flags (see parseFlags), or none when serving a single source (see -source).
+func loadSources(args []string, configFile, source string) (map[string]*logSource, error) {
+	if configFile == "" || source != "" {
+		return nil, nil
+	}
+	names, err := configSources(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration file: %w", err)
+	}
+	sources := make(map[string]*logSource, len(names))
+	for _, name := range names {
+		fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+		logsConfig, opts := serveFlags(fs)
+		if err := reloadFlags(fs, "serve", append([]string{"-source", name}, args...)); err != nil {
+			return nil, fmt.Errorf("source %s: %w", name, err)
+		}
+		cfg, err := logsConfig(true)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", name, err)
+		}
+		if _, err := logging.NewLogs(cfg); err != nil {
+			return nil, fmt.Errorf("source %s: %w", name, err)
+		}
+		src := &logSource{name: name, cfg: cfg}
+		if opts.allowTokens != "" {
+			src.allowed = make(map[string]bool)
+			for _, token := range strings.Split(opts.allowTokens, ",") {
+				src.allowed[strings.TrimSpace(token)] = true
+			}
+		}
+		sources[name] = src
+	}
+	return sources, nil
+}
+
+// allows checks whether the source can be read with a given token: the ones of its access control list when given,
+// otherwise the ones which are not scoped to a directory of their own (see parseTokens).
+func (src *logSource) allows(token apiToken) bool {
+	if src.allowed != nil {
+		return src.allowed[token.name]
+	}
+	return token.directory == ""
+}
+
+// namedSourceKey is the key of the source of a request inside its context, see sourceFrom.
+type namedSourceKey struct{}
+
+// sourceFrom returns the named source a request reads from its context, if any.
+func sourceFrom(ctx context.Context) (*logSource, bool) {
+	src, ok := ctx.Value(namedSourceKey{}).(*logSource)
+	return src, ok
+}
+
+// sourceDashboard checks whether a given path is the one of the dashboard page of a source, e.g. /sources/shop/,
+// which is answered without a token the way / is (see unauthenticatedPaths).
+func sourceDashboard(path string) bool {
+	if !strings.HasPrefix(path, sourcesPath) {
+		return false
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(path, sourcesPath), "/")
+	return name != "" && !strings.Contains(name, "/")
+}
+
+// handleSources serves the API of the named sources: GET /sources returns the names of the sources the token
+// of the request can read as JSON, while /sources/{name}/... answers the requests of the API of the logs
+// (/logs, /stats, /tail, /stats/stream, /timeseries, /top and the dashboard) with the logs of the source.
+// The sources the token can't read are answered 403 Forbidden, see logSource.allows.
+func (srv *server) handleSources(routes http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/sources"), "/")
+		token, authenticated := tokenFrom(r.Context())
+		if rest == "" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			sources := srv.sources()
+			names := []string{}
+			for _, name := range sortedSources(sources) {
+				if !authenticated || sources[name].allows(token) {
+					names = append(names, name)
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = printJSON(w, names)
+			return
+		}
+		name, path := rest, "/"
+		if i := strings.Index(rest, "/"); i >= 0 {
+			name, path = rest[:i], rest[i:]
+		}
+		src, ok := srv.sources()[name]
+		if !ok {
+			http.Error(w, "unknown source '"+name+"'", http.StatusNotFound)
+			return
+		}
+		// the dashboard page is answered without a token, its data being requested with one
+		if authenticated && !src.allows(token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if path == "/" && !strings.HasSuffix(r.URL.Path, "/") {
+			// the dashboard requests its data relative to its own path
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		// the URL is copied, so the audit log keeps the path of the source
+		u := *r.URL
+		u.Path, u.RawPath = path, ""
+		sourced := r.WithContext(context.WithValue(r.Context(), namedSourceKey{}, src))
+		sourced.URL = &u
+		routes.ServeHTTP(w, sourced)
+	})
+}
+
+// sortedSources returns the names of given sources, sorted.
+func sortedSources(sources map[string]*logSource) []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sources returns the current named sources of the server, see server.reload.
+func (srv *server) sources() map[string]*logSource {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	return srv.named
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type sourcesSuite struct {
+	suite.Suite
+	srv     *server
+	handler http.Handler
+}
+
+func (s *sourcesSuite) SetupTest() {
+	dir := writeLogDir(s.T(), "/home")
+	shopDir := writeLogDir(s.T(), "/cart", "/checkout", "/pay")
+	blogDir := writeLogDir(s.T(), "/posts")
+	auth := &authenticator{tokens: []apiToken{
+		{name: "ops", token: "ops-token"},
+		// limited to the shop by its access control list
+		{name: "shop", token: "shop-token", directory: shopDir},
+		{name: "blog", token: "blog-token", directory: blogDir},
+	}}
+	named := map[string]*logSource{
+		"shop": {name: "shop", cfg: logging.LogsConfig{Directory: shopDir, LastNMinutes: 60}, allowed: map[string]bool{"shop": true}},
+		// the tokens not scoped to a directory
+		"blog": {name: "blog", cfg: logging.LogsConfig{Directory: blogDir, LastNMinutes: 60}},
+	}
+	s.srv = newTestServer(dir, auth, named)
+	s.srv.cache = newQueryCache(time.Minute)
+	s.handler = s.srv.handler(nil, false)
+}
+
+func (s *sourcesSuite) Test_handleSources() {
+	tests := []struct {
+		name, target, token string
+		status              int
+		// body is a part of the body of the response, if any
+		body string
+	}{
+		{name: "listed source", target: "/sources/shop/logs", token: "shop-token", status: http.StatusOK, body: "/cart"},
+		{name: "source of another token", target: "/sources/blog/logs", token: "shop-token", status: http.StatusForbidden},
+		{name: "stats of another token", target: "/sources/blog/stats", token: "shop-token", status: http.StatusForbidden},
+		{name: "top of another token", target: "/sources/blog/top", token: "shop-token", status: http.StatusForbidden},
+		{name: "timeseries of another token", target: "/sources/blog/timeseries", token: "shop-token", status: http.StatusForbidden},
+		{name: "tail of another token", target: "/sources/blog/tail", token: "shop-token", status: http.StatusForbidden},
+		{name: "stream of another token", target: "/sources/blog/stats/stream", token: "shop-token", status: http.StatusForbidden},
+		{name: "page of another token", target: "/sources/blog/logs?limit=1", token: "shop-token", status: http.StatusForbidden},
+		// the page holds no logs, its data being requested with the token
+		{name: "dashboard of another token", target: "/sources/blog/", token: "shop-token", status: http.StatusOK},
+		{name: "dashboard without a token", target: "/sources/blog/", status: http.StatusOK},
+		{name: "unknown source", target: "/sources/admin/logs", token: "shop-token", status: http.StatusNotFound},
+		{name: "unlisted token", target: "/sources/shop/logs", token: "ops-token", status: http.StatusForbidden},
+		{name: "token scoped to another directory", target: "/sources/blog/logs", token: "blog-token", status: http.StatusForbidden},
+		{name: "token not scoped to a directory", target: "/sources/blog/logs", token: "ops-token", status: http.StatusOK, body: "/posts"},
+		{name: "stats of the source", target: "/sources/blog/stats", token: "ops-token", status: http.StatusOK},
+		{name: "escaping the source", target: "/sources/shop/../blog/logs", token: "shop-token", status: http.StatusMovedPermanently},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			w := serveRequest(s.handler, test.target, test.token)
+
+			s.Equal(test.status, w.Code, w.Body.String())
+			s.Contains(w.Body.String(), test.body)
+		})
+	}
+}
+
+func (s *sourcesSuite) Test_handleSources_List() {
+	tests := []struct {
+		name, token string
+		expected    []string
+	}{
+		{name: "access control list", token: "shop-token", expected: []string{"shop"}},
+		{name: "not scoped to a directory", token: "ops-token", expected: []string{"blog"}},
+		{name: "scoped to another directory", token: "blog-token", expected: []string{}},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			w := serveRequest(s.handler, "/sources", test.token)
+
+			s.Require().Equal(http.StatusOK, w.Code)
+			var names []string
+			s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &names))
+			s.Equal(test.expected, names)
+		})
+	}
+}
+
+func (s *sourcesSuite) Test_handleSources_Cached() {
+	// the results cached for a token are not served to the tokens which can't read the source
+	s.Equal("MISS", serveRequest(s.handler, "/sources/shop/stats", "shop-token").Header().Get("X-Cache"))
+	s.Equal("HIT", serveRequest(s.handler, "/sources/shop/stats", "shop-token").Header().Get("X-Cache"))
+
+	w := serveRequest(s.handler, "/sources/shop/stats", "ops-token")
+
+	s.Equal(http.StatusForbidden, w.Code)
+	s.Empty(w.Header().Get("X-Cache"))
+}
+
+func (s *sourcesSuite) Test_handleSources_Pages() {
+	var paths []string
+	target := "/sources/shop/logs?limit=2"
+	for target != "" {
+		w := serveRequest(s.handler, target, "shop-token")
+		s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+			var entry logging.LogEntry
+			s.Require().NoError(json.Unmarshal([]byte(line), &entry))
+			paths = append(paths, entry.Path)
+		}
+		target = ""
+		if link := w.Header().Get("Link"); link != "" {
+			// the next page is the one of the source
+			s.True(strings.HasPrefix(link, "</sources/shop/logs?"), link)
+			target = link[1:strings.Index(link, ">")]
+
+			// and cannot be read by the other tokens
+			s.Equal(http.StatusForbidden, serveRequest(s.handler, target, "blog-token").Code)
+			s.Equal(http.StatusForbidden, serveRequest(s.handler, strings.Replace(target, "/shop/", "/blog/", 1), "shop-token").Code)
+		}
+	}
+	s.Equal([]string{"/cart", "/checkout", "/pay"}, paths)
+}
+
+func TestSources(t *testing.T) {
+	suite.Run(t, new(sourcesSuite))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// The ways of splitting the extracted logs into files (see -split).
+const (
+	splitHourly = "hourly"
+	splitDaily  = "daily"
+	splitVHost  = "vhost"
+)
+
+// splitter writes the log lines to the files of an output directory, named after the hour, the day
+// or the virtual host of their log entries (e.g. 2022-03-04T05.log), which is how incident evidence
+// usually needs to be packaged. The files are created once their first log line is written,
+// their names being their keys followed by the suffix, e.g. .log.
+type splitter struct {
+	dir        string
+	key        func(logging.LogEntry) string
+	suffix     string
+	showSource bool
+	files      map[string]*outputFile
+	written    int64
+}
+
+// newSplitter creates a splitter of the given kind (hourly, daily or vhost) writing to a given directory,
+// which is created if needed. The log lines are prefixed with their source when told so (see -show-source).
+func newSplitter(split, dir string, format logging.Format, showSource bool) (*splitter, error) {
+	s := &splitter{dir: dir, suffix: ".log", showSource: showSource, files: make(map[string]*outputFile)}
+	switch split {
+	case splitHourly:
+		s.key = func(entry logging.LogEntry) string { return entry.Time.UTC().Format("2006-01-02T15") }
+	case splitDaily:
+		s.key = func(entry logging.LogEntry) string { return entry.Time.UTC().Format("2006-01-02") }
+	case splitVHost:
+		if format != logging.FormatVHostCombined {
+			return nil, fmt.Errorf("splitting by vhost takes the %s format", logging.FormatVHostCombined)
+		}
+		s.key = vhostKey
+	default:
+		return nil, fmt.Errorf("unsupported split '%s', expected hourly, daily or vhost", split)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// vhostKey returns the virtual host of a log entry as a file name, the log lines without any being written to _.log.
+func vhostKey(entry logging.LogEntry) string {
+	vhost := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, entry.VHost)
+	if vhost == "" || vhost == "." || vhost == ".." || vhost == "-" {
+		return "_"
+	}
+	return vhost
+}
+
+// run writes the log entries to the files of their keys, until done or the context is done.
+// The files are flushed and closed either way.
+func (s *splitter) run(ctx context.Context, each forEach) error {
+	err := each(ctx, func(entry logging.LogEntry) error {
+		file, err := s.file(s.key(entry))
+		if err != nil {
+			return err
+		}
+		bytes := file.bytes
+		err = file.write(entry, s.showSource)
+		s.written += file.bytes - bytes
+		return err
+	})
+	if closeErr := s.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// file returns the file of a given key, creating it on first use along with its directory,
+// the keys possibly holding one (see sourceKey).
+func (s *splitter) file(key string) (*outputFile, error) {
+	if file, ok := s.files[key]; ok {
+		return file, nil
+	}
+	name := filepath.Join(s.dir, key+s.suffix)
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return nil, err
+	}
+	file, err := createOutput(name)
+	if err != nil {
+		return nil, err
+	}
+	s.files[key] = file
+	return file, nil
+}
+
+// close flushes and closes all the files, returning the first error.
+func (s *splitter) close() error {
+	var err error
+	for _, file := range s.files {
+		if closeErr := file.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// outputs returns the files written, in no particular order.
+func (s *splitter) outputs() []*outputFile {
+	files := make([]*outputFile, 0, len(s.files))
+	for _, file := range s.files {
+		files = append(files, file)
+	}
+	return files
+}
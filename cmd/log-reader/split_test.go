@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type splitSuite struct {
+	suite.Suite
+}
+
+// readFiles returns the content of the files of a given directory, and of its subdirectories, by their relative paths.
+func (s *splitSuite) readFiles(dir string) map[string]string {
+	files := make(map[string]string)
+	s.Require().NoError(filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		files[archivePath(dir, path)] = string(content)
+		return err
+	}))
+	return files
+}
+
+func (s *splitSuite) Test_splitter() {
+	start := time.Date(2022, time.March, 3, 23, 30, 0, 0, time.UTC)
+	all := []logging.LogEntry{
+		{Line: "a", Time: start, VHost: "shop.example.com", File: "access.log", Offset: 0},
+		{Line: "b", Time: start.Add(20 * time.Minute), VHost: "blog.example.com", File: "access.log", Offset: 2},
+		{Line: "c", Time: start.Add(40 * time.Minute), VHost: "shop.example.com", File: "access.log", Offset: 4},
+		{Line: "d", Time: start.Add(40 * time.Minute).In(time.FixedZone("CET", 3600)), VHost: "../etc", File: "access.log", Offset: 6},
+		{Line: "e", Time: start.Add(time.Hour), VHost: "-", File: "access.log", Offset: 8},
+	}
+	tests := []struct {
+		name          string
+		split         string
+		showSource    bool
+		expectedFiles map[string]string
+	}{
+		{
+			name:  "Hourly",
+			split: splitHourly,
+			expectedFiles: map[string]string{
+				"2022-03-03T23.log": "a\nb\n",
+				"2022-03-04T00.log": "c\nd\ne\n",
+			},
+		},
+		{
+			name:  "Daily",
+			split: splitDaily,
+			expectedFiles: map[string]string{
+				"2022-03-03.log": "a\nb\n",
+				"2022-03-04.log": "c\nd\ne\n",
+			},
+		},
+		{
+			name:  "Virtual Hosts",
+			split: splitVHost,
+			expectedFiles: map[string]string{
+				"shop.example.com.log": "a\nc\n",
+				"blog.example.com.log": "b\n",
+				".._etc.log":           "d\n",
+				"_.log":                "e\n",
+			},
+		},
+		{
+			name:       "Show Source",
+			split:      splitDaily,
+			showSource: true,
+			expectedFiles: map[string]string{
+				"2022-03-03.log": "access.log:0: a\naccess.log:2: b\n",
+				"2022-03-04.log": "access.log:4: c\naccess.log:6: d\naccess.log:8: e\n",
+			},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			dir := filepath.Join(s.T().TempDir(), "split")
+			split, err := newSplitter(test.split, dir, logging.FormatVHostCombined, test.showSource)
+			s.Require().NoError(err)
+			s.Require().NoError(split.run(context.Background(), entries(all...)))
+			s.Equal(test.expectedFiles, s.readFiles(dir))
+
+			var written int64
+			var names []string
+			for _, file := range split.outputs() {
+				written += file.bytes
+				names = append(names, archivePath(dir, file.name))
+			}
+			s.Equal(written, split.written)
+			sort.Strings(names)
+			expectedNames := make([]string, 0, len(test.expectedFiles))
+			for name := range test.expectedFiles {
+				expectedNames = append(expectedNames, name)
+			}
+			sort.Strings(expectedNames)
+			s.Equal(expectedNames, names)
+		})
+	}
+}
+
+func (s *splitSuite) Test_newSplitter_Invalid() {
+	tests := []struct {
+		name        string
+		split       string
+		format      logging.Format
+		expectedErr string
+	}{
+		{
+			name:        "Unsupported Split",
+			split:       "weekly",
+			format:      logging.FormatCommon,
+			expectedErr: "unsupported split 'weekly', expected hourly, daily or vhost",
+		},
+		{
+			name:        "Virtual Hosts Without Them",
+			split:       splitVHost,
+			format:      logging.FormatCombined,
+			expectedErr: "splitting by vhost takes the vhost_combined format",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			dir := filepath.Join(s.T().TempDir(), "split")
+			_, err := newSplitter(test.split, dir, test.format, false)
+			s.EqualError(err, test.expectedErr)
+			s.NoDirExists(dir)
+		})
+	}
+}
+
+func (s *splitSuite) Test_vhostKey() {
+	tests := []struct {
+		name     string
+		vhost    string
+		expected string
+	}{
+		{name: "Host", vhost: "shop.example.com", expected: "shop.example.com"},
+		{name: "Host And Port", vhost: "shop.example.com:443", expected: "shop.example.com_443"},
+		{name: "Separators", vhost: `a/b\c`, expected: "a_b_c"},
+		{name: "Parent Directory", vhost: "..", expected: "_"},
+		{name: "Current Directory", vhost: ".", expected: "_"},
+		{name: "Unknown", vhost: "-", expected: "_"},
+		{name: "Empty", vhost: "", expected: "_"},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.Equal(test.expected, vhostKey(logging.LogEntry{VHost: test.vhost}))
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	suite.Run(t, new(splitSuite))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// defaultTopN is the number of values ranked for a field given without a number, e.g. "-top ips".
+const defaultTopN = 10
+
+// topSpec is a field to rank along with the number of values to print.
+type topSpec struct {
+	field logging.TopField
+	n     int
+}
+
+// parseTop parses a comma separated list of fields to rank, e.g. "ips=10,paths=5,agents".
+// An empty list means nothing has to be ranked.
+func parseTop(value string) ([]topSpec, error) {
+	var specs []topSpec
+	if value == "" {
+		return specs, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		field, err := logging.ParseTopField(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		spec := topSpec{field: field, n: defaultTopN}
+		if len(kv) == 2 {
+			spec.n, err = strconv.Atoi(kv[1])
+			if err != nil || spec.n <= 0 {
+				return nil, fmt.Errorf("invalid number of %s '%s'", kv[0], kv[1])
+			}
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// printTop prints a ranked table for each of the given fields.
+func printTop(w io.Writer, top *logging.Top, specs []topSpec) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, spec := range specs {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "top %s:\n", spec.field)
+		for _, count := range top.Ranked(spec.field, spec.n) {
+			fmt.Fprintf(tw, "  %s\t%d\n", count.Value, count.Count)
+		}
+		if spec.field.SumsBytes() {
+			fmt.Fprintf(tw, "  total\t%d\n", top.Total(spec.field))
+		}
+		if maxErr := top.MaxError(spec.field); maxErr > 0 {
+			// past -max-top-values
+			fmt.Fprintf(tw, "  (counts overestimated by %d at most)\n", maxErr)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// defaultStatsTopN is the number of values the stats subcommand ranks for a field not given a number.
+const defaultStatsTopN = 5
+
+// statsTopFields are the fields ranked by the reports of the stats subcommand, see parseStatsTop.
+var statsTopFields = []string{"statuses", "paths", "entry-pages", "exit-pages", "domains", "campaigns", "clients"}
+
+// statsTop is the number of values of every field ranked by the reports of the stats subcommand.
+type statsTop map[string]int
+
+// parseStatsTop parses a comma separated list of fields ranked by the stats subcommand along with
+// the number of values to print, e.g. "statuses=10,paths=20,clients", in the same way as parseTop.
+// The fields left out, or given without a number, rank defaultStatsTopN values, while a number alone,
+// e.g. "10" as the -top of the stats subcommand used to be, ranks that many values of every field.
+func parseStatsTop(value string) (statsTop, error) {
+	n := defaultStatsTopN
+	if all, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		if all <= 0 {
+			return nil, fmt.Errorf("invalid number of values '%s'", value)
+		}
+		n, value = all, ""
+	}
+	top := make(statsTop, len(statsTopFields))
+	for _, field := range statsTopFields {
+		top[field] = n
+	}
+	if value == "" {
+		return top, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if _, ok := top[kv[0]]; !ok {
+			return nil, fmt.Errorf("unsupported field '%s' (supported: %s)", kv[0], strings.Join(statsTopFields, ", "))
+		}
+		if len(kv) == 2 {
+			n, err := strconv.Atoi(kv[1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid number of %s '%s'", kv[0], kv[1])
+			}
+			top[kv[0]] = n
+		}
+	}
+
+	return top, nil
+}
+
+// firstCounts returns the first n counts at most of the given ranked ones.
+func firstCounts(counts []logging.Count, n int) []logging.Count {
+	if len(counts) > n {
+		return counts[:n]
+	}
+	return counts
+}
+
+// maxInt returns the larger of a and b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
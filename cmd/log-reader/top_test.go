@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type topSuite struct {
+	suite.Suite
+}
+
+func (s *topSuite) Test_parseStatsTop() {
+	tests := []struct {
+		name     string
+		value    string
+		expected statsTop
+	}{
+		{
+			name:     "default",
+			value:    "",
+			expected: statsTop{"statuses": 5, "paths": 5, "entry-pages": 5, "exit-pages": 5, "domains": 5, "campaigns": 5, "clients": 5},
+		},
+		{
+			name:     "number of every field",
+			value:    "10",
+			expected: statsTop{"statuses": 10, "paths": 10, "entry-pages": 10, "exit-pages": 10, "domains": 10, "campaigns": 10, "clients": 10},
+		},
+		{
+			name:     "fields",
+			value:    "statuses=10, paths=20,clients",
+			expected: statsTop{"statuses": 10, "paths": 20, "entry-pages": 5, "exit-pages": 5, "domains": 5, "campaigns": 5, "clients": 5},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			top, err := parseStatsTop(test.value)
+
+			s.Require().NoError(err)
+			s.Equal(test.expected, top)
+		})
+	}
+}
+
+func (s *topSuite) Test_parseStatsTop_Invalid() {
+	for _, value := range []string{"0", "-3", "ips=10", "paths=0", "paths=x"} {
+		_, err := parseStatsTop(value)
+
+		s.Error(err, value)
+	}
+}
+
+func TestTop(t *testing.T) {
+	suite.Run(t, new(topSuite))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// runValidate runs the validate subcommand, reading every line of the log files of the directory
+// whatever their times, and reporting the lines of each file, their first and last times,
+// the lines not matching the log format and the ones out of order.
+// It exits with exitInvalidLines when a file isn't valid, so it can vet a directory in scripts.
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	logsConfig := logsFlags(fs)
+	outputFlag := fs.String("o", outputTable, "the output format: table, json")
+	parseFlags(fs, "validate", args)
+	if *outputFlag != outputTable && *outputFlag != outputJSON {
+		exit(exitUsage, "unsupported output format '%s'", *outputFlag)
+	}
+
+	cfg, err := logsConfig(false)
+	if err != nil {
+		exit(exitUsage, "invalid configuration: %v", err)
+	}
+	logs, err := logging.NewLogs(cfg)
+	if err != nil {
+		exit(exitIOFailure, "could not create logs: %v", err)
+	}
+
+	reports, err := logs.Validate(context.Background())
+	if err != nil {
+		exit(exitIOFailure, "could not validate logs: %v", err)
+	}
+	if *outputFlag == outputJSON {
+		err = printJSON(os.Stdout, newValidateReport(reports))
+	} else {
+		err = printValidation(os.Stdout, reports)
+	}
+	if err != nil {
+		exit(exitIOFailure, "could not print validation: %v", err)
+	}
+	for _, report := range reports {
+		if !report.Valid() {
+			os.Exit(exitInvalidLines)
+		}
+	}
+}
+
+// fileReport is the JSON representation of the validation of a log file,
+// the invalid lines being the messages of their errors.
+type fileReport struct {
+	logging.FileReport
+	InvalidLines []string `json:"invalid_lines,omitempty"`
+	Valid        bool     `json:"valid"`
+}
+
+func newValidateReport(reports []logging.FileReport) []fileReport {
+	files := make([]fileReport, 0, len(reports))
+	for _, report := range reports {
+		file := fileReport{FileReport: report, Valid: report.Valid()}
+		for _, line := range report.InvalidLines {
+			file.InvalidLines = append(file.InvalidLines, line.Error())
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// printValidation prints the validation of every log file as a row of an aligned table,
+// followed by the first of their invalid and unsorted lines.
+func printValidation(w io.Writer, reports []logging.FileReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tLINES\tFIRST\tLAST\tINVALID\tUNSORTED\tMAX SKEW")
+	var lines, invalid, unsorted int64
+	for _, report := range reports {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%d\t%s\n", report.File, report.Lines,
+			formatTime(report.First), formatTime(report.Last), report.Invalid, report.Unsorted, report.MaxSkew)
+		lines += report.Lines
+		invalid += report.Invalid
+		unsorted += report.Unsorted
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		for _, line := range report.InvalidLines {
+			fmt.Fprintln(w, line.Error())
+		}
+		for _, line := range report.UnsortedLines {
+			fmt.Fprintf(w, "%s:%d (offset %d): log time %s older than %s before it\n", report.File, line.LineNumber,
+				line.Offset, formatTime(line.Time), formatTime(line.Latest))
+		}
+		if report.Incomplete {
+			fmt.Fprintf(w, "%s: incomplete last line, most likely still being written\n", report.File)
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d files, %d lines, %d invalid, %d unsorted\n", len(reports), lines, invalid, unsorted)
+	return err
+}
+
+// formatTime formats the time of a log line, - when there's none.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// textLogger writes the diagnostic messages of the logs (see logging.Logger) as lines of key=value pairs,
+// the way the text handler of log/slog does, e.g.
+// time=2022-03-04T05:30:00.000Z level=INFO msg="offset found" file=access.log offset=1024 by="binary search" took=1.2ms
+// The debug messages are only written when debug is set, and only the messages of a given name when only is set.
+type textLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	debug bool
+	only  string
+}
+
+// Info writes a diagnostic message of every phase.
+func (l *textLogger) Info(msg string, args ...interface{}) {
+	l.write("INFO", msg, args)
+}
+
+// Debug writes a detailed diagnostic message, when debugging.
+func (l *textLogger) Debug(msg string, args ...interface{}) {
+	if l.debug {
+		l.write("DEBUG", msg, args)
+	}
+}
+
+// write writes a message of a given level along with its keys and values, on a line of its own.
+func (l *textLogger) write(level, msg string, args []interface{}) {
+	if l.only != "" && msg != l.only {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("time=" + time.Now().UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteString(" level=" + level)
+	b.WriteString(" msg=" + quoteValue(msg))
+	for i := 0; i < len(args); i += 2 {
+		key, value := fmt.Sprint(args[i]), interface{}("!MISSING")
+		if i+1 < len(args) {
+			value = args[i+1]
+		}
+		b.WriteString(" " + key + "=" + quoteValue(formatValue(value)))
+	}
+	b.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.w, b.String())
+}
+
+// formatValue formats the value of a diagnostic message, the times as RFC 3339 with milliseconds.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format("2006-01-02T15:04:05.000Z07:00")
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// quoteValue quotes a given value when it's empty or holds spaces, quotes, equal signs or control characters.
+func quoteValue(s string) string {
+	if s == "" || strings.ContainsAny(s, ` "=`) || strings.IndexFunc(s, unicode.IsControl) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
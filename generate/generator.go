@@ -0,0 +1,363 @@
+// Package generate writes synthetic Apache access logs, e.g. to benchmark the log reader on realistic data
+// or to reproduce a bug without sharing the actual logs.
+package generate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// The defaults of a Generator.
+const (
+	DefaultFormat = logging.FormatCommon
+	DefaultRate   = 10.0
+	DefaultPaths  = 200
+	DefaultIPs    = 1000
+	// zipfSkew is how skewed the popularity of the paths and IPs is, a few of them making most of the requests.
+	zipfSkew = 1.1
+)
+
+var (
+	// DefaultStatuses are the weights of the statuses of the requests by default.
+	DefaultStatuses = map[int]float64{200: 85, 304: 5, 301: 2, 404: 5, 500: 2, 503: 1}
+	// DefaultMethods are the weights of the methods of the requests by default.
+	DefaultMethods = map[string]float64{"GET": 85, "POST": 10, "HEAD": 2, "PUT": 2, "DELETE": 1}
+)
+
+// pathTemplates are the shapes of the paths requested but the most popular one (/), the path of rank n
+// being the template n modulo their number, given n as parameter.
+var pathTemplates = []string{
+	"/api/v1/items/%d", "/static/js/app.%d.js", "/products/%d", "/search?q=term%d", "/api/v1/users/%d/orders",
+	"/static/css/site.%d.css", "/blog/post-%d", "/images/%d.png", "/login?next=/account/%d",
+}
+
+// agents are the user agents of the combined format, by weight, along with their referers.
+var agents = []struct {
+	agent, referer string
+	weight         float64
+}{
+	{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.51 Safari/537.36", "https://www.google.com/", 40},
+	{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.3 Safari/605.1.15", "https://www.example.com/", 20},
+	{"Mozilla/5.0 (X11; Linux x86_64; rv:97.0) Gecko/20100101 Firefox/97.0", "-", 10},
+	{"Mozilla/5.0 (iPhone; CPU iPhone OS 15_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148", "https://t.co/", 15},
+	{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "-", 8},
+	{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", "-", 4},
+	{"curl/7.79.1", "-", 3},
+}
+
+// Generator writes log lines which times follow a Poisson process of a given rate, their statuses and methods
+// being picked by weight, and their paths and client IPs by popularity, a few of them making most of the requests.
+// The log lines are the same for the same seed and options. A Generator isn't safe for concurrent use.
+type Generator struct {
+	format   logging.Format
+	rate     float64
+	paths    int
+	ips      int
+	seed     int64
+	statuses map[int]float64
+	methods  map[string]float64
+
+	rnd            *rand.Rand
+	statusPicker   picker
+	methodPicker   picker
+	agentPicker    picker
+	pathZipf       *rand.Zipf
+	ipZipf         *rand.Zipf
+	line           []byte
+	second         int64
+	dateTime       string
+	generatedLines int64
+}
+
+// Option configures the Generator created by New.
+type Option func(g *Generator)
+
+// WithFormat sets the format of the log lines, either logging.FormatCommon (by default) or logging.FormatCombined.
+func WithFormat(format logging.Format) Option {
+	return func(g *Generator) {
+		g.format = format
+	}
+}
+
+// WithRate sets the mean number of log lines per second, DefaultRate by default.
+func WithRate(linesPerSecond float64) Option {
+	return func(g *Generator) {
+		g.rate = linesPerSecond
+	}
+}
+
+// WithStatuses sets the weights of the statuses of the requests, e.g. {200: 90, 500: 10}, DefaultStatuses by default.
+func WithStatuses(weights map[int]float64) Option {
+	return func(g *Generator) {
+		g.statuses = weights
+	}
+}
+
+// WithMethods sets the weights of the methods of the requests, e.g. {"GET": 90, "POST": 10}, DefaultMethods by default.
+func WithMethods(weights map[string]float64) Option {
+	return func(g *Generator) {
+		g.methods = weights
+	}
+}
+
+// WithPaths sets the number of distinct paths requested, DefaultPaths by default.
+func WithPaths(n int) Option {
+	return func(g *Generator) {
+		g.paths = n
+	}
+}
+
+// WithIPs sets the number of distinct client IPs, DefaultIPs by default.
+func WithIPs(n int) Option {
+	return func(g *Generator) {
+		g.ips = n
+	}
+}
+
+// WithSeed sets the seed of the random numbers, so the same log lines are generated again, 1 by default.
+func WithSeed(seed int64) Option {
+	return func(g *Generator) {
+		g.seed = seed
+	}
+}
+
+// New creates a Generator configured by the given options, returning an error when they're invalid.
+func New(opts ...Option) (*Generator, error) {
+	g := &Generator{
+		format:   DefaultFormat,
+		rate:     DefaultRate,
+		paths:    DefaultPaths,
+		ips:      DefaultIPs,
+		seed:     1,
+		statuses: DefaultStatuses,
+		methods:  DefaultMethods,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	switch {
+	case g.format != logging.FormatCommon && g.format != logging.FormatCombined:
+		return nil, fmt.Errorf("format: %w: %s", logging.ErrUnsupportedFormat, g.format)
+	case !(g.rate > 0) || math.IsInf(g.rate, 1):
+		return nil, errors.New("rate: must be positive")
+	case g.paths <= 0:
+		return nil, errors.New("paths: must be positive")
+	case g.ips <= 0:
+		return nil, errors.New("ips: must be positive")
+	}
+
+	statuses := make(map[string]float64, len(g.statuses))
+	for status, weight := range g.statuses {
+		if status < 100 || status > 599 {
+			return nil, fmt.Errorf("statuses: invalid status %d", status)
+		}
+		statuses[strconv.Itoa(status)] = weight
+	}
+	var err error
+	if g.statusPicker, err = newPicker(statuses); err != nil {
+		return nil, fmt.Errorf("statuses: %w", err)
+	}
+	if g.methodPicker, err = newPicker(g.methods); err != nil {
+		return nil, fmt.Errorf("methods: %w", err)
+	}
+	agentWeights := make(map[string]float64, len(agents))
+	for i, agent := range agents {
+		agentWeights[strconv.Itoa(i)] = agent.weight
+	}
+	g.agentPicker, _ = newPicker(agentWeights)
+
+	g.rnd = rand.New(rand.NewSource(g.seed))
+	g.pathZipf = rand.NewZipf(g.rnd, zipfSkew, 1, uint64(g.paths-1))
+	g.ipZipf = rand.NewZipf(g.rnd, zipfSkew, 1, uint64(g.ips-1))
+	g.second = -1
+	return g, nil
+}
+
+// Format returns the format of the log lines generated.
+func (g *Generator) Format() logging.Format {
+	return g.format
+}
+
+// Lines returns the number of log lines generated so far.
+func (g *Generator) Lines() int64 {
+	return g.generatedLines
+}
+
+// Write writes the log lines of the time range [from, to) to a given writer.
+func (g *Generator) Write(w io.Writer, from, to time.Time) error {
+	bw := bufio.NewWriterSize(w, 1<<16)
+	for t := g.next(from); t.Before(to); t = g.next(t) {
+		if _, err := bw.Write(g.appendLine(g.line[:0], t)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteFiles writes the log lines of the time range [from, to) to a given number of files inside a given directory,
+// which is created if needed, the way they're rotated by logrotate: access.log holds the latest logs,
+// access.log.1 the ones before, and so on. The modified time of every file is the end of its share of the time range.
+// It returns the paths of the files written, from the oldest to the latest.
+func (g *Generator) WriteFiles(dir string, files int, from, to time.Time) ([]string, error) {
+	if files <= 0 {
+		return nil, errors.New("files: must be positive")
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	span := to.Sub(from) / time.Duration(files)
+	paths := make([]string, 0, files)
+	for i := 0; i < files; i++ {
+		name := "access.log"
+		if n := files - 1 - i; n > 0 {
+			name += "." + strconv.Itoa(n)
+		}
+		path := filepath.Join(dir, name)
+		start, end := from.Add(time.Duration(i)*span), from.Add(time.Duration(i+1)*span)
+		if i == files-1 {
+			end = to
+		}
+		if err := g.writeFile(path, start, end); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeFile writes the log lines of the time range [from, to) to a given file, modified at the end of the time range.
+func (g *Generator) writeFile(path string, from, to time.Time) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := g.Write(f, from, to); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(path, to, to)
+}
+
+// next returns the time of the log line following one at a given time, the times between two lines
+// being exponentially distributed. The times are truncated to the second when written, like Apache does.
+func (g *Generator) next(t time.Time) time.Time {
+	return t.Add(time.Duration(g.rnd.ExpFloat64() / g.rate * float64(time.Second)))
+}
+
+// appendLine appends the log line of a given time to a given buffer, returning it.
+func (g *Generator) appendLine(line []byte, t time.Time) []byte {
+	g.generatedLines++
+	if s := t.Unix(); s != g.second {
+		g.second, g.dateTime = s, t.Format("02/Jan/2006:15:04:05 -0700")
+	}
+	status := g.statusPicker.pick(g.rnd)
+
+	line = appendIP(line, g.ipZipf.Uint64())
+	line = append(line, " - - ["...)
+	line = append(line, g.dateTime...)
+	line = append(line, "] \""...)
+	line = append(line, g.methodPicker.pick(g.rnd)...)
+	line = append(line, ' ')
+	if rank := g.pathZipf.Uint64(); rank == 0 {
+		line = append(line, '/')
+	} else {
+		line = append(line, fmt.Sprintf(pathTemplates[rank%uint64(len(pathTemplates))], rank)...)
+	}
+	line = append(line, " HTTP/1.1\" "...)
+	line = append(line, status...)
+	line = append(line, ' ')
+	line = strconv.AppendInt(line, g.size(status), 10)
+	if g.format == logging.FormatCombined {
+		agent := agents[g.agentPicker.index(g.rnd)]
+		line = append(line, " \""...)
+		line = append(line, agent.referer...)
+		line = append(line, "\" \""...)
+		line = append(line, agent.agent...)
+		line = append(line, '"')
+	}
+	g.line = append(line, '\n')
+	return g.line
+}
+
+// size returns the size of the response of a given status, log-normally distributed around a few kilobytes.
+func (g *Generator) size(status string) int64 {
+	switch status {
+	case "204", "304":
+		return 0
+	case "301", "302":
+		return 200 + g.rnd.Int63n(50)
+	}
+	return int64(math.Exp(8 + 1.2*g.rnd.NormFloat64()))
+}
+
+// appendIP appends the client IP of a given rank, spread over the public IPv4 addresses, to a given buffer.
+func appendIP(b []byte, rank uint64) []byte {
+	// a multiplicative hash, so the IPs of close ranks don't share their subnets
+	h := uint32(rank*2654435761 + 0x9e3779b9)
+	b = strconv.AppendUint(b, uint64(1+(h>>24)%223), 10)
+	for _, shift := range []uint32{16, 8, 0} {
+		b = append(b, '.')
+		b = strconv.AppendUint(b, uint64(h>>shift&0xff), 10)
+	}
+	return b
+}
+
+// picker picks values at random by weight.
+type picker struct {
+	values []string
+	// cumulative are the sums of the weights of the values up to every value
+	cumulative []float64
+}
+
+// newPicker creates a picker of the given values by weight, which must not be negative and sum to more than 0.
+func newPicker(weights map[string]float64) (picker, error) {
+	var p picker
+	for value := range weights {
+		p.values = append(p.values, value)
+	}
+	// sorted, so the same values are picked for the same seed
+	sort.Strings(p.values)
+	var sum float64
+	for _, value := range p.values {
+		weight := weights[value]
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 1) {
+			return p, fmt.Errorf("invalid weight %v of %s", weight, value)
+		}
+		sum += weight
+		p.cumulative = append(p.cumulative, sum)
+	}
+	if !(sum > 0) {
+		return p, errors.New("the weights must sum to more than 0")
+	}
+	return p, nil
+}
+
+// index returns the index of a value picked at random, never one of 0 weight.
+func (p picker) index(rnd *rand.Rand) int {
+	x := rnd.Float64() * p.cumulative[len(p.cumulative)-1]
+	i := sort.Search(len(p.cumulative), func(i int) bool { return p.cumulative[i] > x })
+	// x may only reach the sum of the weights when rounded up
+	if i == len(p.values) {
+		i--
+	}
+	return i
+}
+
+// pick returns a value picked at random.
+func (p picker) pick(rnd *rand.Rand) string {
+	return p.values[p.index(rnd)]
+}
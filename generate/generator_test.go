@@ -0,0 +1,144 @@
+package generate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+const generateDataDir = "test/generate"
+
+type generatorSuite struct {
+	suite.Suite
+	from time.Time
+}
+
+func (s *generatorSuite) SetupTest() {
+	s.from = time.Date(2022, time.March, 3, 2, 0, 0, 0, time.UTC)
+}
+
+func (s *generatorSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(generateDataDir)))
+}
+
+func (s *generatorSuite) Test_WriteFiles() {
+	for _, format := range []logging.Format{logging.FormatCommon, logging.FormatCombined} {
+		s.Run(string(format), func() {
+			dir := filepath.Join(generateDataDir, string(format))
+			g, err := New(WithFormat(format), WithRate(100))
+			s.Require().NoError(err)
+			to := s.from.Add(10 * time.Minute)
+
+			paths, err := g.WriteFiles(dir, 5, s.from, to)
+			s.Require().NoError(err)
+
+			s.Equal([]string{
+				filepath.Join(dir, "access.log.4"), filepath.Join(dir, "access.log.3"), filepath.Join(dir, "access.log.2"),
+				filepath.Join(dir, "access.log.1"), filepath.Join(dir, "access.log"),
+			}, paths)
+			// about 100 lines per second, within 4 standard deviations of the Poisson process
+			s.InDelta(60000, g.Lines(), 4*245)
+
+			// every line matches the format, in order
+			logs, err := logging.New(logging.WithDirectory(dir), logging.WithFormat(format))
+			s.Require().NoError(err)
+			reports, err := logs.Validate(context.Background())
+			s.Require().NoError(err)
+			s.Require().Len(reports, 5)
+			var lines int64
+			for i, report := range reports {
+				s.Equal(paths[i], report.File)
+				s.True(report.Valid(), report.File)
+				s.False(report.First.Before(s.from.Add(time.Duration(i) * 2 * time.Minute)))
+				s.True(report.Last.Before(s.from.Add(time.Duration(i+1) * 2 * time.Minute)))
+				info, err := os.Stat(report.File)
+				s.Require().NoError(err)
+				s.Equal(s.from.Add(time.Duration(i+1)*2*time.Minute), info.ModTime().UTC())
+				lines += report.Lines
+			}
+			s.Equal(g.Lines(), lines)
+		})
+	}
+}
+
+func (s *generatorSuite) Test_Write_Seed() {
+	write := func(opts ...Option) string {
+		g, err := New(opts...)
+		s.Require().NoError(err)
+		buf := &bytes.Buffer{}
+		s.Require().NoError(g.Write(buf, s.from, s.from.Add(time.Minute)))
+		return buf.String()
+	}
+
+	s.Equal(write(), write())
+	s.Equal(write(WithSeed(42)), write(WithSeed(42)))
+	s.NotEqual(write(), write(WithSeed(42)))
+}
+
+func (s *generatorSuite) Test_Write_Distributions() {
+	g, err := New(WithRate(50), WithStatuses(map[int]float64{500: 1, 200: 0}), WithMethods(map[string]float64{"POST": 1}),
+		WithPaths(1), WithIPs(1))
+	s.Require().NoError(err)
+	buf := &bytes.Buffer{}
+	s.Require().NoError(g.Write(buf, s.from, s.from.Add(time.Minute)))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	s.Require().Len(lines, int(g.Lines()))
+	ip := strings.Fields(lines[0])[0]
+	for _, line := range lines {
+		s.True(strings.HasPrefix(line, ip+" - - ["), line)
+		s.Contains(line, "\"POST / HTTP/1.1\" 500 ")
+	}
+}
+
+func (s *generatorSuite) Test_Write_Skewed() {
+	g, err := New(WithRate(1000))
+	s.Require().NoError(err)
+	buf := &bytes.Buffer{}
+	s.Require().NoError(g.Write(buf, s.from, s.from.Add(time.Minute)))
+
+	// a few paths make most of the requests, all of the statuses and methods being picked by weight
+	paths, statuses := map[string]int{}, map[string]int{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		fields := strings.Fields(line)
+		paths[fields[6]]++
+		statuses[fields[8]]++
+	}
+	s.Greater(paths["/"], int(g.Lines())/10)
+	s.Greater(len(paths), 50)
+	s.InDelta(0.85, float64(statuses["200"])/float64(g.Lines()), 0.02)
+	s.Len(statuses, len(DefaultStatuses))
+}
+
+func (s *generatorSuite) Test_New_Invalid() {
+	for _, test := range []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{name: "Format", opts: []Option{WithFormat(logging.FormatCRI)}, expected: "format: unsupported log format: cri"},
+		{name: "Rate", opts: []Option{WithRate(0)}, expected: "rate: must be positive"},
+		{name: "Paths", opts: []Option{WithPaths(0)}, expected: "paths: must be positive"},
+		{name: "IPs", opts: []Option{WithIPs(-1)}, expected: "ips: must be positive"},
+		{name: "Status", opts: []Option{WithStatuses(map[int]float64{42: 1})}, expected: "statuses: invalid status 42"},
+		{name: "Negative Weight", opts: []Option{WithMethods(map[string]float64{"GET": -1})}, expected: "methods: invalid weight -1 of GET"},
+		{name: "No Weight", opts: []Option{WithStatuses(map[int]float64{200: 0})}, expected: "statuses: the weights must sum to more than 0"},
+	} {
+		s.Run(test.name, func() {
+			_, err := New(test.opts...)
+			s.EqualError(err, test.expected)
+		})
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	suite.Run(t, new(generatorSuite))
+}
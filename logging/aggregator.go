@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Aggregator accumulates the log entries one at a time, in order to report on them once they've all been read.
+// All the built-in aggregations (e.g. Stats, Top or Latencies) are Aggregators, so any number of them,
+// built-in or custom, can be computed in a single pass over the logs using Logs.Aggregate.
+type Aggregator interface {
+	// Add accumulates a given log entry, including the ones that are not requests (see LogEntry).
+	Add(entry LogEntry)
+	// Result returns the outcome of the aggregation, e.g. to be encoded as JSON.
+	Result() interface{}
+}
+
+var (
+	aggregatorsMu sync.RWMutex
+	aggregators   = make(map[string]func() Aggregator)
+)
+
+// RegisterAggregator makes an aggregation available by a given name, e.g. to be picked from the command line,
+// creating a new Aggregator using the given function every time it's run (see NewAggregator).
+// It's meant to be called from the init function of the package implementing the aggregation,
+// and panics when called twice with the same name or with a nil function.
+func RegisterAggregator(name string, newAggregator func() Aggregator) {
+	aggregatorsMu.Lock()
+	defer aggregatorsMu.Unlock()
+	if newAggregator == nil {
+		panic("logging: RegisterAggregator function is nil")
+	}
+	if _, dup := aggregators[name]; dup {
+		panic("logging: RegisterAggregator called twice for aggregator " + name)
+	}
+	aggregators[name] = newAggregator
+}
+
+// NewAggregator creates a new Aggregator of the aggregation registered by a given name, see RegisterAggregator.
+func NewAggregator(name string) (Aggregator, error) {
+	aggregatorsMu.RLock()
+	newAggregator, ok := aggregators[name]
+	aggregatorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown aggregator '%s'", name)
+	}
+	return newAggregator(), nil
+}
+
+// Aggregators returns the sorted names of the registered aggregations.
+func Aggregators() []string {
+	aggregatorsMu.RLock()
+	defer aggregatorsMu.RUnlock()
+	names := make([]string, 0, len(aggregators))
+	for name := range aggregators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Aggregate reads the log entries that happened within the last N minutes once, adding each of them to all the given
+// Aggregators in turn. The Aggregators hold the entries read until then even when an error (e.g. ErrNoFilesInWindow)
+// is returned.
+func (logs *Logs) Aggregate(ctx context.Context, aggregators ...Aggregator) error {
+	return logs.ForEach(ctx, func(entry LogEntry) error {
+		for _, aggregator := range aggregators {
+			aggregator.Add(entry)
+		}
+		return nil
+	})
+}
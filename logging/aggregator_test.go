@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const aggregatorDataDir = "test/aggregator"
+
+// the built-in aggregations are all Aggregators
+var (
+	_ Aggregator = (*Stats)(nil)
+	_ Aggregator = (*Top)(nil)
+	_ Aggregator = (*TimeSeries)(nil)
+	_ Aggregator = (*Latencies)(nil)
+	_ Aggregator = (*Apdex)(nil)
+	_ Aggregator = (*Sessions)(nil)
+	_ Aggregator = (*NotFound)(nil)
+	_ Aggregator = (*Referers)(nil)
+	_ Aggregator = (*Suspicious)(nil)
+	_ Aggregator = (*RateLimit)(nil)
+)
+
+// linesAggregator counts all the log lines, including the ones that are not requests.
+type linesAggregator struct {
+	lines int
+}
+
+func (agg *linesAggregator) Add(LogEntry) {
+	agg.lines++
+}
+
+func (agg *linesAggregator) Result() interface{} {
+	return agg.lines
+}
+
+type aggregatorSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *aggregatorSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(aggregatorDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.1" 500 10
+this line cannot be parsed
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(aggregatorDataDir))
+}
+
+func (s *aggregatorSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(aggregatorDataDir)))
+}
+
+func (s *aggregatorSuite) Test_Aggregate() {
+	lines := &linesAggregator{}
+	stats := NewStats(time.Minute)
+	top := NewTop(TopPaths)
+
+	err := s.logs.Aggregate(context.Background(), lines, stats, top)
+
+	s.NoError(err)
+	s.Equal(3, lines.Result())
+	s.Equal(2, stats.Requests)
+	s.Equal(1, stats.Errors)
+	s.Equal(map[TopField][]Count{TopPaths: {{Value: "/a", Count: 1}, {Value: "/b", Count: 1}}}, top.Result())
+}
+
+func (s *aggregatorSuite) Test_RegisterAggregator() {
+	RegisterAggregator("test-lines", func() Aggregator { return &linesAggregator{} })
+
+	s.Contains(Aggregators(), "test-lines")
+	agg, err := NewAggregator("test-lines")
+	s.Require().NoError(err)
+	s.NoError(s.logs.Aggregate(context.Background(), agg))
+	s.Equal(3, agg.Result())
+	// every aggregator starts from scratch
+	agg, err = NewAggregator("test-lines")
+	s.Require().NoError(err)
+	s.Equal(0, agg.Result())
+
+	s.Panics(func() {
+		RegisterAggregator("test-lines", func() Aggregator { return &linesAggregator{} })
+	})
+	s.Panics(func() {
+		RegisterAggregator("test-nil", nil)
+	})
+	_, err = NewAggregator("test-unknown")
+	s.EqualError(err, "unknown aggregator 'test-unknown'")
+}
+
+func TestAggregator(t *testing.T) {
+	suite.Run(t, new(aggregatorSuite))
+}
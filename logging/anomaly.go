@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"math"
+	"time"
+)
+
+// The metrics of a TimeSeries checked for anomalies.
+const (
+	// MetricRequests is the number of requests of a bucket.
+	MetricRequests = "requests"
+	// MetricErrorRate is the share of the requests of a bucket answered with a server error (5xx), between 0 and 1.
+	MetricErrorRate = "error-rate"
+)
+
+const (
+	// minAnomalyHistory is the number of buckets needed before the following ones can be anomalies
+	minAnomalyHistory = 3
+	// minErrorRateStdDev keeps a steady error rate (e.g. always 0) from making any new error an anomaly
+	minErrorRateStdDev = 0.01
+)
+
+// Anomaly is a bucket of a TimeSeries which metric spiked compared to the preceding buckets.
+type Anomaly struct {
+	// Time is the start of the interval of the bucket.
+	Time   time.Time `json:"time"`
+	Metric string    `json:"metric"`
+	Value  float64   `json:"value"`
+	// Mean and StdDev are the mean and standard deviation of the metric over the preceding buckets.
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	// Sigma is the number of standard deviations the value is above the mean.
+	Sigma float64 `json:"sigma"`
+}
+
+// Anomalies returns the buckets which number of requests or error rate is more than sigma standard deviations
+// above the mean of up to the given number of preceding buckets (a rolling window), in order of time.
+// Only spikes are anomalies, so the last bucket, which is usually still being filled, doesn't raise false alarms.
+// The standard deviation is at least the one expected from random arrivals (the square root of the mean)
+// for the number of requests, and 1 point for the error rate, so steady metrics don't make every change an anomaly.
+func (ts *TimeSeries) Anomalies(sigma float64, history int) []Anomaly {
+	requests := make([]float64, len(ts.Buckets))
+	errorRates := make([]float64, len(ts.Buckets))
+	for i, bucket := range ts.Buckets {
+		requests[i] = float64(bucket.Requests)
+		if bucket.Requests > 0 {
+			errorRates[i] = float64(bucket.Errors) / float64(bucket.Requests)
+		}
+	}
+
+	var anomalies []Anomaly
+	for i, bucket := range ts.Buckets {
+		start := i - history
+		if start < 0 {
+			start = 0
+		}
+		if i-start < minAnomalyHistory {
+			continue
+		}
+
+		mean, stdDev := meanStdDev(requests[start:i])
+		if anomaly, ok := anomalous(requests[i], mean, math.Max(stdDev, math.Sqrt(mean)), sigma); ok {
+			anomaly.Time, anomaly.Metric = bucket.Time, MetricRequests
+			anomalies = append(anomalies, anomaly)
+		}
+		mean, stdDev = meanStdDev(errorRates[start:i])
+		if anomaly, ok := anomalous(errorRates[i], mean, math.Max(stdDev, minErrorRateStdDev), sigma); ok {
+			anomaly.Time, anomaly.Metric = bucket.Time, MetricErrorRate
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+
+	return anomalies
+}
+
+// anomalous checks whether a given value is more than sigma (positive) standard deviations above the mean.
+func anomalous(value, mean, stdDev, sigma float64) (Anomaly, bool) {
+	if stdDev <= 0 {
+		return Anomaly{}, false
+	}
+	score := (value - mean) / stdDev
+	return Anomaly{Value: value, Mean: mean, StdDev: stdDev, Sigma: score}, score > sigma
+}
+
+// meanStdDev returns the mean and the (population) standard deviation of the given values.
+func meanStdDev(values []float64) (mean, stdDev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		stdDev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stdDev / float64(len(values)))
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Apdex scores the user satisfaction with the response times of the requests logged within the last N minutes,
+// overall and per path, following the Apdex standard (https://www.apdex.org) for a given threshold T:
+// the requests served within T are satisfied, within 4T tolerating, and frustrated otherwise.
+// Requests answered with a server error are always frustrated.
+// Only the requests logging the time taken to serve them (%D) are taken into account.
+type Apdex struct {
+	threshold time.Duration
+	overall   ApdexScore
+	paths     map[string]*ApdexScore
+}
+
+// ApdexScore is the Apdex score of a number of requests, between 0 (all frustrated) and 1 (all satisfied).
+type ApdexScore struct {
+	// Path is the path of the requests, empty for all the requests.
+	Path       string  `json:"path,omitempty"`
+	Count      int     `json:"count"`
+	Satisfied  int     `json:"satisfied"`
+	Tolerating int     `json:"tolerating"`
+	Frustrated int     `json:"frustrated"`
+	Score      float64 `json:"score"`
+}
+
+// NewApdex creates an empty Apdex for a given threshold T, e.g. 500ms.
+func NewApdex(threshold time.Duration) *Apdex {
+	return &Apdex{
+		threshold: threshold,
+		paths:     make(map[string]*ApdexScore),
+	}
+}
+
+// Apdex reads the log entries that happened within the last N minutes and scores them for a given threshold T.
+// The scores are returned even along with an error (e.g. ErrNoFilesInWindow), accounting for the entries read until then.
+func (logs *Logs) Apdex(ctx context.Context, threshold time.Duration) (*Apdex, error) {
+	apdex := NewApdex(threshold)
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		apdex.Add(entry)
+		return nil
+	})
+
+	return apdex, err
+}
+
+// Add scores a given log entry, ignoring the ones without a duration.
+func (apdex *Apdex) Add(entry LogEntry) {
+	if entry.IP == "" || entry.Duration <= 0 {
+		return
+	}
+
+	score, ok := apdex.paths[entry.Path]
+	if !ok {
+		score = &ApdexScore{Path: entry.Path}
+		apdex.paths[entry.Path] = score
+	}
+	for _, s := range []*ApdexScore{&apdex.overall, score} {
+		s.Count++
+		switch {
+		case entry.serverError() || entry.Duration > 4*apdex.threshold:
+			s.Frustrated++
+		case entry.Duration > apdex.threshold:
+			s.Tolerating++
+		default:
+			s.Satisfied++
+		}
+	}
+}
+
+// Result returns the Apdex scores of all the requests, followed by the ones of every path (see ByPath).
+func (apdex *Apdex) Result() interface{} {
+	return append([]ApdexScore{apdex.Overall()}, apdex.ByPath(0)...)
+}
+
+// Overall returns the Apdex score of all the requests.
+func (apdex *Apdex) Overall() ApdexScore {
+	return apdex.overall.scored()
+}
+
+// ByPath returns the Apdex score of the requests of every path, the lowest first,
+// or only the n lowest ones when n is positive. Ties are ordered by path.
+func (apdex *Apdex) ByPath(n int) []ApdexScore {
+	paths := make([]ApdexScore, 0, len(apdex.paths))
+	for _, score := range apdex.paths {
+		paths = append(paths, score.scored())
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Score == paths[j].Score {
+			return paths[i].Path < paths[j].Path
+		}
+		return paths[i].Score < paths[j].Score
+	})
+	if n > 0 && n < len(paths) {
+		paths = paths[:n]
+	}
+
+	return paths
+}
+
+// scored returns a copy of the score with its Score computed, which is 0 without any request.
+func (score ApdexScore) scored() ApdexScore {
+	if score.Count > 0 {
+		score.Score = (float64(score.Satisfied) + float64(score.Tolerating)/2) / float64(score.Count)
+	}
+	return score
+}
@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type apdexSuite struct {
+	suite.Suite
+}
+
+func (s *apdexSuite) Test_Apdex() {
+	apdex := NewApdex(500 * time.Millisecond)
+	for _, entry := range []LogEntry{
+		{IP: "10.0.0.1", Path: "/fast", Status: 200, Duration: 100 * time.Millisecond},
+		{IP: "10.0.0.1", Path: "/fast", Status: 200, Duration: 500 * time.Millisecond},
+		{IP: "10.0.0.2", Path: "/slow", Status: 200, Duration: time.Second},
+		{IP: "10.0.0.2", Path: "/slow", Status: 200, Duration: 3 * time.Second},
+		{IP: "10.0.0.3", Path: "/broken", Status: 503, Duration: time.Millisecond},
+		// without a duration
+		{IP: "10.0.0.3", Path: "/fast", Status: 200},
+		// not a request
+		{Path: "/fast", Duration: time.Millisecond},
+	} {
+		apdex.Add(entry)
+	}
+
+	s.Equal(ApdexScore{Count: 5, Satisfied: 2, Tolerating: 1, Frustrated: 2, Score: 0.5}, apdex.Overall())
+	s.Equal([]ApdexScore{
+		{Path: "/broken", Count: 1, Frustrated: 1, Score: 0},
+		{Path: "/slow", Count: 2, Tolerating: 1, Frustrated: 1, Score: 0.25},
+		{Path: "/fast", Count: 2, Satisfied: 2, Score: 1},
+	}, apdex.ByPath(0))
+	s.Len(apdex.ByPath(2), 2)
+}
+
+func (s *apdexSuite) Test_Apdex_Empty() {
+	apdex := NewApdex(time.Second)
+
+	s.Equal(ApdexScore{}, apdex.Overall())
+	s.Empty(apdex.ByPath(10))
+}
+
+func TestApdex(t *testing.T) {
+	suite.Run(t, new(apdexSuite))
+}
@@ -0,0 +1,180 @@
+package logging
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// compressedSuffix is the suffix of the compressed rotations of the log files (e.g. access.log.2.gz),
+// which only Backfill reads.
+const compressedSuffix = ".gz"
+
+// Backfill calls the given function for each log entry within the time range, in order, the way ForEach does,
+// including the ones of the compressed rotations (.gz) ForEach leaves out, e.g. to populate a new analytics store
+// from old logs. The compressed files modified within the time range are read first, from their beginning
+// since they cannot be searched, the oldest first, then the other files the way ForEach reads them.
+// No file within the time range (ErrNoFilesInWindow) is only returned when there's no compressed file either.
+func (logs *Logs) Backfill(ctx context.Context, fn func(LogEntry) error) error {
+	compressed, err := logs.compressedFiles()
+	if err != nil {
+		return err
+	}
+	for _, fi := range compressed {
+		if err := logs.readCompressed(ctx, fi, fn); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	err = logs.ForEach(ctx, fn)
+	if errors.Is(err, ErrNoFilesInWindow) && len(compressed) > 0 {
+		return nil
+	}
+	return err
+}
+
+// compressedFiles lists the compressed rotations of the directory, or of the directories of the hosts,
+// modified within the time range, or which names embed a time within it (see LogsConfig.FilenameTimePattern),
+// the oldest first. The dated subdirectories covering the time range are looked into too
+// (see LogsConfig.Sharded).
+func (logs *Logs) compressedFiles() ([]logFile, error) {
+	fsys := logs.cfg.fileSystem()
+	dirs := logs.directories()
+	if logs.cfg.Sharded {
+		var sharded []string
+		for _, dir := range dirs {
+			shards, err := shardDirs(fsys, dir, logs.nowMinusT(), logs.cfg.end())
+			if err != nil {
+				return nil, err
+			}
+			sharded = append(sharded, shards...)
+		}
+		dirs = sharded
+	}
+
+	var files []logFile
+	for _, dir := range dirs {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range entries {
+			if d.IsDir() || !strings.HasSuffix(d.Name(), compressedSuffix) {
+				continue
+			}
+			fi, err := d.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed since the directory was read
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, logFile{FileInfo: fi, path: filepath.Join(dir, d.Name())})
+		}
+	}
+	// the times embedded in the names of the files, if any, win over their modified times
+	logs.nameTimes(files)
+	files = logs.beforeEnd(files)
+	filtered := files[:0]
+	for _, fi := range files {
+		if !fi.modTime().Before(logs.nowMinusT()) {
+			filtered = append(filtered, fi)
+		}
+	}
+	files = filtered
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].modTime().Before(files[j].modTime())
+	})
+	return files, nil
+}
+
+// readCompressed calls the given function for each log entry of a compressed file within the time range,
+// reading it at the limited rate of the logs, if any (see LogsConfig.MaxReadRate). The offsets of the entries
+// are the ones within the uncompressed content, and the file is read until its first log line after the time range.
+func (logs *Logs) readCompressed(ctx context.Context, fi logFile, fn func(LogEntry) error) error {
+	f, err := logs.cfg.fileSystem().Open(fi.path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	gz, err := gzip.NewReader(limitedReader{r: contextReader{ctx: ctx, r: f}, limiter: logs.limiter})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gz.Close() }()
+
+	// the file only parses the lines, the content being read through the gzip reader
+	file := NewFormatFile(nil, logs.cfg.format(strings.TrimSuffix(fi.path, compressedSuffix)))
+	file.skew, file.loc = logs.skew(fi.path), logs.cfg.TimeZone
+	start, end := logs.nowMinusT(), logs.cfg.end()
+	reader := bufio.NewReader(gz)
+	var offset int64
+	var last time.Time
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" {
+			return nil
+		}
+		lineOffset := offset
+		offset += int64(len(line))
+
+		entry, parseErr := file.parseLogEntry(strings.TrimSpace(line))
+		if parseErr != nil {
+			entry.Time = last
+		}
+		last = entry.Time
+		if entry.Time.Before(start) {
+			continue
+		}
+		if !entry.Time.Before(end) {
+			return nil
+		}
+		entry.Line = strings.TrimRight(line, "\r\n")
+		entry.File = fi.path
+		entry.Offset = lineOffset
+		if !logs.accept(&entry) {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// directories returns the directory of the logs, or the directories of the hosts.
+func (logs *Logs) directories() []string {
+	if len(logs.cfg.Hosts) == 0 {
+		return []string{logs.cfg.Directory}
+	}
+	dirs := make([]string, 0, len(logs.cfg.Hosts))
+	for _, host := range logs.cfg.Hosts {
+		dirs = append(dirs, host.Directory)
+	}
+	return dirs
+}
+
+// limitedReader reads from a reader at the rate of a given limiter, if any.
+type limitedReader struct {
+	r       io.Reader
+	limiter *readLimiter
+}
+
+func (lr limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.limiter.wait(n)
+	return n, err
+}
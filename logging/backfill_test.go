@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const backfillDataDir = "test/backfill"
+
+type backfillSuite struct {
+	suite.Suite
+	t time.Time
+}
+
+func (s *backfillSuite) SetupSuite() {
+	s.Require().NoError(os.MkdirAll(backfillDataDir, 0777))
+	s.writeGzip("access.log.2.gz", `10.0.0.1 - - [01/Mar/2022:10:00:00 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.1 - - [01/Mar/2022:12:00:00 +0000] "GET /b HTTP/1.1" 200 20
+`)
+	s.writeGzip("access.log.3.gz", `10.0.0.1 - - [28/Feb/2022:10:00:00 +0000] "GET /old HTTP/1.1" 200 20
+`)
+	s.Require().NoError(os.WriteFile(filepath.Join(backfillDataDir, "access.log.1"), []byte(`10.0.0.1 - - [02/Mar/2022:10:00:00 +0000] "GET /c HTTP/1.1" 200 20
+`), 0666))
+	s.Require().NoError(os.WriteFile(filepath.Join(backfillDataDir, "access.log"), []byte(`10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /d HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:12:00:00 +0000] "GET /e HTTP/1.1" 200 20
+`), 0666))
+
+	for name, modified := range map[string]string{
+		"access.log.3.gz": "28/Feb/2022:10:00:00 +0000",
+		"access.log.2.gz": "01/Mar/2022:12:00:00 +0000",
+		"access.log.1":    "02/Mar/2022:10:00:00 +0000",
+		"access.log":      "03/Mar/2022:12:00:00 +0000",
+	} {
+		t, err := time.Parse(dateTimeFormat, modified)
+		s.Require().NoError(err)
+		s.Require().NoError(os.Chtimes(filepath.Join(backfillDataDir, name), t, t))
+	}
+	t, err := time.Parse(dateTimeFormat, "03/Mar/2022:11:00:00 +0000")
+	s.Require().NoError(err)
+	s.t = t
+}
+
+func (s *backfillSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(backfillDataDir)))
+}
+
+// writeGzip writes a compressed log file.
+func (s *backfillSuite) writeGzip(name, content string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	s.Require().NoError(err)
+	s.Require().NoError(gz.Close())
+	s.Require().NoError(os.WriteFile(filepath.Join(backfillDataDir, name), buf.Bytes(), 0666))
+}
+
+// paths returns the paths of the log entries of a given reading of the logs of the time range ending at the test time.
+func (s *backfillSuite) paths(window time.Duration, read func(*Logs) func(context.Context, func(LogEntry) error) error) []string {
+	logs, err := New(WithDirectory(backfillDataDir), WithWindow(window), WithEnd(s.t))
+	s.Require().NoError(err)
+	var paths []string
+	s.Require().NoError(read(logs)(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+	return paths
+}
+
+func (s *backfillSuite) Test_Backfill() {
+	// from 01/Mar/2022:11:00:00, leaving out the first line of access.log.2.gz and the ones after the end
+	paths := s.paths(48*time.Hour, func(logs *Logs) func(context.Context, func(LogEntry) error) error { return logs.Backfill })
+	s.Equal([]string{"/b", "/c", "/d"}, paths)
+}
+
+func (s *backfillSuite) Test_ForEach_SkipsCompressed() {
+	paths := s.paths(48*time.Hour, func(logs *Logs) func(context.Context, func(LogEntry) error) error { return logs.ForEach })
+	s.Equal([]string{"/c", "/d"}, paths)
+}
+
+func (s *backfillSuite) Test_Backfill_OnlyCompressed() {
+	logs, err := New(WithDirectory(backfillDataDir), WithWindow(2*time.Hour), WithEnd(s.t.Add(-46*time.Hour)))
+	s.Require().NoError(err)
+	var lines []string
+	s.Require().NoError(logs.Backfill(context.Background(), func(entry LogEntry) error {
+		lines = append(lines, entry.File+" "+entry.Path)
+		return nil
+	}))
+	s.Equal([]string{filepath.Join(backfillDataDir, "access.log.2.gz") + " /b"}, lines)
+}
+
+func TestBackfillSuite(t *testing.T) {
+	suite.Run(t, new(backfillSuite))
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"strings"
+)
+
+const (
+	// bloomBitsPerKey and bloomHashes size the Bloom filters of the index (see LogsConfig.IndexBloom)
+	// for about 1% of false positives.
+	bloomBitsPerKey = 10
+	bloomHashes     = 7
+	// bloomPathDepth is the number of leading path segments whose prefixes are added to the Bloom filters,
+	// e.g. /api/, /api/v1/ and /api/v1/users/ for /api/v1/users/42.
+	bloomPathDepth = 3
+)
+
+// kinds of the keys of the Bloom filters, so an IP and a path prefix never collide.
+const (
+	bloomIP   = 'i'
+	bloomPath = 'p'
+)
+
+// bloomFilter is a Bloom filter of strings, telling whether a string was possibly added to it, or certainly not.
+type bloomFilter []uint64
+
+// newBloomFilter creates a Bloom filter sized for a given number of distinct keys.
+func newBloomFilter(keys int) bloomFilter {
+	words := (keys*bloomBitsPerKey + 63) / 64
+	if words == 0 {
+		words = 1
+	}
+	return make(bloomFilter, words)
+}
+
+// add adds a key of a given kind to the Bloom filter.
+func (b bloomFilter) add(kind byte, key string) {
+	h1, h2 := bloomHash(kind, key)
+	bits := uint32(len(b) * 64)
+	for i := uint32(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % bits
+		b[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// mayContain checks whether a key of a given kind was possibly added to the Bloom filter.
+func (b bloomFilter) mayContain(kind byte, key string) bool {
+	h1, h2 := bloomHash(kind, key)
+	bits := uint32(len(b) * 64)
+	for i := uint32(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % bits
+		if b[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash returns the two halves of the 64-bit FNV-1a hash of a key of a given kind,
+// combined into the hashes of the Bloom filter (double hashing), without allocating.
+func bloomHash(kind byte, key string) (uint32, uint32) {
+	const prime = 1099511628211
+	h := uint64(14695981039346656037)
+	h = (h ^ uint64(kind)) * prime
+	for i := 0; i < len(key); i++ {
+		h = (h ^ uint64(key[i])) * prime
+	}
+	return uint32(h), uint32(h>>32) | 1
+}
+
+// pathPrefixes calls a given function with the prefixes of a path added to the Bloom filters:
+// the ones ending with a slash, up to bloomPathDepth segments, the query string left out.
+func pathPrefixes(path string, fn func(prefix string)) {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	depth := 0
+	for i := 1; i < len(path) && depth < bloomPathDepth; i++ {
+		if path[i] == '/' {
+			fn(path[:i+1])
+			depth++
+		}
+	}
+}
+
+// bloomPathKey returns the longest prefix of a given path prefix added to the Bloom filters (see pathPrefixes),
+// which every path starting with the path prefix has, or "" when there's none, e.g. for /api.
+func bloomPathKey(prefix string) string {
+	key := ""
+	pathPrefixes(prefix, func(p string) { key = p })
+	return key
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type bloomSuite struct {
+	suite.Suite
+}
+
+func (s *bloomSuite) Test_bloomFilter() {
+	bloom := newBloomFilter(1000)
+	for i := 0; i < 1000; i++ {
+		bloom.add(bloomIP, fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+
+	// never a false negative, and about 1% of false positives
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		s.Require().True(bloom.mayContain(bloomIP, fmt.Sprintf("10.0.%d.%d", i/256, i%256)))
+		if bloom.mayContain(bloomIP, fmt.Sprintf("10.1.%d.%d", i/256, i%256)) {
+			falsePositives++
+		}
+	}
+	s.Less(falsePositives, 30)
+	// the kinds of keys never collide
+	s.False(bloom.mayContain(bloomPath, "10.0.0.1"))
+
+	empty := newBloomFilter(0)
+	s.Len(empty, 1)
+	s.False(empty.mayContain(bloomIP, "10.0.0.1"))
+}
+
+func (s *bloomSuite) Test_pathPrefixes() {
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{path: "/", expected: nil},
+		{path: "/api", expected: nil},
+		{path: "/api/", expected: []string{"/api/"}},
+		{path: "/api/v1/users/42/orders", expected: []string{"/api/", "/api/v1/", "/api/v1/users/"}},
+		{path: "/api/v1?next=/api/v2/", expected: []string{"/api/"}},
+		{path: "", expected: nil},
+	}
+	for _, test := range tests {
+		var prefixes []string
+		pathPrefixes(test.path, func(prefix string) { prefixes = append(prefixes, prefix) })
+		s.Equal(test.expected, prefixes, test.path)
+	}
+}
+
+func (s *bloomSuite) Test_bloomPathKey() {
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{prefix: "/", expected: ""},
+		{prefix: "/api", expected: ""},
+		{prefix: "/api/", expected: "/api/"},
+		{prefix: "/api/v", expected: "/api/"},
+		{prefix: "/api/v1/users/42/", expected: "/api/v1/users/"},
+		{prefix: "/api/v1?next=/", expected: "/api/"},
+	}
+	for _, test := range tests {
+		s.Equal(test.expected, bloomPathKey(test.prefix), test.prefix)
+	}
+}
+
+func TestBloom(t *testing.T) {
+	suite.Run(t, new(bloomSuite))
+}
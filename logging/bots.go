@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// defaultBotPatterns are the built-in patterns matching the user agents of well known bots, crawlers
+// and non-browser clients, the more specific ones first, so they're reported instead of generic ones.
+var defaultBotPatterns = []string{
+	"googlebot", "bingbot", "yandexbot", "baiduspider", "duckduckbot", "slurp", "applebot",
+	"facebookexternalhit", "twitterbot", "linkedinbot", "ahrefsbot", "semrushbot", "mj12bot",
+	"dotbot", "petalbot", "bytespider", "gptbot", "ccbot", "uptimerobot", "pingdom",
+	"headlesschrome", "phantomjs", "scrapy", "python-requests", "python-urllib", "go-http-client",
+	"okhttp", "libwww-perl", "curl", "wget", "httpclient", "crawler", "spider", "bot",
+}
+
+// BotClassifier tells bots apart from humans by their user agents, which are matched
+// against a list of case insensitive patterns, e.g. "googlebot" or "curl".
+type BotClassifier struct {
+	patterns []string
+}
+
+// NewBotClassifier creates a BotClassifier using the given patterns on top of the built-in ones.
+// The given patterns are checked first, so they can be more specific than the built-in ones.
+func NewBotClassifier(patterns ...string) *BotClassifier {
+	c := &BotClassifier{patterns: make([]string, 0, len(patterns)+len(defaultBotPatterns))}
+	for _, pattern := range patterns {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			c.patterns = append(c.patterns, pattern)
+		}
+	}
+	c.patterns = append(c.patterns, defaultBotPatterns...)
+	return c
+}
+
+// LoadBotPatterns reads the bot patterns of a given file, one pattern per line.
+// Blank lines and lines starting with # are ignored.
+func LoadBotPatterns(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return patterns, nil
+}
+
+// Classify returns the pattern matching a given user agent, or an empty string for humans.
+// Empty user agents (e.g. FormatCommon logs) are considered humans, since there's no telling.
+func (c *BotClassifier) Classify(userAgent string) string {
+	if userAgent == "" || userAgent == "-" {
+		return ""
+	}
+
+	userAgent = strings.ToLower(userAgent)
+	for _, pattern := range c.patterns {
+		if strings.Contains(userAgent, pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// ExcludeBots is a Filter leaving out the log entries of bots, see LogsConfig.Bots.
+func ExcludeBots(entry LogEntry) bool {
+	return entry.Bot == ""
+}
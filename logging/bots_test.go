@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	botsDataDir = "test/bots"
+	// the patterns are kept outside of the logs directory, so they're not read as logs
+	botPatternsFile = "test/bots.txt"
+)
+
+type botsSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *botsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(botsDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET / HTTP/1.1" 200 20 "-" "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET / HTTP/1.1" 200 20 "-" "Mozilla/5.0 (X11; Linux x86_64; rv:98.0) Gecko/20100101 Firefox/98.0"
+10.0.0.3 - - [03/Mar/2022:02:44:30 +0000] "GET /health HTTP/1.1" 200 20 "-" "kube-probe/1.23"
+10.0.0.4 - - [03/Mar/2022:02:44:40 +0000] "GET /robots.txt HTTP/1.1" 200 20 "-" "curl/7.79.1"
+`, t)
+	s.Require().NoError(os.WriteFile(botPatternsFile, []byte(`# internal health checks
+kube-probe
+
+`), 0666))
+}
+
+func (s *botsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(botsDataDir)))
+}
+
+func (s *botsSuite) Test_Classify() {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{
+			name:      "Specific Bot",
+			userAgent: "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			expected:  "bingbot",
+		},
+		{
+			name:      "Generic Bot",
+			userAgent: "SomeNewBot/0.1",
+			expected:  "bot",
+		},
+		{
+			name:      "Custom Pattern",
+			userAgent: "kube-probe/1.23",
+			expected:  "kube-probe",
+		},
+		{
+			name:      "Browser",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 Safari/605.1.15",
+		},
+		{
+			name:      "Unknown User Agent",
+			userAgent: "-",
+		},
+	}
+	bots := NewBotClassifier("Kube-Probe ")
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.Equal(test.expected, bots.Classify(test.userAgent))
+		})
+	}
+}
+
+func (s *botsSuite) Test_LoadBotPatterns() {
+	patterns, err := LoadBotPatterns(botPatternsFile)
+	s.NoError(err)
+	s.Equal([]string{"kube-probe"}, patterns)
+
+	_, err = LoadBotPatterns(filepath.Join(botsDataDir, "missing.txt"))
+	s.Error(err)
+}
+
+func (s *botsSuite) Test_ExcludeBots() {
+	patterns, err := LoadBotPatterns(botPatternsFile)
+	s.Require().NoError(err)
+	logs, err := New(
+		WithDirectory(botsDataDir),
+		WithWindow(time.Minute),
+		WithFormat(FormatCombined),
+		WithBots(NewBotClassifier(patterns...)),
+		WithFilter(ExcludeBots),
+	)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-time.Minute)
+	}
+
+	var ips []string
+	err = logs.ForEach(context.Background(), func(entry LogEntry) error {
+		ips = append(ips, entry.IP)
+		return nil
+	})
+
+	s.NoError(err)
+	s.Equal([]string{"10.0.0.2"}, ips)
+}
+
+func (s *botsSuite) Test_TopBots() {
+	logs, err := New(
+		WithDirectory(botsDataDir),
+		WithWindow(time.Minute),
+		WithFormat(FormatCombined),
+		WithBots(NewBotClassifier()),
+	)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-time.Minute)
+	}
+
+	top, err := logs.Top(context.Background(), TopBots)
+	s.Require().NoError(err)
+	stats, err := logs.Stats(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal([]Count{{Value: "curl", Count: 1}, {Value: "googlebot", Count: 1}}, top.Ranked(TopBots, 0))
+	s.Equal(2, stats.Bots)
+	s.Equal(0.5, stats.BotRate())
+}
+
+func TestBots(t *testing.T) {
+	suite.Run(t, new(botsSuite))
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Cache computes the cache hit ratio of the requests logged within the last N minutes, overall and per path,
+// from their cache status (see LogEntry.Cache and FormatCombinedCache), e.g. to tune a cache or a CDN from the logs
+// of the origin server. Only the requests with a known cache status (see CacheHit) are taken into account.
+type Cache struct {
+	overall CacheRatio
+	paths   map[string]*CacheRatio
+}
+
+// CacheRatio is the cache hit ratio of a number of requests, between 0 (all missed) and 1 (all hit).
+type CacheRatio struct {
+	// Path is the path of the requests, empty for all the requests.
+	Path     string  `json:"path,omitempty"`
+	Requests int     `json:"requests"`
+	Hits     int     `json:"hits"`
+	Misses   int     `json:"misses"`
+	Ratio    float64 `json:"ratio"`
+}
+
+// NewCache creates an empty Cache.
+func NewCache() *Cache {
+	return &Cache{paths: make(map[string]*CacheRatio)}
+}
+
+// Cache reads the log entries that happened within the last N minutes and computes their cache hit ratio.
+// The ratios are returned even along with an error (e.g. ErrNoFilesInWindow), accounting for the entries read until then.
+func (logs *Logs) Cache(ctx context.Context) (*Cache, error) {
+	cache := NewCache()
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		cache.Add(entry)
+		return nil
+	})
+
+	return cache, err
+}
+
+// Add accounts for the cache status of a given log entry, ignoring the ones without a known cache status.
+func (cache *Cache) Add(entry LogEntry) {
+	if entry.IP == "" {
+		return
+	}
+	hit, ok := CacheHit(entry.Cache)
+	if !ok {
+		return
+	}
+
+	ratio, ok := cache.paths[entry.Path]
+	if !ok {
+		ratio = &CacheRatio{Path: entry.Path}
+		cache.paths[entry.Path] = ratio
+	}
+	for _, r := range []*CacheRatio{&cache.overall, ratio} {
+		r.Requests++
+		if hit {
+			r.Hits++
+		} else {
+			r.Misses++
+		}
+	}
+}
+
+// Result returns the cache hit ratio of all the requests, followed by the ones of every path (see ByPath).
+func (cache *Cache) Result() interface{} {
+	return append([]CacheRatio{cache.Overall()}, cache.ByPath(0)...)
+}
+
+// Overall returns the cache hit ratio of all the requests.
+func (cache *Cache) Overall() CacheRatio {
+	return cache.overall.computed()
+}
+
+// ByPath returns the cache hit ratio of the requests of every path, the ones missing the cache the most first,
+// as they're the ones weighing the most on the origin server, or only the n first ones when n is positive.
+// Ties are ordered by path.
+func (cache *Cache) ByPath(n int) []CacheRatio {
+	paths := make([]CacheRatio, 0, len(cache.paths))
+	for _, ratio := range cache.paths {
+		paths = append(paths, ratio.computed())
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Misses == paths[j].Misses {
+			return paths[i].Path < paths[j].Path
+		}
+		return paths[i].Misses > paths[j].Misses
+	})
+	if n > 0 && n < len(paths) {
+		paths = paths[:n]
+	}
+
+	return paths
+}
+
+// computed returns a copy of the ratio with its Ratio computed, which is 0 without any request.
+func (ratio CacheRatio) computed() CacheRatio {
+	if ratio.Requests > 0 {
+		ratio.Ratio = float64(ratio.Hits) / float64(ratio.Requests)
+	}
+	return ratio
+}
+
+// cacheMisses and cacheHits are the cache statuses, or parts of them, telling the misses and the hits apart,
+// the misses being checked first (e.g. TCP_REFRESH_MISS).
+var (
+	cacheMisses = []string{"MISS", "PASS", "BYPASS", "EXPIRED", "DYNAMIC"}
+	cacheHits   = []string{"HIT", "STALE", "REVALIDATED", "UPDATING"}
+)
+
+// CacheHit tells whether a response was served from the cache given its cache status, e.g. HIT or MISS (Varnish, Fastly),
+// "Hit from cloudfront" (CloudFront), TCP_MEM_HIT/200 (Squid) or REVALIDATED (Cloudflare), ignoring the case.
+// Only the first status of a chain of caches is looked at, e.g. HIT for "HIT, MISS", the one of the cache closest to the origin.
+// It returns false when the cache status isn't known, e.g. - for the responses without any.
+func CacheHit(status string) (hit bool, ok bool) {
+	if i := strings.IndexAny(status, " ,;/"); i >= 0 {
+		status = status[:i]
+	}
+	status = strings.ToUpper(status)
+	for _, miss := range cacheMisses {
+		if strings.Contains(status, miss) {
+			return false, true
+		}
+	}
+	for _, hit := range cacheHits {
+		if strings.Contains(status, hit) {
+			return true, true
+		}
+	}
+	return false, false
+}
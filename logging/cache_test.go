@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const cacheDataDir = "test/cache"
+
+// the cache hit ratios are an aggregation
+var _ Aggregator = (*Cache)(nil)
+
+type cacheSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *cacheSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(cacheDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20 "-" "curl/7.79.1" "HIT"
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /a HTTP/1.1" 200 20 "-" "curl/7.79.1" "MISS"
+10.0.0.1 - - [03/Mar/2022:02:44:30 +0000] "GET /b HTTP/1.1" 200 10 "-" "curl/7.79.1" "MISS, HIT"
+10.0.0.1 - - [03/Mar/2022:02:44:35 +0000] "GET /b HTTP/1.1" 200 10 "-" "curl/7.79.1" "TCP_REFRESH_MISS/200"
+10.0.0.1 - - [03/Mar/2022:02:44:40 +0000] "GET /c HTTP/1.1" 200 20 "-" "curl/7.79.1" "Hit from cloudfront"
+10.0.0.1 - - [03/Mar/2022:02:44:50 +0000] "POST /d HTTP/1.1" 200 20 "-" "curl/7.79.1" "-"
+this line cannot be parsed
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(cacheDataDir), WithFormat(FormatCombinedCache))
+}
+
+func (s *cacheSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(cacheDataDir)))
+}
+
+func (s *cacheSuite) Test_Cache() {
+	cache, err := s.logs.Cache(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(CacheRatio{Requests: 5, Hits: 2, Misses: 3, Ratio: 0.4}, cache.Overall())
+	s.Equal([]CacheRatio{
+		{Path: "/b", Requests: 2, Misses: 2},
+		{Path: "/a", Requests: 2, Hits: 1, Misses: 1, Ratio: 0.5},
+		{Path: "/c", Requests: 1, Hits: 1, Ratio: 1},
+	}, cache.ByPath(0))
+	s.Equal([]CacheRatio{{Path: "/b", Requests: 2, Misses: 2}}, cache.ByPath(1))
+	s.Len(cache.Result(), 4)
+}
+
+func (s *cacheSuite) Test_CacheHit() {
+	tests := []struct {
+		status  string
+		hit, ok bool
+	}{
+		{status: "HIT", hit: true, ok: true},
+		{status: "hit", hit: true, ok: true},
+		{status: "TCP_MEM_HIT/200", hit: true, ok: true},
+		{status: "RefreshHit from cloudfront", hit: true, ok: true},
+		{status: "STALE", hit: true, ok: true},
+		{status: "REVALIDATED", hit: true, ok: true},
+		{status: "HIT, MISS", hit: true, ok: true},
+		{status: "MISS", ok: true},
+		{status: "TCP_REFRESH_MISS", ok: true},
+		{status: "PASS", ok: true},
+		{status: "BYPASS", ok: true},
+		{status: "EXPIRED", ok: true},
+		{status: "DYNAMIC", ok: true},
+		{status: "-"},
+		{status: ""},
+		{status: "TCP_DENIED/403"},
+	}
+	for _, test := range tests {
+		hit, ok := CacheHit(test.status)
+		s.Equal(test.hit, hit, test.status)
+		s.Equal(test.ok, ok, test.status)
+	}
+}
+
+func TestCache(t *testing.T) {
+	suite.Run(t, new(cacheSuite))
+}
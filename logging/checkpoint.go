@@ -0,0 +1,201 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpointRetention is how long the checkpoint of a file nothing was shipped from is kept,
+// e.g. once the file is rotated away.
+const checkpointRetention = 7 * 24 * time.Hour
+
+// FileID identifies a log file whatever its name, e.g. once renamed by a rotation.
+type FileID struct {
+	Device uint64 `json:"device"`
+	Inode  uint64 `json:"inode"`
+}
+
+// Checkpoint is how far a log file has been shipped, see Checkpoints.
+type Checkpoint struct {
+	FileID
+	// Path is the path the file was read from, for information only.
+	Path string `json:"path"`
+	// Offset is the offset of the last log line shipped.
+	Offset int64 `json:"offset"`
+	// Time is when the checkpoint was last moved.
+	Time time.Time `json:"time"`
+}
+
+// Checkpoints persist how far every log file has been shipped to a file, so the shipping resumes after a restart
+// without gaps nor massive re-sends (see LogsConfig.Checkpoints). The checkpoints are only moved once the log
+// entries are written to the sink (see Commit), so every log entry is delivered at least once: the entries
+// written but not committed yet when the process stops are shipped again.
+// Checkpoints is safe for concurrent use.
+type Checkpoints struct {
+	path string
+	mu   sync.Mutex
+	// files are the checkpoints as committed, and resume the ones loaded, which the reading resumes after
+	files  map[FileID]Checkpoint
+	resume map[FileID]Checkpoint
+	// saved is when the checkpoints loaded were saved, zero when there were none
+	saved time.Time
+}
+
+// checkpointsFile is the content of a checkpoints file.
+type checkpointsFile struct {
+	Saved time.Time    `json:"saved"`
+	Files []Checkpoint `json:"files"`
+}
+
+// LoadCheckpoints loads the checkpoints of a given file, which are empty when the file doesn't exist yet.
+func LoadCheckpoints(path string) (*Checkpoints, error) {
+	c := &Checkpoints{path: path, files: make(map[FileID]Checkpoint), resume: make(map[FileID]Checkpoint)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var content checkpointsFile
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	c.saved = content.Saved
+	for _, checkpoint := range content.Files {
+		c.files[checkpoint.FileID] = checkpoint
+		c.resume[checkpoint.FileID] = checkpoint
+	}
+	return c, nil
+}
+
+// Commit moves the checkpoints of the files of the given log entries, which have been shipped, to the last of them.
+// The entries are expected in the order they were read, so a file truncated since (e.g. by copytruncate)
+// has its checkpoint moved back.
+func (c *Checkpoints) Commit(entries []LogEntry) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range entries {
+		if entry.FileID == (FileID{}) {
+			continue
+		}
+		c.files[entry.FileID] = Checkpoint{FileID: entry.FileID, Path: entry.File, Offset: entry.Offset, Time: now}
+	}
+}
+
+// Save writes the checkpoints to their file, replacing it atomically so a crash never leaves it partially written.
+// The checkpoints not moved within checkpointRetention are left out.
+func (c *Checkpoints) Save() error {
+	now := time.Now()
+	c.mu.Lock()
+	content := checkpointsFile{Saved: now, Files: make([]Checkpoint, 0, len(c.files))}
+	for id, checkpoint := range c.files {
+		if now.Sub(checkpoint.Time) > checkpointRetention {
+			delete(c.files, id)
+			continue
+		}
+		content.Files = append(content.Files, checkpoint)
+	}
+	c.mu.Unlock()
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// Get returns the checkpoint of a given file as committed, if any.
+func (c *Checkpoints) Get(id FileID) (Checkpoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	checkpoint, ok := c.files[id]
+	return checkpoint, ok
+}
+
+// shipped checks whether a given log entry was shipped before the checkpoints were loaded,
+// i.e. it sits at or before the checkpoint of its file.
+func (c *Checkpoints) shipped(entry LogEntry) bool {
+	if c == nil || entry.FileID == (FileID{}) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	checkpoint, ok := c.resume[entry.FileID]
+	return ok && entry.Offset <= checkpoint.Offset
+}
+
+// opened forgets the checkpoint loaded for a given file when the file has been truncated below it since (e.g. by copytruncate),
+// so its lines are all shipped again.
+func (c *Checkpoints) opened(id FileID, size int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if checkpoint, ok := c.resume[id]; ok && size <= checkpoint.Offset {
+		delete(c.resume, id)
+	}
+}
+
+// forget forgets the checkpoint loaded for a given file, e.g. once it's truncated while followed.
+func (c *Checkpoints) forget(id FileID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.resume, id)
+}
+
+// resumeOffset returns the offset the reading of a given file present when following starts resumes at (see Logs.Follow):
+// the line after its checkpoint, its beginning when it was created or modified since the checkpoints were saved
+// (i.e. while not shipping), or its end otherwise, e.g. for the files not shipped before.
+func (c *Checkpoints) resumeOffset(file File, info os.FileInfo) (int64, error) {
+	c.mu.Lock()
+	checkpoint, ok := c.resume[file.id]
+	saved := c.saved
+	c.mu.Unlock()
+	switch {
+	case ok:
+		return lineEnd(file, checkpoint.Offset)
+	case !saved.IsZero() && info.ModTime().After(saved):
+		return 0, nil
+	default:
+		return file.end()
+	}
+}
+
+// lineEnd returns the offset of the line following the one at a given offset of a file.
+func lineEnd(file File, offset int64) (int64, error) {
+	reader := getReader(io.NewSectionReader(file, offset, 1<<62))
+	defer putReader(reader)
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	return offset + int64(len(line)), nil
+}
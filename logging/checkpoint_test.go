@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const checkpointDataDir = "test/checkpoint/logs"
+
+type checkpointSuite struct {
+	suite.Suite
+	t           time.Time
+	checkpoints string
+}
+
+func (s *checkpointSuite) SetupTest() {
+	s.Require().NoError(os.MkdirAll(checkpointDataDir, 0777))
+	s.writeLog(`10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:44:30 +0000] "GET /c HTTP/1.1" 200 20
+`)
+	s.t = parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.checkpoints = filepath.Join(filepath.Dir(checkpointDataDir), "checkpoints.json")
+}
+
+func (s *checkpointSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(filepath.Dir(checkpointDataDir))))
+}
+
+// writeLog replaces the content of the log file, keeping the file (e.g. truncated by copytruncate).
+func (s *checkpointSuite) writeLog(content string) {
+	f, err := os.OpenFile(filepath.Join(checkpointDataDir, "access.log"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	_, err = f.WriteString(content)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+	s.touch()
+}
+
+// appendLog appends lines to the log file.
+func (s *checkpointSuite) appendLog(lines string) {
+	f, err := os.OpenFile(filepath.Join(checkpointDataDir, "access.log"), os.O_APPEND|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	_, err = f.WriteString(lines)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+	s.touch()
+}
+
+// touch sets the modified time of the log file to the end of the window.
+func (s *checkpointSuite) touch() {
+	if !s.t.IsZero() {
+		s.Require().NoError(os.Chtimes(filepath.Join(checkpointDataDir, "access.log"), s.t, s.t))
+	}
+}
+
+// load loads the checkpoints of the test.
+func (s *checkpointSuite) load() *Checkpoints {
+	checkpoints, err := LoadCheckpoints(s.checkpoints)
+	s.Require().NoError(err)
+	return checkpoints
+}
+
+// read returns the log entries of the last 2 minutes of the test data, resuming after the given checkpoints.
+func (s *checkpointSuite) read(checkpoints *Checkpoints) []LogEntry {
+	logs, err := New(WithDirectory(checkpointDataDir), WithWindow(2*time.Minute), WithEnd(s.t), WithCheckpoints(checkpoints))
+	s.Require().NoError(err)
+	var entries []LogEntry
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+	return entries
+}
+
+// entryPaths returns the paths of the given log entries.
+func entryPaths(entries []LogEntry) []string {
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
+
+func (s *checkpointSuite) Test_ForEach_ResumesAfterCheckpoints() {
+	checkpoints := s.load()
+	entries := s.read(checkpoints)
+	s.Equal([]string{"/a", "/b", "/c"}, entryPaths(entries))
+
+	// only the first 2 entries were shipped
+	checkpoints.Commit(entries[:2])
+	s.Require().NoError(checkpoints.Save())
+	s.appendLog(`10.0.0.1 - - [03/Mar/2022:02:44:40 +0000] "GET /d HTTP/1.1" 200 20
+`)
+	s.Equal([]string{"/c", "/d"}, entryPaths(s.read(s.load())))
+}
+
+func (s *checkpointSuite) Test_ForEach_TruncatedSinceCheckpoints() {
+	checkpoints := s.load()
+	checkpoints.Commit(s.read(checkpoints))
+	s.Require().NoError(checkpoints.Save())
+
+	s.writeLog(`10.0.0.1 - - [03/Mar/2022:02:44:50 +0000] "GET /e HTTP/1.1" 200 20
+`)
+	s.Equal([]string{"/e"}, entryPaths(s.read(s.load())))
+}
+
+func (s *checkpointSuite) Test_Follow_ResumesAfterCheckpoints() {
+	checkpoints := s.load()
+	entries := s.read(checkpoints)
+	checkpoints.Commit(entries[:1])
+	s.Require().NoError(checkpoints.Save())
+
+	// the lines following the checkpoint are followed, instead of the ones written from now on
+	logs, err := New(WithDirectory(checkpointDataDir), WithCheckpoints(s.load()), WithPollInterval(10*time.Millisecond))
+	s.Require().NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var followed []LogEntry
+	err = logs.Follow(ctx, func(entry LogEntry) error {
+		followed = append(followed, entry)
+		if len(followed) == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"/b", "/c"}, entryPaths(followed))
+}
+
+func (s *checkpointSuite) Test_Save_Reload() {
+	checkpoints := s.load()
+	entries := s.read(checkpoints)
+	checkpoints.Commit(entries)
+	s.Require().NoError(checkpoints.Save())
+
+	checkpoint, ok := s.load().Get(entries[2].FileID)
+	s.Require().True(ok)
+	s.Equal(entries[2].Offset, checkpoint.Offset)
+	s.Equal(entries[2].File, checkpoint.Path)
+	s.NotEqual(FileID{}, checkpoint.FileID)
+}
+
+func TestCheckpointSuite(t *testing.T) {
+	suite.Run(t, new(checkpointSuite))
+}
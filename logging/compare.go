@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Comparison compares the requests of two time windows, e.g. the last hour and the hour before it
+// (see LogsConfig.End), to make regressions obvious.
+type Comparison struct {
+	Current  *Stats
+	Previous *Stats
+	// current and previous hold the number of requests by path
+	current, previous *Top
+}
+
+// PathDelta is the number of requests of a path in both windows of a Comparison.
+type PathDelta struct {
+	Path     string `json:"path"`
+	Current  int64  `json:"current"`
+	Previous int64  `json:"previous"`
+	Delta    int64  `json:"delta"`
+}
+
+// NewComparison creates an empty Comparison of two time windows of the given sizes.
+func NewComparison(currentWindow, previousWindow time.Duration) *Comparison {
+	return &Comparison{
+		Current:  NewStats(currentWindow),
+		Previous: NewStats(previousWindow),
+		current:  NewTop(TopPaths),
+		previous: NewTop(TopPaths),
+	}
+}
+
+// Compare reads the log entries of both the current and the previous logs, comparing them.
+// Windows without any log files (ErrNoFilesInWindow) are compared as windows without requests.
+// The comparison is returned even along with an error, accounting for the entries read until then.
+func Compare(ctx context.Context, current, previous *Logs) (*Comparison, error) {
+	c := NewComparison(current.cfg.window(), previous.cfg.window())
+	err := current.ForEach(ctx, func(entry LogEntry) error {
+		c.AddCurrent(entry)
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNoFilesInWindow) {
+		return c, err
+	}
+	err = previous.ForEach(ctx, func(entry LogEntry) error {
+		c.AddPrevious(entry)
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNoFilesInWindow) {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// AddCurrent accounts for a given log entry of the current window, ignoring the ones that are not requests.
+func (c *Comparison) AddCurrent(entry LogEntry) {
+	c.Current.Add(entry)
+	c.current.Add(entry)
+}
+
+// AddPrevious accounts for a given log entry of the previous window, ignoring the ones that are not requests.
+func (c *Comparison) AddPrevious(entry LogEntry) {
+	c.Previous.Add(entry)
+	c.previous.Add(entry)
+}
+
+// RequestsChange returns the relative change of the number of requests, e.g. 0.5 for 50% more requests,
+// or 0 when there were no requests in the previous window.
+func (c *Comparison) RequestsChange() float64 {
+	if c.Previous.Requests == 0 {
+		return 0
+	}
+	return float64(c.Current.Requests-c.Previous.Requests) / float64(c.Previous.Requests)
+}
+
+// ErrorRateDelta returns the difference between the error rates of the windows, e.g. 0.01 for one more point.
+func (c *Comparison) ErrorRateDelta() float64 {
+	return c.Current.ErrorRate() - c.Previous.ErrorRate()
+}
+
+// Paths returns the n paths which number of requests went up the most and the n ones which went down the most,
+// the biggest change first, or all of them when n is not positive. Ties are ordered by path.
+func (c *Comparison) Paths(n int) (up, down []PathDelta) {
+	current, previous := c.current.counts[TopPaths], c.previous.counts[TopPaths]
+	for path, count := range current {
+		if delta := count - previous[path]; delta > 0 {
+			up = append(up, PathDelta{Path: path, Current: count, Previous: previous[path], Delta: delta})
+		}
+	}
+	for path, count := range previous {
+		if delta := current[path] - count; delta < 0 {
+			down = append(down, PathDelta{Path: path, Current: current[path], Previous: count, Delta: delta})
+		}
+	}
+
+	sortDeltas(up, func(i, j int) bool { return up[i].Delta > up[j].Delta })
+	sortDeltas(down, func(i, j int) bool { return down[i].Delta < down[j].Delta })
+	if n > 0 && n < len(up) {
+		up = up[:n]
+	}
+	if n > 0 && n < len(down) {
+		down = down[:n]
+	}
+
+	return up, down
+}
+
+// sortDeltas sorts the given path deltas by the given order of their deltas, ordering the ties by path.
+func sortDeltas(deltas []PathDelta, less func(i, j int) bool) {
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].Delta == deltas[j].Delta {
+			return deltas[i].Path < deltas[j].Path
+		}
+		return less(i, j)
+	})
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const compareDataDir = "test/compare"
+
+type compareSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *compareSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(compareDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:43:10 +0000] "GET /home HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:02:43:20 +0000] "GET /home HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:43:30 +0000] "GET /blog HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:43:40 +0000] "GET /blog HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /home HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:02:44:20 +0000] "GET /shop HTTP/1.1" 500 10
+10.0.0.3 - - [03/Mar/2022:02:44:30 +0000] "GET /shop HTTP/1.1" 500 10
+10.0.0.4 - - [03/Mar/2022:02:44:40 +0000] "GET /shop HTTP/1.1" 200 10
+10.0.0.4 - - [03/Mar/2022:02:44:50 +0000] "GET /blog HTTP/1.1" 200 10
+10.0.0.5 - - [03/Mar/2022:02:45:10 +0000] "GET /home HTTP/1.1" 200 10
+`, t)
+}
+
+func (s *compareSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(compareDataDir)))
+}
+
+func (s *compareSuite) logs(end time.Time) *Logs {
+	logs, err := New(WithDirectory(compareDataDir), WithWindow(time.Minute), WithEnd(end))
+	s.Require().NoError(err)
+	return logs
+}
+
+func (s *compareSuite) Test_End() {
+	var lines []string
+	err := s.logs(s.testTime.Add(-time.Minute)).ForEach(context.Background(), func(entry LogEntry) error {
+		lines = append(lines, entry.Path)
+		return nil
+	})
+
+	s.NoError(err)
+	s.Equal([]string{"/home", "/home", "/blog", "/blog"}, lines)
+}
+
+func (s *compareSuite) Test_Compare() {
+	c, err := Compare(context.Background(), s.logs(s.testTime), s.logs(s.testTime.Add(-time.Minute)))
+	s.Require().NoError(err)
+
+	s.Equal(5, c.Current.Requests)
+	s.Equal(4, c.Previous.Requests)
+	s.Equal(0.25, c.RequestsChange())
+	s.Equal(0.4, c.ErrorRateDelta())
+
+	up, down := c.Paths(0)
+	s.Equal([]PathDelta{{Path: "/shop", Current: 3, Previous: 0, Delta: 3}}, up)
+	s.Equal([]PathDelta{
+		{Path: "/blog", Current: 1, Previous: 2, Delta: -1},
+		{Path: "/home", Current: 1, Previous: 2, Delta: -1},
+	}, down)
+	_, down = c.Paths(1)
+	s.Len(down, 1)
+}
+
+func (s *compareSuite) Test_Compare_NoFilesInWindow() {
+	c, err := Compare(context.Background(), s.logs(s.testTime), s.logs(s.testTime.Add(time.Hour)))
+	s.Require().NoError(err)
+
+	s.Equal(0, c.Previous.Requests)
+	s.Equal(0.0, c.RequestsChange())
+}
+
+func TestCompare(t *testing.T) {
+	suite.Run(t, new(compareSuite))
+}
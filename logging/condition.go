@@ -0,0 +1,190 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// operators are the comparison operators of the conditions, the ones being a prefix of others last.
+var operators = []string{"=~", "!~", "==", "!=", ">=", "<=", ">", "<"}
+
+// ParseCondition parses a conjunction (&&) of comparisons of the fields of the log entries, e.g.
+// status>=500 && path=~^/api, into a Filter, matching all the log entries when empty:
+//   - status (or a class, e.g. 5xx), size and duration (e.g. 1s) are compared with ==, !=, <, <=, > and >=.
+//   - method, path, ip, vhost, bot, cache (see LogEntry.Cache), tls_protocol, tls_cipher, host (see LogEntry.Host) and the parameters of the query string,
+//     e.g. param("utm_source") (see LogEntry.Param), are compared with ==, != and regular expressions (=~ and !~).
+//
+// The values may be quoted, e.g. param("utm_source") == "newsletter".
+func ParseCondition(condition string) (Filter, error) {
+	var filters []Filter
+	if strings.TrimSpace(condition) != "" {
+		for _, comparison := range strings.Split(condition, "&&") {
+			filter, err := parseComparison(strings.TrimSpace(comparison))
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+	}
+	return func(entry LogEntry) bool {
+		for _, filter := range filters {
+			if !filter(entry) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// parseComparison parses a comparison of a field of the log entries with a value, e.g. status>=500.
+func parseComparison(comparison string) (Filter, error) {
+	i := 0
+	for i < len(comparison) && (comparison[i] == '_' || comparison[i] >= 'a' && comparison[i] <= 'z') {
+		i++
+	}
+	field, rest := comparison[:i], strings.TrimSpace(comparison[i:])
+	param := ""
+	if field == "param" {
+		end := strings.IndexByte(rest, ')')
+		if !strings.HasPrefix(rest, "(") || end < 0 {
+			return nil, fmt.Errorf("invalid comparison '%s': param must be given the name of a parameter, e.g. param(\"utm_source\")", comparison)
+		}
+		param = strings.TrimSpace(rest[1:end])
+		if unquoted, err := strconv.Unquote(param); err == nil {
+			param = unquoted
+		}
+		if param == "" {
+			return nil, fmt.Errorf("invalid comparison '%s': param must be given the name of a parameter, e.g. param(\"utm_source\")", comparison)
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+	op := ""
+	for _, o := range operators {
+		if strings.HasPrefix(rest, o) {
+			op = o
+			break
+		}
+	}
+	if field == "" || op == "" {
+		return nil, fmt.Errorf("invalid comparison '%s': must be a field compared with a value, e.g. status>=500", comparison)
+	}
+	value := strings.TrimSpace(rest[len(op):])
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+
+	switch field {
+	case "status", "size", "duration":
+		return numberFilter(comparison, field, op, value)
+	case "method", "path", "ip", "vhost", "bot", "cache", "tls_protocol", "tls_cipher", "host":
+		return stringFilter(comparison, field, stringField(field), op, value)
+	case "param":
+		return stringFilter(comparison, field, func(entry LogEntry) string { return entry.Param(param) }, op, value)
+	default:
+		return nil, fmt.Errorf("invalid comparison '%s': unknown field '%s'", comparison, field)
+	}
+}
+
+// numberFilter returns a Filter comparing a numeric field of the log entries with a given value.
+func numberFilter(comparison, field, op, value string) (Filter, error) {
+	if op == "=~" || op == "!~" {
+		return nil, fmt.Errorf("invalid comparison '%s': %s can't be matched with a regular expression", comparison, field)
+	}
+
+	var get func(LogEntry) float64
+	var n float64
+	var err error
+	switch field {
+	case "status":
+		if (op == "==" || op == "!=") && len(value) == 3 && strings.EqualFold(value[1:], "xx") {
+			class, err := StatusFilter(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid comparison '%s': %v", comparison, err)
+			}
+			if op == "!=" {
+				return func(entry LogEntry) bool { return !class(entry) }, nil
+			}
+			return class, nil
+		}
+		get = func(entry LogEntry) float64 { return float64(entry.Status) }
+		n, err = strconv.ParseFloat(value, 64)
+	case "size":
+		get = func(entry LogEntry) float64 { return float64(entry.Size) }
+		n, err = strconv.ParseFloat(value, 64)
+	default:
+		get = func(entry LogEntry) float64 { return float64(entry.Duration) }
+		var d time.Duration
+		d, err = time.ParseDuration(value)
+		n = float64(d)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid comparison '%s': invalid %s '%s'", comparison, field, value)
+	}
+	return func(entry LogEntry) bool {
+		return compareNumbers(get(entry), op, n)
+	}, nil
+}
+
+// compareNumbers compares two numbers with a given operator.
+func compareNumbers(a float64, op string, b float64) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	default:
+		return a <= b
+	}
+}
+
+// stringField returns the getter of a given textual field of the log entries.
+func stringField(field string) func(LogEntry) string {
+	switch field {
+	case "method":
+		return func(entry LogEntry) string { return entry.Method }
+	case "path":
+		return func(entry LogEntry) string { return entry.Path }
+	case "ip":
+		return func(entry LogEntry) string { return entry.IP }
+	case "vhost":
+		return func(entry LogEntry) string { return entry.VHost }
+	case "cache":
+		return func(entry LogEntry) string { return entry.Cache }
+	case "tls_protocol":
+		return func(entry LogEntry) string { return entry.TLSProtocol }
+	case "tls_cipher":
+		return func(entry LogEntry) string { return entry.TLSCipher }
+	case "host":
+		return func(entry LogEntry) string { return entry.Host }
+	default:
+		return func(entry LogEntry) string { return entry.Bot }
+	}
+}
+
+// stringFilter returns a Filter comparing a textual field of the log entries with a given value or regular expression.
+func stringFilter(comparison, field string, get func(LogEntry) string, op, value string) (Filter, error) {
+	switch op {
+	case "==":
+		return func(entry LogEntry) bool { return get(entry) == value }, nil
+	case "!=":
+		return func(entry LogEntry) bool { return get(entry) != value }, nil
+	case "=~", "!~":
+		regEx, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid comparison '%s': %v", comparison, err)
+		}
+		negate := op == "!~"
+		return func(entry LogEntry) bool { return regEx.MatchString(get(entry)) != negate }, nil
+	default:
+		return nil, fmt.Errorf("invalid comparison '%s': %s can only be compared with ==, !=, =~ and !~", comparison, field)
+	}
+}
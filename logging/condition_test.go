@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type conditionSuite struct {
+	suite.Suite
+}
+
+func (s *conditionSuite) Test_ParseCondition() {
+	entry := LogEntry{IP: "10.0.0.1", Method: "POST", Path: "/api/users?utm_source=newsletter&q=a%20b", Status: 503, Size: 2048, Duration: 1500 * time.Millisecond, VHost: "shop.example.com", TLSProtocol: "TLSv1.2", TLSCipher: "ECDHE-RSA-AES128-GCM-SHA256"}
+	tests := []struct {
+		condition string
+		match     bool
+	}{
+		{condition: "", match: true},
+		{condition: "status>=500", match: true},
+		{condition: "status==5xx", match: true},
+		{condition: "status!=5xx", match: false},
+		{condition: "status==503 && method==POST", match: true},
+		{condition: "status==503 && method==GET", match: false},
+		{condition: `path=~"^/api/"`, match: true},
+		{condition: "path!~^/api/", match: false},
+		{condition: "duration>1s", match: true},
+		{condition: "duration<=1s", match: false},
+		{condition: "size<1024", match: false},
+		{condition: "vhost==shop.example.com && ip!=10.0.0.2", match: true},
+		{condition: "bot==", match: true},
+		{condition: "cache!~(?i)hit", match: true},
+		{condition: "tls_protocol==TLSv1.2 && tls_cipher=~GCM", match: true},
+		{condition: `tls_cipher=~"RC4|DES"`, match: false},
+		{condition: `param("utm_source") == "newsletter"`, match: true},
+		{condition: `param(utm_source)!=newsletter`, match: false},
+		{condition: `param("q") == "a b" && status==5xx`, match: true},
+		{condition: `param("utm_medium") == ""`, match: true},
+		{condition: `param("utm_source") =~ ^news`, match: true},
+	}
+	for _, test := range tests {
+		s.Run(test.condition, func() {
+			match, err := ParseCondition(test.condition)
+			s.Require().NoError(err)
+			s.Equal(test.match, match(entry))
+		})
+	}
+}
+
+func (s *conditionSuite) Test_ParseCondition_Errors() {
+	tests := map[string]string{
+		"status>=5o0":         "invalid comparison 'status>=5o0': invalid status '5o0'",
+		"agent==curl":         "invalid comparison 'agent==curl': unknown field 'agent'",
+		"path>/api":           "invalid comparison 'path>/api': path can only be compared with ==, !=, =~ and !~",
+		"status=~5":           "invalid comparison 'status=~5': status can't be matched with a regular expression",
+		"status":              "invalid comparison 'status': must be a field compared with a value, e.g. status>=500",
+		`param("q") > 1`:      `invalid comparison 'param("q") > 1': param can only be compared with ==, !=, =~ and !~`,
+		`param == newsletter`: `invalid comparison 'param == newsletter': param must be given the name of a parameter, e.g. param("utm_source")`,
+		`param("") == a`:      `invalid comparison 'param("") == a': param must be given the name of a parameter, e.g. param("utm_source")`,
+	}
+	for condition, expected := range tests {
+		_, err := ParseCondition(condition)
+		s.EqualError(err, expected, condition)
+	}
+}
+
+func TestCondition(t *testing.T) {
+	suite.Run(t, new(conditionSuite))
+}
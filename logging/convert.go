@@ -0,0 +1,162 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutputFormat is a format the log entries are rewritten in by a ConvertSink, e.g. to normalize legacy logs
+// before ingesting them elsewhere.
+type OutputFormat string
+
+const (
+	// OutputCommon rewrites the requests as Apache Common Log lines (%h %l %u %t "%r" %>s %b).
+	OutputCommon OutputFormat = "clf"
+	// OutputCombined rewrites the requests as Apache Combined Log lines, the Common Log ones
+	// followed by the referer and the user agent.
+	OutputCombined OutputFormat = "combined"
+	// OutputJSON rewrites the log entries as JSON lines, their fields being the ones of LogEntry.
+	OutputJSON OutputFormat = "json"
+	// OutputCSV rewrites the log entries as CSV records of their fields, under a header naming them (see csvColumns).
+	OutputCSV OutputFormat = "csv"
+)
+
+// csvColumns are the columns of the records written by OutputCSV.
+var csvColumns = []string{"time", "ip", "identity", "user", "method", "path", "protocol", "status", "size", "referer", "user_agent", "vhost"}
+
+// ParseOutputFormat parses the name of an OutputFormat, common standing for clf as well.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch format := OutputFormat(name); format {
+	case OutputCommon, OutputCombined, OutputJSON, OutputCSV:
+		return format, nil
+	case OutputFormat(FormatCommon):
+		return OutputCommon, nil
+	default:
+		return "", fmt.Errorf("unsupported output format '%s'", name)
+	}
+}
+
+// ConvertSink is a Sink rewriting the log entries in an OutputFormat to an io.Writer, one per line, buffered until
+// flushed. The invalid log lines (see LogEntry.Invalid) are left out and counted (see Skipped), and so are
+// the log entries which aren't requests (e.g. lines of the error log) in Apache Common or Combined Log lines.
+type ConvertSink struct {
+	format  OutputFormat
+	w       *bufio.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	skipped int64
+}
+
+// NewConvertSink creates a ConvertSink rewriting the log entries in a given OutputFormat to a given io.Writer.
+func NewConvertSink(w io.Writer, format OutputFormat) (*ConvertSink, error) {
+	if _, err := ParseOutputFormat(string(format)); err != nil {
+		return nil, err
+	}
+	s := &ConvertSink{format: format, w: bufio.NewWriter(w)}
+	switch format {
+	case OutputJSON:
+		s.json = json.NewEncoder(s.w)
+		s.json.SetEscapeHTML(false)
+	case OutputCSV:
+		s.csv = csv.NewWriter(s.w)
+		if err := s.csv.Write(csvColumns); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Write buffers a given log entry rewritten in the OutputFormat of the sink.
+func (s *ConvertSink) Write(_ context.Context, entry LogEntry) error {
+	if entry.Invalid {
+		s.skipped++
+		return nil
+	}
+	switch s.format {
+	case OutputJSON:
+		return s.json.Encode(entry)
+	case OutputCSV:
+		return s.csv.Write([]string{
+			entry.Time.Format(time.RFC3339Nano), entry.IP, entry.Identity, entry.User, entry.Method, entry.Path,
+			entry.Protocol, optionalInt(int64(entry.Status)), strconv.FormatInt(entry.Size, 10), entry.Referer,
+			entry.UserAgent, entry.VHost,
+		})
+	default:
+		if entry.IP == "" {
+			s.skipped++
+			return nil
+		}
+		_, err := s.w.WriteString(FormatCLF(entry, s.format == OutputCombined) + "\n")
+		return err
+	}
+}
+
+// Flush writes the buffered log entries to the io.Writer.
+func (s *ConvertSink) Flush(context.Context) error {
+	if s.csv != nil {
+		s.csv.Flush()
+		if err := s.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+// Skipped returns the number of log entries left out, being invalid or not requests (see ConvertSink).
+func (s *ConvertSink) Skipped() int64 {
+	return s.skipped
+}
+
+// FormatCLF returns a log entry as an Apache Common Log line, or a Combined Log one when told so, the fields
+// it doesn't know being dashes, e.g.
+//
+//	10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a HTTP/1.1" 200 512 "-" "curl/7.79.1"
+func FormatCLF(entry LogEntry, combined bool) string {
+	var b strings.Builder
+	b.WriteString(dash(entry.IP) + " " + dash(entry.Identity) + " " + dash(entry.User))
+	b.WriteString(" [" + entry.Time.Format(dateTimeFormat) + "] ")
+	request := "-"
+	if entry.Method != "" {
+		request = strings.TrimSpace(entry.Method + " " + entry.Path + " " + entry.Protocol)
+	}
+	b.WriteString(`"` + request + `" ` + dash(optionalInt(int64(entry.Status))) + " " + dash(optionalInt(entry.Size)))
+	if combined {
+		b.WriteString(` "` + dash(entry.Referer) + `" "` + dash(entry.UserAgent) + `"`)
+	}
+	return b.String()
+}
+
+// ParseLine parses a log line of the format (FormatCommon when empty) into a LogEntry, failing with
+// an *InvalidLogLineError when it doesn't match the format, e.g. to convert whole files line by line (see ConvertSink).
+func (format Format) ParseLine(line string) (LogEntry, error) {
+	if err := format.validate(); err != nil {
+		return LogEntry{}, err
+	}
+	if format == "" {
+		format = FormatCommon
+	}
+	return NewFormatFile(nil, format).parseLogEntry(line)
+}
+
+// dash returns a given field of a log line, or - when unknown.
+func dash(field string) string {
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
+// optionalInt returns a given number as a field of a log line, or nothing when 0.
+func optionalInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
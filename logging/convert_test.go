@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const convertDataDir = "test/convert"
+
+type convertSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *convertSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:01:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(convertDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a?x=1 HTTP/1.1" 200 512 "https://example.com/" "curl/7.79.1"
+garbage
+10.0.0.2 - bob [03/Mar/2022:10:00:05 +0000] "POST /b HTTP/1.1" 404 - "-" "Mozilla/5.0, compatible"
+`, t)
+}
+
+func (s *convertSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(convertDataDir)))
+}
+
+// convert rewrites the logs of the test file in a given format.
+func (s *convertSuite) convert(format OutputFormat) (string, int64) {
+	logs, err := New(WithDirectory(convertDataDir), WithFormat(FormatCombined), WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	var b bytes.Buffer
+	sink, err := NewConvertSink(&b, format)
+	s.Require().NoError(err)
+	s.Require().NoError(logs.Route(context.Background(), sink))
+	return b.String(), sink.Skipped()
+}
+
+func (s *convertSuite) Test_ConvertSink_Common() {
+	out, skipped := s.convert(OutputCommon)
+	s.Equal(`10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a?x=1 HTTP/1.1" 200 512
+10.0.0.2 - bob [03/Mar/2022:10:00:05 +0000] "POST /b HTTP/1.1" 404 -
+`, out)
+	s.Equal(int64(1), skipped)
+
+	// the lines converted parse back to the same requests
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		entry, err := FormatCommon.ParseLine(line)
+		s.Require().NoError(err)
+		s.NotEmpty(entry.IP)
+	}
+}
+
+func (s *convertSuite) Test_ConvertSink_Combined() {
+	out, _ := s.convert(OutputCombined)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	s.Require().Len(lines, 2)
+	s.Equal(`10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a?x=1 HTTP/1.1" 200 512 "https://example.com/" "curl/7.79.1"`, lines[0])
+	entry, err := FormatCombined.ParseLine(lines[0])
+	s.Require().NoError(err)
+	s.Equal("curl/7.79.1", entry.UserAgent)
+	s.Equal(512, int(entry.Size))
+}
+
+func (s *convertSuite) Test_ConvertSink_JSON() {
+	out, skipped := s.convert(OutputJSON)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	s.Require().Len(lines, 2)
+	s.Contains(lines[0], `"ip":"10.0.0.1"`)
+	s.Contains(lines[0], `"path":"/a?x=1"`)
+	s.Contains(lines[1], `"user":"bob"`)
+	s.Equal(int64(1), skipped)
+}
+
+func (s *convertSuite) Test_ConvertSink_CSV() {
+	out, _ := s.convert(OutputCSV)
+	s.Equal(`time,ip,identity,user,method,path,protocol,status,size,referer,user_agent,vhost
+2022-03-03T10:00:00Z,10.0.0.1,-,-,GET,/a?x=1,HTTP/1.1,200,512,https://example.com/,curl/7.79.1,
+2022-03-03T10:00:05Z,10.0.0.2,-,bob,POST,/b,HTTP/1.1,404,0,-,"Mozilla/5.0, compatible",
+`, out)
+}
+
+func (s *convertSuite) Test_ConvertSink_NotRequests() {
+	var b bytes.Buffer
+	sink, err := NewConvertSink(&b, OutputCombined)
+	s.Require().NoError(err)
+	s.Require().NoError(sink.Write(context.Background(), LogEntry{Time: s.testTime, Level: "error", Message: "oops"}))
+	s.Require().NoError(sink.Write(context.Background(), LogEntry{Time: s.testTime, IP: "10.0.0.1"}))
+	s.Require().NoError(sink.Flush(context.Background()))
+	s.Equal("10.0.0.1 - - [03/Mar/2022:10:01:00 +0000] \"-\" - - \"-\" \"-\"\n", b.String())
+	s.Equal(int64(1), sink.Skipped())
+}
+
+func (s *convertSuite) Test_ParseOutputFormat() {
+	format, err := ParseOutputFormat("common")
+	s.Require().NoError(err)
+	s.Equal(OutputCommon, format)
+	_, err = ParseOutputFormat("xml")
+	s.EqualError(err, "unsupported output format 'xml'")
+	_, err = NewConvertSink(&bytes.Buffer{}, "xml")
+	s.Error(err)
+}
+
+func (s *convertSuite) Test_ParseLine() {
+	entry, err := Format("").ParseLine(`10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a HTTP/1.1" 200 512`)
+	s.Require().NoError(err)
+	s.Equal("/a", entry.Path)
+	s.True(entry.Time.Equal(s.testTime.Add(-time.Minute)))
+
+	entry, err = FormatCommon.ParseLine("garbage")
+	var invalid *InvalidLogLineError
+	s.True(errors.As(err, &invalid))
+	s.True(entry.Invalid)
+
+	_, err = Format("xml").ParseLine("garbage")
+	s.True(errors.Is(err, ErrUnsupportedFormat))
+}
+
+func TestConvertSuite(t *testing.T) {
+	suite.Run(t, new(convertSuite))
+}
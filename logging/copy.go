@@ -0,0 +1,185 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// copyChunkSize is the number of bytes copied at once by Logs.copy, between which the context is checked.
+const copyChunkSize = 64 * printBufferSize
+
+// copyable checks whether the log lines can be printed without looking at them one by one:
+// none is filtered out or transformed, the files are read one after another, and the lines are only looked at
+// to find where the time range starts (e.g. no End, Clock or Tolerance).
+func (logs *Logs) copyable() bool {
+	cfg := logs.cfg
+	return len(cfg.Filters) == 0 && len(cfg.Middlewares) == 0 && cfg.IP == "" && cfg.PathPrefix == "" && !cfg.Merge && cfg.End.IsZero() && cfg.Clock == nil && cfg.Tolerance == 0 &&
+		cfg.Checkpoints == nil && cfg.Duplicates == "" && !cfg.AssertSorted && cfg.Errors == nil
+}
+
+// copy prints the log lines within the last N minutes the same way Print does, copying the byte range
+// of every file from the first line within the time range to its last complete line (see File.end) as is.
+func (logs *Logs) copy(ctx context.Context, w io.Writer) error {
+	files, err := logs.files(ctx)
+	if err != nil {
+		return err
+	}
+	idx := logs.index(files)
+	logs.selected(files, idx)
+	if idx < 0 {
+		return ErrNoFilesInWindow
+	}
+	buf := make([]byte, logs.cfg.writeBufferSize())
+	progress := logs.newProgress(files[idx:])
+	for order, fi := range files[idx:] {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := logs.copyFile(ctx, w, fi, order, buf, progress.file(fi)); err != nil {
+			return err
+		}
+	}
+	progress.finish()
+	return nil
+}
+
+// copyFile copies the log lines of a given file within the last N minutes to a given writer, using a given buffer
+// unless the system copies them. Files with CRLF line endings are printed line by line, so the lines end with LF all the same,
+// as are the files with logs out of order right after the first log within the last N minutes (see boundaryScanSize).
+// The bytes of the file copied or skipped are counted by a given progress, if any (see LogsConfig.Progress).
+func (logs *Logs) copyFile(ctx context.Context, w io.Writer, fi logFile, order int, buf []byte, progress *fileProgress) error {
+	start := time.Now()
+	file, err := logs.open(fi.path)
+	if errors.Is(err, errVanished) {
+		progress.done()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	defer progress.done()
+
+	offset, err := logs.offset(ctx, file, order == 0)
+	if err != nil || offset < 0 {
+		return err
+	}
+	end, err := file.end()
+	if err != nil || end <= offset {
+		return err
+	}
+	line, _, err := readLine(io.NewSectionReader(file, offset, end-offset))
+	if err != nil {
+		return err
+	}
+	outOfOrder, err := logs.boundaryOutOfOrder(ctx, file, offset, end)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(line, "\r\n") || outOfOrder {
+		return logs.printFile(ctx, w, file, order, progress)
+	}
+	progress.add(offset)
+
+	// the open file itself is copied from, so the system copies the bytes where it can (e.g. an os.File),
+	// which is why its cursor is used: the file was opened by this call only.
+	// The bytes go through the File when its reads are limited though, so they are counted.
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	var r io.Reader = file
+	direct := file.mapping == nil && file.limiter == nil && file.budget == nil
+	if direct {
+		r = file.LogFile
+	}
+	for remaining := end - offset; remaining > 0; {
+		if err := ctx.Err(); err != nil {
+			// the line copied last is completed, so the logs printed so far are never cut in the middle of a line
+			if remaining < end-offset {
+				_ = completeLine(w, r, remaining, buf)
+			}
+			return err
+		}
+		n := remaining
+		if n > copyChunkSize {
+			n = copyChunkSize
+		}
+		copied, err := io.CopyBuffer(w, &io.LimitedReader{R: r, N: n}, buf)
+		if direct {
+			// the bytes copied from the open file itself don't go through the File counting them
+			file.metrics.read(copied)
+		}
+		if err != nil {
+			return err
+		}
+		if copied < n {
+			return io.ErrUnexpectedEOF
+		}
+		progress.add(n)
+		remaining -= n
+	}
+
+	// a complete last line without a trailing newline is printed with one
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, end-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		if _, err := w.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	logs.info("file copied", "file", file.Name(), "offset", offset, "bytes", end-offset, "took", time.Since(start))
+	return nil
+}
+
+// completeLine copies the bytes of a given reader up to and including the next newline, at most a given number.
+func completeLine(w io.Writer, r io.Reader, remaining int64, buf []byte) error {
+	for remaining > 0 {
+		if int64(len(buf)) > remaining {
+			buf = buf[:remaining]
+		}
+		n, err := r.Read(buf)
+		line := buf[:n]
+		i := bytes.IndexByte(line, '\n')
+		if i >= 0 {
+			line = line[:i+1]
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		if i >= 0 || err != nil {
+			return err
+		}
+		remaining -= int64(n)
+	}
+	return nil
+}
+
+// printFile prints the log lines of a given file within the last N minutes to a given writer one by one, the way Print does.
+func (logs *Logs) printFile(ctx context.Context, w io.Writer, file File, order int, progress *fileProgress) error {
+	c, err := logs.cursor(ctx, file, order, progress)
+	if err != nil || c == nil {
+		return err
+	}
+	defer c.release()
+
+	bw := bufio.NewWriterSize(w, logs.cfg.writeBufferSize())
+	for ok := true; ok; {
+		if _, err := bw.WriteString(c.entry.Line); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+		if ok, err = c.next(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
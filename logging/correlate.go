@@ -0,0 +1,187 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// DefaultCorrelateTolerance is how long after the start of a request its errors are looked for by default,
+// see CorrelateConfig.Tolerance.
+const DefaultCorrelateTolerance = 5 * time.Second
+
+// CorrelateConfig configures how Correlate links the requests of the access logs with the lines of the error logs.
+type CorrelateConfig struct {
+	// Tolerance is how long after the start of a request, i.e. the time of its access log line,
+	// its errors may be logged. It's DefaultCorrelateTolerance when 0.
+	Tolerance time.Duration
+	// ErrorOffset is the offset of the local time zone of the error logs from UTC, e.g. 1h for UTC+1,
+	// as their times are read as UTC (see FormatError), so they're compared with the times of the requests.
+	ErrorOffset time.Duration
+}
+
+// Correlation is a request of the access logs along with the lines of the error logs it caused, if any.
+type Correlation struct {
+	Request LogEntry `json:"request"`
+	// Errors are the lines of the error logs of the client of the request, logged within the tolerance after it
+	// (see CorrelateConfig.Tolerance), along with the lines without client of the same server processes
+	// and the lines following them which can't be parsed (e.g. stack traces), in order.
+	Errors []LogEntry `json:"errors,omitempty"`
+}
+
+// errorEvent is a line of the error logs along with the lines following it which can't be parsed, e.g. a stack trace.
+type errorEvent struct {
+	entries []LogEntry
+	// linked tells the event has been linked to a request already
+	linked bool
+}
+
+// Correlate reads the requests of the access logs and calls a given function for each of them, in order,
+// along with the lines of the error logs it caused. A line of the error logs is linked to the last request
+// of its client started at the latest when it was logged, and within the tolerance, the ones answered with
+// a server error winning the ties, since the access logs times are rounded down to the second.
+// The lines of the error logs of the window are held in memory, the error logs being expected to cover
+// the window of the access logs, along with the tolerance after it, once shifted by the offset.
+// Error logs without any file within their window (ErrNoFilesInWindow) are taken as logs without errors.
+func Correlate(ctx context.Context, access, errorLogs *Logs, cfg CorrelateConfig, fn func(Correlation) error) error {
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = DefaultCorrelateTolerance
+	}
+	events, err := readErrorEvents(ctx, errorLogs, cfg.ErrorOffset, access.nowMinusT())
+	if err != nil && !errors.Is(err, ErrNoFilesInWindow) {
+		return err
+	}
+
+	c := &correlator{cfg: cfg, events: events, fn: fn}
+	err = access.ForEach(ctx, func(entry LogEntry) error {
+		// a request is linked once no later request may take its errors
+		for len(c.pending) > 0 && entry.Time.Sub(c.pending[0].Time) > cfg.Tolerance {
+			if err := c.emit(); err != nil {
+				return err
+			}
+		}
+		c.pending = append(c.pending, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for len(c.pending) > 0 {
+		if err := c.emit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readErrorEvents reads the lines of the error logs logged since a given time, in order,
+// shifting their times to UTC by a given offset.
+func readErrorEvents(ctx context.Context, errorLogs *Logs, offset time.Duration, since time.Time) ([]*errorEvent, error) {
+	var events []*errorEvent
+	skipped := false
+	err := errorLogs.ForEach(ctx, func(entry LogEntry) error {
+		entry.Time = entry.Time.Add(-offset)
+		if entry.Time.Before(since) {
+			skipped = true
+			return nil
+		}
+		if entry.Invalid && len(events) > 0 && !skipped {
+			last := events[len(events)-1]
+			last.entries = append(last.entries, entry)
+			return nil
+		}
+		skipped = false
+		events = append(events, &errorEvent{entries: []LogEntry{entry}})
+		return nil
+	})
+	// the lines of merged files may be slightly out of order
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].entries[0].Time.Before(events[j].entries[0].Time)
+	})
+	return events, err
+}
+
+// correlator links the requests of the access logs with the events of the error logs, see Correlate.
+type correlator struct {
+	cfg    CorrelateConfig
+	events []*errorEvent
+	fn     func(Correlation) error
+	// pending are the requests read but not linked yet, as later requests may take their errors
+	pending []LogEntry
+}
+
+// emit links the first pending request with its errors and calls the function with them.
+func (c *correlator) emit() error {
+	request := c.pending[0]
+	correlation := Correlation{Request: request}
+	if request.IP != "" && !request.Invalid {
+		correlation.Errors = c.errors(request)
+	}
+	c.pending = c.pending[1:]
+	return c.fn(correlation)
+}
+
+// errors returns the lines of the error logs linked to a given request, the first pending one.
+func (c *correlator) errors(request LogEntry) []LogEntry {
+	end := request.Time.Add(c.cfg.Tolerance)
+	first := sort.Search(len(c.events), func(i int) bool {
+		return !c.events[i].entries[0].Time.Before(request.Time)
+	})
+
+	var linked []LogEntry
+	pids := make(map[string]bool)
+	var clientless []*errorEvent
+	for _, event := range c.events[first:] {
+		head := event.entries[0]
+		if head.Time.After(end) {
+			break
+		}
+		if event.linked {
+			continue
+		}
+		if head.IP == "" {
+			clientless = append(clientless, event)
+			continue
+		}
+		if head.IP != request.IP || c.later(head) {
+			continue
+		}
+		event.linked = true
+		linked = append(linked, event.entries...)
+		if head.PID != "" {
+			pids[head.PID] = true
+		}
+	}
+	if len(pids) == 0 {
+		return linked
+	}
+
+	// the lines without client of the same processes, e.g. the errors of a script, are linked too
+	for _, event := range clientless {
+		if pids[event.entries[0].PID] {
+			event.linked = true
+			linked = append(linked, event.entries...)
+		}
+	}
+	sort.SliceStable(linked, func(i, j int) bool {
+		return linked[i].Time.Before(linked[j].Time)
+	})
+	return linked
+}
+
+// later checks whether a request pending after the first one better matches a given line of the error logs:
+// a later request of the same client started at the latest when the line was logged, or one started
+// within the same second and answered with a server error while the first one wasn't.
+func (c *correlator) later(head LogEntry) bool {
+	first := c.pending[0]
+	for _, request := range c.pending[1:] {
+		if request.IP != head.IP || request.Time.After(head.Time) {
+			continue
+		}
+		if request.Time.After(first.Time) || (request.Status >= 500 && first.Status < 500) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	correlateAccessDir = "test/correlate/access"
+	correlateErrorDir  = "test/correlate/error"
+)
+
+type correlateSuite struct {
+	suite.Suite
+	t time.Time
+}
+
+func (s *correlateSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.t = t
+	writeLogFile(s.T(), filepath.Join(correlateAccessDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:44:20 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:44:20 +0000] "POST /b HTTP/1.1" 500 10
+10.0.0.2 - - [03/Mar/2022:02:44:21 +0000] "GET /c HTTP/1.1" 404 10
+10.0.0.1 - - [03/Mar/2022:02:44:40 +0000] "GET /d HTTP/1.1" 200 10
+`, t)
+	// the error logs are written in UTC+1
+	writeLogFile(s.T(), filepath.Join(correlateErrorDir, "error.log"), `[Thu Mar 03 03:44:05.000000 2022] [core:notice] [pid 1:tid 1] AH00094: Command line: '/usr/sbin/apache2'
+[Thu Mar 03 03:44:20.500000 2022] [php:error] [pid 42:tid 7] [client 10.0.0.1:51234] PHP Fatal error:  Uncaught Exception: boom in /var/www/b.php:3
+Stack trace:
+#0 {main}
+[Thu Mar 03 03:44:20.600000 2022] [php:notice] [pid 42:tid 7] shutting down the script
+[Thu Mar 03 03:44:21.200000 2022] [core:info] [pid 43:tid 8] [client 10.0.0.2:51235] AH00128: File does not exist: /var/www/c
+[Thu Mar 03 03:44:30.000000 2022] [core:error] [pid 44:tid 9] [client 10.0.0.3:51236] AH00126: Invalid URI in request
+`, t)
+}
+
+func (s *correlateSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(filepath.Dir(correlateAccessDir))))
+}
+
+// newLogs creates the logs of the given directory and format, of a given window of the test data.
+func (s *correlateSuite) newLogs(dir string, format Format, window time.Duration, end time.Time) *Logs {
+	logs, err := New(WithDirectory(dir), WithWindow(window), WithFormat(format), WithEnd(end))
+	s.Require().NoError(err)
+	return logs
+}
+
+func (s *correlateSuite) Test_Correlate() {
+	access := s.newLogs(correlateAccessDir, FormatCommon, time.Minute, s.t)
+	// the files are selected by their modified times, unlike the lines of the error logs shifted by the offset
+	errorLogs := s.newLogs(correlateErrorDir, FormatError, 2*time.Hour, s.t.Add(time.Hour))
+
+	linked := make(map[string][]string)
+	var requests []string
+	err := Correlate(context.Background(), access, errorLogs, CorrelateConfig{ErrorOffset: time.Hour}, func(c Correlation) error {
+		request := c.Request.Method + " " + c.Request.Path
+		requests = append(requests, request)
+		for _, entry := range c.Errors {
+			linked[request] = append(linked[request], entry.Line)
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+
+	s.Equal([]string{"GET /a", "GET /a", "POST /b", "GET /c", "GET /d"}, requests)
+	s.Equal(map[string][]string{
+		"POST /b": {
+			`[Thu Mar 03 03:44:20.500000 2022] [php:error] [pid 42:tid 7] [client 10.0.0.1:51234] PHP Fatal error:  Uncaught Exception: boom in /var/www/b.php:3`,
+			`Stack trace:`,
+			`#0 {main}`,
+			`[Thu Mar 03 03:44:20.600000 2022] [php:notice] [pid 42:tid 7] shutting down the script`,
+		},
+		"GET /c": {
+			`[Thu Mar 03 03:44:21.200000 2022] [core:info] [pid 43:tid 8] [client 10.0.0.2:51235] AH00128: File does not exist: /var/www/c`,
+		},
+	}, linked)
+}
+
+func (s *correlateSuite) Test_Correlate_Tolerance() {
+	access := s.newLogs(correlateAccessDir, FormatCommon, time.Minute, s.t)
+	// without the offset, the errors are logged an hour after the requests
+	errorLogs := s.newLogs(correlateErrorDir, FormatError, 2*time.Hour, s.t.Add(time.Hour))
+
+	linked := 0
+	err := Correlate(context.Background(), access, errorLogs, CorrelateConfig{}, func(c Correlation) error {
+		linked += len(c.Errors)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Zero(linked)
+}
+
+func TestCorrelate(t *testing.T) {
+	suite.Run(t, new(correlateSuite))
+}
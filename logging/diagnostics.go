@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"time"
+)
+
+// Logger receives the diagnostic messages of Logs (see LogsConfig.Logger), explaining which files are selected,
+// where the time range starts inside them, which lines are skipped as invalid and how long every phase takes,
+// e.g. to understand why nothing is printed. The messages come with alternating keys and values,
+// e.g. Info("offset found", "file", "access.log", "offset", 1024). Its methods are the ones of *slog.Logger,
+// which can be used as is. It must be safe for concurrent use when the files are read in parallel (see LogsConfig.Workers).
+type Logger interface {
+	// Info reports the outcome of every phase, once per file at most.
+	Info(msg string, args ...interface{})
+	// Debug reports the details, e.g. every invalid line.
+	Debug(msg string, args ...interface{})
+}
+
+// info reports a diagnostic message of every phase, if the logs have a Logger.
+func (logs *Logs) info(msg string, args ...interface{}) {
+	if logs.cfg.Logger != nil {
+		logs.cfg.Logger.Info(msg, args...)
+	}
+}
+
+// selected reports the files selected within the time range among given files, the first one being at a given index.
+func (logs *Logs) selected(files []logFile, idx int) {
+	if logs.cfg.Logger == nil {
+		return
+	}
+	from, to := logs.nowMinusT(), logs.cfg.end()
+	if idx < 0 {
+		var latest time.Time
+		if len(files) > 0 {
+			latest = files[len(files)-1].modTime()
+		}
+		logs.info("no files in window", "from", from, "to", to, "files", len(files), "latest", latest)
+		return
+	}
+	paths := make([]string, 0, len(files)-idx)
+	for _, fi := range files[idx:] {
+		paths = append(paths, fi.path)
+	}
+	logs.info("files selected", "from", from, "to", to, "files", paths, "skipped", idx)
+}
@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const diagnosticsDataDir = "test/diagnostics"
+
+type diagnosticsSuite struct {
+	suite.Suite
+	testTime time.Time
+	logs     string
+}
+
+func (s *diagnosticsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	var logs strings.Builder
+	for i := 0; i < 100; i++ {
+		logs.WriteString(fmt.Sprintf("10.0.0.1 - - [%s] \"GET /%d HTTP/1.1\" 200 10\n",
+			t.Add(-100*time.Minute).Add(time.Duration(i)*time.Minute).Format(dateTimeFormat), i))
+		if i == 95 {
+			logs.WriteString("not a log line\n")
+		}
+	}
+	s.logs = logs.String()
+	writeLogFile(s.T(), filepath.Join(diagnosticsDataDir, "access.log"), s.logs, t)
+}
+
+func (s *diagnosticsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(diagnosticsDataDir)))
+}
+
+// recordingLogger records the diagnostic messages it receives, along with their level and arguments.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Info(msg string, args ...interface{}) {
+	l.record("INFO", msg, args)
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	l.record("DEBUG", msg, args)
+}
+
+func (l *recordingLogger) record(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var b strings.Builder
+	b.WriteString(level + " " + msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		if _, ok := args[i+1].(time.Duration); ok {
+			// the timings vary from run to run
+			continue
+		}
+		b.WriteString(fmt.Sprintf(" %v=%v", args[i], args[i+1]))
+	}
+	l.messages = append(l.messages, b.String())
+}
+
+func (s *diagnosticsSuite) print(window time.Duration, opts ...Option) []string {
+	logger := &recordingLogger{}
+	logs, err := New(append([]Option{WithDirectory(diagnosticsDataDir), WithWindow(window), WithLogger(logger)}, opts...)...)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-window)
+	}
+	s.Require().NoError(logs.Print(context.Background(), &bytes.Buffer{}))
+	return logger.messages
+}
+
+func (s *diagnosticsSuite) Test_Logger_Streamed() {
+	name := filepath.Join(diagnosticsDataDir, "access.log")
+	offset := strings.Index(s.logs, "GET /90 ")
+	offset = strings.LastIndexByte(s.logs[:offset], '\n') + 1
+	invalid := strings.Index(s.logs, "not a log line")
+	messages := s.print(10*time.Minute, WithFilter(func(entry LogEntry) bool { return entry.Path != "/92" }))
+
+	s.Require().Len(messages, 5)
+	s.Equal("INFO files listed directory=test/diagnostics listed=1 files=1", messages[0])
+	s.True(strings.HasPrefix(messages[1], fmt.Sprintf("INFO files selected from=%s", s.testTime.Add(-10*time.Minute))), messages[1])
+	s.True(strings.HasSuffix(messages[1], fmt.Sprintf("files=[%s] skipped=0", name)), messages[1])
+	s.Equal(fmt.Sprintf("INFO offset found file=%s offset=%d by=binary search", name, offset), messages[2])
+	s.True(strings.HasPrefix(messages[3], fmt.Sprintf("DEBUG invalid line kept with the time of the previous one file=%s offset=%d error=", name, invalid)), messages[3])
+	s.Equal(fmt.Sprintf("INFO file read file=%s bytes=%d lines=11 invalid=1 emitted=10 filtered=1 from=%s to=%s", name, len(s.logs)-offset,
+		s.testTime.Add(-10*time.Minute), s.testTime.Add(-time.Minute)), messages[4])
+}
+
+func (s *diagnosticsSuite) Test_Logger_Parallel() {
+	name := filepath.Join(diagnosticsDataDir, "access.log")
+	// the lines are filtered by the goroutine reading the file ahead
+	messages := s.print(10*time.Minute, WithWorkers(2), WithFilter(func(entry LogEntry) bool { return entry.Path != "/92" }))
+
+	s.Require().Len(messages, 5)
+	s.True(strings.HasPrefix(messages[4], fmt.Sprintf("INFO file read file=%s bytes=", name)), messages[4])
+	s.Contains(messages[4], fmt.Sprintf("lines=11 invalid=1 emitted=10 filtered=1 from=%s to=%s", s.testTime.Add(-10*time.Minute), s.testTime.Add(-time.Minute)))
+}
+
+func (s *diagnosticsSuite) Test_Logger_Copied() {
+	name := filepath.Join(diagnosticsDataDir, "access.log")
+	offset := strings.Index(s.logs, "GET /90 ")
+	offset = strings.LastIndexByte(s.logs[:offset], '\n') + 1
+	messages := s.print(10 * time.Minute)
+
+	s.Require().Len(messages, 4)
+	s.Equal(fmt.Sprintf("INFO offset found file=%s offset=%d by=binary search", name, offset), messages[2])
+	s.Equal(fmt.Sprintf("INFO file copied file=%s offset=%d bytes=%d", name, offset, len(s.logs)-offset), messages[3])
+}
+
+func (s *diagnosticsSuite) Test_Logger_NoFilesInWindow() {
+	logger := &recordingLogger{}
+	logs, err := New(WithDirectory(diagnosticsDataDir), WithWindow(time.Minute), WithLogger(logger))
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(time.Hour)
+	}
+	s.ErrorIs(logs.Print(context.Background(), &bytes.Buffer{}), ErrNoFilesInWindow)
+	s.Require().Len(logger.messages, 2)
+	s.True(strings.HasPrefix(logger.messages[1], "INFO no files in window"), logger.messages[1])
+	s.Contains(logger.messages[1], "latest="+s.testTime.Local().String())
+}
+
+func TestDiagnostics(t *testing.T) {
+	suite.Run(t, new(diagnosticsSuite))
+}
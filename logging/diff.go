@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// LogDiff compares the log entries of two logs of the same window line by line, e.g. a primary server
+// and its replica to find the gaps of a log shipping, or the logs before and after a deploy,
+// along with the comparison of their requests (see Comparison, A being the previous window and B the current one).
+type LogDiff struct {
+	*Comparison
+	// OnlyA and OnlyB are the log entries found in one of the logs but not the other, in order.
+	// Identical log lines are matched one for one, so a line logged twice in A but once in B is only in A once.
+	OnlyA []LogEntry
+	OnlyB []LogEntry
+	// Common is the number of log entries found in both logs.
+	Common int64
+}
+
+// Diff reads the log entries of two logs and compares them, the log lines being matched whatever their files,
+// i.e. regardless of how the logs were rotated. The log entries of A are held in memory while B is read.
+// Logs without any log files within their window (ErrNoFilesInWindow) are compared as logs without entries.
+// The diff is returned even along with an error, accounting for the entries read until then.
+func Diff(ctx context.Context, a, b *Logs) (*LogDiff, error) {
+	d := &LogDiff{Comparison: NewComparison(b.cfg.window(), a.cfg.window())}
+	var entries []LogEntry
+	counts := make(map[string]int)
+	err := a.ForEach(ctx, func(entry LogEntry) error {
+		d.AddPrevious(entry)
+		entries = append(entries, entry)
+		counts[entry.Line]++
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNoFilesInWindow) {
+		return d, err
+	}
+
+	err = b.ForEach(ctx, func(entry LogEntry) error {
+		d.AddCurrent(entry)
+		if counts[entry.Line] > 0 {
+			counts[entry.Line]--
+			d.Common++
+			return nil
+		}
+		d.OnlyB = append(d.OnlyB, entry)
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNoFilesInWindow) {
+		return d, err
+	}
+
+	for _, entry := range entries {
+		if counts[entry.Line] > 0 {
+			counts[entry.Line]--
+			d.OnlyA = append(d.OnlyA, entry)
+		}
+	}
+	// the lines of merged files may be slightly out of order
+	for _, only := range [][]LogEntry{d.OnlyA, d.OnlyB} {
+		sort.SliceStable(only, func(i, j int) bool {
+			return only[i].Time.Before(only[j].Time)
+		})
+	}
+	return d, nil
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const diffDataDir = "test/diff"
+
+type diffSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *diffSuite) SetupSuite() {
+	s.testTime = parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+
+	// the replica misses a line, logs another twice and rotated its logs differently
+	s.writeLogs("primary", map[string]string{"access.log": `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /home HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /blog HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:02:44:30 +0000] "GET /shop HTTP/1.1" 500 10
+10.0.0.1 - - [03/Mar/2022:02:44:40 +0000] "GET /home HTTP/1.1" 200 10
+`})
+	s.writeLogs("replica", map[string]string{
+		"access.log.1": `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /home HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /blog HTTP/1.1" 200 10
+`,
+		"access.log": `10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /blog HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:02:44:40 +0000] "GET /home HTTP/1.1" 200 10
+`,
+	})
+}
+
+func (s *diffSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(diffDataDir)))
+}
+
+// writeLogs writes the given log files to a given directory of the test data, access.log being the latest modified.
+func (s *diffSuite) writeLogs(dir string, files map[string]string) {
+	for name, content := range files {
+		modified := s.testTime
+		if name != "access.log" {
+			modified = modified.Add(-10 * time.Second)
+		}
+		writeLogFile(s.T(), filepath.Join(diffDataDir, dir, name), content, modified)
+	}
+}
+
+func (s *diffSuite) logs(dir string) *Logs {
+	logs, err := New(WithDirectory(filepath.Join(diffDataDir, dir)), WithWindow(time.Minute), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	return logs
+}
+
+func (s *diffSuite) Test_Diff() {
+	d, err := Diff(context.Background(), s.logs("primary"), s.logs("replica"))
+	s.Require().NoError(err)
+
+	s.Equal(int64(3), d.Common)
+	s.Require().Len(d.OnlyA, 1)
+	s.Equal("/shop", d.OnlyA[0].Path)
+	s.Require().Len(d.OnlyB, 1)
+	s.Equal("/blog", d.OnlyB[0].Path)
+	s.Equal(filepath.Join(diffDataDir, "replica", "access.log"), d.OnlyB[0].File)
+
+	s.Equal(4, d.Previous.Requests)
+	s.Equal(4, d.Current.Requests)
+	s.Equal(-0.25, d.ErrorRateDelta())
+}
+
+func (s *diffSuite) Test_Diff_NoFilesInWindow() {
+	empty, err := New(WithDirectory(filepath.Join(diffDataDir, "replica")), WithWindow(time.Minute), WithEnd(s.testTime.Add(-time.Hour)))
+	s.Require().NoError(err)
+	d, err := Diff(context.Background(), s.logs("primary"), empty)
+	s.Require().NoError(err)
+
+	s.Len(d.OnlyA, 4)
+	s.Empty(d.OnlyB)
+	s.Zero(d.Common)
+}
+
+func TestDiffSuite(t *testing.T) {
+	suite.Run(t, new(diffSuite))
+}
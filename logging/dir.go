@@ -0,0 +1,348 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logFile pairs the info of a log file with its path
+// and, when known, the times of its first and last log lines.
+type logFile struct {
+	os.FileInfo
+	path        string
+	first, last time.Time
+	// link tells the file is a symlink which info is the one of its target, resolved while listed (see readDir)
+	link bool
+}
+
+// modTime returns the time of the last log line inside the file when known,
+// falling back to the modified time of the file otherwise.
+func (fi logFile) modTime() time.Time {
+	if fi.last.IsZero() {
+		return fi.ModTime()
+	}
+	return fi.last
+}
+
+// listFiles lists the log files of the directory of a given configuration, see LogsConfig.Format,
+// LogsConfig.Sharded and LogsConfig.FollowSymlinks.
+func listFiles(cfg LogsConfig) ([]logFile, error) {
+	fsys := cfg.fileSystem()
+	var filesInfo []logFile
+	var err error
+	switch {
+	case cfg.Format == FormatCRI:
+		filesInfo, err = walkDir(fsys, cfg.Directory)
+	case cfg.Sharded:
+		filesInfo, err = readShardDirs(fsys, cfg)
+	default:
+		filesInfo, err = readDir(fsys, cfg.Directory, cfg.FollowSymlinks)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resolveLinks(fsys, filesInfo, cfg.FollowSymlinks)
+}
+
+// listHostsFiles lists the log files of the directories of the hosts of a given configuration, see LogsConfig.Hosts.
+func listHostsFiles(cfg LogsConfig) ([]logFile, error) {
+	var filesInfo []logFile
+	for _, host := range cfg.Hosts {
+		hostCfg := cfg
+		hostCfg.Directory = host.Directory
+		files, err := listFiles(hostCfg)
+		if err != nil {
+			return nil, fmt.Errorf("could not list the files of host %s: %w", host.Name, err)
+		}
+		filesInfo = append(filesInfo, files...)
+	}
+	return filesInfo, nil
+}
+
+// listLogFiles lists the log files of the directory of a given configuration, or the ones of its hosts (see LogsConfig.Hosts).
+func listLogFiles(cfg LogsConfig) ([]logFile, error) {
+	if len(cfg.Hosts) > 0 {
+		return listHostsFiles(cfg)
+	}
+	return listFiles(cfg)
+}
+
+// DirConfigName is the name of the file of a log directory declaring how its logs are read (e.g. their format and
+// time zone), shipped along with them, which the log-reader command loads when pointed at the directory.
+// It is never taken for a log file.
+const DirConfigName = ".log-reader.yaml"
+
+// isSidecarFile checks whether a file of a given name of a log directory sits next to the log files rather than
+// being one of them: an index file (see IndexSuffix) or the configuration of the directory (see DirConfigName).
+func isSidecarFile(name string) bool {
+	return isIndexFile(name) || name == DirConfigName
+}
+
+// statBatchSize is the number of files of a directory stat-ed at a time by every worker of statEntries,
+// the directories of fewer files being stat-ed by the calling goroutine alone.
+const statBatchSize = 256
+
+// statWorkers is the number of goroutines stat-ing the files of a large directory, the stats being bound
+// by the latency of the file system (e.g. an NFS mount) rather than by the CPU.
+const statWorkers = 16
+
+// readDir lists all the files found directly inside the given directory of a given file system, but the index files and the configuration
+// of the directory (see isSidecarFile), along with the symlinks to files when following them.
+// Compressed rotations (.gz) are skipped, since they cannot be searched (see Logs.Backfill).
+// The entries are filtered by their names and types first (the symlinks being left out unless followed), their info
+// being only read for the remaining ones, in parallel for the directories of tens of thousands of rotated files
+// (see statEntries).
+func readDir(fsys fileSystem, dir string, followSymlinks bool) ([]logFile, error) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := entries[:0]
+	for _, d := range entries {
+		if d.IsDir() || strings.HasSuffix(d.Name(), compressedSuffix) || isSidecarFile(d.Name()) {
+			continue
+		}
+		if d.Type()&fs.ModeSymlink != 0 && !followSymlinks {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return statEntries(fsys, dir, kept)
+}
+
+// statEntries returns the info of the given entries of a directory, in order, by batches of statBatchSize stat-ed
+// in parallel by statWorkers goroutines. The symlinks are stat-ed through, getting the info of their targets (see logFile.link).
+// The entries removed since the directory was read (e.g. by a rotation), the dangling symlinks and the symlinks
+// to directories are left out.
+func statEntries(fsys fileSystem, dir string, entries []fs.DirEntry) ([]logFile, error) {
+	files := make([]logFile, len(entries))
+	errs := make([]error, len(entries))
+	stat := func(from, to int) {
+		for i := from; i < to; i++ {
+			path := filepath.Join(dir, entries[i].Name())
+			if entries[i].Type()&fs.ModeSymlink == 0 {
+				fi, err := entries[i].Info()
+				files[i], errs[i] = logFile{FileInfo: fi, path: path}, err
+				continue
+			}
+			fi, err := fsys.Stat(path)
+			if err == nil && fi.IsDir() {
+				err = fs.ErrNotExist
+			}
+			files[i], errs[i] = logFile{FileInfo: fi, path: path, link: true}, err
+		}
+	}
+	if len(entries) <= statBatchSize {
+		stat(0, len(entries))
+	} else {
+		batches := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < statWorkers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for from := range batches {
+					to := from + statBatchSize
+					if to > len(entries) {
+						to = len(entries)
+					}
+					stat(from, to)
+				}
+			}()
+		}
+		for from := 0; from < len(entries); from += statBatchSize {
+			batches <- from
+		}
+		close(batches)
+		wg.Wait()
+	}
+
+	kept := files[:0]
+	for i, fi := range files {
+		if errors.Is(errs[i], fs.ErrNotExist) {
+			continue
+		}
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		kept = append(kept, fi)
+	}
+	return kept, nil
+}
+
+// readShardDirs lists all the files found directly inside the directory of a given configuration
+// and its dated subdirectories covering the time range within a given file system, see LogsConfig.Sharded.
+func readShardDirs(fsys fileSystem, cfg LogsConfig) ([]logFile, error) {
+	end := cfg.end()
+	dirs, err := shardDirs(fsys, cfg.Directory, end.Add(-cfg.window()), end)
+	if err != nil {
+		return nil, err
+	}
+	var filesInfo []logFile
+	for _, dir := range dirs {
+		files, err := readDir(fsys, dir, cfg.FollowSymlinks)
+		if err != nil {
+			return nil, err
+		}
+		filesInfo = append(filesInfo, files...)
+	}
+	return filesInfo, nil
+}
+
+// walkDir lists all the files found inside the given directory of a given file system and its subdirectories,
+// following the kubelet layout: <namespace>_<pod>_<uid>/<container>/<restart>.log.
+// Compressed rotations (.gz) are skipped, since they cannot be searched.
+func walkDir(fsys fileSystem, dir string) ([]logFile, error) {
+	var filesInfo []logFile
+	err := fsys.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), compressedSuffix) || isSidecarFile(d.Name()) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		filesInfo = append(filesInfo, logFile{FileInfo: fi, path: p})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return filesInfo, nil
+}
+
+// resolveLinks drops or resolves (when following them) the symlinks from the given files,
+// making sure every underlying file is present only once. Regular files take precedence
+// over the symlinks pointing to them, while hardlinks keep the first name found.
+// Dangling symlinks and symlinks to directories are ignored. The symlinks resolved already
+// (see logFile.link) are taken as they are.
+func resolveLinks(fsys fileSystem, files []logFile, followSymlinks bool) ([]logFile, error) {
+	resolved := make([]logFile, 0, len(files))
+	seen := newFileSet(len(files))
+	var symlinks []logFile
+	for _, fi := range files {
+		if fi.link || fi.Mode()&os.ModeSymlink != 0 {
+			symlinks = append(symlinks, fi)
+			continue
+		}
+		if seen.add(fi.FileInfo) {
+			resolved = append(resolved, fi)
+		}
+	}
+	if !followSymlinks {
+		return resolved, nil
+	}
+
+	for _, link := range symlinks {
+		if link.link {
+			if seen.add(link.FileInfo) {
+				resolved = append(resolved, link)
+			}
+			continue
+		}
+		target, err := fsys.Stat(link.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if target.IsDir() {
+			continue
+		}
+
+		fi := logFile{FileInfo: target, path: link.path, link: true}
+		if seen.add(fi.FileInfo) {
+			resolved = append(resolved, fi)
+		}
+	}
+
+	return resolved, nil
+}
+
+// nonEmpty drops the zero-length files (e.g. freshly rotated ones) from the given files,
+// since they contain no logs, yet their modified time would make them look fresh.
+func nonEmpty(files []logFile) []logFile {
+	filtered := files[:0]
+	for _, fi := range files {
+		if fi.Size() > 0 {
+			filtered = append(filtered, fi)
+		}
+	}
+	return filtered
+}
+
+// fileSet holds the underlying files of log files, whatever their names, by their identities (device and inode,
+// see fileIDOf), which hold however the files grow between their stats, unlike their sizes and modified times.
+// The files without identity (e.g. on Windows, where it isn't part of their info) are compared to each other
+// instead (see os.SameFile).
+type fileSet struct {
+	ids    map[FileID]bool
+	others []os.FileInfo
+}
+
+// newFileSet creates an empty fileSet sized for a given number of files.
+func newFileSet(n int) *fileSet {
+	return &fileSet{ids: make(map[FileID]bool, n)}
+}
+
+// add adds the underlying file of a given file info to the set, returning false when it is already present.
+func (set *fileSet) add(info os.FileInfo) bool {
+	if id := fileIDOf(nil, info); id != (FileID{}) {
+		if set.ids[id] {
+			return false
+		}
+		set.ids[id] = true
+		return true
+	}
+	for _, fi := range set.others {
+		if os.SameFile(fi, info) {
+			return false
+		}
+	}
+	set.others = append(set.others, info)
+	return true
+}
+
+// peekTimes reads the times of the first and last log lines of the given files, unless already known.
+// Files that cannot be parsed (e.g. empty or not matching the format) are left as they are,
+// in which case their modified time is used instead.
+// The times are shifted by the offset of the clock of the host of the files, if any (see Host.Offset).
+func (logs *Logs) peekTimes(files []logFile) error {
+	for i, fi := range files {
+		if !fi.last.IsZero() {
+			continue
+		}
+		f, err := logs.cfg.fileSystem().Open(fi.path)
+		if logs.cfg.SkipVanished && errors.Is(err, fs.ErrNotExist) {
+			// skipped once opened to be read, see Logs.open
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		file := NewFormatFile(f, logs.cfg.format(fi.path))
+		file.skew, file.loc = logs.skew(fi.path), logs.cfg.TimeZone
+		first, last, err := file.TimeRange()
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+		files[i].first, files[i].last = first, last
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const linksDataDir = "test/links"
+
+type dirSuite struct {
+	suite.Suite
+}
+
+func (s *dirSuite) SetupSuite() {
+	s.Require().NoError(os.MkdirAll(filepath.Join(linksDataDir, "rotated"), 0777))
+
+	// access.log -> access.log.1 is the typical rotation symlink,
+	// while access.log.hard is a hardlink of access.log.2
+	s.createFile("access.log.1")
+	s.createFile("access.log.2")
+	s.Require().NoError(os.Symlink("access.log.1", filepath.Join(linksDataDir, "access.log")))
+	s.Require().NoError(os.Link(filepath.Join(linksDataDir, "access.log.2"), filepath.Join(linksDataDir, "access.log.hard")))
+	s.Require().NoError(os.Symlink("does-not-exist", filepath.Join(linksDataDir, "dangling.log")))
+	s.Require().NoError(os.Symlink("rotated", filepath.Join(linksDataDir, "rotated.log")))
+	s.Require().NoError(os.Symlink(filepath.Join("rotated", "other.log"), filepath.Join(linksDataDir, "other.log")))
+	s.createFile(filepath.Join("rotated", "other.log"))
+	// the configuration of the directory is never taken for a log file
+	s.createFile(DirConfigName)
+}
+
+func (s *dirSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(linksDataDir)))
+}
+
+func (s *dirSuite) Test_resolveLinks() {
+	tests := []struct {
+		name           string
+		followSymlinks bool
+		expectedFiles  []string
+	}{
+		{
+			name:           "Ignore Symlinks",
+			followSymlinks: false,
+			expectedFiles:  []string{"access.log.1", "access.log.2"},
+		},
+		{
+			name:           "Follow Symlinks",
+			followSymlinks: true,
+			expectedFiles:  []string{"access.log.1", "access.log.2", "other.log"},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			files, err := readDir(osFileSystem{}, linksDataDir, test.followSymlinks)
+			s.Require().NoError(err)
+
+			resolved, err := resolveLinks(osFileSystem{}, files, test.followSymlinks)
+
+			s.NoError(err)
+			names := make([]string, 0, len(resolved))
+			for _, fi := range resolved {
+				names = append(names, filepath.Base(fi.path))
+			}
+			sort.Strings(names)
+			s.Equal(test.expectedFiles, names)
+		})
+	}
+}
+
+func (s *dirSuite) Test_readDir_Large() {
+	dir := filepath.Join(linksDataDir, "large")
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "archive"), 0777))
+	var expected []string
+	// more files than a batch of stats, so they are stat-ed in parallel
+	for i := 0; i < 3*statBatchSize+7; i++ {
+		name := fmt.Sprintf("access.log.%d", i)
+		s.Require().NoError(os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0666))
+		expected = append(expected, name)
+	}
+	for _, name := range []string{"access.log.9999.gz", "access.log.1" + IndexSuffix, DirConfigName} {
+		s.Require().NoError(os.WriteFile(filepath.Join(dir, name), nil, 0666))
+	}
+	s.Require().NoError(os.Symlink("access.log.0", filepath.Join(dir, "access.log")))
+	expected = append(expected, "access.log")
+	sort.Strings(expected)
+
+	files, err := readDir(osFileSystem{}, dir, true)
+	s.Require().NoError(err)
+	names := make([]string, 0, len(files))
+	for _, fi := range files {
+		s.Equal(filepath.Join(dir, fi.Name()), fi.path)
+		names = append(names, fi.Name())
+	}
+	// in the order of the names, the way os.ReadDir lists them
+	s.Equal(expected, names)
+	// the symlink is stat-ed through
+	s.True(files[0].link)
+	s.True(files[0].Mode().IsRegular())
+
+	resolved, err := resolveLinks(osFileSystem{}, files, true)
+	s.Require().NoError(err)
+	s.Len(resolved, len(expected)-1)
+}
+
+func (s *dirSuite) Test_resolveLinks_Growing() {
+	dir := filepath.Join(linksDataDir, "growing")
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	name := filepath.Join(dir, "access.log.20220303")
+	s.Require().NoError(os.WriteFile(name, []byte("first\n"), 0666))
+	s.Require().NoError(os.Symlink(filepath.Base(name), filepath.Join(dir, "access.log")))
+	s.Require().NoError(os.Link(name, filepath.Join(dir, "access.log.hard")))
+	regular, err := os.Lstat(name)
+	s.Require().NoError(err)
+
+	// the server appends to the live file between its stat and the ones of its other names
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0666)
+	s.Require().NoError(err)
+	_, err = f.WriteString("second\n")
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+	hard, err := os.Lstat(filepath.Join(dir, "access.log.hard"))
+	s.Require().NoError(err)
+	link, err := os.Lstat(filepath.Join(dir, "access.log"))
+	s.Require().NoError(err)
+	s.NotEqual(regular.Size(), hard.Size())
+
+	resolved, err := resolveLinks(osFileSystem{}, []logFile{
+		{FileInfo: link, path: filepath.Join(dir, "access.log")},
+		{FileInfo: regular, path: name},
+		{FileInfo: hard, path: filepath.Join(dir, "access.log.hard")},
+	}, true)
+
+	s.Require().NoError(err)
+	s.Require().Len(resolved, 1)
+	s.Equal(name, resolved[0].path)
+}
+
+func (s *dirSuite) createFile(name string) {
+	s.Require().NoError(os.WriteFile(filepath.Join(linksDataDir, name), []byte(name+"\n"), 0666))
+}
+
+func TestDir(t *testing.T) {
+	suite.Run(t, new(dirSuite))
+}
+
+// BenchmarkReadDir lists a directory of tens of thousands of rotated files, e.g. to measure the startup of New.
+func BenchmarkReadDir(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 20000; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("access.log.%d", i)), nil, 0666); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		files, err := readDir(osFileSystem{}, dir, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := resolveLinks(osFileSystem{}, files, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// Duplicates is how the log lines duplicated across files are handled, see LogsConfig.Duplicates.
+type Duplicates string
+
+const (
+	// DuplicatesReport keeps the duplicated log lines, counting them (see MetricsSnapshot.DuplicateLines)
+	// and reporting them to the Logger.
+	DuplicatesReport Duplicates = "report"
+	// DuplicatesSuppress leaves the duplicated log lines out, counting and reporting them the way DuplicatesReport does.
+	DuplicatesSuppress Duplicates = "suppress"
+)
+
+// DefaultDuplicateWindow is how far apart in log time a log line and its duplicate are looked for by default,
+// see LogsConfig.DuplicateWindow.
+const DefaultDuplicateWindow = time.Minute
+
+// ParseDuplicates parses the name of a Duplicates mode, "report" or "suppress".
+func ParseDuplicates(name string) (Duplicates, error) {
+	switch duplicates := Duplicates(name); duplicates {
+	case DuplicatesReport, DuplicatesSuppress:
+		return duplicates, nil
+	default:
+		return "", fmt.Errorf("unsupported duplicates mode '%s': must be report or suppress", name)
+	}
+}
+
+// seenLine is a log line streamed, which the lines of the other files duplicate.
+type seenLine struct {
+	file   string
+	offset int64
+}
+
+// seenHash is the hash of a log line streamed along with its time, in the order the lines were streamed.
+type seenHash struct {
+	hash uint64
+	time time.Time
+}
+
+// deduplicator detects the log lines of a stream duplicated across files, e.g. by a misconfigured rotation copying
+// the last lines of access.log into access.log.1: a log line is a duplicate when the very same line of the same host
+// was streamed from another file, at most a window of log time before it (see LogsConfig.DuplicateWindow).
+// The lines are remembered by their hashes for that long. The identical lines of a same file are legitimate,
+// e.g. a client requesting the same page twice within a second, and are never taken for duplicates.
+type deduplicator struct {
+	window time.Duration
+	lines  map[uint64]seenLine
+	order  []seenHash
+	// counts are the number of duplicates of every pair of files, reported once the stream is closed
+	counts map[[2]string]int
+}
+
+// newDeduplicator creates a deduplicator of the lines within a given window of log time of each other.
+func newDeduplicator(window time.Duration) *deduplicator {
+	if window <= 0 {
+		window = DefaultDuplicateWindow
+	}
+	return &deduplicator{window: window, lines: make(map[uint64]seenLine), counts: make(map[[2]string]int)}
+}
+
+// duplicate checks whether a given log entry duplicates a line of another file streamed before it,
+// returning the line it duplicates. The entries are expected in order. The invalid lines are never taken for duplicates,
+// as they have no time of their own.
+func (d *deduplicator) duplicate(entry LogEntry) (seenLine, bool) {
+	if entry.Invalid {
+		return seenLine{}, false
+	}
+	for len(d.order) > 0 && entry.Time.Sub(d.order[0].time) > d.window {
+		// identical lines have the same time, so the later ones are forgotten along with the first one
+		delete(d.lines, d.order[0].hash)
+		d.order = d.order[1:]
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(entry.Host))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(entry.Line))
+	hash := h.Sum64()
+	if seen, ok := d.lines[hash]; ok && seen.file != entry.File {
+		d.counts[[2]string{entry.File, seen.file}]++
+		return seen, true
+	}
+	d.lines[hash] = seenLine{file: entry.File, offset: entry.Offset}
+	d.order = append(d.order, seenHash{hash: hash, time: entry.Time})
+	return seenLine{}, false
+}
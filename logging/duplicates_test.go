@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const duplicatesDataDir = "test/duplicates"
+
+type duplicatesSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *duplicatesSuite) SetupSuite() {
+	// the rotation copied the last lines of access.log.1 into access.log, which also holds a request made twice
+	files := map[string]string{
+		"access.log.1": `10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:10 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:20 +0000] "GET /c HTTP/1.1" 200 10
+`,
+		"access.log": `10.0.0.1 - - [03/Mar/2022:10:00:10 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:20 +0000] "GET /c HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:30 +0000] "GET /d HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:30 +0000] "GET /d HTTP/1.1" 200 10
+`,
+	}
+	t := parseLogTime(s.T(), "03/Mar/2022:10:01:00 +0000")
+	s.testTime = t
+	for name, content := range files {
+		modified := t
+		if name == "access.log.1" {
+			modified = t.Add(-30 * time.Second)
+		}
+		writeLogFile(s.T(), filepath.Join(duplicatesDataDir, name), content, modified)
+	}
+}
+
+func (s *duplicatesSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(duplicatesDataDir)))
+}
+
+// paths returns the paths of the log entries read with the given options, along with the metrics of the reading.
+func (s *duplicatesSuite) paths(opts ...Option) ([]string, MetricsSnapshot) {
+	metrics := &Metrics{}
+	logs, err := New(append([]Option{WithDirectory(duplicatesDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithMetrics(metrics)}, opts...)...)
+	s.Require().NoError(err)
+	var paths []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+	return paths, metrics.Snapshot()
+}
+
+func (s *duplicatesSuite) Test_Duplicates_Off() {
+	paths, metrics := s.paths()
+	s.Equal([]string{"/a", "/b", "/c", "/b", "/c", "/d", "/d"}, paths)
+	s.Zero(metrics.DuplicateLines)
+}
+
+func (s *duplicatesSuite) Test_Duplicates_Report() {
+	logger := &recordingLogger{}
+	paths, metrics := s.paths(WithDuplicates(DuplicatesReport, 0), WithLogger(logger))
+	s.Equal([]string{"/a", "/b", "/c", "/b", "/c", "/d", "/d"}, paths)
+	s.Equal(int64(2), metrics.DuplicateLines)
+	s.Contains(logger.messages, "INFO duplicate lines file="+filepath.Join(duplicatesDataDir, "access.log")+
+		" of="+filepath.Join(duplicatesDataDir, "access.log.1")+" lines=2")
+}
+
+func (s *duplicatesSuite) Test_Duplicates_Suppress() {
+	paths, metrics := s.paths(WithDuplicates(DuplicatesSuppress, 0))
+	s.Equal([]string{"/a", "/b", "/c", "/d", "/d"}, paths)
+	s.Equal(int64(2), metrics.DuplicateLines)
+}
+
+func (s *duplicatesSuite) Test_Duplicates_Merged() {
+	paths, _ := s.paths(WithDuplicates(DuplicatesSuppress, time.Second), WithMerge())
+	s.Equal([]string{"/a", "/b", "/c", "/d", "/d"}, paths)
+}
+
+func (s *duplicatesSuite) Test_Duplicates_Invalid() {
+	_, err := New(WithDirectory(duplicatesDataDir), WithDuplicates("drop", 0))
+	var configErr *ConfigError
+	s.Require().ErrorAs(err, &configErr)
+	s.Equal("duplicates", configErr.Field)
+}
+
+func TestDuplicatesSuite(t *testing.T) {
+	suite.Run(t, new(duplicatesSuite))
+}
@@ -0,0 +1,182 @@
+package logging
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LogEntry represents a single log line along with its parsed fields.
+// Lines that don't match the log format only have their Line, Time and Invalid set,
+// where Time is inherited from the previous log line of the same file.
+type LogEntry struct {
+	// Line is the raw log line, without its line ending.
+	Line     string    `json:"line"`
+	Time     time.Time `json:"time"`
+	IP       string    `json:"ip,omitempty"`
+	Identity string    `json:"identity,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Method   string    `json:"method,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Protocol string    `json:"protocol,omitempty"`
+	// Status is the HTTP status code of the response, 0 when unknown ("-").
+	Status int `json:"status,omitempty"`
+	// Size is the size of the response in bytes, 0 when unknown ("-").
+	Size int64 `json:"size,omitempty"`
+	// Referer and UserAgent are only set for the FormatCombined format and its variants (e.g. FormatVHostCombined).
+	Referer   string `json:"referer,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// VHost is the virtual host serving the request, only set for the FormatVHostCombined format.
+	VHost string `json:"vhost,omitempty"`
+	// Level and Message are the severity level (e.g. error) and the message of the log line, only set for the FormatError format.
+	Level   string `json:"level,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Severity is how severe the log entry is, only set when mapping the log entries to severities (see SeverityMap).
+	Severity Severity `json:"severity,omitempty"`
+	// PID is the id of the server process which logged the line, only set for the FormatError format.
+	PID string `json:"pid,omitempty"`
+	// Cache is the cache status of the response, e.g. HIT or MISS, only set for the FormatCombinedCache format (see CacheHit).
+	Cache string `json:"cache,omitempty"`
+	// ForwardedFor is the X-Forwarded-For header of the request, the addresses of the client and of the proxies
+	// the request went through, only set for the FormatCombinedXFF format (see LogsConfig.XFF).
+	ForwardedFor string `json:"forwarded_for,omitempty"`
+	// RequestID is the id of the request, e.g. its X-Request-ID or traceparent header, only set for the FormatCombinedRequestID
+	// format, and TraceID and SpanID the W3C trace context ids it's linked to, only set when linking the log entries
+	// to traces (see LinkTrace).
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+	SpanID    string `json:"span_id,omitempty"`
+	// TLSProtocol and TLSCipher are the TLS protocol version (e.g. TLSv1.2) and the cipher suite negotiated for the request,
+	// only set for the FormatSSLRequest format (see WeakTLS).
+	TLSProtocol string `json:"tls_protocol,omitempty"`
+	TLSCipher   string `json:"tls_cipher,omitempty"`
+	// Bot is the pattern matching the user agent of a bot, empty for humans,
+	// only set when classifying the log entries (see LogsConfig.Bots).
+	Bot string `json:"bot,omitempty"`
+	// Duration is the time taken to serve the request, 0 when not logged (see FormatCommon).
+	Duration time.Duration `json:"duration,omitempty"`
+	// Host is the name of the host the log file was read from, only set when reading several hosts (see LogsConfig.Hosts).
+	Host string `json:"host,omitempty"`
+	// File is the name of the log file the line was read from.
+	File string `json:"file"`
+	// Offset is the byte offset of the line inside the log file.
+	Offset int64 `json:"offset"`
+	// Invalid tells the line doesn't match the log format.
+	Invalid bool `json:"invalid,omitempty"`
+	// FileID identifies the log file the line was read from whatever its name, only set when checkpointing
+	// the shipping of the logs (see LogsConfig.Checkpoints).
+	FileID FileID `json:"-"`
+}
+
+// parseLogEntry parses a given log line into a LogEntry.
+// Lines of the CRI format have their message parsed as an Apache Common Log line,
+// when it is one, while the time of the entry is always the CRI timestamp.
+func (file File) parseLogEntry(logLine string) (LogEntry, error) {
+	if file.parser != nil {
+		return file.parseCustom(logLine)
+	}
+	entry := LogEntry{Line: logLine, FileID: file.id}
+	fields, ok := file.match(logLine)
+	if !ok {
+		entry.Invalid = true
+		return entry, file.invalidLine(logLine, nil)
+	}
+
+	t, err := file.parseTime(fields.dateTime)
+	if err != nil {
+		entry.Invalid = true
+		return entry, file.invalidLine(logLine, err)
+	}
+	entry.Time = t.Add(-file.skew)
+
+	if fields.wrapped && file.messageRegEx != nil {
+		fields, ok = file.matchMessage(fields.message)
+		if !ok {
+			return entry, nil
+		}
+	}
+	entry.IP = fields.ip
+	entry.Identity = fields.identity
+	entry.User = fields.user
+	entry.Method = fields.method
+	entry.Path = fields.path
+	entry.Protocol = fields.protocol
+	entry.Referer = fields.referer
+	entry.UserAgent = fields.agent
+	entry.VHost = fields.vhost
+	entry.Level = fields.level
+	entry.PID = fields.pid
+	entry.Cache = fields.cache
+	entry.ForwardedFor = fields.forwardedFor
+	entry.RequestID = fields.requestID
+	entry.TLSProtocol = fields.tlsProtocol
+	entry.TLSCipher = fields.tlsCipher
+	if !fields.wrapped {
+		entry.Message = fields.message
+	}
+	if status, ok := parseNumber(fields.status); ok {
+		entry.Status = int(status)
+	}
+	if size, ok := parseNumber(fields.size); ok {
+		entry.Size = size
+	}
+	if duration, ok := parseNumber(fields.duration); ok {
+		entry.Duration = time.Duration(duration) * time.Microsecond
+	}
+
+	return entry, nil
+}
+
+// parseCustom parses a given log line of a custom format into a LogEntry, see RegisterFormat.
+func (file File) parseCustom(logLine string) (LogEntry, error) {
+	entry, ok := file.parser(logLine)
+	if !ok || entry.Time.IsZero() {
+		return LogEntry{Line: logLine, FileID: file.id, Invalid: true}, file.invalidLine(logLine, nil)
+	}
+	entry.Line, entry.FileID, entry.Invalid = logLine, file.id, false
+	entry.Time = entry.Time.Add(-file.skew)
+	return entry, nil
+}
+
+// serverError checks whether the request was answered with a server error (5xx).
+func (entry LogEntry) serverError() bool {
+	return entry.Status >= 500 && entry.Status < 600
+}
+
+// Query returns the parameters of the query string of the requested path, e.g. utm_source=newsletter
+// for /?utm_source=newsletter, decoded. The malformed parameters are left out.
+func (entry LogEntry) Query() url.Values {
+	i := strings.IndexByte(entry.Path, '?')
+	if i < 0 {
+		return url.Values{}
+	}
+	query, _ := url.ParseQuery(entry.Path[i+1:])
+	return query
+}
+
+// Param returns the first value of a given parameter of the query string of the requested path (see Query),
+// or an empty string when it's missing.
+func (entry LogEntry) Param(name string) string {
+	if strings.IndexByte(entry.Path, '?') < 0 {
+		return ""
+	}
+	return entry.Query().Get(name)
+}
+
+// matchGroups matches a given log line against a regex, returning the values of its named groups,
+// or false when the log line doesn't match the regex.
+func matchGroups(regEx *regexp.Regexp, logLine string) (map[string]string, bool) {
+	matches := regEx.FindStringSubmatch(logLine)
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	fields := make(map[string]string, len(matches))
+	for i, name := range regEx.SubexpNames() {
+		if name != "" {
+			fields[name] = matches[i]
+		}
+	}
+	return fields, true
+}
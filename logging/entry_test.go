@@ -0,0 +1,253 @@
+package logging
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type entrySuite struct {
+	suite.Suite
+}
+
+func (s *entrySuite) Test_parseLogEntry_Success() {
+	expectedTime := time.Date(2022, time.March, 4, 5, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		format        Format
+		log           string
+		expectedEntry LogEntry
+	}{
+		{
+			name:   "Common Format",
+			format: FormatCommon,
+			log:    `127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123`,
+			expectedEntry: LogEntry{
+				Line:     `127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123`,
+				Time:     expectedTime,
+				IP:       "127.0.0.1",
+				Identity: "user-identifier",
+				User:     "frank",
+				Method:   "GET",
+				Path:     "/api/endpoint",
+				Protocol: "HTTP/1.0",
+				Status:   500,
+				Size:     123,
+			},
+		},
+		{
+			name:   "Common Format With Duration",
+			format: FormatCommon,
+			log:    `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 1500`,
+			expectedEntry: LogEntry{
+				Line:     `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 1500`,
+				Time:     expectedTime,
+				IP:       "127.0.0.1",
+				Identity: "-",
+				User:     "frank",
+				Method:   "GET",
+				Path:     "/api/endpoint",
+				Protocol: "HTTP/1.0",
+				Status:   200,
+				Size:     123,
+				Duration: 1500 * time.Microsecond,
+			},
+		},
+		{
+			name:   "Combined Format With Cache Status",
+			format: FormatCombinedCache,
+			log:    `127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 123 "-" "curl/7.79.1" "HIT from varnish" 1500`,
+			expectedEntry: LogEntry{
+				Line:      `127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 123 "-" "curl/7.79.1" "HIT from varnish" 1500`,
+				Time:      expectedTime,
+				IP:        "127.0.0.1",
+				Identity:  "-",
+				User:      "-",
+				Method:    "GET",
+				Path:      "/",
+				Protocol:  "HTTP/1.1",
+				Status:    200,
+				Size:      123,
+				Referer:   "-",
+				UserAgent: "curl/7.79.1",
+				Cache:     "HIT from varnish",
+				Duration:  1500 * time.Microsecond,
+			},
+		},
+		{
+			name:   "Combined Format With X-Forwarded-For",
+			format: FormatCombinedXFF,
+			log:    `10.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 123 "-" "curl/7.79.1" "203.0.113.7, 10.0.0.2"`,
+			expectedEntry: LogEntry{
+				Line:         `10.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 123 "-" "curl/7.79.1" "203.0.113.7, 10.0.0.2"`,
+				Time:         expectedTime,
+				IP:           "10.0.0.1",
+				Identity:     "-",
+				User:         "-",
+				Method:       "GET",
+				Path:         "/",
+				Protocol:     "HTTP/1.1",
+				Status:       200,
+				Size:         123,
+				Referer:      "-",
+				UserAgent:    "curl/7.79.1",
+				ForwardedFor: "203.0.113.7, 10.0.0.2",
+			},
+		},
+		{
+			name:   "Combined Format With Request ID",
+			format: FormatCombinedRequestID,
+			log:    `10.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 123 "-" "curl/7.79.1" "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" 1500`,
+			expectedEntry: LogEntry{
+				Line:      `10.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 123 "-" "curl/7.79.1" "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" 1500`,
+				Time:      expectedTime,
+				IP:        "10.0.0.1",
+				Identity:  "-",
+				User:      "-",
+				Method:    "GET",
+				Path:      "/",
+				Protocol:  "HTTP/1.1",
+				Status:    200,
+				Size:      123,
+				Referer:   "-",
+				UserAgent: "curl/7.79.1",
+				RequestID: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				Duration:  1500 * time.Microsecond,
+			},
+		},
+		{
+			name:   "SSL Request Format",
+			format: FormatSSLRequest,
+			log:    `[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET /api/endpoint HTTP/1.1" 123`,
+			expectedEntry: LogEntry{
+				Line:        `[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET /api/endpoint HTTP/1.1" 123`,
+				Time:        expectedTime,
+				IP:          "127.0.0.1",
+				Method:      "GET",
+				Path:        "/api/endpoint",
+				Protocol:    "HTTP/1.1",
+				Size:        123,
+				TLSProtocol: "TLSv1.2",
+				TLSCipher:   "ECDHE-RSA-AES128-GCM-SHA256",
+			},
+		},
+		{
+			name:   "Unknown Status And Size",
+			format: FormatCommon,
+			log:    `127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "-" - -`,
+			expectedEntry: LogEntry{
+				Line:     `127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "-" - -`,
+				Time:     expectedTime,
+				IP:       "127.0.0.1",
+				Identity: "-",
+				User:     "-",
+				Method:   "-",
+			},
+		},
+		{
+			name:   "Combined Format",
+			format: FormatCombined,
+			log:    `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64)"`,
+			expectedEntry: LogEntry{
+				Line:      `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64)"`,
+				Time:      expectedTime,
+				IP:        "127.0.0.1",
+				Identity:  "-",
+				User:      "frank",
+				Method:    "GET",
+				Path:      "/api/endpoint",
+				Protocol:  "HTTP/1.0",
+				Status:    200,
+				Size:      123,
+				Referer:   "https://example.com/",
+				UserAgent: "Mozilla/5.0 (X11; Linux x86_64)",
+			},
+		},
+		{
+			name:   "VHost Combined Format",
+			format: FormatVHostCombined,
+			log:    `shop.example.com:443 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET /cart HTTP/2.0" 200 123 "-" "curl/7.79.1"`,
+			expectedEntry: LogEntry{
+				Line:      `shop.example.com:443 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET /cart HTTP/2.0" 200 123 "-" "curl/7.79.1"`,
+				Time:      expectedTime,
+				IP:        "127.0.0.1",
+				Identity:  "-",
+				User:      "frank",
+				Method:    "GET",
+				Path:      "/cart",
+				Protocol:  "HTTP/2.0",
+				Status:    200,
+				Size:      123,
+				Referer:   "-",
+				UserAgent: "curl/7.79.1",
+				VHost:     "shop.example.com",
+			},
+		},
+		{
+			name:   "CRI Format",
+			format: FormatCRI,
+			log:    `2022-03-04T05:30:00Z stdout F 127.0.0.1 user-identifier frank [04/Mar/2022:05:29:59 +0000] "POST /api/endpoint HTTP/1.1" 201 42`,
+			expectedEntry: LogEntry{
+				Line:     `2022-03-04T05:30:00Z stdout F 127.0.0.1 user-identifier frank [04/Mar/2022:05:29:59 +0000] "POST /api/endpoint HTTP/1.1" 201 42`,
+				Time:     expectedTime,
+				IP:       "127.0.0.1",
+				Identity: "user-identifier",
+				User:     "frank",
+				Method:   "POST",
+				Path:     "/api/endpoint",
+				Protocol: "HTTP/1.1",
+				Status:   201,
+				Size:     42,
+			},
+		},
+		{
+			name:   "CRI Format Other Message",
+			format: FormatCRI,
+			log:    `2022-03-04T05:30:00Z stderr F AH00558: could not reliably determine the server's domain name`,
+			expectedEntry: LogEntry{
+				Line: `2022-03-04T05:30:00Z stderr F AH00558: could not reliably determine the server's domain name`,
+				Time: expectedTime,
+			},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			file := NewFormatFile(nil, test.format)
+
+			entry, err := file.parseLogEntry(test.log)
+
+			s.NoError(err)
+			s.True(test.expectedEntry.Time.Equal(entry.Time))
+			entry.Time = test.expectedEntry.Time
+			s.Equal(test.expectedEntry, entry)
+		})
+	}
+}
+
+func (s *entrySuite) Test_parseLogEntry_Error() {
+	file := NewFile(nil)
+
+	entry, err := file.parseLogEntry("this log line is not valid")
+
+	s.EqualError(err, "invalid log format on line 'this log line is not valid'")
+	s.Equal(LogEntry{Line: "this log line is not valid", Invalid: true}, entry)
+}
+
+func (s *entrySuite) Test_Query() {
+	entry := LogEntry{Path: "/search?q=apache%20logs&tag=a&tag=b&utm_source="}
+
+	s.Equal(url.Values{"q": {"apache logs"}, "tag": {"a", "b"}, "utm_source": {""}}, entry.Query())
+	s.Equal("apache logs", entry.Param("q"))
+	s.Equal("a", entry.Param("tag"))
+	s.Equal("", entry.Param("page"))
+	s.Equal(url.Values{}, LogEntry{Path: "/search"}.Query())
+	s.Equal("", LogEntry{Path: "/search"}.Param("q"))
+	// the malformed parameters are left out
+	s.Equal(url.Values{"q": {"a"}}, LogEntry{Path: "/search?q=a&bad=%zz"}.Query())
+}
+
+func TestEntry(t *testing.T) {
+	suite.Run(t, new(entrySuite))
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidLogLine is matched by every *InvalidLogLineError, for callers only
+	// interested in whether a log line didn't match the log format:
+	//
+	//	if errors.Is(err, logging.ErrInvalidLogLine) { ... }
+	ErrInvalidLogLine = errors.New("invalid log line")
+	// ErrNoFilesInWindow is returned when none of the log files contains logs within the time window.
+	ErrNoFilesInWindow = errors.New("no log files within the time window")
+	// ErrUnsupportedFormat is returned for log formats that are not supported.
+	ErrUnsupportedFormat = errors.New("unsupported log format")
+	// ErrBudgetExceeded is returned once more bytes than the maximum were read from the log files (see LogsConfig.MaxBytesRead).
+	ErrBudgetExceeded = errors.New("maximum of bytes read exceeded")
+	// ErrPluginsUnsupported is returned when loading plugins on a platform, or by a binary, not supporting them (see LoadPlugins).
+	ErrPluginsUnsupported = errors.New("plugins are not supported on this platform")
+)
+
+// InvalidLogLineError describes a log line that doesn't match the log format.
+type InvalidLogLineError struct {
+	// File is the name of the log file, empty when unknown.
+	File string
+	// Offset is the byte offset of the line inside the log file.
+	Offset int64
+	// LineNumber is the 1-based number of the line inside the log file, 0 when unknown.
+	LineNumber int
+	// Content is the content of the invalid log line.
+	Content string
+	// Err is the underlying error (e.g. the time could not be parsed), if any.
+	Err error
+}
+
+func (e *InvalidLogLineError) Error() string {
+	msg := fmt.Sprintf("invalid log format on line '%s'", e.Content)
+	if e.Err != nil {
+		msg = fmt.Sprintf("invalid log time on line '%s': %v", e.Content, e.Err)
+	}
+
+	switch {
+	case e.File != "" && e.LineNumber > 0:
+		return fmt.Sprintf("%s:%d (offset %d): %s", e.File, e.LineNumber, e.Offset, msg)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s", e.File, msg)
+	default:
+		return msg
+	}
+}
+
+// Is makes every InvalidLogLineError match ErrInvalidLogLine.
+func (e *InvalidLogLineError) Is(target error) bool {
+	return target == ErrInvalidLogLine
+}
+
+func (e *InvalidLogLineError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigError describes an invalid configuration given to New (or NewLogs).
+type ConfigError struct {
+	Field  string
+	Reason string
+	// Err is the underlying error (e.g. ErrUnsupportedFormat), if any.
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
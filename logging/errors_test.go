@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const errorsDataDir = "test/errors"
+
+type errorsSuite struct {
+	suite.Suite
+}
+
+func (s *errorsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(errorsDataDir, "http.log"), `10.0.0.2 - frank [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.0" 200 20
+`, t)
+}
+
+func (s *errorsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(errorsDataDir)))
+}
+
+func (s *errorsSuite) Test_InvalidLogLineError() {
+	file, err := os.Open(filepath.Join(errorsDataDir, "http.log"))
+	s.Require().NoError(err)
+	defer func() { s.NoError(file.Close()) }()
+
+	tests := []struct {
+		name        string
+		log         string
+		expectedErr string
+	}{
+		{
+			name:        "Invalid Format",
+			log:         "some invalid log",
+			expectedErr: "invalid log format on line 'some invalid log'",
+		},
+		{
+			name:        "Invalid Time",
+			log:         `127.0.0.1 user-identifier frank [36/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123`,
+			expectedErr: `invalid log time on line '127.0.0.1 user-identifier frank [36/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123': parsing time "36/Mar/2022:05:30:00 +0000": day out of range`,
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			_, err := NewFile(file).parseLogTime(test.log)
+
+			s.EqualError(err, file.Name()+": "+test.expectedErr)
+			s.True(errors.Is(err, ErrInvalidLogLine))
+			var lineErr *InvalidLogLineError
+			s.Require().True(errors.As(err, &lineErr))
+			s.Equal(file.Name(), lineErr.File)
+			s.Equal(test.log, lineErr.Content)
+		})
+	}
+}
+
+func (s *errorsSuite) Test_InvalidLogLineError_Position() {
+	logs := `10.0.0.1 - frank [03/Mar/2022:02:44:00 +0000] "GET /a HTTP/1.0" 200 20
+10.0.0.2 - frank [03/Mar/2022:02:44:10 +0000] "GET /b HTTP/1.0" 200 20
+this line is corrupt
+`
+	s.Require().NoError(os.WriteFile(filepath.Join(errorsDataDir, "corrupt.log"), []byte(logs), 0666))
+	defer func() { s.NoError(os.Remove(filepath.Join(errorsDataDir, "corrupt.log"))) }()
+	file, err := os.Open(filepath.Join(errorsDataDir, "corrupt.log"))
+	s.Require().NoError(err)
+	defer func() { s.NoError(file.Close()) }()
+
+	_, _, err = NewFile(file).TimeRange()
+
+	var lineErr *InvalidLogLineError
+	s.Require().True(errors.As(err, &lineErr))
+	s.Equal(file.Name(), lineErr.File)
+	s.Equal(3, lineErr.LineNumber)
+	s.Equal(int64(142), lineErr.Offset)
+	s.EqualError(err, file.Name()+":3 (offset 142): invalid log format on line 'this line is corrupt'")
+}
+
+func (s *errorsSuite) Test_UnsupportedFormat() {
+	_, err := New(WithDirectory(errorsDataDir), WithFormat("xml"))
+
+	s.True(errors.Is(err, ErrUnsupportedFormat))
+	var cfgErr *ConfigError
+	s.Require().True(errors.As(err, &cfgErr))
+	s.Equal("format", cfgErr.Field)
+}
+
+func (s *errorsSuite) Test_NoFilesInWindow() {
+	logs, err := NewLogs(LogsConfig{Directory: errorsDataDir, LastNMinutes: 1})
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC)
+	}
+
+	var buf bytes.Buffer
+	err = logs.Print(context.Background(), &buf)
+	s.True(errors.Is(err, ErrNoFilesInWindow))
+	s.Empty(buf.String())
+
+	it := logs.Entries(context.Background())
+	s.False(it.Next())
+	s.True(errors.Is(it.Err(), ErrNoFilesInWindow))
+	s.NoError(it.Close())
+
+	r := logs.Reader(context.Background())
+	data, err := io.ReadAll(r)
+	s.True(errors.Is(err, ErrNoFilesInWindow))
+	s.Empty(data)
+	s.NoError(r.Close())
+}
+
+func TestErrors(t *testing.T) {
+	suite.Run(t, new(errorsSuite))
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+)
+
+// The types of the errors counted by an ErrorSummary.
+const (
+	// ErrorInvalidFormat is the type of the log lines not matching the log format.
+	ErrorInvalidFormat = "invalid_format"
+	// ErrorInvalidTime is the type of the log lines which time could not be parsed.
+	ErrorInvalidTime = "invalid_time"
+	// ErrorRead is the type of the other errors, e.g. a log file that could not be opened or read.
+	ErrorRead = "read"
+)
+
+// ErrorSummary counts the errors met while reading the logs, per log file and per type (ErrorInvalidFormat,
+// ErrorInvalidTime or ErrorRead), e.g. to tell how trustworthy an extract is once done rather than only its first error
+// (see LogsConfig.Errors). It is safe for concurrent use, the files being read in parallel. A nil ErrorSummary counts nothing.
+type ErrorSummary struct {
+	mu    sync.Mutex
+	files map[string]*FileErrors
+}
+
+// FileErrors holds the counts of the errors of a log file, see ErrorSummary.Files.
+type FileErrors struct {
+	// File is the name of the log file, empty for the errors of no file in particular.
+	File string `json:"file"`
+	// Counts are the numbers of errors per type, e.g. ErrorInvalidFormat.
+	Counts map[string]int64 `json:"counts"`
+	// First is the message of the first error of the file.
+	First string `json:"first"`
+}
+
+// Add counts a given error, under the log file it is about when known (see InvalidLogLineError.File and os.PathError).
+func (s *ErrorSummary) Add(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	file, typ := "", ErrorRead
+	var invalidErr *InvalidLogLineError
+	var pathErr *os.PathError
+	switch {
+	case errors.As(err, &invalidErr):
+		file, typ = invalidErr.File, ErrorInvalidFormat
+		if invalidErr.Err != nil {
+			typ = ErrorInvalidTime
+		}
+	case errors.As(err, &pathErr):
+		file = pathErr.Path
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = map[string]*FileErrors{}
+	}
+	fe, ok := s.files[file]
+	if !ok {
+		fe = &FileErrors{File: file, Counts: map[string]int64{}, First: err.Error()}
+		s.files[file] = fe
+	}
+	fe.Counts[typ]++
+}
+
+// Files returns the counts of the errors so far, per log file sorted by name.
+func (s *ErrorSummary) Files() []FileErrors {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make([]FileErrors, 0, len(s.files))
+	for _, fe := range s.files {
+		counts := make(map[string]int64, len(fe.Counts))
+		for typ, n := range fe.Counts {
+			counts[typ] = n
+		}
+		files = append(files, FileErrors{File: fe.File, Counts: counts, First: fe.First})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].File < files[j].File })
+	return files
+}
+
+// Total returns the number of errors so far, of all the files and types.
+func (s *ErrorSummary) Total() int64 {
+	var total int64
+	for _, fe := range s.Files() {
+		for _, n := range fe.Counts {
+			total += n
+		}
+	}
+	return total
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const errSummaryDataDir = "test/errsummary"
+
+type errSummarySuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *errSummarySuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:01:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(errSummaryDataDir, "access.log.1"), `10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a HTTP/1.1" 200 10
+garbage
+`, t.Add(-30*time.Second))
+	writeLogFile(s.T(), filepath.Join(errSummaryDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:10:00:40 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.1 - - [33/Mar/2022:10:00:45 +0000] "GET /c HTTP/1.1" 200 10
+more garbage
+even more garbage
+10.0.0.1 - - [03/Mar/2022:10:00:50 +0000] "GET /d HTTP/1.1" 200 10
+`, t)
+}
+
+func (s *errSummarySuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(errSummaryDataDir)))
+}
+
+// expected returns the error counts of the test files.
+func (s *errSummarySuite) expected() []FileErrors {
+	return []FileErrors{
+		{
+			File:   filepath.Join(errSummaryDataDir, "access.log"),
+			Counts: map[string]int64{ErrorInvalidTime: 1, ErrorInvalidFormat: 2},
+			First:  filepath.Join(errSummaryDataDir, "access.log") + ": invalid log time on line '10.0.0.1 - - [33/Mar/2022:10:00:45 +0000] \"GET /c HTTP/1.1\" 200 10': parsing time \"33/Mar/2022:10:00:45 +0000\": day out of range",
+		},
+		{
+			File:   filepath.Join(errSummaryDataDir, "access.log.1"),
+			Counts: map[string]int64{ErrorInvalidFormat: 1},
+			First:  filepath.Join(errSummaryDataDir, "access.log.1") + ": invalid log format on line 'garbage'",
+		},
+	}
+}
+
+func (s *errSummarySuite) Test_ErrorSummary_Print() {
+	summary := &ErrorSummary{}
+	logs, err := New(WithDirectory(errSummaryDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithErrorSummary(summary))
+	s.Require().NoError(err)
+	s.False(logs.copyable())
+	s.Require().NoError(logs.Print(context.Background(), io.Discard))
+	s.Equal(s.expected(), summary.Files())
+	s.Equal(int64(4), summary.Total())
+}
+
+func (s *errSummarySuite) Test_ErrorSummary_Reverse() {
+	summary := &ErrorSummary{}
+	logs, err := New(WithDirectory(errSummaryDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithErrorSummary(summary))
+	s.Require().NoError(err)
+	s.Require().NoError(logs.ForEachReverse(context.Background(), func(LogEntry) error { return nil }))
+	// the lines are read newest first, so are their errors
+	files := summary.Files()
+	s.Require().Len(files, 2)
+	for i, expected := range s.expected() {
+		s.Equal(expected.Counts, files[i].Counts)
+	}
+	s.Equal(filepath.Join(errSummaryDataDir, "access.log")+": invalid log format on line 'even more garbage'", files[0].First)
+}
+
+func (s *errSummarySuite) Test_ErrorSummary_Add() {
+	summary := &ErrorSummary{}
+	_, err := os.Open(filepath.Join(errSummaryDataDir, "missing.log"))
+	summary.Add(err)
+	summary.Add(err)
+	summary.Add(context.Canceled)
+	summary.Add(nil)
+
+	files := summary.Files()
+	s.Require().Len(files, 2)
+	s.Equal(FileErrors{File: "", Counts: map[string]int64{ErrorRead: 1}, First: "context canceled"}, files[0])
+	s.Equal(filepath.Join(errSummaryDataDir, "missing.log"), files[1].File)
+	s.Equal(map[string]int64{ErrorRead: 2}, files[1].Counts)
+
+	var none *ErrorSummary
+	none.Add(err)
+	s.Empty(none.Files())
+	s.Zero(none.Total())
+}
+
+func TestErrSummarySuite(t *testing.T) {
+	suite.Run(t, new(errSummarySuite))
+}
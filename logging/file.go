@@ -2,12 +2,14 @@ package logging
 
 import (
 	"bufio"
-	"fmt"
+	"context"
+	"errors"
 	"io"
-	"os"
+	"math"
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
 )
 
 const (
@@ -21,171 +23,272 @@ const (
 	dateTimeFormat    = "02/Jan/2006:15:04:05 -0700"
 )
 
-// NewFile wraps an os.File, creating a special apache common log format regex
+// NewFile wraps an open log file (e.g. an os.File), creating a special apache common log format regex
 // adding useful seek & search helper functions to easier work with log files.
 // Here's an example of Apache Common Log format:
 // 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123
-func NewFile(file *os.File) File {
-	ip := fmt.Sprintf(`(?P<%s>\S+)`, ipGroupName)
-	id := fmt.Sprintf(`(?P<%s>\S+)`, idGroupName)
-	user := fmt.Sprintf(`(?P<%s>\S+)`, userGroupName)
-	datetime := fmt.Sprintf(`\[(?P<%s>[\w:/]+\s[+\-]\d{4})\]`, dateTimeGroupName)
-	request := fmt.Sprintf(`"(?P<%s>\S+)\s?(\S+)?\s?(\S+)?"`, requestGroupName)
-	status := fmt.Sprintf(`(?P<%s>\d{3}|-)`, statusGroupName)
-	size := fmt.Sprintf(`(?P<%s>\d+|-)`, sizeGroupName)
-	logFormat := fmt.Sprintf(`^%s %s %s %s %s %s %s$`, ip, id, user, datetime, request, status, size)
+func NewFile(file LogFile) File {
+	return NewFormatFile(file, FormatCommon)
+}
 
-	return File{
-		File:  file,
-		regEx: regexp.MustCompile(logFormat),
+// NewFormatFile wraps an open log file the same way NewFile does,
+// but matches the log lines against the given log format.
+func NewFormatFile(file LogFile, format Format) File {
+	f := File{
+		LogFile:    file,
+		format:     format,
+		parser:     format.parser(),
+		regEx:      format.regEx(),
+		timeLayout: format.timeLayout(),
+	}
+	if format == FormatCRI {
+		f.messageRegEx = FormatCommon.regEx()
+	}
+	if named, ok := file.(interface{ Name() string }); ok {
+		f.name = named.Name()
 	}
+	return f
 }
 
-// File represents a wrapped structure around the os.File type
-// providing additional constructs and helpers for working with log files
+// File represents a wrapped structure around an open log file (see LogFile), e.g. an os.File,
+// providing additional constructs and helpers for working with log files.
+// The helpers (IndexTime, IndexedTime, TimeRange) read the file at explicit offsets,
+// never moving the internal file cursor, so they are safe for concurrent use on the same File.
+// Read and Seek share the internal file cursor though, like the os.File does.
 type File struct {
-	*os.File
-	regEx *regexp.Regexp
+	LogFile
+	// name is the name of the log file, see Name
+	name   string
+	format Format
+	// parser parses the log lines of a custom format (see RegisterFormat), if any
+	parser     Parser
+	regEx      *regexp.Regexp
+	timeLayout string
+	// messageRegEx matches the messages wrapped by the log lines (e.g. CRI), if any
+	messageRegEx *regexp.Regexp
+	// mapping is the memory mapping the file is read through, if any (see MapFile)
+	mapping *mapping
+	// limiter limits the bytes read from the file, if any (see LogsConfig.MaxReadRate)
+	limiter *readLimiter
+	// budget fails the reads of the file once too many bytes were read, if any (see LogsConfig.MaxBytesRead)
+	budget *readBudget
+	// metrics counts the bytes read from the file and the probes of its searches, if any (see LogsConfig.Metrics)
+	metrics *Metrics
+	// skew is the offset of the clock of the host the file comes from, its log times being shifted back by it (see Host.Offset),
+	// except inside its index, which holds the times as logged
+	skew time.Duration
+	// loc is the time zone of the log times which don't give theirs (e.g. FormatError), UTC when nil (see LogsConfig.TimeZone)
+	loc *time.Location
+	// id identifies the file for its log entries, when checkpointing the shipping of the logs (see LogsConfig.Checkpoints)
+	id FileID
 }
 
+// Name returns the name of the log file: the one of the os.File, or its path within the file system of the logs
+// (see LogsConfig.FS). It is empty for the other log files.
+func (file File) Name() string {
+	return file.name
+}
+
+// indexTimeMaxProbes is the number of log lines IndexTime reads at most while searching, after which it scans
+// the lines left linearly instead. The binary search takes way fewer probes on sorted logs (about log2 of the file size),
+// so it is only reached on pathological files, e.g. logs out of order or a few huge lines stepped over one by one.
+var indexTimeMaxProbes = 128
+
 // IndexTime applies a binary search on a log file using Apache Common Log format, looking for
 // the offset of the log that is within the lookup time (that took place within the last T time).
 // offset >= 0 -> means an actual log line to begin reading logs at was found
 // offset == -1 -> all the logs inside the log file are older than the lookup time T
-func (file File) IndexTime(lookupTime time.Time) (int64, error) {
-	var pos, prevPos int64
-	scanLines := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		advance, token, err = bufio.ScanLines(data, atEOF)
-		prevPos, pos = pos, pos+int64(advance)
-		return
-	}
-
-	stat, err := file.Stat()
+// The offset is the one of the first log line that happened at or after the lookup time, given the logs are sorted.
+// The search stops with the context error as soon as the context is done.
+func (file File) IndexTime(ctx context.Context, lookupTime time.Time) (int64, error) {
+	end, err := file.end()
 	if err != nil {
 		return -1, err
 	}
-	top, bottom := int64(0), stat.Size()
-	var prevLogTime time.Time
-	for top <= bottom {
-		middle := top + (bottom-top)/2
-		_, err := file.Seek(middle, io.SeekStart)
-		if err != nil {
-			return -1, err
-		}
-
-		// reposition the middle to the beginning of the current line
-		offset, err := file.seekLine()
-		if err != nil {
-			return -1, err
-		}
-
-		scanner := bufio.NewScanner(file)
-		scanner.Split(scanLines)
-		scanner.Scan()
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			// we'll consider empty line an EOF
-			break
-		}
+	return file.search().search(ctx, end, lookupTime)
+}
 
-		logTime, err := file.parseLogTime(line)
-		if err != nil {
-			return -1, err
-		}
+// search returns the search of the log lines of the log file, shared with IndexTime over any reader.
+func (file File) search() lineSearch {
+	return lineSearch{r: file, lineStart: file.lineStart, parse: file.parseLogTime, locate: file.locate, metrics: file.metrics}
+}
 
-		if lookupTime.Sub(logTime) > 0 {
-			// the starting log is way down (relative to the middle)
-			// move down the top
-			top = offset + (pos - prevPos)
-		} else if prevLogTime.Sub(logTime) < 0 {
-			// the starting log is way up (relative to the middle)
-			// move up the bottom
-			bottom = offset - (pos - prevPos)
-		} else if lookupTime.Sub(prevLogTime) < 0 && offset != top {
-			if lookupTime.Minute() == logTime.Minute() {
-				return offset - (pos - prevPos), nil
-			}
-			return top, nil
-		}
+// scanTime returns the offset of the first log line between two given offsets that happened at or after the lookup time,
+// or -1 if there's none, reading the log lines one by one. The lines that cannot be parsed are skipped.
+func (file File) scanTime(ctx context.Context, offset, end int64, lookupTime time.Time) (int64, error) {
+	return file.search().scan(ctx, offset, end, lookupTime)
+}
 
-		if offset == top {
-			if lookupTime.Minute() == logTime.Minute() || top == 0 {
-				return top, nil
-			}
-			return offset - (pos - prevPos), nil
-		}
-		if offset == bottom {
-			if lookupTime.Minute() > logTime.Minute() {
-				return top, nil
-			}
-			return bottom, nil
-		}
+// TimeRange returns the times of the first and the last log lines inside the log file,
+// which (given the logs are sorted) bound the times of all the logs inside the file.
+func (file File) TimeRange() (time.Time, time.Time, error) {
+	first, err := file.readLogTimeAt(0)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
 
-		prevLogTime = logTime
+	end, err := file.end()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	// the line the last character of the last complete line belongs to is the last log line of the file
+	offset, err := file.lineStart(end - 1)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	last, err := file.readLogTimeAt(offset)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
 	}
 
-	return -1, nil
+	return first, last, nil
 }
 
-// seekLine sets back the file cursor to the beginning of the closest line.
-// Note: this function also repositions the internal file cursor at the closest new line offset.
-func (file File) seekLine() (int64, error) {
-	// check if we're already at the beginning of the file (offset 0)
-	offset, err := file.Seek(0, io.SeekCurrent)
+// end returns the offset right after the last complete line of the log file.
+// A last line without a trailing newline is considered complete only when it can be parsed,
+// otherwise it's most likely still being written and is left out.
+func (file File) end() (int64, error) {
+	stat, err := file.Stat()
 	if err != nil {
 		return -1, err
 	}
-	if offset == 0 {
-		return file.Seek(0, io.SeekStart)
+	return file.search().end(stat.Size())
+}
+
+// readLogTimeAt reads the log line found at the given offset and parses its time.
+func (file File) readLogTimeAt(offset int64) (time.Time, error) {
+	line, _, err := readLine(io.NewSectionReader(file, offset, math.MaxInt64-offset))
+	if err != nil {
+		return time.Time{}, err
 	}
 
-	// traverse the file backwards till we reach a newline
+	t, err := file.parseLogTime(strings.TrimSpace(line))
+	if err != nil {
+		return time.Time{}, file.locate(err, offset)
+	}
+	return t, nil
+}
+
+// readLine reads a single line from a given reader, returning it along with its length
+// in bytes (including the line ending). Unlike bufio.Scanner, which fails with
+// "token too long" for lines over 64KB, there's no limit to the length of the line.
+func readLine(r io.Reader) (string, int64, error) {
+	reader := getReader(r)
+	defer putReader(reader)
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+
+	return line, int64(len(line)), nil
+}
+
+// blank checks whether the log file contains nothing but whitespace.
+func (file File) blank() (bool, error) {
+	reader := getReader(io.NewSectionReader(file, 0, math.MaxInt64))
+	defer putReader(reader)
 	for {
-		offset, err = file.Seek(-1, io.SeekCurrent)
+		r, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return true, nil
+		}
 		if err != nil {
-			return -1, err
+			return false, err
 		}
+		if !unicode.IsSpace(r) {
+			return false, nil
+		}
+	}
+}
+
+// lineStartBlockSize is the size of the blocks read backwards by lineStart looking for the beginning of a line.
+const lineStartBlockSize = 4096
+
+// lineStart returns the offset of the beginning of the line a given offset belongs to.
+// The file is read backwards by blocks (see lineStartBlockSize), so long lines take a few reads only.
+// Only ReadAt is used, so the internal file cursor is left untouched.
+func (file File) lineStart(offset int64) (int64, error) {
+	if offset <= 0 {
+		return 0, nil
+	}
 
-		buf := make([]byte, 1)
-		_, err = file.ReadAt(buf, offset)
+	// the newlines of a memory mapped file are looked for right inside the mapping
+	if m := file.mapping; m != nil && offset <= int64(len(m.data)) {
+		i, err := m.lastNewLine(offset)
 		if err != nil {
 			return -1, err
 		}
-
-		if offset == 0 {
-			return file.Seek(0, io.SeekStart)
-		}
-
-		if buf[0] == '\n' {
-			return file.Seek(1, io.SeekCurrent)
+		if i > 0 {
+			return i + 1, nil
 		}
+		return 0, nil
 	}
+
+	// traverse the file backwards till we reach a newline
+	return lineStartAt(file, offset)
 }
 
-// parseLogTime parses a given Apache Common Log line and attempts to convert it into time.Time
+// parseLogTime parses a given log line and attempts to convert it into time.Time
 // Here's an example of Apache Common Log format:
 // 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123
 func (file File) parseLogTime(logLine string) (time.Time, error) {
-	matches := file.regEx.FindStringSubmatch(logLine)
-	if len(matches) == 0 {
-		return time.Time{}, fmt.Errorf("invalid log format on line '%s'", logLine)
+	if file.parser != nil {
+		entry, err := file.parseCustom(logLine)
+		return entry.Time, err
+	}
+	fields, ok := file.match(logLine)
+	if !ok || fields.dateTime == "" {
+		return time.Time{}, file.invalidLine(logLine, nil)
 	}
 
-	var dateTime string
-	for i, name := range file.regEx.SubexpNames() {
-		if name == dateTimeGroupName {
-			dateTime = matches[i]
-			break
-		}
+	t, err := file.parseTime(fields.dateTime)
+	if err != nil {
+		return time.Time{}, file.invalidLine(logLine, err)
 	}
-	if dateTime == "" {
-		return time.Time{}, fmt.Errorf("invalid date format on line '%s'", logLine)
+
+	return t.Add(-file.skew), nil
+}
+
+// parseTime parses the time of a log line in the time layout of the format of the file, in the time zone of the file
+// when the layout has none (see LogsConfig.TimeZone).
+func (file File) parseTime(value string) (time.Time, error) {
+	if file.loc != nil {
+		return time.ParseInLocation(file.timeLayout, value, file.loc)
 	}
+	return time.Parse(file.timeLayout, value)
+}
 
-	t, err := time.Parse(dateTimeFormat, dateTime)
-	if err != nil {
-		return time.Time{}, err
+// locate sets the position of the line found at the given offset on an InvalidLogLineError,
+// counting the lines before it, which is only worth doing once something went wrong.
+// Other errors are returned as they are.
+func (file File) locate(err error, offset int64) error {
+	var invalidErr *InvalidLogLineError
+	if !errors.As(err, &invalidErr) || file.LogFile == nil {
+		return err
 	}
 
-	return t, nil
+	invalidErr.Offset = offset
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, offset))
+	lineNumber := 1
+	for {
+		_, readErr := reader.ReadSlice('\n')
+		if readErr == bufio.ErrBufferFull {
+			continue
+		}
+		if readErr != nil {
+			break
+		}
+		lineNumber++
+	}
+	invalidErr.LineNumber = lineNumber
+	return invalidErr
+}
+
+// invalidLine creates an InvalidLogLineError for a given log line of the file.
+func (file File) invalidLine(logLine string, err error) error {
+	invalidErr := &InvalidLogLineError{Content: logLine, Err: err}
+	if file.LogFile != nil {
+		invalidErr.File = file.Name()
+	}
+	return invalidErr
 }
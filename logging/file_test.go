@@ -2,9 +2,13 @@ package logging
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"io"
+	"math/rand"
 	"os"
-	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,12 +26,12 @@ type fileSuite struct {
 }
 
 func (s *fileSuite) SetupSuite() {
-	s.Require().NoError(os.RemoveAll(path.Dir(testDataDir)))
+	s.Require().NoError(os.RemoveAll(filepath.Dir(testDataDir)))
 	s.Require().NoError(os.MkdirAll(testDataDir, 0777))
 }
 
 func (s *fileSuite) TearDownSuite() {
-	s.Require().NoError(os.RemoveAll(path.Dir(testDataDir)))
+	s.Require().NoError(os.RemoveAll(filepath.Dir(testDataDir)))
 }
 
 func (s *fileSuite) Test_NewFile() {
@@ -38,7 +42,7 @@ func (s *fileSuite) Test_NewFile() {
 	file := NewFile(f)
 
 	s.NotNil(file)
-	s.NotNil(file.File)
+	s.NotNil(file.LogFile)
 	s.NotNil(file.regEx)
 }
 
@@ -115,7 +119,7 @@ func (s *fileSuite) Test_IndexTime_Success() {
 	}
 	for _, test := range tests {
 		s.Run(test.name, func() {
-			offset, err := file.IndexTime(test.timeLookup)
+			offset, err := file.IndexTime(context.Background(), test.timeLookup)
 			log := s.readLogAt(f, offset)
 
 			s.NoError(err)
@@ -125,6 +129,140 @@ func (s *fileSuite) Test_IndexTime_Success() {
 	}
 }
 
+func (s *fileSuite) Test_IndexTime_LongLines() {
+	// lines with huge query strings are way over the 64KB bufio.Scanner limit
+	query := strings.Repeat("a", 100*1024)
+	logLine := `127.0.0.1 user-identifier frank [07/Mar/2022:02:%s +0000] "GET /api/endpoint?q=%s HTTP/1.0" 200 123`
+	var logs strings.Builder
+	for _, t := range []string{"39:32", "40:02", "40:32", "41:02", "41:32", "42:02"} {
+		logs.WriteString(fmt.Sprintf(logLine, t, query) + "\n")
+	}
+	now, err := time.Parse(dateTimeFormat, "07/Mar/2022:02:43:00 +0000")
+	s.Require().NoError(err)
+	f := s.createLogs(logs.String())
+	defer func() { s.Require().NoError(f.Close()) }()
+	file := NewFile(f)
+
+	offset, err := file.IndexTime(context.Background(), now.Add(-2*time.Minute))
+	s.NoError(err)
+	s.Equal(fmt.Sprintf(logLine, "41:02", query), s.readLogAt(f, offset))
+
+	first, last, err := file.TimeRange()
+	s.NoError(err)
+	s.Equal("02:39:32", first.Format("15:04:05"))
+	s.Equal("02:42:02", last.Format("15:04:05"))
+}
+
+// randomLogs returns random sorted log lines (or shuffled when unsorted): lines of various lengths, a few of them huge,
+// logs happening at the same time, and a few empty lines.
+func randomLogs(r *rand.Rand, from time.Time, unsorted bool) (string, []string) {
+	n := 1 + r.Intn(300)
+	lines := make([]string, 0, n)
+	t := from
+	for i := 0; i < n; i++ {
+		if r.Intn(50) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		// most logs happen a few seconds after the previous ones, some at the same time
+		t = t.Add(time.Duration(r.Intn(4)) * time.Second)
+		length := r.Intn(200)
+		if r.Intn(30) == 0 {
+			length = lineStartBlockSize + r.Intn(3*lineStartBlockSize)
+		}
+		lines = append(lines, fmt.Sprintf("10.0.0.%d - - [%s] \"GET /%s HTTP/1.1\" 200 %d", i%256, t.Format(dateTimeFormat), strings.Repeat("a", length), i))
+	}
+	if unsorted {
+		r.Shuffle(len(lines), func(i, j int) { lines[i], lines[j] = lines[j], lines[i] })
+	}
+	return strings.Join(lines, "\n") + "\n", lines
+}
+
+// referenceIndexTime returns the offset of the first log line happening at or after the lookup time, or -1,
+// reading the log lines one by one.
+func (s *fileSuite) referenceIndexTime(lines []string, lookupTime time.Time) int64 {
+	file := NewFile(nil)
+	offset := int64(0)
+	for _, line := range lines {
+		if line != "" {
+			logTime, err := file.parseLogTime(line)
+			s.Require().NoError(err)
+			if !logTime.Before(lookupTime) {
+				return offset
+			}
+		}
+		offset += int64(len(line)) + 1
+	}
+	return -1
+}
+
+func (s *fileSuite) Test_IndexTime_SameAsReference() {
+	from := time.Date(2022, 3, 7, 2, 0, 0, 0, time.UTC)
+	for _, maxProbes := range []int{indexTimeMaxProbes, 3, 0} {
+		s.Run(fmt.Sprintf("%d Probes", maxProbes), func() {
+			defer func(probes int) { indexTimeMaxProbes = probes }(indexTimeMaxProbes)
+			indexTimeMaxProbes = maxProbes
+
+			r := rand.New(rand.NewSource(int64(maxProbes)))
+			for i := 0; i < 50; i++ {
+				logs, lines := randomLogs(r, from, false)
+				f := s.createLogs(logs)
+				file := NewFile(f)
+				last := from.Add(time.Duration(len(lines)) * 3 * time.Second)
+				// the exact times of the logs, the times between them, and the times around all of them
+				for _, lookupTime := range []time.Time{
+					from.Add(-time.Second),
+					from,
+					from.Add(time.Duration(r.Int63n(int64(last.Sub(from)))) / time.Second * time.Second),
+					from.Add(time.Duration(r.Int63n(int64(last.Sub(from))))),
+					last.Add(time.Second),
+				} {
+					offset, err := file.IndexTime(context.Background(), lookupTime)
+					s.Require().NoError(err)
+					s.Require().Equal(s.referenceIndexTime(lines, lookupTime), offset, "%s, %d lines", lookupTime, len(lines))
+				}
+				s.Require().NoError(f.Close())
+			}
+		})
+	}
+}
+
+func (s *fileSuite) Test_IndexTime_Unsorted() {
+	// logs out of order cannot be searched, but the search still ends on a log line at or after the lookup time
+	from := time.Date(2022, 3, 7, 2, 0, 0, 0, time.UTC)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		logs, lines := randomLogs(r, from, true)
+		f := s.createLogs(logs)
+		file := NewFile(f)
+		lookupTime := from.Add(time.Duration(r.Intn(len(lines))) * time.Second)
+
+		offset, err := file.IndexTime(context.Background(), lookupTime)
+		s.Require().NoError(err)
+		if offset >= 0 {
+			line := s.readLogAt(f, offset)
+			s.Require().Equal(int64(strings.Index(logs, line+"\n")), offset)
+			logTime, err := file.parseLogTime(line)
+			s.Require().NoError(err)
+			s.False(logTime.Before(lookupTime))
+		}
+		s.Require().NoError(f.Close())
+	}
+}
+
+func (s *fileSuite) Test_IndexTime_Cancelled() {
+	f := s.createLogs(`127.0.0.1 user-identifier frank [07/Mar/2022:02:39:32 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`)
+	defer func() { s.Require().NoError(f.Close()) }()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	offset, err := NewFile(f).IndexTime(ctx, time.Now())
+
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(int64(-1), offset)
+}
+
 func (s *fileSuite) Test_IndexTime_Error() {
 	f := s.createLogs("some invalid log line\n")
 	defer func() { s.Require().NoError(f.Close()) }()
@@ -132,13 +270,101 @@ func (s *fileSuite) Test_IndexTime_Error() {
 	s.NotNil(file)
 
 	lookupTime := time.Now().UTC().Add(-1 * time.Minute)
-	offset, err := file.IndexTime(lookupTime)
+	offset, err := file.IndexTime(context.Background(), lookupTime)
 
-	s.EqualError(err, "invalid log format on line 'some invalid log line'")
+	s.EqualError(err, f.Name()+":1 (offset 0): invalid log format on line 'some invalid log line'")
 	s.Equal(int64(-1), offset)
 }
 
-func (s *fileSuite) Test_seekLine() {
+func (s *fileSuite) Test_TimeRange_Success() {
+	tests := []struct {
+		name          string
+		logs          string
+		expectedFirst string
+		expectedLast  string
+	}{
+		{
+			name: "Multiple Lines",
+			logs: `127.0.0.1 user-identifier frank [07/Mar/2022:02:39:32 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [07/Mar/2022:02:39:42 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [07/Mar/2022:02:39:52 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`,
+			expectedFirst: "07/Mar/2022:02:39:32 +0000",
+			expectedLast:  "07/Mar/2022:02:39:52 +0000",
+		},
+		{
+			name: "Single Line",
+			logs: `127.0.0.1 user-identifier frank [07/Mar/2022:02:39:32 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`,
+			expectedFirst: "07/Mar/2022:02:39:32 +0000",
+			expectedLast:  "07/Mar/2022:02:39:32 +0000",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			f := s.createLogs(test.logs)
+			defer func() { s.Require().NoError(f.Close()) }()
+			expectedFirst, err := time.Parse(dateTimeFormat, test.expectedFirst)
+			s.Require().NoError(err)
+			expectedLast, err := time.Parse(dateTimeFormat, test.expectedLast)
+			s.Require().NoError(err)
+
+			first, last, err := NewFile(f).TimeRange()
+
+			s.NoError(err)
+			s.True(first.Equal(expectedFirst))
+			s.True(last.Equal(expectedLast))
+		})
+	}
+}
+
+func (s *fileSuite) Test_TimeRange_Error() {
+	f := s.createLogs("some invalid log line\n")
+	defer func() { s.Require().NoError(f.Close()) }()
+
+	first, last, err := NewFile(f).TimeRange()
+
+	s.EqualError(err, f.Name()+":1 (offset 0): invalid log format on line 'some invalid log line'")
+	s.True(first.IsZero())
+	s.True(last.IsZero())
+}
+
+func (s *fileSuite) Test_blank() {
+	tests := []struct {
+		name     string
+		logs     string
+		expected bool
+	}{
+		{
+			name:     "Empty File",
+			logs:     "",
+			expected: true,
+		},
+		{
+			name:     "Whitespace Only",
+			logs:     " \n\t\r\n\n",
+			expected: true,
+		},
+		{
+			name:     "Logs After Blank Lines",
+			logs:     "\n\n127.0.0.1 user-identifier frank [07/Mar/2022:02:39:32 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\n",
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			f := s.createLogs(test.logs)
+			defer func() { s.Require().NoError(f.Close()) }()
+
+			blank, err := NewFile(f).blank()
+
+			s.NoError(err)
+			s.Equal(test.expected, blank)
+		})
+	}
+}
+
+func (s *fileSuite) Test_lineStart() {
 	data := "some\ntest\nstring\n"
 	f := s.createLogs(data)
 	defer func() { s.Require().NoError(f.Close()) }()
@@ -181,12 +407,10 @@ func (s *fileSuite) Test_seekLine() {
 	}
 	for _, test := range tests {
 		s.Run(test.name, func() {
-			_, err := f.Seek(test.currentOffset, io.SeekStart)
-			s.NoError(err)
 			file := NewFile(f)
 			s.NotNil(file)
 
-			offset, err := file.seekLine()
+			offset, err := file.lineStart(test.currentOffset)
 
 			s.NoError(err)
 			s.Equal(test.expectedOffset, offset)
@@ -194,6 +418,32 @@ func (s *fileSuite) Test_seekLine() {
 	}
 }
 
+func (s *fileSuite) Test_lineStart_LongLines() {
+	// lines shorter and longer than the blocks read backwards, with newlines right at their boundaries
+	var data strings.Builder
+	for _, length := range []int{lineStartBlockSize - 1, 1, 3 * lineStartBlockSize, lineStartBlockSize, 0, 10} {
+		data.WriteString(strings.Repeat("a", length) + "\n")
+	}
+	logs := data.String()
+	f := s.createLogs(logs)
+	defer func() { s.Require().NoError(f.Close()) }()
+	file := NewFile(f)
+	position, err := f.Seek(0, io.SeekCurrent)
+	s.Require().NoError(err)
+
+	for current := int64(0); current <= int64(len(logs)); current++ {
+		offset, err := file.lineStart(current)
+		s.Require().NoError(err)
+		expected := int64(strings.LastIndexByte(logs[:current], '\n') + 1)
+		s.Require().Equal(expected, offset, current)
+	}
+
+	// the internal file cursor is left untouched
+	current, err := f.Seek(0, io.SeekCurrent)
+	s.Require().NoError(err)
+	s.Equal(position, current)
+}
+
 func (s *fileSuite) Test_parseLogTime_Success() {
 	log := `127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123`
 	expectedTime, err := time.Parse(dateTimeFormat, "04/Mar/2022:05:30:00 +0000")
@@ -228,7 +478,7 @@ func (s *fileSuite) Test_parseLogTime_Error() {
 		{
 			name:        "Invalid DateFormat",
 			log:         `127.0.0.1 user-identifier frank [36/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123`,
-			expectedErr: `parsing time "36/Mar/2022:05:30:00 +0000": day out of range`,
+			expectedErr: `invalid log time on line '127.0.0.1 user-identifier frank [36/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123': parsing time "36/Mar/2022:05:30:00 +0000": day out of range`,
 		},
 	}
 	for _, test := range tests {
@@ -261,9 +511,11 @@ func (s *fileSuite) readLogAt(file *os.File, offset int64) string {
 	_, err := file.Seek(offset, io.SeekStart)
 	s.Require().NoError(err)
 
-	scanner := bufio.NewScanner(file)
-	scanner.Scan()
-	return scanner.Text()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != io.EOF {
+		s.Require().NoError(err)
+	}
+	return strings.TrimSuffix(line, "\n")
 }
 
 func TestLogFile(t *testing.T) {
@@ -274,7 +526,7 @@ func TestLogFile(t *testing.T) {
 // and make sure to store it inside benchDataDir
 func BenchmarkIndexTime(b *testing.B) {
 	// log-generator stores the big data in http-1.log
-	f, err := os.Open(path.Join(benchDataDir, "http-1.log"))
+	f, err := os.Open(filepath.Join(benchDataDir, "http-1.log"))
 	defer func() { require.NoError(b, f.Close()) }()
 	require.NoError(b, err)
 	file := NewFile(f)
@@ -285,9 +537,85 @@ func BenchmarkIndexTime(b *testing.B) {
 	// and check for execution time and memory footprint
 	for i := 0; i < b.N; i++ {
 		lookupTime := time.Now().UTC().Add(-time.Duration(i) * time.Minute)
-		_, err = file.IndexTime(lookupTime)
+		_, err = file.IndexTime(context.Background(), lookupTime)
 		require.NoError(b, err)
 	}
 
 	b.ReportAllocs()
 }
+
+// writeLongLines writes a log file of n log lines of a given length (at least), one every second, returning its path.
+func writeLongLines(b *testing.B, n, length int) string {
+	name := filepath.Join(b.TempDir(), "http.log")
+	var logs strings.Builder
+	from := time.Now().UTC().Add(-time.Duration(n) * time.Second)
+	for i := 0; i < n; i++ {
+		logs.WriteString(fmt.Sprintf("127.0.0.1 - - [%s] \"GET /%s HTTP/1.1\" 200 1\n",
+			from.Add(time.Duration(i)*time.Second).Format(dateTimeFormat), strings.Repeat("a", length)))
+	}
+	require.NoError(b, os.WriteFile(name, []byte(logs.String()), 0666))
+	return name
+}
+
+func BenchmarkLineStart(b *testing.B) {
+	for _, length := range []int{100, 10 << 10, 1 << 20} {
+		b.Run(fmt.Sprintf("LineLength-%d", length), func(b *testing.B) {
+			f, err := os.Open(writeLongLines(b, 10, length))
+			require.NoError(b, err)
+			defer func() { require.NoError(b, f.Close()) }()
+			file := NewFile(f)
+			end, err := file.end()
+			require.NoError(b, err)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			// from the end of a line, the whole line is walked through
+			for i := 0; i < b.N; i++ {
+				_, err := file.lineStart(end - 1)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkIndexTime_LongLines(b *testing.B) {
+	f, err := os.Open(writeLongLines(b, 1000, 64<<10))
+	require.NoError(b, err)
+	defer func() { require.NoError(b, f.Close()) }()
+	file := NewFile(f)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lookupTime := time.Now().UTC().Add(-time.Duration(i%1000) * time.Second)
+		_, err = file.IndexTime(context.Background(), lookupTime)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkOpenRotatedFiles(b *testing.B) {
+	// a directory of many small rotated files, each of them opened and searched
+	dir := b.TempDir()
+	from := time.Now().UTC().Add(-time.Hour)
+	for i := 0; i < 200; i++ {
+		var logs strings.Builder
+		for j := 0; j < 20; j++ {
+			logs.WriteString(fmt.Sprintf("127.0.0.1 - - [%s] \"GET /%d HTTP/1.1\" 200 1\n",
+				from.Add(time.Duration(i*20+j)*time.Second).Format(dateTimeFormat), j))
+		}
+		require.NoError(b, os.WriteFile(filepath.Join(dir, fmt.Sprintf("access.log.%d", i)), []byte(logs.String()), 0666))
+	}
+	lookupTime := from.Add(30 * time.Minute)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 200; j++ {
+			f, err := os.Open(filepath.Join(dir, fmt.Sprintf("access.log.%d", j)))
+			require.NoError(b, err)
+			_, err = NewFormatFile(f, FormatCommon).IndexTime(context.Background(), lookupTime)
+			require.NoError(b, err)
+			require.NoError(b, f.Close())
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package logging
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIDOf returns the device and the inode of a file given its info, opened or not.
+func fileIDOf(_ *os.File, info os.FileInfo) FileID {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileID{}
+	}
+	return FileID{Device: uint64(stat.Dev), Inode: uint64(stat.Ino)}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package logging
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIDOf returns the serial number of the volume and the index of an opened file, which the info of a file lacks
+// on Windows, so the files keep their identity once renamed by a rotation the way inodes do. There is none without the file.
+func fileIDOf(file *os.File, _ os.FileInfo) FileID {
+	var d syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(file.Fd()), &d); err != nil {
+		return FileID{}
+	}
+	return FileID{Device: uint64(d.VolumeSerialNumber), Inode: uint64(d.FileIndexHigh)<<32 | uint64(d.FileIndexLow)}
+}
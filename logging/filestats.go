@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"strings"
+	"time"
+)
+
+// fileStats are the statistics of a log file once read, reported along with the time it took (see LogsConfig.Logger),
+// e.g. to tune the filters or to spot a file left out unexpectedly.
+type fileStats struct {
+	// bytes counts the bytes of the lines read, the ones before the time range included
+	bytes int64
+	// lines counts the log lines of the time range, out of which the invalid ones
+	lines, invalid int
+	// emitted and filtered count the log lines accepted and left out by the filters (see Logs.accept),
+	// the emitted ones happening from first to last
+	emitted, filtered int
+	first, last       time.Time
+	// parsing is the time spent parsing the lines, when timed
+	parsing time.Duration
+}
+
+// parse parses a given log line of a given file (see File.parseLogEntry), timing it when told so.
+func (s *fileStats) parse(file File, line string, timed bool) (LogEntry, error) {
+	if !timed {
+		return file.parseLogEntry(strings.TrimSpace(line))
+	}
+	start := time.Now()
+	entry, err := file.parseLogEntry(strings.TrimSpace(line))
+	s.parsing += time.Since(start)
+	return entry, err
+}
+
+// accepted counts a log line of a given time either accepted or left out by the filters.
+func (s *fileStats) accepted(t time.Time, accepted bool) {
+	if !accepted {
+		s.filtered++
+		return
+	}
+	s.emitted++
+	if s.first.IsZero() || t.Before(s.first) {
+		s.first = t
+	}
+	if t.After(s.last) {
+		s.last = t
+	}
+}
+
+// args returns the arguments of the message reporting the statistics of a given file, read in a given time,
+// the time range of the emitted lines being left out when there are none.
+func (s *fileStats) args(name string, took time.Duration) []interface{} {
+	args := []interface{}{"file", name, "bytes", s.bytes, "lines", s.lines, "invalid", s.invalid, "emitted", s.emitted, "filtered", s.filtered}
+	if s.emitted > 0 {
+		args = append(args, "from", s.first, "to", s.last)
+	}
+	return append(args, "parsing", s.parsing, "took", took)
+}
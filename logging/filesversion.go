@@ -0,0 +1,24 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Version returns a fingerprint of the log files of the directory (or of the hosts, see LogsConfig.Hosts) as of now,
+// made of their paths, sizes and modified times: it changes whenever a file is written, rotated, added or removed,
+// e.g. to tell whether a result computed from the logs is still current.
+func (logs *Logs) Version() (string, error) {
+	files, err := listLogFiles(logs.cfg)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	h := sha256.New()
+	for _, fi := range files {
+		_, _ = fmt.Fprintf(h, "%s\x00%d\x00%d\n", fi.path, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const filesVersionDataDir = "test/filesversion"
+
+type filesVersionSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *filesVersionSuite) SetupTest() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:01:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(filesVersionDataDir, "access.log"), "10.0.0.1 - - [03/Mar/2022:10:00:40 +0000] \"GET /a HTTP/1.1\" 200 10\n", t)
+	writeLogFile(s.T(), filepath.Join(filesVersionDataDir, "access.log.1"), "10.0.0.1 - - [03/Mar/2022:09:00:40 +0000] \"GET /b HTTP/1.1\" 200 10\n", t.Add(-time.Hour))
+}
+
+func (s *filesVersionSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(filesVersionDataDir)))
+}
+
+// version returns the version of the logs of the test directory.
+func (s *filesVersionSuite) version() string {
+	logs, err := New(WithDirectory(filesVersionDataDir), WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	version, err := logs.Version()
+	s.Require().NoError(err)
+	return version
+}
+
+func (s *filesVersionSuite) Test_Version_Unchanged() {
+	s.Equal(s.version(), s.version())
+}
+
+func (s *filesVersionSuite) Test_Version_Written() {
+	before := s.version()
+	writeLogFile(s.T(), filepath.Join(filesVersionDataDir, "access.log"), "10.0.0.1 - - [03/Mar/2022:10:00:40 +0000] \"GET /a HTTP/1.1\" 200 10\n"+
+		"10.0.0.1 - - [03/Mar/2022:10:00:50 +0000] \"GET /c HTTP/1.1\" 200 10\n", s.testTime)
+	s.NotEqual(before, s.version())
+}
+
+func (s *filesVersionSuite) Test_Version_Touched() {
+	before := s.version()
+	s.Require().NoError(os.Chtimes(filepath.Join(filesVersionDataDir, "access.log"), s.testTime.Add(time.Second), s.testTime.Add(time.Second)))
+	s.NotEqual(before, s.version())
+}
+
+func (s *filesVersionSuite) Test_Version_Rotated() {
+	before := s.version()
+	s.Require().NoError(os.Rename(filepath.Join(filesVersionDataDir, "access.log.1"), filepath.Join(filesVersionDataDir, "access.log.2")))
+	s.Require().NoError(os.Rename(filepath.Join(filesVersionDataDir, "access.log"), filepath.Join(filesVersionDataDir, "access.log.1")))
+	writeLogFile(s.T(), filepath.Join(filesVersionDataDir, "access.log"), "", s.testTime)
+	s.NotEqual(before, s.version())
+}
+
+func (s *filesVersionSuite) Test_Version_MissingDirectory() {
+	logs, err := New(WithDirectory(filesVersionDataDir), WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	s.Require().NoError(os.RemoveAll(filesVersionDataDir))
+	_, err = logs.Version()
+	s.Error(err)
+}
+
+func TestFilesVersionSuite(t *testing.T) {
+	suite.Run(t, new(filesVersionSuite))
+}
@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nameTimePattern matches the names of the log files embedding the time of their logs, e.g. access-%Y%m%d-%H.log
+// (see LogsConfig.FilenameTimePattern), giving the period each of them covers.
+type nameTimePattern struct {
+	re *regexp.Regexp
+	// fields are the directives of the submatches of re, in order, e.g. 'Y' for %Y
+	fields []byte
+	// period is the period covered by a file, the one of the finest directive, e.g. an hour for %H,
+	// which is 0 for the months and the years, their lengths varying
+	period time.Duration
+	// loc is the time zone of the times of the file names, UTC when nil (see LogsConfig.TimeZone)
+	loc *time.Location
+}
+
+// nameTimeDirectives are the supported directives of the file name time patterns, along with their number of digits.
+var nameTimeDirectives = map[byte]int{'Y': 4, 'm': 2, 'd': 2, 'H': 2, 'M': 2, 'S': 2}
+
+// parseNameTimePattern parses a file name time pattern made of strftime directives (%Y, %m, %d, %H, %M, %S, and %%
+// for a percent sign), e.g. access-%Y%m%d-%H.log. The pattern requires at least a year.
+func parseNameTimePattern(pattern string) (*nameTimePattern, error) {
+	p := &nameTimePattern{}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' {
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+			continue
+		}
+		if i++; i == len(pattern) {
+			return nil, fmt.Errorf("trailing %% in '%s'", pattern)
+		}
+		directive := pattern[i]
+		if directive == '%' {
+			expr.WriteString("%")
+			continue
+		}
+		digits, ok := nameTimeDirectives[directive]
+		if !ok {
+			return nil, fmt.Errorf("unsupported directive %%%c in '%s': must be %%Y, %%m, %%d, %%H, %%M or %%S", directive, pattern)
+		}
+		expr.WriteString("(" + strings.Repeat("[0-9]", digits) + ")")
+		p.fields = append(p.fields, directive)
+	}
+	expr.WriteString("$")
+
+	has := func(directive byte) bool { return strings.IndexByte(string(p.fields), directive) >= 0 }
+	switch {
+	case !has('Y'):
+		return nil, fmt.Errorf("no year (%%Y) in '%s'", pattern)
+	case has('S'):
+		p.period = time.Second
+	case has('M'):
+		p.period = time.Minute
+	case has('H'):
+		p.period = time.Hour
+	case has('d'):
+		p.period = 24 * time.Hour
+	}
+	p.re = regexp.MustCompile(expr.String())
+	return p, nil
+}
+
+// match returns the period covered by the log file of a given name, in UTC the way rotatelogs names its files
+// by default (or in the time zone of the pattern, if any), or false when the name doesn't match the pattern. The compressed rotations match it once their
+// suffix (.gz) is stripped.
+func (p *nameTimePattern) match(name string) (from, to time.Time, ok bool) {
+	m := p.re.FindStringSubmatch(strings.TrimSuffix(name, compressedSuffix))
+	if m == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	values := map[byte]int{'m': 1, 'd': 1}
+	for i, field := range p.fields {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		values[field] = n
+	}
+	loc := p.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	from = time.Date(values['Y'], time.Month(values['m']), values['d'], values['H'], values['M'], values['S'], 0, loc)
+	switch {
+	case p.period > 0:
+		to = from.Add(p.period)
+	case strings.IndexByte(string(p.fields), 'm') >= 0:
+		to = from.AddDate(0, 1, 0)
+	default:
+		to = from.AddDate(1, 0, 0)
+	}
+	return from, to, true
+}
+
+// nameTimes sets the times of the given log files which names match the file name time pattern, if any
+// (see LogsConfig.FilenameTimePattern), to the start and the end of the period they cover, shifted by the offset
+// of the clock of their host, if any, the way their log times are (see Host.Offset).
+func (logs *Logs) nameTimes(files []logFile) {
+	if logs.nameTime == nil {
+		return
+	}
+	for i, fi := range files {
+		from, to, ok := logs.nameTime.match(fi.Name())
+		if !ok {
+			continue
+		}
+		skew := logs.skew(fi.path)
+		files[i].first, files[i].last = from.Add(-skew), to.Add(-skew)
+	}
+}
+
+// beforeEnd drops the log files which names embed a time after the end of the time range from the given files
+// (see LogsConfig.FilenameTimePattern), since they contain no logs of it.
+func (logs *Logs) beforeEnd(files []logFile) []logFile {
+	if logs.nameTime == nil {
+		return files
+	}
+	end := logs.cfg.end()
+	filtered := files[:0]
+	for _, fi := range files {
+		if fi.first.IsZero() || !fi.first.After(end) {
+			filtered = append(filtered, fi)
+		}
+	}
+	return filtered
+}
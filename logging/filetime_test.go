@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const filetimeDataDir = "test/filetime"
+
+type filetimeSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *filetimeSuite) SetupSuite() {
+	// the files were copied around, so they all have the same modified time
+	copied := parseLogTime(s.T(), "04/Mar/2022:00:00:00 +0000")
+	for hour := 8; hour <= 11; hour++ {
+		file := filepath.Join(filetimeDataDir, fmt.Sprintf("access-20220303-%02d.log", hour))
+		writeLogFile(s.T(), file, fmt.Sprintf(`10.0.0.1 - - [03/Mar/2022:%02d:15:00 +0000] "GET /%d-15 HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:%02d:45:00 +0000] "GET /%d-45 HTTP/1.1" 200 10
+`, hour, hour, hour, hour), copied)
+	}
+	s.testTime = parseLogTime(s.T(), "03/Mar/2022:10:30:00 +0000")
+}
+
+func (s *filetimeSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(filetimeDataDir)))
+}
+
+func (s *filetimeSuite) Test_FilenameTimePattern() {
+	logs, err := New(WithDirectory(filetimeDataDir), WithFilenameTimePattern("access-%Y%m%d-%H.log"),
+		WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	// the file of 11h is left out
+	s.Len(logs.filesInfo, 3)
+
+	var paths []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+	s.Equal([]string{"/9-45", "/10-15"}, paths)
+}
+
+func (s *filetimeSuite) Test_FilenameTimePattern_TimeZone() {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	s.Require().NoError(err)
+	// named after the hours of Berlin, an hour ahead of UTC, the file of 11h starts at 10h UTC
+	logs, err := New(WithDirectory(filetimeDataDir), WithFilenameTimePattern("access-%Y%m%d-%H.log"),
+		WithTimeZone(berlin), WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	s.Len(logs.filesInfo, 4)
+}
+
+func (s *filetimeSuite) Test_FilenameTimePattern_Invalid() {
+	tests := []struct {
+		pattern     string
+		expectedErr string
+	}{
+		{pattern: "access-%m%d.log", expectedErr: "invalid filename time pattern: no year (%Y) in 'access-%m%d.log'"},
+		{pattern: "access-%Y%j.log", expectedErr: "invalid filename time pattern: unsupported directive %j in 'access-%Y%j.log': must be %Y, %m, %d, %H, %M or %S"},
+		{pattern: "access-%Y%", expectedErr: "invalid filename time pattern: trailing % in 'access-%Y%'"},
+	}
+	for _, test := range tests {
+		_, err := New(WithDirectory(filetimeDataDir), WithFilenameTimePattern(test.pattern))
+		s.EqualError(err, test.expectedErr)
+	}
+}
+
+func (s *filetimeSuite) Test_NameTimePattern_Match() {
+	p, err := parseNameTimePattern("access-%Y%m%d-%H.log")
+	s.Require().NoError(err)
+
+	from, to, ok := p.match("access-20240512-10.log.gz")
+	s.Require().True(ok)
+	s.Equal(time.Date(2024, time.May, 12, 10, 0, 0, 0, time.UTC), from)
+	s.Equal(time.Date(2024, time.May, 12, 11, 0, 0, 0, time.UTC), to)
+
+	_, _, ok = p.match("access.log")
+	s.False(ok)
+	_, _, ok = p.match("access-20240512-10.log.1")
+	s.False(ok)
+
+	p, err = parseNameTimePattern("%%access.%Y-%m")
+	s.Require().NoError(err)
+	from, to, ok = p.match("%access.2024-12")
+	s.Require().True(ok)
+	s.Equal(time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC), from)
+	s.Equal(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), to)
+}
+
+func TestFiletimeSuite(t *testing.T) {
+	suite.Run(t, new(filetimeSuite))
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	filtersMu sync.RWMutex
+	filters   = make(map[string]func(arg string) (Filter, error))
+)
+
+// RegisterFilter makes a filter available by a given name, e.g. to be picked from the command line,
+// creating a new Filter for a given argument (e.g. a threshold or a pattern, empty when none) using the given function
+// (see NewFilter). It's meant to be called from the init function of the package implementing the filter,
+// e.g. a plugin (see LoadPlugins), and panics when called twice with the same name or with a nil function.
+func RegisterFilter(name string, newFilter func(arg string) (Filter, error)) {
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+	if newFilter == nil {
+		panic("logging: RegisterFilter function is nil")
+	}
+	if _, dup := filters[name]; dup {
+		panic("logging: RegisterFilter called twice for filter " + name)
+	}
+	filters[name] = newFilter
+}
+
+// NewFilter creates a new Filter of the filter registered by a given name for a given argument, see RegisterFilter.
+func NewFilter(name, arg string) (Filter, error) {
+	filtersMu.RLock()
+	newFilter, ok := filters[name]
+	filtersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown filter '%s'", name)
+	}
+	return newFilter(arg)
+}
+
+// ParseFilter creates the Filter of a given name, optionally followed by a colon and its argument,
+// e.g. "internal" or "slower-than:500ms", see NewFilter.
+func ParseFilter(s string) (Filter, error) {
+	name, arg := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		name, arg = s[:i], s[i+1:]
+	}
+	return NewFilter(strings.TrimSpace(name), arg)
+}
+
+// Filters returns the sorted names of the registered filters.
+func Filters() []string {
+	filtersMu.RLock()
+	defer filtersMu.RUnlock()
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
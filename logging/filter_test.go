@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type filterSuite struct {
+	suite.Suite
+}
+
+func (s *filterSuite) Test_RegisterFilter() {
+	RegisterFilter("test-status-above", func(arg string) (Filter, error) {
+		status, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, errors.New("the status must be a number")
+		}
+		return func(entry LogEntry) bool { return entry.Status > status }, nil
+	})
+
+	s.Contains(Filters(), "test-status-above")
+	filter, err := ParseFilter("test-status-above:399")
+	s.Require().NoError(err)
+	s.True(filter(LogEntry{Status: 404}))
+	s.False(filter(LogEntry{Status: 200}))
+	_, err = ParseFilter("test-status-above")
+	s.EqualError(err, "the status must be a number")
+
+	s.Panics(func() {
+		RegisterFilter("test-status-above", func(string) (Filter, error) { return nil, nil })
+	})
+	s.Panics(func() {
+		RegisterFilter("test-nil", nil)
+	})
+	_, err = NewFilter("test-unknown", "")
+	s.EqualError(err, "unknown filter 'test-unknown'")
+}
+
+func TestFilterSuite(t *testing.T) {
+	suite.Run(t, new(filterSuite))
+}
@@ -0,0 +1,283 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// followPollInterval is how often Follow checks the log files for new lines and the directory for new files by default,
+// see LogsConfig.PollInterval.
+const followPollInterval = time.Second
+
+// pollInterval returns how often Follow checks for new logs, see LogsConfig.PollInterval.
+func (cfg LogsConfig) pollInterval() time.Duration {
+	if cfg.PollInterval > 0 {
+		return cfg.PollInterval
+	}
+	return followPollInterval
+}
+
+// Follow calls the given function for each log entry appended to the log files from now on, in order, the same
+// way `tail -F` does, until the context is done (returning its error) or the function returns an error.
+// ErrStop stops following the logs and makes Follow return nil.
+// New files (e.g. rotations) are read from their beginning, while the files that are renamed (e.g. access.log
+// becoming access.log.1) or truncated (e.g. copytruncate) keep being read without losing or duplicating lines.
+// The entries are classified and filtered the same way as the ones within the last N minutes.
+// The logs of a file system other than the one of the operating system (see LogsConfig.FS) cannot be followed.
+func (logs *Logs) Follow(ctx context.Context, fn func(LogEntry) error) error {
+	if logs.cfg.FS != nil {
+		return &ConfigError{Field: "fs", Reason: "cannot be followed"}
+	}
+	f := &follower{logs: logs}
+	defer f.close()
+
+	ticker := time.NewTicker(logs.pollInterval)
+	defer ticker.Stop()
+	for initial := true; ; initial = false {
+		err := f.poll(initial, fn)
+		if errors.Is(err, ErrStop) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// follower keeps track of the log files followed by Follow.
+type follower struct {
+	logs  *Logs
+	files []*followedFile
+	// opened is the number of followed files currently open, kept under a maximum, if any (see LogsConfig.MaxOpenFiles)
+	opened int
+}
+
+// followedFile is a log file followed by Follow, along with the offset of the following line to read.
+type followedFile struct {
+	file   File
+	info   os.FileInfo
+	offset int64
+	// last is the time of the previous line, inherited by the lines that cannot be parsed
+	last time.Time
+	// closed tells the file was read until its end then closed to stay under the maximum of open files,
+	// so it's opened again once grown (see LogsConfig.MaxOpenFiles)
+	closed bool
+}
+
+// poll reads the new lines of the followed files, after checking the directory for new, renamed or removed files.
+// The files present initially are read from their end, while the ones appearing afterwards are read entirely.
+func (f *follower) poll(initial bool, fn func(LogEntry) error) error {
+	filesInfo, err := listLogFiles(f.logs.cfg)
+	if err != nil {
+		return err
+	}
+	sort.Slice(filesInfo, func(i, j int) bool {
+		return filesInfo[i].ModTime().Before(filesInfo[j].ModTime())
+	})
+
+	var files []*followedFile
+	for _, fi := range filesInfo {
+		ff := f.find(fi)
+		switch {
+		case ff == nil:
+			ff, err = f.open(fi, initial)
+		case ff.closed && fi.Size() != ff.offset:
+			err = f.reopen(ff, fi)
+		}
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		ff.info = fi.FileInfo
+		files = append(files, ff)
+		f.limit(files)
+	}
+
+	// the files gone from the directory still get their last lines read
+	for _, ff := range f.files {
+		if !containsFollowed(files, ff) && !ff.closed {
+			err := ff.read(f.logs, fn)
+			f.closeFile(ff)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	f.files = files
+
+	for _, ff := range f.files {
+		if ff.closed {
+			continue
+		}
+		if err := ff.read(f.logs, fn); err != nil {
+			return err
+		}
+	}
+	f.limit(f.files)
+	return nil
+}
+
+// limit closes the least recently modified of the given files read until their end while there are more open files
+// than the maximum, if any (see LogsConfig.MaxOpenFiles). The files are expected by modified time, the oldest first.
+func (f *follower) limit(files []*followedFile) {
+	max := f.logs.cfg.MaxOpenFiles
+	for _, ff := range files {
+		if max <= 0 || f.opened <= max {
+			return
+		}
+		if !ff.closed && ff.offset >= ff.info.Size() {
+			f.closeFile(ff)
+			ff.closed = true
+		}
+	}
+}
+
+// closeFile closes a given followed file.
+func (f *follower) closeFile(ff *followedFile) {
+	_ = ff.file.Close()
+	f.opened--
+}
+
+// find returns the followed file that is the same underlying file as a given one, whatever its name, if any.
+func (f *follower) find(fi logFile) *followedFile {
+	for _, ff := range f.files {
+		if os.SameFile(ff.info, fi.FileInfo) {
+			return ff
+		}
+	}
+	return nil
+}
+
+// open starts following a given file, from its end when it is there initially,
+// unless resuming after its checkpoint (see LogsConfig.Checkpoints and Checkpoints.resumeOffset).
+func (f *follower) open(fi logFile, initial bool) (*followedFile, error) {
+	file, err := openLogFile(fi.path)
+	if err != nil {
+		return nil, err
+	}
+	f.opened++
+
+	ff := &followedFile{file: f.formatFile(file, fi.path)}
+	f.logs.cfg.Metrics.scanned()
+	checkpoints := f.logs.cfg.Checkpoints
+	if checkpoints != nil {
+		ff.file.id = fileIDOf(file, fi.FileInfo)
+		checkpoints.opened(ff.file.id, fi.Size())
+	}
+	switch {
+	case initial && checkpoints != nil:
+		ff.offset, err = checkpoints.resumeOffset(ff.file, fi.FileInfo)
+	case initial:
+		ff.offset, err = ff.file.end()
+	}
+	if err != nil {
+		f.closeFile(ff)
+		return nil, err
+	}
+	return ff, nil
+}
+
+// reopen opens a followed file again once closed to stay under the maximum of open files, its new lines being read
+// from where they stopped (see LogsConfig.MaxOpenFiles).
+func (f *follower) reopen(ff *followedFile, fi logFile) error {
+	file, err := openLogFile(fi.path)
+	if err != nil {
+		return err
+	}
+	f.opened++
+	id := ff.file.id
+	ff.file, ff.closed = f.formatFile(file, fi.path), false
+	ff.file.id = id
+	return nil
+}
+
+// formatFile returns a given opened log file of a given path, of its format and read at the limited rate of the logs,
+// if any, its log times being shifted by the offset of the clock of its host, if any (see Host.Offset),
+// and read in the time zone of the logs when they don't give theirs (see LogsConfig.TimeZone).
+func (f *follower) formatFile(file *os.File, path string) File {
+	ff := NewFormatFile(file, f.logs.cfg.format(path))
+	ff.limiter = f.logs.limiter
+	ff.metrics = f.logs.cfg.Metrics
+	ff.skew, ff.loc = f.logs.skew(path), f.logs.cfg.TimeZone
+	return ff
+}
+
+// close closes all the followed files.
+func (f *follower) close() {
+	for _, ff := range f.files {
+		if !ff.closed {
+			f.closeFile(ff)
+		}
+	}
+	f.files = nil
+}
+
+// read calls the given function for each new complete line of the file, starting over when the file was truncated.
+// The file is read until its end rather than up to its size, which the attribute cache of a network file system
+// (e.g. NFS) can report late, so the lines are never held back until the next poll.
+func (ff *followedFile) read(logs *Logs, fn func(LogEntry) error) error {
+	stat, err := ff.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	if size < ff.offset {
+		ff.offset = 0
+		logs.cfg.Checkpoints.forget(ff.file.id)
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(ff.file, ff.offset, math.MaxInt64-ff.offset))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if !strings.HasSuffix(line, "\n") {
+			// the last line is most likely still being written, so it's read once complete
+			return nil
+		}
+		offset := ff.offset
+		ff.offset += int64(len(line))
+
+		entry, parseErr := ff.file.parseLogEntry(strings.TrimSpace(line))
+		if parseErr != nil {
+			entry.Time = ff.last
+			logs.cfg.Errors.Add(parseErr)
+		}
+		ff.last = entry.Time
+		entry.Line = strings.TrimRight(line, "\r\n")
+		entry.File = ff.file.Name()
+		entry.Offset = offset
+		if !logs.accept(&entry) {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// containsFollowed checks whether a given followed file is one of the given files.
+func containsFollowed(files []*followedFile, file *followedFile) bool {
+	for _, ff := range files {
+		if ff == file {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const followDataDir = "test/follow"
+
+type followSuite struct {
+	suite.Suite
+	logs    *Logs
+	entries chan LogEntry
+	cancel  context.CancelFunc
+	done    chan error
+}
+
+func (s *followSuite) SetupTest() {
+	s.Require().NoError(os.MkdirAll(followDataDir, 0777))
+	s.Require().NoError(os.WriteFile(filepath.Join(followDataDir, "access.log"), []byte(`10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /old HTTP/1.1" 200 20
+`), 0666))
+
+	logs, err := New(WithDirectory(followDataDir), WithFilter(func(entry LogEntry) bool { return entry.Path != "/filtered" }),
+		WithPollInterval(10*time.Millisecond))
+	s.Require().NoError(err)
+	s.logs = logs
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.entries = make(chan LogEntry, 100)
+	s.done = make(chan error, 1)
+	go func() {
+		s.done <- logs.Follow(ctx, func(entry LogEntry) error {
+			s.entries <- entry
+			return nil
+		})
+	}()
+	// let the initial files be found
+	time.Sleep(50 * time.Millisecond)
+}
+
+func (s *followSuite) TearDownTest() {
+	s.cancel()
+	s.ErrorIs(<-s.done, context.Canceled)
+	s.Require().NoError(os.RemoveAll(filepath.Dir(followDataDir)))
+}
+
+// appendLines appends the given lines to a log file, creating it when needed.
+func (s *followSuite) appendLines(name, lines string) {
+	f, err := os.OpenFile(filepath.Join(followDataDir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	_, err = f.WriteString(lines)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+}
+
+// paths returns the paths of the next n followed entries, failing after a while.
+func (s *followSuite) paths(n int) []string {
+	var paths []string
+	for len(paths) < n {
+		select {
+		case entry := <-s.entries:
+			paths = append(paths, entry.Path)
+		case <-time.After(time.Second):
+			s.FailNow("timed out waiting for entries", "got %v", paths)
+		}
+	}
+	return paths
+}
+
+func (s *followSuite) Test_Follow_NewLines() {
+	s.appendLines("access.log", `10.0.0.1 - - [03/Mar/2022:02:45:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:45:11 +0000] "GET /filtered HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:45:12 +0000] "GET /b HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:45:13 +0000] "GET /partial`)
+	s.Equal([]string{"/a", "/b"}, s.paths(2))
+
+	s.appendLines("access.log", ` HTTP/1.1" 200 20
+`)
+	s.Equal([]string{"/partial"}, s.paths(1))
+}
+
+func (s *followSuite) Test_Follow_Rotation() {
+	s.appendLines("access.log", `10.0.0.1 - - [03/Mar/2022:02:45:10 +0000] "GET /a HTTP/1.1" 200 20
+`)
+	s.Equal([]string{"/a"}, s.paths(1))
+
+	s.Require().NoError(os.Rename(filepath.Join(followDataDir, "access.log"), filepath.Join(followDataDir, "access.log.1")))
+	s.appendLines("access.log.1", `10.0.0.1 - - [03/Mar/2022:02:45:20 +0000] "GET /b HTTP/1.1" 200 20
+`)
+	// the rotated file was last written before the new one
+	rotated := time.Now().Add(-time.Minute)
+	s.Require().NoError(os.Chtimes(filepath.Join(followDataDir, "access.log.1"), rotated, rotated))
+	s.appendLines("access.log", `10.0.0.1 - - [03/Mar/2022:02:45:30 +0000] "GET /c HTTP/1.1" 200 20
+`)
+	s.Equal([]string{"/b", "/c"}, s.paths(2))
+}
+
+func (s *followSuite) Test_Follow_Truncation() {
+	s.Require().NoError(os.Truncate(filepath.Join(followDataDir, "access.log"), 0))
+	time.Sleep(50 * time.Millisecond)
+	s.appendLines("access.log", `10.0.0.1 - - [03/Mar/2022:02:45:10 +0000] "GET /a HTTP/1.1" 200 20
+`)
+
+	s.Equal([]string{"/a"}, s.paths(1))
+}
+
+func (s *followSuite) Test_Follow_Stop() {
+	logs, err := New(WithDirectory(followDataDir), WithPollInterval(10*time.Millisecond))
+	s.Require().NoError(err)
+
+	var paths []string
+	done := make(chan error, 1)
+	go func() {
+		done <- logs.Follow(context.Background(), func(entry LogEntry) error {
+			paths = append(paths, entry.Path)
+			if len(paths) == 2 {
+				return ErrStop
+			}
+			return nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// a file created after following started is read from its beginning
+	s.appendLines("new.log", `10.0.0.1 - - [03/Mar/2022:02:45:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:45:20 +0000] "GET /b HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:45:30 +0000] "GET /c HTTP/1.1" 200 20
+`)
+
+	select {
+	case err := <-done:
+		s.NoError(err)
+	case <-time.After(time.Second):
+		s.FailNow("timed out waiting for Follow to stop")
+	}
+	s.Equal([]string{"/a", "/b"}, paths)
+}
+
+func (s *followSuite) Test_PollInterval() {
+	s.Equal(followPollInterval, LogsConfig{}.pollInterval())
+	s.Equal(time.Minute, LogsConfig{PollInterval: time.Minute}.pollInterval())
+}
+
+func TestFollow(t *testing.T) {
+	suite.Run(t, new(followSuite))
+}
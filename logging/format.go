@@ -0,0 +1,274 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	methodGroupName       = "method"
+	pathGroupName         = "path"
+	protocolGroupName     = "protocol"
+	streamGroupName       = "stream"
+	tagGroupName          = "tag"
+	messageGroupName      = "message"
+	refererGroupName      = "referer"
+	agentGroupName        = "agent"
+	durationGroupName     = "duration"
+	vhostGroupName        = "vhost"
+	levelGroupName        = "level"
+	cacheGroupName        = "cache"
+	forwardedForGroupName = "forwarded_for"
+	requestIDGroupName    = "request_id"
+	tlsProtocolGroupName  = "tls_protocol"
+	tlsCipherGroupName    = "tls_cipher"
+	pidGroupName          = "pid"
+	// errorTimeLayout is the layout of the times of the FormatError format, the fractional seconds being optional
+	errorTimeLayout = "Mon Jan _2 15:04:05 2006"
+)
+
+// Format represents the layout of the lines stored inside the log files.
+type Format string
+
+const (
+	// FormatCommon is the Apache Common Log format, e.g.:
+	// 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123
+	// Both FormatCommon and FormatCombined lines can end with the time taken to serve the request
+	// in microseconds (%D), e.g. LogFormat "%h %l %u %t \"%r\" %>s %b %D".
+	FormatCommon Format = "common"
+	// FormatCombined is the Apache Combined Log format, which is the Common Log format
+	// followed by the referer and the user agent of the request, e.g.:
+	// 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123 "https://example.com/" "curl/7.79.1"
+	FormatCombined Format = "combined"
+	// FormatVHostCombined is the Apache vhost_combined log format, which is the Combined Log format
+	// prefixed by the virtual host serving the request and its port, e.g.:
+	// example.com:443 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123 "https://example.com/" "curl/7.79.1"
+	FormatVHostCombined Format = "vhost_combined"
+	// FormatCombinedCache is the Combined Log format followed by the cache status of the response, quoted,
+	// as set by a cache or a CDN in front of the server (e.g. the X-Cache header of Varnish, Squid or CloudFront),
+	// e.g. LogFormat "%h %l %u %t \"%r\" %>s %b \"%{Referer}i\" \"%{User-agent}i\" \"%{X-Cache}o\"":
+	// 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 "https://example.com/" "curl/7.79.1" "HIT"
+	FormatCombinedCache Format = "combined_cache"
+	// FormatCombinedXFF is the Combined Log format followed by the X-Forwarded-For header of the request, quoted,
+	// as set by the proxies and load balancers in front of the server (see LogsConfig.XFF),
+	// e.g. LogFormat "%h %l %u %t \"%r\" %>s %b \"%{Referer}i\" \"%{User-agent}i\" \"%{X-Forwarded-For}i\"":
+	// 10.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 "https://example.com/" "curl/7.79.1" "203.0.113.7, 10.0.0.2"
+	FormatCombinedXFF Format = "combined_xff"
+	// FormatCombinedRequestID is the Combined Log format followed by the id of the request, quoted, as set by the server
+	// (e.g. %{UNIQUE_ID}e of mod_unique_id) or by a proxy or a tracer in front of it (e.g. the X-Request-ID or the W3C
+	// traceparent header), so the access logs can be joined with the traces of the request (see LinkTrace), e.g.
+	// LogFormat "%h %l %u %t \"%r\" %>s %b \"%{Referer}i\" \"%{User-agent}i\" \"%{X-Request-ID}i\"":
+	// 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 "https://example.com/" "curl/7.79.1" "f3b1c2d4-9a8e-4c7b-b6a5-0d1e2f3a4b5c"
+	FormatCombinedRequestID Format = "combined_request_id"
+	// FormatSSLRequest is the format of the ssl_request_log of the mod_ssl of Apache, where every request is logged
+	// along with the TLS protocol and cipher it was made over, after the time and the client IP, but without any status, e.g.
+	// LogFormat "%t %h %{SSL_PROTOCOL}x %{SSL_CIPHER}x \"%r\" %b":
+	// [04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET /api/endpoint HTTP/1.1" 123
+	FormatSSLRequest Format = "ssl_request"
+	// FormatCRI is the container runtime (CRI) log format written by the kubelet under /var/log/pods,
+	// where every line is prefixed by an RFC3339 timestamp, the output stream and a partial/full tag, e.g.:
+	// 2022-03-04T05:30:00.000000000Z stdout F 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123
+	FormatCRI Format = "cri"
+	// FormatError is the Apache error log format, where every line starts with the time (in the local time zone
+	// of the server, read as UTC unless given, see LogsConfig.TimeZone) and the module and severity level,
+	// optionally followed by the process and the client the message is about, e.g.:
+	// [Fri Mar 04 05:30:00.123456 2022] [core:error] [pid 1234:tid 5678] [client 127.0.0.1:51234] AH00126: Invalid URI in request GET /x HTTP/1.1
+	// The lines of Apache 2.2 (e.g. [Fri Mar 04 05:30:00 2022] [error] [client 127.0.0.1] File does not exist: /var/www/x) are read too.
+	FormatError Format = "error"
+)
+
+// FormatPattern gives the format of the log files which names match a pattern (see path.Match),
+// e.g. error* for FormatError, see LogsConfig.Formats.
+type FormatPattern struct {
+	Pattern string
+	Format  Format
+}
+
+// ParseFormatPatterns parses comma separated patterns of file names along with their formats,
+// e.g. "access*: combined, error*: error".
+func ParseFormatPatterns(s string) ([]FormatPattern, error) {
+	var patterns []FormatPattern
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.LastIndex(part, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid format pattern '%s', expected pattern: format", part)
+		}
+		pattern := FormatPattern{Pattern: strings.TrimSpace(part[:i]), Format: Format(strings.TrimSpace(part[i+1:]))}
+		if err := pattern.validate(); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// validate makes sure the pattern is a valid pattern of a file name and the format a supported one.
+func (p FormatPattern) validate() error {
+	if p.Pattern == "" {
+		return errors.New("empty file name pattern")
+	}
+	if _, err := path.Match(p.Pattern, ""); err != nil {
+		return fmt.Errorf("invalid file name pattern '%s': %w", p.Pattern, err)
+	}
+	if p.Format == "" {
+		return fmt.Errorf("no format for the file name pattern '%s'", p.Pattern)
+	}
+	return p.Format.validate()
+}
+
+// validate makes sure the format is one of the supported formats.
+// An empty format is considered valid and defaults to FormatCommon.
+func (format Format) validate() error {
+	if format == "" || format.builtIn() || format.parser() != nil {
+		return nil
+	}
+	return fmt.Errorf("%w '%s'", ErrUnsupportedFormat, format)
+}
+
+// Parser parses a log line of a custom format (see RegisterFormat) into a log entry, its Time set at least,
+// or returns false when the line doesn't match the format. The Line of the log entry is set by the caller.
+type Parser func(line string) (LogEntry, bool)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = make(map[Format]Parser)
+)
+
+// RegisterFormat makes a custom log format available by a given name, e.g. to be picked from the command line,
+// the log lines of the format being parsed by the given Parser, e.g. from a plugin (see LoadPlugins).
+// The lines of the format must be time-ordered all the same, so the log files are searched by their times.
+// It's meant to be called from the init function of the package implementing the format,
+// and panics when called twice with the same name, with the name of a built-in format or with a nil parser.
+func RegisterFormat(name Format, parse Parser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	if parse == nil {
+		panic("logging: RegisterFormat parser is nil")
+	}
+	if _, dup := parsers[name]; dup || name == "" || name.builtIn() {
+		panic("logging: RegisterFormat called twice for format " + string(name))
+	}
+	parsers[name] = parse
+}
+
+// Formats returns the sorted names of the custom formats registered, see RegisterFormat.
+func Formats() []Format {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	names := make([]Format, 0, len(parsers))
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// parser returns the Parser of a custom format, or nil for the built-in ones.
+func (format Format) parser() Parser {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	return parsers[format]
+}
+
+// builtIn tells whether the format is one of the built-in formats.
+func (format Format) builtIn() bool {
+	switch format {
+	case FormatCommon, FormatCombined, FormatVHostCombined, FormatCombinedCache, FormatCombinedXFF, FormatCombinedRequestID, FormatSSLRequest, FormatCRI, FormatError:
+		return true
+	}
+	return false
+}
+
+// regExps holds the regular expressions of the formats once compiled (see Format.regEx),
+// so opening hundreds of rotated files doesn't compile the same regular expression every time.
+var regExps sync.Map
+
+// regEx returns the regular expression matching a single log line of the given format,
+// compiled once and shared by all the files, as a regexp.Regexp is safe for concurrent use.
+func (format Format) regEx() *regexp.Regexp {
+	if regEx, ok := regExps.Load(format); ok {
+		return regEx.(*regexp.Regexp)
+	}
+	regEx, _ := regExps.LoadOrStore(format, format.compileRegEx())
+	return regEx.(*regexp.Regexp)
+}
+
+// quotedField is the pattern of a quoted header field of a log line, e.g. its user agent, which quotes and backslashes
+// Apache escapes with a backslash (see unescapeField).
+const quotedField = `"(?P<%s>(?:[^"\\]|\\.)*)"`
+
+// compileRegEx builds the regular expression matching a single log line of the given format.
+func (format Format) compileRegEx() *regexp.Regexp {
+	if format == FormatCRI {
+		datetime := fmt.Sprintf(`(?P<%s>\S+)`, dateTimeGroupName)
+		stream := fmt.Sprintf(`(?P<%s>stdout|stderr)`, streamGroupName)
+		tag := fmt.Sprintf(`(?P<%s>[FP])`, tagGroupName)
+		message := fmt.Sprintf(`(?P<%s>.*)`, messageGroupName)
+		return regexp.MustCompile(fmt.Sprintf(`^%s %s %s %s$`, datetime, stream, tag, message))
+	}
+
+	if format == FormatError {
+		datetime := fmt.Sprintf(`\[(?P<%s>[^\]]+)\]`, dateTimeGroupName)
+		level := fmt.Sprintf(`\[(?:[^\]]*:)?(?P<%s>[^\]:]+)\]`, levelGroupName)
+		pid := fmt.Sprintf(`(?:\s+\[pid (?P<%s>[^\]:]*)[^\]]*\])?`, pidGroupName)
+		client := fmt.Sprintf(`(?:\s+\[client (?P<%s>[^\]]+?)(?::\d+)?\])?`, ipGroupName)
+		message := fmt.Sprintf(`(?:\s+(?P<%s>.*))?`, messageGroupName)
+		return regexp.MustCompile(fmt.Sprintf(`^%s\s+%s%s%s%s$`, datetime, level, pid, client, message))
+	}
+
+	ip := fmt.Sprintf(`(?P<%s>\S+)`, ipGroupName)
+	id := fmt.Sprintf(`(?P<%s>\S+)`, idGroupName)
+	user := fmt.Sprintf(`(?P<%s>\S+)`, userGroupName)
+	datetime := fmt.Sprintf(`\[(?P<%s>[\w:/]+\s[+\-]\d{4})\]`, dateTimeGroupName)
+	request := fmt.Sprintf(`"(?P<%s>(?P<%s>\S+)\s?(?P<%s>\S+)?\s?(?P<%s>\S+)?)"`,
+		requestGroupName, methodGroupName, pathGroupName, protocolGroupName)
+	status := fmt.Sprintf(`(?P<%s>\d{3}|-)`, statusGroupName)
+	size := fmt.Sprintf(`(?P<%s>\d+|-)`, sizeGroupName)
+	duration := fmt.Sprintf(`(?: (?P<%s>\d+))?`, durationGroupName)
+	if format == FormatSSLRequest {
+		tlsProtocol := fmt.Sprintf(`(?P<%s>\S+)`, tlsProtocolGroupName)
+		tlsCipher := fmt.Sprintf(`(?P<%s>\S+)`, tlsCipherGroupName)
+		return regexp.MustCompile(fmt.Sprintf(`^%s %s %s %s %s %s$`, datetime, ip, tlsProtocol, tlsCipher, request, size))
+	}
+	common := fmt.Sprintf(`%s %s %s %s %s %s %s`, ip, id, user, datetime, request, status, size)
+	referer := fmt.Sprintf(quotedField, refererGroupName)
+	agent := fmt.Sprintf(quotedField, agentGroupName)
+	switch format {
+	case FormatCombined:
+		return regexp.MustCompile(fmt.Sprintf(`^%s %s %s%s$`, common, referer, agent, duration))
+	case FormatVHostCombined:
+		vhost := fmt.Sprintf(`(?P<%s>[^\s:]+)(?::\d+)?`, vhostGroupName)
+		return regexp.MustCompile(fmt.Sprintf(`^%s %s %s %s%s$`, vhost, common, referer, agent, duration))
+	case FormatCombinedCache:
+		cache := fmt.Sprintf(quotedField, cacheGroupName)
+		return regexp.MustCompile(fmt.Sprintf(`^%s %s %s %s%s$`, common, referer, agent, cache, duration))
+	case FormatCombinedXFF:
+		forwardedFor := fmt.Sprintf(quotedField, forwardedForGroupName)
+		return regexp.MustCompile(fmt.Sprintf(`^%s %s %s %s%s$`, common, referer, agent, forwardedFor, duration))
+	case FormatCombinedRequestID:
+		requestID := fmt.Sprintf(quotedField, requestIDGroupName)
+		return regexp.MustCompile(fmt.Sprintf(`^%s %s %s %s%s$`, common, referer, agent, requestID, duration))
+	}
+	return regexp.MustCompile(fmt.Sprintf(`^%s%s$`, common, duration))
+}
+
+// timeLayout returns the layout used to parse the datetime group of the given format.
+func (format Format) timeLayout() string {
+	switch format {
+	case FormatCRI:
+		return time.RFC3339Nano
+	case FormatError:
+		return errorTimeLayout
+	}
+	return dateTimeFormat
+}
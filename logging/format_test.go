@@ -0,0 +1,251 @@
+package logging
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type formatSuite struct {
+	suite.Suite
+}
+
+func (s *formatSuite) Test_validate() {
+	tests := []struct {
+		name        string
+		format      Format
+		expectedErr string
+	}{
+		{
+			name:   "Empty Format",
+			format: "",
+		},
+		{
+			name:   "Common Format",
+			format: FormatCommon,
+		},
+		{
+			name:   "Combined Format",
+			format: FormatCombined,
+		},
+		{
+			name:   "VHost Combined Format",
+			format: FormatVHostCombined,
+		},
+		{
+			name:   "CRI Format",
+			format: FormatCRI,
+		},
+		{
+			name:   "Error Format",
+			format: FormatError,
+		},
+		{
+			name:        "Unsupported Format",
+			format:      "xml",
+			expectedErr: "unsupported log format 'xml'",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			err := test.format.validate()
+
+			if test.expectedErr == "" {
+				s.NoError(err)
+			} else {
+				s.EqualError(err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func (s *formatSuite) Test_parseLogTime_CRI() {
+	log := `2022-03-04T05:30:00.123456789Z stdout F 127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123`
+	expectedTime := time.Date(2022, time.March, 4, 5, 30, 0, 123456789, time.UTC)
+	file := NewFormatFile(nil, FormatCRI)
+	s.NotNil(file)
+
+	t, err := file.parseLogTime(log)
+
+	s.NoError(err)
+	s.True(t.Equal(expectedTime))
+}
+
+func (s *formatSuite) Test_parseLogTime_CRIError() {
+	file := NewFormatFile(nil, FormatCRI)
+	s.NotNil(file)
+
+	t, err := file.parseLogTime(`127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123`)
+
+	s.Error(err)
+	s.True(t.IsZero())
+}
+
+func (s *formatSuite) Test_parseLogTime_TimeZone() {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	s.Require().NoError(err)
+	file := NewFormatFile(nil, FormatError)
+	file.loc = berlin
+
+	// the error log times are in the time zone of the logs
+	t, err := file.parseLogTime(`[Fri Mar 04 05:30:00.123456 2022] [core:error] [pid 1234] AH00126: Invalid URI in request`)
+	s.NoError(err)
+	s.True(t.Equal(time.Date(2022, time.March, 4, 4, 30, 0, 123456000, time.UTC)))
+
+	// while the times giving their offset are read as logged
+	file = NewFormatFile(nil, FormatCommon)
+	file.loc = berlin
+	t, err = file.parseLogTime(`127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123`)
+	s.NoError(err)
+	s.True(t.Equal(time.Date(2022, time.March, 4, 5, 30, 0, 0, time.UTC)))
+}
+
+func (s *formatSuite) Test_ParseFormatPatterns() {
+	tests := []struct {
+		name        string
+		patterns    string
+		expected    []FormatPattern
+		expectedErr string
+	}{
+		{
+			name:     "Patterns",
+			patterns: "access*: combined, error*: error",
+			expected: []FormatPattern{{Pattern: "access*", Format: FormatCombined}, {Pattern: "error*", Format: FormatError}},
+		},
+		{
+			name:     "Empty",
+			patterns: " , ",
+		},
+		{
+			name:        "Missing Format",
+			patterns:    "access*",
+			expectedErr: "invalid format pattern 'access*', expected pattern: format",
+		},
+		{
+			name:        "Invalid Pattern",
+			patterns:    "access[: combined",
+			expectedErr: "invalid file name pattern 'access[': syntax error in pattern",
+		},
+		{
+			name:        "Unsupported Format",
+			patterns:    "access*: xml",
+			expectedErr: "unsupported log format 'xml'",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			patterns, err := ParseFormatPatterns(test.patterns)
+
+			if test.expectedErr == "" {
+				s.NoError(err)
+				s.Equal(test.expected, patterns)
+			} else {
+				s.EqualError(err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func (s *formatSuite) Test_format() {
+	cfg := LogsConfig{
+		Format:  FormatCombined,
+		Formats: []FormatPattern{{Pattern: "error*", Format: FormatError}, {Pattern: "*.cri", Format: FormatCRI}},
+	}
+
+	s.Equal(FormatError, cfg.format("/var/log/apache2/error.log.1"))
+	s.Equal(FormatCRI, cfg.format("pods/0.cri"))
+	s.Equal(FormatCombined, cfg.format("/var/log/apache2/access.log"))
+}
+
+func (s *formatSuite) Test_regEx_Compiled_Once() {
+	for _, format := range []Format{FormatCommon, FormatCombined, FormatVHostCombined, FormatCRI, FormatError} {
+		// the files of the same format share the regular expression, compiled once
+		s.Same(NewFormatFile(nil, format).regEx, NewFormatFile(nil, format).regEx, format)
+		s.Equal(format.compileRegEx().String(), format.regEx().String(), format)
+		allocs := testing.AllocsPerRun(10, func() {
+			_ = NewFormatFile(nil, format)
+		})
+		s.Zero(allocs, format)
+	}
+	s.NotSame(FormatCommon.regEx(), FormatCombined.regEx())
+}
+
+func (s *formatSuite) Test_ParseLine_EscapedQuotes() {
+	line := `10.0.0.1 - - [03/Mar/2022:02:45:00 +0000] "GET /a HTTP/1.1" 200 10 "http://example.com/?q=\"x\"" "Mozilla \"quoted\" agent \\ \x01"`
+	for _, format := range []Format{FormatCombined, FormatVHostCombined} {
+		input := line
+		if format == FormatVHostCombined {
+			input = "example.com:443 " + line
+		}
+		entry, err := format.ParseLine(input)
+
+		s.Require().NoError(err, format)
+		s.False(entry.Invalid, format)
+		s.Equal("10.0.0.1", entry.IP, format)
+		s.Equal(200, entry.Status, format)
+		s.Equal(`http://example.com/?q="x"`, entry.Referer, format)
+		s.Equal("Mozilla \"quoted\" agent \\ \x01", entry.UserAgent, format)
+	}
+
+	// the regular expression of the format, which takes the lines the hand-written parser leaves to it, unescapes them too
+	groups, ok := matchGroups(FormatCombined.regEx(), line)
+	s.Require().True(ok)
+	s.Equal("Mozilla \"quoted\" agent \\ \x01", groupFields(groups).agent)
+}
+
+func (s *formatSuite) Test_RegisterFormat() {
+	// test-pipe lines are the time, the IP and the path separated by pipes
+	RegisterFormat("test-pipe", func(line string) (LogEntry, bool) {
+		fields := strings.Split(line, "|")
+		if len(fields) != 3 {
+			return LogEntry{}, false
+		}
+		t, err := time.Parse(time.RFC3339, fields[0])
+		return LogEntry{Time: t, IP: fields[1], Path: fields[2]}, err == nil
+	})
+
+	s.Contains(Formats(), Format("test-pipe"))
+	s.NoError(Format("test-pipe").validate())
+	entry, err := Format("test-pipe").ParseLine("2022-03-03T10:00:00Z|10.0.0.1|/a")
+	s.Require().NoError(err)
+	s.Equal(LogEntry{Line: "2022-03-03T10:00:00Z|10.0.0.1|/a", Time: time.Date(2022, 3, 3, 10, 0, 0, 0, time.UTC), IP: "10.0.0.1", Path: "/a"}, entry)
+	entry, err = Format("test-pipe").ParseLine("10.0.0.1|/a")
+	s.ErrorIs(err, ErrInvalidLogLine)
+	s.True(entry.Invalid)
+	t, err := Format("test-pipe").ParseTime("2022-03-03T10:00:00Z|10.0.0.1|/a")
+	s.NoError(err)
+	s.Equal(time.Date(2022, 3, 3, 10, 0, 0, 0, time.UTC), t)
+
+	// the log lines of the format are searched by their times
+	var paths []string
+	end := time.Date(2022, 3, 3, 10, 3, 0, 0, time.UTC)
+	logs, err := New(WithFS(fstest.MapFS{"access.log": {
+		Data:    []byte("2022-03-03T10:00:00Z|10.0.0.1|/a\n2022-03-03T10:01:00Z|10.0.0.1|/b\n2022-03-03T10:02:00Z|10.0.0.2|/c\n"),
+		ModTime: end,
+	}}), WithDirectory("."), WithFormat("test-pipe"), WithEnd(end), WithWindow(2*time.Minute))
+	s.Require().NoError(err)
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+	s.Equal([]string{"/b", "/c"}, paths)
+
+	s.Panics(func() {
+		RegisterFormat("test-pipe", func(string) (LogEntry, bool) { return LogEntry{}, false })
+	})
+	s.Panics(func() {
+		RegisterFormat(FormatCombined, func(string) (LogEntry, bool) { return LogEntry{}, false })
+	})
+	s.Panics(func() {
+		RegisterFormat("test-nil", nil)
+	})
+	s.ErrorIs(Format("test-unknown").validate(), ErrUnsupportedFormat)
+}
+
+func TestFormat(t *testing.T) {
+	suite.Run(t, new(formatSuite))
+}
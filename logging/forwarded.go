@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// XFF tells which address of the X-Forwarded-For header of the requests (see LogEntry.ForwardedFor)
+// is their client IP, see LogsConfig.XFF.
+type XFF string
+
+const (
+	// XFFFirst takes the first address of the header, the client as seen by the first proxy.
+	// The clients can forge it, unless the server is only reachable through trusted proxies.
+	XFFFirst XFF = "first"
+	// XFFLastTrusted takes the last address of the header which isn't a trusted proxy (see LogsConfig.TrustedProxies),
+	// walking the header back from the proxy closest to the server, which the clients can't forge.
+	XFFLastTrusted XFF = "last-trusted"
+)
+
+// ParseXFF parses the name of an XFF mode, "first" or "last-trusted".
+func ParseXFF(name string) (XFF, error) {
+	switch xff := XFF(name); xff {
+	case XFFFirst, XFFLastTrusted:
+		return xff, nil
+	default:
+		return "", fmt.Errorf("unsupported xff mode '%s': must be first or last-trusted", name)
+	}
+}
+
+// ParseTrustedProxies parses comma-separated CIDRs or IPs of trusted proxies, e.g. 10.0.0.0/8,172.16.0.0/12,192.0.2.1.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, proxy := range strings.Split(s, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy '%s': must be a CIDR or an IP", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': must be a CIDR or an IP", proxy)
+		}
+		proxies = append(proxies, ipNet)
+	}
+	return proxies, nil
+}
+
+// ClientIP returns the client IP of a given log entry read from its X-Forwarded-For header with a given XFF mode.
+// The header is only believed when the entry comes from one of the trusted proxies, or from anyone when there are
+// none with XFFFirst. The IP of the entry, i.e. the peer of the server, is returned otherwise, and when the header
+// is empty or the address it gives isn't an IP (e.g. unknown).
+func ClientIP(entry LogEntry, xff XFF, trustedProxies []*net.IPNet) string {
+	if xff == "" || entry.ForwardedFor == "" || entry.ForwardedFor == "-" {
+		return entry.IP
+	}
+	if len(trustedProxies) > 0 && !isTrustedProxy(entry.IP, trustedProxies) {
+		return entry.IP
+	}
+
+	hops := strings.Split(entry.ForwardedFor, ",")
+	client := strings.TrimSpace(hops[0])
+	if xff == XFFLastTrusted {
+		for i := len(hops) - 1; i >= 0; i-- {
+			client = strings.TrimSpace(hops[i])
+			if !isTrustedProxy(client, trustedProxies) {
+				break
+			}
+		}
+	}
+	if ip := hopIP(client); ip != "" {
+		return ip
+	}
+	return entry.IP
+}
+
+// isTrustedProxy checks whether a given address is one of the trusted proxies.
+func isTrustedProxy(address string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(hopIP(address))
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hopIP returns the IP of an address of the X-Forwarded-For header, which some proxies give along with a port
+// (e.g. 192.0.2.1:51234 or [2001:db8::1]:51234), or an empty string when it isn't an IP.
+func hopIP(address string) string {
+	if net.ParseIP(address) != nil {
+		return address
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil && net.ParseIP(host) != nil {
+		return host
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const forwardedDataDir = "test/forwarded"
+
+type forwardedSuite struct {
+	suite.Suite
+	t time.Time
+}
+
+func (s *forwardedSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.t = t
+	writeLogFile(s.T(), filepath.Join(forwardedDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20 "-" "curl/7.79.1" "203.0.113.7"
+10.0.0.1 - - [03/Mar/2022:02:44:20 +0000] "GET /a HTTP/1.1" 200 20 "-" "curl/7.79.1" "198.51.100.1, 203.0.113.7, 10.0.0.2"
+10.0.0.2 - - [03/Mar/2022:02:44:30 +0000] "GET /b HTTP/1.1" 200 10 "-" "curl/7.79.1" "-"
+192.0.2.9 - - [03/Mar/2022:02:44:40 +0000] "GET /b HTTP/1.1" 200 10 "-" "curl/7.79.1" "203.0.113.8"
+`, t)
+}
+
+func (s *forwardedSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(forwardedDataDir)))
+}
+
+// newLogs creates the logs of the last minute of the test data with the given options.
+func (s *forwardedSuite) newLogs(opts ...Option) *Logs {
+	logs, err := New(append([]Option{WithDirectory(forwardedDataDir), WithWindow(time.Minute), WithFormat(FormatCombinedXFF)}, opts...)...)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.t.Add(-time.Minute)
+	}
+	return logs
+}
+
+func (s *forwardedSuite) Test_XFF() {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8")
+	s.Require().NoError(err)
+
+	for _, test := range []struct {
+		name     string
+		opts     []Option
+		expected []Count
+	}{
+		{
+			name:     "Peers",
+			expected: []Count{{Value: "10.0.0.1", Count: 2}, {Value: "10.0.0.2", Count: 1}, {Value: "192.0.2.9", Count: 1}},
+		},
+		{
+			name:     "First",
+			opts:     []Option{WithXFF(XFFFirst)},
+			expected: []Count{{Value: "10.0.0.2", Count: 1}, {Value: "198.51.100.1", Count: 1}, {Value: "203.0.113.7", Count: 1}, {Value: "203.0.113.8", Count: 1}},
+		},
+		{
+			name:     "First Of Trusted Proxies",
+			opts:     []Option{WithXFF(XFFFirst, trusted...)},
+			expected: []Count{{Value: "10.0.0.2", Count: 1}, {Value: "192.0.2.9", Count: 1}, {Value: "198.51.100.1", Count: 1}, {Value: "203.0.113.7", Count: 1}},
+		},
+		{
+			name:     "Last Trusted",
+			opts:     []Option{WithXFF(XFFLastTrusted, trusted...)},
+			expected: []Count{{Value: "203.0.113.7", Count: 2}, {Value: "10.0.0.2", Count: 1}, {Value: "192.0.2.9", Count: 1}},
+		},
+	} {
+		s.Run(test.name, func() {
+			top, err := s.newLogs(test.opts...).Top(context.Background(), TopIPs)
+			s.Require().NoError(err)
+			s.Equal(test.expected, top.Ranked(TopIPs, 0))
+		})
+	}
+
+	// the IP looked for is the client IP
+	var paths []string
+	s.Require().NoError(s.newLogs(WithXFF(XFFLastTrusted, trusted...), WithIP("203.0.113.7")).ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+	s.Equal([]string{"/a", "/a"}, paths)
+}
+
+func (s *forwardedSuite) Test_ClientIP() {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 2001:db8::1")
+	s.Require().NoError(err)
+
+	for _, test := range []struct {
+		ip, forwardedFor string
+		xff              XFF
+		expected         string
+	}{
+		{ip: "10.0.0.1", forwardedFor: "203.0.113.7", expected: "10.0.0.1"},
+		{ip: "10.0.0.1", forwardedFor: "203.0.113.7, 10.0.0.2", xff: XFFFirst, expected: "203.0.113.7"},
+		{ip: "10.0.0.1", forwardedFor: "1.2.3.4, 203.0.113.7, 10.0.0.2", xff: XFFLastTrusted, expected: "203.0.113.7"},
+		{ip: "10.0.0.1", forwardedFor: "10.0.0.3, 10.0.0.2", xff: XFFLastTrusted, expected: "10.0.0.3"},
+		{ip: "2001:db8::1", forwardedFor: "[2001:db8::7]:51234", xff: XFFLastTrusted, expected: "2001:db8::7"},
+		{ip: "10.0.0.1", forwardedFor: "203.0.113.7:51234", xff: XFFFirst, expected: "203.0.113.7"},
+		{ip: "192.0.2.9", forwardedFor: "203.0.113.7", xff: XFFFirst, expected: "192.0.2.9"},
+		{ip: "10.0.0.1", forwardedFor: "unknown, 10.0.0.2", xff: XFFLastTrusted, expected: "10.0.0.1"},
+		{ip: "10.0.0.1", forwardedFor: "-", xff: XFFFirst, expected: "10.0.0.1"},
+		{ip: "10.0.0.1", xff: XFFFirst, expected: "10.0.0.1"},
+	} {
+		entry := LogEntry{IP: test.ip, ForwardedFor: test.forwardedFor}
+		s.Equal(test.expected, ClientIP(entry, test.xff, trusted), "%s %s %s", test.xff, test.ip, test.forwardedFor)
+	}
+}
+
+func (s *forwardedSuite) Test_ParseTrustedProxies() {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8,192.0.2.1,,2001:db8::/32")
+	s.Require().NoError(err)
+	s.Require().Len(proxies, 3)
+	s.Equal("10.0.0.0/8", proxies[0].String())
+	s.Equal("192.0.2.1/32", proxies[1].String())
+	s.Equal("2001:db8::/32", proxies[2].String())
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	s.EqualError(err, "invalid trusted proxy '10.0.0.0/33': must be a CIDR or an IP")
+	_, err = ParseTrustedProxies("proxy.local")
+	s.EqualError(err, "invalid trusted proxy 'proxy.local': must be a CIDR or an IP")
+
+	_, err = ParseXFF("last")
+	s.EqualError(err, "unsupported xff mode 'last': must be first or last-trusted")
+}
+
+func TestForwarded(t *testing.T) {
+	suite.Run(t, new(forwardedSuite))
+}
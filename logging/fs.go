@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LogFile is an open log file read by File: an *os.File, or a file of the file system of the logs (see LogsConfig.FS)
+// which can be read at offsets and seeked, e.g. the files of embed.FS, fstest.MapFS or an in-memory file system.
+type LogFile interface {
+	fs.File
+	io.ReaderAt
+	io.Seeker
+}
+
+// fileSystem is the file system the log files are listed and opened from: the one of the operating system,
+// or the one the logs are read from (see LogsConfig.FS). Its paths are the ones of the operating system either way.
+type fileSystem interface {
+	Open(name string) (LogFile, error)
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	WalkDir(root string, fn fs.WalkDirFunc) error
+}
+
+// fileSystem returns the file system the log files are read from, see LogsConfig.FS.
+func (cfg LogsConfig) fileSystem() fileSystem {
+	if cfg.FS != nil {
+		return ioFileSystem{fsys: cfg.FS}
+	}
+	return osFileSystem{}
+}
+
+// osFileSystem is the file system of the operating system.
+type osFileSystem struct{}
+
+// Open opens a log file for reading, see openLogFile.
+func (osFileSystem) Open(name string) (LogFile, error) {
+	file, err := openLogFile(name)
+	if err != nil {
+		// a nil *os.File would make a non-nil LogFile
+		return nil, err
+	}
+	return file, nil
+}
+
+func (osFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
+
+// ioFileSystem is a file system of the io/fs package, which paths are slash-separated (see fs.ValidPath).
+type ioFileSystem struct {
+	fsys fs.FS
+}
+
+// Open opens a file of the file system, which must be readable at offsets and seekable to be searched (see LogFile).
+func (f ioFileSystem) Open(name string) (LogFile, error) {
+	file, err := f.fsys.Open(filepath.ToSlash(name))
+	if err != nil {
+		return nil, err
+	}
+	logFile, ok := file.(LogFile)
+	if !ok {
+		_ = file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("%T cannot be read at offsets and seeked", file)}
+	}
+	return namedFile{LogFile: logFile, name: name}, nil
+}
+
+func (f ioFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, filepath.ToSlash(name))
+}
+
+func (f ioFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.fsys, filepath.ToSlash(name))
+}
+
+func (f ioFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(f.fsys, filepath.ToSlash(root), func(path string, d fs.DirEntry, err error) error {
+		return fn(filepath.FromSlash(path), d, err)
+	})
+}
+
+// namedFile is a file of an ioFileSystem along with its path, named the way an *os.File is (see File.Name).
+type namedFile struct {
+	LogFile
+	name string
+}
+
+func (f namedFile) Name() string {
+	return f.name
+}
@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type fsSuite struct {
+	suite.Suite
+	testTime time.Time
+	// fsys has an access.log of log lines a minute apart starting at testTime, the 4th one being invalid
+	// and the 6th one being of a bot, modified right after the last one
+	fsys fstest.MapFS
+}
+
+func (s *fsSuite) SetupSuite() {
+	s.testTime = time.Date(2022, time.March, 3, 10, 0, 0, 0, time.UTC)
+	var b strings.Builder
+	for i := 0; i < 6; i++ {
+		if i == 3 {
+			b.WriteString("garbage\n")
+			continue
+		}
+		status, agent := 200, "Mozilla/5.0"
+		if i%2 == 1 {
+			status = 500
+		}
+		if i == 5 {
+			agent = "Googlebot/2.1"
+		}
+		fmt.Fprintf(&b, "10.0.0.%d - - [%s] \"GET /%d HTTP/1.1\" %d 10 \"-\" \"%s\"\n",
+			i, s.testTime.Add(time.Duration(i)*time.Minute).Format(dateTimeFormat), i, status, agent)
+	}
+	s.fsys = fstest.MapFS{
+		"logs/access.log": {Data: []byte(b.String()), ModTime: s.testTime.Add(6 * time.Minute)},
+	}
+}
+
+// paths returns the paths of the log entries of the file system within a window ending at a given time,
+// or the lines of the invalid ones.
+func (s *fsSuite) paths(end time.Time, window time.Duration, opts ...Option) []string {
+	logs, err := New(append([]Option{WithFS(s.fsys), WithDirectory("logs"), WithFormat(FormatCombined),
+		WithEnd(end), WithWindow(window)}, opts...)...)
+	s.Require().NoError(err)
+	var paths []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		if entry.Invalid {
+			paths = append(paths, entry.Line)
+		} else {
+			paths = append(paths, entry.Path)
+		}
+		return nil
+	}))
+	return paths
+}
+
+func (s *fsSuite) Test_FS() {
+	tests := []struct {
+		name          string
+		end           time.Time
+		window        time.Duration
+		expectedPaths []string
+	}{
+		{
+			name:          "Whole File",
+			end:           s.testTime.Add(6 * time.Minute),
+			window:        time.Hour,
+			expectedPaths: []string{"/0", "/1", "/2", "garbage", "/4", "/5"},
+		},
+		{
+			name:          "Window Within The File",
+			end:           s.testTime.Add(5 * time.Minute),
+			window:        4 * time.Minute,
+			expectedPaths: []string{"/1", "/2", "garbage", "/4"},
+		},
+		{
+			name:          "Window At The End Of The File",
+			end:           s.testTime.Add(6 * time.Minute),
+			window:        90 * time.Second,
+			expectedPaths: []string{"/5"},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.Equal(test.expectedPaths, s.paths(test.end, test.window))
+		})
+	}
+}
+
+func (s *fsSuite) Test_FS_NoFilesInWindow() {
+	logs, err := New(WithFS(s.fsys), WithDirectory("logs"), WithEnd(s.testTime.Add(time.Hour)), WithWindow(time.Minute))
+	s.Require().NoError(err)
+	err = logs.ForEach(context.Background(), func(LogEntry) error { return nil })
+	s.ErrorIs(err, ErrNoFilesInWindow)
+}
+
+func (s *fsSuite) Test_FS_Entries() {
+	end := s.testTime.Add(6 * time.Minute)
+	errors := func(entry LogEntry) bool { return entry.Status >= 500 }
+	s.Equal([]string{"/1", "/5"}, s.paths(end, time.Hour, WithFilter(errors)))
+
+	slash := func(entry LogEntry) (LogEntry, bool) {
+		if !entry.Invalid {
+			entry.Path += "/"
+		}
+		return entry, entry.Path != "/0/"
+	}
+	s.Equal([]string{"/1/", "/2/", "garbage", "/4/", "/5/"}, s.paths(end, time.Hour, WithMiddleware(slash)))
+
+	humans := func(entry LogEntry) bool { return !entry.Invalid && entry.Bot == "" }
+	s.Equal([]string{"/0", "/1", "/2", "/4"}, s.paths(end, time.Hour, WithBots(NewBotClassifier()), WithFilter(humans)))
+}
+
+// unseekableFS is a file system which files can only be read sequentially.
+type unseekableFS struct {
+	fsys fs.FS
+}
+
+func (u unseekableFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(u.fsys, name)
+}
+
+func (u unseekableFS) Open(name string) (fs.File, error) {
+	file, err := u.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ fs.File }{file}, nil
+}
+
+func (s *fsSuite) Test_FS_Unseekable() {
+	logs, err := New(WithFS(unseekableFS{fsys: s.fsys}), WithDirectory("logs"), WithEnd(s.testTime.Add(6*time.Minute)), WithWindow(time.Hour))
+	s.Require().NoError(err)
+	err = logs.ForEach(context.Background(), func(LogEntry) error { return nil })
+	var pathErr *fs.PathError
+	s.Require().ErrorAs(err, &pathErr)
+	s.Contains(pathErr.Error(), "cannot be read at offsets and seeked")
+}
+
+func (s *fsSuite) Test_FS_Unsupported() {
+	_, err := New(WithFS(s.fsys), WithDirectory("logs"), WithIndex(time.Minute))
+	var cfgErr *ConfigError
+	s.Require().ErrorAs(err, &cfgErr)
+	s.Equal("fs", cfgErr.Field)
+
+	_, err = New(WithFS(s.fsys), WithDirectory("logs"), WithCopyDir(s.T().TempDir()))
+	s.Require().ErrorAs(err, &cfgErr)
+	s.Equal("fs", cfgErr.Field)
+
+	logs, err := New(WithFS(s.fsys), WithDirectory("logs"))
+	s.Require().NoError(err)
+	err = logs.Follow(context.Background(), func(LogEntry) error { return nil })
+	s.Require().ErrorAs(err, &cfgErr)
+	s.Equal("fs", cfgErr.Field)
+}
+
+func TestFSSuite(t *testing.T) {
+	suite.Run(t, new(fsSuite))
+}
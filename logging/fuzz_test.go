@@ -0,0 +1,152 @@
+//go:build go1.18
+// +build go1.18
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fuzz targets run as regular tests over their seeds, or fuzz the parsing and the search of the logs with
+// go test -run XXX -fuzz FuzzIndexTime ./logging (FuzzParseLine for the parsing).
+
+// FuzzParseLine parses arbitrary lines in every format: the parsing never panics, and the log entries parsed
+// have a time and the line they were parsed from, the invalid ones an *InvalidLogLineError.
+func FuzzParseLine(f *testing.F) {
+	for _, line := range []string{
+		`127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123`,
+		`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET /a HTTP/1.1" 200 12 "https://example.com/" "curl/7.79.1" 1234`,
+		`example.com:443 127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET /a HTTP/1.1" 200 - "-" "-"`,
+		`[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET /a HTTP/1.1" 123`,
+		`2022-03-04T05:30:00.000000000Z stdout F 127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.0" 200 1`,
+		`[Fri Mar 04 05:30:00.123456 2022] [core:error] [pid 1234:tid 5678] [client 127.0.0.1:51234] AH00126: Invalid URI`,
+		`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET  /a  HTTP/1.1" 200 12`,
+		`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "" 400 -`,
+		`127.0.0.1 - - [99/Xyz/2022:25:61:61 +9999] "GET / HTTP/1.1" 200 1`,
+		`- - - [] "" - -`,
+		"\x00\xff[\"",
+	} {
+		f.Add(line)
+	}
+	formats := []Format{FormatCommon, FormatCombined, FormatVHostCombined, FormatCombinedCache, FormatCombinedXFF, FormatCombinedRequestID, FormatSSLRequest, FormatCRI, FormatError}
+	f.Fuzz(func(t *testing.T, line string) {
+		for _, format := range formats {
+			entry, err := format.ParseLine(line)
+			if entry.Line != line {
+				t.Fatalf("%s: the line of the entry is %q, not %q", format, entry.Line, line)
+			}
+			if err != nil {
+				var invalid *InvalidLogLineError
+				if !entry.Invalid || !errors.As(err, &invalid) {
+					t.Fatalf("%s: %q failed with %v, not an invalid log line", format, line, err)
+				}
+				continue
+			}
+			if entry.Invalid || entry.Time.IsZero() {
+				t.Fatalf("%s: %q parsed without a time: %+v", format, line, entry)
+			}
+			if _, err := format.ParseTime(line); err != nil {
+				t.Fatalf("%s: %q parsed but its time didn't: %v", format, line, err)
+			}
+		}
+	})
+}
+
+// FuzzIndexTime searches files built out of arbitrary bytes, each one giving a log line: later, earlier or at
+// the same time as the line before it, invalid, empty, huge or cut short, the file possibly ending without
+// a line ending. The search never panics and ends on the start of a line, the first one at or after
+// the lookup time when the lines are all valid and sorted (see referenceOffset), or fails with an invalid line.
+func FuzzIndexTime(f *testing.F) {
+	f.Add([]byte{1, 2, 3, 4, 5}, uint16(3), false)
+	f.Add([]byte{1, 1, 1, 1}, uint16(0), true)
+	f.Add([]byte{200, 3, 3, 100, 3}, uint16(2), false)
+	f.Add([]byte{7, 0x40, 7, 7}, uint16(5), true)
+	f.Add([]byte{3, 0x80, 3, 0xc0, 3}, uint16(4), false)
+	f.Add([]byte{}, uint16(0), false)
+	from := time.Date(2022, 3, 7, 2, 0, 0, 0, time.UTC)
+	f.Fuzz(func(t *testing.T, data []byte, lookup uint16, cut bool) {
+		if len(data) > fuzzMaxLines {
+			data = data[:fuzzMaxLines]
+		}
+		logs, sorted := fuzzLogs(data, from, cut)
+		lookupTime := from.Add(time.Duration(lookup) * time.Second)
+		offset, err := IndexTime(context.Background(), bytes.NewReader(logs), FormatCommon.ParseTime, lookupTime)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidLogLine) {
+				t.Fatalf("failed with %v, not an invalid log line", err)
+			}
+			if sorted {
+				t.Fatalf("failed with %v on sorted logs", err)
+			}
+			return
+		}
+		if offset < -1 || offset >= int64(len(logs)) || (offset > 0 && logs[offset-1] != '\n') {
+			t.Fatalf("offset %d isn't the start of a line of %q", offset, logs)
+		}
+		if sorted {
+			if expected := referenceOffset(logs, lookupTime); offset != expected {
+				t.Fatalf("offset %d instead of %d looking up %s in %q", offset, expected, lookupTime, logs)
+			}
+		}
+	})
+}
+
+// fuzzMaxLines is the number of log lines of the files searched by FuzzIndexTime at most, so they stay small.
+const fuzzMaxLines = 256
+
+// fuzzLogs builds log lines out of bytes, the first 2 bits of each byte telling the kind of line (a valid one,
+// an invalid one, an empty one or a huge valid one) and the others how many seconds after the line before it
+// the log happened, minus 8 for the valid ones, so a few of them happen before the one before them.
+// The last line is cut short when told so. It also returns whether the lines are all valid and sorted.
+func fuzzLogs(data []byte, from time.Time, cut bool) ([]byte, bool) {
+	var b bytes.Buffer
+	t := from
+	sorted := !cut
+	for i, d := range data {
+		delta := time.Duration(int(d&0x3f)-8) * time.Second
+		switch d >> 6 {
+		case 1:
+			b.WriteString("garbage " + strings.Repeat("x", int(d&0x3f)) + "\n")
+			sorted = false
+			continue
+		case 2:
+			b.WriteString("\n")
+			continue
+		}
+		if delta < 0 {
+			sorted = false
+		}
+		t = t.Add(delta)
+		path := "/"
+		if d>>6 == 3 {
+			path += strings.Repeat("a", 2*lineStartBlockSize)
+		}
+		fmt.Fprintf(&b, "10.0.0.%d - - [%s] \"GET %s HTTP/1.1\" 200 %d\n", i%256, t.Format(dateTimeFormat), path, i)
+	}
+	logs := b.Bytes()
+	if cut && len(logs) > 1 {
+		logs = logs[:len(logs)-1-len(logs)/3]
+	}
+	return logs, sorted
+}
+
+// referenceOffset returns the offset of the first log line happening at or after the lookup time, or -1,
+// reading the valid log lines one by one.
+func referenceOffset(logs []byte, lookupTime time.Time) int64 {
+	offset := int64(0)
+	for _, line := range strings.SplitAfter(string(logs), "\n") {
+		if strings.TrimSpace(line) != "" {
+			if logTime, err := FormatCommon.ParseTime(strings.TrimSpace(line)); err == nil && !logTime.Before(lookupTime) {
+				return offset
+			}
+		}
+		offset += int64(len(line))
+	}
+	return -1
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Gaps finds the periods of the time range without any log line for longer than a threshold,
+// e.g. an outage of the server or a failure of its logging, which the reports of the logs would silently skip over.
+// The start and the end of the time range count as log lines, so logs stopping before the end make a gap too.
+type Gaps struct {
+	threshold  time.Duration
+	start, end time.Time
+	// last is the latest log entry added, if any
+	last *LogEntry
+	gaps []Gap
+}
+
+// Gap is a period without any log line, along with the log entries around it.
+type Gap struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	// Before is the last log entry before the gap, nil for a gap starting with the time range,
+	// and After the first one after it, nil for a gap ending with the time range.
+	Before *LogEntry `json:"before,omitempty"`
+	After  *LogEntry `json:"after,omitempty"`
+}
+
+// NewGaps creates an empty Gaps finding the periods longer than a given threshold within a given time range.
+func NewGaps(threshold time.Duration, start, end time.Time) *Gaps {
+	return &Gaps{threshold: threshold, start: start, end: end}
+}
+
+// Gaps reads the log entries that happened within the last N minutes and finds the periods without any of them
+// for longer than a given threshold. Logs without any log files within their window (ErrNoFilesInWindow)
+// are a single gap. The gaps are returned even along with an error, found among the entries read until then.
+func (logs *Logs) Gaps(ctx context.Context, threshold time.Duration) (*Gaps, error) {
+	gaps := NewGaps(threshold, logs.nowMinusT(), logs.cfg.end())
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		gaps.Add(entry)
+		return nil
+	})
+	if errors.Is(err, ErrNoFilesInWindow) {
+		err = nil
+	}
+	return gaps, err
+}
+
+// Add accounts for a given log entry, the invalid lines included as they're logged all the same.
+// The entries are expected in order, the ones slightly out of order (e.g. of merged files) being taken as they come.
+func (g *Gaps) Add(entry LogEntry) {
+	start := g.start
+	if g.last != nil {
+		start = g.last.Time
+	}
+	if entry.Time.Sub(start) > g.threshold {
+		after := entry
+		g.gaps = append(g.gaps, Gap{Start: start, End: entry.Time, Duration: entry.Time.Sub(start), Before: g.last, After: &after})
+	}
+	if g.last == nil || entry.Time.After(g.last.Time) {
+		last := entry
+		g.last = &last
+	}
+}
+
+// List returns the gaps found, in order, along with the one ending the time range, if any.
+func (g *Gaps) List() []Gap {
+	gaps := g.gaps
+	start := g.start
+	if g.last != nil {
+		start = g.last.Time
+	}
+	if g.end.Sub(start) > g.threshold {
+		gaps = append(gaps[:len(gaps):len(gaps)], Gap{Start: start, End: g.end, Duration: g.end.Sub(start), Before: g.last})
+	}
+	return gaps
+}
+
+// Result returns the gaps found, see List.
+func (g *Gaps) Result() interface{} {
+	return g.List()
+}
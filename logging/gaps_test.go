@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const gapsDataDir = "test/gaps"
+
+type gapsSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *gapsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:10:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(gapsDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:10:00:30 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:01:00 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:06:00 +0000] "GET /c HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:07:00 +0000] "GET /d HTTP/1.1" 200 10
+`, t)
+}
+
+func (s *gapsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(gapsDataDir)))
+}
+
+func (s *gapsSuite) gaps(end time.Time, threshold time.Duration) []Gap {
+	logs, err := New(WithDirectory(gapsDataDir), WithWindow(10*time.Minute), WithEnd(end))
+	s.Require().NoError(err)
+	gaps, err := logs.Gaps(context.Background(), threshold)
+	s.Require().NoError(err)
+	return gaps.List()
+}
+
+func (s *gapsSuite) Test_Gaps() {
+	gaps := s.gaps(s.testTime, 2*time.Minute)
+	s.Require().Len(gaps, 2)
+
+	s.Equal(5*time.Minute, gaps[0].Duration)
+	s.Equal("/b", gaps[0].Before.Path)
+	s.Equal("/c", gaps[0].After.Path)
+	// the logs stopped 3 minutes before the end of the window
+	s.Equal(3*time.Minute, gaps[1].Duration)
+	s.Equal("/d", gaps[1].Before.Path)
+	s.Nil(gaps[1].After)
+	s.True(gaps[1].End.Equal(s.testTime))
+}
+
+func (s *gapsSuite) Test_Gaps_StartOfWindow() {
+	gaps := s.gaps(s.testTime, 20*time.Second)
+	s.Require().Len(gaps, 5)
+	s.Equal(30*time.Second, gaps[0].Duration)
+	s.Nil(gaps[0].Before)
+	s.Equal("/a", gaps[0].After.Path)
+}
+
+func (s *gapsSuite) Test_Gaps_NoFilesInWindow() {
+	gaps := s.gaps(s.testTime.Add(time.Hour), time.Minute)
+	s.Require().Len(gaps, 1)
+	s.Equal(10*time.Minute, gaps[0].Duration)
+	s.Nil(gaps[0].Before)
+	s.Nil(gaps[0].After)
+}
+
+func TestGapsSuite(t *testing.T) {
+	suite.Run(t, new(gapsSuite))
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// parseLogTime parses a time of the log lines, e.g. "03/Mar/2022:02:45:00 +0000", failing the test right away when invalid.
+func parseLogTime(t testing.TB, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(dateTimeFormat, value)
+	if err != nil {
+		t.Fatalf("invalid log time: %v", err)
+	}
+	return parsed
+}
+
+// writeLogFile writes a log file of the given content, along with its directory, modified at the given time.
+func writeLogFile(t testing.TB, name, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		t.Fatalf("could not create the log directory: %v", err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0666); err != nil {
+		t.Fatalf("could not write the log file: %v", err)
+	}
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatalf("could not set the modification time of the log file: %v", err)
+	}
+}
+
+// newTestLogs creates the Logs configured by the given options (e.g. a directory and a format) which window,
+// of the given length, ends at the given time rather than now.
+func newTestLogs(t testing.TB, end time.Time, window time.Duration, opts ...Option) *Logs {
+	t.Helper()
+	logs, err := New(append(opts, WithWindow(window))...)
+	if err != nil {
+		t.Fatalf("could not create the logs: %v", err)
+	}
+	logs.nowMinusT = func() time.Time {
+		return end.Add(-window)
+	}
+	return logs
+}
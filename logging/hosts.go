@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Host is a directory of log files labelled with the name of the host they come from, see LogsConfig.Hosts.
+type Host struct {
+	Name      string
+	Directory string
+	// Offset is how far ahead of the actual time the clock of the host is (behind when negative): the times
+	// of its logs are shifted back by it, so a skewed clock doesn't break the order of the merged logs nor the window.
+	// The modified times of its files are taken as they are, being the ones of the file system the logs are read from.
+	Offset time.Duration
+	// EstimateOffset estimates the Offset from the modified time of the latest file of the host when the logs are created,
+	// as the time of its last log line minus its modified time, which is up to date on a file written as the lines are logged.
+	// Estimates within minEstimatedOffset are left out, the log times being rounded down to the second.
+	EstimateOffset bool
+}
+
+// estimateOffset is the offset of a host given as name=directory@auto, estimating it (see Host.EstimateOffset).
+const estimateOffset = "auto"
+
+// minEstimatedOffset is the smallest offset estimated for a host (see Host.EstimateOffset),
+// smaller differences being the rounding of the log times and the delay of the writes.
+const minEstimatedOffset = 2 * time.Second
+
+// ParseHosts parses hosts given as comma-separated name=directory pairs, e.g. web1=/var/log/web1,web2=/mnt/web2/apache2,
+// each possibly followed by the offset of the clock of the host (see Host.Offset), e.g. web2=/mnt/web2@-1.5s,
+// or @auto to estimate it (see Host.EstimateOffset).
+func ParseHosts(s string) ([]Host, error) {
+	var hosts []Host
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("invalid host '%s': must be name=directory", pair)
+		}
+		host := Host{Name: pair[:i], Directory: pair[i+1:]}
+		if j := strings.LastIndexByte(host.Directory, '@'); j >= 0 {
+			offset := host.Directory[j+1:]
+			host.Directory = host.Directory[:j]
+			if offset == estimateOffset {
+				host.EstimateOffset = true
+			} else {
+				d, err := time.ParseDuration(offset)
+				if err != nil || host.Directory == "" {
+					return nil, fmt.Errorf("invalid host '%s': the offset must be a duration, e.g. -1.5s, or auto", pair)
+				}
+				host.Offset = d
+			}
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// hostDir is the directory of the log files of a host, cleaned so the paths of its files start with it,
+// along with the offset of the clock of the host (see Host.Offset).
+type hostDir struct {
+	name   string
+	dir    string
+	offset time.Duration
+}
+
+// newHostDirs returns the directories of the given hosts, the deepest ones first,
+// so a host nested inside the directory of another one is told apart.
+func newHostDirs(hosts []Host) []hostDir {
+	dirs := make([]hostDir, 0, len(hosts))
+	for _, host := range hosts {
+		dirs = append(dirs, hostDir{name: host.Name, dir: filepath.Clean(host.Directory) + string(filepath.Separator), offset: host.Offset})
+	}
+	for i := 1; i < len(dirs); i++ {
+		for j := i; j > 0 && len(dirs[j].dir) > len(dirs[j-1].dir); j-- {
+			dirs[j], dirs[j-1] = dirs[j-1], dirs[j]
+		}
+	}
+	return dirs
+}
+
+// hostDir returns the directory of the host of the log file of a given path, if any.
+func (logs *Logs) hostDir(file string) *hostDir {
+	for i, host := range logs.hostDirs {
+		if strings.HasPrefix(file, host.dir) {
+			return &logs.hostDirs[i]
+		}
+	}
+	return nil
+}
+
+// host returns the name of the host of the log file of a given path, if any.
+func (logs *Logs) host(file string) string {
+	if host := logs.hostDir(file); host != nil {
+		return host.name
+	}
+	return ""
+}
+
+// skew returns the offset of the clock of the host of the log file of a given path, if any (see Host.Offset).
+func (logs *Logs) skew(file string) time.Duration {
+	if host := logs.hostDir(file); host != nil {
+		return host.offset
+	}
+	return 0
+}
+
+// estimateOffsets estimates the offsets of the clocks of the hosts asking for it, see Host.EstimateOffset.
+// The hosts without any file, or which latest file can't be parsed, are left without offset.
+func (logs *Logs) estimateOffsets() error {
+	for _, host := range logs.cfg.Hosts {
+		if !host.EstimateOffset {
+			continue
+		}
+		hostCfg := logs.cfg
+		hostCfg.Directory = host.Directory
+		files, err := listFiles(hostCfg)
+		if err != nil {
+			return fmt.Errorf("could not list the files of host %s: %w", host.Name, err)
+		}
+		files = nonEmpty(files)
+		if len(files) == 0 {
+			continue
+		}
+		latest := files[0]
+		for _, fi := range files[1:] {
+			if fi.ModTime().After(latest.ModTime()) {
+				latest = fi
+			}
+		}
+
+		f, err := logs.cfg.fileSystem().Open(latest.path)
+		if err != nil {
+			return err
+		}
+		file := NewFormatFile(f, logs.cfg.format(latest.path))
+		file.loc = logs.cfg.TimeZone
+		_, last, err := file.TimeRange()
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+		offset := last.Sub(latest.ModTime()).Round(time.Second)
+		if offset > -minEstimatedOffset && offset < minEstimatedOffset {
+			offset = 0
+		}
+		for i := range logs.hostDirs {
+			if logs.hostDirs[i].name == host.Name {
+				logs.hostDirs[i].offset = offset
+			}
+		}
+		logs.info("offset estimated", "host", host.Name, "file", latest.path, "offset", offset)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	hostsWeb1Dir = "test/hosts/web1"
+	hostsWeb2Dir = "test/hosts/web2"
+	// the clock of web3 is an hour ahead
+	hostsWeb3Dir = "test/hosts/web3"
+)
+
+type hostsSuite struct {
+	suite.Suite
+	t time.Time
+}
+
+func (s *hostsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.t = t
+	// web3 was last written at 02:44:25, as its last line was logged
+	written := t.Add(-35 * time.Second)
+	writeLogFile(s.T(), filepath.Join(hostsWeb1Dir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:44:30 +0000] "GET /c HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:44:50 +0000] "GET /e HTTP/1.1" 200 20
+`, t)
+	writeLogFile(s.T(), filepath.Join(hostsWeb2Dir, "access.log"), `10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.1" 500 10
+10.0.0.2 - - [03/Mar/2022:02:44:40 +0000] "GET /d HTTP/1.1" 200 10
+`, t)
+	writeLogFile(s.T(), filepath.Join(hostsWeb3Dir, "access.log"), `10.0.0.3 - - [03/Mar/2022:03:43:50 +0000] "GET /x HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:03:44:25 +0000] "GET /y HTTP/1.1" 200 10
+`, written)
+}
+
+func (s *hostsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(hostsWeb1Dir)))
+}
+
+// newLogs creates the logs of the hosts web1 and web2 of the last minute of the test data.
+func (s *hostsSuite) newLogs(opts ...Option) *Logs {
+	hosts := WithHosts(Host{Name: "web1", Directory: hostsWeb1Dir}, Host{Name: "web2", Directory: hostsWeb2Dir + "/"})
+	logs, err := New(append([]Option{hosts, WithWindow(time.Minute), WithEnd(s.t)}, opts...)...)
+	s.Require().NoError(err)
+	return logs
+}
+
+func (s *hostsSuite) Test_ForEach_MergesHosts() {
+	logs := s.newLogs()
+
+	var entries []string
+	err := logs.ForEach(context.Background(), func(entry LogEntry) error {
+		entries = append(entries, entry.Host+" "+entry.Path)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"web1 /a", "web2 /b", "web1 /c", "web2 /d", "web1 /e"}, entries)
+}
+
+func (s *hostsSuite) Test_ForEach_Offset() {
+	for name, web3 := range map[string]Host{
+		"manual":    {Name: "web3", Directory: hostsWeb3Dir, Offset: time.Hour},
+		"estimated": {Name: "web3", Directory: hostsWeb3Dir, EstimateOffset: true},
+	} {
+		logs, err := New(WithHosts(Host{Name: "web1", Directory: hostsWeb1Dir}, web3), WithWindow(time.Minute), WithEnd(s.t))
+		s.Require().NoError(err, name)
+
+		var entries []string
+		err = logs.ForEach(context.Background(), func(entry LogEntry) error {
+			entries = append(entries, entry.Time.Format("15:04:05")+" "+entry.Path)
+			return nil
+		})
+		s.Require().NoError(err, name)
+		// the first line of web3 happened before the window
+		s.Equal([]string{"02:44:10 /a", "02:44:25 /y", "02:44:30 /c", "02:44:50 /e"}, entries, name)
+	}
+}
+
+func (s *hostsSuite) Test_Top_Hosts() {
+	logs := s.newLogs()
+
+	top, err := logs.Top(context.Background(), TopHosts)
+	s.Require().NoError(err)
+	s.Equal([]Count{{Value: "web1", Count: 3}, {Value: "web2", Count: 2}}, top.Ranked(TopHosts, 0))
+}
+
+func (s *hostsSuite) Test_Condition_Host() {
+	host, err := ParseCondition("host == web2")
+	s.Require().NoError(err)
+	logs := s.newLogs(WithFilter(host))
+
+	var paths []string
+	err = logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"/b", "/d"}, paths)
+}
+
+func (s *hostsSuite) Test_New_InvalidHosts() {
+	for name, hosts := range map[string][]Host{
+		"no name":      {{Directory: hostsWeb1Dir}},
+		"no directory": {{Name: "web1"}},
+		"duplicate":    {{Name: "web1", Directory: hostsWeb1Dir}, {Name: "web1", Directory: hostsWeb2Dir}},
+	} {
+		_, err := New(WithHosts(hosts...))
+		var configErr *ConfigError
+		s.True(errors.As(err, &configErr), name)
+		s.Equal("hosts", configErr.Field, name)
+	}
+}
+
+func (s *hostsSuite) Test_ParseHosts() {
+	hosts, err := ParseHosts("web1=/var/log/web1, web2=/mnt/web2/apache2")
+	s.Require().NoError(err)
+	s.Equal([]Host{{Name: "web1", Directory: "/var/log/web1"}, {Name: "web2", Directory: "/mnt/web2/apache2"}}, hosts)
+
+	hosts, err = ParseHosts("web1=/var/log/web1@-1.5s,web2=/mnt/web2@auto")
+	s.Require().NoError(err)
+	s.Equal([]Host{{Name: "web1", Directory: "/var/log/web1", Offset: -1500 * time.Millisecond}, {Name: "web2", Directory: "/mnt/web2", EstimateOffset: true}}, hosts)
+
+	for _, invalid := range []string{"web1", "=/var/log", "web1=", "web1=/var/log@1", "web1=@1s"} {
+		_, err := ParseHosts(invalid)
+		s.Error(err, invalid)
+	}
+}
+
+func (s *hostsSuite) Test_host_NestedDirectories() {
+	logs := &Logs{hostDirs: newHostDirs([]Host{{Name: "all", Directory: "/var/log"}, {Name: "web1", Directory: "/var/log/web1"}})}
+
+	s.Equal("web1", logs.host("/var/log/web1/access.log"))
+	s.Equal("all", logs.host("/var/log/access.log"))
+	s.Equal("", logs.host("/srv/access.log"))
+}
+
+func TestHostsSuite(t *testing.T) {
+	suite.Run(t, new(hostsSuite))
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// hyperLogLogPrecision is the number of bits of the hashes picking a register of a hyperLogLog,
+// using 2^14 registers (16KB) for a standard error of about 0.8% (1.04/sqrt(2^14)).
+const hyperLogLogPrecision = 14
+
+// hyperLogLog estimates the number of distinct values of a stream (Flajolet et al.) using constant memory,
+// however many values there are. Small cardinalities are estimated by linear counting, which is more accurate.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hyperLogLogPrecision)}
+}
+
+// add adds a value to the estimation.
+func (hll *hyperLogLog) add(value string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	hash := mix64(h.Sum64())
+
+	idx := hash >> (64 - hyperLogLogPrecision)
+	// the position of the leftmost 1 within the remaining bits
+	rank := uint8(bits.LeadingZeros64(hash<<hyperLogLogPrecision|1<<(hyperLogLogPrecision-1))) + 1
+	if rank > hll.registers[idx] {
+		hll.registers[idx] = rank
+	}
+}
+
+// count returns the estimated number of distinct values added.
+func (hll *hyperLogLog) count() uint64 {
+	m := float64(len(hll.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range hll.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// mix64 scrambles the bits of a hash (the splitmix64 finalizer), since FNV spreads
+// similar values (e.g. IPs of the same subnet) poorly over the high bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
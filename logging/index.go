@@ -0,0 +1,357 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// IndexSuffix is the suffix of the sidecar index files, see LogsConfig.IndexInterval.
+	// Files with this suffix (or being written, with a further suffix) are never read as log files.
+	IndexSuffix = ".logidx"
+	// indexMagic starts every index file, along with the version of its layout,
+	// and indexBloomMagic the ones storing Bloom filters (see LogsConfig.IndexBloom).
+	indexMagic      = "LOGIDX1\n"
+	indexBloomMagic = "LOGIDX2\n"
+	// indexSampleSize is the number of bytes of the log file sampled at both ends
+	// of the indexed part, to tell whether the log file is still the one indexed.
+	indexSampleSize = 4096
+)
+
+// indexEntry is the offset of the first log line that happened within an interval of log time.
+type indexEntry struct {
+	// Time is the start of the interval, in Unix nanoseconds.
+	Time   int64
+	Offset int64
+}
+
+// timeIndex is the index of a log file, stored next to it in a sidecar file (see IndexSuffix):
+// the offset of the first log line of every interval of log time having logs,
+// covering the first Size bytes of the log file.
+type timeIndex struct {
+	interval time.Duration
+	size     int64
+	// head and tail are the checksums of the first and last indexSampleSize bytes of the indexed part.
+	head, tail uint32
+	entries    []indexEntry
+	// blooms are the Bloom filters of the IPs and path prefixes of the log lines of every entry,
+	// from its offset to the offset of the next entry, when built with them (see LogsConfig.IndexBloom).
+	bloom  bool
+	blooms []bloomFilter
+}
+
+// indexHeader is the header of an index file, following indexMagic and followed by its entries,
+// then by the Bloom filters of the entries (their number of words and their words) when built with them.
+type indexHeader struct {
+	Interval   int64
+	Size       int64
+	Head, Tail uint32
+	Count      uint32
+}
+
+// indexPath returns the path of the sidecar index file of a given log file.
+func indexPath(name string) string {
+	return name + IndexSuffix
+}
+
+// isIndexFile checks whether a given file name is the one of an index file, or of one being written.
+func isIndexFile(name string) bool {
+	return strings.Contains(name, IndexSuffix)
+}
+
+// IndexedTime looks for the offset of the first log line that happened at or after the lookup time,
+// the same way IndexTime does, using the sidecar index file of the log file instead of a binary search:
+// the index is built on first use, extended when the log file has grown since, rebuilt when it changed otherwise,
+// and saved for the next runs. The index stores the offset of the first log line of every given interval of log time,
+// so only the log lines of a single interval are read past the offset it gives.
+// Failing to save the index (e.g. a read-only directory) is not an error, the index being used all the same.
+func (file File) IndexedTime(ctx context.Context, lookupTime time.Time, interval time.Duration) (int64, error) {
+	return file.indexedTime(ctx, lookupTime, interval, false)
+}
+
+// indexedTime looks for the offset of the first log line that happened at or after the lookup time the way IndexedTime does,
+// building the index with Bloom filters when asked to (see LogsConfig.IndexBloom).
+func (file File) indexedTime(ctx context.Context, lookupTime time.Time, interval time.Duration, bloom bool) (int64, error) {
+	if interval <= 0 {
+		return -1, errors.New("index interval must be positive")
+	}
+	// the index holds the times as logged
+	lookupTime, file.skew = lookupTime.Add(file.skew), 0
+	end, err := file.end()
+	if err != nil {
+		return -1, err
+	}
+
+	idx, err := file.loadIndex(ctx, interval, end, bloom)
+	if err != nil {
+		return -1, err
+	}
+	return file.scanIndex(ctx, idx, lookupTime, end)
+}
+
+// loadIndex reads the sidecar index of the log file, building or updating it (and saving it) when needed,
+// so it covers the log file up to a given end, with Bloom filters when asked to. An index built with Bloom filters
+// keeps them up to date even when not asked to.
+func (file File) loadIndex(ctx context.Context, interval time.Duration, end int64, bloom bool) (*timeIndex, error) {
+	// the index holds the times as logged, whatever the skew of the file
+	file.skew = 0
+	idx, err := readIndex(indexPath(file.Name()))
+	switch {
+	case err != nil || idx.interval != interval || idx.size > end || (bloom && !idx.bloom):
+		idx = &timeIndex{interval: interval, bloom: bloom}
+	case !file.indexed(idx):
+		// the log file has been replaced (e.g. rotated by copytruncate and written again)
+		idx = &timeIndex{interval: interval, bloom: bloom}
+	case idx.size == end:
+		return idx, nil
+	}
+
+	if err := file.extendIndex(ctx, idx, end); err != nil {
+		return nil, err
+	}
+	_ = writeIndex(indexPath(file.Name()), idx)
+	return idx, nil
+}
+
+// indexed checks whether a given index was built from the log file, comparing the checksums of both ends of its indexed part.
+func (file File) indexed(idx *timeIndex) bool {
+	head, tail, err := file.checksums(idx.size)
+	return err == nil && head == idx.head && tail == idx.tail
+}
+
+// checksums returns the checksums of the first and last indexSampleSize bytes of the first size bytes of the log file.
+func (file File) checksums(size int64) (uint32, uint32, error) {
+	n := int64(indexSampleSize)
+	if size < n {
+		n = size
+	}
+	buf := make([]byte, n)
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		return 0, 0, err
+	}
+	head := crc32.ChecksumIEEE(buf)
+	if _, err := file.ReadAt(buf, size-n); err != nil {
+		return 0, 0, err
+	}
+	return head, crc32.ChecksumIEEE(buf), nil
+}
+
+// extendIndex reads the log lines of the log file between the end of the indexed part and a given end,
+// adding an entry for every interval of log time reached. The lines that cannot be parsed are skipped.
+// The Bloom filter of the last entry, if any, is built again along with the lines appended to its interval.
+func (file File) extendIndex(ctx context.Context, idx *timeIndex, end int64) error {
+	if n := len(idx.entries); idx.bloom && n > 0 {
+		idx.size = idx.entries[n-1].Offset
+		idx.entries, idx.blooms = idx.entries[:n-1], idx.blooms[:n-1]
+	}
+	reader := getReader(contextReader{ctx: ctx, r: io.NewSectionReader(file, idx.size, end-idx.size)})
+	defer putReader(reader)
+	// keys are the IPs and path prefixes of the lines of the last entry, when building Bloom filters
+	keys := make(map[bloomKey]struct{})
+	addBloom := func() {
+		if !idx.bloom || len(idx.entries) == len(idx.blooms) {
+			return
+		}
+		bloom := newBloomFilter(len(keys))
+		for key := range keys {
+			bloom.add(key.kind, key.value)
+			delete(keys, key)
+		}
+		idx.blooms = append(idx.blooms, bloom)
+	}
+	offset := idx.size
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" {
+			break
+		}
+
+		var logTime time.Time
+		var entry LogEntry
+		if idx.bloom {
+			entry, err = file.parseLogEntry(strings.TrimSpace(line))
+			logTime = entry.Time
+		} else {
+			logTime, err = file.parseLogTime(strings.TrimSpace(line))
+		}
+		if err == nil {
+			start := logTime.Truncate(idx.interval).UnixNano()
+			if n := len(idx.entries); n == 0 || start > idx.entries[n-1].Time {
+				addBloom()
+				idx.entries = append(idx.entries, indexEntry{Time: start, Offset: offset})
+			}
+			if idx.bloom && len(idx.entries) > 0 {
+				keys[bloomKey{kind: bloomIP, value: entry.IP}] = struct{}{}
+				pathPrefixes(entry.Path, func(prefix string) {
+					keys[bloomKey{kind: bloomPath, value: prefix}] = struct{}{}
+				})
+			}
+		}
+		offset += int64(len(line))
+	}
+	addBloom()
+
+	idx.size = end
+	var err error
+	idx.head, idx.tail, err = file.checksums(end)
+	return err
+}
+
+// bloomKey is a key added to the Bloom filter of an entry of the index, along with its kind.
+type bloomKey struct {
+	kind  byte
+	value string
+}
+
+// skippable returns the byte ranges of the indexed part of the log file without any log line of a given IP
+// or path prefix (see bloomPathKey), according to the Bloom filters of the index, sorted by offset.
+// Empty values are not looked for, and no range is returned when the index has no Bloom filters.
+func (idx *timeIndex) skippable(ip, pathKey string) []byteRange {
+	if !idx.bloom || (ip == "" && pathKey == "") {
+		return nil
+	}
+	var ranges []byteRange
+	for i, bloom := range idx.blooms {
+		if (ip == "" || bloom.mayContain(bloomIP, ip)) && (pathKey == "" || bloom.mayContain(bloomPath, pathKey)) {
+			continue
+		}
+		end := idx.size
+		if i+1 < len(idx.entries) {
+			end = idx.entries[i+1].Offset
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].end == idx.entries[i].Offset {
+			ranges[n-1].end = end
+		} else {
+			ranges = append(ranges, byteRange{start: idx.entries[i].Offset, end: end})
+		}
+	}
+	return ranges
+}
+
+// byteRange is a range of bytes of a file, from start (included) to end (excluded).
+type byteRange struct {
+	start, end int64
+}
+
+// scanIndex returns the offset of the first log line that happened at or after the lookup time, or -1 if there's none,
+// reading the log lines from the offset of the interval of the lookup time onwards.
+func (file File) scanIndex(ctx context.Context, idx *timeIndex, lookupTime time.Time, end int64) (int64, error) {
+	lookup := lookupTime.UnixNano()
+	// the first interval starting after the lookup time, the log lines before it having happened before it
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return idx.entries[i].Time > lookup
+	})
+	if i == 0 {
+		return 0, nil
+	}
+
+	return file.scanTime(ctx, idx.entries[i-1].Offset, end, lookupTime)
+}
+
+// readIndex reads the index file of a given path.
+func readIndex(path string) (*timeIndex, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || (string(magic) != indexMagic && string(magic) != indexBloomMagic) {
+		return nil, errors.New("invalid index file")
+	}
+	bloom := string(magic) == indexBloomMagic
+
+	var header indexHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if size := int64(header.Count) * 16; size > int64(r.Len()) || (!bloom && size != int64(r.Len())) {
+		return nil, errors.New("invalid index file")
+	}
+	idx := &timeIndex{
+		interval: time.Duration(header.Interval),
+		size:     header.Size,
+		head:     header.Head,
+		tail:     header.Tail,
+		entries:  make([]indexEntry, header.Count),
+		bloom:    bloom,
+	}
+	if err := binary.Read(r, binary.LittleEndian, idx.entries); err != nil {
+		return nil, err
+	}
+	if !bloom {
+		return idx, nil
+	}
+
+	idx.blooms = make([]bloomFilter, header.Count)
+	for i := range idx.blooms {
+		var words uint32
+		if err := binary.Read(r, binary.LittleEndian, &words); err != nil {
+			return nil, err
+		}
+		if words == 0 || int64(words)*8 > int64(r.Len()) {
+			return nil, errors.New("invalid index file")
+		}
+		idx.blooms[i] = make(bloomFilter, words)
+		if err := binary.Read(r, binary.LittleEndian, idx.blooms[i]); err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("invalid index file")
+	}
+	return idx, nil
+}
+
+// writeIndex writes an index to the index file of a given path, replacing it atomically,
+// so concurrent runs never read a partially written index.
+func writeIndex(path string, idx *timeIndex) error {
+	var buf bytes.Buffer
+	if idx.bloom {
+		buf.WriteString(indexBloomMagic)
+	} else {
+		buf.WriteString(indexMagic)
+	}
+	header := indexHeader{Interval: int64(idx.interval), Size: idx.size, Head: idx.head, Tail: idx.tail, Count: uint32(len(idx.entries))}
+	if err := binary.Write(&buf, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, idx.entries); err != nil {
+		return err
+	}
+	for _, bloom := range idx.blooms {
+		if err := binary.Write(&buf, binary.LittleEndian, uint32(len(bloom))); err != nil {
+			return err
+		}
+		if err := binary.Write(&buf, binary.LittleEndian, bloom); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,322 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const indexDataDir = "test/index"
+
+type indexSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *indexSuite) SetupTest() {
+	s.Require().NoError(os.RemoveAll(indexDataDir))
+	s.Require().NoError(os.MkdirAll(indexDataDir, 0777))
+	s.testTime = parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+}
+
+func (s *indexSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(indexDataDir)))
+}
+
+// logLines returns n log lines of various lengths, one every 7 seconds from a given time.
+func (s *indexSuite) logLines(from time.Time, n int) string {
+	var logs strings.Builder
+	for i := 0; i < n; i++ {
+		logs.WriteString(fmt.Sprintf("10.0.0.%d - - [%s] \"GET /%s HTTP/1.1\" 200 %d\n",
+			i%10, from.Add(time.Duration(i)*7*time.Second).Format(dateTimeFormat), strings.Repeat("a", i%37), i))
+	}
+	return logs.String()
+}
+
+// expectedOffset returns the offset of the first log line of given logs that happened at or after a given time, or -1.
+func (s *indexSuite) expectedOffset(logs string, lookupTime time.Time) int64 {
+	file := NewFile(nil)
+	offset := int64(0)
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		if line == "" {
+			break
+		}
+		logTime, err := file.parseLogTime(strings.TrimSpace(line))
+		s.Require().NoError(err)
+		if !logTime.Before(lookupTime) {
+			return offset
+		}
+		offset += int64(len(line))
+	}
+	return -1
+}
+
+func (s *indexSuite) open(name string) File {
+	f, err := os.Open(filepath.Join(indexDataDir, name))
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { _ = f.Close() })
+	return NewFile(f)
+}
+
+func (s *indexSuite) Test_IndexedTime() {
+	start := s.testTime.Add(-time.Hour)
+	logs := s.logLines(start, 500)
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log"), []byte(logs), 0666))
+	file := s.open("access.log")
+
+	for _, lookupTime := range []time.Time{
+		start.Add(-time.Hour),
+		start,
+		start.Add(time.Second),
+		start.Add(10 * time.Minute),
+		start.Add(10*time.Minute + 30*time.Second),
+		start.Add(7 * 499 * time.Second),
+		start.Add(7*499*time.Second + time.Nanosecond),
+		s.testTime.Add(time.Hour),
+	} {
+		offset, err := file.IndexedTime(context.Background(), lookupTime, 30*time.Second)
+		s.Require().NoError(err)
+		s.Equal(s.expectedOffset(logs, lookupTime), offset, lookupTime)
+	}
+
+	idx, err := readIndex(filepath.Join(indexDataDir, "access.log"+IndexSuffix))
+	s.Require().NoError(err)
+	s.Equal(30*time.Second, idx.interval)
+	s.Equal(int64(len(logs)), idx.size)
+	// one entry every 30 seconds of logs
+	s.Len(idx.entries, 7*500/30+1)
+	s.Equal(indexEntry{Time: start.UnixNano(), Offset: 0}, idx.entries[0])
+}
+
+func (s *indexSuite) Test_IndexedTime_Reused() {
+	logs := s.logLines(s.testTime.Add(-time.Hour), 100)
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log"), []byte(logs), 0666))
+	file := s.open("access.log")
+	_, err := file.IndexedTime(context.Background(), s.testTime.Add(-30*time.Minute), time.Minute)
+	s.Require().NoError(err)
+
+	// a forged index is trusted as long as it matches the log file
+	indexFile := filepath.Join(indexDataDir, "access.log"+IndexSuffix)
+	idx, err := readIndex(indexFile)
+	s.Require().NoError(err)
+	forged := *idx
+	forged.entries = []indexEntry{{Time: s.testTime.Add(-time.Hour).UnixNano(), Offset: 0}, {Time: s.testTime.UnixNano(), Offset: 42}}
+	s.Require().NoError(writeIndex(indexFile, &forged))
+	offset, err := file.IndexedTime(context.Background(), s.testTime.Add(time.Minute), time.Minute)
+	s.Require().NoError(err)
+	s.Equal(int64(-1), offset)
+	reused, err := readIndex(indexFile)
+	s.Require().NoError(err)
+	s.Equal(forged.entries, reused.entries)
+
+	// but rebuilt for another interval
+	_, err = file.IndexedTime(context.Background(), s.testTime.Add(time.Minute), 2*time.Minute)
+	s.Require().NoError(err)
+	rebuilt, err := readIndex(indexFile)
+	s.Require().NoError(err)
+	s.Equal(2*time.Minute, rebuilt.interval)
+	s.Equal(s.testTime.Add(-time.Hour).Truncate(2*time.Minute).UnixNano(), rebuilt.entries[0].Time)
+	// from 01:44 to 01:56
+	s.Len(rebuilt.entries, 7)
+}
+
+func (s *indexSuite) Test_IndexedTime_Appended() {
+	start := s.testTime.Add(-time.Hour)
+	logs := s.logLines(start, 100)
+	// a partially written last line is left out of the index until complete
+	partial := `10.0.0.1 - - [03/Mar/2022:02:44:00 +0000] "GET / HTTP/1.1`
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log"), []byte(logs+partial), 0666))
+	file := s.open("access.log")
+	_, err := file.IndexedTime(context.Background(), start, time.Minute)
+	s.Require().NoError(err)
+	idx, err := readIndex(filepath.Join(indexDataDir, "access.log"+IndexSuffix))
+	s.Require().NoError(err)
+	s.Equal(int64(len(logs)), idx.size)
+
+	f, err := os.OpenFile(filepath.Join(indexDataDir, "access.log"), os.O_APPEND|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	appended := "\" 200 1\n" + s.logLines(s.testTime.Add(-45*time.Second), 5)
+	_, err = f.WriteString(appended)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+
+	all := logs + partial + appended
+	for _, lookupTime := range []time.Time{start.Add(5 * time.Minute), s.testTime.Add(-time.Minute), s.testTime.Add(-30 * time.Second)} {
+		offset, err := file.IndexedTime(context.Background(), lookupTime, time.Minute)
+		s.Require().NoError(err)
+		s.Equal(s.expectedOffset(all, lookupTime), offset, lookupTime)
+	}
+	extended, err := readIndex(filepath.Join(indexDataDir, "access.log"+IndexSuffix))
+	s.Require().NoError(err)
+	s.Equal(int64(len(all)), extended.size)
+	s.Equal(idx.entries, extended.entries[:len(idx.entries)])
+}
+
+func (s *indexSuite) Test_IndexedTime_Replaced() {
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log"), []byte(s.logLines(s.testTime.Add(-2*time.Hour), 100)), 0666))
+	file := s.open("access.log")
+	_, err := file.IndexedTime(context.Background(), s.testTime, time.Minute)
+	s.Require().NoError(err)
+
+	// the log file is rotated (copytruncate) and written again, growing past the indexed size
+	logs := s.logLines(s.testTime.Add(-time.Hour), 200)
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log"), []byte(logs), 0666))
+	lookupTime := s.testTime.Add(-50 * time.Minute)
+	offset, err := file.IndexedTime(context.Background(), lookupTime, time.Minute)
+	s.Require().NoError(err)
+	s.Equal(s.expectedOffset(logs, lookupTime), offset)
+}
+
+func (s *indexSuite) Test_IndexedTime_InvalidIndex() {
+	logs := s.logLines(s.testTime.Add(-time.Hour), 100)
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log"), []byte(logs), 0666))
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log"+IndexSuffix), []byte("LOGIDX1\ngarbage"), 0666))
+	file := s.open("access.log")
+
+	lookupTime := s.testTime.Add(-30 * time.Minute)
+	offset, err := file.IndexedTime(context.Background(), lookupTime, time.Minute)
+	s.Require().NoError(err)
+	s.Equal(s.expectedOffset(logs, lookupTime), offset)
+	_, err = readIndex(filepath.Join(indexDataDir, "access.log"+IndexSuffix))
+	s.NoError(err)
+
+	_, err = file.IndexedTime(context.Background(), lookupTime, 0)
+	s.Error(err)
+}
+
+func (s *indexSuite) Test_Logs_WithIndex() {
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log.1"), []byte(s.logLines(s.testTime.Add(-3*time.Hour), 1000)), 0666))
+	logs := s.logLines(s.testTime.Add(-time.Hour), 500)
+	s.Require().NoError(os.WriteFile(filepath.Join(indexDataDir, "access.log"), []byte(logs), 0666))
+	s.Require().NoError(os.Chtimes(filepath.Join(indexDataDir, "access.log.1"), s.testTime.Add(-time.Hour), s.testTime.Add(-time.Hour)))
+	s.Require().NoError(os.Chtimes(filepath.Join(indexDataDir, "access.log"), s.testTime, s.testTime))
+
+	read := func(opts ...Option) []string {
+		logs, err := New(append([]Option{WithDirectory(indexDataDir), WithWindow(30 * time.Minute)}, opts...)...)
+		s.Require().NoError(err)
+		logs.nowMinusT = func() time.Time {
+			return s.testTime.Add(-30 * time.Minute)
+		}
+		var lines []string
+		s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+			lines = append(lines, entry.Line)
+			return nil
+		}))
+		return lines
+	}
+
+	// the lines of the first file all happened before the window
+	fresh := logs[s.expectedOffset(logs, s.testTime.Add(-30*time.Minute)):]
+	expected := strings.Split(strings.TrimSuffix(fresh, "\n"), "\n")
+	// the index files are created, then reused, and never read as log files
+	s.Equal(expected, read(WithIndex(10*time.Second)))
+	s.FileExists(filepath.Join(indexDataDir, "access.log"+IndexSuffix))
+	s.Equal(expected, read(WithIndex(10*time.Second)))
+}
+
+// bloomLines returns n log lines, one every 6 seconds from a given time, of a few IPs and static paths,
+// but for every 100th line, of a rare IP and an API path.
+func (s *indexSuite) bloomLines(from time.Time, first, n int) string {
+	var logs strings.Builder
+	for i := first; i < first+n; i++ {
+		ip, p := fmt.Sprintf("10.0.0.%d", i%10), "/static/app.js"
+		if i%100 == 42 {
+			ip, p = "10.9.9.9", fmt.Sprintf("/api/v1/users/%d", i)
+		}
+		logs.WriteString(fmt.Sprintf("%s - - [%s] \"GET %s HTTP/1.1\" 200 %d\n",
+			ip, from.Add(time.Duration(i)*6*time.Second).Format(dateTimeFormat), p, i))
+	}
+	return logs.String()
+}
+
+func (s *indexSuite) Test_Logs_WithIndexBloom() {
+	start := s.testTime.Add(-time.Hour)
+	name := filepath.Join(indexDataDir, "access.log")
+	s.Require().NoError(os.WriteFile(name, []byte(s.bloomLines(start, 0, 600)), 0666))
+	s.Require().NoError(os.Chtimes(name, s.testTime, s.testTime))
+
+	newLogs := func(opts ...Option) *Logs {
+		logs, err := New(append([]Option{WithDirectory(indexDataDir), WithWindow(2 * time.Hour)}, opts...)...)
+		s.Require().NoError(err)
+		logs.nowMinusT = func() time.Time {
+			return s.testTime.Add(-2 * time.Hour)
+		}
+		return logs
+	}
+	// read returns the lines read along with the number of lines the cursor went through
+	read := func(opts ...Option) ([]string, int) {
+		logs := newLogs(opts...)
+		var lines []string
+		s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+			lines = append(lines, entry.Line)
+			return nil
+		}))
+
+		file, err := logs.open(name)
+		s.Require().NoError(err)
+		defer func() { _ = file.Close() }()
+		c, err := logs.cursor(context.Background(), file, 0, nil)
+		s.Require().NoError(err)
+		scanned := 0
+		for ok := c != nil; ok; ok, err = c.next() {
+			s.Require().NoError(err)
+			scanned++
+		}
+		return lines, scanned
+	}
+
+	for _, test := range []struct {
+		name     string
+		opts     []Option
+		expected int
+	}{
+		{name: "IP", opts: []Option{WithIP("10.9.9.9")}, expected: 6},
+		{name: "Path Prefix", opts: []Option{WithPathPrefix("/api/v1/u")}, expected: 6},
+		{name: "Both", opts: []Option{WithIP("10.9.9.9"), WithPathPrefix("/api/")}, expected: 6},
+		{name: "Neither", opts: []Option{WithIP("10.9.9.9"), WithPathPrefix("/static/")}, expected: 0},
+		{name: "Missing", opts: []Option{WithIP("192.168.0.1")}, expected: 0},
+	} {
+		s.Run(test.name, func() {
+			expected, scanned := read(append([]Option{WithIndex(time.Minute)}, test.opts...)...)
+			s.Len(expected, test.expected)
+			s.Equal(600, scanned)
+
+			// only the intervals (of 10 lines) of the lines looked for, plus a few false positives, are read
+			lines, scanned := read(append([]Option{WithIndex(time.Minute), WithIndexBloom()}, test.opts...)...)
+			s.Equal(expected, lines)
+			s.LessOrEqual(scanned, 80)
+		})
+	}
+
+	// the lines of the IP are all there, without any Bloom filter to skip the intervals
+	lines, scanned := read(WithIndex(time.Minute), WithIndexBloom(), WithPathPrefix("/api"))
+	s.Len(lines, 6)
+	s.Equal(600, scanned)
+
+	idx, err := readIndex(name + IndexSuffix)
+	s.Require().NoError(err)
+	s.True(idx.bloom)
+	s.Len(idx.blooms, len(idx.entries))
+
+	// the Bloom filter of the last interval is built again with the lines appended to it
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	_, err = f.WriteString(s.bloomLines(start, 600, 50))
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+	s.Require().NoError(os.Chtimes(name, s.testTime, s.testTime))
+	lines, _ = read(WithIndex(time.Minute), WithIndexBloom(), WithIP("10.9.9.9"))
+	s.Len(lines, 7)
+	expected, _ := read(WithIndex(time.Minute), WithIP("10.9.9.9"))
+	s.Equal(expected, lines)
+}
+
+func TestIndex(t *testing.T) {
+	suite.Run(t, new(indexSuite))
+}
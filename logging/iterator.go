@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStop can be returned by the function given to ForEach to stop the iteration early without failing it.
+var ErrStop = errors.New("stop iteration")
+
+// Iterator iterates in order over the log entries that happened within the last N minutes:
+//
+//	it := logs.Entries(ctx)
+//	defer it.Close()
+//	for it.Next() {
+//		entry := it.Entry()
+//		// ...
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type Iterator struct {
+	stream *stream
+	// sorter guarantees the entries are in order, if told so (see LogsConfig.AssertSorted)
+	sorter *sorter
+}
+
+// Entries returns an Iterator over the log entries that happened within the last N minutes,
+// which stops early once the given context is done. Make sure to close the Iterator once done with it.
+func (logs *Logs) Entries(ctx context.Context) *Iterator {
+	it := &Iterator{stream: logs.stream(ctx)}
+	if logs.cfg.AssertSorted {
+		it.sorter = &sorter{size: logs.cfg.ReorderBuffer}
+	}
+	return it
+}
+
+// Next advances the Iterator to the following log entry, which is then available through Entry.
+// It returns false once there are no entries left or an error occurred, see Err.
+func (it *Iterator) Next() bool {
+	if it.sorter != nil {
+		return it.sorter.next(it.stream)
+	}
+	return it.stream.next()
+}
+
+// Entry returns the current log entry, only valid after a call to Next returning true.
+func (it *Iterator) Entry() LogEntry {
+	if it.sorter != nil {
+		return it.sorter.last
+	}
+	return it.stream.entry()
+}
+
+// Err returns the error that stopped the Iterator, if any.
+func (it *Iterator) Err() error {
+	if it.sorter != nil && it.sorter.err != nil {
+		return it.sorter.err
+	}
+	return it.stream.err
+}
+
+// Close releases the log files still opened by the Iterator.
+func (it *Iterator) Close() error {
+	it.stream.close()
+	return nil
+}
+
+// ForEach calls the given function for each log entry that happened within the last N minutes, in order.
+// The iteration stops at the first error returned by the function, which is then returned by ForEach,
+// except for ErrStop, which stops the iteration and makes ForEach return nil.
+func (logs *Logs) ForEach(ctx context.Context, fn func(LogEntry) error) error {
+	it := logs.Entries(ctx)
+	defer func() { _ = it.Close() }()
+
+	for it.Next() {
+		if err := fn(it.Entry()); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return it.Err()
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const iteratorDataDir = "test/iterator"
+
+type iteratorSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *iteratorSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(iteratorDataDir, "http.log"), `10.0.0.2 - frank [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.0" 200 20
+this line cannot be parsed
+10.0.0.3 - frank [03/Mar/2022:02:44:50 +0000] "POST /b HTTP/1.1" 500 30
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(iteratorDataDir))
+}
+
+func (s *iteratorSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(iteratorDataDir)))
+}
+
+func (s *iteratorSuite) Test_Entries() {
+	it := s.logs.Entries(context.Background())
+	defer func() { s.NoError(it.Close()) }()
+
+	var entries []LogEntry
+	for it.Next() {
+		entries = append(entries, it.Entry())
+	}
+
+	s.NoError(it.Err())
+	s.Require().Len(entries, 3)
+	s.Equal("10.0.0.2", entries[0].IP)
+	s.Equal("/a", entries[0].Path)
+	s.Equal(int64(20), entries[0].Size)
+	s.Equal("this line cannot be parsed", entries[1].Line)
+	s.Equal("", entries[1].IP)
+	s.True(entries[1].Time.Equal(entries[0].Time))
+	s.Equal("POST", entries[2].Method)
+	s.Equal(500, entries[2].Status)
+	for _, entry := range entries {
+		s.Equal(filepath.Join(iteratorDataDir, "http.log"), entry.File)
+	}
+	s.Equal([]int64{0, 71, 98}, []int64{entries[0].Offset, entries[1].Offset, entries[2].Offset})
+}
+
+func (s *iteratorSuite) Test_Entries_Cancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	it := s.logs.Entries(ctx)
+	defer func() { s.NoError(it.Close()) }()
+
+	s.True(it.Next())
+	cancel()
+
+	s.False(it.Next())
+	s.ErrorIs(it.Err(), context.Canceled)
+}
+
+func (s *iteratorSuite) Test_ForEach() {
+	var paths []string
+
+	err := s.logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+
+	s.NoError(err)
+	s.Equal([]string{"/a", "", "/b"}, paths)
+}
+
+func (s *iteratorSuite) Test_ForEach_Stop() {
+	var paths []string
+
+	err := s.logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return fmt.Errorf("found what i was looking for: %w", ErrStop)
+	})
+
+	s.NoError(err)
+	s.Equal([]string{"/a"}, paths)
+}
+
+func (s *iteratorSuite) Test_ForEach_Error() {
+	calls := 0
+
+	err := s.logs.ForEach(context.Background(), func(entry LogEntry) error {
+		calls++
+		return errors.New("something went wrong")
+	})
+
+	s.EqualError(err, "something went wrong")
+	s.Equal(1, calls)
+}
+
+func TestIterator(t *testing.T) {
+	suite.Run(t, new(iteratorSuite))
+}
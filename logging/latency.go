@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Latencies estimates the latency percentiles of the requests logged within the last N minutes,
+// overall and per path, using constant memory per path (see tdigest).
+// Only the requests logging the time taken to serve them (%D) are taken into account.
+type Latencies struct {
+	overall *tdigest
+	paths   map[string]*tdigest
+}
+
+// LatencyPercentiles are the estimated latency percentiles of a number of requests.
+type LatencyPercentiles struct {
+	// Path is the path of the requests, empty for all the requests.
+	Path  string        `json:"path,omitempty"`
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+}
+
+// NewLatencies creates empty Latencies.
+func NewLatencies() *Latencies {
+	return &Latencies{
+		overall: newTDigest(tdigestCompression),
+		paths:   make(map[string]*tdigest),
+	}
+}
+
+// Latencies reads the log entries that happened within the last N minutes and estimates their latency percentiles.
+// The latencies are returned even along with an error (e.g. ErrNoFilesInWindow), accounting for the entries read until then.
+func (logs *Logs) Latencies(ctx context.Context) (*Latencies, error) {
+	latencies := NewLatencies()
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		latencies.Add(entry)
+		return nil
+	})
+
+	return latencies, err
+}
+
+// Add accounts for the latency of a given log entry, ignoring the ones without a duration.
+func (latencies *Latencies) Add(entry LogEntry) {
+	if entry.IP == "" || entry.Duration <= 0 {
+		return
+	}
+
+	latencies.overall.add(float64(entry.Duration))
+	td, ok := latencies.paths[entry.Path]
+	if !ok {
+		td = newTDigest(tdigestCompression)
+		latencies.paths[entry.Path] = td
+	}
+	td.add(float64(entry.Duration))
+}
+
+// Result returns the latency percentiles of all the requests, followed by the ones of every path (see ByPath).
+func (latencies *Latencies) Result() interface{} {
+	return append([]LatencyPercentiles{latencies.Overall()}, latencies.ByPath(0)...)
+}
+
+// Overall returns the latency percentiles of all the requests.
+func (latencies *Latencies) Overall() LatencyPercentiles {
+	return percentiles("", latencies.overall)
+}
+
+// ByPath returns the latency percentiles of the requests of every path,
+// the slowest first (by p99), or only the n slowest ones when n is positive.
+func (latencies *Latencies) ByPath(n int) []LatencyPercentiles {
+	paths := make([]LatencyPercentiles, 0, len(latencies.paths))
+	for path, td := range latencies.paths {
+		paths = append(paths, percentiles(path, td))
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].P99 == paths[j].P99 {
+			return paths[i].Path < paths[j].Path
+		}
+		return paths[i].P99 > paths[j].P99
+	})
+	if n > 0 && n < len(paths) {
+		paths = paths[:n]
+	}
+
+	return paths
+}
+
+func percentiles(path string, td *tdigest) LatencyPercentiles {
+	return LatencyPercentiles{
+		Path:  path,
+		Count: int(td.count),
+		P50:   time.Duration(td.quantile(0.5)),
+		P90:   time.Duration(td.quantile(0.9)),
+		P99:   time.Duration(td.quantile(0.99)),
+	}
+}
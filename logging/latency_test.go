@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const latencyDataDir = "test/latency"
+
+type latencySuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *latencySuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(latencyDataDir, "http.log"), `10.0.0.1 - frank [03/Mar/2022:02:44:10 +0000] "GET /fast HTTP/1.0" 200 20 1000
+10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /fast HTTP/1.0" 200 20 3000
+10.0.0.1 - frank [03/Mar/2022:02:44:30 +0000] "GET /slow HTTP/1.1" 200 30 900000
+10.0.0.3 - frank [03/Mar/2022:02:44:40 +0000] "GET /unknown HTTP/1.1" 200 10
+this line cannot be parsed
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(latencyDataDir))
+}
+
+func (s *latencySuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(latencyDataDir)))
+}
+
+func (s *latencySuite) Test_Latencies() {
+	latencies, err := s.logs.Latencies(context.Background())
+	s.Require().NoError(err)
+
+	overall := latencies.Overall()
+	s.Equal(3, overall.Count)
+	s.Equal(3*time.Millisecond, overall.P50)
+	s.Equal(900*time.Millisecond, overall.P99)
+
+	byPath := latencies.ByPath(0)
+	s.Require().Len(byPath, 2)
+	s.Equal("/slow", byPath[0].Path)
+	s.Equal(900*time.Millisecond, byPath[0].P50)
+	s.Equal("/fast", byPath[1].Path)
+	s.Equal(2, byPath[1].Count)
+	// interpolated between both requests
+	s.Equal(2*time.Millisecond, byPath[1].P50)
+	s.Equal(3*time.Millisecond, byPath[1].P99)
+	s.Len(latencies.ByPath(1), 1)
+}
+
+func (s *latencySuite) Test_Latencies_Empty() {
+	overall := NewLatencies().Overall()
+
+	s.Equal(LatencyPercentiles{}, overall)
+}
+
+func (s *latencySuite) Test_tdigest_quantile() {
+	r := rand.New(rand.NewSource(42))
+	values := make([]float64, 100000)
+	td := newTDigest(tdigestCompression)
+	for i := range values {
+		// exponentially distributed latencies, the way they usually are
+		values[i] = r.ExpFloat64() * 100
+		td.add(values[i])
+	}
+	sort.Float64s(values)
+
+	// the error of a t-digest is bounded in terms of quantiles (ranks) rather than values,
+	// and the closer to the extremes, the smaller the error
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		rank := float64(sort.SearchFloat64s(values, td.quantile(q))) / float64(len(values))
+		s.InDelta(q, rank, 0.01*math.Sqrt(q*(1-q)), "quantile %v", q)
+	}
+	s.Equal(values[0], td.quantile(0))
+	s.Equal(values[len(values)-1], td.quantile(1))
+	s.Less(len(td.centroids), 2*tdigestCompression)
+}
+
+func TestLatency(t *testing.T) {
+	suite.Run(t, new(latencySuite))
+}
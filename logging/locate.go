@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FileRange is the byte range [From, To) of a log file holding its log lines of a time range (see Logs.Locate),
+// From being the offset of the first of them and To the offset right after the last one.
+type FileRange struct {
+	File string `json:"file"`
+	From int64  `json:"from"`
+	To   int64  `json:"to"`
+}
+
+// Locate returns the byte ranges of the log files holding their log lines that happened within a given time range
+// [from, to), in the order the files are read, without reading the log lines in between: the ranges are bounded
+// the way Print finds where to start (see LogsConfig.IndexInterval), so the Logs can serve as an index to tools
+// doing their own I/O (e.g. uploading the byte ranges). The files without any log line within the time range are left out.
+// The time window of the configuration is ignored, as are the filters, IP and PathPrefix. The files whose times
+// cannot be read are searched only when they're the first candidate, and located entirely otherwise, like Print does.
+// ErrNoFilesInWindow is returned when none of the log files was modified within the time range.
+func (logs *Logs) Locate(ctx context.Context, from, to time.Time) ([]FileRange, error) {
+	if !to.After(from) {
+		return nil, errors.New("the end of the time range must be after its start")
+	}
+	files, err := logs.files(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := -1
+	for i, fi := range files {
+		if !fi.modTime().Before(from) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, ErrNoFilesInWindow
+	}
+
+	var ranges []FileRange
+	for order, fi := range files[idx:] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, err := logs.locateFile(ctx, fi.path, from, to, order == 0)
+		if err != nil {
+			return nil, err
+		}
+		if r.To > r.From {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges, nil
+}
+
+// EstimateBytes returns the number of bytes of the log files holding their log lines within the time window of the configuration,
+// the sum of their byte ranges (see Locate), so how much a call would read is known without reading it, e.g. to refuse
+// a window too wide beforehand. The filters are ignored, so it's how much is read rather than printed. It's 0 when none
+// of the log files was modified within the window.
+func (logs *Logs) EstimateBytes(ctx context.Context) (int64, error) {
+	ranges, err := logs.Locate(ctx, logs.nowMinusT(), logs.cfg.end())
+	if errors.Is(err, ErrNoFilesInWindow) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, r := range ranges {
+		n += r.To - r.From
+	}
+	return n, nil
+}
+
+// locateFile returns the byte range of a given file holding its log lines within a given time range the way Locate does,
+// an empty one when there are none. The file is searched for the start of the range even if its times cannot be read when told so.
+func (logs *Logs) locateFile(ctx context.Context, path string, from, to time.Time, search bool) (FileRange, error) {
+	start := time.Now()
+	file, err := logs.open(path)
+	if errors.Is(err, errVanished) {
+		return FileRange{File: path}, nil
+	}
+	if err != nil {
+		return FileRange{}, err
+	}
+	defer func() { _ = file.Close() }()
+
+	r := FileRange{File: path}
+	offset, by, err := logs.findOffset(ctx, file, from, search)
+	if err != nil || offset < 0 {
+		return r, err
+	}
+	end, err := file.end()
+	if err != nil {
+		return r, err
+	}
+	r.From, r.To = offset, end
+	if by != "unreadable times" {
+		// the log lines from the one at or after the end of the time range onwards are left out
+		if offset, _, err = logs.findOffset(ctx, file, to, true); err != nil {
+			return r, err
+		}
+		if offset >= 0 {
+			r.To = offset
+		}
+	}
+	logs.info("file located", "file", path, "from", r.From, "to", r.To, "by", by, "took", time.Since(start))
+	return r, nil
+}
@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const locateDataDir = "test/locate"
+
+// locateLineLength is the length of the log lines of the tests, line ending included.
+const locateLineLength = 67
+
+type locateSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *locateSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	files := []string{
+		`10.0.0.1 - - [03/Mar/2022:01:00:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:01:10:00 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:01:20:00 +0000] "GET /c HTTP/1.1" 200 10
+`,
+		`10.0.0.1 - - [03/Mar/2022:02:00:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:10:00 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:02:20:00 +0000] "GET /c HTTP/1.1" 200 10
+10.0.0.4 - - [03/Mar/2022:02:30:00 +0000] "GET /d HTTP/1.1" 200 10
+10.0.0.5 - - [03/Mar/2022:02:4`,
+	}
+	for i, logs := range files {
+		name := filepath.Join(locateDataDir, []string{"access.log.1", "access.log"}[i])
+		modTime := []time.Time{t.Add(-85 * time.Minute), t.Add(-time.Minute)}[i]
+		writeLogFile(s.T(), name, logs, modTime)
+	}
+}
+
+func (s *locateSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(locateDataDir)))
+}
+
+func (s *locateSuite) Test_Locate() {
+	rotated, current := filepath.Join(locateDataDir, "access.log.1"), filepath.Join(locateDataDir, "access.log")
+	for _, test := range []struct {
+		name     string
+		from, to time.Duration
+		expected []FileRange
+	}{
+		{
+			name: "Within", from: -95 * time.Minute, to: -30 * time.Minute,
+			expected: []FileRange{
+				{File: rotated, From: locateLineLength, To: 3 * locateLineLength},
+				{File: current, From: 0, To: 2 * locateLineLength},
+			},
+		},
+		{
+			name: "Boundaries", from: -85 * time.Minute, to: -25 * time.Minute,
+			expected: []FileRange{
+				{File: rotated, From: 2 * locateLineLength, To: 3 * locateLineLength},
+				{File: current, From: 0, To: 2 * locateLineLength},
+			},
+		},
+		{
+			// the last line, still being written, is left out
+			name: "End", from: -20 * time.Minute, to: time.Hour,
+			expected: []FileRange{{File: current, From: 3 * locateLineLength, To: 4 * locateLineLength}},
+		},
+		{
+			name: "Between", from: -80 * time.Minute, to: -50 * time.Minute,
+		},
+	} {
+		s.Run(test.name, func() {
+			logs, err := New(WithDirectory(locateDataDir), WithWindow(time.Minute))
+			s.Require().NoError(err)
+
+			ranges, err := logs.Locate(context.Background(), s.testTime.Add(test.from), s.testTime.Add(test.to))
+
+			s.Require().NoError(err)
+			s.Equal(test.expected, ranges)
+		})
+	}
+}
+
+func (s *locateSuite) Test_Locate_Indexed() {
+	logs, err := New(WithDirectory(locateDataDir), WithWindow(time.Minute), WithIndex(time.Minute))
+	s.Require().NoError(err)
+	defer func() {
+		for _, name := range []string{"access.log.1", "access.log"} {
+			_ = os.Remove(filepath.Join(locateDataDir, name+IndexSuffix))
+		}
+	}()
+
+	ranges, err := logs.Locate(context.Background(), s.testTime.Add(-40*time.Minute), s.testTime.Add(-20*time.Minute))
+
+	s.Require().NoError(err)
+	s.Equal([]FileRange{{File: filepath.Join(locateDataDir, "access.log"), From: locateLineLength, To: 3 * locateLineLength}}, ranges)
+}
+
+func (s *locateSuite) Test_Locate_NoFilesInWindow() {
+	logs, err := New(WithDirectory(locateDataDir), WithWindow(time.Minute))
+	s.Require().NoError(err)
+
+	_, err = logs.Locate(context.Background(), s.testTime, s.testTime.Add(time.Hour))
+
+	s.ErrorIs(err, ErrNoFilesInWindow)
+}
+
+func (s *locateSuite) Test_Locate_InvalidRange() {
+	logs, err := New(WithDirectory(locateDataDir), WithWindow(time.Minute))
+	s.Require().NoError(err)
+
+	_, err = logs.Locate(context.Background(), s.testTime, s.testTime)
+
+	s.EqualError(err, "the end of the time range must be after its start")
+}
+
+func (s *locateSuite) Test_EstimateBytes() {
+	for _, test := range []struct {
+		name     string
+		window   time.Duration
+		expected int64
+	}{
+		{name: "Current", window: 30 * time.Minute, expected: 2 * locateLineLength},
+		{name: "Rotated", window: 2 * time.Hour, expected: 7 * locateLineLength},
+		{name: "No Files", window: time.Minute},
+	} {
+		s.Run(test.name, func() {
+			logs, err := New(WithDirectory(locateDataDir), WithWindow(test.window), WithEnd(s.testTime))
+			s.Require().NoError(err)
+
+			n, err := logs.EstimateBytes(context.Background())
+
+			s.Require().NoError(err)
+			s.Equal(test.expected, n)
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+	suite.Run(t, new(locateSuite))
+}
@@ -2,101 +2,607 @@ package logging
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"io"
-	"io/ioutil"
-	"os"
+	"io/fs"
+	"net"
 	"path"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 // LogsConfig represents the configuration Logs.
 type LogsConfig struct {
-	Directory    string
+	// Directory is the directory of the log files, unless reading the ones of several Hosts.
+	Directory string
+	// FS is the file system the log files are read from instead of the one of the operating system, if any, Directory
+	// (e.g. ".") and the directories of the Hosts being paths within it, e.g. the files uploaded to a browser
+	// (see cmd/log-reader-wasm) or embedded in a binary. Its files must be readable at offsets and seekable (see LogFile).
+	// It cannot be followed, backfilled, indexed (see IndexInterval) nor copied (see CopyDir), which write or watch
+	// the files of the operating system, and it is never memory mapped (see MemoryMap).
+	FS           fs.FS
 	LastNMinutes int
+	// Window is the time range to look for logs in, taking precedence
+	// over LastNMinutes when set, for windows that are not whole minutes.
+	Window time.Duration
+	// End is the end of the time range to look for logs in, defaulting to now,
+	// e.g. to look at the same window a week ago. The logs are expected in order,
+	// so the logs are streamed until the first one that happened at or after End.
+	End time.Time
+	// Clock returns the current time the time range ends at unless End is set, time.Now by default,
+	// e.g. the fake clock of a test (see loggingtest.Clock).
+	Clock func() time.Time
+	// Format is the format of the log lines (FormatCommon, FormatCombined, FormatVHostCombined, FormatCombinedCache,
+	// FormatCombinedXFF, FormatCombinedRequestID, FormatSSLRequest, FormatCRI or FormatError), defaults to FormatCommon.
+	// With FormatCRI the directory is walked recursively, so a kubelet
+	// pod log directory (/var/log/pods/<namespace>_<pod>_<uid>) can be used as is.
+	Format Format
+	// Formats are the formats of the log files which names match their patterns, the first matching one winning,
+	// e.g. for directories of both access and error logs (access*: combined, error*: error). The files matching
+	// none of them are of the Format. Such files usually cover the same time range, so they're read along with Merge.
+	Formats []FormatPattern
+	// FollowSymlinks makes symlinked log files (e.g. access.log -> access.log.2022-03-03)
+	// part of the lookup, otherwise they are ignored. Either way, files pointing
+	// to the same underlying file (symlinks or hardlinks) are only read once.
+	FollowSymlinks bool
+	// Sharded reads the log files of the subdirectories of Directory dated by year, month, day and optionally hour
+	// (e.g. /logs/2024/05/12/access.log) along with the ones of Directory itself, only descending into the subdirectories
+	// which date is within a day of the time range, so the other days are never listed. The subdirectories which names
+	// are not dates are left out.
+	Sharded bool
+	// FilenameTimePattern orders and selects the log files which names embed the time of their logs using that time
+	// instead of their modified time, e.g. access-%Y%m%d-%H.log for the hourly files of rotatelogs (access-20240512-10.log),
+	// the times being in UTC (see TimeZone). Every file is taken as covering the period of the finest directive (an hour for %H),
+	// so the ones starting after the time range are left out without being read. The supported directives are
+	// %Y, %m, %d, %H, %M and %S (%% for a percent sign), the year being required. The files not matching it,
+	// if any, keep being selected by their modified time (see OrderByContent).
+	FilenameTimePattern string
+	// TimeZone is the time zone of the log times which don't give theirs (e.g. FormatError, logged in the local time zone
+	// of the server) and of the times embedded in the names of the log files (see FilenameTimePattern), UTC when nil.
+	// The log times giving their offset (e.g. +0200 in FormatCommon) are read as logged whatever it is.
+	TimeZone *time.Location
+	// CopyDir, when not empty, is an empty (or missing) directory the log files modified within the time range are copied
+	// into by New, with their modified times, to read the copies instead, e.g. for forensic workflows: the sources are
+	// read once, then never looked at again, and the index files (see IndexInterval) are written next to the copies.
+	// The log files themselves are never written either way, and are read without updating their access times
+	// where allowed (O_NOATIME on Linux). The compressed rotations (see Backfill) are not copied.
+	CopyDir string
+	// OrderByContent orders and selects the log files using the times of their
+	// first and last log lines instead of their modified time, which is unreliable
+	// for files that have been copied around (e.g. using rsync or cp).
+	// Files that cannot be parsed still fall back to their modified time.
+	OrderByContent bool
+	// Hosts are the directories of the log files of several hosts (e.g. the servers of a small cluster without
+	// centralized logging) read instead of Directory, their logs being merged by time (Merge is implied),
+	// and every log entry being labelled with its host (see LogEntry.Host). The times of the logs of the hosts
+	// with a skewed clock are shifted by its offset (see Host.Offset).
+	Hosts []Host
+	// Merge interleaves the logs of all the files by their times, instead of
+	// streaming one file after another, which is needed when files cover
+	// overlapping time ranges (e.g. one file per virtual host or server).
+	Merge bool
+	// DeterministicOrder orders the log lines with the same time of different files (see Merge) by the paths of
+	// their files, then by their order within their file, and the files with the same modified time by their paths,
+	// instead of the order the files were modified in, so repeated runs print the same lines in the same order
+	// (e.g. to diff or checksum them) even though the files are written to in between.
+	DeterministicOrder bool
+	// Tolerance is the number of bytes to rewind before the first log found within
+	// the last N minutes, checking the time of every log in between. It keeps logs
+	// written slightly out of order (e.g. by multiple workers) from being dropped.
+	Tolerance int64
+	// IndexInterval makes the files be searched using sidecar index files (see IndexSuffix) storing the offset
+	// of their first log line of every interval of log time (e.g. 10s), built on first use and reused by the next runs,
+	// instead of binary searching them every time. The files are binary searched when 0.
+	IndexInterval time.Duration
+	// IndexBloom makes the index files (see IndexInterval) also store Bloom filters of the IPs and path prefixes
+	// of the log lines of every interval, so the intervals without any log line of the IP or PathPrefix looked for
+	// are skipped without being read, which speeds up heavily filtered lookups of large files.
+	IndexBloom bool
+	// Workers is the number of files parsed in parallel, each file being read ahead by its own goroutine
+	// while the logs are still streamed in order, e.g. to make the most of SSDs with many rotated files.
+	// The files are read one by one when 0 or 1, DefaultWorkers being a sane number of them otherwise.
+	// Filters and Bots must be safe for concurrent use then.
+	Workers int
+	// ReadAheadBatch is the number of log entries a file read ahead (see Workers) parses at once while holding a worker,
+	// 512 when 0, and ReadAheadDepth the number of its batches parsed before they are read, 1 when 0: deeper queues
+	// smooth out the reading of storage of high latency (e.g. network mounts) at the cost of memory.
+	ReadAheadBatch int
+	ReadAheadDepth int
+	// ReadBufferSize is the size of the buffer every log file is streamed through, picked by the size of the file
+	// when 0: 4KB, or 64KB for the files of 64MB or more, sparing the syscalls of reading them sequentially.
+	// The searches (see IndexTime) read the few lines they need through small buffers all the same.
+	ReadBufferSize int
+	// WriteBufferSize is the size of the buffer Print writes the log lines to, flushed once full, 64KB when 0.
+	WriteBufferSize int
+	// MaxOpenFiles is the maximum number of log files kept open at once by every call (and by Follow), e.g. so merging
+	// or following thousands of rotated files doesn't hit the limit of open files of the process (ulimit -n).
+	// When merged (see Merge), the files of the least recently read lines are closed, then opened again once read
+	// again, failing when replaced in between (e.g. rotated); when followed, the least recently modified files
+	// read until their end are, then opened again once grown. The files read in parallel (see Workers), limited by
+	// Workers already, are not counted. The open files are not limited when 0.
+	MaxOpenFiles int
+	// SkipVanished makes the log files removed between the listing of the directory and their opening (e.g. by an aggressive
+	// logrotate) be skipped, the remaining ones being read all the same: they're reported to the Logger and counted
+	// (see MetricsSnapshot.FilesVanished). The calls fail with the error of the first missing file otherwise.
+	SkipVanished bool
+	// MemoryMap makes the files be read through read-only memory mappings (see MapFile), both when searched and streamed,
+	// which spares the syscalls of seeking and reading large files. The files are read as usual where it isn't supported.
+	// A file truncated while mapped fails the reading with an error.
+	MemoryMap bool
+	// Refresh makes every call (Print, ForEach, Entries, ...) look at the log files created, rotated or truncated
+	// since New, instead of the files listed by New, e.g. for Logs living as long as a server. The directory is
+	// listed again only once modified, the known files being stat'ed otherwise (see Logs.files).
+	Refresh bool
+	// IP and PathPrefix keep the log entries of the given IP and the ones which path starts with the given prefix only,
+	// when not empty. Unlike Filters, they can skip whole intervals of the files without reading them (see IndexBloom).
+	IP         string
+	PathPrefix string
+	// XFF makes the IP of the log entries (see LogEntry.IP) be their client IP read from their X-Forwarded-For header
+	// (see ClientIP and FormatCombinedXFF) instead of the IP of the proxy in front of the server, before they're looked at
+	// by IP, the filters and all the reports. The Bloom filters of the index files (see IndexBloom) are then left unused
+	// by IP, as they hold the IPs of the proxies. The log entries are left as parsed when empty.
+	XFF XFF
+	// TrustedProxies are the networks of the proxies which X-Forwarded-For headers are believed (see ClientIP),
+	// e.g. the load balancers, required by XFFLastTrusted.
+	TrustedProxies []*net.IPNet
+	// MaxReadRate is the maximum number of bytes read from the log files per second, shared by all the files
+	// and calls of the Logs, e.g. so a large extract on a busy web server doesn't starve it of disk I/O.
+	// The reads are not limited when 0.
+	MaxReadRate int64
+	// MaxBytesRead is the maximum number of bytes read from the log files by all the calls of the Logs, the ones of the
+	// binary searches included, after which the calls fail with ErrBudgetExceeded, e.g. so a single expensive query
+	// (a week of logs, a regular expression filter...) can't take down a host serving live traffic. Follow isn't limited,
+	// its files being read endlessly. The bytes read are not limited when 0.
+	MaxBytesRead int64
+	// MaxTopValues is the maximum number of distinct values of every field counted by Top, so ranking fields of
+	// unbounded cardinality (e.g. the IPs or the paths of a month of logs) takes constant memory, the most frequent
+	// values being counted approximately once there are more (see NewBoundedTop). Every value is counted when 0.
+	MaxTopValues int
+	// PollInterval is how often Follow checks the log files for new lines and the directory for new, renamed or removed
+	// files, stat-ing them rather than waiting for file system events, which don't fire reliably on NFS/SMB mounts.
+	// A longer interval spares the round trips to a network file system. It's one second when 0.
+	PollInterval time.Duration
+	// Progress is called with the progress of every call (Print, ForEach, Entries, ...) every megabyte processed
+	// and once done, e.g. to render the progress of multi-GB extracts. It is never called concurrently.
+	Progress func(Progress)
+	// Logger receives diagnostic messages (see Logger), e.g. the files selected and the offsets found,
+	// to understand why nothing is printed. Nothing is reported when nil.
+	Logger Logger
+	// Tracer starts the spans of the scan of the directory, the lookup of the offset inside every file and the reading
+	// of the files (see Tracer), e.g. to see where the time is spent within a larger service. Nothing is traced when nil.
+	Tracer Tracer
+	// Metrics counts the work of the reader itself (files scanned, bytes read, ...), if any, see Metrics
+	Metrics *Metrics
+	// Errors counts the log lines not matching the log format that are read, per log file and per type, if any
+	// (see ErrorSummary), which requires looking at every log line.
+	Errors *ErrorSummary
+	// Duplicates detects the log lines duplicated across files by a misconfigured rotation (e.g. copied from access.log
+	// into access.log.1) while streamed, the same line of the same host being read from another file within
+	// DuplicateWindow of log time, and either reports them (DuplicatesReport) or leaves them out (DuplicatesSuppress).
+	// Identical lines of vhost files of the common format, which has no virtual host, are taken for duplicates too.
+	// The log lines are taken as they are when empty.
+	Duplicates Duplicates
+	// DuplicateWindow is how far apart in log time a log line and its duplicate are looked for, see Duplicates.
+	// It's DefaultDuplicateWindow when 0.
+	DuplicateWindow time.Duration
+	// AssertSorted guarantees the log entries streamed (see Logs.Entries) are in non-decreasing time order,
+	// for the consumers requiring it: the entries slightly out of order (e.g. of merged files) are put back in order
+	// by holding back ReorderBuffer of them, and the streaming fails with an *UnsortedError on the first entry
+	// still older than one streamed already.
+	AssertSorted bool
+	// ReorderBuffer is the number of log entries held back to put them in order, see AssertSorted.
+	// The entries are only verified when 0.
+	ReorderBuffer int
+	// Checkpoints, if any, resume the shipping of the logs after the log lines shipped by the previous runs
+	// (see Checkpoints), e.g. the ones of the window shipped already, or the ones written since when following the logs.
+	Checkpoints *Checkpoints
+	// Middlewares transform the log entries in turn once parsed and classified (see Bots), before they're filtered
+	// (see Filters), e.g. to enrich them, rewrite their paths or drop them. Unlike Filters, they're applied after IP
+	// and PathPrefix, which look at the log entries as parsed. They must be safe for concurrent use along with Workers.
+	Middlewares []Middleware
+	// Filters are the filters every log entry has to match in order to be streamed.
+	Filters []Filter
+	// Bots classifies the log entries as bots or humans by their user agents (see LogEntry.Bot)
+	// before they're filtered, e.g. using ExcludeBots. No entry is classified when nil.
+	Bots *BotClassifier
+}
+
+// window returns the time range to look for logs in.
+func (cfg LogsConfig) window() time.Duration {
+	if cfg.Window != 0 {
+		return cfg.Window
+	}
+	return time.Duration(cfg.LastNMinutes) * time.Minute
+}
+
+// format returns the format of the log file of a given path, see LogsConfig.Formats.
+func (cfg LogsConfig) format(p string) Format {
+	name := filepath.Base(p)
+	for _, pattern := range cfg.Formats {
+		if ok, _ := path.Match(pattern.Pattern, name); ok {
+			return pattern.Format
+		}
+	}
+	return cfg.Format
+}
+
+// end returns the end of the time range to look for logs in.
+func (cfg LogsConfig) end() time.Time {
+	if !cfg.End.IsZero() {
+		return cfg.End.UTC()
+	}
+	if cfg.Clock != nil {
+		return cfg.Clock().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// until returns the time the logs are streamed until, the end of the time range when it's bounded by End or a Clock,
+// zero otherwise: the logs are then streamed until the last one, however late it was written.
+func (cfg LogsConfig) until() time.Time {
+	if cfg.End.IsZero() && cfg.Clock == nil {
+		return time.Time{}
+	}
+	return cfg.end()
 }
 
 // NewLogs creates a new instance of Logs containing all the info
 // about the log files to look for within a given time range.
+// It's the equivalent of New(WithConfig(cfg)).
 func NewLogs(cfg LogsConfig) (*Logs, error) {
-	files, err := ioutil.ReadDir(cfg.Directory)
-	if err != nil {
+	return New(WithConfig(cfg))
+}
+
+// New creates a new instance of Logs configured by the given options,
+// containing all the info about the log files to look for within a given time range.
+// The configuration is validated upfront, returning a *ConfigError when invalid.
+func New(opts ...Option) (*Logs, error) {
+	var cfg LogsConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
+	if len(cfg.Hosts) > 0 {
+		// the files of the hosts cover the same time range
+		cfg.Merge = true
+	}
+	if cfg.CopyDir != "" {
+		copied, err := cfg.copyFiles()
+		if err != nil {
+			return nil, err
+		}
+		cfg = copied
+	}
 
-	filesInfo := make([]os.FileInfo, 0, len(files))
-	for _, fi := range files {
-		if fi.IsDir() {
-			continue
+	logs := &Logs{
+		cfg:          cfg,
+		hostDirs:     newHostDirs(cfg.Hosts),
+		pollInterval: cfg.pollInterval(),
+		nowMinusT: func() time.Time {
+			return cfg.end().Add(-cfg.window())
+		},
+	}
+	if cfg.MaxReadRate > 0 {
+		logs.limiter = newReadLimiter(cfg.MaxReadRate)
+	}
+	if cfg.MaxBytesRead > 0 {
+		logs.budget = &readBudget{max: cfg.MaxBytesRead}
+	}
+	if cfg.FilenameTimePattern != "" {
+		// validated already
+		logs.nameTime, _ = parseNameTimePattern(cfg.FilenameTimePattern)
+		logs.nameTime.loc = cfg.TimeZone
+	}
+	if err := logs.estimateOffsets(); err != nil {
+		return nil, err
+	}
+	if err := logs.list(context.Background()); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// list lists the log files of the directory, keeping the modified time of the directory
+// so the files are only listed again once it's modified (see Logs.files). The listing is traced
+// as a child of the span of a given context, if any (see LogsConfig.Tracer).
+func (logs *Logs) list(ctx context.Context) (err error) {
+	_, span := logs.startSpan(ctx, SpanScan)
+	defer func() { span.End(err, "listed", len(logs.listed), "files", len(logs.filesInfo)) }()
+	start := time.Now()
+	if len(logs.cfg.Hosts) > 0 {
+		listed, err := listHostsFiles(logs.cfg)
+		if err != nil {
+			return err
+		}
+		logs.listed = listed
+		if err := logs.sortFiles(); err != nil {
+			return err
+		}
+		logs.info("files listed", "hosts", len(logs.cfg.Hosts), "listed", len(logs.listed), "files", len(logs.filesInfo), "took", time.Since(start))
+		return nil
+	}
+
+	// the directory is stat'ed first, so it's listed again when modified while being listed
+	dir, statErr := logs.cfg.fileSystem().Stat(logs.cfg.Directory)
+	listed, err := listFiles(logs.cfg)
+	if err != nil {
+		return err
+	}
+	if statErr != nil {
+		return statErr
+	}
+	logs.listed, logs.dirModTime = listed, dir.ModTime()
+	if err := logs.sortFiles(); err != nil {
+		return err
+	}
+	logs.info("files listed", "directory", logs.cfg.Directory, "listed", len(logs.listed), "files", len(logs.filesInfo), "took", time.Since(start))
+	return nil
+}
+
+// sortFiles picks the files in which to look for logs among the listed files.
+func (logs *Logs) sortFiles() error {
+	logs.nameTimes(logs.listed)
+	if logs.cfg.OrderByContent {
+		if err := logs.peekTimes(logs.listed); err != nil {
+			return err
 		}
-		filesInfo = append(filesInfo, fi)
 	}
+	filesInfo := logs.beforeEnd(nonEmpty(append([]logFile(nil), logs.listed...)))
 	// make sure to sort all the log files by the modified time
 	// instead of relying on alphanumerical sorting
 	sort.Slice(filesInfo, func(i, j int) bool {
-		return filesInfo[i].ModTime().Sub(filesInfo[j].ModTime()) < 0
+		if d := filesInfo[i].modTime().Sub(filesInfo[j].modTime()); d != 0 || !logs.cfg.DeterministicOrder {
+			return d < 0
+		}
+		return filesInfo[i].path < filesInfo[j].path
 	})
+	logs.filesInfo = filesInfo
+	return nil
+}
 
-	logs := &Logs{
-		cfg:       cfg,
-		filesInfo: filesInfo,
-		nowMinusT: func() time.Time {
-			return time.Now().UTC().Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
-		},
+// files returns the files in which to look for logs, sorted by their modified time (see LogsConfig.Refresh).
+// When refreshed, the directory is listed again only once modified (e.g. a file was created or rotated by renaming),
+// the known files being stat'ed otherwise, so the ones appended to or truncated (e.g. by copytruncate) are up to date.
+// The subdirectories of the CRI layout, the dated ones (see LogsConfig.Sharded) and the directories of the hosts
+// are not watched that way, so they are listed again every time.
+func (logs *Logs) files(ctx context.Context) ([]logFile, error) {
+	if !logs.cfg.Refresh {
+		return logs.filesInfo, nil
 	}
-	return logs, nil
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+
+	if len(logs.cfg.Hosts) > 0 {
+		if err := logs.list(ctx); err != nil {
+			return nil, err
+		}
+		return logs.filesInfo, nil
+	}
+
+	fsys := logs.cfg.fileSystem()
+	dir, err := fsys.Stat(logs.cfg.Directory)
+	if err != nil {
+		return nil, err
+	}
+	if logs.cfg.Format == FormatCRI || logs.cfg.Sharded || !dir.ModTime().Equal(logs.dirModTime) {
+		if err := logs.list(ctx); err != nil {
+			return nil, err
+		}
+		return logs.filesInfo, nil
+	}
+
+	listed := make([]logFile, len(logs.listed))
+	for i, fi := range logs.listed {
+		info, err := fsys.Stat(fi.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// gone without the directory being modified, e.g. within the same second
+			if err := logs.list(ctx); err != nil {
+				return nil, err
+			}
+			return logs.filesInfo, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		listed[i] = logFile{FileInfo: info, path: fi.path}
+		if info.Size() == fi.Size() && info.ModTime().Equal(fi.ModTime()) {
+			// the times of the logs of an unchanged file are still the same
+			listed[i].first, listed[i].last = fi.first, fi.last
+		}
+	}
+	logs.listed = listed
+	if err := logs.sortFiles(); err != nil {
+		return nil, err
+	}
+	return logs.filesInfo, nil
 }
 
 // Logs represents the application Logs type
 // containing information about the logs files from a given directory
 // that were written in the last N minutes.
+// Logs is safe for concurrent use (e.g. by the handlers of a server): every call (Print, ForEach, Entries, ...)
+// opens the log files it reads on its own. The log files are listed once by New though, unless refreshed
+// (see LogsConfig.Refresh), so the files created afterwards take a new Logs otherwise.
 type Logs struct {
-	cfg       LogsConfig
-	filesInfo []os.FileInfo
+	cfg LogsConfig
+	// filesInfo are the files in which to look for logs, among the listed files of the directory
+	// last modified at dirModTime, which are refreshed under mu when configured so (see Logs.files)
+	filesInfo  []logFile
+	listed     []logFile
+	dirModTime time.Time
+	// hostDirs are the directories of the hosts labelling the log entries, if any (see LogsConfig.Hosts)
+	hostDirs  []hostDir
+	mu        sync.Mutex
 	nowMinusT func() time.Time
+	// pollInterval is how often Follow checks for new logs
+	pollInterval time.Duration
+	// limiter limits the bytes read by all the calls, if any (see LogsConfig.MaxReadRate)
+	limiter *readLimiter
+	// budget fails the calls once they read too many bytes altogether, if any (see LogsConfig.MaxBytesRead)
+	budget *readBudget
+	// nameTime gives the times of the log files from their names, if any (see LogsConfig.FilenameTimePattern)
+	nameTime *nameTimePattern
 }
 
+// printBufferSize is the default size of the buffer Print writes the log lines to, which is flushed once full
+// and once done, so the log lines are written by large chunks instead of one by one.
+const printBufferSize = 64 << 10
+
 // Print reads the log files using the given Logs configuration
-// and streams them to a given writer.
-func (logs *Logs) Print(w io.Writer) error {
-	idx := logs.index()
-	if idx == -1 {
-		return nil
+// and streams them to a given writer, until done or the context is cancelled.
+// ErrNoFilesInWindow is returned when none of the log files was modified within the last N minutes.
+// When no log line has to be looked at (see Logs.copyable), the lines within the last N minutes
+// are copied file by file as byte ranges, letting the system copy them (e.g. sendfile) where it can.
+func (logs *Logs) Print(ctx context.Context, w io.Writer) error {
+	if logs.copyable() {
+		return logs.copy(ctx, w)
 	}
 
-	file, err := os.Open(path.Join(logs.cfg.Directory, logs.filesInfo[idx].Name()))
-	if err != nil {
+	it := logs.Entries(ctx)
+	defer func() { _ = it.Close() }()
+
+	bw := bufio.NewWriterSize(w, logs.cfg.writeBufferSize())
+	for it.Next() {
+		if _, err := bw.WriteString(it.Entry().Line); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
 		return err
 	}
 
-	offset, err := NewFile(file).IndexTime(logs.nowMinusT())
+	return it.Err()
+}
+
+// skippable returns the byte ranges of a given file without any log line of the IP or path prefix looked for,
+// according to the Bloom filters of its index (see LogsConfig.IndexBloom), built on first use.
+func (logs *Logs) skippable(ctx context.Context, file File) ([]byteRange, error) {
+	pathKey := bloomPathKey(logs.cfg.PathPrefix)
+	// the Bloom filters hold the IPs as parsed, not the client IPs read from the X-Forwarded-For headers
+	ip := logs.cfg.IP
+	if logs.cfg.XFF != "" {
+		ip = ""
+	}
+	if !logs.cfg.IndexBloom || logs.cfg.IndexInterval <= 0 || (ip == "" && pathKey == "") {
+		return nil, nil
+	}
+	end, err := file.end()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	idx, err := file.loadIndex(ctx, logs.cfg.IndexInterval, end, true)
+	if err != nil {
+		return nil, err
 	}
+	return idx.skippable(ip, pathKey), nil
+}
 
-	if offset >= 0 {
-		_, err = logs.streamFile(file, offset, w)
-		if err != nil {
-			return err
+// offset returns the offset of the first log inside a file that happened within the last N minutes,
+// or -1 if there's none. The times of the first and last logs of the file are checked beforehand,
+// so files entirely outside the time range are skipped and files entirely inside it are not searched.
+// Files whose times cannot be read are searched only when they're the first candidate (search)
+// and streamed entirely otherwise, since their modified time says they're within the time range.
+// Blank files are always skipped.
+func (logs *Logs) offset(ctx context.Context, file File, search bool) (int64, error) {
+	start := time.Now()
+	ctx, span := logs.startSpan(ctx, SpanIndex)
+	offset, by, err := logs.findOffset(ctx, file, logs.nowMinusT(), search)
+	span.End(err, "file", file.Name(), "offset", offset, "by", by)
+	if err == nil {
+		logs.info("offset found", "file", file.Name(), "offset", offset, "by", by, "took", time.Since(start))
+	}
+	return offset, err
+}
+
+// findOffset returns the offset of the first log inside a file that happened at or after a given lookup time
+// the way offset does, along with how it was found.
+func (logs *Logs) findOffset(ctx context.Context, file File, lookupTime time.Time, search bool) (int64, string, error) {
+	first, last, err := file.TimeRange()
+	if err != nil {
+		blank, blankErr := file.blank()
+		if blankErr != nil {
+			return -1, "", blankErr
+		}
+		if blank {
+			return -1, "blank file", nil
 		}
 	}
 
-	// means we're reading the last file which has no fresh logs
-	// so there are no other files left to stream => return.
-	if idx+1 >= len(logs.filesInfo) || logs.nowMinusT().Sub(logs.filesInfo[idx+1].ModTime()) > 0 {
-		return nil
+	var offset int64
+	switch {
+	case err != nil && !search:
+		return 0, "unreadable times", nil
+	case err == nil && last.Before(lookupTime):
+		return -1, "last log before window", nil
+	case err == nil && !first.Before(lookupTime):
+		return 0, "first log within window", nil
+	case logs.cfg.IndexInterval > 0:
+		offset, err = file.indexedTime(ctx, lookupTime, logs.cfg.IndexInterval, logs.cfg.IndexBloom)
+		return offset, "index", err
+	default:
+		offset, err = file.IndexTime(ctx, lookupTime)
+		return offset, "binary search", err
+	}
+}
+
+// boundaryScanSize is the number of bytes from the offset of the first log within the last N minutes whose log lines
+// are checked one by one, leaving out the ones that happened before the lookup time: logs written slightly out of order
+// (e.g. by multiple workers, or logged to the second when the lookup time isn't) can follow the first log found.
+const boundaryScanSize = 64 << 10
+
+// boundaryOutOfOrder checks whether any log line within boundaryScanSize bytes from a given offset (and before a given end)
+// of a file happened before the lookup time, in which case the lines have to be checked one by one.
+func (logs *Logs) boundaryOutOfOrder(ctx context.Context, file File, offset, end int64) (bool, error) {
+	if end > offset+boundaryScanSize {
+		end = offset + boundaryScanSize
+	}
+	lookupTime := logs.nowMinusT()
+	reader := getReader(contextReader{ctx: ctx, r: io.NewSectionReader(file, offset, end-offset)})
+	defer putReader(reader)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if line == "" {
+			return false, nil
+		}
+		if logTime, err := file.parseLogTime(strings.TrimSpace(line)); err == nil && logTime.Before(lookupTime) {
+			return true, nil
+		}
+	}
+}
+
+// rewind returns the offset of the line found Tolerance bytes before the given offset.
+// Logs are not always written in order (e.g. by buffered or multiple workers), so some
+// logs within the last N minutes might sit right before the offset found by the binary search.
+func (logs *Logs) rewind(file File, offset int64) (int64, error) {
+	if logs.cfg.Tolerance <= 0 || offset == 0 {
+		return offset, nil
 	}
 
-	rest := logs.filesInfo[idx+1 : len(logs.filesInfo)]
-	return logs.streamFiles(rest, w)
+	start := offset - logs.cfg.Tolerance
+	if start < 0 {
+		start = 0
+	}
+	return file.lineStart(start)
 }
 
 // index returns the index (offset) of the first file that contains logs
 // that have happened within the last N minutes or -1 if no file contains any fresh logs.
-func (logs *Logs) index() int {
+func (logs *Logs) index(files []logFile) int {
 	idx := -1
-	for i, fi := range logs.filesInfo {
-		if logs.nowMinusT().Sub(fi.ModTime()) <= 0 {
+	for i, fi := range files {
+		if logs.nowMinusT().Sub(fi.modTime()) <= 0 {
 			idx = i
 			break
 		}
@@ -105,35 +611,16 @@ func (logs *Logs) index() int {
 	return idx
 }
 
-// streamFiles reads from a given list of files and writes to a given writer using a given seek offset.
-// Because we need to preserve the order of the logs, and we want to also immediately stream to
-// a given writer, we cannot use go routines. In a different scenario where order is not important
-// that can of course be very useful.
-func (logs *Logs) streamFiles(files []os.FileInfo, w io.Writer) error {
-	for _, fi := range files {
-		file, err := os.Open(path.Join(logs.cfg.Directory, fi.Name()))
-		if err != nil {
-			return err
-		}
-
-		if _, err := logs.streamFile(file, -1, w); err != nil {
-			return err
-		}
-	}
-
-	return nil
+// contextReader wraps a reader, failing every read once the context is done,
+// so long-running reads of huge files stop promptly when cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
 }
 
-// stream outputs the contents of a file with a given seek offset to a given writer.
-func (logs *Logs) streamFile(file *os.File, offset int64, w io.Writer) (int64, error) {
-	defer func() { _ = file.Close() }()
-
-	if offset >= 0 {
-		_, err := file.Seek(offset, io.SeekStart)
-		if err != nil {
-			return 0, err
-		}
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
 	}
-
-	return bufio.NewReader(file).WriteTo(w)
+	return cr.r.Read(p)
 }
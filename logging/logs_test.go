@@ -2,13 +2,16 @@ package logging
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
-	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -18,7 +21,7 @@ type logsSuite struct {
 }
 
 func (s *logsSuite) SetupSuite() {
-	s.Require().NoError(os.RemoveAll(path.Dir(testDataDir)))
+	s.Require().NoError(os.RemoveAll(filepath.Dir(testDataDir)))
 	s.Require().NoError(os.MkdirAll(testDataDir, 0777))
 
 	// generate a few log files with some dummy logs for testing
@@ -39,14 +42,14 @@ func (s *logsSuite) SetupSuite() {
 		)
 
 		s.createLogFile(testDataDir, fmt.Sprintf("http-%d.log", i+1), logs)
-		err = os.Chtimes(path.Join(testDataDir, fmt.Sprintf("http-%d.log", i+1)), now, now)
+		err = os.Chtimes(filepath.Join(testDataDir, fmt.Sprintf("http-%d.log", i+1)), now, now)
 		s.Require().NoError(err)
 		now = now.Add(time.Duration(numOfLogs)*20*time.Second + 20*time.Second)
 	}
 }
 
 func (s *logsSuite) TearDownSuite() {
-	s.Require().NoError(os.RemoveAll(path.Dir(testDataDir)))
+	s.Require().NoError(os.RemoveAll(filepath.Dir(testDataDir)))
 }
 
 func (s *logsSuite) Test_NewLogs_Success() {
@@ -71,6 +74,33 @@ func (s *logsSuite) Test_NewLogs_Success() {
 	s.Len(logs.filesInfo, 5)
 }
 
+func (s *logsSuite) Test_NewLogs_CRI() {
+	dir := "test/pods"
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	for _, container := range []string{"default_web-0_uid/apache", "default_web-0_uid/sidecar"} {
+		s.Require().NoError(os.MkdirAll(filepath.Join(dir, container), 0777))
+		s.createLogFile(filepath.Join(dir, container), "0.log", "log 0")
+		s.createLogFile(filepath.Join(dir, container), "0.log.20220303-024500.gz", "compressed")
+	}
+	cfg := LogsConfig{
+		Directory:    dir,
+		LastNMinutes: 3,
+		Format:       FormatCRI,
+	}
+
+	logs, err := NewLogs(cfg)
+
+	s.NoError(err)
+	s.NotNil(logs)
+	s.Len(logs.filesInfo, 2)
+	for _, fi := range logs.filesInfo {
+		s.Equal("0.log", fi.Name())
+		s.Contains(fi.path, "default_web-0_uid")
+	}
+}
+
 func (s *logsSuite) Test_NewLogs_Error() {
 	cfg := LogsConfig{
 		Directory: "/path/to/nothing",
@@ -82,6 +112,121 @@ func (s *logsSuite) Test_NewLogs_Error() {
 	s.Nil(logs)
 }
 
+func (s *logsSuite) Test_NewLogs_UnsupportedFormat() {
+	cfg := LogsConfig{
+		Directory: testDataDir,
+		Format:    "xml",
+	}
+
+	logs, err := NewLogs(cfg)
+
+	s.EqualError(err, "invalid format: unsupported log format 'xml'")
+	s.Nil(logs)
+}
+
+func (s *logsSuite) Test_Print_CRI() {
+	dir := "test/cri/default_web-0_uid/apache"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(filepath.Dir(filepath.Dir(dir))))
+	}()
+	s.createLogFile(dir, "0.log", `2022-03-03T02:43:30.000000000Z stdout F 127.0.0.1 user-identifier frank [03/Mar/2022:02:43:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+2022-03-03T02:44:10.000000000Z stdout F 127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+2022-03-03T02:44:50.000000000Z stderr F 127.0.0.1 user-identifier frank [03/Mar/2022:02:44:50 +0000] "GET /api/endpoint HTTP/1.0" 500 123
+`)
+	buf := &bytes.Buffer{}
+	cfg := LogsConfig{
+		Directory:    filepath.Dir(filepath.Dir(dir)),
+		LastNMinutes: 1,
+		Format:       FormatCRI,
+	}
+	logs, err := NewLogs(cfg)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+	}
+
+	err = logs.Print(context.Background(), buf)
+
+	s.NoError(err)
+	s.Equal(`2022-03-03T02:44:10.000000000Z stdout F 127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+2022-03-03T02:44:50.000000000Z stderr F 127.0.0.1 user-identifier frank [03/Mar/2022:02:44:50 +0000] "GET /api/endpoint HTTP/1.0" 500 123
+`, buf.String())
+}
+
+func (s *logsSuite) Test_ForEach_Formats() {
+	dir := "test/formats/apache2"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(filepath.Dir(dir)))
+	}()
+	s.createLogFile(dir, "access.log", `127.0.0.1 - frank [03/Mar/2022:02:43:30 +0000] "GET /a HTTP/1.0" 200 123 "-" "curl/7.79.1"
+127.0.0.2 - frank [03/Mar/2022:02:44:10 +0000] "GET /b HTTP/1.0" 500 123 "-" "curl/7.79.1"
+127.0.0.3 - frank [03/Mar/2022:02:44:50 +0000] "GET /c HTTP/1.0" 200 123 "-" "curl/7.79.1"
+`)
+	s.createLogFile(dir, "error.log", `[Thu Mar 03 02:43:40.000000 2022] [core:error] [pid 1234:tid 5678] [client 127.0.0.1:51234] AH00126: Invalid URI
+[Thu Mar 03 02:44:20.000000 2022] [php:error] [pid 1234:tid 5678] [client 127.0.0.2:51235] PHP Fatal error: Uncaught Error
+`)
+	logs, err := New(
+		WithDirectory(dir),
+		WithWindow(time.Minute),
+		WithFormat(FormatCombined),
+		WithFormats(FormatPattern{Pattern: "error*", Format: FormatError}),
+		WithMerge(),
+	)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-time.Minute)
+	}
+
+	var lines []string
+	err = logs.ForEach(context.Background(), func(entry LogEntry) error {
+		s.False(entry.Invalid, entry.Line)
+		lines = append(lines, fmt.Sprintf("%s %s %s%s", entry.IP, filepath.Base(entry.File), entry.Path, entry.Level))
+		return nil
+	})
+
+	s.NoError(err)
+	s.Equal([]string{
+		"127.0.0.2 access.log /b",
+		"127.0.0.2 error.log error",
+		"127.0.0.3 access.log /c",
+	}, lines)
+}
+
+func (s *logsSuite) Test_ForEach_DeterministicOrder() {
+	dir := "test/ties"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	// b.log is modified before a.log, and both are modified at the same time as c.log
+	for i, name := range []string{"b.log", "a.log", "c.log"} {
+		s.Require().NoError(s.createLogFile(dir, name, fmt.Sprintf(`127.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /%[1]s1 HTTP/1.0" 200 123
+127.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /%[1]s2 HTTP/1.0" 200 123
+127.0.0.1 - - [03/Mar/2022:02:44:20 +0000] "GET /%[1]s3 HTTP/1.0" 200 123
+`, name[:1])).Close())
+		modTime := s.testTime.Add(time.Duration((i+1)/2) * time.Second)
+		s.Require().NoError(os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+	}
+
+	for _, workers := range []int{1, 4} {
+		s.Run(fmt.Sprintf("%d Workers", workers), func() {
+			logs, err := New(WithDirectory(dir), WithWindow(time.Minute), WithEnd(s.testTime), WithMerge(), WithWorkers(workers), WithDeterministicOrder())
+			s.Require().NoError(err)
+
+			var paths []string
+			err = logs.ForEach(context.Background(), func(entry LogEntry) error {
+				paths = append(paths, entry.Path)
+				return nil
+			})
+
+			s.NoError(err)
+			s.Equal([]string{"/a1", "/a2", "/b1", "/b2", "/c1", "/c2", "/a3", "/b3", "/c3"}, paths)
+		})
+	}
+}
+
 func (s *logsSuite) Test_Print_Success() {
 	tests := []struct {
 		name         string
@@ -91,9 +236,7 @@ func (s *logsSuite) Test_Print_Success() {
 		{
 			name:         "Last Minute",
 			lastNMinutes: 1,
-			expectedLogs: `127.0.0.1 user-identifier frank [03/Mar/2022:02:43:20 +0000] "GET /api/endpoint HTTP/1.0" 200 123
-127.0.0.1 user-identifier frank [03/Mar/2022:02:43:40 +0000] "GET /api/endpoint HTTP/1.0" 200 123
-127.0.0.1 user-identifier frank [03/Mar/2022:02:44:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+			expectedLogs: `127.0.0.1 user-identifier frank [03/Mar/2022:02:44:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
 127.0.0.1 user-identifier frank [03/Mar/2022:02:45:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
 127.0.0.1 user-identifier frank [03/Mar/2022:02:45:20 +0000] "GET /api/endpoint HTTP/1.0" 200 123
 127.0.0.1 user-identifier frank [03/Mar/2022:02:45:40 +0000] "GET /api/endpoint HTTP/1.0" 200 123
@@ -165,7 +308,7 @@ func (s *logsSuite) Test_Print_Success() {
 			}
 			s.Require().NoError(err)
 
-			err = logs.Print(buf)
+			err = logs.Print(context.Background(), buf)
 
 			s.NoError(err)
 			s.Equal(test.expectedLogs, buf.String())
@@ -173,6 +316,364 @@ func (s *logsSuite) Test_Print_Success() {
 	}
 }
 
+func (s *logsSuite) Test_Print_OrderByContent() {
+	dir := "test/content"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	// the modified times of the files are the opposite of their contents,
+	// as if they were copied around in the wrong order
+	s.createLogFile(dir, "a.log", `127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:50 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`)
+	s.createLogFile(dir, "b.log", `127.0.0.1 user-identifier frank [03/Mar/2022:02:43:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:43:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`)
+	s.createLogFile(dir, "c.log", "not a log file")
+	s.Require().NoError(os.Chtimes(filepath.Join(dir, "a.log"), s.testTime, s.testTime))
+	s.Require().NoError(os.Chtimes(filepath.Join(dir, "b.log"), s.testTime.Add(time.Hour), s.testTime.Add(time.Hour)))
+	s.Require().NoError(os.Chtimes(filepath.Join(dir, "c.log"), s.testTime.Add(-time.Hour), s.testTime.Add(-time.Hour)))
+	buf := &bytes.Buffer{}
+	cfg := LogsConfig{
+		Directory:      dir,
+		LastNMinutes:   3,
+		OrderByContent: true,
+	}
+	logs, err := NewLogs(cfg)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+	}
+
+	err = logs.Print(context.Background(), buf)
+
+	s.NoError(err)
+	s.Equal(`127.0.0.1 user-identifier frank [03/Mar/2022:02:43:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:43:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:50 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`, buf.String())
+}
+
+func (s *logsSuite) Test_Print_PrunedFiles() {
+	dir := "test/pruned"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	// all the files have fresh modified times, but only the last one has fresh logs
+	s.createLogFile(dir, "http-1.log", `127.0.0.1 user-identifier frank [03/Mar/2022:01:10:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`)
+	s.createLogFile(dir, "http-2.log", `127.0.0.1 user-identifier frank [03/Mar/2022:01:20:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`)
+	s.createLogFile(dir, "http-3.log", `127.0.0.1 user-identifier frank [03/Mar/2022:02:43:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`)
+	for i := 1; i <= 3; i++ {
+		modTime := s.testTime.Add(time.Duration(i) * time.Second)
+		s.Require().NoError(os.Chtimes(filepath.Join(dir, fmt.Sprintf("http-%d.log", i)), modTime, modTime))
+	}
+	buf := &bytes.Buffer{}
+	cfg := LogsConfig{
+		Directory:    dir,
+		LastNMinutes: 1,
+	}
+	logs, err := NewLogs(cfg)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+	}
+
+	err = logs.Print(context.Background(), buf)
+
+	s.NoError(err)
+	s.Equal(`127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`, buf.String())
+}
+
+func (s *logsSuite) Test_Print_Tolerance() {
+	dir := "test/tolerance"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	// the second log has been written slightly out of order
+	s.createLogFile(dir, "http.log", `127.0.0.1 user-identifier frank [03/Mar/2022:02:42:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:43:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:01 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:43:59 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:20 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`)
+	s.Require().NoError(os.Chtimes(filepath.Join(dir, "http.log"), s.testTime, s.testTime))
+	tests := []struct {
+		name         string
+		tolerance    int64
+		expectedLogs string
+	}{
+		{
+			name:      "No Tolerance",
+			tolerance: 0,
+			expectedLogs: `127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:20 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`,
+		},
+		{
+			name:      "Tolerance",
+			tolerance: 1024,
+			expectedLogs: `127.0.0.1 user-identifier frank [03/Mar/2022:02:44:01 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:20 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`,
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			buf := &bytes.Buffer{}
+			cfg := LogsConfig{
+				Directory:    dir,
+				LastNMinutes: 1,
+				Tolerance:    test.tolerance,
+			}
+			logs, err := NewLogs(cfg)
+			s.Require().NoError(err)
+			logs.nowMinusT = func() time.Time {
+				return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+			}
+
+			err = logs.Print(context.Background(), buf)
+
+			s.NoError(err)
+			s.Equal(test.expectedLogs, buf.String())
+		})
+	}
+}
+
+func (s *logsSuite) Test_Print_Boundary() {
+	dir := "test/boundary"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	line := func(t string) string {
+		return fmt.Sprintf(`127.0.0.1 - frank [03/Mar/2022:%s +0000] "GET /api/endpoint HTTP/1.0" 200 123`+"\n", t)
+	}
+	tests := []struct {
+		name         string
+		lookupTime   string
+		logs         []string
+		expectedLogs []string
+	}{
+		{
+			name:         "Minute",
+			lookupTime:   "02:44:00",
+			logs:         []string{line("02:43:58"), line("02:43:59"), line("02:44:00"), line("02:43:59"), line("02:44:01")},
+			expectedLogs: []string{line("02:44:00"), line("02:44:01")},
+		},
+		{
+			name:         "Hour",
+			lookupTime:   "02:00:00",
+			logs:         []string{line("01:59:58"), line("01:59:59"), line("02:00:00"), line("02:00:01"), line("01:59:59"), line("02:00:02")},
+			expectedLogs: []string{line("02:00:00"), line("02:00:01"), line("02:00:02")},
+		},
+		{
+			// the file isn't searched, its first log being within the window, and the invalid lines
+			// stay with the previous log, left out or not
+			name:         "Invalid",
+			lookupTime:   "02:00:00",
+			logs:         []string{line("02:00:00"), line("01:59:59"), "invalid\n", line("02:00:01"), "invalid\n", line("02:00:02")},
+			expectedLogs: []string{line("02:00:00"), line("02:00:01"), "invalid\n", line("02:00:02")},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.createLogFile(dir, "http.log", strings.Join(test.logs, ""))
+			lookupTime, err := time.Parse(dateTimeFormat, "03/Mar/2022:"+test.lookupTime+" +0000")
+			s.Require().NoError(err)
+			s.Require().NoError(os.Chtimes(filepath.Join(dir, "http.log"), lookupTime, lookupTime))
+			expected := strings.Join(test.expectedLogs, "")
+
+			for name, opts := range map[string][]Option{
+				"Copied":   nil,
+				"Streamed": {WithFilter(func(LogEntry) bool { return true })},
+				"Parallel": {WithWorkers(2), WithFilter(func(LogEntry) bool { return true })},
+			} {
+				logs, err := New(append([]Option{WithDirectory(dir), WithWindow(time.Minute)}, opts...)...)
+				s.Require().NoError(err)
+				logs.nowMinusT = func() time.Time { return lookupTime }
+				buf := &bytes.Buffer{}
+
+				s.Require().NoError(logs.Print(context.Background(), buf), name)
+				s.Equal(expected, buf.String(), name)
+			}
+
+			logs, err := New(WithDirectory(dir), WithWindow(time.Minute))
+			s.Require().NoError(err)
+			logs.nowMinusT = func() time.Time { return lookupTime }
+			var reversed []string
+			s.Require().NoError(logs.ForEachReverse(context.Background(), func(entry LogEntry) error {
+				reversed = append([]string{entry.Line + "\n"}, reversed...)
+				return nil
+			}))
+			s.Equal(expected, strings.Join(reversed, ""), "Reversed")
+		})
+	}
+}
+
+func (s *logsSuite) Test_Print_EmptyFiles() {
+	dir := "test/empty"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	// freshly rotated files are empty (or blank), yet their modified times are the most recent ones
+	s.createLogFile(dir, "http-1.log", "")
+	s.createLogFile(dir, "http-2.log", `127.0.0.1 user-identifier frank [03/Mar/2022:02:43:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`)
+	s.createLogFile(dir, "http-3.log", " \n\n\t\n")
+	s.createLogFile(dir, "http-4.log", "")
+	for i := 1; i <= 4; i++ {
+		modTime := s.testTime.Add(time.Duration(i) * time.Second)
+		if i == 1 {
+			modTime = s.testTime.Add(-time.Minute)
+		}
+		s.Require().NoError(os.Chtimes(filepath.Join(dir, fmt.Sprintf("http-%d.log", i)), modTime, modTime))
+	}
+	tests := []struct {
+		name  string
+		merge bool
+	}{
+		{name: "Sequential", merge: false},
+		{name: "Merged", merge: true},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			buf := &bytes.Buffer{}
+			cfg := LogsConfig{
+				Directory:    dir,
+				LastNMinutes: 2,
+				Merge:        test.merge,
+			}
+			logs, err := NewLogs(cfg)
+			s.Require().NoError(err)
+			s.Len(logs.filesInfo, 2)
+			logs.nowMinusT = func() time.Time {
+				return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+			}
+
+			err = logs.Print(context.Background(), buf)
+
+			s.NoError(err)
+			s.Equal(`127.0.0.1 user-identifier frank [03/Mar/2022:02:43:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`, buf.String())
+		})
+	}
+}
+
+func (s *logsSuite) Test_Print_LineEndings() {
+	tests := []struct {
+		name  string
+		files []string
+	}{
+		{
+			name: "CRLF",
+			files: []string{
+				"127.0.0.1 user-identifier frank [03/Mar/2022:02:43:00 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\r\n" +
+					"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\r\n",
+				"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\r\n" +
+					"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:50 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\r\n",
+			},
+		},
+		{
+			name: "Missing Trailing Newline",
+			files: []string{
+				"127.0.0.1 user-identifier frank [03/Mar/2022:02:43:00 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\n" +
+					"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123",
+				"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\n" +
+					"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:50 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123",
+			},
+		},
+		{
+			name: "Partially Written Last Line",
+			files: []string{
+				"127.0.0.1 user-identifier frank [03/Mar/2022:02:43:00 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\n" +
+					"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\n",
+				"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\n" +
+					"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:50 +0000] \"GET /api/endpoint HTTP/1.0\" 200 123\n" +
+					"127.0.0.1 user-identifier frank [03/Mar/2022:02:44:5",
+			},
+		},
+	}
+	expectedLogs := `127.0.0.1 user-identifier frank [03/Mar/2022:02:44:10 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:30 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+127.0.0.1 user-identifier frank [03/Mar/2022:02:44:50 +0000] "GET /api/endpoint HTTP/1.0" 200 123
+`
+	for _, test := range tests {
+		for _, merge := range []bool{false, true} {
+			s.Run(fmt.Sprintf("%s Merge %t", test.name, merge), func() {
+				dir := "test/line-endings"
+				s.Require().NoError(os.MkdirAll(dir, 0777))
+				defer func() {
+					s.Require().NoError(os.RemoveAll(dir))
+				}()
+				for i, logs := range test.files {
+					name := fmt.Sprintf("http-%d.log", i+1)
+					s.createLogFile(dir, name, logs)
+					modTime := s.testTime.Add(time.Duration(i) * time.Second)
+					s.Require().NoError(os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+				}
+				buf := &bytes.Buffer{}
+				cfg := LogsConfig{
+					Directory:    dir,
+					LastNMinutes: 1,
+					Merge:        merge,
+				}
+				logs, err := NewLogs(cfg)
+				s.Require().NoError(err)
+				logs.nowMinusT = func() time.Time {
+					return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+				}
+
+				err = logs.Print(context.Background(), buf)
+
+				s.NoError(err)
+				s.Equal(expectedLogs, buf.String())
+			})
+		}
+	}
+}
+
+func (s *logsSuite) Test_Print_Cancelled() {
+	for _, merge := range []bool{false, true} {
+		s.Run(fmt.Sprintf("Merge %t", merge), func() {
+			buf := &bytes.Buffer{}
+			cfg := LogsConfig{
+				Directory:    testDataDir,
+				LastNMinutes: 5,
+				Merge:        merge,
+			}
+			logs, err := NewLogs(cfg)
+			s.Require().NoError(err)
+			logs.nowMinusT = func() time.Time {
+				return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err = logs.Print(ctx, buf)
+
+			s.ErrorIs(err, context.Canceled)
+			s.Equal("", buf.String())
+		})
+	}
+}
+
 type fakeFile struct {
 	name string
 }
@@ -195,12 +696,12 @@ func (s *logsSuite) Test_Print_OpenError() {
 			return s.testTime
 		},
 		cfg: cfg,
-		filesInfo: []os.FileInfo{
-			fakeFile{name: "does-not-exist"},
+		filesInfo: []logFile{
+			{FileInfo: fakeFile{name: "does-not-exist"}, path: "/path/to/nothing/does-not-exist"},
 		},
 	}
 
-	err := logs.Print(buf)
+	err := logs.Print(context.Background(), buf)
 
 	s.EqualError(err, "open /path/to/nothing/does-not-exist: no such file or directory")
 	s.Equal("", buf.String())
@@ -212,7 +713,7 @@ func (s *logsSuite) Test_Print_IndexTimeError() {
 	defer func() {
 		s.Require().NoError(os.RemoveAll(dir))
 	}()
-	s.createLogFile(dir, "bad.log", "some invalid log")
+	s.createLogFile(dir, "bad.log", "some invalid log\n")
 	buf := &bytes.Buffer{}
 	cfg := LogsConfig{
 		Directory: dir,
@@ -223,14 +724,185 @@ func (s *logsSuite) Test_Print_IndexTimeError() {
 	}
 	s.Require().NoError(err)
 
-	err = logs.Print(buf)
+	err = logs.Print(context.Background(), buf)
 
-	s.EqualError(err, "invalid log format on line 'some invalid log'")
+	s.EqualError(err, "test/index-time/bad.log:1 (offset 0): invalid log format on line 'some invalid log'")
 	s.Equal("", buf.String())
 }
 
+func (s *logsSuite) Test_Print_Buffered() {
+	dir := "test/buffered"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	s.Require().NoError(writeBenchLogs(dir, s.testTime, 10000))
+	logs, err := NewLogs(LogsConfig{Directory: dir, LastNMinutes: 60})
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-time.Hour)
+	}
+
+	w := &countingWriter{}
+	s.Require().NoError(logs.Print(context.Background(), w))
+
+	// the lines are written by chunks of the size of the buffer
+	s.Greater(w.bytes, 10*printBufferSize)
+	s.Equal((w.bytes+printBufferSize-1)/printBufferSize, w.writes)
+}
+
+func (s *logsSuite) Test_Print_Copied() {
+	dir := "test/copied"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	// a file larger than the chunks copied at once, followed by files of unusual shapes
+	s.Require().NoError(writeBenchLogs(dir, s.testTime.Add(-10*time.Minute), 60000))
+	files := []string{
+		"127.0.0.1 - - [03/Mar/2022:02:36:00 +0000] \"GET / HTTP/1.1\" 200 1\r\n" +
+			"127.0.0.1 - - [03/Mar/2022:02:37:00 +0000] \"GET / HTTP/1.1\" 200 1\r\n",
+		"127.0.0.1 - - [03/Mar/2022:02:38:00 +0000] \"GET / HTTP/1.1\" 200 1\n" +
+			"not a log line\n\n" +
+			"127.0.0.1 - - [03/Mar/2022:02:39:00 +0000] \"GET / HTTP/1.1\" 200 1",
+		"127.0.0.1 - - [03/Mar/2022:02:40:00 +0000] \"GET / HTTP/1.1\" 200 1\n" +
+			"127.0.0.1 - - [03/Mar/2022:02:4",
+	}
+	for i, logs := range files {
+		name := fmt.Sprintf("access.log.%d", i+1)
+		s.createLogFile(dir, name, logs)
+		modTime := s.testTime.Add(time.Duration(i-len(files)) * time.Minute)
+		s.Require().NoError(os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+	}
+
+	print := func(opts ...Option) string {
+		logs, err := New(append([]Option{WithDirectory(dir), WithWindow(2 * time.Hour)}, opts...)...)
+		s.Require().NoError(err)
+		logs.nowMinusT = func() time.Time {
+			return s.testTime.Add(-2 * time.Hour)
+		}
+		buf := &bytes.Buffer{}
+		s.Require().NoError(logs.Print(context.Background(), buf))
+		return buf.String()
+	}
+
+	// the log lines are printed one by one as soon as they're filtered
+	expected := print(WithFilter(func(LogEntry) bool { return true }))
+	s.Greater(len(expected), copyChunkSize)
+	s.Contains(expected, "02:37:00 +0000] \"GET / HTTP/1.1\" 200 1\n")
+	s.Contains(expected, "not a log line\n\n")
+	s.NotContains(expected, "02:4\n")
+	s.Equal(expected, print())
+	s.Equal(expected, print(WithMemoryMap()))
+}
+
+func (s *logsSuite) Test_Print_Copied_Cancelled() {
+	dir := "test/copied-cancelled"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	s.Require().NoError(writeBenchLogs(dir, s.testTime.Add(-10*time.Minute), 60000))
+	logs, err := New(WithDirectory(dir), WithWindow(2*time.Hour))
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-2 * time.Hour)
+	}
+	expected := &bytes.Buffer{}
+	s.Require().NoError(logs.Print(context.Background(), expected))
+
+	// canceled while the first chunk is copied
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	buf := &bytes.Buffer{}
+	err = logs.Print(ctx, writerFunc(func(p []byte) (int, error) {
+		cancel()
+		return buf.Write(p)
+	}))
+
+	s.ErrorIs(err, context.Canceled)
+	// the line copied last is completed
+	s.Greater(buf.Len(), copyChunkSize)
+	s.Less(buf.Len(), expected.Len())
+	s.Equal(byte('\n'), buf.Bytes()[buf.Len()-1])
+	s.True(bytes.HasPrefix(expected.Bytes(), buf.Bytes()))
+}
+
+func (s *logsSuite) Test_copyable() {
+	tests := []struct {
+		name     string
+		cfg      LogsConfig
+		expected bool
+	}{
+		{name: "Default", cfg: LogsConfig{}, expected: true},
+		{name: "Bots", cfg: LogsConfig{Bots: NewBotClassifier(), Workers: 4}, expected: true},
+		{name: "Filters", cfg: LogsConfig{Filters: []Filter{ExcludeBots}}},
+		{name: "Merge", cfg: LogsConfig{Merge: true}},
+		{name: "End", cfg: LogsConfig{End: s.testTime}},
+		{name: "Tolerance", cfg: LogsConfig{Tolerance: 1024}},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			logs := &Logs{cfg: test.cfg}
+			s.Equal(test.expected, logs.copyable())
+		})
+	}
+}
+
+func (s *logsSuite) Test_Print_Refreshed() {
+	dir := "test/refresh"
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	defer func() {
+		s.Require().NoError(os.RemoveAll(dir))
+	}()
+	line := func(t string) string {
+		return fmt.Sprintf(`127.0.0.1 user-identifier frank [03/Mar/2022:02:%s +0000] "GET /api/endpoint HTTP/1.0" 200 123`+"\n", t)
+	}
+	write := func(name, logs string, modTime time.Time) {
+		s.Require().NoError(os.WriteFile(filepath.Join(dir, name), []byte(logs), 0666))
+		s.Require().NoError(os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+	}
+	write("access.log", line("44:00"), s.testTime.Add(-time.Minute))
+	newLogs := func(opts ...Option) *Logs {
+		logs, err := New(append([]Option{WithDirectory(dir), WithWindow(2 * time.Minute)}, opts...)...)
+		s.Require().NoError(err)
+		logs.nowMinusT = func() time.Time {
+			return s.testTime.Add(-2 * time.Minute)
+		}
+		return logs
+	}
+	refreshed, listedOnce := newLogs(WithRefresh()), newLogs()
+	read := func(logs *Logs) string {
+		buf := &bytes.Buffer{}
+		s.Require().NoError(logs.Print(context.Background(), buf))
+		return buf.String()
+	}
+	s.Equal(line("44:00"), read(refreshed))
+
+	// rotated by renaming, the new file being empty at first then appended to
+	s.Require().NoError(os.Rename(filepath.Join(dir, "access.log"), filepath.Join(dir, "access.log.1")))
+	write("access.log", "", s.testTime)
+	s.Equal(line("44:00"), read(refreshed))
+	f, err := os.OpenFile(filepath.Join(dir, "access.log"), os.O_APPEND|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	_, err = f.WriteString(line("44:30"))
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+	s.Require().NoError(os.Chtimes(filepath.Join(dir, "access.log"), s.testTime, s.testTime))
+	s.Equal(line("44:00")+line("44:30"), read(refreshed))
+	s.NotEqual(line("44:00")+line("44:30"), read(listedOnce))
+
+	// rotated by copytruncate, which doesn't modify the directory
+	write("access.log", line("44:45"), s.testTime.Add(time.Second))
+	s.Equal(line("44:00")+line("44:45"), read(refreshed))
+
+	// removed
+	s.Require().NoError(os.Remove(filepath.Join(dir, "access.log.1")))
+	s.Equal(line("44:45"), read(refreshed))
+}
+
 func (s *logsSuite) createLogFile(dir, name, logs string) *os.File {
-	file, err := os.Create(path.Join(dir, name))
+	file, err := os.Create(filepath.Join(dir, name))
 	s.Require().NoError(err)
 	_, err = file.WriteString(logs)
 	s.Require().NoError(err)
@@ -240,3 +912,74 @@ func (s *logsSuite) createLogFile(dir, name, logs string) *os.File {
 func TestLogs(t *testing.T) {
 	suite.Run(t, new(logsSuite))
 }
+
+// countingWriter counts the writes made to it, along with the bytes written.
+type countingWriter struct {
+	writes, bytes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.bytes += len(p)
+	return len(p), nil
+}
+
+// writerFunc is a writer calling a given function.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// writeBenchLogs writes n log lines, one every 100ms until a given time, to an access.log file inside a given directory.
+func writeBenchLogs(dir string, until time.Time, n int) error {
+	var logs bytes.Buffer
+	from := until.Add(-time.Duration(n) * 100 * time.Millisecond)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&logs, "10.0.%d.%d - frank [%s] \"GET /api/endpoint/%d?page=%d HTTP/1.1\" 200 %d\n",
+			i%7, i%251, from.Add(time.Duration(i)*100*time.Millisecond).Format(dateTimeFormat), i%1000, i%10, i)
+	}
+	name := filepath.Join(dir, "access.log")
+	if err := os.WriteFile(name, logs.Bytes(), 0666); err != nil {
+		return err
+	}
+	return os.Chtimes(name, until, until)
+}
+
+// BenchmarkPrint compares Print, which buffers the log lines, or copies them when none is filtered,
+// with writing them one by one to the same file.
+func BenchmarkPrint(b *testing.B) {
+	dir := b.TempDir()
+	until := time.Now().UTC().Truncate(time.Second)
+	require.NoError(b, writeBenchLogs(dir, until, 100000))
+	logs, err := NewLogs(LogsConfig{Directory: dir, LastNMinutes: 180})
+	require.NoError(b, err)
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	require.NoError(b, err)
+	defer func() { _ = devNull.Close() }()
+
+	b.Run("PerLine", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			err := logs.ForEach(context.Background(), func(entry LogEntry) error {
+				_, err := fmt.Fprintln(devNull, entry.Line)
+				return err
+			})
+			require.NoError(b, err)
+		}
+	})
+	b.Run("Buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		filtered, err := NewLogs(LogsConfig{Directory: dir, LastNMinutes: 180, Filters: []Filter{func(LogEntry) bool { return true }}})
+		require.NoError(b, err)
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, filtered.Print(context.Background(), devNull))
+		}
+	})
+	b.Run("Copied", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, logs.Print(context.Background(), devNull))
+		}
+	})
+}
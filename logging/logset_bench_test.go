@@ -0,0 +1,214 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The synthetic rotated log set of the benchmarks below is generated once and reused by the next runs.
+// Its size defaults to 64MB, and can be raised to several gigabytes to catch the regressions showing on large files only:
+// LOG_READER_BENCH_SIZE=4GB go test -run XXX -bench LogSet -benchtime 10x ./logging
+const (
+	benchSizeEnv = "LOG_READER_BENCH_SIZE"
+	benchDirEnv  = "LOG_READER_BENCH_DIR"
+	// benchFiles is the number of files of the log set (access.log, access.log.1, ...)
+	benchFiles = 8
+	// benchSpan is the time covered by the log set, ending at benchNow
+	benchSpan = 24 * time.Hour
+)
+
+// benchNow is the time the log set ends at.
+var benchNow = time.Date(2022, time.March, 3, 0, 0, 0, 0, time.UTC)
+
+// parseBenchSize parses a size in bytes, optionally suffixed by KB, MB or GB.
+func parseBenchSize(s string) (int64, error) {
+	multiplier := int64(1)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(strings.ToUpper(s), suffix) {
+			s, multiplier = s[:len(s)-len(suffix)], m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n * multiplier, err
+}
+
+// benchLogSet returns the directory of the log set of the benchmarks, generating it unless done by a previous run.
+func benchLogSet(b *testing.B) string {
+	size := int64(64 << 20)
+	if s := os.Getenv(benchSizeEnv); s != "" {
+		var err error
+		size, err = parseBenchSize(s)
+		require.NoError(b, err, benchSizeEnv)
+	}
+	dir := os.Getenv(benchDirEnv)
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("log-reader-bench-%d", size))
+	}
+	complete := filepath.Join(dir, ".complete")
+	if _, err := os.Stat(complete); err == nil {
+		return dir
+	}
+
+	b.Logf("generating %d bytes of logs in %s", size, dir)
+	require.NoError(b, os.RemoveAll(dir))
+	require.NoError(b, os.MkdirAll(dir, 0777))
+	// a line is about 200 bytes long
+	lines := size / 200
+	interval := benchSpan / time.Duration(lines)
+	from := benchNow.Add(-benchSpan)
+	for i := 0; i < benchFiles; i++ {
+		name := "access.log"
+		if n := benchFiles - 1 - i; n > 0 {
+			name += "." + strconv.Itoa(n)
+		}
+		first := int64(i) * lines / benchFiles
+		last := int64(i+1) * lines / benchFiles
+		modTime := writeBenchFile(b, filepath.Join(dir, name), from, interval, first, last)
+		require.NoError(b, os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+	}
+	require.NoError(b, os.WriteFile(complete, nil, 0666))
+	return dir
+}
+
+// writeBenchFile writes the log lines of indexes [first, last) of the log set to a given file, one every interval
+// from a given time, returning the time of its last log line.
+func writeBenchFile(b *testing.B, name string, from time.Time, interval time.Duration, first, last int64) time.Time {
+	f, err := os.Create(name)
+	require.NoError(b, err)
+	w := bufio.NewWriterSize(f, 1<<20)
+	agents := []string{
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0 Safari/537.36",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"curl/7.79.1",
+	}
+	statuses := []int{200, 200, 200, 200, 200, 200, 301, 404, 500, 503}
+
+	var line []byte
+	var logTime time.Time
+	var second int64 = -1
+	var dateTime string
+	for i := first; i < last; i++ {
+		logTime = from.Add(time.Duration(i) * interval)
+		if s := logTime.Unix(); s != second {
+			second, dateTime = s, logTime.Format(dateTimeFormat)
+		}
+		line = append(line[:0], "10.0."...)
+		line = strconv.AppendInt(line, i%13, 10)
+		line = append(line, '.')
+		line = strconv.AppendInt(line, i%251, 10)
+		line = append(line, " - - ["...)
+		line = append(line, dateTime...)
+		line = append(line, "] \"GET /api/v1/items/"...)
+		line = strconv.AppendInt(line, i%5000, 10)
+		line = append(line, "?page="...)
+		line = strconv.AppendInt(line, i%10, 10)
+		line = append(line, " HTTP/1.1\" "...)
+		line = strconv.AppendInt(line, int64(statuses[i%int64(len(statuses))]), 10)
+		line = append(line, ' ')
+		line = strconv.AppendInt(line, i%20000, 10)
+		line = append(line, " \"https://example.com/\" \""...)
+		line = append(line, agents[i%int64(len(agents))]...)
+		line = append(line, "\"\n"...)
+		_, err := w.Write(line)
+		require.NoError(b, err)
+	}
+	require.NoError(b, w.Flush())
+	require.NoError(b, f.Close())
+	return logTime
+}
+
+// benchLogs returns the logs of the log set within a given window ending at benchNow, along with the size of their lines.
+func benchLogs(b *testing.B, window time.Duration, opts ...Option) (*Logs, int64) {
+	logs, err := New(append([]Option{WithDirectory(benchLogSet(b)), WithFormat(FormatCombined), WithWindow(window)}, opts...)...)
+	require.NoError(b, err)
+	logs.nowMinusT = func() time.Time {
+		return benchNow.Add(-window)
+	}
+	w := &countingWriter{}
+	require.NoError(b, logs.Print(context.Background(), w))
+	return logs, int64(w.bytes)
+}
+
+func BenchmarkLogSet_IndexTime(b *testing.B) {
+	dir := benchLogSet(b)
+	f, err := os.Open(filepath.Join(dir, "access.log"))
+	require.NoError(b, err)
+	defer func() { _ = f.Close() }()
+	file := NewFormatFile(f, FormatCombined)
+	first, last, err := file.TimeRange()
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lookupTime := first.Add(time.Duration(i%100) * last.Sub(first) / 100)
+		_, err := file.IndexTime(context.Background(), lookupTime)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkLogSet_Print(b *testing.B) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	require.NoError(b, err)
+	defer func() { _ = devNull.Close() }()
+	all := func(LogEntry) bool { return true }
+
+	for _, window := range []time.Duration{time.Hour, 6 * time.Hour} {
+		for _, test := range []struct {
+			name string
+			opts []Option
+		}{
+			{name: "Copied"},
+			{name: "Parsed", opts: []Option{WithFilter(all)}},
+			{name: "Parsed Workers", opts: []Option{WithFilter(all), WithWorkers(4)}},
+			{name: "Parsed Merged", opts: []Option{WithFilter(all), WithMerge()}},
+		} {
+			b.Run(fmt.Sprintf("%s/%s", window, test.name), func(b *testing.B) {
+				logs, size := benchLogs(b, window, test.opts...)
+				b.SetBytes(size)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					require.NoError(b, logs.Print(context.Background(), devNull))
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkLogSet_Filter(b *testing.B) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Server Errors", opts: []Option{WithFilter(func(entry LogEntry) bool { return entry.serverError() })}},
+		{name: "Path Prefix", opts: []Option{WithFilter(func(entry LogEntry) bool { return strings.HasPrefix(entry.Path, "/api/v1/items/42") })}},
+		{name: "Exclude Bots", opts: []Option{WithBots(NewBotClassifier()), WithFilter(ExcludeBots)}},
+	}
+	for _, test := range tests {
+		b.Run(test.name, func(b *testing.B) {
+			_, size := benchLogs(b, time.Hour)
+			logs, _ := benchLogs(b, time.Hour, test.opts...)
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				n := 0
+				require.NoError(b, logs.ForEach(context.Background(), func(LogEntry) error {
+					n++
+					return nil
+				}))
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package logging
+
+import "sync/atomic"
+
+// Metrics counts the work of the reader itself, as opposed to the metrics of the logs read, e.g. to monitor an agent
+// reading the logs over and over (see LogsConfig.Metrics): the log files scanned, the bytes read from them,
+// the probes of the binary searches, the log lines read but left out and the log files skipped as removed since listed. It is safe for concurrent use,
+// so the Logs of several calls (e.g. the requests of a server) can share it. A nil Metrics counts nothing.
+type Metrics struct {
+	filesScanned, bytesRead, searchProbes, linesSkipped, duplicateLines, filesVanished int64
+}
+
+// MetricsSnapshot holds the counts of a Metrics at some point, see Metrics.Snapshot.
+type MetricsSnapshot struct {
+	// FilesScanned counts the log files opened to be read or searched.
+	FilesScanned int64 `json:"files_scanned"`
+	// BytesRead counts the bytes read from the log files, the ones copied as is included.
+	BytesRead int64 `json:"bytes_read"`
+	// SearchProbes counts the log lines read by the binary searches of the log files (see File.IndexTime).
+	SearchProbes int64 `json:"search_probes"`
+	// LinesSkipped counts the log lines read but left out, e.g. before the time range or filtered out.
+	LinesSkipped int64 `json:"lines_skipped"`
+	// DuplicateLines counts the log lines duplicated across files, suppressed or not (see LogsConfig.Duplicates).
+	DuplicateLines int64 `json:"duplicate_lines"`
+	// FilesVanished counts the log files skipped as removed between the listing of the directory and their opening
+	// (see LogsConfig.SkipVanished).
+	FilesVanished int64 `json:"files_vanished"`
+}
+
+// Snapshot returns the counts of the metrics so far.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	if m == nil {
+		return MetricsSnapshot{}
+	}
+	return MetricsSnapshot{
+		FilesScanned:   atomic.LoadInt64(&m.filesScanned),
+		BytesRead:      atomic.LoadInt64(&m.bytesRead),
+		SearchProbes:   atomic.LoadInt64(&m.searchProbes),
+		LinesSkipped:   atomic.LoadInt64(&m.linesSkipped),
+		DuplicateLines: atomic.LoadInt64(&m.duplicateLines),
+		FilesVanished:  atomic.LoadInt64(&m.filesVanished),
+	}
+}
+
+// scanned counts a log file opened.
+func (m *Metrics) scanned() {
+	if m != nil {
+		atomic.AddInt64(&m.filesScanned, 1)
+	}
+}
+
+// read counts a given number of bytes read from a log file.
+func (m *Metrics) read(n int64) {
+	if m != nil && n > 0 {
+		atomic.AddInt64(&m.bytesRead, n)
+	}
+}
+
+// probed counts a log line read by a binary search.
+func (m *Metrics) probed() {
+	if m != nil {
+		atomic.AddInt64(&m.searchProbes, 1)
+	}
+}
+
+// skipped counts a log line left out.
+func (m *Metrics) skipped() {
+	if m != nil {
+		atomic.AddInt64(&m.linesSkipped, 1)
+	}
+}
+
+// duplicated counts a log line duplicated across files.
+func (m *Metrics) duplicated() {
+	if m != nil {
+		atomic.AddInt64(&m.duplicateLines, 1)
+	}
+}
+
+// vanished counts a log file skipped as removed since listed.
+func (m *Metrics) vanished() {
+	if m != nil {
+		atomic.AddInt64(&m.filesVanished, 1)
+	}
+}
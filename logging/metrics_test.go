@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const metricsDataDir = "test/metrics"
+
+// metricsLineLength is the length of the log lines of the tests, line ending included.
+const metricsLineLength = 67
+
+type metricsSuite struct {
+	suite.Suite
+	testTime time.Time
+	// size is the size of the current log file
+	size int64
+}
+
+func (s *metricsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	files := []string{
+		`10.0.0.1 - - [03/Mar/2022:02:20:00 +0000] "GET /a HTTP/1.1" 200 10
+`,
+		`10.0.0.1 - - [03/Mar/2022:02:30:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:40:00 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:02:42:00 +0000] "GET /c HTTP/1.1" 200 10
+10.0.0.4 - - [03/Mar/2022:02:44:00 +0000] "GET /d HTTP/1.1" 200 10
+`,
+	}
+	for i, logs := range files {
+		name := filepath.Join(metricsDataDir, []string{"access.log.1", "access.log"}[i])
+		modTime := []time.Time{t.Add(-20 * time.Minute), t.Add(-time.Minute)}[i]
+		writeLogFile(s.T(), name, logs, modTime)
+		s.size = int64(len(logs))
+	}
+}
+
+func (s *metricsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(metricsDataDir)))
+}
+
+func (s *metricsSuite) Test_Metrics_ForEach() {
+	metrics := &Metrics{}
+	logs, err := New(WithDirectory(metricsDataDir), WithWindow(10*time.Minute), WithEnd(s.testTime),
+		WithPathPrefix("/c"), WithMetrics(metrics))
+	s.Require().NoError(err)
+
+	var ips []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		ips = append(ips, entry.IP)
+		return nil
+	}))
+
+	s.Equal([]string{"10.0.0.3"}, ips)
+	snapshot := metrics.Snapshot()
+	s.Equal(int64(1), snapshot.FilesScanned)
+	s.Positive(snapshot.SearchProbes)
+	s.GreaterOrEqual(snapshot.BytesRead, s.size-metricsLineLength)
+	// the logs of /b and /d are filtered out
+	s.Equal(int64(2), snapshot.LinesSkipped)
+}
+
+func (s *metricsSuite) Test_Metrics_Print() {
+	metrics := &Metrics{}
+	logs, err := New(WithDirectory(metricsDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithMetrics(metrics))
+	s.Require().NoError(err)
+
+	s.Require().NoError(logs.Print(context.Background(), io.Discard))
+
+	// the bytes copied as is are counted too
+	snapshot := metrics.Snapshot()
+	s.Equal(int64(2), snapshot.FilesScanned)
+	s.GreaterOrEqual(snapshot.BytesRead, s.size+metricsLineLength)
+	s.Zero(snapshot.LinesSkipped)
+}
+
+func (s *metricsSuite) Test_Metrics_Nil() {
+	var metrics *Metrics
+
+	metrics.scanned()
+	metrics.read(10)
+	metrics.probed()
+	metrics.skipped()
+	metrics.vanished()
+
+	s.Equal(MetricsSnapshot{}, metrics.Snapshot())
+}
+
+func TestMetrics(t *testing.T) {
+	suite.Run(t, new(metricsSuite))
+}
@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime/debug"
+)
+
+// errMappingFault is returned when the memory mapping of a file cannot be read,
+// most likely because the file was truncated while mapped (e.g. rotated by copytruncate).
+var errMappingFault = errors.New("memory mapped file truncated while being read")
+
+// mapping is the read-only memory mapping of a file, along with the position
+// of the file cursor, which is shared by the copies of the File.
+type mapping struct {
+	data []byte
+	pos  int64
+}
+
+// MapFile wraps an os.File the same way NewFormatFile does, reading it through a read-only memory mapping
+// of its current content: seeking is free and reading doesn't go through syscalls, which makes searching and streaming
+// large files faster. The bytes appended to the file after it was mapped are read from the file as usual.
+// The file is read as usual when it cannot be mapped, e.g. when empty or on platforms without memory mapping.
+// The File must be closed instead of the os.File, so the mapping is released.
+func MapFile(file *os.File, format Format) File {
+	f := NewFormatFile(file, format)
+	stat, err := file.Stat()
+	if err != nil || !stat.Mode().IsRegular() || stat.Size() == 0 || int64(int(stat.Size())) != stat.Size() {
+		return f
+	}
+	data, err := mmap(file, stat.Size())
+	if err != nil {
+		return f
+	}
+	f.mapping = &mapping{data: data}
+	return f
+}
+
+// Read reads from the file cursor, through the memory mapping of the file when mapped (see MapFile),
+// waiting for the bytes read when the reads of the logs are limited (see LogsConfig.MaxReadRate)
+// and failing once they read too many bytes (see LogsConfig.MaxBytesRead).
+func (file File) Read(p []byte) (int, error) {
+	if file.mapping == nil {
+		n, err := file.LogFile.Read(p)
+		file.limiter.wait(n)
+		file.metrics.read(int64(n))
+		if budgetErr := file.budget.spend(n); budgetErr != nil {
+			return n, budgetErr
+		}
+		return n, err
+	}
+	n, err := file.ReadAt(p, file.mapping.pos)
+	file.mapping.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt reads at a given offset the way Read does, without moving the file cursor.
+func (file File) ReadAt(p []byte, offset int64) (int, error) {
+	n, err := file.readAt(p, offset)
+	file.limiter.wait(n)
+	file.metrics.read(int64(n))
+	if budgetErr := file.budget.spend(n); budgetErr != nil {
+		return n, budgetErr
+	}
+	return n, err
+}
+
+// readAt reads at a given offset the way ReadAt does, without limiting the bytes read.
+func (file File) readAt(p []byte, offset int64) (int, error) {
+	if file.mapping == nil {
+		return file.LogFile.ReadAt(p, offset)
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	if size := int64(len(file.mapping.data)); offset < size {
+		var err error
+		if n, err = file.mapping.copyAt(p, offset); err != nil {
+			return n, err
+		}
+		if n == len(p) {
+			return n, nil
+		}
+	}
+	// the bytes appended since the file was mapped
+	m, err := file.LogFile.ReadAt(p[n:], offset+int64(n))
+	return n + m, err
+}
+
+// Seek sets the file cursor, which is only a position inside the memory mapping of the file when mapped (see MapFile).
+func (file File) Seek(offset int64, whence int) (int64, error) {
+	if file.mapping == nil {
+		return file.LogFile.Seek(offset, whence)
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += file.mapping.pos
+	case io.SeekEnd:
+		stat, err := file.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += stat.Size()
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	file.mapping.pos = offset
+	return offset, nil
+}
+
+// Close releases the memory mapping of the file, if any, and closes the file.
+func (file File) Close() error {
+	if file.mapping != nil && file.mapping.data != nil {
+		_ = munmap(file.mapping.data)
+		file.mapping.data = nil
+	}
+	return file.LogFile.Close()
+}
+
+// copyAt copies the mapped bytes from a given offset, failing instead of crashing
+// when the pages are gone because the file was truncated while mapped.
+func (m *mapping) copyAt(p []byte, offset int64) (n int, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if recover() != nil {
+			n, err = 0, errMappingFault
+		}
+	}()
+	return copy(p, m.data[offset:]), nil
+}
+
+// lastNewLine returns the offset of the last newline of the mapped bytes before a given offset, or -1 if there's none,
+// failing the same way copyAt does.
+func (m *mapping) lastNewLine(offset int64) (i int64, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if recover() != nil {
+			i, err = -1, errMappingFault
+		}
+	}()
+	return int64(bytes.LastIndexByte(m.data[:offset], '\n')), nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package logging
+
+import (
+	"errors"
+	"os"
+)
+
+// mmap fails on the platforms without memory mapping support, the files being read as usual.
+func mmap(*os.File, int64) ([]byte, error) {
+	return nil, errors.New("memory mapping not supported")
+}
+
+// munmap does nothing on the platforms without memory mapping support.
+func munmap([]byte) error {
+	return nil
+}
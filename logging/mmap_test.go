@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const mmapDataDir = "test/mmap"
+
+type mmapSuite struct {
+	suite.Suite
+	testTime time.Time
+	logs     string
+}
+
+func (s *mmapSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	var logs strings.Builder
+	for i := 0; i < 2000; i++ {
+		logs.WriteString(fmt.Sprintf("10.0.0.%d - - [%s] \"GET /%s HTTP/1.1\" 200 %d\n",
+			i%10, t.Add(-time.Hour).Add(time.Duration(i)*2*time.Second).Format(dateTimeFormat), strings.Repeat("a", i%300), i))
+	}
+	s.logs = logs.String()
+	writeLogFile(s.T(), filepath.Join(mmapDataDir, "access.log"), s.logs, t)
+}
+
+func (s *mmapSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(mmapDataDir)))
+}
+
+// open opens a copy of the log file, memory mapped or not.
+func (s *mmapSuite) open(mapped bool) File {
+	name := filepath.Join(s.T().TempDir(), "access.log")
+	s.Require().NoError(os.WriteFile(name, []byte(s.logs), 0666))
+	f, err := os.Open(name)
+	s.Require().NoError(err)
+	file := NewFile(f)
+	if mapped {
+		file = MapFile(f, FormatCommon)
+		if file.mapping == nil {
+			s.T().Skip("memory mapping not supported")
+		}
+	}
+	s.T().Cleanup(func() { _ = file.Close() })
+	return file
+}
+
+func (s *mmapSuite) Test_MapFile_Read() {
+	file := s.open(true)
+
+	data, err := ioutil.ReadAll(file)
+	s.Require().NoError(err)
+	s.Equal(s.logs, string(data))
+
+	buf := make([]byte, 10)
+	n, err := file.ReadAt(buf, 5)
+	s.Require().NoError(err)
+	s.Equal(s.logs[5:15], string(buf[:n]))
+	_, err = file.ReadAt(buf, int64(len(s.logs))-5)
+	s.ErrorIs(err, io.EOF)
+
+	offset, err := file.Seek(-10, io.SeekEnd)
+	s.Require().NoError(err)
+	s.Equal(int64(len(s.logs))-10, offset)
+	offset, err = file.Seek(4, io.SeekCurrent)
+	s.Require().NoError(err)
+	s.Equal(int64(len(s.logs))-6, offset)
+	rest, err := ioutil.ReadAll(file)
+	s.Require().NoError(err)
+	s.Equal(s.logs[len(s.logs)-6:], string(rest))
+}
+
+func (s *mmapSuite) Test_MapFile_Appended() {
+	file := s.open(true)
+	f, err := os.OpenFile(file.Name(), os.O_APPEND|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	_, err = f.WriteString("appended\n")
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+
+	// the bytes appended after the file was mapped are read from the file
+	buf := make([]byte, 20)
+	n, err := file.ReadAt(buf, int64(len(s.logs))-11)
+	s.Require().NoError(err)
+	s.Equal(s.logs[len(s.logs)-11:]+"appended\n", string(buf[:n]))
+	_, err = file.Seek(0, io.SeekStart)
+	s.Require().NoError(err)
+	data, err := ioutil.ReadAll(file)
+	s.Require().NoError(err)
+	s.Equal(s.logs+"appended\n", string(data))
+}
+
+func (s *mmapSuite) Test_MapFile_Truncated() {
+	file := s.open(true)
+	s.Require().NoError(os.Truncate(file.Name(), 0))
+
+	// the pages are gone, which fails the reading instead of crashing
+	buf := make([]byte, 10)
+	_, err := file.ReadAt(buf, int64(len(s.logs))-10)
+	s.ErrorIs(err, errMappingFault)
+	_, err = file.lineStart(int64(len(s.logs)) - 10)
+	s.ErrorIs(err, errMappingFault)
+}
+
+func (s *mmapSuite) Test_MapFile_Empty() {
+	name := filepath.Join(s.T().TempDir(), "empty.log")
+	s.Require().NoError(os.WriteFile(name, nil, 0666))
+	f, err := os.Open(name)
+	s.Require().NoError(err)
+	file := MapFile(f, FormatCommon)
+	defer func() { s.NoError(file.Close()) }()
+	s.Nil(file.mapping)
+}
+
+func (s *mmapSuite) Test_MapFile_SameAsFile() {
+	file, mapped := s.open(false), s.open(true)
+
+	for _, offset := range []int64{0, 1, 100, 4095, 4096, 50000, int64(len(s.logs)) - 1, int64(len(s.logs))} {
+		expected, err := file.lineStart(offset)
+		s.Require().NoError(err)
+		actual, err := mapped.lineStart(offset)
+		s.Require().NoError(err)
+		s.Equal(expected, actual, offset)
+	}
+
+	for _, lookupTime := range []time.Time{s.testTime.Add(-2 * time.Hour), s.testTime.Add(-30 * time.Minute), s.testTime} {
+		expected, err := file.IndexTime(context.Background(), lookupTime)
+		s.Require().NoError(err)
+		actual, err := mapped.IndexTime(context.Background(), lookupTime)
+		s.Require().NoError(err)
+		s.Equal(expected, actual, lookupTime)
+	}
+}
+
+func (s *mmapSuite) Test_Logs_WithMemoryMap() {
+	read := func(opts ...Option) []LogEntry {
+		logs, err := New(append([]Option{WithDirectory(mmapDataDir), WithWindow(30 * time.Minute)}, opts...)...)
+		s.Require().NoError(err)
+		logs.nowMinusT = func() time.Time {
+			return s.testTime.Add(-30 * time.Minute)
+		}
+		var entries []LogEntry
+		s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+			entries = append(entries, entry)
+			return nil
+		}))
+		return entries
+	}
+
+	expected := read()
+	s.Require().NotEmpty(expected)
+	s.Equal(expected, read(WithMemoryMap()))
+	s.Equal(expected, read(WithMemoryMap(), WithWorkers(2)))
+}
+
+func TestMmap(t *testing.T) {
+	suite.Run(t, new(mmapSuite))
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package logging
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmap maps the first size bytes of a given file into memory, read-only.
+func mmap(file *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// munmap releases a memory mapping made by mmap.
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"sort"
+)
+
+// NotFound counts the requests answered with 404 Not Found within the last N minutes by path,
+// along with the referers they came from, to find broken links and misconfigured clients.
+type NotFound struct {
+	paths map[string]*notFoundPath
+}
+
+// NotFoundPath is the number of requests of a path answered with 404 Not Found, along with their most frequent referers.
+type NotFoundPath struct {
+	Path     string  `json:"path"`
+	Count    int64   `json:"count"`
+	Referers []Count `json:"referers"`
+}
+
+type notFoundPath struct {
+	count    int64
+	referers map[string]int64
+}
+
+// NewNotFound creates an empty NotFound.
+func NewNotFound() *NotFound {
+	return &NotFound{paths: make(map[string]*notFoundPath)}
+}
+
+// NotFound reads the log entries that happened within the last N minutes and counts the ones answered with 404 Not Found.
+// The counts are returned even along with an error (e.g. ErrNoFilesInWindow), accounting for the entries read until then.
+func (logs *Logs) NotFound(ctx context.Context) (*NotFound, error) {
+	nf := NewNotFound()
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		nf.Add(entry)
+		return nil
+	})
+
+	return nf, err
+}
+
+// Add counts a given log entry when it was answered with 404 Not Found.
+// The referers are only known for the FormatCombined format.
+func (nf *NotFound) Add(entry LogEntry) {
+	if entry.IP == "" || entry.Status != http.StatusNotFound {
+		return
+	}
+
+	p, ok := nf.paths[entry.Path]
+	if !ok {
+		p = &notFoundPath{referers: make(map[string]int64)}
+		nf.paths[entry.Path] = p
+	}
+	p.count++
+	if entry.Referer != "" {
+		p.referers[entry.Referer]++
+	}
+}
+
+// Result returns all the paths answered with 404 Not Found along with all their referers, see Ranked.
+func (nf *NotFound) Result() interface{} {
+	return nf.Ranked(0, 0)
+}
+
+// Ranked returns the n most frequent paths answered with 404 Not Found, or all of them when n is not positive,
+// each along with its given number of most frequent referers (all of them when not positive). Ties are ordered by path.
+func (nf *NotFound) Ranked(n, referers int) []NotFoundPath {
+	paths := make([]NotFoundPath, 0, len(nf.paths))
+	for path, p := range nf.paths {
+		paths = append(paths, NotFoundPath{Path: path, Count: p.count})
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Count == paths[j].Count {
+			return paths[i].Path < paths[j].Path
+		}
+		return paths[i].Count > paths[j].Count
+	})
+	if n > 0 && n < len(paths) {
+		paths = paths[:n]
+	}
+	for i := range paths {
+		paths[i].Referers = rank(nf.paths[paths[i].Path].referers, referers)
+	}
+
+	return paths
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const notFoundDataDir = "test/notfound"
+
+type notFoundSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *notFoundSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(notFoundDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /old-page HTTP/1.1" 404 10 "https://example.com/blog" "Mozilla/5.0"
+10.0.0.2 - - [03/Mar/2022:02:44:15 +0000] "GET /old-page HTTP/1.1" 404 10 "https://example.com/blog" "Mozilla/5.0"
+10.0.0.3 - - [03/Mar/2022:02:44:20 +0000] "GET /old-page HTTP/1.1" 404 10 "-" "Mozilla/5.0"
+10.0.0.1 - - [03/Mar/2022:02:44:25 +0000] "GET /favicon.ico HTTP/1.1" 404 10 "https://example.com/" "Mozilla/5.0"
+10.0.0.1 - - [03/Mar/2022:02:44:30 +0000] "GET /blog HTTP/1.1" 200 10 "https://example.com/" "Mozilla/5.0"
+10.0.0.4 - - [03/Mar/2022:02:44:35 +0000] "GET /wp-login.php HTTP/1.1" 404 10 "-" "curl/7.79.1"
+this line cannot be parsed
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(notFoundDataDir), WithFormat(FormatCombined))
+}
+
+func (s *notFoundSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(notFoundDataDir)))
+}
+
+func (s *notFoundSuite) Test_NotFound() {
+	nf, err := s.logs.NotFound(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal([]NotFoundPath{
+		{
+			Path:     "/old-page",
+			Count:    3,
+			Referers: []Count{{Value: "https://example.com/blog", Count: 2}},
+		},
+		{
+			Path:     "/favicon.ico",
+			Count:    1,
+			Referers: []Count{{Value: "https://example.com/", Count: 1}},
+		},
+	}, nf.Ranked(2, 1))
+	s.Len(nf.Ranked(0, 0), 3)
+	s.Len(nf.Ranked(0, 0)[0].Referers, 2)
+}
+
+func (s *notFoundSuite) Test_NotFound_Empty() {
+	s.Empty(NewNotFound().Ranked(10, 3))
+}
+
+func TestNotFound(t *testing.T) {
+	suite.Run(t, new(notFoundSuite))
+}
@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenLogFile_NoAtime(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "access.log")
+	require.NoError(t, os.WriteFile(file, []byte("10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] \"GET / HTTP/1.1\" 200 10\n"), 0666))
+	// an access time older than the modified time would be updated by any read, even on relatime mounts
+	accessed, modified := time.Now().Add(-48*time.Hour), time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(file, accessed, modified))
+
+	f, err := openLogFile(file)
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	stat := info.Sys().(*syscall.Stat_t)
+	require.Equal(t, accessed.Unix(), stat.Atim.Sec)
+}
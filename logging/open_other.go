@@ -0,0 +1,11 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package logging
+
+import "os"
+
+// openLogFile opens a log file for reading, which never prevents it from being renamed or deleted, e.g. when rotated.
+func openLogFile(name string) (*os.File, error) {
+	return os.Open(name)
+}
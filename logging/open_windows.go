@@ -0,0 +1,24 @@
+//go:build windows
+// +build windows
+
+package logging
+
+import (
+	"os"
+	"syscall"
+)
+
+// openLogFile opens a log file for reading, sharing it for deletion on top of reading and writing: unlike os.Open,
+// it doesn't prevent Apache (or rotatelogs) from renaming or deleting the file while it's read, e.g. when rotating it.
+func openLogFile(name string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.NewFile(uintptr(h), name), nil
+}
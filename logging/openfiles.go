@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// openFiles keeps the cursors of a stream with an open file, the most recently read first, closing the least recently
+// read ones once there are more than a maximum of them (see LogsConfig.MaxOpenFiles). Their files are opened again
+// once read again, their lines being read from where they stopped.
+type openFiles struct {
+	max     int
+	cursors *list.List
+}
+
+// newOpenFiles keeps the open files of the cursors of a stream under a given maximum, or returns nil when unlimited.
+func newOpenFiles(max int) *openFiles {
+	if max <= 0 {
+		return nil
+	}
+	return &openFiles{max: max, cursors: list.New()}
+}
+
+// opened accounts for a cursor which file was just opened, closing the files of the least recently read cursors
+// but this one while there are too many.
+func (o *openFiles) opened(c *cursor) {
+	if o == nil {
+		return
+	}
+	c.lru = o.cursors.PushFront(c)
+	for o.cursors.Len() > o.max {
+		back := o.cursors.Back()
+		lru := back.Value.(*cursor)
+		if lru == c {
+			return
+		}
+		o.cursors.Remove(back)
+		lru.lru = nil
+		lru.park()
+	}
+}
+
+// use makes sure the file of a given cursor is open before reading it, opening it again when it was closed.
+func (o *openFiles) use(logs *Logs, c *cursor) error {
+	if o == nil {
+		return nil
+	}
+	if !c.parked {
+		o.cursors.MoveToFront(c.lru)
+		return nil
+	}
+	if err := c.unpark(logs); err != nil {
+		return err
+	}
+	o.opened(c)
+	return nil
+}
+
+// closed forgets a given cursor once its file is closed for good.
+func (o *openFiles) closed(c *cursor) {
+	if o == nil || c.lru == nil {
+		return
+	}
+	o.cursors.Remove(c.lru)
+	c.lru = nil
+}
+
+// park closes the file of the cursor to stay under the maximum of open files (see LogsConfig.MaxOpenFiles),
+// remembering the file so the very same one is opened again.
+func (c *cursor) park() {
+	info, err := c.file.Stat()
+	if err == nil {
+		c.info = info
+	}
+	_ = c.file.Close()
+	c.parked = true
+	if c.logger != nil {
+		c.logger.Debug("file closed", "file", c.file.Name(), "offset", c.offset, "reason", "max open files")
+	}
+}
+
+// unpark opens the file of the cursor again once parked, the lines being read from where they stopped.
+// It fails when the file is not the same anymore, e.g. renamed by a rotation in between.
+func (c *cursor) unpark(logs *Logs) error {
+	file, err := logs.reopen(c.file.Name())
+	if err != nil {
+		return err
+	}
+	if c.info != nil {
+		info, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		if !os.SameFile(c.info, info) {
+			_ = file.Close()
+			return fmt.Errorf("%s was replaced (e.g. rotated) while closed to stay under the maximum of open files", c.file.Name())
+		}
+	}
+	c.file, c.parked = file, false
+	c.reader.Reset(contextReader{ctx: c.readCtx, r: io.NewSectionReader(c.file, c.offset, math.MaxInt64-c.offset)})
+	if c.logger != nil {
+		c.logger.Debug("file reopened", "file", c.file.Name(), "offset", c.offset)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const openFilesDataDir = "test/openfiles"
+
+type openFilesSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *openFilesSuite) SetupTest() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:10:00 +0000")
+	s.testTime = t
+	// one file per virtual host, which lines interleave
+	for i, host := range []string{"a", "b", "c"} {
+		var lines strings.Builder
+		for minute := 0; minute < 3; minute++ {
+			lines.WriteString(fmt.Sprintf("10.0.0.1 - - [03/Mar/2022:10:0%d:%02d +0000] \"GET /%s%d HTTP/1.1\" 200 10\n", minute, i*10, host, minute))
+		}
+		writeLogFile(s.T(), filepath.Join(openFilesDataDir, host+".log"), lines.String(), t)
+	}
+}
+
+func (s *openFilesSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(openFilesDataDir)))
+}
+
+// paths returns the paths of the log entries merged with the given options, along with the metrics of the reading.
+func (s *openFilesSuite) paths(opts ...Option) ([]string, MetricsSnapshot) {
+	metrics := &Metrics{}
+	logs, err := New(append([]Option{WithDirectory(openFilesDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithMerge(), WithMetrics(metrics)}, opts...)...)
+	s.Require().NoError(err)
+	var paths []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+	return paths, metrics.Snapshot()
+}
+
+func (s *openFilesSuite) Test_MaxOpenFiles_Merge() {
+	expected, _ := s.paths()
+	s.Equal([]string{"/a0", "/b0", "/c0", "/a1", "/b1", "/c1", "/a2", "/b2", "/c2"}, expected)
+
+	logger := &recordingLogger{}
+	paths, metrics := s.paths(WithMaxOpenFiles(1), WithLogger(logger))
+	s.Equal(expected, paths)
+	// the files opened again are not scanned once more
+	s.Equal(int64(3), metrics.FilesScanned)
+	s.Contains(logger.messages, "DEBUG file reopened file="+filepath.Join(openFilesDataDir, "a.log")+" offset=68")
+}
+
+func (s *openFilesSuite) Test_MaxOpenFiles_Replaced() {
+	logs, err := New(WithDirectory(openFilesDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithMerge(), WithMaxOpenFiles(1))
+	s.Require().NoError(err)
+	it := logs.Entries(context.Background())
+	defer func() { _ = it.Close() }()
+	s.Require().True(it.Next())
+	s.Equal("/a0", it.Entry().Path)
+
+	// a.log is closed, only the last file opened being kept open, then rotated
+	s.Require().NoError(os.Rename(filepath.Join(openFilesDataDir, "a.log"), filepath.Join(openFilesDataDir, "a.log.1")))
+	writeLogFile(s.T(), filepath.Join(openFilesDataDir, "a.log"), "", s.testTime)
+	s.False(it.Next())
+	s.Require().Error(it.Err())
+	s.Contains(it.Err().Error(), "a.log was replaced (e.g. rotated) while closed to stay under the maximum of open files")
+}
+
+func (s *openFilesSuite) Test_MaxOpenFiles_Follow() {
+	logs, err := New(WithDirectory(openFilesDataDir), WithMaxOpenFiles(1))
+	s.Require().NoError(err)
+	f := &follower{logs: logs}
+	defer f.close()
+	var paths []string
+	fn := func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}
+
+	s.Require().NoError(f.poll(true, fn))
+	s.Equal(1, f.opened)
+
+	// a file closed once read until its end is opened again once grown
+	file, err := os.OpenFile(filepath.Join(openFilesDataDir, "a.log"), os.O_APPEND|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	_, err = file.WriteString("10.0.0.1 - - [03/Mar/2022:10:05:00 +0000] \"GET /a5 HTTP/1.1\" 200 10\n")
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+	s.Require().NoError(f.poll(false, fn))
+	s.Equal([]string{"/a5"}, paths)
+	s.Equal(1, f.opened)
+
+	f.close()
+	s.Zero(f.opened)
+}
+
+func (s *openFilesSuite) Test_MaxOpenFiles_Invalid() {
+	_, err := New(WithDirectory(openFilesDataDir), WithMaxOpenFiles(-1))
+	s.EqualError(err, "invalid max open files: must not be negative")
+}
+
+func TestOpenFilesSuite(t *testing.T) {
+	suite.Run(t, new(openFilesSuite))
+}
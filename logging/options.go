@@ -0,0 +1,489 @@
+package logging
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Option configures the Logs created by New.
+type Option func(cfg *LogsConfig)
+
+// Filter reports whether a log entry should be streamed.
+type Filter func(entry LogEntry) bool
+
+// WithConfig sets the whole configuration at once, overriding the options given before it.
+func WithConfig(cfg LogsConfig) Option {
+	return func(c *LogsConfig) {
+		*c = cfg
+	}
+}
+
+// WithDirectory sets the directory where all the log files are stored.
+func WithDirectory(dir string) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Directory = dir
+	}
+}
+
+// WithFS sets the file system the log files are read from instead of the one of the operating system, the directory
+// being a path within it (e.g. "."), see LogsConfig.FS.
+func WithFS(fsys fs.FS) Option {
+	return func(cfg *LogsConfig) {
+		cfg.FS = fsys
+	}
+}
+
+// WithWindow sets the time range to look for logs in, e.g. the last 5 minutes.
+func WithWindow(window time.Duration) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Window = window
+	}
+}
+
+// WithEnd sets the end of the time range to look for logs in, see LogsConfig.End.
+func WithEnd(end time.Time) Option {
+	return func(cfg *LogsConfig) {
+		cfg.End = end
+	}
+}
+
+// WithClock sets the clock giving the current time, see LogsConfig.Clock.
+func WithClock(now func() time.Time) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Clock = now
+	}
+}
+
+// WithTimeZone sets the time zone of the log times which don't give theirs, see LogsConfig.TimeZone.
+func WithTimeZone(loc *time.Location) Option {
+	return func(cfg *LogsConfig) {
+		cfg.TimeZone = loc
+	}
+}
+
+// WithFormat sets the format of the log lines, see LogsConfig.Format.
+func WithFormat(format Format) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Format = format
+	}
+}
+
+// WithMetrics counts the work of the reader in given metrics, see LogsConfig.Metrics.
+func WithMetrics(metrics *Metrics) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Metrics = metrics
+	}
+}
+
+// WithErrorSummary counts the invalid log lines read in a given summary, see LogsConfig.Errors.
+func WithErrorSummary(summary *ErrorSummary) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Errors = summary
+	}
+}
+
+// WithFormats sets the formats of the log files which names match given patterns, see LogsConfig.Formats.
+func WithFormats(patterns ...FormatPattern) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Formats = patterns
+	}
+}
+
+// WithFollowSymlinks makes the symlinked log files part of the lookup, see LogsConfig.FollowSymlinks.
+func WithFollowSymlinks() Option {
+	return func(cfg *LogsConfig) {
+		cfg.FollowSymlinks = true
+	}
+}
+
+// WithOrderByContent orders the log files by the times of their logs, see LogsConfig.OrderByContent.
+func WithOrderByContent() Option {
+	return func(cfg *LogsConfig) {
+		cfg.OrderByContent = true
+	}
+}
+
+// WithMerge interleaves the logs of all the files by their times, see LogsConfig.Merge.
+func WithMerge() Option {
+	return func(cfg *LogsConfig) {
+		cfg.Merge = true
+	}
+}
+
+// WithDeterministicOrder orders the log lines with the same time by the paths of their files,
+// see LogsConfig.DeterministicOrder.
+func WithDeterministicOrder() Option {
+	return func(cfg *LogsConfig) {
+		cfg.DeterministicOrder = true
+	}
+}
+
+// WithTolerance sets the number of bytes to check for logs written out of order, see LogsConfig.Tolerance.
+func WithTolerance(tolerance int64) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Tolerance = tolerance
+	}
+}
+
+// WithIndex searches the log files using sidecar index files of the given interval, see LogsConfig.IndexInterval.
+func WithIndex(interval time.Duration) Option {
+	return func(cfg *LogsConfig) {
+		cfg.IndexInterval = interval
+	}
+}
+
+// WithIndexBloom makes the index files store Bloom filters of the IPs and path prefixes, see LogsConfig.IndexBloom.
+func WithIndexBloom() Option {
+	return func(cfg *LogsConfig) {
+		cfg.IndexBloom = true
+	}
+}
+
+// WithWorkers parses the given number of files in parallel, see LogsConfig.Workers.
+func WithWorkers(workers int) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Workers = workers
+	}
+}
+
+// WithMemoryMap reads the log files through memory mappings, see LogsConfig.MemoryMap.
+func WithMemoryMap() Option {
+	return func(cfg *LogsConfig) {
+		cfg.MemoryMap = true
+	}
+}
+
+// WithMaxBytesRead fails the calls once they read more than a given number of bytes altogether, see LogsConfig.MaxBytesRead.
+func WithMaxBytesRead(bytes int64) Option {
+	return func(cfg *LogsConfig) {
+		cfg.MaxBytesRead = bytes
+	}
+}
+
+// WithMaxReadRate limits the bytes read from the log files per second, see LogsConfig.MaxReadRate.
+func WithMaxReadRate(bytesPerSecond int64) Option {
+	return func(cfg *LogsConfig) {
+		cfg.MaxReadRate = bytesPerSecond
+	}
+}
+
+// WithSharded reads the log files of the dated subdirectories of the directory too, see LogsConfig.Sharded.
+func WithSharded() Option {
+	return func(cfg *LogsConfig) {
+		cfg.Sharded = true
+	}
+}
+
+// WithFilenameTimePattern orders and selects the log files by the times embedded in their names,
+// see LogsConfig.FilenameTimePattern.
+func WithFilenameTimePattern(pattern string) Option {
+	return func(cfg *LogsConfig) {
+		cfg.FilenameTimePattern = pattern
+	}
+}
+
+// WithReadAhead sizes the batches of the files read ahead and their number queued, see LogsConfig.ReadAheadBatch.
+func WithReadAhead(batch, depth int) Option {
+	return func(cfg *LogsConfig) {
+		cfg.ReadAheadBatch, cfg.ReadAheadDepth = batch, depth
+	}
+}
+
+// WithBufferSizes sizes the buffers the log files are read through and the log lines are printed to,
+// see LogsConfig.ReadBufferSize and LogsConfig.WriteBufferSize.
+func WithBufferSizes(read, write int) Option {
+	return func(cfg *LogsConfig) {
+		cfg.ReadBufferSize, cfg.WriteBufferSize = read, write
+	}
+}
+
+// WithMaxTopValues limits the number of distinct values of every field counted by Top, see LogsConfig.MaxTopValues.
+func WithMaxTopValues(max int) Option {
+	return func(cfg *LogsConfig) {
+		cfg.MaxTopValues = max
+	}
+}
+
+// WithMaxOpenFiles limits the number of log files kept open at once, see LogsConfig.MaxOpenFiles.
+func WithMaxOpenFiles(max int) Option {
+	return func(cfg *LogsConfig) {
+		cfg.MaxOpenFiles = max
+	}
+}
+
+// WithSkipVanished skips the log files removed since the directory was listed, see LogsConfig.SkipVanished.
+func WithSkipVanished() Option {
+	return func(cfg *LogsConfig) {
+		cfg.SkipVanished = true
+	}
+}
+
+// WithCopyDir copies the log files into a given directory to read the copies instead, see LogsConfig.CopyDir.
+func WithCopyDir(dir string) Option {
+	return func(cfg *LogsConfig) {
+		cfg.CopyDir = dir
+	}
+}
+
+// WithPollInterval sets how often Follow checks for new logs, see LogsConfig.PollInterval.
+func WithPollInterval(interval time.Duration) Option {
+	return func(cfg *LogsConfig) {
+		cfg.PollInterval = interval
+	}
+}
+
+// WithHosts reads the log files of the given hosts instead of the ones of a single directory, see LogsConfig.Hosts.
+func WithHosts(hosts ...Host) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Hosts = append(cfg.Hosts, hosts...)
+	}
+}
+
+// WithCheckpoints resumes the shipping of the logs after the log lines shipped by the previous runs, see LogsConfig.Checkpoints.
+func WithCheckpoints(checkpoints *Checkpoints) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Checkpoints = checkpoints
+	}
+}
+
+// WithIP keeps the log entries of a given IP only, see LogsConfig.IP.
+func WithIP(ip string) Option {
+	return func(cfg *LogsConfig) {
+		cfg.IP = ip
+	}
+}
+
+// WithXFF reads the client IP of the log entries from their X-Forwarded-For header, believing the given trusted proxies,
+// see LogsConfig.XFF and LogsConfig.TrustedProxies.
+func WithXFF(xff XFF, trustedProxies ...*net.IPNet) Option {
+	return func(cfg *LogsConfig) {
+		cfg.XFF = xff
+		cfg.TrustedProxies = append(cfg.TrustedProxies, trustedProxies...)
+	}
+}
+
+// WithDuplicates reports or suppresses the log lines duplicated across files within a given window of log time,
+// see LogsConfig.Duplicates and LogsConfig.DuplicateWindow.
+func WithDuplicates(duplicates Duplicates, window time.Duration) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Duplicates = duplicates
+		cfg.DuplicateWindow = window
+	}
+}
+
+// WithAssertSorted guarantees the log entries are in order, holding back a given number of them to put them in order,
+// see LogsConfig.AssertSorted and LogsConfig.ReorderBuffer.
+func WithAssertSorted(reorderBuffer int) Option {
+	return func(cfg *LogsConfig) {
+		cfg.AssertSorted = true
+		cfg.ReorderBuffer = reorderBuffer
+	}
+}
+
+// WithPathPrefix keeps the log entries which path starts with a given prefix only, see LogsConfig.PathPrefix.
+func WithPathPrefix(prefix string) Option {
+	return func(cfg *LogsConfig) {
+		cfg.PathPrefix = prefix
+	}
+}
+
+// WithRefresh makes every call look at the log files created or rotated since New, see LogsConfig.Refresh.
+func WithRefresh() Option {
+	return func(cfg *LogsConfig) {
+		cfg.Refresh = true
+	}
+}
+
+// WithProgress reports the progress of every call to a given function, see LogsConfig.Progress.
+func WithProgress(fn func(Progress)) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Progress = fn
+	}
+}
+
+// WithLogger reports diagnostic messages to a given Logger, see LogsConfig.Logger.
+func WithLogger(logger Logger) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Logger = logger
+	}
+}
+
+// WithTracer traces the phases of every call with a given Tracer, see LogsConfig.Tracer.
+func WithTracer(tracer Tracer) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Tracer = tracer
+	}
+}
+
+// Middleware transforms a log entry (see LogsConfig.Middlewares), returning the log entry to stream in its place,
+// or false to drop it. Printing the log entries (see Logs.Print) writes their Line, so rewriting it rewrites the output.
+// The time of the log entries should be left as is, as it's how the files are searched and merged.
+type Middleware func(entry LogEntry) (LogEntry, bool)
+
+// WithMiddleware adds middlewares transforming every log entry in turn, after the ones added before.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Middlewares = append(cfg.Middlewares, middlewares...)
+	}
+}
+
+// WithFilter adds a filter every log entry has to match in order to be streamed.
+func WithFilter(filter Filter) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Filters = append(cfg.Filters, filter)
+	}
+}
+
+// InVHosts returns a Filter keeping the log entries of the given virtual hosts only,
+// ignoring the case of their names, see LogEntry.VHost.
+func InVHosts(vhosts ...string) Filter {
+	names := make(map[string]bool, len(vhosts))
+	for _, vhost := range vhosts {
+		names[strings.ToLower(vhost)] = true
+	}
+	return func(entry LogEntry) bool {
+		return names[strings.ToLower(entry.VHost)]
+	}
+}
+
+// StatusFilter parses a status code (e.g. 500) or a status code class (e.g. 5xx)
+// into a Filter keeping the log entries answered with it.
+func StatusFilter(status string) (Filter, error) {
+	if len(status) == 3 && strings.EqualFold(status[1:], "xx") && status[0] >= '1' && status[0] <= '5' {
+		class := int(status[0]-'0') * 100
+		return func(entry LogEntry) bool {
+			return entry.Status >= class && entry.Status < class+100
+		}, nil
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return nil, fmt.Errorf("invalid status '%s'", status)
+	}
+	return func(entry LogEntry) bool {
+		return entry.Status == code
+	}, nil
+}
+
+// validate makes sure the configuration is usable, before touching any log file.
+func (cfg LogsConfig) validate() error {
+	if cfg.Directory == "" && len(cfg.Hosts) == 0 {
+		return &ConfigError{Field: "directory", Reason: "must not be empty"}
+	}
+	names := make(map[string]bool, len(cfg.Hosts))
+	for _, host := range cfg.Hosts {
+		if host.Name == "" || host.Directory == "" {
+			return &ConfigError{Field: "hosts", Reason: "must all have a name and a directory"}
+		}
+		if names[host.Name] {
+			return &ConfigError{Field: "hosts", Reason: fmt.Sprintf("duplicate host '%s'", host.Name)}
+		}
+		names[host.Name] = true
+	}
+	if cfg.LastNMinutes < 0 || cfg.Window < 0 {
+		return &ConfigError{Field: "window", Reason: "must not be negative"}
+	}
+	if cfg.Tolerance < 0 {
+		return &ConfigError{Field: "tolerance", Reason: "must not be negative"}
+	}
+	if cfg.Workers < 0 {
+		return &ConfigError{Field: "workers", Reason: "must not be negative"}
+	}
+	if cfg.ReadAheadBatch < 0 {
+		return &ConfigError{Field: "read ahead batch", Reason: "must not be negative"}
+	}
+	if cfg.ReadAheadDepth < 0 {
+		return &ConfigError{Field: "read ahead depth", Reason: "must not be negative"}
+	}
+	if cfg.ReadBufferSize < 0 {
+		return &ConfigError{Field: "read buffer size", Reason: "must not be negative"}
+	}
+	if cfg.WriteBufferSize < 0 {
+		return &ConfigError{Field: "write buffer size", Reason: "must not be negative"}
+	}
+	if cfg.IndexInterval < 0 {
+		return &ConfigError{Field: "index interval", Reason: "must not be negative"}
+	}
+	if cfg.MaxReadRate < 0 {
+		return &ConfigError{Field: "max read rate", Reason: "must not be negative"}
+	}
+	if cfg.MaxBytesRead < 0 {
+		return &ConfigError{Field: "max bytes read", Reason: "must not be negative"}
+	}
+	if cfg.MaxTopValues < 0 {
+		return &ConfigError{Field: "max top values", Reason: "must not be negative"}
+	}
+	if cfg.MaxOpenFiles < 0 {
+		return &ConfigError{Field: "max open files", Reason: "must not be negative"}
+	}
+	if cfg.PollInterval < 0 {
+		return &ConfigError{Field: "poll interval", Reason: "must not be negative"}
+	}
+	if cfg.FS != nil && cfg.IndexInterval > 0 {
+		return &ConfigError{Field: "fs", Reason: "cannot be indexed, the index files being written next to the log files"}
+	}
+	if cfg.FS != nil && cfg.CopyDir != "" {
+		return &ConfigError{Field: "fs", Reason: "cannot be copied to a directory"}
+	}
+	if cfg.IndexBloom && cfg.IndexInterval == 0 {
+		return &ConfigError{Field: "index bloom", Reason: "requires an index interval"}
+	}
+	if cfg.XFF != "" {
+		if _, err := ParseXFF(string(cfg.XFF)); err != nil {
+			return &ConfigError{Field: "xff", Reason: "must be first or last-trusted"}
+		}
+	}
+	if cfg.Duplicates != "" {
+		if _, err := ParseDuplicates(string(cfg.Duplicates)); err != nil {
+			return &ConfigError{Field: "duplicates", Reason: "must be report or suppress"}
+		}
+	}
+	if cfg.DuplicateWindow < 0 {
+		return &ConfigError{Field: "duplicate window", Reason: "must not be negative"}
+	}
+	if cfg.ReorderBuffer < 0 {
+		return &ConfigError{Field: "reorder buffer", Reason: "must not be negative"}
+	}
+	if cfg.XFF == XFFLastTrusted && len(cfg.TrustedProxies) == 0 {
+		return &ConfigError{Field: "xff", Reason: "last-trusted requires trusted proxies"}
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if proxy == nil {
+			return &ConfigError{Field: "trusted proxy", Reason: "must not be nil"}
+		}
+	}
+	for _, middleware := range cfg.Middlewares {
+		if middleware == nil {
+			return &ConfigError{Field: "middleware", Reason: "must not be nil"}
+		}
+	}
+	for _, filter := range cfg.Filters {
+		if filter == nil {
+			return &ConfigError{Field: "filter", Reason: "must not be nil"}
+		}
+	}
+	if err := cfg.Format.validate(); err != nil {
+		return &ConfigError{Field: "format", Reason: err.Error(), Err: err}
+	}
+	for _, pattern := range cfg.Formats {
+		if err := pattern.validate(); err != nil {
+			return &ConfigError{Field: "formats", Reason: err.Error(), Err: err}
+		}
+	}
+	if cfg.FilenameTimePattern != "" {
+		if _, err := parseNameTimePattern(cfg.FilenameTimePattern); err != nil {
+			return &ConfigError{Field: "filename time pattern", Reason: err.Error(), Err: err}
+		}
+	}
+	return nil
+}
+
+// WithBots classifies the log entries as bots or humans using the given classifier, see LogsConfig.Bots.
+func WithBots(bots *BotClassifier) Option {
+	return func(cfg *LogsConfig) {
+		cfg.Bots = bots
+	}
+}
@@ -0,0 +1,269 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const optionsDataDir = "test/options"
+
+type optionsSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *optionsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(optionsDataDir, "http.log"), `10.0.0.1 - frank [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.0" 200 20
+10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.0" 500 20
+10.0.0.3 - frank [03/Mar/2022:02:44:30 +0000] "GET /c HTTP/1.0" 503 20
+`, t)
+}
+
+func (s *optionsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(optionsDataDir)))
+}
+
+func (s *optionsSuite) Test_New_Success() {
+	logs, err := New(
+		WithDirectory(optionsDataDir),
+		WithWindow(90*time.Second),
+		WithFormat(FormatCommon),
+		WithFollowSymlinks(),
+		WithOrderByContent(),
+		WithMerge(),
+		WithTolerance(1024),
+		WithIndex(10*time.Second),
+		WithIndexBloom(),
+		WithWorkers(4),
+		WithMemoryMap(),
+		WithRefresh(),
+		WithMaxReadRate(1<<20),
+	)
+
+	s.NoError(err)
+	s.Require().NotNil(logs)
+	s.Equal(LogsConfig{
+		Directory:      optionsDataDir,
+		Window:         90 * time.Second,
+		Format:         FormatCommon,
+		FollowSymlinks: true,
+		OrderByContent: true,
+		Merge:          true,
+		Tolerance:      1024,
+		IndexInterval:  10 * time.Second,
+		IndexBloom:     true,
+		Workers:        4,
+		MemoryMap:      true,
+		Refresh:        true,
+		MaxReadRate:    1 << 20,
+	}, logs.cfg)
+	s.NotNil(logs.limiter)
+	s.Len(logs.filesInfo, 1)
+}
+
+func (s *optionsSuite) Test_New_WithConfig() {
+	logs, err := New(
+		WithWindow(time.Hour),
+		WithConfig(LogsConfig{Directory: optionsDataDir, LastNMinutes: 5}),
+		WithMerge(),
+	)
+
+	s.NoError(err)
+	s.Require().NotNil(logs)
+	s.Equal(LogsConfig{Directory: optionsDataDir, LastNMinutes: 5, Merge: true}, logs.cfg)
+	s.Equal(5*time.Minute, logs.cfg.window())
+}
+
+func (s *optionsSuite) Test_New_Error() {
+	tests := []struct {
+		name        string
+		opts        []Option
+		expectedErr string
+	}{
+		{
+			name:        "Empty Directory",
+			opts:        []Option{WithWindow(time.Minute)},
+			expectedErr: "invalid directory: must not be empty",
+		},
+		{
+			name:        "Negative Window",
+			opts:        []Option{WithDirectory(optionsDataDir), WithWindow(-time.Minute)},
+			expectedErr: "invalid window: must not be negative",
+		},
+		{
+			name:        "Negative Minutes",
+			opts:        []Option{WithConfig(LogsConfig{Directory: optionsDataDir, LastNMinutes: -1})},
+			expectedErr: "invalid window: must not be negative",
+		},
+		{
+			name:        "Negative Tolerance",
+			opts:        []Option{WithDirectory(optionsDataDir), WithTolerance(-1)},
+			expectedErr: "invalid tolerance: must not be negative",
+		},
+		{
+			name:        "Negative Index Interval",
+			opts:        []Option{WithDirectory(optionsDataDir), WithIndex(-time.Second)},
+			expectedErr: "invalid index interval: must not be negative",
+		},
+		{
+			name:        "Negative Max Read Rate",
+			opts:        []Option{WithDirectory(optionsDataDir), WithMaxReadRate(-1)},
+			expectedErr: "invalid max read rate: must not be negative",
+		},
+		{
+			name:        "Negative Poll Interval",
+			opts:        []Option{WithDirectory(optionsDataDir), WithPollInterval(-time.Second)},
+			expectedErr: "invalid poll interval: must not be negative",
+		},
+		{
+			name:        "Index Bloom Without Index",
+			opts:        []Option{WithDirectory(optionsDataDir), WithIndexBloom()},
+			expectedErr: "invalid index bloom: requires an index interval",
+		},
+		{
+			name:        "Negative Workers",
+			opts:        []Option{WithDirectory(optionsDataDir), WithWorkers(-1)},
+			expectedErr: "invalid workers: must not be negative",
+		},
+		{
+			name:        "Nil Filter",
+			opts:        []Option{WithDirectory(optionsDataDir), WithFilter(nil)},
+			expectedErr: "invalid filter: must not be nil",
+		},
+		{
+			name:        "Nil Middleware",
+			opts:        []Option{WithDirectory(optionsDataDir), WithMiddleware(nil)},
+			expectedErr: "invalid middleware: must not be nil",
+		},
+		{
+			name:        "Unsupported XFF",
+			opts:        []Option{WithDirectory(optionsDataDir), WithXFF("last")},
+			expectedErr: "invalid xff: must be first or last-trusted",
+		},
+		{
+			name:        "XFF Last Trusted Without Trusted Proxies",
+			opts:        []Option{WithDirectory(optionsDataDir), WithXFF(XFFLastTrusted)},
+			expectedErr: "invalid xff: last-trusted requires trusted proxies",
+		},
+		{
+			name:        "Nil Trusted Proxy",
+			opts:        []Option{WithDirectory(optionsDataDir), WithXFF(XFFFirst, nil)},
+			expectedErr: "invalid trusted proxy: must not be nil",
+		},
+		{
+			name:        "Unsupported Format",
+			opts:        []Option{WithDirectory(optionsDataDir), WithFormat("xml")},
+			expectedErr: "invalid format: unsupported log format 'xml'",
+		},
+		{
+			name:        "Unsupported Formats",
+			opts:        []Option{WithDirectory(optionsDataDir), WithFormats(FormatPattern{Pattern: "error*", Format: "xml"})},
+			expectedErr: "invalid formats: unsupported log format 'xml'",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			logs, err := New(test.opts...)
+
+			s.EqualError(err, test.expectedErr)
+			var cfgErr *ConfigError
+			s.True(errors.As(err, &cfgErr))
+			s.Nil(logs)
+		})
+	}
+}
+
+func (s *optionsSuite) Test_WithFilter() {
+	buf := &bytes.Buffer{}
+	logs, err := New(
+		WithDirectory(optionsDataDir),
+		WithWindow(time.Minute),
+		WithFilter(func(entry LogEntry) bool { return entry.Status >= 500 }),
+		WithFilter(func(entry LogEntry) bool { return entry.Path != "/c" }),
+	)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-logs.cfg.window())
+	}
+
+	err = logs.Print(context.Background(), buf)
+
+	s.NoError(err)
+	s.Equal(`10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.0" 500 20
+`, buf.String())
+}
+
+func (s *optionsSuite) Test_WithMiddleware() {
+	for name, opts := range map[string][]Option{"Streamed": nil, "Parallel": {WithWorkers(2)}} {
+		s.Run(name, func() {
+			logs, err := New(append([]Option{
+				WithDirectory(optionsDataDir),
+				WithWindow(time.Minute),
+				// drops /a, then rewrites the paths the filters look at
+				WithMiddleware(func(entry LogEntry) (LogEntry, bool) { return entry, entry.Path != "/a" }),
+				WithMiddleware(func(entry LogEntry) (LogEntry, bool) {
+					entry.Path = "/items" + entry.Path
+					entry.Line = entry.IP + " " + entry.Path
+					return entry, true
+				}),
+				WithFilter(func(entry LogEntry) bool { return entry.Path != "/items/c" }),
+			}, opts...)...)
+			s.Require().NoError(err)
+			logs.nowMinusT = func() time.Time {
+				return s.testTime.Add(-logs.cfg.window())
+			}
+
+			var entries []LogEntry
+			err = logs.ForEach(context.Background(), func(entry LogEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+			s.NoError(err)
+			s.Require().Len(entries, 1)
+			s.Equal("/items/b", entries[0].Path)
+			buf := &bytes.Buffer{}
+			s.NoError(logs.Print(context.Background(), buf))
+			s.Equal("10.0.0.2 /items/b\n", buf.String())
+		})
+	}
+}
+
+func (s *optionsSuite) Test_InVHosts() {
+	filter := InVHosts("shop.example.com", "Blog.Example.com")
+
+	s.True(filter(LogEntry{VHost: "shop.example.com"}))
+	s.True(filter(LogEntry{VHost: "blog.example.com"}))
+	s.False(filter(LogEntry{VHost: "example.com"}))
+	s.False(filter(LogEntry{}))
+}
+
+func (s *optionsSuite) Test_StatusFilter() {
+	filter, err := StatusFilter("5xx")
+	s.Require().NoError(err)
+	s.True(filter(LogEntry{Status: 500}))
+	s.True(filter(LogEntry{Status: 503}))
+	s.False(filter(LogEntry{Status: 404}))
+
+	filter, err = StatusFilter("404")
+	s.Require().NoError(err)
+	s.True(filter(LogEntry{Status: 404}))
+	s.False(filter(LogEntry{Status: 400}))
+
+	for _, status := range []string{"", "6xx", "abc", "4x"} {
+		_, err = StatusFilter(status)
+		s.EqualError(err, "invalid status '"+status+"'")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	suite.Run(t, new(optionsSuite))
+}
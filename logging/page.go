@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Cursor is the position of a log entry within the logs paged through (see Logs.Page), made of the log file
+// and the offset of the entry along with its time, so the next page resumes right after it. It also fixes the end
+// of the time range of the first page, so all the pages read the same time range however long the paging takes.
+type Cursor struct {
+	File   string    `json:"file"`
+	Offset int64     `json:"offset"`
+	Time   time.Time `json:"time"`
+	End    time.Time `json:"end"`
+}
+
+// String returns the Cursor as an opaque token, safe for URLs, see ParseCursor.
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor parses a Cursor returned by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.End.IsZero() {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// CursorOf returns the Cursor of a given log entry within a time range ending at a given time, e.g. to resume
+// after any entry of a page rather than after the last one (see Logs.Page).
+func CursorOf(entry LogEntry, end time.Time) Cursor {
+	return Cursor{File: entry.File, Offset: entry.Offset, Time: entry.Time, End: end}
+}
+
+// Page calls the given function for at most a given number of the log entries that happened within the last N minutes,
+// in order, starting right after a given Cursor, or from the first entry without one. It returns the Cursor of
+// the last entry to get the next page with, or nil once there are none left. The time range of the first page
+// ends at the End of the configuration, or now (see Cursor.End).
+//
+// The pages are deterministic as long as the log files don't change: the entries of the same time are merged
+// in the order of their files, so the ones before the entry of the cursor are left out too. When the file
+// of the cursor is gone since (e.g. rotated), the entries of the time of the cursor are all read again.
+// Like ForEach, Page fails with ErrNoFilesInWindow when none of the files was modified after the cursor.
+func (logs *Logs) Page(ctx context.Context, after *Cursor, limit int, fn func(LogEntry) error) (*Cursor, error) {
+	if limit <= 0 {
+		return nil, errors.New("the size of a page must be positive")
+	}
+	cfg := logs.cfg
+	// the files are copied already, if told so
+	cfg.CopyDir = ""
+	cfg.End = cfg.end()
+	if after != nil {
+		cfg.End = after.End
+		if !after.Time.Before(cfg.End) {
+			return nil, nil
+		}
+		// the binary search skips the pages before
+		if start := cfg.End.Add(-cfg.window()); after.Time.After(start) {
+			cfg.Window = cfg.End.Sub(after.Time)
+		}
+	}
+	pageLogs, err := NewLogs(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	it := pageLogs.Entries(ctx)
+	defer func() { _ = it.Close() }()
+	skipper := &cursorSkipper{after: after}
+	var last *Cursor
+	n := 0
+	for it.Next() {
+		for _, entry := range skipper.next(it.Entry()) {
+			if n == limit {
+				// there are entries left, so the cursor of the last one is the one of the next page
+				return last, nil
+			}
+			if err := fn(entry); err != nil {
+				return nil, err
+			}
+			cursor := CursorOf(entry, cfg.End)
+			last = &cursor
+			n++
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	// the entries of the time of the cursor held back, if its file is gone
+	for _, entry := range skipper.flush() {
+		if n == limit {
+			return last, nil
+		}
+		if err := fn(entry); err != nil {
+			return nil, err
+		}
+		cursor := CursorOf(entry, cfg.End)
+		last = &cursor
+		n++
+	}
+	return nil, nil
+}
+
+// cursorSkipper leaves out the log entries up to the one of a Cursor, if any, holding back the ones of its time
+// until it is met, so they are all read again when it isn't (e.g. its file was rotated since).
+type cursorSkipper struct {
+	after  *Cursor
+	passed bool
+	held   []LogEntry
+}
+
+// next returns the log entries to read given the following one: none until the cursor is passed.
+func (s *cursorSkipper) next(entry LogEntry) []LogEntry {
+	switch {
+	case s.after == nil || s.passed:
+		return []LogEntry{entry}
+	case entry.Time.Before(s.after.Time):
+		return nil
+	case entry.Time.Equal(s.after.Time):
+		if entry.File == s.after.File && entry.Offset == s.after.Offset {
+			s.passed, s.held = true, nil
+			return nil
+		}
+		s.held = append(s.held, entry)
+		return nil
+	default:
+		// the cursor was not met, so the entries of its time are read again
+		s.passed = true
+		return append(s.flush(), entry)
+	}
+}
+
+// flush returns the log entries held back, if any.
+func (s *cursorSkipper) flush() []LogEntry {
+	held := s.held
+	s.held = nil
+	return held
+}
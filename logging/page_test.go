@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const pageDataDir = "test/page"
+
+type pageSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *pageSuite) SetupTest() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:01:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(pageDataDir, "access.log.1"), `10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /1 HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:10 +0000] "GET /2 HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:10 +0000] "GET /3 HTTP/1.1" 200 10
+`, t.Add(-45*time.Second))
+	writeLogFile(s.T(), filepath.Join(pageDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:10:00:10 +0000] "GET /4 HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:20 +0000] "GET /5 HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:30 +0000] "GET /6 HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:30 +0000] "GET /7 HTTP/1.1" 200 10
+`, t)
+}
+
+func (s *pageSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(pageDataDir)))
+}
+
+// logs returns the logs of the test directory, merged when told so.
+func (s *pageSuite) logs(opts ...Option) *Logs {
+	logs, err := New(append([]Option{WithDirectory(pageDataDir), WithWindow(time.Hour), WithEnd(s.testTime)}, opts...)...)
+	s.Require().NoError(err)
+	return logs
+}
+
+// pages pages through given logs by pages of a given size, returning the paths of the entries of every page.
+func (s *pageSuite) pages(logs *Logs, size int) [][]string {
+	var pages [][]string
+	var cursor *Cursor
+	for {
+		var paths []string
+		next, err := logs.Page(context.Background(), cursor, size, func(entry LogEntry) error {
+			paths = append(paths, entry.Path)
+			return nil
+		})
+		s.Require().NoError(err)
+		pages = append(pages, paths)
+		if next == nil {
+			return pages
+		}
+		// the cursors go through their tokens, as they would through an API
+		parsed, err := ParseCursor(next.String())
+		s.Require().NoError(err)
+		s.Equal(next.String(), parsed.String())
+		cursor = &parsed
+	}
+}
+
+func (s *pageSuite) Test_Page() {
+	s.Equal([][]string{{"/1", "/2"}, {"/3", "/4"}, {"/5", "/6"}, {"/7"}}, s.pages(s.logs(), 2))
+	s.Equal([][]string{{"/1", "/2", "/3"}, {"/4", "/5", "/6"}, {"/7"}}, s.pages(s.logs(), 3))
+	s.Equal([][]string{{"/1", "/2", "/3", "/4", "/5", "/6", "/7"}}, s.pages(s.logs(), 7))
+}
+
+func (s *pageSuite) Test_Page_Merged() {
+	s.Equal([][]string{{"/1", "/2"}, {"/3", "/4"}, {"/5", "/6"}, {"/7"}}, s.pages(s.logs(WithMerge()), 2))
+}
+
+func (s *pageSuite) Test_Page_Rotated() {
+	logs := s.logs()
+	var first []string
+	cursor, err := logs.Page(context.Background(), nil, 3, func(entry LogEntry) error {
+		first = append(first, entry.Path)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"/1", "/2", "/3"}, first)
+
+	// the file of the cursor is rotated away, so the entries of its time are read again rather than lost
+	s.Require().NoError(os.Rename(filepath.Join(pageDataDir, "access.log.1"), filepath.Join(pageDataDir, "access.log.2")))
+	var second []string
+	cursor, err = s.logs().Page(context.Background(), cursor, 10, func(entry LogEntry) error {
+		second = append(second, entry.Path)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Nil(cursor)
+	s.Equal([]string{"/2", "/3", "/4", "/5", "/6", "/7"}, second)
+}
+
+func (s *pageSuite) Test_Page_End() {
+	logs := s.logs()
+	cursor, err := logs.Page(context.Background(), nil, 1, func(LogEntry) error { return nil })
+	s.Require().NoError(err)
+	s.True(s.testTime.Equal(cursor.End))
+
+	// the end of the time range is the one of the first page, whatever the logs say
+	var paths []string
+	next, err := s.logs(WithEnd(s.testTime.Add(-35*time.Second))).Page(context.Background(), cursor, 10, func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Nil(next)
+	s.Equal([]string{"/2", "/3", "/4", "/5", "/6", "/7"}, paths)
+}
+
+func (s *pageSuite) Test_Page_Invalid() {
+	_, err := s.logs().Page(context.Background(), nil, 0, func(LogEntry) error { return nil })
+	s.Error(err)
+
+	for _, token := range []string{"", "not a cursor", "e30"} {
+		_, err := ParseCursor(token)
+		s.Error(err, token)
+	}
+}
+
+func TestPageSuite(t *testing.T) {
+	suite.Run(t, new(pageSuite))
+}
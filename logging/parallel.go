@@ -0,0 +1,173 @@
+package logging
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"runtime"
+)
+
+// DefaultWorkers returns the number of files worth parsing in parallel (see LogsConfig.Workers): as many as
+// there are CPUs the goroutines run on (GOMAXPROCS), the parsing of the lines being bound by the CPU once cached.
+func DefaultWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// aheadBatchSize is the default number of log entries a goroutine reading a file ahead parses at once,
+// holding one of the workers (see LogsConfig.ReadAheadBatch).
+const aheadBatchSize = 512
+
+// readAheadBatch returns the number of log entries a file read ahead parses at once, see LogsConfig.ReadAheadBatch.
+func (cfg LogsConfig) readAheadBatch() int {
+	if cfg.ReadAheadBatch > 0 {
+		return cfg.ReadAheadBatch
+	}
+	return aheadBatchSize
+}
+
+// readAheadDepth returns the number of batches of a file read ahead queued, see LogsConfig.ReadAheadDepth.
+func (cfg LogsConfig) readAheadDepth() int {
+	if cfg.ReadAheadDepth > 0 {
+		return cfg.ReadAheadDepth
+	}
+	return 1
+}
+
+// cursorBatch is a batch of log entries read ahead, along with whether they are accepted (see Logs.accept).
+// The error that stopped the reading, if any, comes after the entries.
+type cursorBatch struct {
+	entries  []LogEntry
+	accepted []bool
+	err      error
+}
+
+// openNextAhead opens the next file of the stream the way openNext does, when the files are read in parallel:
+// the following files are read ahead by their own goroutines, all of them when the files are interleaved
+// (see LogsConfig.Merge) or as many as there are workers otherwise, the cursor of the next file
+// waiting for its first batch of log entries.
+func (s *stream) openNextAhead() error {
+	ahead := s.logs.cfg.Workers
+	if s.logs.cfg.Merge {
+		ahead = len(s.files) + len(s.ahead)
+	}
+	for len(s.files) > 0 && len(s.ahead) < ahead {
+		s.ahead = append(s.ahead, s.readAhead(s.files[0], s.opened))
+		s.files = s.files[1:]
+		s.opened++
+	}
+
+	c := s.ahead[0]
+	s.ahead = s.ahead[1:]
+	ok, err := c.next()
+	if err != nil || !ok {
+		c.close()
+		return err
+	}
+	heap.Push(&s.cursors, c)
+	return nil
+}
+
+// readAhead starts reading a given file ahead in its own goroutine, returning the cursor receiving its log entries.
+func (s *stream) readAhead(fi logFile, order int) *cursor {
+	ctx, cancel := context.WithCancel(s.ctx)
+	batches := make(chan cursorBatch, s.logs.cfg.readAheadDepth())
+	progress := s.progress.file(fi)
+	s.readers.Add(1)
+	go func() {
+		defer s.readers.Done()
+		defer close(batches)
+		s.logs.readAhead(ctx, s.workers, fi, order, progress, batches)
+	}()
+	return &cursor{order: order, tie: s.tie(fi, order), batches: batches, ctx: ctx, stop: cancel}
+}
+
+// readAhead reads the log lines of a given file that happened within the last N minutes, sending them by batches
+// once parsed, classified and filtered (see Logs.accept), until done or the context is done. A worker is held
+// while parsing a batch only, so the goroutines waiting for their batches to be received leave room to the others.
+// The bytes of the file read or skipped are counted by a given progress, if any (see LogsConfig.Progress).
+func (logs *Logs) readAhead(ctx context.Context, workers chan struct{}, fi logFile, order int, progress *fileProgress, batches chan<- cursorBatch) {
+	send := func(batch cursorBatch) bool {
+		select {
+		case batches <- batch:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	acquire := func() bool {
+		select {
+		case workers <- struct{}{}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	release := func() { <-workers }
+
+	if !acquire() {
+		return
+	}
+	file, err := logs.open(fi.path)
+	if err != nil {
+		release()
+		if errors.Is(err, errVanished) {
+			progress.done()
+			return
+		}
+		send(cursorBatch{err: err})
+		return
+	}
+	defer func() { _ = file.Close() }()
+	c, err := logs.cursor(ctx, file, order, progress)
+	if err != nil || c == nil {
+		release()
+		if err != nil {
+			send(cursorBatch{err: err})
+		}
+		return
+	}
+	defer c.release()
+
+	size := logs.cfg.readAheadBatch()
+	for {
+		batch := cursorBatch{
+			entries:  make([]LogEntry, 0, size),
+			accepted: make([]bool, 0, size),
+		}
+		ok := true
+		for ok && len(batch.entries) < size {
+			entry := c.entry
+			accepted := logs.accept(&entry)
+			c.stats.accepted(entry.Time, accepted)
+			batch.accepted = append(batch.accepted, accepted)
+			batch.entries = append(batch.entries, entry)
+			ok, batch.err = c.next()
+			ok = ok && batch.err == nil
+		}
+		release()
+		if !send(batch) || !ok || !acquire() {
+			return
+		}
+	}
+}
+
+// nextAhead advances the cursor to the following log entry read ahead, waiting for the next batch when needed.
+func (c *cursor) nextAhead() (bool, error) {
+	if len(c.batch.entries) > 0 {
+		c.batch.entries = c.batch.entries[1:]
+		c.batch.accepted = c.batch.accepted[1:]
+	}
+	for len(c.batch.entries) == 0 {
+		if c.batch.err != nil {
+			return false, c.batch.err
+		}
+		batch, ok := <-c.batches
+		if !ok {
+			// the goroutine stopped early when its context is done
+			return false, c.ctx.Err()
+		}
+		c.batch = batch
+	}
+	c.entry, c.accepted = c.batch.entries[0], c.batch.accepted[0]
+	return true, nil
+}
@@ -0,0 +1,278 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const parallelDataDir = "test/parallel"
+
+type parallelSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *parallelSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	// 8 rotated files of 30 minutes each, with more lines than a batch
+	for i := 0; i < 8; i++ {
+		var logs strings.Builder
+		from := t.Add(-time.Duration(8-i) * 30 * time.Minute)
+		for j := 0; j < 1800; j++ {
+			status := 200
+			if j%13 == 0 {
+				status = 500
+			}
+			logs.WriteString(fmt.Sprintf("10.0.%d.%d - - [%s] \"GET /%d HTTP/1.1\" %d 10\n",
+				i, j%50, from.Add(time.Duration(j)*time.Second).Format(dateTimeFormat), j, status))
+		}
+		name := filepath.Join(parallelDataDir, fmt.Sprintf("access.log.%d", 8-i))
+		modTime := from.Add(30 * time.Minute)
+		writeLogFile(s.T(), name, logs.String(), modTime)
+	}
+}
+
+func (s *parallelSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(parallelDataDir)))
+}
+
+func (s *parallelSuite) read(ctx context.Context, cfg LogsConfig) ([]LogEntry, error) {
+	cfg.Directory = parallelDataDir
+	logs, err := NewLogs(cfg)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-cfg.window())
+	}
+	var entries []LogEntry
+	err = logs.ForEach(ctx, func(entry LogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+func (s *parallelSuite) Test_Workers_SameAsSequential() {
+	serverErrors := func(entry LogEntry) bool { return entry.Status >= 500 }
+	tests := []struct {
+		name string
+		cfg  LogsConfig
+	}{
+		{name: "Sequential", cfg: LogsConfig{LastNMinutes: 200}},
+		{name: "Merged", cfg: LogsConfig{LastNMinutes: 200, Merge: true}},
+		{name: "Filtered", cfg: LogsConfig{LastNMinutes: 200, Filters: []Filter{serverErrors}}},
+		{name: "End", cfg: LogsConfig{LastNMinutes: 120, End: s.testTime.Add(-time.Hour)}},
+		{name: "Merged End", cfg: LogsConfig{LastNMinutes: 120, End: s.testTime.Add(-time.Hour), Merge: true}},
+		{name: "Bots", cfg: LogsConfig{LastNMinutes: 90, Bots: NewBotClassifier(), Filters: []Filter{ExcludeBots}}},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			expected, err := s.read(context.Background(), test.cfg)
+			s.Require().NoError(err)
+			s.Require().NotEmpty(expected)
+
+			for _, workers := range []int{2, 3, 16} {
+				cfg := test.cfg
+				cfg.Workers = workers
+				entries, err := s.read(context.Background(), cfg)
+				s.Require().NoError(err)
+				s.Equal(expected, entries, "%d workers", workers)
+			}
+		})
+	}
+}
+
+func (s *parallelSuite) Test_Workers_Tuned() {
+	expected, err := s.read(context.Background(), LogsConfig{LastNMinutes: 200, Merge: true})
+	s.Require().NoError(err)
+
+	tests := []struct {
+		name string
+		cfg  LogsConfig
+	}{
+		{name: "Small Batches", cfg: LogsConfig{Workers: 3, ReadAheadBatch: 7, ReadAheadDepth: 4}},
+		{name: "Default Workers", cfg: LogsConfig{Workers: DefaultWorkers(), ReadAheadDepth: 16}},
+		{name: "Small Buffers", cfg: LogsConfig{Workers: 2, ReadBufferSize: 16, WriteBufferSize: 16}},
+		{name: "Large Buffers", cfg: LogsConfig{ReadBufferSize: 1 << 20}},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			cfg := test.cfg
+			cfg.LastNMinutes, cfg.Merge = 200, true
+			entries, err := s.read(context.Background(), cfg)
+			s.Require().NoError(err)
+			s.Equal(expected, entries)
+		})
+	}
+
+	s.Positive(DefaultWorkers())
+	s.Equal(defaultReadBufferSize, LogsConfig{}.readBufferSize(1<<20))
+	s.Equal(largeReadBufferSize, LogsConfig{}.readBufferSize(largeFileSize))
+	s.Equal(1024, LogsConfig{ReadBufferSize: 1024}.readBufferSize(largeFileSize))
+	s.Equal(printBufferSize, LogsConfig{}.writeBufferSize())
+
+	for _, cfg := range []LogsConfig{{ReadAheadBatch: -1}, {ReadAheadDepth: -1}, {ReadBufferSize: -1}, {WriteBufferSize: -1}} {
+		cfg.Directory = parallelDataDir
+		_, err := NewLogs(cfg)
+		var cfgErr *ConfigError
+		s.True(errors.As(err, &cfgErr), "%+v", cfg)
+	}
+}
+
+func (s *parallelSuite) Test_Workers_InvalidLine() {
+	// the first file within the window is searched, failing on its invalid line, while the next one is valid
+	dir := filepath.Join(parallelDataDir, "invalid")
+	s.Require().NoError(os.MkdirAll(dir, 0777))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "access.log.1"), []byte("not a log line\n"), 0666))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "access.log"), []byte(`10.0.0.1 - - [03/Mar/2022:02:44:00 +0000] "GET / HTTP/1.1" 200 1\n`), 0666))
+	s.Require().NoError(os.Chtimes(filepath.Join(dir, "access.log.1"), s.testTime.Add(-time.Minute), s.testTime.Add(-time.Minute)))
+	s.Require().NoError(os.Chtimes(filepath.Join(dir, "access.log"), s.testTime, s.testTime))
+
+	read := func(workers int) error {
+		logs, err := NewLogs(LogsConfig{Directory: dir, LastNMinutes: 5, Workers: workers})
+		s.Require().NoError(err)
+		logs.nowMinusT = func() time.Time {
+			return s.testTime.Add(-5 * time.Minute)
+		}
+		return logs.ForEach(context.Background(), func(LogEntry) error { return nil })
+	}
+	expectedErr := read(0)
+	var invalidErr *InvalidLogLineError
+	s.Require().ErrorAs(expectedErr, &invalidErr)
+	s.Equal(expectedErr, read(4))
+}
+
+func (s *parallelSuite) Test_Workers_Canceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := LogsConfig{Directory: parallelDataDir, LastNMinutes: 200, Workers: 4, Merge: true}
+	logs, err := NewLogs(cfg)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-cfg.window())
+	}
+	goroutines := runtime.NumGoroutine()
+
+	n := 0
+	err = logs.ForEach(ctx, func(entry LogEntry) error {
+		n++
+		if n == 1000 {
+			cancel()
+		}
+		return nil
+	})
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(1000, n)
+	// the goroutines reading ahead are done once the iteration is
+	s.Equal(goroutines, runtime.NumGoroutine())
+}
+
+func (s *parallelSuite) Test_Workers_ClosedEarly() {
+	cfg := LogsConfig{Directory: parallelDataDir, LastNMinutes: 200, Workers: 2}
+	logs, err := NewLogs(cfg)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-cfg.window())
+	}
+	goroutines := runtime.NumGoroutine()
+
+	it := logs.Entries(context.Background())
+	s.Require().True(it.Next())
+	s.Require().NoError(it.Close())
+	s.False(it.Next())
+	s.NoError(it.Err())
+	s.Equal(goroutines, runtime.NumGoroutine())
+}
+
+func (s *parallelSuite) Test_Logs_ConcurrentCalls() {
+	cfg := LogsConfig{Directory: parallelDataDir, LastNMinutes: 200}
+	for _, test := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Copied"},
+		{name: "Filtered", opts: []Option{WithFilter(func(entry LogEntry) bool { return entry.Status >= 500 })}},
+		{name: "Memory Mapped", opts: []Option{WithMemoryMap(), WithWorkers(2)}},
+		{name: "Refreshed", opts: []Option{WithRefresh(), WithWorkers(2)}},
+	} {
+		s.Run(test.name, func() {
+			logs, err := New(append([]Option{WithConfig(cfg)}, test.opts...)...)
+			s.Require().NoError(err)
+			logs.nowMinusT = func() time.Time {
+				return s.testTime.Add(-cfg.window())
+			}
+			var expected bytes.Buffer
+			s.Require().NoError(logs.Print(context.Background(), &expected))
+			s.Require().NotZero(expected.Len())
+
+			// the same Logs prints the same logs from concurrent calls
+			outputs := make([]bytes.Buffer, 8)
+			errs := make(chan error, len(outputs))
+			for i := range outputs {
+				go func(w io.Writer) {
+					errs <- logs.Print(context.Background(), w)
+				}(&outputs[i])
+			}
+			for range outputs {
+				s.Require().NoError(<-errs)
+			}
+			for i := range outputs {
+				s.Equal(expected.String(), outputs[i].String())
+			}
+		})
+	}
+}
+
+func (s *parallelSuite) Test_File_ConcurrentCalls() {
+	for _, mapped := range []bool{false, true} {
+		f, err := os.Open(filepath.Join(parallelDataDir, "access.log.1"))
+		s.Require().NoError(err)
+		file := NewFile(f)
+		if mapped {
+			file = MapFile(f, FormatCommon)
+		}
+		lookupTimes := []time.Time{s.testTime.Add(-40 * time.Minute), s.testTime.Add(-20 * time.Minute), s.testTime}
+		expected := make([]int64, len(lookupTimes))
+		for i, lookupTime := range lookupTimes {
+			expected[i], err = file.IndexTime(context.Background(), lookupTime)
+			s.Require().NoError(err)
+		}
+		first, last, err := file.TimeRange()
+		s.Require().NoError(err)
+
+		// the helpers never move the internal file cursor, so they can be called concurrently on the same File
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				j := i % len(lookupTimes)
+				offset, err := file.IndexTime(context.Background(), lookupTimes[j])
+				s.NoError(err)
+				s.Equal(expected[j], offset, "mapped: %t", mapped)
+				f, l, err := file.TimeRange()
+				s.NoError(err)
+				s.Equal(first, f)
+				s.Equal(last, l)
+			}(i)
+		}
+		wg.Wait()
+		s.Require().NoError(file.Close())
+	}
+}
+
+func TestParallel(t *testing.T) {
+	suite.Run(t, new(parallelSuite))
+}
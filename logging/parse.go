@@ -0,0 +1,486 @@
+package logging
+
+import (
+	"strconv"
+	"strings"
+)
+
+// logFields are the fields of a log line, as substrings of it, so getting them allocates nothing
+// but for the quoted fields holding escapes (see unescapeField).
+// Fields missing from the log line, or from its format, are empty.
+type logFields struct {
+	dateTime, vhost, ip, identity, user  string
+	method, path, protocol, status, size string
+	referer, agent, duration             string
+	// cache is the cache status of the response (see FormatCombinedCache), if any.
+	cache string
+	// forwardedFor is the X-Forwarded-For header of the request (see FormatCombinedXFF), if any.
+	forwardedFor string
+	// requestID is the id of the request (see FormatCombinedRequestID), if any.
+	requestID string
+	// tlsProtocol and tlsCipher are the TLS protocol and cipher of the request (see FormatSSLRequest), if any.
+	tlsProtocol, tlsCipher string
+	// level and pid are the severity of the log line and the process id of the server (e.g. FormatError), if any.
+	level, pid string
+	// message is the message of the log line, wrapped by it when it's another log line (e.g. CRI), if any.
+	message string
+	wrapped bool
+}
+
+// match returns the fields of a given log line, or false when it doesn't match the format of the file.
+// The log lines are parsed by hand (see parseCLF and parseCRI), the regular expression of the format
+// only deciding about the lines of unusual shapes the hand-written parsers leave to it.
+func (file File) match(logLine string) (logFields, bool) {
+	var fields logFields
+	var ok bool
+	switch file.format {
+	case FormatCRI:
+		fields, ok = parseCRI(logLine)
+	case FormatError:
+		fields, ok = parseError(logLine)
+	case FormatSSLRequest:
+		fields, ok = parseSSLRequest(logLine)
+	default:
+		fields, ok = parseCLF(logLine, file.format)
+	}
+	if ok {
+		return fields, true
+	}
+
+	groups, ok := matchGroups(file.regEx, logLine)
+	if !ok {
+		return fields, false
+	}
+	return groupFields(groups), true
+}
+
+// matchMessage returns the fields of a given message wrapped by a log line, the same way match does for log lines.
+func (file File) matchMessage(message string) (logFields, bool) {
+	if fields, ok := parseCLF(message, FormatCommon); ok {
+		return fields, true
+	}
+	groups, ok := matchGroups(file.messageRegEx, message)
+	if !ok {
+		return logFields{}, false
+	}
+	return groupFields(groups), true
+}
+
+// groupFields returns the fields of the named groups matched by a regular expression (see matchGroups).
+func groupFields(groups map[string]string) logFields {
+	// only the messages of the CRI format, tagged, are log lines themselves
+	_, wrapped := groups[tagGroupName]
+	return logFields{
+		dateTime:     groups[dateTimeGroupName],
+		vhost:        groups[vhostGroupName],
+		ip:           groups[ipGroupName],
+		identity:     groups[idGroupName],
+		user:         groups[userGroupName],
+		method:       groups[methodGroupName],
+		path:         groups[pathGroupName],
+		protocol:     groups[protocolGroupName],
+		status:       groups[statusGroupName],
+		size:         groups[sizeGroupName],
+		referer:      unescapeField(groups[refererGroupName]),
+		agent:        unescapeField(groups[agentGroupName]),
+		duration:     groups[durationGroupName],
+		cache:        unescapeField(groups[cacheGroupName]),
+		forwardedFor: unescapeField(groups[forwardedForGroupName]),
+		requestID:    unescapeField(groups[requestIDGroupName]),
+		tlsProtocol:  groups[tlsProtocolGroupName],
+		tlsCipher:    groups[tlsCipherGroupName],
+		level:        groups[levelGroupName],
+		pid:          groups[pidGroupName],
+		message:      groups[messageGroupName],
+		wrapped:      wrapped,
+	}
+}
+
+// parseCLF parses a log line of the Apache Common Log format or one of its variants by hand,
+// giving the same fields as the regular expression of the format (see Format.regEx).
+// It returns false when the log line isn't of the usual shape, either because it is invalid
+// or because of what only the regular expression tells apart (e.g. other whitespaces than spaces,
+// quotes or consecutive spaces inside the request), leaving such lines to it.
+func parseCLF(line string, format Format) (logFields, bool) {
+	var fields logFields
+	if strings.ContainsAny(line, "\t\n\f\r") {
+		return fields, false
+	}
+
+	var ok bool
+	rest := line
+	if format == FormatVHostCombined {
+		var vhost string
+		if vhost, rest, ok = cutField(rest); !ok {
+			return fields, false
+		}
+		// the port of the virtual host is left out
+		if i := strings.IndexByte(vhost, ':'); i >= 0 {
+			if !isDigits(vhost[i+1:]) {
+				return fields, false
+			}
+			vhost = vhost[:i]
+		}
+		if vhost == "" {
+			return fields, false
+		}
+		fields.vhost = vhost
+	}
+	if fields.ip, rest, ok = cutField(rest); !ok {
+		return fields, false
+	}
+	if fields.identity, rest, ok = cutField(rest); !ok {
+		return fields, false
+	}
+	if fields.user, rest, ok = cutField(rest); !ok {
+		return fields, false
+	}
+
+	if !strings.HasPrefix(rest, "[") {
+		return fields, false
+	}
+	end := strings.IndexByte(rest, ']')
+	if end < 0 || !isCLFDateTime(rest[1:end]) {
+		return fields, false
+	}
+	fields.dateTime = rest[1:end]
+	rest = rest[end+1:]
+
+	if !strings.HasPrefix(rest, ` "`) {
+		return fields, false
+	}
+	rest = rest[2:]
+	end = strings.IndexByte(rest, '"')
+	if end < 0 || !fields.parseRequest(rest[:end]) {
+		return fields, false
+	}
+	rest = rest[end+1:]
+
+	if !strings.HasPrefix(rest, " ") {
+		return fields, false
+	}
+	if fields.status, rest, ok = cutField(rest[1:]); !ok || (fields.status != "-" && (len(fields.status) != 3 || !isDigits(fields.status))) {
+		return fields, false
+	}
+	fields.size, rest = cutToken(rest)
+	if fields.size != "-" && !isDigits(fields.size) {
+		return fields, false
+	}
+
+	if format == FormatCombined || format == FormatVHostCombined || format == FormatCombinedCache || format == FormatCombinedXFF ||
+		format == FormatCombinedRequestID {
+		if fields.referer, rest, ok = cutQuoted(rest); !ok {
+			return fields, false
+		}
+		if fields.agent, rest, ok = cutQuoted(rest); !ok {
+			return fields, false
+		}
+	}
+	if format == FormatCombinedCache {
+		if fields.cache, rest, ok = cutQuoted(rest); !ok {
+			return fields, false
+		}
+	}
+	if format == FormatCombinedXFF {
+		if fields.forwardedFor, rest, ok = cutQuoted(rest); !ok {
+			return fields, false
+		}
+	}
+	if format == FormatCombinedRequestID {
+		if fields.requestID, rest, ok = cutQuoted(rest); !ok {
+			return fields, false
+		}
+	}
+
+	if rest != "" {
+		if rest[0] != ' ' || !isDigits(rest[1:]) {
+			return fields, false
+		}
+		fields.duration = rest[1:]
+	}
+	return fields, true
+}
+
+// parseRequest splits the request of a log line into its method, path and protocol,
+// returning false unless they are separated by single spaces.
+func (fields *logFields) parseRequest(request string) bool {
+	var rest string
+	fields.method, rest = cutToken(request)
+	if fields.method == "" {
+		return false
+	}
+	if rest == "" {
+		return true
+	}
+	fields.path, rest = cutToken(rest[1:])
+	if fields.path == "" {
+		return false
+	}
+	if rest == "" {
+		return true
+	}
+	fields.protocol, rest = cutToken(rest[1:])
+	return fields.protocol != "" && rest == ""
+}
+
+// parseSSLRequest parses a log line of the ssl_request_log format by hand, the same way parseCLF does:
+// the bracketed time, the client IP, the TLS protocol and cipher, the quoted request and the size of the response.
+func parseSSLRequest(line string) (logFields, bool) {
+	var fields logFields
+	if strings.ContainsAny(line, "\t\n\f\r") || !strings.HasPrefix(line, "[") {
+		return fields, false
+	}
+	end := strings.IndexByte(line, ']')
+	if end < 0 || !isCLFDateTime(line[1:end]) {
+		return fields, false
+	}
+	fields.dateTime = line[1:end]
+	rest := line[end+1:]
+
+	if !strings.HasPrefix(rest, " ") {
+		return fields, false
+	}
+	var ok bool
+	if fields.ip, rest, ok = cutField(rest[1:]); !ok {
+		return fields, false
+	}
+	if fields.tlsProtocol, rest, ok = cutField(rest); !ok {
+		return fields, false
+	}
+	if fields.tlsCipher, rest, ok = cutField(rest); !ok {
+		return fields, false
+	}
+
+	if !strings.HasPrefix(rest, `"`) {
+		return fields, false
+	}
+	rest = rest[1:]
+	end = strings.IndexByte(rest, '"')
+	if end < 0 || !fields.parseRequest(rest[:end]) {
+		return fields, false
+	}
+	rest = rest[end+1:]
+
+	if !strings.HasPrefix(rest, " ") {
+		return fields, false
+	}
+	fields.size = rest[1:]
+	return fields, fields.size == "-" || isDigits(fields.size)
+}
+
+// parseCRI parses a log line of the CRI format by hand, the same way parseCLF does.
+func parseCRI(line string) (logFields, bool) {
+	var fields logFields
+	var rest string
+	fields.dateTime, rest = cutToken(line)
+	if fields.dateTime == "" || strings.ContainsAny(fields.dateTime, "\t\n\f\r") {
+		return fields, false
+	}
+	if !strings.HasPrefix(rest, " stdout ") && !strings.HasPrefix(rest, " stderr ") {
+		return fields, false
+	}
+	rest = rest[len(" stdout "):]
+	if len(rest) < 2 || (rest[0] != 'F' && rest[0] != 'P') || rest[1] != ' ' {
+		return fields, false
+	}
+	fields.message, fields.wrapped = rest[2:], true
+	return fields, strings.IndexByte(fields.message, '\n') < 0
+}
+
+// parseError parses a log line of the Apache error log format by hand, the same way parseCLF does:
+// the bracketed time, then the bracketed module and level, the process and the client, if any, and the message.
+func parseError(line string) (logFields, bool) {
+	var fields logFields
+	if strings.ContainsAny(line, "\t\n\f\r") {
+		return fields, false
+	}
+	dateTime, rest, ok := cutBracketed(" " + line)
+	if !ok || dateTime == "" {
+		return fields, false
+	}
+	fields.dateTime = dateTime
+
+	// [module:level], or [level] before Apache 2.4
+	level, rest, ok := cutBracketed(rest)
+	if !ok {
+		return fields, false
+	}
+	if i := strings.LastIndexByte(level, ':'); i >= 0 {
+		level = level[i+1:]
+	}
+	if level == "" {
+		return fields, false
+	}
+	fields.level = level
+
+	if strings.HasPrefix(rest, " [pid ") {
+		var pid string
+		if pid, rest, ok = cutBracketed(rest); !ok {
+			return fields, false
+		}
+		// the id of the thread is left out
+		pid = pid[len("pid "):]
+		if i := strings.IndexByte(pid, ':'); i >= 0 {
+			pid = pid[:i]
+		}
+		fields.pid = pid
+	}
+	if strings.HasPrefix(rest, " [client ") {
+		var client string
+		if client, rest, ok = cutBracketed(rest); !ok || client == "client " {
+			return fields, false
+		}
+		fields.ip = clientIP(client[len("client "):])
+	}
+	if rest != "" {
+		if rest[0] != ' ' || strings.HasPrefix(rest, "  ") {
+			return fields, false
+		}
+		fields.message = rest[1:]
+	}
+	return fields, true
+}
+
+// cutBracketed returns the bracketed part of a given string following a space, and the rest of it after the brackets.
+func cutBracketed(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, " [") {
+		return "", "", false
+	}
+	s = s[2:]
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return "", "", false
+	}
+	return s[:end], s[end+1:], true
+}
+
+// clientIP returns the IP of the client of an error log line, leaving out its port, if any.
+func clientIP(client string) string {
+	if i := strings.LastIndexByte(client, ':'); i > 0 && isDigits(client[i+1:]) {
+		return client[:i]
+	}
+	return client
+}
+
+// cutToken returns the part of a given string before its first space, and the rest of it from the space on.
+func cutToken(s string) (string, string) {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+// cutField returns the non-empty part of a given string before its first space, and the rest of it after the space.
+func cutField(s string) (string, string, bool) {
+	field, rest := cutToken(s)
+	if field == "" || rest == "" {
+		return "", "", false
+	}
+	return field, rest[1:], true
+}
+
+// cutQuoted returns the quoted part of a given string following a space, unescaped (see unescapeField),
+// and the rest of it after the quotes.
+func cutQuoted(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, ` "`) {
+		return "", "", false
+	}
+	s = s[2:]
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return unescapeField(s[:i]), s[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// unescapeField returns a given quoted field of a log line with the escapes of Apache replaced by the characters
+// they stand for: \" and \\ for the quotes and the backslashes, \n, \t and the like for the whitespaces and \xhh
+// for the other control characters. The fields without escapes, most of them, are returned as they are.
+func unescapeField(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			if i+3 <= len(s) {
+				if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(c))
+					i += 2
+					break
+				}
+			}
+			b.WriteString(`\x`)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// isCLFDateTime checks whether a given string has the shape of the datetime of an Apache Common Log line,
+// e.g. 04/Mar/2022:05:30:00 +0000, leaving its parsing to time.Parse.
+func isCLFDateTime(s string) bool {
+	n := len(s) - len(" +0000")
+	if n < 1 || s[n] != ' ' || (s[n+1] != '+' && s[n+1] != '-') || !isDigits(s[n+2:]) {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || c == '/') {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits checks whether a given string is made of ASCII digits only, and isn't empty.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNumber parses a given field made of digits, returning false when it isn't one (e.g. "-") or overflows,
+// without allocating an error the way strconv does.
+func parseNumber(s string) (int64, bool) {
+	if !isDigits(s) {
+		return 0, false
+	}
+	if len(s) > 18 {
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, err == nil
+	}
+	var n int64
+	for i := 0; i < len(s); i++ {
+		n = n*10 + int64(s[i]-'0')
+	}
+	return n, true
+}
@@ -0,0 +1,272 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type parseSuite struct {
+	suite.Suite
+}
+
+// parseLines are log lines of every format, of usual and unusual shapes, valid or not.
+var parseLines = []string{
+	`127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 1500`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "-" - -`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET" 200 -`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET /" 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET  /" 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / " 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] " GET /" 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1 extra" 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET /"quoted" HTTP/1.1" 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET /\"escaped\" HTTP/1.1" 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "" 200 1`,
+	"127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] \"GET\t/ HTTP/1.1\" 200 1",
+	"127.0.0.1\t- - [04/Mar/2022:05:30:00 +0000] \"GET / HTTP/1.1\" 200 1",
+	`127.0.0.1  - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 2000 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 20 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 99999999999999999999`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 `,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 x`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00 -0700] "GET / HTTP/1.1" 200 1`,
+	`127.0.0.1 - - [04/Mar/2022:05:30:00] "GET / HTTP/1.1" 200 1`,
+	`127.0.0.1 - - [04/Foo/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1`,
+	`127.0.0.1 - - [04-Mar-2022 05:30:00 +0000] "GET / HTTP/1.1" 200 1`,
+	`127.0.0.1 - - 04/Mar/2022:05:30:00 +0000 "GET / HTTP/1.1" 200 1`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "https://example.com/" "curl/7.79.1"`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "-" 1500`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "" ""`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "Mozilla \"quoted\""`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-"`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "-" 1500 1`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET /" 200 1 "-" "GET / HTTP/1.1" 200 1 "-" "-"`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "HIT"`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "Miss from cloudfront" 1500`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" ""`,
+	`127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" HIT`,
+	`10.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "203.0.113.7, 10.0.0.2"`,
+	`10.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "-" 1500`,
+	`[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET /api/endpoint HTTP/1.1" 123`,
+	`[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.3 TLS_AES_256_GCM_SHA384 "-" -`,
+	`[04/Mar/2022:05:30:00 +0000] 127.0.0.1 - - "GET / HTTP/1.1" 1`,
+	`[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 "GET / HTTP/1.1" 1`,
+	`[04/Mar/2022:05:30:00 +0000] 127.0.0.1  TLSv1.2 RC4-SHA "GET / HTTP/1.1" 1`,
+	`[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 RC4-SHA "GET / HTTP/1.1" 1 200`,
+	`[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 RC4-SHA "GET / HTTP/1.1"`,
+	`[04/Mar/2022:05:30:00 +0000]127.0.0.1 TLSv1.2 RC4-SHA "GET / HTTP/1.1" 1`,
+	`example.com:443 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1"`,
+	`example.com 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" 1500`,
+	`example.com:https 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "-"`,
+	`:443 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "-"`,
+	`example.com: 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "-"`,
+	`2022-03-04T05:30:00.000000000Z stdout F 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 500 123`,
+	`2022-03-04T05:30:00.5Z stderr P partial message`,
+	`2022-03-04T05:30:00Z stdout F `,
+	`2022-03-04T05:30:00Z stdout F`,
+	`2022-03-04T05:30:00Z stdin F message`,
+	`2022-03-04T05:30:00Z stdout X message`,
+	"2022-03-04T05:30:00Z stdout F message\twith a tab",
+	`[Fri Mar 04 05:30:00.123456 2022] [core:error] [pid 1234:tid 5678] [client 127.0.0.1:51234] AH00126: Invalid URI in request GET /x HTTP/1.1`,
+	`[Fri Mar 04 05:30:00 2022] [error] [client 127.0.0.1] File does not exist: /var/www/x`,
+	`[Fri Mar 04 05:30:00 2022] [mpm_event:notice] [pid 1234:tid 5678] AH00489: Apache/2.4.52 configured`,
+	`[Fri Mar 04 05:30:00 2022] [notice] Apache/2.2.22 configured`,
+	`[Fri Mar 04 05:30:00 2022] [core:error] [client ::1:51234] message`,
+	`[Fri Mar 04 05:30:00 2022] [core:error] [client [::1]:51234] message`,
+	`[Fri Mar 04 05:30:00 2022] [core:error] [client :80] message`,
+	`[Fri Mar 04 05:30:00 2022] [core:error] [client ] message`,
+	`[Fri Mar 04 05:30:00 2022] [core:error]`,
+	`[Fri Mar 04 05:30:00 2022] [core:error] `,
+	`[Fri Mar 04 05:30:00 2022] [core:error]  two spaces`,
+	`[Fri Mar 04 05:30:00 2022] [core:error]no space`,
+	`[Fri Mar 04 05:30:00 2022]  [core:error] message`,
+	"[Fri Mar 04 05:30:00 2022] [core:error] message\twith a tab",
+	`[Fri Mar 04 05:30:00 2022] [core:] message`,
+	`[Fri Mar 04 05:30:00 2022] [pid 1234] message`,
+	`[Fri Mar 04 05:30:00 2022] [core:error] [pid 1234 message`,
+	`[Fri Mar 04 05:30:00 2022] [core:error] [pid :tid 5678] message`,
+	`[Fri Mar 04 05:30:00 2022] [core:error] [pid 1234] [client 127.0.0.1] message`,
+	`[Fri Mar 04 05:30:00 2022] message`,
+	`[] [core:error] message`,
+	`not a log line`,
+	``,
+}
+
+func (s *parseSuite) Test_parse_SameAsRegEx() {
+	for _, format := range []Format{FormatCommon, FormatCombined, FormatVHostCombined, FormatCombinedCache, FormatCombinedXFF, FormatCombinedRequestID, FormatSSLRequest, FormatCRI, FormatError} {
+		file := NewFormatFile(nil, format)
+		for _, line := range parseLines {
+			groups, matched := matchGroups(file.regEx, line)
+			var fields logFields
+			var ok bool
+			switch format {
+			case FormatCRI:
+				fields, ok = parseCRI(line)
+			case FormatError:
+				fields, ok = parseError(line)
+			case FormatSSLRequest:
+				fields, ok = parseSSLRequest(line)
+			default:
+				fields, ok = parseCLF(line, format)
+			}
+			// the hand-written parsers may leave lines to the regular expression, but never disagree with it
+			if ok {
+				s.Require().True(matched, "%s: %s", format, line)
+				s.Equal(groupFields(groups), fields, "%s: %s", format, line)
+			}
+
+			fields, ok = file.match(line)
+			s.Equal(matched, ok, "%s: %s", format, line)
+			if matched {
+				s.Equal(groupFields(groups), fields, "%s: %s", format, line)
+			}
+		}
+	}
+}
+
+func (s *parseSuite) Test_parse_UsualLines() {
+	// the usual lines are never left to the regular expression
+	for _, test := range []struct {
+		format Format
+		line   string
+	}{
+		{format: FormatCommon, line: `127.0.0.1 user-identifier frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 500 123`},
+		{format: FormatCommon, line: `127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "-" - -`},
+		{format: FormatCombined, line: `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "https://example.com/" "curl/7.79.1"`},
+		{format: FormatVHostCombined, line: `example.com:443 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1"`},
+		{format: FormatCombinedCache, line: `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "HIT"`},
+		{format: FormatCombinedXFF, line: `10.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "203.0.113.7, 10.0.0.2"`},
+		{format: FormatCombinedRequestID, line: `10.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "f3b1c2d4-9a8e-4c7b-b6a5-0d1e2f3a4b5c"`},
+	} {
+		_, ok := parseCLF(test.line, test.format)
+		s.True(ok, test.line)
+	}
+	for _, line := range []string{
+		`[Fri Mar 04 05:30:00.123456 2022] [core:error] [pid 1234:tid 5678] [client 127.0.0.1:51234] AH00126: Invalid URI in request GET /x HTTP/1.1`,
+		`[Fri Mar 04 05:30:00 2022] [error] [client 127.0.0.1] File does not exist: /var/www/x`,
+		`[Fri Mar 04 05:30:00 2022] [mpm_event:notice] [pid 1234:tid 5678] AH00489: Apache/2.4.52 configured`,
+	} {
+		_, ok := parseError(line)
+		s.True(ok, line)
+	}
+	_, ok := parseSSLRequest(`[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET /api/endpoint HTTP/1.1" 123`)
+	s.True(ok)
+	_, ok = parseCRI(`2022-03-04T05:30:00.000000000Z stdout F 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 500 123`)
+	s.True(ok)
+}
+
+func (s *parseSuite) Test_parseLogEntry_NoAllocations() {
+	for _, test := range []struct {
+		format Format
+		line   string
+	}{
+		{format: FormatCommon, line: `127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint HTTP/1.0" 200 123 1500`},
+		{format: FormatCommon, line: `127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "-" - -`},
+		{format: FormatCombined, line: `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "https://example.com/" "curl/7.79.1"`},
+		{format: FormatVHostCombined, line: `example.com:443 127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1"`},
+		{format: FormatCombinedCache, line: `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "MISS"`},
+		{format: FormatCombinedXFF, line: `10.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "203.0.113.7"`},
+		{format: FormatCombinedRequestID, line: `10.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1 "-" "curl/7.79.1" "-"`},
+		{format: FormatSSLRequest, line: `[04/Mar/2022:05:30:00 +0000] 127.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET / HTTP/1.1" 1`},
+	} {
+		file := NewFormatFile(nil, test.format)
+		// the log times are UTC, which doesn't need a time zone allocated when parsed
+		allocs := testing.AllocsPerRun(100, func() {
+			_, _ = file.parseLogEntry(test.line)
+		})
+		if time.Local == time.UTC {
+			s.Zero(allocs, test.line)
+		} else {
+			s.LessOrEqual(allocs, float64(1), test.line)
+		}
+	}
+}
+
+func (s *parseSuite) Test_parseLogEntry_Error() {
+	file := NewFormatFile(nil, FormatError)
+	for _, test := range []struct {
+		line     string
+		expected LogEntry
+	}{
+		{
+			line: `[Fri Mar 04 05:30:00.123456 2022] [core:error] [pid 1234:tid 5678] [client 127.0.0.1:51234] AH00126: Invalid URI in request GET /x HTTP/1.1`,
+			expected: LogEntry{
+				Time: time.Date(2022, time.March, 4, 5, 30, 0, 123456000, time.UTC), IP: "127.0.0.1", Level: "error", PID: "1234",
+				Message: "AH00126: Invalid URI in request GET /x HTTP/1.1",
+			},
+		},
+		{
+			line: `[Fri Mar 04 05:30:00 2022] [error] [client 127.0.0.1] File does not exist: /var/www/x`,
+			expected: LogEntry{
+				Time: time.Date(2022, time.March, 4, 5, 30, 0, 0, time.UTC), IP: "127.0.0.1", Level: "error",
+				Message: "File does not exist: /var/www/x",
+			},
+		},
+		{
+			line: `[Fri Mar 04 05:30:00 2022] [core:error] [client ::1:51234] message`,
+			expected: LogEntry{
+				Time: time.Date(2022, time.March, 4, 5, 30, 0, 0, time.UTC), IP: "::1", Level: "error", Message: "message",
+			},
+		},
+		{
+			line: `[Fri Mar  4 05:30:00 2022] [mpm_event:notice] [pid 1234:tid 5678] AH00489: Apache/2.4.52 configured`,
+			expected: LogEntry{
+				Time: time.Date(2022, time.March, 4, 5, 30, 0, 0, time.UTC), Level: "notice", PID: "1234", Message: "AH00489: Apache/2.4.52 configured",
+			},
+		},
+	} {
+		entry, err := file.parseLogEntry(test.line)
+
+		s.Require().NoError(err, test.line)
+		s.Equal(test.expected.Time, entry.Time.UTC(), test.line)
+		s.Equal(test.expected.IP, entry.IP, test.line)
+		s.Equal(test.expected.Level, entry.Level, test.line)
+		s.Equal(test.expected.PID, entry.PID, test.line)
+		s.Equal(test.expected.Message, entry.Message, test.line)
+	}
+
+	_, err := file.parseLogEntry(`127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET / HTTP/1.1" 200 1`)
+	s.ErrorIs(err, ErrInvalidLogLine)
+}
+
+func TestParse(t *testing.T) {
+	suite.Run(t, new(parseSuite))
+}
+
+func BenchmarkParseLogEntry(b *testing.B) {
+	lines := []struct {
+		format Format
+		line   string
+	}{
+		{format: FormatCommon, line: `127.0.0.1 - frank [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint?id=42 HTTP/1.1" 200 2326 1500`},
+		{format: FormatCombined, line: `127.0.0.1 - - [04/Mar/2022:05:30:00 +0000] "GET /api/endpoint?id=42 HTTP/1.1" 200 2326 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0 Safari/537.36"`},
+	}
+	for _, l := range lines {
+		file := NewFormatFile(nil, l.format)
+		b.Run(fmt.Sprintf("%s/RegEx", l.format), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(l.line)))
+			for i := 0; i < b.N; i++ {
+				groups, _ := matchGroups(file.regEx, l.line)
+				if _, err := time.Parse(file.timeLayout, groups[dateTimeGroupName]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("%s/HandWritten", l.format), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(l.line)))
+			for i := 0; i < b.N; i++ {
+				if _, err := file.parseLogEntry(l.line); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
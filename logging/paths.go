@@ -0,0 +1,165 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// The placeholders of the segments of the paths replaced by the built-in rules of a PathNormalizer.
+const (
+	PathID   = "{id}"
+	PathUUID = "{uuid}"
+	PathHash = "{hash}"
+)
+
+// minHashLength is the minimum length of the hexadecimal segments of the paths taken for hashes, e.g. 32 for MD5.
+const minHashLength = 16
+
+// PathRule replaces the parts of the paths matching a regular expression with a template,
+// which may refer to the groups of the expression, e.g. ^/users/[^/]+ with /users/{name}.
+type PathRule struct {
+	Pattern  *regexp.Regexp
+	Template string
+}
+
+// ParsePathRule parses a rule given as a regular expression followed by its template, separated by whitespace,
+// e.g. `^/users/[^/]+ /users/{name}`.
+func ParsePathRule(rule string) (PathRule, error) {
+	fields := strings.Fields(rule)
+	if len(fields) != 2 {
+		return PathRule{}, fmt.Errorf("invalid path rule '%s': must be a regular expression and a template", rule)
+	}
+	pattern, err := regexp.Compile(fields[0])
+	if err != nil {
+		return PathRule{}, fmt.Errorf("invalid path rule '%s': %w", rule, err)
+	}
+	return PathRule{Pattern: pattern, Template: fields[1]}, nil
+}
+
+// LoadPathRules reads the path rules of a given file, one rule per line (see ParsePathRule).
+// Blank lines and lines starting with # are ignored.
+func LoadPathRules(name string) ([]PathRule, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var rules []PathRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := ParsePathRule(line)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// PathNormalizer turns the paths of the requests into the templates of their endpoints, e.g. /orders/123 into
+// /orders/{id}, so the top paths and the latencies group the requests by endpoint instead of by URL.
+// The given rules are applied first, then the segments of the paths made of digits, UUIDs and hexadecimal hashes
+// are replaced with PathID, PathUUID and PathHash. The query strings are left as is.
+type PathNormalizer struct {
+	rules []PathRule
+}
+
+// NewPathNormalizer creates a PathNormalizer using the given rules, in order, on top of the built-in ones.
+func NewPathNormalizer(rules ...PathRule) *PathNormalizer {
+	return &PathNormalizer{rules: rules}
+}
+
+// Normalize returns the template of a given path.
+func (n *PathNormalizer) Normalize(path string) string {
+	query := ""
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i:]
+	}
+	for _, rule := range n.rules {
+		path = rule.Pattern.ReplaceAllString(path, rule.Template)
+	}
+
+	segments := strings.Split(path, "/")
+	replaced := false
+	for i, segment := range segments {
+		if placeholder := segmentPlaceholder(segment); placeholder != "" {
+			segments[i] = placeholder
+			replaced = true
+		}
+	}
+	if replaced {
+		path = strings.Join(segments, "/")
+	}
+	return path + query
+}
+
+// Transform is a Middleware replacing the path of a log entry with its template, see LogsConfig.Middlewares.
+func (n *PathNormalizer) Transform(entry LogEntry) (LogEntry, bool) {
+	if entry.Path != "" {
+		entry.Path = n.Normalize(entry.Path)
+	}
+	return entry, true
+}
+
+// segmentPlaceholder returns the placeholder of a given segment of a path for the built-in rules, if any.
+func segmentPlaceholder(segment string) string {
+	switch {
+	case isDigits(segment):
+		return PathID
+	case isUUID(segment):
+		return PathUUID
+	case len(segment) >= minHashLength && isHash(segment):
+		return PathHash
+	}
+	return ""
+}
+
+// isUUID checks whether a given string is a UUID, e.g. 123e4567-e89b-12d3-a456-426614174000, in either case.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isHash checks whether a given string is made of hexadecimal digits, at least one of them a decimal one,
+// so that long words made of the letters a to f are not taken for hashes.
+func isHash(s string) bool {
+	digits := false
+	for i := 0; i < len(s); i++ {
+		if !isHexDigit(s[i]) {
+			return false
+		}
+		digits = digits || s[i] <= '9'
+	}
+	return digits
+}
+
+// isHexDigit checks whether a given byte is a hexadecimal digit, in either case.
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
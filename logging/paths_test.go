@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const pathsDataDir = "test/paths"
+
+type pathsSuite struct {
+	suite.Suite
+}
+
+func (s *pathsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(pathsDataDir))
+}
+
+func (s *pathsSuite) Test_Normalize() {
+	normalizer := NewPathNormalizer(PathRule{Pattern: regexp.MustCompile(`^/users/([^/]+)/avatar$`), Template: "/users/{name}/avatar"})
+	tests := map[string]string{
+		"/":                                   "/",
+		"/products":                           "/products",
+		"/products/123":                       "/products/{id}",
+		"/products/123/reviews/4?sort=recent": "/products/{id}/reviews/{id}?sort=recent",
+		"/orders/123e4567-E89B-12d3-a456-426614174000":    "/orders/{uuid}",
+		"/static/app.5d41402abc4b2a76b9719d911017c592.js": "/static/app.5d41402abc4b2a76b9719d911017c592.js",
+		"/blobs/5d41402abc4b2a76b9719d911017c592":         "/blobs/{hash}",
+		// long words made of hexadecimal letters only, and short hexadecimal segments, are kept
+		"/deadbeefcafebabedead": "/deadbeefcafebabedead",
+		"/v1/abc123":            "/v1/abc123",
+		"/users/alice/avatar":   "/users/{name}/avatar",
+		"/users/alice/42":       "/users/alice/{id}",
+	}
+	for path, expected := range tests {
+		s.Equal(expected, normalizer.Normalize(path), path)
+	}
+
+	entry, ok := normalizer.Transform(LogEntry{Path: "/products/123", IP: "10.0.0.1"})
+	s.True(ok)
+	s.Equal(LogEntry{Path: "/products/{id}", IP: "10.0.0.1"}, entry)
+}
+
+func (s *pathsSuite) Test_ParsePathRule() {
+	rule, err := ParsePathRule(`^/(fr|en)/  /{lang}/`)
+	s.Require().NoError(err)
+	s.Equal("/{lang}/about", NewPathNormalizer(rule).Normalize("/fr/about"))
+
+	_, err = ParsePathRule(`^/users/[^/]+`)
+	s.EqualError(err, "invalid path rule '^/users/[^/]+': must be a regular expression and a template")
+	_, err = ParsePathRule(`^/users/( /users`)
+	s.EqualError(err, "invalid path rule '^/users/( /users': error parsing regexp: missing closing ): `^/users/(`")
+}
+
+func (s *pathsSuite) Test_LoadPathRules() {
+	s.Require().NoError(os.MkdirAll(pathsDataDir, 0777))
+	name := filepath.Join(pathsDataDir, "rules.txt")
+	s.Require().NoError(os.WriteFile(name, []byte(`# endpoints of the shop
+^/users/[^/]+ /users/{name}
+
+^/tags/[^/]+$ /tags/{tag}
+`), 0666))
+
+	rules, err := LoadPathRules(name)
+
+	s.Require().NoError(err)
+	s.Len(rules, 2)
+	s.Equal("/users/{name}/orders/{id}", NewPathNormalizer(rules...).Normalize("/users/bob/orders/7"))
+	_, err = LoadPathRules(filepath.Join(pathsDataDir, "missing.txt"))
+	s.Error(err)
+}
+
+func TestPaths(t *testing.T) {
+	suite.Run(t, new(pathsSuite))
+}
@@ -0,0 +1,59 @@
+package logging
+
+import "context"
+
+// Pipe streams the log entries of a source (e.g. Logs.ForEach or Logs.Follow), called by its own goroutine,
+// through a buffered channel, so they can be received along with other events (e.g. tickers) in a select.
+//
+// The source is held back as soon as the buffer is full: at most the size of the buffer of log entries
+// are held in memory, and the log files are read no faster than the log entries are received.
+// The channel is closed once the source returns, after which Err returns its error, or once the pipe is closed.
+// Make sure to close the pipe once done with it, which stops the source and waits for its goroutine,
+// so no goroutine is left behind even when the log entries stop being received early (e.g. on error).
+type Pipe struct {
+	entries chan LogEntry
+	cancel  context.CancelFunc
+	err     error
+}
+
+// NewPipe starts calling a given source, the log entries being sent to a channel buffering up to a given number of them.
+// The source is stopped once the given context is done, the function sending the log entries returning its error.
+func NewPipe(ctx context.Context, source func(context.Context, func(LogEntry) error) error, size int) *Pipe {
+	if size < 0 {
+		size = 0
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Pipe{entries: make(chan LogEntry, size), cancel: cancel}
+	go func() {
+		// the error is set before the channel is closed, which makes it visible to the receivers of the channel
+		p.err = source(ctx, func(entry LogEntry) error {
+			select {
+			case p.entries <- entry:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(p.entries)
+	}()
+	return p
+}
+
+// Entries returns the channel of the log entries, closed once the source is done.
+func (p *Pipe) Entries() <-chan LogEntry {
+	return p.entries
+}
+
+// Err returns the error of the source, once the channel of the log entries is closed.
+func (p *Pipe) Err() error {
+	return p.err
+}
+
+// Close stops the source and waits for its goroutine to be done, dropping the log entries not received yet.
+// It returns the error of the source, which is the context error when the source had to be stopped.
+func (p *Pipe) Close() error {
+	p.cancel()
+	for range p.entries {
+	}
+	return p.err
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type pipeSuite struct {
+	suite.Suite
+}
+
+// countingSource returns a source calling the function for n log entries, counting the entries sent so far.
+func countingSource(n int, sent *int) func(context.Context, func(LogEntry) error) error {
+	return func(ctx context.Context, fn func(LogEntry) error) error {
+		for i := 0; i < n; i++ {
+			if err := fn(LogEntry{Path: fmt.Sprintf("/%d", i)}); err != nil {
+				return err
+			}
+			*sent = i + 1
+		}
+		return nil
+	}
+}
+
+func (s *pipeSuite) Test_Pipe() {
+	sent := 0
+	pipe := NewPipe(context.Background(), countingSource(10, &sent), 3)
+
+	var paths []string
+	for entry := range pipe.Entries() {
+		paths = append(paths, entry.Path)
+	}
+	s.Equal([]string{"/0", "/1", "/2", "/3", "/4", "/5", "/6", "/7", "/8", "/9"}, paths)
+	s.NoError(pipe.Err())
+	s.NoError(pipe.Close())
+}
+
+func (s *pipeSuite) Test_Pipe_SourceError() {
+	pipe := NewPipe(context.Background(), func(ctx context.Context, fn func(LogEntry) error) error {
+		if err := fn(LogEntry{Path: "/"}); err != nil {
+			return err
+		}
+		return ErrNoFilesInWindow
+	}, 0)
+
+	entry, ok := <-pipe.Entries()
+	s.True(ok)
+	s.Equal("/", entry.Path)
+	_, ok = <-pipe.Entries()
+	s.False(ok)
+	s.ErrorIs(pipe.Err(), ErrNoFilesInWindow)
+}
+
+func (s *pipeSuite) Test_Pipe_Backpressure() {
+	sent := 0
+	pipe := NewPipe(context.Background(), countingSource(100, &sent), 5)
+
+	<-pipe.Entries()
+	// the source is held back by the full buffer, the entry being sent being the only one outside of it
+	time.Sleep(20 * time.Millisecond)
+	s.Len(pipe.Entries(), 5)
+	s.ErrorIs(pipe.Close(), context.Canceled)
+	s.LessOrEqual(sent, 1+5+1)
+}
+
+func (s *pipeSuite) Test_Pipe_ClosedEarly() {
+	goroutines := runtime.NumGoroutine()
+	sent := 0
+	pipe := NewPipe(context.Background(), countingSource(100, &sent), 5)
+
+	<-pipe.Entries()
+	s.ErrorIs(pipe.Close(), context.Canceled)
+	s.Equal(goroutines, runtime.NumGoroutine())
+	_, ok := <-pipe.Entries()
+	s.False(ok)
+}
+
+func (s *pipeSuite) Test_Pipe_Canceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	block := make(chan struct{})
+	pipe := NewPipe(ctx, func(ctx context.Context, fn func(LogEntry) error) error {
+		close(block)
+		<-ctx.Done()
+		return ctx.Err()
+	}, 1)
+
+	<-block
+	cancel()
+	for range pipe.Entries() {
+		s.Fail("no entries expected")
+	}
+	s.ErrorIs(pipe.Err(), context.Canceled)
+}
+
+func TestPipe(t *testing.T) {
+	suite.Run(t, new(pipeSuite))
+}
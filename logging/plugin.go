@@ -0,0 +1,30 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// LoadPlugins loads the Go plugins of the given paths (see the plugin package), built with -buildmode=plugin against
+// the same version of this module, so the site-specific formats, filters, sinks and aggregations live outside of it:
+// the init functions of the plugins register them (see RegisterFormat, RegisterFilter, RegisterSink and
+// RegisterAggregator) as they are loaded. The paths are glob patterns, e.g. /etc/log-reader/plugins/*.so.
+// Loading a plugin twice is harmless, its init functions only run once. Plugins are only supported
+// on Linux, macOS and FreeBSD by binaries built with cgo, failing with ErrPluginsUnsupported otherwise.
+func LoadPlugins(paths ...string) error {
+	for _, pattern := range paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid plugin path '%s': %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no plugin found at '%s'", pattern)
+		}
+		for _, path := range matches {
+			if err := openPlugin(path); err != nil {
+				return fmt.Errorf("could not load plugin '%s': %w", path, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd) || !cgo
+// +build !linux,!darwin,!freebsd !cgo
+
+package logging
+
+// openPlugin fails, Go plugins being unsupported.
+func openPlugin(string) error {
+	return ErrPluginsUnsupported
+}
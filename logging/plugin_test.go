@@ -0,0 +1,22 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type pluginSuite struct {
+	suite.Suite
+}
+
+func (s *pluginSuite) Test_LoadPlugins() {
+	err := LoadPlugins("test/plugins/*.so")
+	s.EqualError(err, "no plugin found at 'test/plugins/*.so'")
+	err = LoadPlugins("[")
+	s.Error(err)
+}
+
+func TestPluginSuite(t *testing.T) {
+	suite.Run(t, new(pluginSuite))
+}
@@ -0,0 +1,13 @@
+//go:build (linux || darwin || freebsd) && cgo
+// +build linux darwin freebsd
+// +build cgo
+
+package logging
+
+import "plugin"
+
+// openPlugin opens a Go plugin, running its init functions.
+func openPlugin(path string) error {
+	_, err := plugin.Open(path)
+	return err
+}
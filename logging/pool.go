@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// The sizes of the buffers the log files are streamed through, by the size of the files (see LogsConfig.ReadBufferSize).
+const (
+	defaultReadBufferSize = 4 << 10
+	largeReadBufferSize   = 64 << 10
+	largeFileSize         = 64 << 20
+)
+
+// readerPool holds the buffered readers reading the log lines, so opening hundreds of rotated files
+// (e.g. searching each of them) doesn't allocate a buffer for every search step and every file.
+var readerPool = sync.Pool{
+	New: func() interface{} { return bufio.NewReaderSize(nil, defaultReadBufferSize) },
+}
+
+// sizedReaderPools holds the pools of the buffered readers of other sizes, by size (see getReaderSize).
+var sizedReaderPools sync.Map
+
+// getReader returns a buffered reader of the pool reading from a given reader.
+func getReader(r io.Reader) *bufio.Reader {
+	reader := readerPool.Get().(*bufio.Reader)
+	reader.Reset(r)
+	return reader
+}
+
+// getReaderSize returns a buffered reader of a given size reading from a given reader, from the pool of its size.
+func getReaderSize(r io.Reader, size int) *bufio.Reader {
+	if size == defaultReadBufferSize {
+		return getReader(r)
+	}
+	pool, _ := sizedReaderPools.LoadOrStore(size, &sync.Pool{
+		New: func() interface{} { return bufio.NewReaderSize(nil, size) },
+	})
+	reader := pool.(*sync.Pool).Get().(*bufio.Reader)
+	reader.Reset(r)
+	return reader
+}
+
+// putReader puts back a buffered reader into the pool of its size, once done reading from it.
+func putReader(reader *bufio.Reader) {
+	// the underlying reader (e.g. a file) isn't kept alive by the pool
+	reader.Reset(nil)
+	if size := reader.Size(); size != defaultReadBufferSize {
+		if pool, ok := sizedReaderPools.Load(size); ok {
+			pool.(*sync.Pool).Put(reader)
+		}
+		return
+	}
+	readerPool.Put(reader)
+}
+
+// readBufferSize returns the size of the buffer a log file of a given size is streamed through, see LogsConfig.ReadBufferSize.
+func (cfg LogsConfig) readBufferSize(fileSize int64) int {
+	switch {
+	case cfg.ReadBufferSize > 0:
+		return cfg.ReadBufferSize
+	case fileSize >= largeFileSize:
+		return largeReadBufferSize
+	default:
+		return defaultReadBufferSize
+	}
+}
+
+// writeBufferSize returns the size of the buffer Print writes the log lines to, see LogsConfig.WriteBufferSize.
+func (cfg LogsConfig) writeBufferSize() int {
+	if cfg.WriteBufferSize > 0 {
+		return cfg.WriteBufferSize
+	}
+	return printBufferSize
+}
+
+// blockPool holds the blocks read backwards by lineStart.
+var blockPool = sync.Pool{
+	New: func() interface{} {
+		block := make([]byte, lineStartBlockSize)
+		return &block
+	},
+}
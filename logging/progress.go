@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// progressStep is the number of bytes processed between two reports of the progress (see LogsConfig.Progress).
+const progressStep = 1 << 20
+
+// Progress is the progress of a call reading the logs (Print, ForEach, Entries, ...), see LogsConfig.Progress.
+type Progress struct {
+	// Done is the number of bytes of the files within the time range processed so far, either read
+	// or skipped (e.g. the logs before the time range), out of the Total bytes of the files when selected.
+	Done, Total int64
+	// Elapsed is the time since the call started.
+	Elapsed time.Duration
+}
+
+// ETA estimates the time left until the call is done from the rate of the bytes processed so far,
+// which is 0 when done and -1 when unknown yet.
+func (p Progress) ETA() time.Duration {
+	if p.Done >= p.Total {
+		return 0
+	}
+	if p.Done <= 0 || p.Elapsed <= 0 {
+		return -1
+	}
+	return time.Duration(float64(p.Elapsed) * float64(p.Total-p.Done) / float64(p.Done))
+}
+
+// progress tracks the progress of a single call, reporting it every progressStep bytes processed
+// and once done. The reports are never concurrent, even when the files are read in parallel.
+type progress struct {
+	report func(Progress)
+	start  time.Time
+
+	mu             sync.Mutex
+	done, reported int64
+	total          int64
+	finished       bool
+}
+
+// newProgress creates the progress of a call processing given files, or nil when the progress isn't reported.
+func (logs *Logs) newProgress(files []logFile) *progress {
+	if logs.cfg.Progress == nil {
+		return nil
+	}
+	p := &progress{report: logs.cfg.Progress, start: time.Now()}
+	for _, fi := range files {
+		p.total += fi.Size()
+	}
+	return p
+}
+
+// add counts a given number of bytes processed, reporting the progress every progressStep bytes.
+func (p *progress) add(n int64) {
+	if p == nil || n <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	if p.done-p.reported >= progressStep && !p.finished {
+		p.reported = p.done
+		p.report(Progress{Done: p.done, Total: p.total, Elapsed: time.Since(p.start)})
+	}
+}
+
+// finish reports the progress once done, all the bytes being processed, only once.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	p.report(Progress{Done: p.total, Total: p.total, Elapsed: time.Since(p.start)})
+}
+
+// file returns the progress of a given file, which counts its bytes up to its size when selected.
+func (p *progress) file(fi logFile) *fileProgress {
+	if p == nil {
+		return nil
+	}
+	return &fileProgress{progress: p, size: fi.Size()}
+}
+
+// fileProgress counts the bytes of a single file processed, by batches of progressStep bytes at most,
+// so the progress of the call isn't locked for every line. It is used by a single goroutine.
+type fileProgress struct {
+	progress *progress
+	size     int64
+	counted  int64
+	pending  int64
+}
+
+// add counts a given number of bytes of the file processed.
+func (f *fileProgress) add(n int64) {
+	if f == nil {
+		return
+	}
+	f.pending += n
+	if f.pending >= progressStep {
+		f.flush()
+	}
+}
+
+// flush counts the bytes of the file processed since the last flush towards the progress of the call,
+// never more than the size of the file, which may grow while read.
+func (f *fileProgress) flush() {
+	n := f.pending
+	if n > f.size-f.counted {
+		n = f.size - f.counted
+	}
+	f.progress.add(n)
+	f.counted += n
+	f.pending = 0
+}
+
+// done counts the rest of the file as processed, once done with it.
+func (f *fileProgress) done() {
+	if f == nil {
+		return
+	}
+	f.pending = f.size
+	f.flush()
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const progressDataDir = "test/progress"
+
+type progressSuite struct {
+	suite.Suite
+	testTime time.Time
+	size     int64
+}
+
+func (s *progressSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	// 3 rotated files of an hour each, of more than a megabyte each
+	for i := 0; i < 3; i++ {
+		var logs strings.Builder
+		from := t.Add(-time.Duration(3-i) * time.Hour)
+		for j := 0; j < 20000; j++ {
+			logs.WriteString(fmt.Sprintf("10.0.%d.%d - - [%s] \"GET /%d HTTP/1.1\" %d 10\n",
+				i, j%50, from.Add(time.Duration(j)*180*time.Millisecond).Format(dateTimeFormat), j, 200+j%2*300))
+		}
+		name := filepath.Join(progressDataDir, fmt.Sprintf("access.log.%d", 3-i))
+		modTime := from.Add(time.Hour)
+		writeLogFile(s.T(), name, logs.String(), modTime)
+		s.size += int64(logs.Len())
+	}
+}
+
+func (s *progressSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(progressDataDir)))
+}
+
+func (s *progressSuite) Test_Progress_Reported() {
+	for _, test := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Copied"},
+		{name: "Filtered", opts: []Option{WithFilter(func(entry LogEntry) bool { return entry.Status >= 500 })}},
+		{name: "Workers", opts: []Option{WithWorkers(2), WithMerge()}},
+		{name: "End", opts: []Option{WithEnd(s.testTime.Add(-90 * time.Minute))}},
+	} {
+		s.Run(test.name, func() {
+			var mu sync.Mutex
+			var reports []Progress
+			opts := append([]Option{WithDirectory(progressDataDir), WithWindow(150 * time.Minute), WithProgress(func(p Progress) {
+				mu.Lock()
+				defer mu.Unlock()
+				reports = append(reports, p)
+			})}, test.opts...)
+			logs, err := New(opts...)
+			s.Require().NoError(err)
+			logs.nowMinusT = func() time.Time {
+				return s.testTime.Add(-150 * time.Minute)
+			}
+			s.Require().NoError(logs.Print(context.Background(), &bytes.Buffer{}))
+
+			// the first file is within the window, some of its bytes being skipped, and the others are all read
+			s.Require().GreaterOrEqual(len(reports), 3)
+			for i, p := range reports {
+				s.Equal(s.size, p.Total)
+				if i > 0 {
+					s.GreaterOrEqual(p.Done, reports[i-1].Done)
+				}
+			}
+			s.Equal(s.size, reports[len(reports)-1].Done)
+			s.Zero(reports[len(reports)-1].ETA())
+		})
+	}
+}
+
+func (s *progressSuite) Test_Progress_NoFilesInWindow() {
+	called := false
+	logs, err := New(WithDirectory(progressDataDir), WithWindow(time.Minute), WithProgress(func(Progress) { called = true }))
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(time.Hour)
+	}
+	s.ErrorIs(logs.Print(context.Background(), &bytes.Buffer{}), ErrNoFilesInWindow)
+	s.False(called)
+}
+
+func (s *progressSuite) Test_Progress_ETA() {
+	s.Equal(time.Duration(-1), Progress{Total: 100}.ETA())
+	s.Equal(3*time.Second, Progress{Done: 25, Total: 100, Elapsed: time.Second}.ETA())
+	s.Zero(Progress{Done: 100, Total: 100, Elapsed: time.Second}.ETA())
+}
+
+func TestProgress(t *testing.T) {
+	suite.Run(t, new(progressSuite))
+}
@@ -0,0 +1,280 @@
+package logging
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The actions taken by Logs.Prune on the rotated log files.
+const (
+	PruneCompress = "compress"
+	PruneDelete   = "delete"
+)
+
+// DefaultPruneActive is how long after their last modification Logs.Prune takes the files as still written to by default,
+// see PruneConfig.Active.
+const DefaultPruneActive = time.Hour
+
+// pruneTempSuffix is the suffix of the compressed files being written by Logs.Prune, renamed once complete.
+const pruneTempSuffix = ".tmp"
+
+// PruneConfig configures which rotated log files Logs.Prune compresses and deletes.
+type PruneConfig struct {
+	// Keep is how long the rotated log files are kept after their last log line, forever when 0.
+	Keep time.Duration
+	// CompressAfter is how long after their last log line the rotated log files are compressed (gzip), never when 0.
+	CompressAfter time.Duration
+	// Now is the time the ages of the files are computed at, the current time when zero.
+	Now time.Time
+	// DryRun only reports the actions which would be taken, leaving the files as they are.
+	DryRun bool
+	// Active is how long after their last modification the files are taken as still written to and left out,
+	// e.g. the live logs of rotatelogs or of several sites sharing the directory, DefaultPruneActive when 0.
+	Active time.Duration
+	// ActivePattern, if any, matches the names of the files written to (see filepath.Match), left out
+	// whatever their modified time, e.g. "app-*.log.1" for the live logs which names end with a number.
+	ActivePattern string
+}
+
+// PruneAction is the compression or the deletion of a rotated log file, see Logs.Prune.
+type PruneAction struct {
+	File string `json:"file"`
+	// Action is either PruneCompress or PruneDelete.
+	Action string `json:"action"`
+	// Last is the time of the last log line of the file, or its modified time when it has none.
+	Last time.Time     `json:"last"`
+	Age  time.Duration `json:"age"`
+	// Size is the size of the file before the action.
+	Size int64 `json:"size"`
+}
+
+// Prune compresses and deletes the rotated log files of the directory, or of the directories of the hosts,
+// by their age, i.e. the time since their last log line, rather than their modified time, which a copy
+// or a backup may have changed. The rotated files are the ones which names end with a number, e.g. access.log.1
+// or access.log.1656000000, compressed (.gz) or not, the files still written to being left out: the latest modified
+// file of every directory, the ones modified within PruneConfig.Active and the ones matching PruneConfig.ActivePattern.
+// The files older than the retention (see PruneConfig.Keep) are deleted, along with their
+// sidecar index files, and the other ones older than PruneConfig.CompressAfter are compressed, keeping their
+// modified time so they're still selected by their time range (see Backfill).
+// The actions taken are returned even along with an error, which stops the pruning.
+func (logs *Logs) Prune(ctx context.Context, cfg PruneConfig) ([]PruneAction, error) {
+	if cfg.Now.IsZero() {
+		cfg.Now = time.Now()
+	}
+	if cfg.Active <= 0 {
+		cfg.Active = DefaultPruneActive
+	}
+	if _, err := filepath.Match(cfg.ActivePattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid active pattern '%s': %w", cfg.ActivePattern, err)
+	}
+	var actions []PruneAction
+	for _, dir := range logs.directories() {
+		files, err := rotatedFiles(dir, cfg)
+		if err != nil {
+			return actions, err
+		}
+		for _, fi := range files {
+			if err := ctx.Err(); err != nil {
+				return actions, err
+			}
+			last, err := logs.lastLogTime(ctx, fi)
+			if err != nil {
+				return actions, err
+			}
+			action := PruneAction{File: fi.path, Last: last, Age: cfg.Now.Sub(last), Size: fi.Size()}
+			compressed := strings.HasSuffix(fi.Name(), compressedSuffix)
+			switch {
+			case cfg.Keep > 0 && action.Age >= cfg.Keep:
+				action.Action = PruneDelete
+				if !cfg.DryRun {
+					err = removeLogFile(fi.path)
+				}
+			case cfg.CompressAfter > 0 && action.Age >= cfg.CompressAfter && !compressed:
+				action.Action = PruneCompress
+				if !cfg.DryRun {
+					err = compressLogFile(fi)
+				}
+			default:
+				continue
+			}
+			if err != nil {
+				return actions, err
+			}
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+// rotatedFiles lists the rotated log files of a given directory, compressed or not, the oldest first,
+// leaving out the ones still written to (see isActive) along with the latest modified file.
+func rotatedFiles(dir string, cfg PruneConfig) ([]logFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []logFile
+	for _, d := range entries {
+		if !d.Type().IsRegular() || isSidecarFile(d.Name()) {
+			continue
+		}
+		fi, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// rotated away since listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, logFile{FileInfo: fi, path: filepath.Join(dir, fi.Name())})
+	}
+	// the file written to wins the ties, e.g. of a coarse clock, over the rotated ones
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].ModTime().Equal(files[j].ModTime()) {
+			return isRotated(files[i].Name()) && !isRotated(files[j].Name())
+		}
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	if len(files) > 0 {
+		files = files[:len(files)-1]
+	}
+
+	rotated := files[:0]
+	for _, fi := range files {
+		if isRotated(fi.Name()) && !isActive(fi, cfg) {
+			rotated = append(rotated, fi)
+		}
+	}
+	return rotated, nil
+}
+
+// isRotated checks whether a given file name is the one of a rotated log file, ending with a number once
+// its compression suffix is stripped, e.g. access.log.1.gz or access_log.20220304.
+func isRotated(name string) bool {
+	name = strings.TrimSuffix(name, compressedSuffix)
+	return name != "" && name[len(name)-1] >= '0' && name[len(name)-1] <= '9'
+}
+
+// isActive checks whether a given file is still written to, modified within PruneConfig.Active
+// or named after PruneConfig.ActivePattern, e.g. a live log which name ends with a number.
+func isActive(fi logFile, cfg PruneConfig) bool {
+	if cfg.Now.Sub(fi.ModTime()) < cfg.Active {
+		return true
+	}
+	matched, _ := filepath.Match(cfg.ActivePattern, fi.Name())
+	return matched
+}
+
+// lastLogTime returns the time of the last log line of a given file, shifted by the offset of the clock of its host,
+// reading the compressed files till their end, or its modified time when none of its lines can be parsed.
+func (logs *Logs) lastLogTime(ctx context.Context, fi logFile) (time.Time, error) {
+	f, err := openLogFile(fi.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	name := strings.TrimSuffix(fi.path, compressedSuffix)
+	if name == fi.path {
+		file := NewFormatFile(f, logs.cfg.format(name))
+		file.skew, file.loc = logs.skew(fi.path), logs.cfg.TimeZone
+		if _, last, err := file.TimeRange(); err == nil {
+			return last, nil
+		}
+		return fi.ModTime(), nil
+	}
+
+	gz, err := gzip.NewReader(limitedReader{r: contextReader{ctx: ctx, r: f}, limiter: logs.limiter})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not read %s: %w", fi.path, err)
+	}
+	defer func() { _ = gz.Close() }()
+	file := NewFormatFile(nil, logs.cfg.format(name))
+	file.skew = logs.skew(fi.path)
+	last := fi.ModTime()
+	reader := bufio.NewReader(gz)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return time.Time{}, fmt.Errorf("could not read %s: %w", fi.path, err)
+		}
+		if line == "" {
+			return last, nil
+		}
+		if t, parseErr := file.parseLogTime(strings.TrimSpace(line)); parseErr == nil {
+			last = t
+		}
+	}
+}
+
+// compressLogFile compresses a given log file next to it (gzip), keeping its modified time,
+// then removes it along with its sidecar index file. The compressed file is written under a temporary name first,
+// so a failure never leaves a partial one, and an existing compressed file is never overwritten.
+func compressLogFile(fi logFile) error {
+	target := fi.path + compressedSuffix
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("could not compress %s: %s already exists", fi.path, target)
+	}
+	src, err := openLogFile(fi.path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	tmp := target + pruneTempSuffix
+	if err := writeCompressed(tmp, src, fi); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("could not compress %s: %w", fi.path, err)
+	}
+	if err := os.Chtimes(tmp, fi.ModTime(), fi.ModTime()); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return removeLogFile(fi.path)
+}
+
+// writeCompressed writes the content of a given log file to a new compressed file, synced to the disk.
+func writeCompressed(name string, src io.Reader, fi logFile) error {
+	dst, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	gz.Name, gz.ModTime = fi.Name(), fi.ModTime()
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// removeLogFile removes a given log file along with its sidecar index file, if any.
+func removeLogFile(name string) error {
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+	if err := os.Remove(indexPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const pruneDataDir = "test/prune"
+
+type pruneSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (s *pruneSuite) SetupTest() {
+	s.Require().NoError(os.MkdirAll(pruneDataDir, 0777))
+	s.writeGzip("access.log.4.gz", `10.0.0.1 - - [01/Jan/2022:10:00:00 +0000] "GET /a HTTP/1.1" 200 20
+`)
+	s.writeFile("access.log.3", `10.0.0.1 - - [15/Jan/2022:10:00:00 +0000] "GET /b HTTP/1.1" 200 20
+`)
+	s.writeFile("access.log.3"+IndexSuffix, "index")
+	s.writeFile("access.log.2", `10.0.0.1 - - [25/Feb/2022:10:00:00 +0000] "GET /c HTTP/1.1" 200 20
+`)
+	s.writeFile("access.log.1", `10.0.0.1 - - [02/Mar/2022:10:00:00 +0000] "GET /d HTTP/1.1" 200 20
+`)
+	s.writeFile("access.log", `10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /e HTTP/1.1" 200 20
+`)
+
+	// the modified times are all recent, e.g. after a copy, but access.log, which is written to
+	recent, err := time.Parse(dateTimeFormat, "02/Mar/2022:23:00:00 +0000")
+	s.Require().NoError(err)
+	for _, name := range []string{"access.log.4.gz", "access.log.3", "access.log.2", "access.log.1"} {
+		s.Require().NoError(os.Chtimes(filepath.Join(pruneDataDir, name), recent, recent))
+	}
+	s.now, err = time.Parse(dateTimeFormat, "03/Mar/2022:12:00:00 +0000")
+	s.Require().NoError(err)
+	s.Require().NoError(os.Chtimes(filepath.Join(pruneDataDir, "access.log"), s.now, s.now))
+}
+
+func (s *pruneSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(pruneDataDir)))
+}
+
+func (s *pruneSuite) writeFile(name, content string) {
+	s.Require().NoError(os.WriteFile(filepath.Join(pruneDataDir, name), []byte(content), 0666))
+}
+
+// writeGzip writes a compressed log file.
+func (s *pruneSuite) writeGzip(name, content string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	s.Require().NoError(err)
+	s.Require().NoError(gz.Close())
+	s.writeFile(name, buf.String())
+}
+
+// prune prunes the test directory, returning the actions taken and the names of the files left.
+func (s *pruneSuite) prune(cfg PruneConfig) (map[string]string, []string) {
+	logs, err := New(WithDirectory(pruneDataDir), WithEnd(s.now))
+	s.Require().NoError(err)
+	cfg.Now = s.now
+	actions, err := logs.Prune(context.Background(), cfg)
+	s.Require().NoError(err)
+
+	taken := make(map[string]string)
+	for _, action := range actions {
+		taken[filepath.Base(action.File)] = action.Action
+	}
+	entries, err := os.ReadDir(pruneDataDir)
+	s.Require().NoError(err)
+	var left []string
+	for _, d := range entries {
+		left = append(left, d.Name())
+	}
+	return taken, left
+}
+
+func (s *pruneSuite) Test_Prune() {
+	taken, left := s.prune(PruneConfig{Keep: 30 * 24 * time.Hour, CompressAfter: 2 * 24 * time.Hour})
+	s.Equal(map[string]string{
+		"access.log.4.gz": PruneDelete,
+		"access.log.3":    PruneDelete,
+		"access.log.2":    PruneCompress,
+	}, taken)
+	s.Equal([]string{"access.log", "access.log.1", "access.log.2.gz"}, left)
+
+	// the compressed file keeps the modified time and the content of the original
+	fi, err := os.Stat(filepath.Join(pruneDataDir, "access.log.2.gz"))
+	s.Require().NoError(err)
+	s.True(fi.ModTime().Before(s.now))
+	logs, err := New(WithDirectory(pruneDataDir), WithWindow(7*24*time.Hour), WithEnd(s.now))
+	s.Require().NoError(err)
+	var paths []string
+	s.Require().NoError(logs.Backfill(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+	s.Equal([]string{"/c", "/d", "/e"}, paths)
+}
+
+func (s *pruneSuite) Test_Prune_DryRun() {
+	taken, left := s.prune(PruneConfig{Keep: 30 * 24 * time.Hour, CompressAfter: 2 * 24 * time.Hour, DryRun: true})
+	s.Len(taken, 3)
+	s.Len(left, 6)
+}
+
+func (s *pruneSuite) Test_Prune_CompressOnly() {
+	taken, left := s.prune(PruneConfig{CompressAfter: 12 * time.Hour})
+	s.Equal(map[string]string{
+		"access.log.3": PruneCompress,
+		"access.log.2": PruneCompress,
+		"access.log.1": PruneCompress,
+	}, taken)
+	s.Equal([]string{"access.log", "access.log.1.gz", "access.log.2.gz", "access.log.3.gz", "access.log.4.gz"}, left)
+}
+
+func (s *pruneSuite) Test_Prune_Active() {
+	// the live logs of other sites, which names end with a number: b.log.20220303 was written to half an hour ago,
+	// although its last log line is older, and c.log.1 is quiet
+	writeLogFile(s.T(), filepath.Join(pruneDataDir, "b.log.20220303"), `10.0.0.1 - - [02/Mar/2022:20:00:00 +0000] "GET /f HTTP/1.1" 200 20
+`, s.now.Add(-30*time.Minute))
+	writeLogFile(s.T(), filepath.Join(pruneDataDir, "c.log.1"), `10.0.0.1 - - [02/Mar/2022:10:00:00 +0000] "GET /g HTTP/1.1" 200 20
+`, s.now.Add(-13*time.Hour))
+
+	taken, _ := s.prune(PruneConfig{CompressAfter: 12 * time.Hour, ActivePattern: "c.log.*"})
+	s.Equal(map[string]string{
+		"access.log.3": PruneCompress,
+		"access.log.2": PruneCompress,
+		"access.log.1": PruneCompress,
+	}, taken)
+
+	taken, _ = s.prune(PruneConfig{CompressAfter: 12 * time.Hour, Active: 10 * time.Minute})
+	s.Equal(map[string]string{
+		"b.log.20220303": PruneCompress,
+		"c.log.1":        PruneCompress,
+	}, taken)
+}
+
+func (s *pruneSuite) Test_Prune_InvalidActivePattern() {
+	logs, err := New(WithDirectory(pruneDataDir), WithEnd(s.now))
+	s.Require().NoError(err)
+
+	_, err = logs.Prune(context.Background(), PruneConfig{CompressAfter: time.Hour, ActivePattern: "["})
+
+	s.Require().ErrorIs(err, filepath.ErrBadPattern)
+}
+
+func TestPruneSuite(t *testing.T) {
+	suite.Run(t, new(pruneSuite))
+}
@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate is a number of requests per interval of time, e.g. 100 requests per minute.
+type Rate struct {
+	Limit int
+	Per   time.Duration
+}
+
+// ParseRate parses a rate written as the number of requests per interval, e.g. "100/1m",
+// where the interval may leave out a count of 1, e.g. "100/m".
+func ParseRate(s string) (Rate, error) {
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return Rate{}, fmt.Errorf("invalid rate '%s': expected requests/interval, e.g. 100/1m", s)
+	}
+
+	limit, err := strconv.Atoi(s[:i])
+	if err != nil || limit <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate '%s': the number of requests must be a positive integer", s)
+	}
+	per, err := time.ParseDuration(s[i+1:])
+	if err != nil {
+		per, err = time.ParseDuration("1" + s[i+1:])
+	}
+	if err != nil || per <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate '%s': the interval must be a positive duration", s)
+	}
+
+	return Rate{Limit: limit, Per: per}, nil
+}
+
+func (rate Rate) String() string {
+	return fmt.Sprintf("%d/%s", rate.Limit, rate.Per)
+}
+
+// RateLimit finds the clients which requests logged within the last N minutes exceeded a Rate,
+// i.e. the clients that made more requests than the limit within any interval of the rate (a sliding window).
+type RateLimit struct {
+	rate Rate
+	// times holds the times of the latest requests by IP, within the interval of the rate
+	times     map[string][]time.Time
+	requests  map[string]int64
+	offenders map[string]*RateLimitOffender
+}
+
+// RateLimitOffender is a client that exceeded the rate.
+type RateLimitOffender struct {
+	IP string `json:"ip"`
+	// Requests is the number of requests of the client within the last N minutes.
+	Requests int64 `json:"requests"`
+	// Peak is the highest number of requests of the client within an interval of the rate.
+	Peak int `json:"peak"`
+	// Since is the time of the request the client first exceeded the rate with.
+	Since time.Time `json:"since"`
+}
+
+// NewRateLimit creates an empty RateLimit for a given rate.
+func NewRateLimit(rate Rate) *RateLimit {
+	return &RateLimit{
+		rate:      rate,
+		times:     make(map[string][]time.Time),
+		requests:  make(map[string]int64),
+		offenders: make(map[string]*RateLimitOffender),
+	}
+}
+
+// RateLimit reads the log entries that happened within the last N minutes and finds the clients exceeding a given rate.
+// The offenders are returned even along with an error (e.g. ErrNoFilesInWindow), accounting for the entries read until then.
+func (logs *Logs) RateLimit(ctx context.Context, rate Rate) (*RateLimit, error) {
+	rl := NewRateLimit(rate)
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		rl.Add(entry)
+		return nil
+	})
+
+	return rl, err
+}
+
+// Add counts a given log entry towards the rate of its client, ignoring the ones that are not requests.
+// The entries are expected in the order of their times, as read by Logs.
+func (rl *RateLimit) Add(entry LogEntry) {
+	if entry.IP == "" {
+		return
+	}
+	rl.requests[entry.IP]++
+
+	times := append(rl.times[entry.IP], entry.Time)
+	start := 0
+	for start < len(times) && entry.Time.Sub(times[start]) >= rl.rate.Per {
+		start++
+	}
+	times = times[start:]
+	rl.times[entry.IP] = times
+
+	if len(times) <= rl.rate.Limit {
+		return
+	}
+	offender, ok := rl.offenders[entry.IP]
+	if !ok {
+		offender = &RateLimitOffender{IP: entry.IP, Since: entry.Time}
+		rl.offenders[entry.IP] = offender
+	}
+	if len(times) > offender.Peak {
+		offender.Peak = len(times)
+	}
+}
+
+// Result returns the clients that exceeded the rate, see Offenders.
+func (rl *RateLimit) Result() interface{} {
+	return rl.Offenders()
+}
+
+// Offenders returns the clients that exceeded the rate, the highest peak first. Ties are ordered by IP.
+func (rl *RateLimit) Offenders() []RateLimitOffender {
+	offenders := make([]RateLimitOffender, 0, len(rl.offenders))
+	for ip, offender := range rl.offenders {
+		o := *offender
+		o.Requests = rl.requests[ip]
+		offenders = append(offenders, o)
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Peak == offenders[j].Peak {
+			return offenders[i].IP < offenders[j].IP
+		}
+		return offenders[i].Peak > offenders[j].Peak
+	})
+
+	return offenders
+}
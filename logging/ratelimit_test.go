@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const rateLimitDataDir = "test/ratelimit"
+
+type rateLimitSuite struct {
+	suite.Suite
+	logs     *Logs
+	testTime time.Time
+}
+
+func (s *rateLimitSuite) SetupSuite() {
+	var logs strings.Builder
+	for i := 0; i < 10; i++ {
+		// 10.0.0.1 makes a request every 6 seconds, 10.0.0.2 makes 2 requests every 6 seconds
+		logs.WriteString(fmt.Sprintf("10.0.0.1 - - [03/Mar/2022:02:44:%02d +0000] \"GET / HTTP/1.1\" 200 20\n", i*6))
+		logs.WriteString(fmt.Sprintf("10.0.0.2 - - [03/Mar/2022:02:44:%02d +0000] \"GET / HTTP/1.1\" 200 20\n", i*6))
+		logs.WriteString(fmt.Sprintf("10.0.0.2 - - [03/Mar/2022:02:44:%02d +0000] \"GET / HTTP/1.1\" 200 20\n", i*6))
+		if i == 5 {
+			// 10.0.0.3 makes a burst of 5 requests
+			for j := 0; j < 5; j++ {
+				logs.WriteString(fmt.Sprintf("10.0.0.3 - - [03/Mar/2022:02:44:%02d +0000] \"GET / HTTP/1.1\" 200 20\n", i*6))
+			}
+		}
+	}
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(rateLimitDataDir, "access.log"), logs.String(), t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(rateLimitDataDir))
+}
+
+func (s *rateLimitSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(rateLimitDataDir)))
+}
+
+func (s *rateLimitSuite) Test_ParseRate() {
+	tests := []struct {
+		rate     string
+		expected Rate
+		err      bool
+	}{
+		{rate: "100/1m", expected: Rate{Limit: 100, Per: time.Minute}},
+		{rate: "5/10s", expected: Rate{Limit: 5, Per: 10 * time.Second}},
+		{rate: "1000/h", expected: Rate{Limit: 1000, Per: time.Hour}},
+		{rate: "100", err: true},
+		{rate: "0/1m", err: true},
+		{rate: "x/1m", err: true},
+		{rate: "100/0s", err: true},
+		{rate: "100/forever", err: true},
+	}
+	for _, test := range tests {
+		s.Run(test.rate, func() {
+			rate, err := ParseRate(test.rate)
+			if test.err {
+				s.Error(err)
+				return
+			}
+			s.NoError(err)
+			s.Equal(test.expected, rate)
+		})
+	}
+}
+
+func (s *rateLimitSuite) Test_RateLimit() {
+	rl, err := s.logs.RateLimit(context.Background(), Rate{Limit: 3, Per: 10 * time.Second})
+	s.Require().NoError(err)
+
+	s.Equal([]RateLimitOffender{
+		{IP: "10.0.0.3", Requests: 5, Peak: 5, Since: s.testTime.Add(-30 * time.Second)},
+		{IP: "10.0.0.2", Requests: 20, Peak: 4, Since: s.testTime.Add(-54 * time.Second)},
+	}, rl.Offenders())
+}
+
+func (s *rateLimitSuite) Test_RateLimit_NoOffenders() {
+	rl, err := s.logs.RateLimit(context.Background(), Rate{Limit: 20, Per: time.Minute})
+	s.Require().NoError(err)
+
+	s.Empty(rl.Offenders())
+}
+
+func TestRateLimit(t *testing.T) {
+	suite.Run(t, new(rateLimitSuite))
+}
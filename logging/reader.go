@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+	"io"
+)
+
+// Reader returns an io.ReadCloser streaming the log lines that happened within the last N minutes,
+// the same way Print does, so they can be piped (e.g. into a compressor or an HTTP response)
+// without buffering them first. Reads fail once the given context is done.
+// No goroutine is involved: the log files are read as the reader is, a line at a time,
+// so a slow consumer simply holds the reading back (see Pipe to receive the log entries from a channel).
+// Having no log files within the time window fails the first read with ErrNoFilesInWindow, the way Print does.
+// Make sure to close the reader once done with it.
+func (logs *Logs) Reader(ctx context.Context) io.ReadCloser {
+	return &logsReader{it: logs.Entries(ctx)}
+}
+
+// logsReader reads the log lines of an Iterator, one line at a time.
+type logsReader struct {
+	it *Iterator
+	// pending holds the part of the current line that hasn't been read yet
+	pending []byte
+	buf     []byte
+}
+
+func (r *logsReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.pending) == 0 {
+			if !r.it.Next() {
+				break
+			}
+			r.buf = append(append(r.buf[:0], r.it.Entry().Line...), '\n')
+			r.pending = r.buf
+		}
+
+		copied := copy(p[n:], r.pending)
+		r.pending = r.pending[copied:]
+		n += copied
+	}
+	if n > 0 {
+		return n, nil
+	}
+
+	if err := r.it.Err(); err != nil {
+		return 0, err
+	}
+	return 0, io.EOF
+}
+
+func (r *logsReader) Close() error {
+	return r.it.Close()
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const readerDataDir = "test/reader"
+
+type readerSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *readerSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(readerDataDir, "http-1.log"), `10.0.0.1 - frank [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.0" 200 20
+10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.0" 200 20
+`, t)
+	writeLogFile(s.T(), filepath.Join(readerDataDir, "http-2.log"), `10.0.0.3 - frank [03/Mar/2022:02:44:30 +0000] "GET /c HTTP/1.0" 200 20
+`, t.Add(time.Second))
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(readerDataDir))
+}
+
+func (s *readerSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(readerDataDir)))
+}
+
+func (s *readerSuite) Test_Reader() {
+	r := s.logs.Reader(context.Background())
+	defer func() { s.NoError(r.Close()) }()
+
+	data, err := io.ReadAll(r)
+
+	s.NoError(err)
+	s.Equal(`10.0.0.1 - frank [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.0" 200 20
+10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.0" 200 20
+10.0.0.3 - frank [03/Mar/2022:02:44:30 +0000] "GET /c HTTP/1.0" 200 20
+`, string(data))
+}
+
+func (s *readerSuite) Test_Reader_SmallReads() {
+	r := s.logs.Reader(context.Background())
+	defer func() { s.NoError(r.Close()) }()
+
+	// read through a tiny buffer, so lines get split across reads
+	buf := make([]byte, 7)
+	var data []byte
+	for {
+		n, err := r.Read(buf)
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		s.Require().NoError(err)
+	}
+
+	s.Len(data, 3*71)
+}
+
+func (s *readerSuite) Test_Reader_Cancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := s.logs.Reader(ctx)
+	defer func() { s.NoError(r.Close()) }()
+
+	n, err := r.Read(make([]byte, 10))
+
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(0, n)
+}
+
+func TestReader(t *testing.T) {
+	suite.Run(t, new(readerSuite))
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readLimiter is a token bucket limiting the number of bytes read from the log files per second
+// (see LogsConfig.MaxReadRate), shared by all the files and calls of a Logs so their reads are limited altogether.
+// The bucket holds a second worth of bytes at most, which are read at full speed after a pause.
+type readLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	// now and sleep are the clock of the limiter, replaced by the tests
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// newReadLimiter creates a readLimiter of a given number of bytes per second, starting with a full bucket.
+func newReadLimiter(bytesPerSecond int64) *readLimiter {
+	return &readLimiter{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// wait takes a given number of bytes just read out of the bucket, sleeping until they're paid for when it runs dry.
+// The bytes are taken once read, so a read is never held back, only the ones following it.
+func (l *readLimiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	// the deficit is paid by this reader, the bucket being empty for the next ones until then
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay > 0 {
+		l.sleep(delay)
+	}
+}
+
+// readBudget counts the bytes read from the log files by all the files and calls of a Logs (see LogsConfig.MaxBytesRead),
+// failing the reads once there were more than its maximum. It is safe for concurrent use.
+type readBudget struct {
+	max, read int64
+}
+
+// spend counts a given number of bytes just read, failing with ErrBudgetExceeded once more than the maximum were read.
+func (b *readBudget) spend(n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+	if atomic.AddInt64(&b.read, int64(n)) > b.max {
+		return fmt.Errorf("%w: more than %d bytes read", ErrBudgetExceeded, b.max)
+	}
+	return nil
+}
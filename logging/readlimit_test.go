@@ -0,0 +1,181 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const readLimitDataDir = "test/readlimit"
+
+type readLimitSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *readLimitSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	var logs strings.Builder
+	for i := 0; i < 1000; i++ {
+		logs.WriteString(fmt.Sprintf("10.0.0.%d - - [%s] \"GET /%d HTTP/1.1\" %d 10\n",
+			i%10, t.Add(-time.Hour).Add(time.Duration(i)*3*time.Second).Format(dateTimeFormat), i, 200+i%2*300))
+	}
+	writeLogFile(s.T(), filepath.Join(readLimitDataDir, "access.log"), logs.String(), t)
+}
+
+func (s *readLimitSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(readLimitDataDir)))
+}
+
+// fakeClock is a clock only moving forward when slept on, recording how long it was slept on.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) limiter(bytesPerSecond int64) *readLimiter {
+	l := newReadLimiter(bytesPerSecond)
+	l.last = c.now
+	l.now = func() time.Time {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.now
+	}
+	l.sleep = func(d time.Duration) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.now = c.now.Add(d)
+		c.slept += d
+	}
+	return l
+}
+
+func (s *readLimitSuite) Test_readLimiter_Paced() {
+	clock := &fakeClock{now: s.testTime}
+	l := clock.limiter(1000)
+
+	// the first second worth of bytes is read at once, the rest at 1000 bytes per second
+	for i := 0; i < 6; i++ {
+		l.wait(500)
+	}
+	s.Equal(2*time.Second, clock.slept)
+
+	// the bucket refills while idle, up to a second worth of bytes
+	clock.now = clock.now.Add(time.Minute)
+	clock.slept = 0
+	l.wait(1000)
+	s.Zero(clock.slept)
+	l.wait(1500)
+	s.Equal(1500*time.Millisecond, clock.slept)
+}
+
+func (s *readLimitSuite) Test_readLimiter_Nil() {
+	var l *readLimiter
+	s.NotPanics(func() { l.wait(1 << 20) })
+}
+
+func (s *readLimitSuite) Test_Logs_WithMaxReadRate() {
+	for _, test := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Copied"},
+		{name: "Filtered", opts: []Option{WithFilter(func(entry LogEntry) bool { return entry.Status >= 500 })}},
+		{name: "Memory Mapped", opts: []Option{WithMemoryMap(), WithIP("10.0.0.3")}},
+		{name: "Workers", opts: []Option{WithWorkers(2), WithMerge()}},
+	} {
+		s.Run(test.name, func() {
+			opts := append([]Option{WithDirectory(readLimitDataDir), WithWindow(30 * time.Minute)}, test.opts...)
+			printLogs := func(opts ...Option) string {
+				logs, err := New(opts...)
+				s.Require().NoError(err)
+				logs.nowMinusT = func() time.Time {
+					return s.testTime.Add(-30 * time.Minute)
+				}
+				clock := &fakeClock{now: s.testTime}
+				if logs.limiter != nil {
+					logs.limiter = clock.limiter(logs.cfg.MaxReadRate)
+				}
+				var out bytes.Buffer
+				s.Require().NoError(logs.Print(context.Background(), &out))
+				if logs.limiter != nil {
+					// half of the file is within the window, read at 1000 bytes per second after the first second
+					size := int64(0)
+					for _, fi := range logs.filesInfo {
+						size += fi.Size()
+					}
+					s.GreaterOrEqual(clock.slept, time.Duration(size/2-1000)*time.Millisecond)
+				}
+				return out.String()
+			}
+
+			expected := printLogs(opts...)
+			s.Require().NotEmpty(expected)
+			s.Equal(expected, printLogs(append(opts, WithMaxReadRate(1000))...))
+		})
+	}
+}
+
+func (s *readLimitSuite) Test_Logs_WithMaxBytesRead() {
+	for _, test := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Copied"},
+		{name: "Filtered", opts: []Option{WithFilter(func(entry LogEntry) bool { return entry.Status >= 500 })}},
+		{name: "Memory Mapped", opts: []Option{WithMemoryMap(), WithIP("10.0.0.3")}},
+		{name: "Workers", opts: []Option{WithWorkers(2), WithMerge()}},
+		{name: "Reversed", opts: []Option{WithEnd(s.testTime)}},
+	} {
+		s.Run(test.name, func() {
+			opts := append([]Option{WithDirectory(readLimitDataDir), WithWindow(30 * time.Minute), WithEnd(s.testTime)}, test.opts...)
+			printLogs := func(opts ...Option) (string, error) {
+				logs, err := New(opts...)
+				s.Require().NoError(err)
+				var out bytes.Buffer
+				if test.name == "Reversed" {
+					err = logs.ForEachReverse(context.Background(), func(entry LogEntry) error {
+						out.WriteString(entry.Line + "\n")
+						return nil
+					})
+				} else {
+					err = logs.Print(context.Background(), &out)
+				}
+				return out.String(), err
+			}
+
+			expected, err := printLogs(opts...)
+			s.Require().NoError(err)
+			s.Require().NotEmpty(expected)
+			// the whole file fits in the budget
+			out, err := printLogs(append(opts, WithMaxBytesRead(1<<20))...)
+			s.Require().NoError(err)
+			s.Equal(expected, out)
+
+			// half of the file is within the window
+			_, err = printLogs(append(opts, WithMaxBytesRead(10000))...)
+			s.Require().ErrorIs(err, ErrBudgetExceeded)
+			s.Contains(err.Error(), "more than 10000 bytes read")
+		})
+	}
+}
+
+func (s *readLimitSuite) Test_Logs_WithMaxBytesRead_Invalid() {
+	_, err := New(WithDirectory(readLimitDataDir), WithMaxBytesRead(-1))
+	s.EqualError(err, "invalid max bytes read: must not be negative")
+}
+
+func TestReadLimit(t *testing.T) {
+	suite.Run(t, new(readLimitSuite))
+}
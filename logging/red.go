@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultREDWindows are the rolling windows of REDMetrics by default: the last 1, 5 and 15 minutes.
+var DefaultREDWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// redBuckets is the number of buckets the shortest window of REDMetrics is divided into, so the windows roll
+// by a sixth of it at a time, e.g. every 10 seconds for 1 minute.
+const redBuckets = 6
+
+// REDMetrics maintains the RED metrics (rate, errors, duration) of the requests logged over rolling windows
+// (e.g. the last 1, 5 and 15 minutes), updated by every log entry as it's read, e.g. while following the logs
+// (see Logs.Follow), so the reader serves the metrics of a web server in real time without a log pipeline.
+// The entries are accounted for when added rather than when they happened, and bucketed by a fraction of the shortest
+// window (see redBuckets), so the oldest bucket of a window leaves it at once. The latencies of every bucket are
+// summarized by a t-digest, so it uses constant memory however many requests are logged. It is safe for concurrent use.
+type REDMetrics struct {
+	mu      sync.Mutex
+	clock   func() time.Time
+	windows []time.Duration
+	// width is the time span of a bucket, the buckets being reused in a ring spanning the longest window
+	width   time.Duration
+	buckets []redBucket
+}
+
+// redBucket holds the metrics of the requests added within a span of time.
+type redBucket struct {
+	// slot is the number of the span of time of the bucket since the Unix epoch, telling whether it's stale
+	slot             int64
+	requests, errors int64
+	latencies        *tdigest
+}
+
+// REDSnapshot holds the RED metrics of the requests added within a rolling window, see REDMetrics.Snapshot.
+type REDSnapshot struct {
+	Window time.Duration `json:"window"`
+	// Requests counts the requests of the window, and Errors the ones answered with a server error (5xx).
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+	// RequestRate is the number of requests per second over the window.
+	RequestRate float64 `json:"request_rate"`
+	// ErrorRate is the share of the requests answered with a server error, 0 without requests.
+	ErrorRate float64 `json:"error_rate"`
+	// Latency holds the latency percentiles of the requests logging the time taken to serve them (%D).
+	Latency LatencyPercentiles `json:"latency"`
+}
+
+// NewREDMetrics creates empty REDMetrics over given rolling windows (DefaultREDWindows when none),
+// the current time being given by a clock (time.Now when nil), e.g. the fake clock of a test (see loggingtest.Clock).
+func NewREDMetrics(clock func() time.Time, windows ...time.Duration) *REDMetrics {
+	if clock == nil {
+		clock = time.Now
+	}
+	var kept []time.Duration
+	for _, window := range windows {
+		if window > 0 {
+			kept = append(kept, window)
+		}
+	}
+	if len(kept) == 0 {
+		kept = DefaultREDWindows
+	}
+	kept = append([]time.Duration(nil), kept...)
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i] < kept[j]
+	})
+	width := kept[0] / redBuckets
+	if width < time.Second {
+		width = time.Second
+	}
+	return &REDMetrics{
+		clock:   clock,
+		windows: kept,
+		width:   width,
+		// one more bucket than the longest window spans, the current one being partly elapsed
+		buckets: make([]redBucket, int(kept[len(kept)-1]/width)+1),
+	}
+}
+
+// Add accounts for a given log entry, ignoring the invalid ones and the ones which aren't requests (e.g. the lines of an error log).
+func (m *REDMetrics) Add(entry LogEntry) {
+	if entry.Invalid || entry.IP == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	slot := m.clock().UnixNano() / int64(m.width)
+	b := &m.buckets[slot%int64(len(m.buckets))]
+	if b.slot != slot {
+		*b = redBucket{slot: slot}
+	}
+	b.requests++
+	if entry.serverError() {
+		b.errors++
+	}
+	if entry.Duration > 0 {
+		if b.latencies == nil {
+			b.latencies = newTDigest(tdigestCompression)
+		}
+		b.latencies.add(float64(entry.Duration))
+	}
+}
+
+// Snapshot returns the RED metrics of every rolling window at the current time, the shortest window first.
+func (m *REDMetrics) Snapshot() []REDSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.clock().UnixNano() / int64(m.width)
+	snapshots := make([]REDSnapshot, 0, len(m.windows))
+	for _, window := range m.windows {
+		// the buckets of the window are the current one and the ones before it, as many as the window spans
+		oldest := current - int64(window/m.width) + 1
+		snapshot := REDSnapshot{Window: window}
+		latencies := newTDigest(tdigestCompression)
+		for i := range m.buckets {
+			b := &m.buckets[i]
+			if b.slot < oldest || b.slot > current || b.requests == 0 {
+				continue
+			}
+			snapshot.Requests += b.requests
+			snapshot.Errors += b.errors
+			if b.latencies != nil {
+				latencies.addDigest(b.latencies)
+			}
+		}
+		snapshot.RequestRate = float64(snapshot.Requests) / window.Seconds()
+		if snapshot.Requests > 0 {
+			snapshot.ErrorRate = float64(snapshot.Errors) / float64(snapshot.Requests)
+		}
+		snapshot.Latency = percentiles("", latencies)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
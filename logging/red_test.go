@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type redSuite struct {
+	suite.Suite
+	now time.Time
+	red *REDMetrics
+}
+
+func (s *redSuite) SetupTest() {
+	s.now = time.Date(2022, 3, 3, 2, 45, 0, 0, time.UTC)
+	s.red = NewREDMetrics(func() time.Time { return s.now })
+}
+
+func (s *redSuite) add(n int, status int, duration time.Duration) {
+	for i := 0; i < n; i++ {
+		s.red.Add(LogEntry{IP: "10.0.0.1", Status: status, Duration: duration})
+	}
+}
+
+func (s *redSuite) Test_Snapshot() {
+	// 10 minutes ago: only within the 15 minutes
+	s.now = s.now.Add(-10 * time.Minute)
+	s.add(60, 200, 100*time.Millisecond)
+	// 2 minutes ago: within the 5 and 15 minutes
+	s.now = s.now.Add(8 * time.Minute)
+	s.add(30, 500, time.Second)
+	// now: within every window
+	s.now = s.now.Add(2 * time.Minute)
+	s.add(6, 200, 10*time.Millisecond)
+	s.add(6, 503, 0)
+	// neither invalid lines nor lines which aren't requests count
+	s.red.Add(LogEntry{Invalid: true})
+	s.red.Add(LogEntry{Status: 500})
+
+	snapshots := s.red.Snapshot()
+	s.Require().Len(snapshots, 3)
+
+	s.Equal(time.Minute, snapshots[0].Window)
+	s.Equal(int64(12), snapshots[0].Requests)
+	s.Equal(int64(6), snapshots[0].Errors)
+	s.InDelta(0.2, snapshots[0].RequestRate, 1e-9)
+	s.InDelta(0.5, snapshots[0].ErrorRate, 1e-9)
+	s.Equal(6, snapshots[0].Latency.Count)
+	s.Equal(10*time.Millisecond, snapshots[0].Latency.P99)
+
+	s.Equal(5*time.Minute, snapshots[1].Window)
+	s.Equal(int64(42), snapshots[1].Requests)
+	s.Equal(int64(36), snapshots[1].Errors)
+	s.InDelta(42.0/300, snapshots[1].RequestRate, 1e-9)
+	s.Equal(36, snapshots[1].Latency.Count)
+	s.Equal(time.Second, snapshots[1].Latency.P50)
+
+	s.Equal(15*time.Minute, snapshots[2].Window)
+	s.Equal(int64(102), snapshots[2].Requests)
+	s.Equal(int64(36), snapshots[2].Errors)
+	s.Equal(96, snapshots[2].Latency.Count)
+	s.Equal(100*time.Millisecond, snapshots[2].Latency.P50)
+	s.Equal(time.Second, snapshots[2].Latency.P99)
+}
+
+func (s *redSuite) Test_Snapshot_Rolls() {
+	s.add(10, 500, time.Second)
+	s.now = s.now.Add(59 * time.Second)
+	s.Equal(int64(10), s.red.Snapshot()[0].Requests)
+
+	// the requests leave the windows once they are older than them, however many buckets were skipped
+	s.now = s.now.Add(11 * time.Second)
+	snapshots := s.red.Snapshot()
+	s.Zero(snapshots[0].Requests)
+	s.Zero(snapshots[0].ErrorRate)
+	s.Zero(snapshots[0].Latency.P99)
+	s.Equal(int64(10), snapshots[1].Requests)
+
+	s.now = s.now.Add(time.Hour)
+	for _, snapshot := range s.red.Snapshot() {
+		s.Zero(snapshot.Requests)
+	}
+	// the buckets are reused
+	s.add(1, 200, time.Millisecond)
+	s.Equal(int64(1), s.red.Snapshot()[2].Requests)
+}
+
+func (s *redSuite) Test_NewREDMetrics_Windows() {
+	red := NewREDMetrics(func() time.Time { return s.now }, time.Hour, 0, 10*time.Second)
+	red.Add(LogEntry{IP: "10.0.0.1", Status: 200})
+	snapshots := red.Snapshot()
+	s.Require().Len(snapshots, 2)
+	s.Equal(10*time.Second, snapshots[0].Window)
+	s.Equal(time.Hour, snapshots[1].Window)
+	s.InDelta(0.1, snapshots[0].RequestRate, 1e-9)
+}
+
+func (s *redSuite) Test_Add_Concurrent() {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				s.red.Add(LogEntry{IP: "10.0.0.1", Status: 200, Duration: time.Duration(j) * time.Millisecond})
+			}
+		}()
+	}
+	wg.Wait()
+	snapshot := s.red.Snapshot()[0]
+	s.Equal(int64(8000), snapshot.Requests)
+	s.InDelta(float64(500*time.Millisecond), float64(snapshot.Latency.P50), float64(20*time.Millisecond))
+}
+
+func TestRED(t *testing.T) {
+	suite.Run(t, new(redSuite))
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// campaignParams are the query parameters tagging the links of marketing campaigns (Urchin Tracking Module).
+var campaignParams = []string{"utm_source", "utm_medium", "utm_campaign"}
+
+// Referers counts where the requests logged within the last N minutes came from: the external domains
+// referring to the site (excluding its own hostnames) and the campaigns tagged in the requested URLs.
+// The referers are only known for the FormatCombined format.
+type Referers struct {
+	own       []string
+	external  int64
+	domains   map[string]int64
+	campaigns map[string]int64
+}
+
+// ReferersReport is the report of where the requests came from.
+type ReferersReport struct {
+	// External is the number of requests referred by an external domain.
+	External int64 `json:"external"`
+	// Domains are the external domains referring the most requests.
+	Domains []Count `json:"domains"`
+	// Campaigns are the campaigns tagged in the most requested URLs,
+	// e.g. "source=newsletter medium=email campaign=spring", leaving out the missing parameters.
+	Campaigns []Count `json:"campaigns"`
+}
+
+// NewReferers creates empty Referers, ignoring the referers of the given own hostnames and their subdomains,
+// e.g. example.com ignores the referers of example.com and www.example.com.
+func NewReferers(ownHosts ...string) *Referers {
+	own := make([]string, 0, len(ownHosts))
+	for _, host := range ownHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			own = append(own, host)
+		}
+	}
+	return &Referers{
+		own:       own,
+		domains:   make(map[string]int64),
+		campaigns: make(map[string]int64),
+	}
+}
+
+// Referers reads the log entries that happened within the last N minutes and counts where they came from,
+// see NewReferers. The counts are returned even along with an error (e.g. ErrNoFilesInWindow),
+// accounting for the entries read until then.
+func (logs *Logs) Referers(ctx context.Context, ownHosts ...string) (*Referers, error) {
+	referers := NewReferers(ownHosts...)
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		referers.Add(entry)
+		return nil
+	})
+
+	return referers, err
+}
+
+// Add counts the referer and the campaign of a given log entry, ignoring the ones that are not requests.
+func (referers *Referers) Add(entry LogEntry) {
+	if entry.IP == "" {
+		return
+	}
+
+	if host := refererHost(entry.Referer); host != "" && !referers.isOwn(host) {
+		referers.external++
+		referers.domains[host]++
+	}
+	if campaign := campaign(entry.Path); campaign != "" {
+		referers.campaigns[campaign]++
+	}
+}
+
+// Result returns the report of all the external domains and campaigns, see Report.
+func (referers *Referers) Result() interface{} {
+	return referers.Report(0)
+}
+
+// isOwn checks whether a given host is one of the own hostnames or one of their subdomains.
+func (referers *Referers) isOwn(host string) bool {
+	for _, own := range referers.own {
+		if host == own || strings.HasSuffix(host, "."+own) {
+			return true
+		}
+	}
+	return false
+}
+
+// Report returns the n external domains referring the most requests and the n most frequent campaigns,
+// or all of them when n is not positive.
+func (referers *Referers) Report(n int) ReferersReport {
+	return ReferersReport{
+		External:  referers.external,
+		Domains:   rank(referers.domains, n),
+		Campaigns: rank(referers.campaigns, n),
+	}
+}
+
+// refererHost returns the lower case hostname of a referer URL, without its port,
+// or an empty string when the referer is unknown (e.g. "-") or not a URL.
+func refererHost(referer string) string {
+	if referer == "" || referer == "-" {
+		return ""
+	}
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// campaign returns the campaign parameters of a requested path, e.g. "source=newsletter medium=email",
+// or an empty string when the path isn't tagged.
+func campaign(path string) string {
+	i := strings.Index(path, "?")
+	if i < 0 {
+		return ""
+	}
+	query, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return ""
+	}
+
+	var params []string
+	for _, param := range campaignParams {
+		if value := query.Get(param); value != "" {
+			params = append(params, strings.TrimPrefix(param, "utm_")+"="+value)
+		}
+	}
+	return strings.Join(params, " ")
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const referersDataDir = "test/referers"
+
+type referersSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *referersSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(referersDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /?utm_source=newsletter&utm_medium=email&utm_campaign=spring HTTP/1.1" 200 10 "https://mail.google.com/" "Mozilla/5.0"
+10.0.0.2 - - [03/Mar/2022:02:44:15 +0000] "GET /?utm_source=newsletter&utm_medium=email&utm_campaign=spring HTTP/1.1" 200 10 "-" "Mozilla/5.0"
+10.0.0.3 - - [03/Mar/2022:02:44:20 +0000] "GET /blog HTTP/1.1" 200 10 "https://www.Google.com/search?q=logs" "Mozilla/5.0"
+10.0.0.4 - - [03/Mar/2022:02:44:25 +0000] "GET /blog HTTP/1.1" 200 10 "https://www.google.com:443/" "Mozilla/5.0"
+10.0.0.3 - - [03/Mar/2022:02:44:30 +0000] "GET /about HTTP/1.1" 200 10 "https://www.example.com/blog" "Mozilla/5.0"
+10.0.0.3 - - [03/Mar/2022:02:44:35 +0000] "GET /about HTTP/1.1" 200 10 "https://example.com/" "Mozilla/5.0"
+10.0.0.5 - - [03/Mar/2022:02:44:40 +0000] "GET /?utm_source=twitter HTTP/1.1" 200 10 "https://t.co/abc" "Mozilla/5.0"
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(referersDataDir), WithFormat(FormatCombined))
+}
+
+func (s *referersSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(referersDataDir)))
+}
+
+func (s *referersSuite) Test_Referers() {
+	referers, err := s.logs.Referers(context.Background(), "Example.com", " ")
+	s.Require().NoError(err)
+
+	s.Equal(ReferersReport{
+		External: 4,
+		Domains: []Count{
+			{Value: "www.google.com", Count: 2},
+			{Value: "mail.google.com", Count: 1},
+			{Value: "t.co", Count: 1},
+		},
+		Campaigns: []Count{
+			{Value: "source=newsletter medium=email campaign=spring", Count: 2},
+			{Value: "source=twitter", Count: 1},
+		},
+	}, referers.Report(0))
+	s.Len(referers.Report(1).Domains, 1)
+}
+
+func (s *referersSuite) Test_Referers_NoOwnHosts() {
+	referers, err := s.logs.Referers(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(int64(6), referers.Report(0).External)
+}
+
+func TestReferers(t *testing.T) {
+	suite.Run(t, new(referersSuite))
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ParseTraceparent parses a W3C trace context traceparent header (https://www.w3.org/TR/trace-context/#traceparent-header),
+// e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01, into its trace id and its parent (span) id,
+// returning false when it isn't one, the ids being all zeros included.
+func ParseTraceparent(header string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || !isLowerHex(parts[0]) || len(parts[3]) != 2 || !isLowerHex(parts[3]) {
+		return "", "", false
+	}
+	// the future versions may append fields, but version 00 has exactly 4 of them
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false
+	}
+	traceID, spanID = parts[1], parts[2]
+	if len(traceID) != 32 || !isLowerHex(traceID) || isZeros(traceID) || len(spanID) != 16 || !isLowerHex(spanID) || isZeros(spanID) {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+// TraceContext returns the W3C trace context ids a given request id links to: the ones of a traceparent header as is,
+// a UUID or 32 hexadecimal digits (e.g. the X-Request-ID of many proxies) as the trace id, and a trace id derived
+// from its hash otherwise (e.g. the UNIQUE_ID of mod_unique_id), the span id being derived from the hash of the request id
+// unless given by the traceparent. The same request id always gives the same ids, so the access logs of several servers
+// are linked to the same trace. It returns false for an empty or missing ("-") request id.
+func TraceContext(requestID string) (traceID, spanID string, ok bool) {
+	requestID = strings.TrimSpace(requestID)
+	if requestID == "" || requestID == "-" {
+		return "", "", false
+	}
+	if traceID, spanID, ok := ParseTraceparent(requestID); ok {
+		return traceID, spanID, true
+	}
+	sum := sha256.Sum256([]byte(requestID))
+	spanID = hex.EncodeToString(sum[:8])
+	if id := strings.ToLower(strings.ReplaceAll(requestID, "-", "")); len(id) == 32 && isLowerHex(id) && !isZeros(id) {
+		return id, spanID, true
+	}
+	return hex.EncodeToString(sum[8:24]), spanID, true
+}
+
+// Traceparent returns the W3C traceparent header of given trace context ids, sampled, e.g. for the APM to look them up.
+func Traceparent(traceID, spanID string) string {
+	return "00-" + traceID + "-" + spanID + "-01"
+}
+
+// LinkTrace is a Middleware linking a log entry to the trace of its request by its request id (see TraceContext),
+// setting its TraceID and SpanID, so the log entries written as JSON (e.g. OutputJSON or the sinks) can be joined
+// with the traces of an APM. The log entries without a request id are left as they are.
+func LinkTrace(entry LogEntry) (LogEntry, bool) {
+	if traceID, spanID, ok := TraceContext(entry.RequestID); ok {
+		entry.TraceID, entry.SpanID = traceID, spanID
+	}
+	return entry, true
+}
+
+// RequestIDFilter returns a Filter keeping the log entries of the given request ids only, either the request ids
+// as logged or the trace ids they link to (see TraceContext), ignoring their case, e.g. to find the requests of a trace.
+func RequestIDFilter(ids ...string) Filter {
+	wanted := make(map[string]bool, 2*len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		wanted[strings.ToLower(id)] = true
+		if traceID, _, ok := TraceContext(id); ok {
+			wanted[traceID] = true
+		}
+	}
+	return func(entry LogEntry) bool {
+		if entry.RequestID == "" {
+			return false
+		}
+		if wanted[strings.ToLower(entry.RequestID)] {
+			return true
+		}
+		traceID, _, ok := TraceContext(entry.RequestID)
+		return ok && wanted[traceID]
+	}
+}
+
+// isLowerHex checks whether a given string is made of lowercase hexadecimal digits only.
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isZeros checks whether a given string is made of zeros only.
+func isZeros(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
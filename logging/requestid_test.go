@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type requestIDSuite struct {
+	suite.Suite
+}
+
+func (s *requestIDSuite) Test_ParseTraceparent() {
+	traceID, spanID, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s.True(ok)
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	s.Equal("00f067aa0ba902b7", spanID)
+	// the later versions may have more fields
+	_, _, ok = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	s.True(ok)
+
+	for _, header := range []string{
+		"",
+		"-",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		_, _, ok := ParseTraceparent(header)
+		s.False(ok, header)
+	}
+}
+
+func (s *requestIDSuite) Test_TraceContext() {
+	traceID, spanID, ok := TraceContext("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s.True(ok)
+	s.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", Traceparent(traceID, spanID))
+
+	// a UUID is the trace id
+	traceID, spanID, ok = TraceContext("F3B1C2D4-9A8E-4C7B-B6A5-0D1E2F3A4B5C")
+	s.True(ok)
+	s.Equal("f3b1c2d49a8e4c7bb6a50d1e2f3a4b5c", traceID)
+	s.Len(spanID, 16)
+
+	// other ids are hashed, always the same way
+	traceID, spanID, ok = TraceContext("YiFxV38AAQEAAB2pNS0AAAAB")
+	s.True(ok)
+	s.Len(traceID, 32)
+	s.Len(spanID, 16)
+	_, _, parsed := ParseTraceparent(Traceparent(traceID, spanID))
+	s.True(parsed)
+	again, _, _ := TraceContext("YiFxV38AAQEAAB2pNS0AAAAB")
+	s.Equal(traceID, again)
+
+	for _, id := range []string{"", "-", " "} {
+		_, _, ok := TraceContext(id)
+		s.False(ok, id)
+	}
+}
+
+func (s *requestIDSuite) Test_LinkTrace() {
+	entry, ok := LinkTrace(LogEntry{RequestID: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"})
+	s.True(ok)
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", entry.TraceID)
+	s.Equal("00f067aa0ba902b7", entry.SpanID)
+
+	entry, ok = LinkTrace(LogEntry{RequestID: "-"})
+	s.True(ok)
+	s.Empty(entry.TraceID)
+	s.Empty(entry.SpanID)
+}
+
+func (s *requestIDSuite) Test_RequestIDFilter() {
+	filter := RequestIDFilter("F3B1C2D4-9A8E-4C7B-B6A5-0D1E2F3A4B5C", "4bf92f3577b34da6a3ce929d0e0e4736", " ")
+	tests := []struct {
+		requestID string
+		expected  bool
+	}{
+		{"f3b1c2d4-9a8e-4c7b-b6a5-0d1e2f3a4b5c", true},
+		// the trace id of the UUID
+		{"00-f3b1c2d49a8e4c7bb6a50d1e2f3a4b5c-00f067aa0ba902b7-01", true},
+		// the request of a trace
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-5bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"YiFxV38AAQEAAB2pNS0AAAAB", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		s.Equal(test.expected, filter(LogEntry{RequestID: test.requestID}), test.requestID)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	suite.Run(t, new(requestIDSuite))
+}
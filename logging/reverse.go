@@ -0,0 +1,242 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// reverseBlockSize is the size of the blocks read backwards by ForEachReverse.
+const reverseBlockSize = 64 << 10
+
+// ForEachReverse calls the given function for each log entry that happened within the last N minutes the way ForEach does,
+// but newest first: the files are read from the most recently modified one, each of them backwards by blocks
+// from its last complete line (see File.end) down to the first line within the time range, which is found
+// the same way (binary search or index). Lines that cannot be parsed inherit the time of the previous line the way
+// they do when read forward, so they stay next to it and are left out along with it, the entries being exactly
+// the ones of ForEach in reverse. The files are never interleaved, so merged files (see LogsConfig.Merge) are not supported,
+// and they are read one by one, without reporting the progress (see LogsConfig.Workers and LogsConfig.Progress).
+func (logs *Logs) ForEachReverse(ctx context.Context, fn func(LogEntry) error) error {
+	if logs.cfg.Merge {
+		return errors.New("merged files cannot be read in reverse")
+	}
+	files, err := logs.files(ctx)
+	if err != nil {
+		return err
+	}
+	idx := logs.index(files)
+	logs.selected(files, idx)
+	if idx < 0 {
+		return ErrNoFilesInWindow
+	}
+
+	for i := len(files) - 1; i >= idx; i-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := logs.reverseFile(ctx, files[i].path, i == idx, fn)
+		if errors.Is(err, ErrStop) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reverseFile calls a given function for each accepted log entry of a given file within the last N minutes, newest first.
+// The file is searched for the first of them even if its times cannot be read when told so (see Logs.offset).
+func (logs *Logs) reverseFile(ctx context.Context, path string, search bool, fn func(LogEntry) error) (err error) {
+	start := time.Now()
+	file, err := logs.open(path)
+	if errors.Is(err, errVanished) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	offset, err := logs.offset(ctx, file, search)
+	if err != nil || offset < 0 {
+		return err
+	}
+	from, err := logs.rewind(file, offset)
+	if err != nil {
+		return err
+	}
+	end, err := file.end()
+	if err != nil {
+		return err
+	}
+	var stats fileStats
+	ctx, span := logs.startSpan(ctx, SpanRead)
+	defer func() {
+		spanErr := err
+		if errors.Is(err, ErrStop) {
+			spanErr = nil
+		}
+		span.End(spanErr, stats.args(file.Name(), time.Since(start))...)
+	}()
+	until := logs.cfg.until()
+	if !until.IsZero() {
+		// the log lines at or after the end of the time range are not even read, when they can be found
+		if endOffset, _, err := logs.findOffset(ctx, file, until, true); err == nil && endOffset >= 0 {
+			end = endOffset
+		}
+	}
+
+	lookupTime := logs.nowMinusT()
+	boundary := offset + boundaryScanSize
+	// emit calls the function for a given log entry of a given line and offset, unless after the time range or not accepted
+	emit := func(entry LogEntry, line string, lineOffset int64) error {
+		if !until.IsZero() && !entry.Time.Before(until) {
+			return nil
+		}
+		entry.Line = strings.TrimRight(line, "\r\n")
+		entry.File = file.Name()
+		entry.Offset = lineOffset
+		accepted := logs.accept(&entry)
+		stats.accepted(entry.Time, accepted)
+		if !accepted {
+			return nil
+		}
+		return fn(entry)
+	}
+	// the invalid lines stay with the previous log the way they do when read forward (see cursor.next), so they're held
+	// until it is read: undecided until the previous log of the time range tells whether they're left out along with it
+	// (see boundaryScanSize), then held until the previous log kept gives them its time
+	var undecided, held []invalidLine
+	decide := func(dropped bool) {
+		for _, invalid := range undecided {
+			if dropped && invalid.offset < boundary {
+				logs.cfg.Metrics.skipped()
+				continue
+			}
+			held = append(held, invalid)
+		}
+		undecided = undecided[:0]
+	}
+	release := func(t time.Time) error {
+		for _, invalid := range held {
+			stats.lines++
+			stats.invalid++
+			logs.cfg.Errors.Add(invalid.err)
+			if logs.cfg.Logger != nil {
+				logs.cfg.Logger.Debug("invalid line kept with the time of the previous one", "file", file.Name(), "offset", invalid.offset, "error", invalid.err)
+			}
+			invalid.entry.Time = t
+			if err := emit(invalid.entry, invalid.line, invalid.offset); err != nil {
+				return err
+			}
+		}
+		held = held[:0]
+		return nil
+	}
+
+	r := &reverseReader{r: file, start: from, pos: end}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, lineOffset, err := r.prev()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		stats.bytes += int64(len(line))
+		entry, parseErr := stats.parse(file, line, logs.cfg.Logger != nil)
+		if lineOffset < offset {
+			// within the bytes rewound (see LogsConfig.Tolerance), only the logs of the time range are kept
+			if parseErr != nil || entry.Time.Before(lookupTime) {
+				logs.cfg.Metrics.skipped()
+				continue
+			}
+			decide(false)
+		} else if parseErr != nil {
+			undecided = append(undecided, invalidLine{entry: entry, line: line, offset: lineOffset, err: parseErr})
+			continue
+		} else if lineOffset < boundary && !entry.Time.IsZero() && entry.Time.Before(lookupTime) {
+			// logs out of order right after the first log within the time range (see boundaryScanSize)
+			decide(true)
+			logs.cfg.Metrics.skipped()
+			continue
+		} else {
+			decide(false)
+		}
+		if err := release(entry.Time); err != nil {
+			return err
+		}
+		stats.lines++
+		if err := emit(entry, line, lineOffset); err != nil {
+			return err
+		}
+	}
+	// the invalid lines before any log have no time, the way they have none when read forward
+	decide(false)
+	if err := release(time.Time{}); err != nil {
+		return err
+	}
+	logs.info("file read in reverse", stats.args(file.Name(), time.Since(start))...)
+	return nil
+}
+
+// invalidLine is a line of a file read in reverse that doesn't match the log format, held until the log before it is read.
+type invalidLine struct {
+	entry  LogEntry
+	line   string
+	offset int64
+	err    error
+}
+
+// reverseReader reads the lines of the byte range [start, pos) of a file backwards, by blocks (see reverseBlockSize).
+type reverseReader struct {
+	r          io.ReaderAt
+	start, pos int64
+	// pending are the bytes read from pos onwards whose lines are not returned yet
+	pending []byte
+}
+
+// prev returns the line before the ones returned so far, along with its offset, or io.EOF once the start is reached.
+// The line keeps its line ending, if any.
+func (rr *reverseReader) prev() (string, int64, error) {
+	for {
+		if len(rr.pending) == 0 && rr.pos <= rr.start {
+			return "", 0, io.EOF
+		}
+		// the line ending of the last pending line is not the beginning of a line
+		search := rr.pending
+		if n := len(search); n > 0 && search[n-1] == '\n' {
+			search = search[:n-1]
+		}
+		if i := bytes.LastIndexByte(search, '\n'); i >= 0 {
+			line := string(rr.pending[i+1:])
+			rr.pending = rr.pending[:i+1]
+			return line, rr.pos + int64(i+1), nil
+		}
+		if rr.pos <= rr.start {
+			line := string(rr.pending)
+			rr.pending = rr.pending[:0]
+			return line, rr.pos, nil
+		}
+
+		size := int64(reverseBlockSize)
+		if rr.pos-rr.start < size {
+			size = rr.pos - rr.start
+		}
+		block := make([]byte, size+int64(len(rr.pending)))
+		if _, err := rr.r.ReadAt(block[:size], rr.pos-size); err != nil {
+			return "", 0, err
+		}
+		copy(block[size:], rr.pending)
+		rr.pending = block
+		rr.pos -= size
+	}
+}
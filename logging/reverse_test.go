@@ -0,0 +1,168 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const reverseDataDir = "test/reverse"
+
+type reverseSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *reverseSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	files := []string{
+		`10.0.0.1 - - [03/Mar/2022:02:20:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:30:00 +0000] "GET /b HTTP/1.1" 200 10
+`,
+		`10.0.0.3 - - [03/Mar/2022:02:35:00 +0000] "GET /c HTTP/1.1" 200 10
+this line cannot be parsed
+10.0.0.4 - - [03/Mar/2022:02:40:00 +0000] "GET /d HTTP/1.1" 200 10
+10.0.0.5 - - [03/Mar/2022:02:44:00 +0000] "GET /e HTTP/1.1" 200 10
+10.0.0.6 - - [03/Mar/2022:02:4`,
+	}
+	for i, logs := range files {
+		name := filepath.Join(reverseDataDir, []string{"access.log.1", "access.log"}[i])
+		modTime := []time.Time{t.Add(-15 * time.Minute), t.Add(-time.Minute)}[i]
+		writeLogFile(s.T(), name, logs, modTime)
+	}
+}
+
+func (s *reverseSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(reverseDataDir)))
+}
+
+// ips returns the IPs of the log entries iterated in reverse by given logs, the invalid ones as "-".
+func (s *reverseSuite) ips(logs *Logs) []string {
+	var ips []string
+	s.Require().NoError(logs.ForEachReverse(context.Background(), func(entry LogEntry) error {
+		if entry.Invalid {
+			ips = append(ips, "-")
+			return nil
+		}
+		ips = append(ips, entry.IP)
+		return nil
+	}))
+	return ips
+}
+
+// forwardIPs returns the IPs of the log entries iterated by given logs the way ips does, newest first.
+func (s *reverseSuite) forwardIPs(logs *Logs) []string {
+	var ips []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		ip := entry.IP
+		if entry.Invalid {
+			ip = "-"
+		}
+		ips = append([]string{ip}, ips...)
+		return nil
+	}))
+	return ips
+}
+
+func (s *reverseSuite) Test_ForEachReverse() {
+	logs, err := New(WithDirectory(reverseDataDir), WithWindow(20*time.Minute), WithEnd(s.testTime))
+	s.Require().NoError(err)
+
+	// the last line is still being written, so it's left out
+	s.Equal([]string{"10.0.0.5", "10.0.0.4", "-", "10.0.0.3", "10.0.0.2"}, s.ips(logs))
+	s.Equal(s.forwardIPs(logs), s.ips(logs))
+}
+
+func (s *reverseSuite) Test_ForEachReverse_Entries() {
+	logs, err := New(WithDirectory(reverseDataDir), WithWindow(20*time.Minute), WithEnd(s.testTime))
+	s.Require().NoError(err)
+
+	var entries []LogEntry
+	s.Require().NoError(logs.ForEachReverse(context.Background(), func(entry LogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+
+	s.Require().Len(entries, 5)
+	s.Equal(`10.0.0.5 - - [03/Mar/2022:02:44:00 +0000] "GET /e HTTP/1.1" 200 10`, entries[0].Line)
+	s.Equal(filepath.Join(reverseDataDir, "access.log"), entries[0].File)
+	s.Equal(int64(2*67+27), entries[0].Offset)
+	// the invalid line keeps the time of the previous one, the way it does when read forward
+	s.Equal("this line cannot be parsed", entries[2].Line)
+	s.Equal(entries[3].Time, entries[2].Time)
+	s.Equal(filepath.Join(reverseDataDir, "access.log.1"), entries[4].File)
+	s.Equal(int64(67), entries[4].Offset)
+}
+
+func (s *reverseSuite) Test_ForEachReverse_End() {
+	logs, err := New(WithDirectory(reverseDataDir), WithWindow(15*time.Minute), WithEnd(s.testTime.Add(-5*time.Minute)))
+	s.Require().NoError(err)
+
+	// the invalid line happened before the end of the time range as far as it's known, like the previous one
+	s.Equal([]string{"-", "10.0.0.3", "10.0.0.2"}, s.ips(logs))
+	s.Equal(s.forwardIPs(logs), s.ips(logs))
+}
+
+func (s *reverseSuite) Test_ForEachReverse_Filtered() {
+	logs, err := New(WithDirectory(reverseDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithPathPrefix("/a"))
+	s.Require().NoError(err)
+
+	s.Equal([]string{"10.0.0.1"}, s.ips(logs))
+}
+
+func (s *reverseSuite) Test_ForEachReverse_Stop() {
+	logs, err := New(WithDirectory(reverseDataDir), WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+
+	var ips []string
+	err = logs.ForEachReverse(context.Background(), func(entry LogEntry) error {
+		ips = append(ips, entry.IP)
+		return ErrStop
+	})
+
+	s.NoError(err)
+	s.Equal([]string{"10.0.0.5"}, ips)
+}
+
+func (s *reverseSuite) Test_ForEachReverse_Merge() {
+	logs, err := New(WithDirectory(reverseDataDir), WithWindow(time.Hour), WithMerge())
+	s.Require().NoError(err)
+
+	err = logs.ForEachReverse(context.Background(), func(LogEntry) error { return nil })
+
+	s.EqualError(err, "merged files cannot be read in reverse")
+}
+
+func (s *reverseSuite) Test_reverseReader() {
+	// lines longer than a block are read over several blocks
+	long := strings.Repeat("x", reverseBlockSize+10)
+	content := "first\n" + long + "\n\nlast"
+	r := &reverseReader{r: strings.NewReader(content), start: 0, pos: int64(len(content))}
+
+	var lines []string
+	var offsets []int64
+	for {
+		line, offset, err := r.prev()
+		if err == io.EOF {
+			break
+		}
+		s.Require().NoError(err)
+		lines = append(lines, line)
+		offsets = append(offsets, offset)
+	}
+
+	s.Equal([]string{"last", "\n", long + "\n", "first\n"}, lines)
+	s.Equal([]int64{int64(len(content) - 4), int64(len(content) - 5), 6, 0}, offsets)
+}
+
+func TestReverse(t *testing.T) {
+	suite.Run(t, new(reverseSuite))
+}
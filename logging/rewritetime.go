@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"strings"
+	"time"
+)
+
+// The names of the layouts of the times of the log lines rewritten by a TimeRewriter, see ParseTimeLayout.
+const (
+	// TimeLayoutCLF is the layout of the Apache Common Log, e.g. 03/Mar/2022:10:00:00 +0100.
+	TimeLayoutCLF = "clf"
+	// TimeLayoutRFC3339 is the layout of RFC 3339, e.g. 2022-03-03T10:00:00+01:00.
+	TimeLayoutRFC3339 = "rfc3339"
+	// TimeLayoutRFC3339Nano is the layout of RFC 3339 with the fractions of the seconds, if any.
+	TimeLayoutRFC3339Nano = "rfc3339nano"
+)
+
+// ParseTimeLayout returns the time layout of a given name (see TimeLayoutCLF, ...), or the name itself
+// when it isn't one, taken as a layout of the time package, e.g. "2006-01-02 15:04:05 MST".
+func ParseTimeLayout(name string) string {
+	switch strings.ToLower(name) {
+	case TimeLayoutCLF:
+		return dateTimeFormat
+	case TimeLayoutRFC3339:
+		return time.RFC3339
+	case TimeLayoutRFC3339Nano:
+		return time.RFC3339Nano
+	}
+	return name
+}
+
+// TimeRewriter rewrites the times of the log lines in a time zone and a layout of its own (see Transform),
+// e.g. so the extracts read by a team outside UTC show their local times. Only the Line of the log entries
+// is rewritten, their Time is left as is, so the log entries are searched, filtered and merged the same.
+type TimeRewriter struct {
+	format Format
+	loc    *time.Location
+	layout string
+}
+
+// NewTimeRewriter creates a TimeRewriter of the log lines of a given format, rewriting their times in a given
+// time zone (left as logged when nil) and a given layout (the one of the format when empty, see ParseTimeLayout).
+func NewTimeRewriter(format Format, loc *time.Location, layout string) *TimeRewriter {
+	if layout == "" {
+		layout = format.timeLayout()
+	}
+	return &TimeRewriter{format: format, loc: loc, layout: layout}
+}
+
+// Transform is a Middleware rewriting the time of the line of a log entry, see LogsConfig.Middlewares.
+// The invalid log lines are left as is, their times being the ones of the lines before them.
+func (r *TimeRewriter) Transform(entry LogEntry) (LogEntry, bool) {
+	if entry.Invalid || entry.Time.IsZero() {
+		return entry, true
+	}
+	start, end := r.timeSpan(entry.Line)
+	if start < 0 {
+		return entry, true
+	}
+	t := entry.Time
+	if r.loc != nil {
+		t = t.In(r.loc)
+	}
+	entry.Line = entry.Line[:start] + t.Format(r.layout) + entry.Line[end:]
+	return entry, true
+}
+
+// timeSpan returns the byte range of the time within a log line: its first field for the CRI format,
+// the first bracketed part of the line otherwise, or -1 when there's none.
+func (r *TimeRewriter) timeSpan(line string) (int, int) {
+	if r.format == FormatCRI {
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			return 0, i
+		}
+		return -1, -1
+	}
+	start := strings.IndexByte(line, '[')
+	if start < 0 {
+		return -1, -1
+	}
+	end := strings.IndexByte(line[start:], ']')
+	if end < 0 {
+		return -1, -1
+	}
+	return start + 1, start + end
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const rewriteTimeDataDir = "test/rewritetime"
+
+type rewriteTimeSuite struct {
+	suite.Suite
+	testTime time.Time
+	berlin   *time.Location
+}
+
+func (s *rewriteTimeSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:01:00 +0000")
+	s.testTime = t
+	s.berlin = time.FixedZone("CET", 3600)
+	writeLogFile(s.T(), filepath.Join(rewriteTimeDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:09:59:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /b HTTP/1.1" 500 10
+garbage
+10.0.0.2 - - [03/Mar/2022:10:00:30 +0000] "GET /c HTTP/1.1" 200 10
+`, t)
+}
+
+func (s *rewriteTimeSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(rewriteTimeDataDir)))
+}
+
+func (s *rewriteTimeSuite) Test_TimeRewriter_Print() {
+	rewriter := NewTimeRewriter(FormatCommon, s.berlin, ParseTimeLayout(TimeLayoutRFC3339))
+	// the window and the filters see the original times
+	logs, err := New(WithDirectory(rewriteTimeDataDir), WithWindow(90*time.Second), WithEnd(s.testTime),
+		WithMiddleware(rewriter.Transform), WithFilter(func(entry LogEntry) bool { return entry.Time.UTC().Hour() == 10 }))
+	s.Require().NoError(err)
+	var b bytes.Buffer
+	s.Require().NoError(logs.Print(context.Background(), &b))
+	s.Equal(`10.0.0.1 - - [2022-03-03T11:00:00+01:00] "GET /b HTTP/1.1" 500 10
+garbage
+10.0.0.2 - - [2022-03-03T11:00:30+01:00] "GET /c HTTP/1.1" 200 10
+`, b.String())
+}
+
+func (s *rewriteTimeSuite) Test_TimeRewriter_Formats() {
+	tests := []struct {
+		name     string
+		format   Format
+		loc      *time.Location
+		layout   string
+		line     string
+		expected string
+	}{
+		{
+			name:     "Zone Only",
+			format:   FormatCommon,
+			loc:      s.berlin,
+			line:     `10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET / HTTP/1.1" 200 10`,
+			expected: `10.0.0.1 - - [03/Mar/2022:11:00:00 +0100] "GET / HTTP/1.1" 200 10`,
+		},
+		{
+			name:     "Layout Only",
+			format:   FormatCommon,
+			layout:   "2006-01-02 15:04:05 -0700",
+			line:     `10.0.0.1 - - [03/Mar/2022:10:00:00 +0200] "GET / HTTP/1.1" 200 10`,
+			expected: `10.0.0.1 - - [2022-03-03 10:00:00 +0200] "GET / HTTP/1.1" 200 10`,
+		},
+		{
+			name:     "CRI",
+			format:   FormatCRI,
+			loc:      s.berlin,
+			line:     `2022-03-03T10:00:00.5Z stdout F hello [world]`,
+			expected: `2022-03-03T11:00:00.5+01:00 stdout F hello [world]`,
+		},
+		{
+			name:     "SSL Request",
+			format:   FormatSSLRequest,
+			loc:      s.berlin,
+			layout:   ParseTimeLayout("RFC3339"),
+			line:     `[03/Mar/2022:10:00:00 +0000] 10.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET / HTTP/1.1" 10`,
+			expected: `[2022-03-03T11:00:00+01:00] 10.0.0.1 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 "GET / HTTP/1.1" 10`,
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			entry, err := test.format.ParseLine(test.line)
+			s.Require().NoError(err)
+			rewritten, ok := NewTimeRewriter(test.format, test.loc, test.layout).Transform(entry)
+			s.True(ok)
+			s.Equal(test.expected, rewritten.Line)
+			s.Equal(entry.Time, rewritten.Time)
+		})
+	}
+
+	invalid := LogEntry{Line: "garbage [x]", Time: s.testTime, Invalid: true}
+	rewritten, ok := NewTimeRewriter(FormatCommon, s.berlin, "").Transform(invalid)
+	s.True(ok)
+	s.Equal(invalid, rewritten)
+	s.Equal("2006", ParseTimeLayout("2006"))
+}
+
+func TestRewriteTimeSuite(t *testing.T) {
+	suite.Run(t, new(rewriteTimeSuite))
+}
@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sampler keeps a share of the log entries by the status of their requests, e.g. all the 5xx and 1% of the 2xx,
+// so the volume is reduced while every error is retained. Rules for single statuses (e.g. 404=0.5) take precedence
+// over the rules for status classes (e.g. 4xx=0.1), the other entries being kept at the rate of no status (*=0.01),
+// all of them by default. Whether an entry is kept is decided by the hash of its line rather than at random, so the same
+// lines are kept by every run (e.g. by extract and ship alike) and the files can be parsed in parallel.
+type Sampler struct {
+	statuses map[int]float64
+	classes  map[int]float64
+	fallback float64
+}
+
+// NewSampler creates a Sampler keeping all the log entries.
+func NewSampler() *Sampler {
+	return &Sampler{statuses: make(map[int]float64), classes: make(map[int]float64), fallback: 1}
+}
+
+// ParseSampler parses the rules of a Sampler, separated by commas, each one being a status, a status class or *
+// followed by the share of the entries kept, as a fraction or a percentage, e.g. "5xx=1,404=50%,2xx=0.01,*=0.1".
+func ParseSampler(rules string) (*Sampler, error) {
+	s := NewSampler()
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		i := strings.IndexByte(rule, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid sampling rule '%s': must be a status, a status class or * and a rate, e.g. 2xx=0.01", rule)
+		}
+		statuses, value := strings.TrimSpace(rule[:i]), strings.TrimSpace(rule[i+1:])
+		rate, err := parseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sampling rule '%s': %v", rule, err)
+		}
+		switch {
+		case statuses == "*":
+			s.fallback = rate
+		case len(statuses) == 3 && strings.HasSuffix(strings.ToLower(statuses), "xx") && statuses[0] >= '1' && statuses[0] <= '5':
+			s.classes[int(statuses[0]-'0')] = rate
+		default:
+			status, err := strconv.Atoi(statuses)
+			if err != nil || status < 100 || status > 599 {
+				return nil, fmt.Errorf("invalid sampling rule '%s': unknown status '%s'", rule, statuses)
+			}
+			s.statuses[status] = rate
+		}
+	}
+	return s, nil
+}
+
+// parseRate parses the share of the entries kept by a sampling rule, a fraction (e.g. 0.01) or a percentage (e.g. 1%).
+func parseRate(value string) (float64, error) {
+	percent := strings.HasSuffix(value, "%")
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate '%s'", value)
+	}
+	if percent {
+		rate /= 100
+	}
+	if math.IsNaN(rate) || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid rate '%s': must be between 0 and 1, or 0%% and 100%%", value)
+	}
+	return rate, nil
+}
+
+// Rate returns the share of the log entries like a given one kept by the rules of the sampler.
+func (s *Sampler) Rate(entry LogEntry) float64 {
+	if rate, ok := s.statuses[entry.Status]; ok {
+		return rate
+	}
+	if rate, ok := s.classes[entry.Status/100]; ok {
+		return rate
+	}
+	return s.fallback
+}
+
+// Keep is a Filter keeping a log entry at the rate of its status, see LogsConfig.Filters.
+func (s *Sampler) Keep(entry LogEntry) bool {
+	rate := s.Rate(entry)
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(entry.Line))
+	return float64(h.Sum64()) < rate*math.MaxUint64
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type samplingSuite struct {
+	suite.Suite
+}
+
+func (s *samplingSuite) Test_ParseSampler() {
+	sampler, err := ParseSampler(" 5xx=1, 404=50%,2XX=0.01,*=0.1")
+	s.Require().NoError(err)
+	tests := []struct {
+		status   int
+		expected float64
+	}{
+		{200, 0.01},
+		{301, 0.1},
+		{403, 0.1},
+		{404, 0.5},
+		{503, 1},
+		{0, 0.1},
+	}
+	for _, test := range tests {
+		s.Equal(test.expected, sampler.Rate(LogEntry{Status: test.status}), "status %d", test.status)
+	}
+	s.Equal(1.0, NewSampler().Rate(LogEntry{Status: 200}))
+
+	for _, rules := range []string{"2xx", "2xx=2", "2xx=-1", "2xx=150%", "2xx=NaN", "2xx=all", "6xx=1", "99=1"} {
+		_, err := ParseSampler(rules)
+		s.Error(err, rules)
+	}
+}
+
+func (s *samplingSuite) Test_Sampler_Keep() {
+	sampler, err := ParseSampler("5xx=1,2xx=0.1,4xx=0")
+	s.Require().NoError(err)
+	kept := map[int]int{}
+	for i := 0; i < 10000; i++ {
+		for _, status := range []int{200, 404, 500} {
+			entry := LogEntry{Status: status, Line: fmt.Sprintf(`10.0.%d.%d - - [03/Mar/2022:02:44:%02d +0000] "GET /a HTTP/1.1" %d 10`, i/256, i%256, i%60, status)}
+			if sampler.Keep(entry) {
+				kept[status]++
+			}
+			// the same lines are kept every time
+			s.Equal(sampler.Keep(entry), sampler.Keep(entry))
+		}
+	}
+	s.Equal(10000, kept[500])
+	s.Zero(kept[404])
+	s.InDelta(1000, kept[200], 100)
+}
+
+func TestSampling(t *testing.T) {
+	suite.Run(t, new(samplingSuite))
+}
@@ -0,0 +1,231 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// TimeParser parses the time of a log line (without its line ending), returning an error when it has none,
+// e.g. Format.ParseTime, or the parser of any other time-ordered lines (JSON logs, CSV exports, ...).
+type TimeParser func(line string) (time.Time, error)
+
+// ParseTime parses the time of a log line of the format, returning an *InvalidLogLineError when it doesn't match the format.
+func (format Format) ParseTime(line string) (time.Time, error) {
+	return NewFormatFile(nil, format).parseLogTime(line)
+}
+
+// IndexTime binary searches the time-ordered lines of a given reader (e.g. an *os.File or a *bytes.Reader)
+// the way File.IndexTime does, the time of every line being parsed by a given parser, so any file of time-ordered lines
+// can be searched, not only log files. It returns the offset of the first line that happened at or after the lookup time,
+// or -1 when all the lines happened before it. A last line without a trailing newline is left out when it cannot be parsed,
+// as it's most likely still being written. The reader is read at explicit offsets when it implements io.ReaderAt,
+// otherwise it is seeked around, its position being undefined once done.
+func IndexTime(ctx context.Context, r io.ReadSeeker, parse TimeParser, lookupTime time.Time) (int64, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1, err
+	}
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		ra = &seekReaderAt{r: r}
+	}
+	s := lineSearch{
+		r:         ra,
+		lineStart: func(offset int64) (int64, error) { return lineStartAt(ra, offset) },
+		parse:     parse,
+	}
+	end, err := s.end(size)
+	if err != nil {
+		return -1, err
+	}
+	return s.search(ctx, end, lookupTime)
+}
+
+// lineSearch searches the time-ordered lines read from r, shared by File.IndexTime and IndexTime.
+type lineSearch struct {
+	r io.ReaderAt
+	// lineStart returns the offset of the beginning of the line a given offset belongs to (see lineStartAt)
+	lineStart func(offset int64) (int64, error)
+	parse     TimeParser
+	// locate sets the position of the line found at a given offset on the error of its parsing, if any (see File.locate)
+	locate func(err error, offset int64) error
+	// metrics counts the lines probed, if any
+	metrics *Metrics
+}
+
+// search returns the offset of the first line before a given end that happened at or after the lookup time,
+// or -1 if there's none, binary searching the lines (see File.IndexTime).
+func (s lineSearch) search(ctx context.Context, end int64, lookupTime time.Time) (int64, error) {
+	// the lines before top happened before the lookup time, the ones from bottom onwards at or after it,
+	// top and bottom being the offsets of the beginning of a line (or the end)
+	top, bottom := int64(0), end
+	// emptyBottom tells whether bottom is the beginning of empty lines, rather than of a log line
+	emptyBottom := false
+	for probes := 0; top < bottom; probes++ {
+		if err := ctx.Err(); err != nil {
+			return -1, err
+		}
+		if probes == indexTimeMaxProbes {
+			// the lines from top onwards are scanned instead,
+			// top being a safe offset to start from whatever the lines went through
+			return s.scan(ctx, top, end, lookupTime)
+		}
+
+		// reposition the middle to the beginning of the current line, which is never before top
+		offset, err := s.lineStart(top + (bottom-top)/2)
+		if err != nil {
+			return -1, err
+		}
+
+		s.metrics.probed()
+		// never read past the end, so a partially written last line is considered an EOF
+		line, length, err := readLine(io.NewSectionReader(s.r, offset, end-offset))
+		if err != nil {
+			return -1, err
+		}
+		trimmed := strings.TrimSpace(line)
+		// the empty lines are no logs: the first log line after them is probed instead, if any before bottom
+		empty := offset
+		for trimmed == "" && offset+length < bottom {
+			offset += length
+			if line, length, err = readLine(io.NewSectionReader(s.r, offset, end-offset)); err != nil {
+				return -1, err
+			}
+			trimmed = strings.TrimSpace(line)
+		}
+		if trimmed == "" {
+			// there are only empty lines left before bottom
+			bottom, emptyBottom = empty, true
+			continue
+		}
+
+		logTime, err := s.parse(trimmed)
+		if err != nil {
+			if s.locate != nil {
+				err = s.locate(err, offset)
+			}
+			return -1, err
+		}
+		if logTime.Before(lookupTime) {
+			top = offset + length
+		} else {
+			bottom, emptyBottom = offset, false
+		}
+	}
+
+	// the search may end on empty lines, the first log line is the one after them
+	for emptyBottom && top < end {
+		line, length, err := readLine(io.NewSectionReader(s.r, top, end-top))
+		if err != nil {
+			return -1, err
+		}
+		if strings.TrimSpace(line) != "" || length == 0 {
+			break
+		}
+		top += length
+	}
+	if top >= end {
+		return -1, nil
+	}
+	return top, nil
+}
+
+// scan returns the offset of the first line between two given offsets that happened at or after the lookup time,
+// or -1 if there's none, reading the lines one by one. The lines that cannot be parsed are skipped.
+func (s lineSearch) scan(ctx context.Context, offset, end int64, lookupTime time.Time) (int64, error) {
+	reader := getReader(contextReader{ctx: ctx, r: io.NewSectionReader(s.r, offset, end-offset)})
+	defer putReader(reader)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return -1, err
+		}
+		if line == "" {
+			return -1, nil
+		}
+
+		logTime, err := s.parse(strings.TrimSpace(line))
+		if err == nil && !logTime.Before(lookupTime) {
+			return offset, nil
+		}
+		offset += int64(len(line))
+	}
+}
+
+// end returns the offset right after the last complete line among the first given number of bytes.
+// A last line without a trailing newline is considered complete only when it can be parsed,
+// otherwise it's most likely still being written and is left out.
+func (s lineSearch) end(size int64) (int64, error) {
+	if size == 0 {
+		return 0, nil
+	}
+
+	buf := make([]byte, 1)
+	if _, err := s.r.ReadAt(buf, size-1); err != nil {
+		return -1, err
+	}
+	if buf[0] == '\n' {
+		return size, nil
+	}
+
+	offset, err := s.lineStart(size - 1)
+	if err != nil {
+		return -1, err
+	}
+	line, _, err := readLine(io.NewSectionReader(s.r, offset, size-offset))
+	if err != nil {
+		return -1, err
+	}
+	if _, err := s.parse(strings.TrimSpace(line)); err != nil {
+		return offset, nil
+	}
+
+	return size, nil
+}
+
+// lineStartAt returns the offset of the beginning of the line a given offset of a reader belongs to,
+// reading it backwards by blocks (see lineStartBlockSize), so long lines take a few reads only.
+func lineStartAt(r io.ReaderAt, offset int64) (int64, error) {
+	buf := blockPool.Get().(*[]byte)
+	defer blockPool.Put(buf)
+	for offset > 0 {
+		start := offset - lineStartBlockSize
+		if start < 0 {
+			start = 0
+		}
+		block := (*buf)[:offset-start]
+		if _, err := r.ReadAt(block, start); err != nil {
+			return -1, err
+		}
+
+		if i := bytes.LastIndexByte(block, '\n'); i >= 0 {
+			// a newline at the very beginning of the file still starts the closest line at 0
+			if start+int64(i) == 0 {
+				break
+			}
+			return start + int64(i) + 1, nil
+		}
+		offset = start
+	}
+	return 0, nil
+}
+
+// seekReaderAt reads an io.ReadSeeker at explicit offsets by seeking it first, so it is not safe for concurrent use.
+type seekReaderAt struct {
+	r io.ReadSeeker
+}
+
+func (sr *seekReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	if _, err := sr.r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(sr.r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
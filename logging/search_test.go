@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type searchSuite struct {
+	suite.Suite
+	testTime time.Time
+	// lines are JSON lines a minute apart, starting at testTime
+	lines []string
+}
+
+func (s *searchSuite) SetupSuite() {
+	s.testTime = time.Date(2022, time.March, 3, 2, 45, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		s.lines = append(s.lines, fmt.Sprintf(`{"time":"%s","msg":"line %d"}`, s.testTime.Add(time.Duration(i)*time.Minute).Format(time.RFC3339), i))
+	}
+}
+
+// parseJSONTime parses the time of the JSON lines of the tests.
+func parseJSONTime(line string) (time.Time, error) {
+	const prefix = `{"time":"`
+	if !strings.HasPrefix(line, prefix) || len(line) < len(prefix)+20 {
+		return time.Time{}, errors.New("no time")
+	}
+	return time.Parse(time.RFC3339, line[len(prefix):len(prefix)+20])
+}
+
+// seeker hides the io.ReaderAt of a reader, so only its io.ReadSeeker is used.
+type seeker struct {
+	io.ReadSeeker
+}
+
+// offsetOf returns the offset of the line of a given index in the content of given lines.
+func offsetOf(lines []string, i int) int64 {
+	return int64(len(strings.Join(lines[:i], "\n")) + 1)
+}
+
+func (s *searchSuite) Test_IndexTime() {
+	content := strings.Join(s.lines, "\n") + "\n"
+	for _, test := range []struct {
+		name     string
+		lookup   time.Time
+		expected int64
+	}{
+		{name: "First", lookup: s.testTime.Add(-time.Hour), expected: 0},
+		{name: "Middle", lookup: s.testTime.Add(4 * time.Minute), expected: offsetOf(s.lines, 4)},
+		{name: "Between", lookup: s.testTime.Add(4*time.Minute + time.Second), expected: offsetOf(s.lines, 5)},
+		{name: "Last", lookup: s.testTime.Add(9 * time.Minute), expected: offsetOf(s.lines, 9)},
+		{name: "None", lookup: s.testTime.Add(time.Hour), expected: -1},
+	} {
+		s.Run(test.name, func() {
+			for name, r := range map[string]io.ReadSeeker{
+				"ReaderAt":   strings.NewReader(content),
+				"ReadSeeker": seeker{strings.NewReader(content)},
+			} {
+				offset, err := IndexTime(context.Background(), r, parseJSONTime, test.lookup)
+
+				s.Require().NoError(err, name)
+				s.Equal(test.expected, offset, name)
+			}
+		})
+	}
+}
+
+func (s *searchSuite) Test_IndexTime_PartialLastLine() {
+	// the last line is still being written, so it's left out
+	content := strings.Join(s.lines, "\n") + "\n" + `{"time":"2022-03-03T`
+
+	offset, err := IndexTime(context.Background(), seeker{strings.NewReader(content)}, parseJSONTime, s.testTime.Add(time.Hour))
+
+	s.Require().NoError(err)
+	s.Equal(int64(-1), offset)
+}
+
+func (s *searchSuite) Test_IndexTime_Format() {
+	content := `10.0.0.1 - - [03/Mar/2022:02:40:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:02:45:00 +0000] "GET /b HTTP/1.1" 200 10
+`
+
+	offset, err := IndexTime(context.Background(), strings.NewReader(content), FormatCommon.ParseTime, s.testTime)
+
+	s.Require().NoError(err)
+	s.Equal(int64(67), offset)
+}
+
+func (s *searchSuite) Test_IndexTime_Invalid() {
+	content := "not a log line\n"
+
+	_, err := IndexTime(context.Background(), strings.NewReader(content), FormatCommon.ParseTime, s.testTime)
+
+	s.ErrorIs(err, ErrInvalidLogLine)
+}
+
+func (s *searchSuite) Test_IndexTime_Cancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := IndexTime(ctx, strings.NewReader(strings.Join(s.lines, "\n")), parseJSONTime, s.testTime)
+
+	s.ErrorIs(err, context.Canceled)
+}
+
+func TestSearch(t *testing.T) {
+	suite.Run(t, new(searchSuite))
+}
@@ -0,0 +1,159 @@
+package logging
+
+import (
+	"context"
+	"time"
+)
+
+// Sessions reconstructs the visits (sessions) of the clients from the requests logged within the last N minutes.
+// The requests of a client, identified by its IP and user agent, belong to the same session as long as
+// they're no more than the idle timeout apart. Sessions idle for longer are closed as the logs go by,
+// so only the sessions of the recently active clients are kept in memory.
+type Sessions struct {
+	idleTimeout time.Duration
+	open        map[sessionKey]*session
+	// latest is the time of the latest request, lastSweep the latest time the idle sessions were closed
+	latest, lastSweep time.Time
+	// closed accounts for the sessions closed so far
+	closed sessionTotals
+}
+
+// SessionsReport summarizes the reconstructed sessions.
+type SessionsReport struct {
+	Sessions        int           `json:"sessions"`
+	AverageDuration time.Duration `json:"average_duration"`
+	PagesPerSession float64       `json:"pages_per_session"`
+	// EntryPages and ExitPages rank the first and last requested paths of the sessions.
+	EntryPages []Count `json:"entry_pages"`
+	ExitPages  []Count `json:"exit_pages"`
+}
+
+type sessionKey struct {
+	ip        string
+	userAgent string
+}
+
+type session struct {
+	start, last time.Time
+	pages       int
+	entry, exit string
+}
+
+type sessionTotals struct {
+	sessions    int
+	duration    time.Duration
+	pages       int
+	entry, exit map[string]int64
+}
+
+// NewSessions creates empty Sessions closing the sessions idle for longer than the given timeout.
+func NewSessions(idleTimeout time.Duration) *Sessions {
+	return &Sessions{
+		idleTimeout: idleTimeout,
+		open:        make(map[sessionKey]*session),
+		closed:      newSessionTotals(),
+	}
+}
+
+func newSessionTotals() sessionTotals {
+	return sessionTotals{entry: make(map[string]int64), exit: make(map[string]int64)}
+}
+
+// Sessions reads the log entries that happened within the last N minutes and reconstructs the sessions of the clients.
+// The sessions are returned even along with an error (e.g. ErrNoFilesInWindow), accounting for the entries read until then.
+func (logs *Logs) Sessions(ctx context.Context, idleTimeout time.Duration) (*Sessions, error) {
+	sessions := NewSessions(idleTimeout)
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		sessions.Add(entry)
+		return nil
+	})
+
+	return sessions, err
+}
+
+// Add adds a given log entry to the session of its client, ignoring the ones that are not requests.
+func (sessions *Sessions) Add(entry LogEntry) {
+	if entry.IP == "" {
+		return
+	}
+
+	key := sessionKey{ip: entry.IP, userAgent: entry.UserAgent}
+	s, ok := sessions.open[key]
+	if ok && entry.Time.Sub(s.last) > sessions.idleTimeout {
+		sessions.closed.add(s)
+		ok = false
+	}
+	if !ok {
+		s = &session{start: entry.Time, entry: entry.Path}
+		sessions.open[key] = s
+	}
+	if entry.Time.After(s.last) {
+		s.last = entry.Time
+	}
+	s.exit = entry.Path
+	s.pages++
+
+	if entry.Time.After(sessions.latest) {
+		sessions.latest = entry.Time
+	}
+	if sessions.latest.Sub(sessions.lastSweep) > sessions.idleTimeout {
+		sessions.sweep()
+	}
+}
+
+// Result returns the sessions report along with all the entry and exit pages, see Report.
+func (sessions *Sessions) Result() interface{} {
+	return sessions.Report(0)
+}
+
+// sweep closes the sessions idle for longer than the idle timeout.
+func (sessions *Sessions) sweep() {
+	for key, s := range sessions.open {
+		if sessions.latest.Sub(s.last) > sessions.idleTimeout {
+			sessions.closed.add(s)
+			delete(sessions.open, key)
+		}
+	}
+	sessions.lastSweep = sessions.latest
+}
+
+// Report summarizes the sessions, still open ones included, ranking the n most frequent
+// entry and exit pages, or all of them when n is not positive.
+func (sessions *Sessions) Report(n int) SessionsReport {
+	totals := newSessionTotals()
+	totals.merge(sessions.closed)
+	for _, s := range sessions.open {
+		totals.add(s)
+	}
+
+	report := SessionsReport{
+		Sessions:   totals.sessions,
+		EntryPages: rank(totals.entry, n),
+		ExitPages:  rank(totals.exit, n),
+	}
+	if totals.sessions > 0 {
+		report.AverageDuration = totals.duration / time.Duration(totals.sessions)
+		report.PagesPerSession = float64(totals.pages) / float64(totals.sessions)
+	}
+	return report
+}
+
+func (totals *sessionTotals) add(s *session) {
+	totals.sessions++
+	totals.duration += s.last.Sub(s.start)
+	totals.pages += s.pages
+	totals.entry[s.entry]++
+	totals.exit[s.exit]++
+}
+
+func (totals *sessionTotals) merge(other sessionTotals) {
+	totals.sessions += other.sessions
+	totals.duration += other.duration
+	totals.pages += other.pages
+	for page, count := range other.entry {
+		totals.entry[page] += count
+	}
+	for page, count := range other.exit {
+		totals.exit[page] += count
+	}
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const sessionsDataDir = "test/sessions"
+
+type sessionsSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *sessionsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(sessionsDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:30:00 +0000] "GET / HTTP/1.1" 200 20 "-" "Mozilla/5.0"
+10.0.0.1 - - [03/Mar/2022:02:30:00 +0000] "GET / HTTP/1.1" 200 20 "-" "curl/7.79.1"
+10.0.0.1 - - [03/Mar/2022:02:32:00 +0000] "GET /products HTTP/1.1" 200 20 "/" "Mozilla/5.0"
+10.0.0.2 - - [03/Mar/2022:02:33:00 +0000] "GET /products HTTP/1.1" 200 20 "-" "Mozilla/5.0"
+10.0.0.1 - - [03/Mar/2022:02:35:00 +0000] "GET /cart HTTP/1.1" 200 20 "/products" "Mozilla/5.0"
+this line cannot be parsed
+10.0.0.1 - - [03/Mar/2022:02:44:00 +0000] "GET /products HTTP/1.1" 200 20 "-" "Mozilla/5.0"
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Hour, WithDirectory(sessionsDataDir), WithFormat(FormatCombined))
+}
+
+func (s *sessionsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(sessionsDataDir)))
+}
+
+func (s *sessionsSuite) Test_Sessions() {
+	sessions, err := s.logs.Sessions(context.Background(), 5*time.Minute)
+	s.Require().NoError(err)
+
+	report := sessions.Report(0)
+
+	// 10.0.0.1 with Mozilla (/ -> /products -> /cart, then /products after being idle),
+	// 10.0.0.1 with curl (/) and 10.0.0.2 (/products)
+	s.Equal(4, report.Sessions)
+	s.Equal(5*time.Minute/4, report.AverageDuration)
+	s.Equal(6.0/4, report.PagesPerSession)
+	s.Equal([]Count{{Value: "/", Count: 2}, {Value: "/products", Count: 2}}, report.EntryPages)
+	s.Equal([]Count{{Value: "/products", Count: 2}, {Value: "/", Count: 1}, {Value: "/cart", Count: 1}}, report.ExitPages)
+}
+
+func (s *sessionsSuite) Test_Sessions_Sweep() {
+	start := time.Date(2022, time.March, 3, 2, 0, 0, 0, time.UTC)
+	sessions := NewSessions(time.Minute)
+
+	for i := 0; i < 100; i++ {
+		sessions.Add(LogEntry{IP: "10.0.0.1", Path: "/", Time: start.Add(time.Duration(i) * time.Hour)})
+	}
+
+	// idle sessions are closed as the logs go by, instead of being kept in memory
+	s.LessOrEqual(len(sessions.open), 2)
+	s.Equal(100, sessions.Report(1).Sessions)
+	s.Equal([]Count{{Value: "/", Count: 100}}, sessions.Report(1).EntryPages)
+}
+
+func (s *sessionsSuite) Test_Sessions_Empty() {
+	report := NewSessions(time.Minute).Report(10)
+
+	s.Equal(0, report.Sessions)
+	s.Zero(report.AverageDuration)
+	s.Zero(report.PagesPerSession)
+	s.Empty(report.EntryPages)
+}
+
+func TestSessions(t *testing.T) {
+	suite.Run(t, new(sessionsSuite))
+}
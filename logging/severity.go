@@ -0,0 +1,163 @@
+package logging
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity is how severe a log entry is, e.g. so the sinks pass it on to the systems they ship to as a level
+// (e.g. the priority of a syslog message or the level label of Loki), see SeverityMap.
+type Severity string
+
+// The severities of the log entries, the ones of syslog (RFC 5424) under shorter names.
+const (
+	SeverityCritical Severity = "crit"
+	SeverityError    Severity = "error"
+	SeverityWarning  Severity = "warn"
+	SeverityNotice   Severity = "notice"
+	SeverityInfo     Severity = "info"
+	SeverityDebug    Severity = "debug"
+)
+
+// ParseSeverity parses the name of a Severity, including the levels of the error log, e.g. alert or trace1.
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(name) {
+	case "emerg", "alert", "crit", "critical":
+		return SeverityCritical, nil
+	case "error", "err":
+		return SeverityError, nil
+	case "warn", "warning":
+		return SeverityWarning, nil
+	case "notice":
+		return SeverityNotice, nil
+	case "info":
+		return SeverityInfo, nil
+	case "debug", "trace1", "trace2", "trace3", "trace4", "trace5", "trace6", "trace7", "trace8":
+		return SeverityDebug, nil
+	}
+	return "", fmt.Errorf("unknown severity '%s'", name)
+}
+
+// SyslogSeverity returns the numerical code of the severity of syslog (RFC 5424 section 6.2.1),
+// e.g. 3 for SeverityError, informational (6) when unknown.
+func (severity Severity) SyslogSeverity() int {
+	switch severity {
+	case SeverityCritical:
+		return 2
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 4
+	case SeverityNotice:
+		return 5
+	case SeverityDebug:
+		return 7
+	}
+	return 6
+}
+
+// SeverityMap maps the log entries to their severities (see LogEntry.Severity) by the status of their requests,
+// rules for single statuses (e.g. 404=info) taking precedence over the rules for status classes (e.g. 4xx=warn),
+// the entries of the error log by their levels, and the others to the severity of no status (*=info).
+// The default rules are 5xx=error, 4xx=warn and *=info.
+type SeverityMap struct {
+	statuses map[int]Severity
+	classes  map[int]Severity
+	fallback Severity
+}
+
+// NewSeverityMap creates a SeverityMap of the default rules: 5xx=error, 4xx=warn and *=info.
+func NewSeverityMap() *SeverityMap {
+	return &SeverityMap{
+		statuses: make(map[int]Severity),
+		classes:  map[int]Severity{5: SeverityError, 4: SeverityWarning},
+		fallback: SeverityInfo,
+	}
+}
+
+// ParseSeverityMap parses the rules of a SeverityMap completing the default ones, separated by commas,
+// each one being a status, a status class or * followed by its severity, e.g. "404=info,3xx=notice,*=debug".
+func ParseSeverityMap(rules string) (*SeverityMap, error) {
+	m := NewSeverityMap()
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		i := strings.IndexByte(rule, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid severity rule '%s': must be a status, a status class or * and a severity, e.g. 4xx=warn", rule)
+		}
+		statuses, name := strings.TrimSpace(rule[:i]), strings.TrimSpace(rule[i+1:])
+		severity, err := ParseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid severity rule '%s': %v", rule, err)
+		}
+		switch {
+		case statuses == "*":
+			m.fallback = severity
+		case len(statuses) == 3 && strings.HasSuffix(strings.ToLower(statuses), "xx") && statuses[0] >= '1' && statuses[0] <= '5':
+			m.classes[int(statuses[0]-'0')] = severity
+		default:
+			status, err := strconv.Atoi(statuses)
+			if err != nil || status < 100 || status > 599 {
+				return nil, fmt.Errorf("invalid severity rule '%s': unknown status '%s'", rule, statuses)
+			}
+			m.statuses[status] = severity
+		}
+	}
+	return m, nil
+}
+
+// Severity returns the severity of a given log entry by the rules of the map.
+func (m *SeverityMap) Severity(entry LogEntry) Severity {
+	if entry.Status == 0 && entry.Level != "" {
+		if severity, err := ParseSeverity(entry.Level); err == nil {
+			return severity
+		}
+	}
+	if severity, ok := m.statuses[entry.Status]; ok {
+		return severity
+	}
+	if severity, ok := m.classes[entry.Status/100]; ok {
+		return severity
+	}
+	return m.fallback
+}
+
+// Transform is a Middleware setting the severity of a log entry, see LogsConfig.Middlewares.
+func (m *SeverityMap) Transform(entry LogEntry) (LogEntry, bool) {
+	entry.Severity = m.Severity(entry)
+	return entry, true
+}
+
+// defaultSeverities are the default rules of SeverityOf.
+var defaultSeverities = NewSeverityMap()
+
+// SeverityOf returns the severity of a given log entry: its Severity when set (see SeverityMap.Transform),
+// the one of the default rules otherwise, e.g. for the sinks to pass on a level whether mapped or not.
+func SeverityOf(entry LogEntry) Severity {
+	if entry.Severity != "" {
+		return entry.Severity
+	}
+	return defaultSeverities.Severity(entry)
+}
+
+// severityColors are the ANSI escape codes of the colors of the severities, see ColorBySeverity.
+var severityColors = map[Severity]string{
+	SeverityCritical: "\x1b[1;31m",
+	SeverityError:    "\x1b[31m",
+	SeverityWarning:  "\x1b[33m",
+	SeverityNotice:   "\x1b[36m",
+	SeverityDebug:    "\x1b[2m",
+}
+
+// ColorBySeverity is a Middleware coloring the line of a log entry by its severity (see SeverityOf) with ANSI
+// escape codes, e.g. errors in red and warnings in yellow, leaving the informational ones as is.
+func ColorBySeverity(entry LogEntry) (LogEntry, bool) {
+	if color, ok := severityColors[SeverityOf(entry)]; ok {
+		entry.Line = color + entry.Line + "\x1b[0m"
+	}
+	return entry, true
+}
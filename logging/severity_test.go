@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type severitySuite struct {
+	suite.Suite
+}
+
+func (s *severitySuite) Test_SeverityMap_Defaults() {
+	m := NewSeverityMap()
+	tests := []struct {
+		entry    LogEntry
+		expected Severity
+	}{
+		{LogEntry{Status: 200}, SeverityInfo},
+		{LogEntry{Status: 304}, SeverityInfo},
+		{LogEntry{Status: 404}, SeverityWarning},
+		{LogEntry{Status: 503}, SeverityError},
+		{LogEntry{Level: "crit"}, SeverityCritical},
+		{LogEntry{Level: "trace3"}, SeverityDebug},
+		{LogEntry{Level: "unknown"}, SeverityInfo},
+		{LogEntry{}, SeverityInfo},
+	}
+	for _, test := range tests {
+		s.Equal(test.expected, m.Severity(test.entry), "%+v", test.entry)
+	}
+}
+
+func (s *severitySuite) Test_ParseSeverityMap() {
+	m, err := ParseSeverityMap(" 404=info, 3XX=notice,*=debug,503=crit")
+	s.Require().NoError(err)
+	tests := []struct {
+		status   int
+		expected Severity
+	}{
+		{200, SeverityDebug},
+		{301, SeverityNotice},
+		{403, SeverityWarning},
+		{404, SeverityInfo},
+		{500, SeverityError},
+		{503, SeverityCritical},
+	}
+	for _, test := range tests {
+		s.Equal(test.expected, m.Severity(LogEntry{Status: test.status}), "status %d", test.status)
+	}
+
+	for _, rules := range []string{"404", "404=fatal", "6xx=error", "abc=info", "99=info"} {
+		_, err := ParseSeverityMap(rules)
+		s.Error(err, rules)
+	}
+}
+
+func (s *severitySuite) Test_SeverityMap_Transform() {
+	m, err := ParseSeverityMap("404=info")
+	s.Require().NoError(err)
+	entry, ok := m.Transform(LogEntry{Status: 404})
+	s.True(ok)
+	s.Equal(SeverityInfo, entry.Severity)
+	// the severity mapped takes precedence over the default one
+	s.Equal(SeverityInfo, SeverityOf(entry))
+	s.Equal(SeverityWarning, SeverityOf(LogEntry{Status: 404}))
+}
+
+func (s *severitySuite) Test_SyslogSeverity() {
+	s.Equal(2, SeverityCritical.SyslogSeverity())
+	s.Equal(3, SeverityError.SyslogSeverity())
+	s.Equal(4, SeverityWarning.SyslogSeverity())
+	s.Equal(5, SeverityNotice.SyslogSeverity())
+	s.Equal(6, SeverityInfo.SyslogSeverity())
+	s.Equal(7, SeverityDebug.SyslogSeverity())
+	s.Equal(6, Severity("").SyslogSeverity())
+}
+
+func (s *severitySuite) Test_ColorBySeverity() {
+	entry, ok := ColorBySeverity(LogEntry{Line: "a", Status: 500})
+	s.True(ok)
+	s.Equal("\x1b[31ma\x1b[0m", entry.Line)
+	entry, _ = ColorBySeverity(LogEntry{Line: "a", Status: 404})
+	s.Equal("\x1b[33ma\x1b[0m", entry.Line)
+	entry, _ = ColorBySeverity(LogEntry{Line: "a", Status: 200})
+	s.Equal("a", entry.Line)
+}
+
+func TestSeveritySuite(t *testing.T) {
+	suite.Run(t, new(severitySuite))
+}
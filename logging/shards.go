@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// shardSlack is how far outside the time range the dated subdirectories are still descended into (see LogsConfig.Sharded),
+// their dates being the ones of the time zone of the server, which is unknown.
+const shardSlack = 24 * time.Hour
+
+// shardParts are the lengths and the ranges of the names of the dated subdirectories, by depth:
+// year, month, day, and hour.
+var shardParts = []struct {
+	length   int
+	min, max int
+}{
+	{length: 4, min: 0, max: 9999},
+	{length: 2, min: 1, max: 12},
+	{length: 2, min: 1, max: 31},
+	{length: 2, min: 0, max: 23},
+}
+
+// shardDirs returns a given directory of a given file system along with its dated subdirectories (e.g. 2024/05/12, see LogsConfig.Sharded)
+// covering a given time range, the others being left out without being descended into.
+// The subdirectories which names are not dates are left out.
+func shardDirs(fsys fileSystem, dir string, start, end time.Time) ([]string, error) {
+	dirs := []string{dir}
+	var descend func(dir string, date []int) error
+	descend = func(dir string, date []int) error {
+		if len(date) == len(shardParts) {
+			return nil
+		}
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, fi := range entries {
+			if !fi.IsDir() {
+				continue
+			}
+			part, ok := parseShardPart(fi.Name(), len(date))
+			if !ok {
+				continue
+			}
+			shard := append(date[:len(date):len(date)], part)
+			from, to := shardPeriod(shard)
+			if !to.After(start.Add(-shardSlack)) || from.After(end.Add(shardSlack)) {
+				continue
+			}
+			sub := filepath.Join(dir, fi.Name())
+			dirs = append(dirs, sub)
+			if err := descend(sub, shard); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := descend(dir, nil); err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
+// parseShardPart parses the name of a dated subdirectory at a given depth, e.g. 05 for a month, see shardParts.
+func parseShardPart(name string, depth int) (int, bool) {
+	p := shardParts[depth]
+	if len(name) != p.length {
+		return 0, false
+	}
+	for _, c := range name {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(name)
+	if err != nil || n < p.min || n > p.max {
+		return 0, false
+	}
+	return n, true
+}
+
+// shardPeriod returns the period covered by a dated subdirectory given its date (year, month, day, hour),
+// e.g. the whole of May 2024 for 2024/05.
+func shardPeriod(date []int) (from, to time.Time) {
+	year, month, day, hour := date[0], 1, 1, 0
+	if len(date) > 1 {
+		month = date[1]
+	}
+	if len(date) > 2 {
+		day = date[2]
+	}
+	if len(date) > 3 {
+		hour = date[3]
+	}
+	from = time.Date(year, time.Month(month), day, hour, 0, 0, 0, time.UTC)
+	switch len(date) {
+	case 1:
+		to = from.AddDate(1, 0, 0)
+	case 2:
+		to = from.AddDate(0, 1, 0)
+	case 3:
+		to = from.AddDate(0, 0, 1)
+	default:
+		to = from.Add(time.Hour)
+	}
+	return from, to
+}
@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const shardsDataDir = "test/shards"
+
+type shardsSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *shardsSuite) SetupSuite() {
+	files := map[string]string{
+		"2022/02/27/access.log": `10.0.0.1 - - [27/Feb/2022:10:00:00 +0000] "GET /old HTTP/1.1" 200 10
+`,
+		"2022/03/02/access.log": `10.0.0.1 - - [02/Mar/2022:23:00:00 +0000] "GET /yesterday HTTP/1.1" 200 10
+`,
+		"2022/03/03/access.log": `10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:05:00 +0000] "GET /b HTTP/1.1" 200 10
+`,
+		"archive/access.log": `10.0.0.1 - - [03/Mar/2022:10:06:00 +0000] "GET /archived HTTP/1.1" 200 10
+`,
+	}
+	modified := map[string]string{
+		"2022/02/27/access.log": "27/Feb/2022:10:00:00 +0000",
+		"2022/03/02/access.log": "02/Mar/2022:23:00:00 +0000",
+		"2022/03/03/access.log": "03/Mar/2022:10:05:00 +0000",
+		"archive/access.log":    "03/Mar/2022:10:06:00 +0000",
+	}
+	for name, content := range files {
+		writeLogFile(s.T(), filepath.Join(shardsDataDir, filepath.FromSlash(name)), content, parseLogTime(s.T(), modified[name]))
+	}
+	s.testTime = parseLogTime(s.T(), "03/Mar/2022:10:10:00 +0000")
+}
+
+func (s *shardsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(shardsDataDir)))
+}
+
+func (s *shardsSuite) paths(window time.Duration) []string {
+	logs, err := New(WithDirectory(shardsDataDir), WithSharded(), WithWindow(window), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	var paths []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+	return paths
+}
+
+func (s *shardsSuite) Test_ShardDirs() {
+	dirs, err := shardDirs(osFileSystem{}, shardsDataDir, s.testTime.Add(-time.Hour), s.testTime)
+	s.Require().NoError(err)
+	// the month of February is left out without being descended into
+	s.Equal([]string{
+		shardsDataDir,
+		filepath.Join(shardsDataDir, "2022"),
+		filepath.Join(shardsDataDir, "2022", "03"),
+		filepath.Join(shardsDataDir, "2022", "03", "02"),
+		filepath.Join(shardsDataDir, "2022", "03", "03"),
+	}, dirs)
+}
+
+func (s *shardsSuite) Test_Sharded() {
+	s.Equal([]string{"/a", "/b"}, s.paths(time.Hour))
+	s.Equal([]string{"/yesterday", "/a", "/b"}, s.paths(12*time.Hour))
+}
+
+func (s *shardsSuite) Test_NotSharded() {
+	logs, err := New(WithDirectory(shardsDataDir), WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	// the subdirectories are left out
+	s.ErrorIs(logs.ForEach(context.Background(), func(LogEntry) error { return nil }), ErrNoFilesInWindow)
+}
+
+func (s *shardsSuite) Test_ParseShardPart() {
+	tests := []struct {
+		name  string
+		depth int
+		part  int
+		ok    bool
+	}{
+		{name: "2024", depth: 0, part: 2024, ok: true},
+		{name: "24", depth: 0},
+		{name: "05", depth: 1, part: 5, ok: true},
+		{name: "13", depth: 1},
+		{name: "5", depth: 1},
+		{name: "31", depth: 2, part: 31, ok: true},
+		{name: "00", depth: 2},
+		{name: "23", depth: 3, part: 23, ok: true},
+		{name: "+1", depth: 3},
+	}
+	for _, test := range tests {
+		part, ok := parseShardPart(test.name, test.depth)
+		s.Equal(test.ok, ok, test.name)
+		s.Equal(test.part, part, test.name)
+	}
+}
+
+func (s *shardsSuite) Test_ShardPeriod() {
+	from, to := shardPeriod([]int{2024, 12})
+	s.Equal(time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC), from)
+	s.Equal(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), to)
+	from, to = shardPeriod([]int{2024, 5, 12, 7})
+	s.Equal(time.Date(2024, time.May, 12, 7, 0, 0, 0, time.UTC), from)
+	s.Equal(time.Hour, to.Sub(from))
+}
+
+func TestShardsSuite(t *testing.T) {
+	suite.Run(t, new(shardsSuite))
+}
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Sink is where the log entries of a window are routed to (see Logs.Route), one at a time.
+// Sinks may buffer the log entries they are given, writing them for good only once flushed.
+type Sink interface {
+	// Write writes a given log entry, or buffers it until the sink is flushed.
+	Write(ctx context.Context, entry LogEntry) error
+	// Flush writes the log entries buffered by the sink, if any.
+	Flush(ctx context.Context) error
+}
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = make(map[string]func(target string) (Sink, error))
+)
+
+func init() {
+	RegisterSink("stdout", func(string) (Sink, error) {
+		return NewWriterSink(os.Stdout), nil
+	})
+	RegisterSink("file", func(target string) (Sink, error) {
+		return NewFileSink(target)
+	})
+}
+
+// RegisterSink makes a sink available by a given name, e.g. to be picked from the command line,
+// creating a new Sink for a given target (e.g. a file name or a URL) using the given function (see NewSink).
+// It's meant to be called from the init function of the package implementing the sink,
+// and panics when called twice with the same name or with a nil function.
+func RegisterSink(name string, newSink func(target string) (Sink, error)) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	if newSink == nil {
+		panic("logging: RegisterSink function is nil")
+	}
+	if _, dup := sinks[name]; dup {
+		panic("logging: RegisterSink called twice for sink " + name)
+	}
+	sinks[name] = newSink
+}
+
+// NewSink creates a new Sink of the sink registered by a given name for a given target, see RegisterSink.
+func NewSink(name, target string) (Sink, error) {
+	sinksMu.RLock()
+	newSink, ok := sinks[name]
+	sinksMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink '%s'", name)
+	}
+	return newSink(target)
+}
+
+// Sinks returns the sorted names of the registered sinks.
+func Sinks() []string {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Route reads the log entries that happened within the last N minutes once, writing each of them to all the given
+// Sinks in turn, then flushes the sinks, even when an error (e.g. ErrNoFilesInWindow) is returned.
+// The first error is returned, be it of the reading, of a write or of a flush.
+func (logs *Logs) Route(ctx context.Context, sinks ...Sink) error {
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		for _, sink := range sinks {
+			if err := sink.Write(ctx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for _, sink := range sinks {
+		if flushErr := sink.Flush(ctx); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}
+	return err
+}
+
+// WriterSink is a Sink writing the raw log lines to an io.Writer, one per line, buffered until flushed.
+type WriterSink struct {
+	w *bufio.Writer
+}
+
+// NewWriterSink creates a WriterSink writing to a given io.Writer.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: bufio.NewWriter(w)}
+}
+
+// Write buffers the raw log line of a given log entry.
+func (s *WriterSink) Write(_ context.Context, entry LogEntry) error {
+	if _, err := s.w.WriteString(entry.Line); err != nil {
+		return err
+	}
+	return s.w.WriteByte('\n')
+}
+
+// Flush writes the buffered log lines to the io.Writer.
+func (s *WriterSink) Flush(context.Context) error {
+	return s.w.Flush()
+}
+
+// FileSink is a WriterSink appending the raw log lines to a file, created if needed.
+type FileSink struct {
+	*WriterSink
+	file *os.File
+}
+
+// NewFileSink creates a FileSink appending to the file of a given name, which must be closed once done with.
+func NewFileSink(name string) (*FileSink, error) {
+	if name == "" {
+		return nil, fmt.Errorf("invalid file sink: the file name must not be empty")
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{WriterSink: NewWriterSink(file), file: file}, nil
+}
+
+// Close flushes the buffered log lines and closes the file.
+func (s *FileSink) Close() error {
+	err := s.Flush(context.Background())
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const sinkDataDir = "test/sink/logs"
+
+// pathsSink records the paths of the log entries written to it, and how many times it was flushed.
+type pathsSink struct {
+	paths   []string
+	flushes int
+	err     error
+}
+
+func (sink *pathsSink) Write(_ context.Context, entry LogEntry) error {
+	if sink.err != nil {
+		return sink.err
+	}
+	sink.paths = append(sink.paths, entry.Path)
+	return nil
+}
+
+func (sink *pathsSink) Flush(context.Context) error {
+	sink.flushes++
+	return nil
+}
+
+type sinkSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *sinkSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(sinkDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:43:10 +0000] "GET /old HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.1" 500 10
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(sinkDataDir))
+}
+
+func (s *sinkSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(sinkDataDir)))
+}
+
+func (s *sinkSuite) Test_Route() {
+	first, second := &pathsSink{}, &pathsSink{}
+
+	err := s.logs.Route(context.Background(), first, second)
+
+	s.NoError(err)
+	s.Equal([]string{"/a", "/b"}, first.paths)
+	s.Equal([]string{"/a", "/b"}, second.paths)
+	s.Equal(1, first.flushes)
+	s.Equal(1, second.flushes)
+}
+
+func (s *sinkSuite) Test_Route_WriteError() {
+	sink := &pathsSink{err: errors.New("full")}
+
+	err := s.logs.Route(context.Background(), sink)
+
+	s.EqualError(err, "full")
+	// the sinks are flushed anyway
+	s.Equal(1, sink.flushes)
+}
+
+func (s *sinkSuite) Test_WriterSink() {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	s.Require().NoError(s.logs.Route(context.Background(), sink))
+
+	s.Equal(`10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.1" 500 10
+`, buf.String())
+}
+
+func (s *sinkSuite) Test_FileSink() {
+	name := filepath.Join(filepath.Dir(sinkDataDir), "window.log")
+	s.Require().NoError(os.WriteFile(name, []byte("before\n"), 0666))
+	sink, err := NewFileSink(name)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.logs.Route(context.Background(), sink))
+	s.Require().NoError(sink.Close())
+
+	content, err := os.ReadFile(name)
+	s.Require().NoError(err)
+	s.Equal(`before
+10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.1" 500 10
+`, string(content))
+
+	_, err = NewFileSink("")
+	s.EqualError(err, "invalid file sink: the file name must not be empty")
+}
+
+func (s *sinkSuite) Test_RegisterSink() {
+	sink := &pathsSink{}
+	RegisterSink("test-paths", func(target string) (Sink, error) {
+		s.Equal("target", target)
+		return sink, nil
+	})
+
+	s.Contains(Sinks(), "test-paths")
+	s.Contains(Sinks(), "file")
+	registered, err := NewSink("test-paths", "target")
+	s.Require().NoError(err)
+	s.NoError(s.logs.Route(context.Background(), registered))
+	s.Equal([]string{"/a", "/b"}, sink.paths)
+
+	s.Panics(func() {
+		RegisterSink("test-paths", func(string) (Sink, error) { return sink, nil })
+	})
+	s.Panics(func() {
+		RegisterSink("test-nil", nil)
+	})
+	_, err = NewSink("test-unknown", "")
+	s.EqualError(err, "unknown sink 'test-unknown'")
+}
+
+func TestSink(t *testing.T) {
+	suite.Run(t, new(sinkSuite))
+}
@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// copyFiles copies the log files of a given configuration into its CopyDir (see LogsConfig.CopyDir), each source
+// directory into its own subdirectory for the hosts (see LogsConfig.Hosts), returning the configuration reading
+// the copies instead. Only the files modified within the time range are copied, unless the files are ordered
+// by other times than their modified ones (see LogsConfig.OrderByContent and LogsConfig.FilenameTimePattern).
+func (cfg LogsConfig) copyFiles() (LogsConfig, error) {
+	if err := os.MkdirAll(cfg.CopyDir, 0777); err != nil {
+		return cfg, err
+	}
+	entries, err := os.ReadDir(cfg.CopyDir)
+	if err != nil {
+		return cfg, err
+	}
+	if len(entries) > 0 {
+		return cfg, fmt.Errorf("could not copy the log files: %s is not empty", cfg.CopyDir)
+	}
+
+	if len(cfg.Hosts) == 0 {
+		if err := cfg.copyDir(cfg.Directory, cfg.CopyDir); err != nil {
+			return cfg, err
+		}
+		cfg.Directory = cfg.CopyDir
+		return cfg, nil
+	}
+	hosts := make([]Host, len(cfg.Hosts))
+	for i, host := range cfg.Hosts {
+		// the names of the hosts can be anything, so the copies are numbered
+		dst := filepath.Join(cfg.CopyDir, strconv.Itoa(i+1))
+		if err := cfg.copyDir(host.Directory, dst); err != nil {
+			return cfg, fmt.Errorf("could not copy the files of host %s: %w", host.Name, err)
+		}
+		host.Directory = dst
+		hosts[i] = host
+	}
+	cfg.Hosts = hosts
+	return cfg, nil
+}
+
+// copyDir copies the log files of a given directory (see listFiles) into another one, keeping their relative paths
+// and their modified times.
+func (cfg LogsConfig) copyDir(src, dst string) error {
+	dirCfg := cfg
+	dirCfg.Directory, dirCfg.Hosts = src, nil
+	files, err := listFiles(dirCfg)
+	if err != nil {
+		return err
+	}
+	start := cfg.end().Add(-cfg.window())
+	for _, fi := range files {
+		if !cfg.OrderByContent && cfg.FilenameTimePattern == "" && fi.ModTime().Before(start) {
+			continue
+		}
+		rel, err := filepath.Rel(src, fi.path)
+		if err != nil {
+			return err
+		}
+		if err := copyLogFile(fi, filepath.Join(dst, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyLogFile copies a given log file to a given path, with the modified time of the original.
+func copyLogFile(fi logFile, name string) error {
+	src, err := openLogFile(fi.path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(name, fi.ModTime(), fi.ModTime())
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	snapshotDataDir = "test/snapshot/logs"
+	snapshotCopyDir = "test/snapshot/copy"
+)
+
+type snapshotSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *snapshotSuite) SetupTest() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:10:00 +0000")
+	s.testTime = t
+	files := map[string]time.Time{"access.log": t, "access.log.1": t.Add(-2 * time.Hour)}
+	for name, modified := range files {
+		// the window starts in the middle of the file, which is then searched
+		writeLogFile(s.T(), filepath.Join(snapshotDataDir, name), `10.0.0.1 - - [`+modified.Add(-2*time.Hour).Format(dateTimeFormat)+`] "GET /old HTTP/1.1" 200 10
+10.0.0.1 - - [`+modified.Add(-time.Minute).Format(dateTimeFormat)+`] "GET /`+name+` HTTP/1.1" 200 10
+`, modified)
+	}
+}
+
+func (s *snapshotSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(snapshotDataDir)))
+}
+
+func (s *snapshotSuite) Test_ReadOnly() {
+	file := filepath.Join(snapshotDataDir, "access.log")
+	before, err := os.ReadFile(file)
+	s.Require().NoError(err)
+
+	logs, err := New(WithDirectory(snapshotDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithIndex(time.Second))
+	s.Require().NoError(err)
+	var out bytes.Buffer
+	s.Require().NoError(logs.Print(context.Background(), &out))
+	s.Equal(string(before[bytes.IndexByte(before, '\n')+1:]), out.String())
+
+	// the log file is left as it was, its index being written beside it
+	after, err := os.ReadFile(file)
+	s.Require().NoError(err)
+	s.Equal(before, after)
+	info, err := os.Stat(file)
+	s.Require().NoError(err)
+	s.True(info.ModTime().Equal(s.testTime))
+	s.FileExists(file + IndexSuffix)
+}
+
+func (s *snapshotSuite) Test_CopyDir() {
+	logs, err := New(WithDirectory(snapshotDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithIndex(time.Second),
+		WithCopyDir(snapshotCopyDir))
+	s.Require().NoError(err)
+	var files []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		files = append(files, entry.File)
+		return nil
+	}))
+	s.Equal([]string{filepath.Join(snapshotCopyDir, "access.log")}, files)
+
+	// the file modified before the time range is not copied, and the index is written next to the copy
+	s.NoFileExists(filepath.Join(snapshotCopyDir, "access.log.1"))
+	s.FileExists(filepath.Join(snapshotCopyDir, "access.log"+IndexSuffix))
+	s.NoFileExists(filepath.Join(snapshotDataDir, "access.log"+IndexSuffix))
+	info, err := os.Stat(filepath.Join(snapshotCopyDir, "access.log"))
+	s.Require().NoError(err)
+	s.True(info.ModTime().Equal(s.testTime))
+}
+
+func (s *snapshotSuite) Test_CopyDir_NotEmpty() {
+	s.Require().NoError(os.MkdirAll(snapshotCopyDir, 0777))
+	s.Require().NoError(os.WriteFile(filepath.Join(snapshotCopyDir, "access.log"), nil, 0666))
+	_, err := New(WithDirectory(snapshotDataDir), WithWindow(time.Hour), WithEnd(s.testTime), WithCopyDir(snapshotCopyDir))
+	s.EqualError(err, "could not copy the log files: "+snapshotCopyDir+" is not empty")
+}
+
+func TestSnapshotSuite(t *testing.T) {
+	suite.Run(t, new(snapshotSuite))
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsorted is matched by every *UnsortedError, see LogsConfig.AssertSorted.
+var ErrUnsorted = errors.New("log entries out of order")
+
+// UnsortedError describes a log line older than a log line streamed before it, which the reorder buffer
+// couldn't put back in order (see LogsConfig.AssertSorted and LogsConfig.ReorderBuffer).
+type UnsortedError struct {
+	// File, Offset, Time and Content are the ones of the log line out of order.
+	File    string
+	Offset  int64
+	Time    time.Time
+	Content string
+	// PreviousFile, PreviousOffset and PreviousTime are the ones of the latest log line streamed before it.
+	PreviousFile   string
+	PreviousOffset int64
+	PreviousTime   time.Time
+	// ReorderBuffer is the number of log entries the reorder buffer held.
+	ReorderBuffer int
+}
+
+func (e *UnsortedError) Error() string {
+	return fmt.Sprintf("%s (offset %d): log line at %s older than the one of %s (offset %d) at %s, beyond a reorder buffer of %d entries: '%s'",
+		e.File, e.Offset, e.Time.Format(time.RFC3339), e.PreviousFile, e.PreviousOffset, e.PreviousTime.Format(time.RFC3339),
+		e.ReorderBuffer, e.Content)
+}
+
+// Is makes every UnsortedError match ErrUnsorted.
+func (e *UnsortedError) Is(target error) bool {
+	return target == ErrUnsorted
+}
+
+// bufferedEntry is a log entry held by a reorder buffer, along with the order it was read in,
+// so the entries of the same time keep it.
+type bufferedEntry struct {
+	entry LogEntry
+	seq   int64
+}
+
+// entryHeap is a min heap of buffered log entries ordered by time, then by the order they were read in.
+type entryHeap []bufferedEntry
+
+func (h entryHeap) Len() int { return len(h) }
+func (h entryHeap) Less(i, j int) bool {
+	if h[i].entry.Time.Equal(h[j].entry.Time) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].entry.Time.Before(h[j].entry.Time)
+}
+func (h entryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *entryHeap) Push(x interface{}) { *h = append(*h, x.(bufferedEntry)) }
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// sorter guarantees the log entries of a stream are in order (see LogsConfig.AssertSorted), holding back
+// a given number of them to put the ones slightly out of order back in order, and failing with an *UnsortedError
+// on the first one older than an entry streamed already.
+type sorter struct {
+	size   int
+	buffer entryHeap
+	seq    int64
+	// last is the latest entry streamed, if any (emitted)
+	last    LogEntry
+	emitted bool
+	done    bool
+	err     error
+}
+
+// next advances the sorter to the following log entry of a given stream, returning false once there are
+// no entries left or an error occurred, either the one of the stream or an *UnsortedError kept by the sorter.
+func (s *sorter) next(st *stream) bool {
+	if s.err != nil {
+		return false
+	}
+	for !s.done && len(s.buffer) <= s.size {
+		if !st.next() {
+			if st.err != nil {
+				return false
+			}
+			s.done = true
+			break
+		}
+		entry := st.entry()
+		if s.emitted && entry.Time.Before(s.last.Time) {
+			s.err = &UnsortedError{
+				File: entry.File, Offset: entry.Offset, Time: entry.Time, Content: entry.Line,
+				PreviousFile: s.last.File, PreviousOffset: s.last.Offset, PreviousTime: s.last.Time,
+				ReorderBuffer: s.size,
+			}
+			return false
+		}
+		heap.Push(&s.buffer, bufferedEntry{entry: entry, seq: s.seq})
+		s.seq++
+	}
+	if len(s.buffer) == 0 {
+		return false
+	}
+	s.last, s.emitted = heap.Pop(&s.buffer).(bufferedEntry).entry, true
+	return true
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const sortedDataDir = "test/sorted"
+
+type sortedSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *sortedSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:01:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(sortedDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:10:00:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:20 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:10 +0000] "GET /c HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:30 +0000] "GET /d HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:05 +0000] "GET /e HTTP/1.1" 200 10
+`, t)
+}
+
+func (s *sortedSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(sortedDataDir)))
+}
+
+// paths returns the paths of the log entries read with the given options, along with the error of the reading.
+func (s *sortedSuite) paths(opts ...Option) ([]string, error) {
+	logs, err := New(append([]Option{WithDirectory(sortedDataDir), WithWindow(time.Hour), WithEnd(s.testTime)}, opts...)...)
+	s.Require().NoError(err)
+	var paths []string
+	err = logs.ForEach(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+	return paths, err
+}
+
+func (s *sortedSuite) Test_AssertSorted_Off() {
+	paths, err := s.paths()
+	s.Require().NoError(err)
+	s.Equal([]string{"/a", "/b", "/c", "/d", "/e"}, paths)
+}
+
+func (s *sortedSuite) Test_AssertSorted_Verify() {
+	paths, err := s.paths(WithAssertSorted(0))
+	s.Equal([]string{"/a", "/b"}, paths)
+	s.Require().ErrorIs(err, ErrUnsorted)
+	var unsorted *UnsortedError
+	s.Require().ErrorAs(err, &unsorted)
+	s.Equal(filepath.Join(sortedDataDir, "access.log"), unsorted.File)
+	s.Equal(int64(134), unsorted.Offset)
+	s.Contains(unsorted.Content, "/c")
+	s.Equal(int64(67), unsorted.PreviousOffset)
+	s.Contains(err.Error(), "beyond a reorder buffer of 0 entries")
+}
+
+func (s *sortedSuite) Test_AssertSorted_BufferTooSmall() {
+	paths, err := s.paths(WithAssertSorted(1))
+	// /c is put back before /b, but /e comes too late
+	s.Equal([]string{"/a", "/c", "/b"}, paths)
+	var unsorted *UnsortedError
+	s.Require().ErrorAs(err, &unsorted)
+	s.Contains(unsorted.Content, "/e")
+	s.Equal(int64(67), unsorted.PreviousOffset)
+}
+
+func (s *sortedSuite) Test_AssertSorted_Reordered() {
+	paths, err := s.paths(WithAssertSorted(3))
+	s.Require().NoError(err)
+	s.Equal([]string{"/a", "/e", "/c", "/b", "/d"}, paths)
+}
+
+func (s *sortedSuite) Test_AssertSorted_Print() {
+	logs, err := New(WithDirectory(sortedDataDir), WithWindow(time.Hour), WithAssertSorted(0))
+	s.Require().NoError(err)
+	s.False(logs.copyable())
+}
+
+func (s *sortedSuite) Test_AssertSorted_InvalidBuffer() {
+	_, err := New(WithDirectory(sortedDataDir), WithAssertSorted(-1))
+	var configErr *ConfigError
+	s.Require().ErrorAs(err, &configErr)
+	s.Equal("reorder buffer", configErr.Field)
+}
+
+func TestSortedSuite(t *testing.T) {
+	suite.Run(t, new(sortedSuite))
+}
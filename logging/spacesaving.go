@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"container/heap"
+)
+
+// spaceSaving counts the most frequent values of a stream within constant memory, however many distinct values
+// there are (Metwally et al., space-saving): at most a given number of values are counted, and once full,
+// a new value takes the place of the least counted one, inheriting its count. The counts are overestimated
+// by the count inherited at most, while any value counted more than the least count of the full counter
+// is guaranteed to be kept.
+type spaceSaving struct {
+	capacity int
+	// counters is a min heap of the counted values by their counts
+	counters spaceSavingHeap
+}
+
+// spaceSavingCounter is the count of a value, and the count it inherited from the value it replaced.
+type spaceSavingCounter struct {
+	value     string
+	count     int64
+	overcount int64
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{capacity: capacity, counters: spaceSavingHeap{index: make(map[string]int, capacity)}}
+}
+
+// add adds a given weight to the count of a value.
+func (ss *spaceSaving) add(value string, weight int64) {
+	if i, ok := ss.counters.index[value]; ok {
+		ss.counters.counters[i].count += weight
+		heap.Fix(&ss.counters, i)
+		return
+	}
+	if len(ss.counters.counters) < ss.capacity {
+		heap.Push(&ss.counters, spaceSavingCounter{value: value, count: weight})
+		return
+	}
+	// the least counted value is replaced
+	least := ss.counters.counters[0]
+	delete(ss.counters.index, least.value)
+	ss.counters.counters[0] = spaceSavingCounter{value: value, count: least.count + weight, overcount: least.count}
+	ss.counters.index[value] = 0
+	heap.Fix(&ss.counters, 0)
+}
+
+// counts returns the counts of the values counted.
+func (ss *spaceSaving) counts() map[string]int64 {
+	counts := make(map[string]int64, len(ss.counters.counters))
+	for _, c := range ss.counters.counters {
+		counts[c.value] = c.count
+	}
+	return counts
+}
+
+// maxError returns how much the counts are overestimated at most.
+func (ss *spaceSaving) maxError() int64 {
+	var max int64
+	for _, c := range ss.counters.counters {
+		if c.overcount > max {
+			max = c.overcount
+		}
+	}
+	return max
+}
+
+// spaceSavingHeap is a min heap of counters by their counts, along with their positions by value.
+type spaceSavingHeap struct {
+	counters []spaceSavingCounter
+	index    map[string]int
+}
+
+func (h spaceSavingHeap) Len() int           { return len(h.counters) }
+func (h spaceSavingHeap) Less(i, j int) bool { return h.counters[i].count < h.counters[j].count }
+func (h spaceSavingHeap) Swap(i, j int) {
+	h.counters[i], h.counters[j] = h.counters[j], h.counters[i]
+	h.index[h.counters[i].value] = i
+	h.index[h.counters[j].value] = j
+}
+func (h *spaceSavingHeap) Push(x interface{}) {
+	c := x.(spaceSavingCounter)
+	h.index[c.value] = len(h.counters)
+	h.counters = append(h.counters, c)
+}
+func (h *spaceSavingHeap) Pop() interface{} {
+	old := h.counters
+	c := old[len(old)-1]
+	h.counters = old[:len(old)-1]
+	delete(h.index, c.value)
+	return c
+}
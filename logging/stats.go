@@ -0,0 +1,228 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Stats summarizes the requests logged within the last N minutes.
+// Only the log lines that could be parsed as requests are taken into account.
+type Stats struct {
+	// Requests is the number of requests.
+	Requests int
+	// Errors is the number of requests answered with a server error (5xx).
+	Errors int
+	// Bots is the number of requests made by bots, only known when classifying
+	// the log entries (see LogsConfig.Bots).
+	Bots int
+	// Bytes is the total size of the responses in bytes.
+	Bytes int64
+	// Window is the time range the requests were looked for in.
+	Window time.Duration
+	// Statuses counts the requests by their status code, 0 standing for an unknown status ("-").
+	Statuses map[int]int
+	ips      *hyperLogLog
+}
+
+// StatusCount is the number of requests answered with a given status code.
+type StatusCount struct {
+	Status int `json:"status"`
+	Count  int `json:"count"`
+}
+
+// NewStats creates empty Stats for requests logged within the given time window.
+func NewStats(window time.Duration) *Stats {
+	return &Stats{
+		Window:   window,
+		Statuses: make(map[int]int),
+		ips:      newHyperLogLog(),
+	}
+}
+
+// Stats reads the log entries that happened within the last N minutes and summarizes them.
+// The stats are returned even along with an error (e.g. ErrNoFilesInWindow),
+// summarizing the entries read until then.
+func (logs *Logs) Stats(ctx context.Context) (*Stats, error) {
+	stats := NewStats(logs.cfg.window())
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		stats.Add(entry)
+		return nil
+	})
+
+	return stats, err
+}
+
+// Add accounts for a given log entry, ignoring the ones that are not requests.
+func (stats *Stats) Add(entry LogEntry) {
+	if entry.IP == "" {
+		return
+	}
+
+	stats.Requests++
+	stats.Bytes += entry.Size
+	stats.Statuses[entry.Status]++
+	stats.ips.add(entry.IP)
+	if entry.serverError() {
+		stats.Errors++
+	}
+	if entry.Bot != "" {
+		stats.Bots++
+	}
+}
+
+// Result returns the Stats themselves.
+func (stats *Stats) Result() interface{} {
+	return stats
+}
+
+// UniqueIPs returns the estimated number of distinct client IPs (unique visitors).
+// The estimation uses constant memory however large the window is, and is typically off
+// by less than 1% (see hyperLogLog), while being exact for a handful of IPs.
+func (stats *Stats) UniqueIPs() int {
+	return int(stats.ips.count())
+}
+
+// ErrorRate returns the share of requests answered with a server error, between 0 and 1.
+func (stats *Stats) ErrorRate() float64 {
+	if stats.Requests == 0 {
+		return 0
+	}
+	return float64(stats.Errors) / float64(stats.Requests)
+}
+
+// BotRate returns the share of requests made by bots, between 0 and 1.
+func (stats *Stats) BotRate() float64 {
+	if stats.Requests == 0 {
+		return 0
+	}
+	return float64(stats.Bots) / float64(stats.Requests)
+}
+
+// RequestsPerSecond returns the average number of requests per second over the time window.
+func (stats *Stats) RequestsPerSecond() float64 {
+	if stats.Window <= 0 {
+		return 0
+	}
+	return float64(stats.Requests) / stats.Window.Seconds()
+}
+
+// TopStatuses returns the n most frequent status codes, the most frequent first,
+// or all of them when n is not positive. Ties are ordered by status code.
+func (stats *Stats) TopStatuses(n int) []StatusCount {
+	counts := make([]StatusCount, 0, len(stats.Statuses))
+	for status, count := range stats.Statuses {
+		counts = append(counts, StatusCount{Status: status, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Status < counts[j].Status
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	if n > 0 && n < len(counts) {
+		counts = counts[:n]
+	}
+
+	return counts
+}
+
+// StatsReport summarizes the stats along with their n most frequent status codes, e.g. to be marshalled to JSON.
+type StatsReport struct {
+	Requests          int           `json:"requests"`
+	UniqueIPs         int           `json:"unique_ips"`
+	Errors            int           `json:"errors"`
+	ErrorRate         float64       `json:"error_rate"`
+	Bots              int           `json:"bots"`
+	BotRate           float64       `json:"bot_rate"`
+	Bytes             int64         `json:"bytes"`
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	TopStatuses       []StatusCount `json:"top_statuses"`
+}
+
+// Report returns the report of the stats along with their n most frequent status codes,
+// or all of them when n is not positive (see TopStatuses).
+func (stats *Stats) Report(n int) StatsReport {
+	return StatsReport{
+		Requests:          stats.Requests,
+		UniqueIPs:         stats.UniqueIPs(),
+		Errors:            stats.Errors,
+		ErrorRate:         stats.ErrorRate(),
+		Bots:              stats.Bots,
+		BotRate:           stats.BotRate(),
+		Bytes:             stats.Bytes,
+		RequestsPerSecond: stats.RequestsPerSecond(),
+		TopStatuses:       stats.TopStatuses(n),
+	}
+}
+
+// StatusHistogram breaks the requests down by status code class (1xx to 5xx) and by status code.
+type StatusHistogram struct {
+	Total int `json:"total"`
+	// Classes always holds the 1xx to 5xx classes in order, followed by
+	// an "unknown" class when some requests have no (or an unusual) status code.
+	Classes []StatusBucket `json:"classes"`
+	// Codes holds the individual status codes in order, "-" standing for an unknown status.
+	Codes []StatusBucket `json:"codes"`
+}
+
+// StatusBucket is the number of requests answered with a status code or a status code class.
+type StatusBucket struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+	// Percent is the share of the requests of the bucket, between 0 and 100.
+	Percent float64 `json:"percent"`
+}
+
+// Histogram returns the status code histogram of the requests.
+func (stats *Stats) Histogram() StatusHistogram {
+	histogram := StatusHistogram{Total: stats.Requests}
+	percent := func(count int) float64 {
+		if stats.Requests == 0 {
+			return 0
+		}
+		return float64(count) * 100 / float64(stats.Requests)
+	}
+
+	classes := make([]int, 6)
+	codes := make([]int, 0, len(stats.Statuses))
+	for status, count := range stats.Statuses {
+		codes = append(codes, status)
+		if status >= 100 && status < 600 {
+			classes[status/100] += count
+		} else {
+			classes[0] += count
+		}
+	}
+	for class := 1; class < len(classes); class++ {
+		histogram.Classes = append(histogram.Classes, StatusBucket{
+			Status:  fmt.Sprintf("%dxx", class),
+			Count:   classes[class],
+			Percent: percent(classes[class]),
+		})
+	}
+	if classes[0] > 0 {
+		histogram.Classes = append(histogram.Classes, StatusBucket{
+			Status:  "unknown",
+			Count:   classes[0],
+			Percent: percent(classes[0]),
+		})
+	}
+
+	sort.Ints(codes)
+	for _, status := range codes {
+		bucket := StatusBucket{
+			Status:  strconv.Itoa(status),
+			Count:   stats.Statuses[status],
+			Percent: percent(stats.Statuses[status]),
+		}
+		if status == 0 {
+			bucket.Status = "-"
+		}
+		histogram.Codes = append(histogram.Codes, bucket)
+	}
+
+	return histogram
+}
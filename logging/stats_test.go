@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const statsDataDir = "test/stats"
+
+type statsSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *statsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(statsDataDir, "http.log"), `10.0.0.1 - frank [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.0" 200 20
+10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /a HTTP/1.0" 200 20
+this line cannot be parsed
+10.0.0.1 - frank [03/Mar/2022:02:44:30 +0000] "POST /b HTTP/1.1" 500 30
+10.0.0.3 - frank [03/Mar/2022:02:44:40 +0000] "GET /c HTTP/1.1" 404 -
+10.0.0.1 - frank [03/Mar/2022:02:44:50 +0000] "GET /a HTTP/1.0" 200 20
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(statsDataDir))
+}
+
+func (s *statsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(statsDataDir)))
+}
+
+func (s *statsSuite) Test_Stats() {
+	stats, err := s.logs.Stats(context.Background())
+
+	s.Require().NoError(err)
+	s.Equal(5, stats.Requests)
+	s.Equal(3, stats.UniqueIPs())
+	s.Equal(1, stats.Errors)
+	s.InDelta(0.2, stats.ErrorRate(), 1e-9)
+	s.Equal(int64(90), stats.Bytes)
+	s.InDelta(5.0/60, stats.RequestsPerSecond(), 1e-9)
+	s.Equal(map[int]int{200: 3, 404: 1, 500: 1}, stats.Statuses)
+}
+
+func (s *statsSuite) Test_TopStatuses() {
+	stats, err := s.logs.Stats(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal([]StatusCount{{Status: 200, Count: 3}, {Status: 404, Count: 1}}, stats.TopStatuses(2))
+	s.Len(stats.TopStatuses(0), 3)
+}
+
+func (s *statsSuite) Test_Histogram() {
+	stats := NewStats(time.Minute)
+	for _, status := range []int{200, 200, 201, 404, 500, 0, 200, 999} {
+		stats.Add(LogEntry{IP: "10.0.0.1", Status: status})
+	}
+
+	histogram := stats.Histogram()
+
+	s.Equal(8, histogram.Total)
+	s.Equal([]StatusBucket{
+		{Status: "1xx", Count: 0, Percent: 0},
+		{Status: "2xx", Count: 4, Percent: 50},
+		{Status: "3xx", Count: 0, Percent: 0},
+		{Status: "4xx", Count: 1, Percent: 12.5},
+		{Status: "5xx", Count: 1, Percent: 12.5},
+		{Status: "unknown", Count: 2, Percent: 25},
+	}, histogram.Classes)
+	s.Equal([]StatusBucket{
+		{Status: "-", Count: 1, Percent: 12.5},
+		{Status: "200", Count: 3, Percent: 37.5},
+		{Status: "201", Count: 1, Percent: 12.5},
+		{Status: "404", Count: 1, Percent: 12.5},
+		{Status: "500", Count: 1, Percent: 12.5},
+		{Status: "999", Count: 1, Percent: 12.5},
+	}, histogram.Codes)
+}
+
+func (s *statsSuite) Test_Histogram_Empty() {
+	histogram := NewStats(time.Minute).Histogram()
+
+	s.Equal(0, histogram.Total)
+	s.Len(histogram.Classes, 5)
+	s.Empty(histogram.Codes)
+}
+
+func (s *statsSuite) Test_UniqueIPs() {
+	tests := []struct {
+		name  string
+		ips   int
+		delta float64
+	}{
+		{name: "Few IPs", ips: 1000, delta: 0.01},
+		{name: "Many IPs", ips: 500000, delta: 0.03},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			stats := NewStats(time.Minute)
+			for i := 0; i < test.ips; i++ {
+				ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+				// every IP is added twice, as a returning visitor
+				stats.Add(LogEntry{IP: ip})
+				stats.Add(LogEntry{IP: ip})
+			}
+
+			s.InEpsilon(test.ips, stats.UniqueIPs(), test.delta)
+		})
+	}
+}
+
+func (s *statsSuite) Test_Report() {
+	stats, err := s.logs.Stats(context.Background())
+	s.Require().NoError(err)
+
+	report := stats.Report(1)
+
+	s.Equal(StatsReport{
+		Requests:          5,
+		UniqueIPs:         3,
+		Errors:            1,
+		ErrorRate:         stats.ErrorRate(),
+		Bytes:             90,
+		RequestsPerSecond: stats.RequestsPerSecond(),
+		TopStatuses:       []StatusCount{{Status: 200, Count: 3}},
+	}, report)
+}
+
+func (s *statsSuite) Test_Stats_Empty() {
+	stats := NewStats(time.Minute)
+
+	s.Equal(0, stats.Requests)
+	s.Equal(0.0, stats.ErrorRate())
+	s.Equal(0.0, stats.RequestsPerSecond())
+	s.Empty(stats.TopStatuses(5))
+}
+
+func TestStats(t *testing.T) {
+	suite.Run(t, new(statsSuite))
+}
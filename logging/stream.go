@@ -0,0 +1,619 @@
+package logging
+
+import (
+	"bufio"
+	"container/heap"
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cursor reads the log lines of a single file one by one,
+// keeping track of the current log entry.
+type cursor struct {
+	file   File
+	reader *bufio.Reader
+	// order is the position of the file within the streamed files, and tie orders the logs with the same time
+	// of the cursors, their order unless ordered by path (see LogsConfig.DeterministicOrder)
+	order int
+	tie   int
+	entry LogEntry
+	// offset is the offset of the following line inside the file
+	offset int64
+	// skips are the byte ranges of the file the cursor jumps over, without any accepted log line (see LogsConfig.IndexBloom),
+	// read through a context
+	skips   []byteRange
+	readCtx context.Context
+	// the logs within the first filtered bytes are only kept
+	// when they happened after the lookup time (see Logs.rewind)
+	filtered   int64
+	lookupTime time.Time
+	// the logs before the boundary offset that happened before the lookup time are left out (see boundaryScanSize),
+	// along with the invalid lines following them (dropped)
+	boundary int64
+	dropped  bool
+
+	// batches are the log entries of the file read ahead by another goroutine, along with whether
+	// they are accepted (see Logs.accept), when the files are read in parallel (see LogsConfig.Workers)
+	batches  <-chan cursorBatch
+	batch    cursorBatch
+	accepted bool
+	// ctx is the context of the goroutine reading ahead, which stop cancels
+	ctx  context.Context
+	stop context.CancelFunc
+
+	// logger reports the statistics of the file once read (see cursor.release), since started (see LogsConfig.Logger)
+	logger Logger
+	// span is the span of the reading of the file, ended along with its statistics (see LogsConfig.Tracer)
+	span    Span
+	stats   fileStats
+	started time.Time
+	// progress counts the bytes of the file read or skipped, if reported (see LogsConfig.Progress)
+	progress *fileProgress
+	// metrics counts the lines left out, if any (see LogsConfig.Metrics)
+	metrics *Metrics
+	// errors counts the invalid lines kept, if any (see LogsConfig.Errors)
+	errors *ErrorSummary
+
+	// parked tells the file was closed to stay under the maximum of open files, its info (if known) making sure
+	// the same file is opened again, and lru is the element of the cursor among the open ones (see openFiles)
+	parked bool
+	info   os.FileInfo
+	lru    *list.Element
+}
+
+// next advances the cursor to the following line, returning false once there are no lines left.
+// Lines that cannot be parsed inherit the time of the previous line, so they stay next to it,
+// except for a last line without a newline, which is left out (see File.end).
+func (c *cursor) next() (bool, error) {
+	if c.batches != nil {
+		return c.nextAhead()
+	}
+	for {
+		c.skip()
+		line, err := c.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if line == "" {
+			return false, nil
+		}
+		offset := c.offset
+		c.offset += int64(len(line))
+		c.progress.add(int64(len(line)))
+		c.stats.bytes += int64(len(line))
+
+		entry, parseErr := c.stats.parse(c.file, line, c.logger != nil)
+		if c.filtered > 0 {
+			c.filtered -= int64(len(line))
+			if parseErr != nil || entry.Time.Before(c.lookupTime) {
+				if parseErr != nil && c.logger != nil {
+					c.logger.Debug("invalid line skipped before window", "file", c.file.Name(), "offset", offset)
+				}
+				c.metrics.skipped()
+				continue
+			}
+		}
+
+		if offset < c.boundary && (parseErr == nil && entry.Time.Before(c.lookupTime) || parseErr != nil && c.dropped) {
+			c.dropped = true
+			if c.logger != nil {
+				c.logger.Debug("line before window skipped", "file", c.file.Name(), "offset", offset)
+			}
+			c.metrics.skipped()
+			continue
+		}
+		c.dropped = false
+
+		if !strings.HasSuffix(line, "\n") && parseErr != nil {
+			// the last line is most likely still being written
+			if c.logger != nil {
+				c.logger.Debug("incomplete last line left out", "file", c.file.Name(), "offset", offset)
+			}
+			return false, nil
+		}
+		c.stats.lines++
+		if parseErr != nil {
+			entry.Time = c.entry.Time
+			c.stats.invalid++
+			c.errors.Add(parseErr)
+			if c.logger != nil {
+				c.logger.Debug("invalid line kept with the time of the previous one", "file", c.file.Name(), "offset", offset, "error", parseErr)
+			}
+		}
+		entry.Line = strings.TrimRight(line, "\r\n")
+		entry.File = c.file.Name()
+		entry.Offset = offset
+		c.entry = entry
+		return true, nil
+	}
+}
+
+// skip moves the cursor past the byte range to skip it's in, if any.
+func (c *cursor) skip() {
+	for len(c.skips) > 0 && c.skips[0].end <= c.offset {
+		c.skips = c.skips[1:]
+	}
+	if len(c.skips) == 0 || c.skips[0].start > c.offset {
+		return
+	}
+	end := c.skips[0].end
+	c.skips = c.skips[1:]
+	c.progress.add(end - c.offset)
+	c.filtered -= end - c.offset
+	c.offset = end
+	c.reader.Reset(contextReader{ctx: c.readCtx, r: io.NewSectionReader(c.file, end, math.MaxInt64-end)})
+}
+
+// accept checks whether the current log entry of the cursor is accepted by given logs (see Logs.accept),
+// which has already been checked by the goroutine reading ahead, if any.
+func (c *cursor) accept(logs *Logs) bool {
+	if c.batches != nil {
+		return c.accepted
+	}
+	accepted := logs.accept(&c.entry)
+	c.stats.accepted(c.entry.Time, accepted)
+	return accepted
+}
+
+// close closes the file of the cursor, or stops the goroutine reading it ahead.
+func (c *cursor) close() {
+	if c.batches != nil {
+		c.stop()
+		return
+	}
+	c.release()
+	if !c.parked {
+		_ = c.file.Close()
+	}
+}
+
+// release puts back the reader of the cursor into the pool (see readerPool), once done reading the file,
+// reporting its statistics (see LogsConfig.Logger) and counting the rest of the file as processed (see LogsConfig.Progress).
+func (c *cursor) release() {
+	if c.reader != nil {
+		putReader(c.reader)
+		c.reader = nil
+		c.progress.done()
+		if c.logger != nil {
+			c.logger.Info("file read", c.stats.args(c.file.Name(), time.Since(c.started))...)
+		}
+		c.span.End(nil, c.stats.args(c.file.Name(), time.Since(c.started))...)
+	}
+}
+
+// cursorHeap is a min heap of cursors ordered by the time of their current line.
+type cursorHeap []*cursor
+
+func (h cursorHeap) Len() int { return len(h) }
+func (h cursorHeap) Less(i, j int) bool {
+	if h[i].entry.Time.Equal(h[j].entry.Time) {
+		return h[i].tie < h[j].tie
+	}
+	return h[i].entry.Time.Before(h[j].entry.Time)
+}
+func (h cursorHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *cursorHeap) Push(x interface{}) { *h = append(*h, x.(*cursor)) }
+func (h *cursorHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// stream iterates in order over the log lines that happened within the last N minutes.
+// The files are either read one after another, opening each file only once the previous
+// one is done, or all at once, interleaving their lines by time (see LogsConfig.Merge).
+type stream struct {
+	ctx  context.Context
+	logs *Logs
+	// until is the time the lines are streamed until, if any (see LogsConfig.until)
+	until time.Time
+	// span is the span of the stream, ended once closed (see LogsConfig.Tracer)
+	span Span
+	// files are the files left to be opened
+	files  []logFile
+	opened int
+	// ranks are the positions of the paths of the files sorted, ordering the logs with the same time
+	// when told so (see LogsConfig.DeterministicOrder)
+	ranks   map[string]int
+	cursors cursorHeap
+	started bool
+	err     error
+
+	// ahead are the cursors over the files being read ahead, in order, and workers
+	// limits the number of files parsed at once, when reading them in parallel (see LogsConfig.Workers)
+	ahead   []*cursor
+	workers chan struct{}
+	readers sync.WaitGroup
+	// progress is the progress of the stream, if reported (see LogsConfig.Progress)
+	progress *progress
+	// dedup detects the log lines duplicated across files, if told so (see LogsConfig.Duplicates)
+	dedup *deduplicator
+	// open keeps the number of open files under a maximum, if any (see LogsConfig.MaxOpenFiles)
+	open *openFiles
+}
+
+// stream creates a new stream over the log files that contain logs within the last N minutes,
+// failing with ErrNoFilesInWindow when none of the files was modified within that time.
+// Make sure to close the stream once done with it.
+func (logs *Logs) stream(ctx context.Context) *stream {
+	ctx, span := logs.startSpan(ctx, SpanStream)
+	s := &stream{ctx: ctx, logs: logs, until: logs.cfg.until(), span: span}
+	files, err := logs.files(ctx)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	idx := logs.index(files)
+	logs.selected(files, idx)
+	if idx < 0 {
+		s.err = ErrNoFilesInWindow
+		return s
+	}
+	s.files = files[idx:]
+	if logs.cfg.DeterministicOrder {
+		s.ranks = pathRanks(s.files)
+	}
+	s.progress = logs.newProgress(s.files)
+	if logs.cfg.Workers > 1 {
+		s.workers = make(chan struct{}, logs.cfg.Workers)
+	}
+	if logs.cfg.Duplicates != "" {
+		s.dedup = newDeduplicator(logs.cfg.DuplicateWindow)
+	}
+	s.open = newOpenFiles(logs.cfg.MaxOpenFiles)
+	return s
+}
+
+// next advances the stream to the following line, returning false once there are no lines left,
+// the end of the time range is reached (see LogsConfig.End) or an error occurred, in which case
+// the error is kept by the stream.
+func (s *stream) next() bool {
+	for s.advance() {
+		c := s.cursors[0]
+		if !s.until.IsZero() && !c.entry.Time.Before(s.until) {
+			s.close()
+			s.progress.finish()
+			return false
+		}
+		if c.accept(s.logs) && !s.duplicate(c.entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicate checks whether a given accepted log entry is a duplicate to leave out, counting and reporting
+// the duplicates either way (see LogsConfig.Duplicates).
+func (s *stream) duplicate(entry LogEntry) bool {
+	if s.dedup == nil {
+		return false
+	}
+	seen, ok := s.dedup.duplicate(entry)
+	if !ok {
+		return false
+	}
+	s.logs.cfg.Metrics.duplicated()
+	if logger := s.logs.cfg.Logger; logger != nil {
+		logger.Debug("duplicate line", "file", entry.File, "offset", entry.Offset, "of", seen.file, "at", seen.offset)
+	}
+	if s.logs.cfg.Duplicates != DuplicatesSuppress {
+		return false
+	}
+	s.logs.cfg.Metrics.skipped()
+	return true
+}
+
+// accept leaves out the entries shipped already (see LogsConfig.Checkpoints), labels a given entry with its host, classifies it as a bot or a human and resolves its client IP when configured
+// (see LogsConfig.Hosts, LogsConfig.Bots and LogsConfig.XFF),
+// transforms it with the configured middlewares, then checks it against all the configured filters, counting the entries left out (see LogsConfig.Metrics).
+func (logs *Logs) accept(entry *LogEntry) bool {
+	if logs.filter(entry) {
+		return true
+	}
+	logs.cfg.Metrics.skipped()
+	return false
+}
+
+// filter classifies, transforms and checks a given entry the way accept does, without counting it.
+func (logs *Logs) filter(entry *LogEntry) bool {
+	if logs.cfg.Checkpoints.shipped(*entry) {
+		return false
+	}
+	if len(logs.hostDirs) > 0 {
+		entry.Host = logs.host(entry.File)
+	}
+	if bots := logs.cfg.Bots; bots != nil {
+		entry.Bot = bots.Classify(entry.UserAgent)
+	}
+	if logs.cfg.XFF != "" {
+		entry.IP = ClientIP(*entry, logs.cfg.XFF, logs.cfg.TrustedProxies)
+	}
+	if (logs.cfg.IP != "" && entry.IP != logs.cfg.IP) || !strings.HasPrefix(entry.Path, logs.cfg.PathPrefix) {
+		return false
+	}
+	for _, middleware := range logs.cfg.Middlewares {
+		transformed, ok := middleware(*entry)
+		if !ok {
+			return false
+		}
+		*entry = transformed
+	}
+	for _, filter := range logs.cfg.Filters {
+		if !filter(*entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// advance moves the stream to the following line, regardless of the filters.
+func (s *stream) advance() bool {
+	if s.err != nil {
+		return false
+	}
+	if err := s.ctx.Err(); err != nil {
+		s.err = err
+		return false
+	}
+
+	switch {
+	case !s.started && s.logs.cfg.Merge:
+		s.started = true
+		for s.remaining() > 0 {
+			if err := s.openNext(); err != nil {
+				s.err = err
+				return false
+			}
+		}
+		heap.Init(&s.cursors)
+	case !s.started:
+		s.started = true
+	case len(s.cursors) > 0:
+		c := s.cursors[0]
+		if err := s.open.use(s.logs, c); err != nil {
+			s.err = err
+			return false
+		}
+		ok, err := c.next()
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if ok {
+			heap.Fix(&s.cursors, 0)
+		} else {
+			heap.Pop(&s.cursors)
+			s.open.closed(c)
+			c.close()
+		}
+	}
+
+	// when reading the files one after another, there's at most one cursor at a time
+	for len(s.cursors) == 0 && s.remaining() > 0 {
+		if err := s.openNext(); err != nil {
+			s.err = err
+			return false
+		}
+	}
+
+	if len(s.cursors) == 0 {
+		s.progress.finish()
+		return false
+	}
+	return true
+}
+
+// entry returns the current log entry of the stream.
+func (s *stream) entry() LogEntry {
+	return s.cursors[0].entry
+}
+
+// close closes all the files still opened by the stream.
+func (s *stream) close() {
+	for _, c := range s.cursors {
+		c.close()
+	}
+	for _, c := range s.ahead {
+		c.close()
+	}
+	// the files read ahead are closed by their goroutines
+	s.readers.Wait()
+	s.reportDuplicates()
+	if s.span != nil {
+		s.span.End(s.err, "files", s.opened)
+		s.span = nil
+	}
+	s.cursors = nil
+	s.ahead = nil
+	s.files = nil
+}
+
+// reportDuplicates reports the number of log lines of every file duplicating the lines of another one, if any,
+// then forgets them, the stream possibly being closed more than once.
+func (s *stream) reportDuplicates() {
+	if s.dedup == nil || s.logs.cfg.Logger == nil {
+		return
+	}
+	pairs := make([][2]string, 0, len(s.dedup.counts))
+	for pair := range s.dedup.counts {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0] < pairs[j][0] || pairs[i][0] == pairs[j][0] && pairs[i][1] < pairs[j][1]
+	})
+	for _, pair := range pairs {
+		s.logs.cfg.Logger.Info("duplicate lines", "file", pair[0], "of", pair[1], "lines", s.dedup.counts[pair])
+		delete(s.dedup.counts, pair)
+	}
+}
+
+// remaining returns the number of files left to be opened, read ahead or not.
+func (s *stream) remaining() int {
+	return len(s.files) + len(s.ahead)
+}
+
+// openNext opens the next file of the stream, positioning a new cursor on its first line
+// that happened within the last N minutes. Files without such lines are closed right away.
+func (s *stream) openNext() error {
+	if s.workers != nil {
+		return s.openNextAhead()
+	}
+	fi := s.files[0]
+	s.files = s.files[1:]
+	order := s.opened
+	s.opened++
+
+	file, err := s.logs.open(fi.path)
+	if errors.Is(err, errVanished) {
+		s.progress.file(fi).done()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c, err := s.logs.cursor(s.ctx, file, order, s.progress.file(fi))
+	if err != nil || c == nil {
+		_ = file.Close()
+		return err
+	}
+	c.tie = s.tie(fi, order)
+
+	heap.Push(&s.cursors, c)
+	s.open.opened(c)
+	return nil
+}
+
+// tie returns what orders the logs with the same time of the cursor over a given file at a given position
+// among the streamed files: the rank of its path when told so (see LogsConfig.DeterministicOrder), its position otherwise.
+func (s *stream) tie(fi logFile, order int) int {
+	if rank, ok := s.ranks[fi.path]; ok {
+		return rank
+	}
+	return order
+}
+
+// pathRanks returns the positions of the paths of given files once sorted.
+func pathRanks(files []logFile) map[string]int {
+	paths := make([]string, len(files))
+	for i, fi := range files {
+		paths[i] = fi.path
+	}
+	sort.Strings(paths)
+	ranks := make(map[string]int, len(paths))
+	for i, p := range paths {
+		ranks[p] = i
+	}
+	return ranks
+}
+
+// errVanished is the error of Logs.open for the log files removed since listed, which are skipped (see LogsConfig.SkipVanished).
+var errVanished = errors.New("log file vanished")
+
+// open opens the log file of a given path the way reopen does, counting it as scanned (see LogsConfig.Metrics).
+// A file removed since listed fails with errVanished when skipped (see LogsConfig.SkipVanished), once reported and counted.
+func (logs *Logs) open(path string) (File, error) {
+	f, err := logs.reopen(path)
+	if err != nil {
+		if logs.cfg.SkipVanished && errors.Is(err, fs.ErrNotExist) {
+			logs.info("file vanished", "path", path, "error", err)
+			logs.cfg.Metrics.vanished()
+			return File{}, errVanished
+		}
+		return File{}, err
+	}
+	logs.cfg.Metrics.scanned()
+	return f, nil
+}
+
+// reopen opens the log file of a given path, memory mapped when the logs are (see LogsConfig.MemoryMap, the files of LogsConfig.FS never are)
+// and read at the limited rate and within the budget of the logs, if any (see LogsConfig.MaxReadRate and LogsConfig.MaxBytesRead), its log times being shifted
+// by the offset of the clock of its host, if any (see Host.Offset), and identified when checkpointing (see LogsConfig.Checkpoints).
+func (logs *Logs) reopen(path string) (File, error) {
+	file, err := logs.cfg.fileSystem().Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	osFile, isOSFile := file.(*os.File)
+	var f File
+	if logs.cfg.MemoryMap && isOSFile {
+		f = MapFile(osFile, logs.cfg.format(path))
+	} else {
+		f = NewFormatFile(file, logs.cfg.format(path))
+	}
+	f.limiter = logs.limiter
+	f.budget = logs.budget
+	f.metrics = logs.cfg.Metrics
+	f.skew, f.loc = logs.skew(path), logs.cfg.TimeZone
+	if logs.cfg.Checkpoints != nil {
+		info, err := file.Stat()
+		if err != nil {
+			_ = f.Close()
+			return File{}, err
+		}
+		f.id = fileIDOf(osFile, info)
+		logs.cfg.Checkpoints.opened(f.id, info.Size())
+	}
+	return f, nil
+}
+
+// cursor creates a cursor over the lines of a file that happened within the last N minutes,
+// returning a nil cursor when there are no such lines. Only the first file of the stream
+// is searched when its times cannot be read, unless the files are interleaved (see Logs.offset).
+// The bytes of the file read or skipped are counted by a given progress, if any (see LogsConfig.Progress).
+func (logs *Logs) cursor(ctx context.Context, file File, order int, progress *fileProgress) (*cursor, error) {
+	offset, err := logs.offset(ctx, file, order == 0 || logs.cfg.Merge)
+	if err != nil || offset < 0 {
+		progress.done()
+		return nil, err
+	}
+	start, err := logs.rewind(file, offset)
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	ctx, span := logs.startSpan(ctx, SpanRead)
+
+	// the cursor reads through its own section of the file, so the cursors never share the internal file cursor
+	c := &cursor{
+		file:       file,
+		reader:     getReaderSize(contextReader{ctx: ctx, r: io.NewSectionReader(file, start, math.MaxInt64-start)}, logs.cfg.readBufferSize(size)),
+		order:      order,
+		offset:     start,
+		filtered:   offset - start,
+		lookupTime: logs.nowMinusT(),
+		boundary:   offset + boundaryScanSize,
+		readCtx:    ctx,
+		logger:     logs.cfg.Logger,
+		metrics:    logs.cfg.Metrics,
+		errors:     logs.cfg.Errors,
+		started:    time.Now(),
+		progress:   progress,
+		span:       span,
+	}
+	// the bytes before the cursor are skipped
+	progress.add(start)
+	if c.skips, err = logs.skippable(ctx, file); err != nil {
+		c.release()
+		return nil, err
+	}
+	ok, err := c.next()
+	if err != nil || !ok {
+		c.release()
+		return nil, err
+	}
+
+	return c, nil
+}
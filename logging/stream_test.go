@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const streamDataDir = "test/stream"
+
+type streamSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *streamSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	// two virtual hosts writing at the same time into their own files
+	writeLogFile(s.T(), filepath.Join(streamDataDir, "site-a.log"), `10.0.0.1 - frank [03/Mar/2022:02:40:00 +0000] "GET /a HTTP/1.0" 200 123
+10.0.0.1 - frank [03/Mar/2022:02:43:10 +0000] "GET /a HTTP/1.0" 200 123
+10.0.0.1 - frank [03/Mar/2022:02:44:00 +0000] "GET /a HTTP/1.0" 200 123
+10.0.0.1 - frank [03/Mar/2022:02:44:40 +0000] "GET /a HTTP/1.0" 200 123
+`, t)
+	writeLogFile(s.T(), filepath.Join(streamDataDir, "site-b.log"), `10.0.0.2 - frank [03/Mar/2022:02:41:00 +0000] "GET /b HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:43:30 +0000] "GET /b HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:44:00 +0000] "GET /b HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.0" 200 123`, t.Add(time.Second))
+}
+
+func (s *streamSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(streamDataDir)))
+}
+
+func (s *streamSuite) Test_Print_Merge() {
+	tests := []struct {
+		name         string
+		merge        bool
+		expectedLogs string
+	}{
+		{
+			name:  "Sequential",
+			merge: false,
+			expectedLogs: `10.0.0.1 - frank [03/Mar/2022:02:43:10 +0000] "GET /a HTTP/1.0" 200 123
+10.0.0.1 - frank [03/Mar/2022:02:44:00 +0000] "GET /a HTTP/1.0" 200 123
+10.0.0.1 - frank [03/Mar/2022:02:44:40 +0000] "GET /a HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:43:30 +0000] "GET /b HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:44:00 +0000] "GET /b HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.0" 200 123
+`,
+		},
+		{
+			name:  "Merged",
+			merge: true,
+			expectedLogs: `10.0.0.1 - frank [03/Mar/2022:02:43:10 +0000] "GET /a HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:43:30 +0000] "GET /b HTTP/1.0" 200 123
+10.0.0.1 - frank [03/Mar/2022:02:44:00 +0000] "GET /a HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:44:00 +0000] "GET /b HTTP/1.0" 200 123
+10.0.0.2 - frank [03/Mar/2022:02:44:20 +0000] "GET /b HTTP/1.0" 200 123
+10.0.0.1 - frank [03/Mar/2022:02:44:40 +0000] "GET /a HTTP/1.0" 200 123
+`,
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			buf := &bytes.Buffer{}
+			cfg := LogsConfig{
+				Directory:    streamDataDir,
+				LastNMinutes: 2,
+				Merge:        test.merge,
+			}
+			logs, err := NewLogs(cfg)
+			s.Require().NoError(err)
+			logs.nowMinusT = func() time.Time {
+				return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+			}
+
+			err = logs.Print(context.Background(), buf)
+
+			s.NoError(err)
+			s.Equal(test.expectedLogs, buf.String())
+		})
+	}
+}
+
+func (s *streamSuite) Test_stream_ClosesFiles() {
+	cfg := LogsConfig{
+		Directory:    streamDataDir,
+		LastNMinutes: 2,
+		Merge:        true,
+	}
+	logs, err := NewLogs(cfg)
+	s.Require().NoError(err)
+	logs.nowMinusT = func() time.Time {
+		return s.testTime.Add(-time.Duration(cfg.LastNMinutes) * time.Minute)
+	}
+	stream := logs.stream(context.Background())
+	s.Require().True(stream.next())
+	s.Len(stream.cursors, 2)
+	files := []File{stream.cursors[0].file, stream.cursors[1].file}
+
+	stream.close()
+
+	s.False(stream.next())
+	for _, file := range files {
+		_, err := file.Stat()
+		s.ErrorIs(err, os.ErrClosed)
+	}
+}
+
+func TestStream(t *testing.T) {
+	suite.Run(t, new(streamSuite))
+}
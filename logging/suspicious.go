@@ -0,0 +1,185 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The reasons why a client is considered suspicious.
+const (
+	// ReasonPathTraversal flags the requests trying to escape the document root, e.g. /../../etc/passwd.
+	ReasonPathTraversal = "path-traversal"
+	// ReasonSQLInjection flags the requests carrying SQL, e.g. ?id=1' OR '1'='1.
+	ReasonSQLInjection = "sql-injection"
+	// ReasonProbe flags the requests of paths commonly probed for vulnerabilities, e.g. /wp-login.php or /.env.
+	ReasonProbe = "probe"
+	// ReasonScanner flags the requests of well known vulnerability scanners, recognized by their user agents.
+	ReasonScanner = "scanner"
+	// ReasonNotFoundBurst flags the requests answered with 404 Not Found within a burst, see NewSuspicious.
+	ReasonNotFoundBurst = "404-burst"
+)
+
+// suspiciousRule flags the requests which (lower case and unescaped) path or user agent contains any of its patterns.
+type suspiciousRule struct {
+	reason        string
+	paths, agents []string
+}
+
+var suspiciousRules = []suspiciousRule{
+	{
+		reason: ReasonPathTraversal,
+		paths:  []string{"../", "..\\", "%2e%2e", "/etc/passwd", "/proc/self/", "c:\\windows"},
+	},
+	{
+		reason: ReasonSQLInjection,
+		paths:  []string{"union select", "union all select", "' or ", "\" or ", "or 1=1", "sleep(", "benchmark(", "information_schema", "xp_cmdshell"},
+		agents: []string{"sqlmap"},
+	},
+	{
+		reason: ReasonProbe,
+		paths: []string{"/wp-login.php", "/wp-admin", "/xmlrpc.php", "/.env", "/.git/", "/.aws/", "/phpmyadmin",
+			"/cgi-bin/", "/vendor/phpunit", "/boaform", "/actuator/", "/server-status", "/config.php", "/shell"},
+	},
+	{
+		reason: ReasonScanner,
+		agents: []string{"nikto", "nmap", "masscan", "zgrab", "wpscan", "nuclei", "dirbuster", "gobuster", "acunetix", "nessus"},
+	},
+}
+
+func (rule suspiciousRule) match(path, agent string) bool {
+	for _, pattern := range rule.paths {
+		if strings.Contains(path, pattern) {
+			return true
+		}
+	}
+	for _, pattern := range rule.agents {
+		if strings.Contains(agent, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Suspicious flags the requests logged within the last N minutes that look like attacks or vulnerability scans
+// using a few heuristics (see the Reason constants), in order to rank the suspicious clients by IP.
+type Suspicious struct {
+	burst       int
+	burstWindow time.Duration
+	// notFound holds the times of the latest requests answered with 404 Not Found by IP, within the burst window
+	notFound map[string][]time.Time
+	clients  map[string]map[string]int64
+}
+
+// SuspiciousClient is a client which requests have been flagged, along with the number of requests flagged by reason.
+type SuspiciousClient struct {
+	IP string `json:"ip"`
+	// Score is the number of requests flagged, counting the requests flagged for multiple reasons once per reason.
+	Score   int64   `json:"score"`
+	Reasons []Count `json:"reasons"`
+}
+
+// NewSuspicious creates an empty Suspicious, flagging the clients that got burst or more requests
+// answered with 404 Not Found within the burst window, e.g. 20 within a minute.
+func NewSuspicious(burst int, burstWindow time.Duration) *Suspicious {
+	return &Suspicious{
+		burst:       burst,
+		burstWindow: burstWindow,
+		notFound:    make(map[string][]time.Time),
+		clients:     make(map[string]map[string]int64),
+	}
+}
+
+// Suspicious reads the log entries that happened within the last N minutes and flags the suspicious requests,
+// see NewSuspicious. The clients are returned even along with an error (e.g. ErrNoFilesInWindow),
+// accounting for the entries read until then.
+func (logs *Logs) Suspicious(ctx context.Context, burst int, burstWindow time.Duration) (*Suspicious, error) {
+	suspicious := NewSuspicious(burst, burstWindow)
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		suspicious.Add(entry)
+		return nil
+	})
+
+	return suspicious, err
+}
+
+// Add flags a given log entry when suspicious, ignoring the ones that are not requests.
+func (suspicious *Suspicious) Add(entry LogEntry) {
+	if entry.IP == "" {
+		return
+	}
+
+	path := strings.ToLower(entry.Path)
+	if unescaped, err := url.QueryUnescape(path); err == nil {
+		path = unescaped
+	}
+	agent := strings.ToLower(entry.UserAgent)
+	for _, rule := range suspiciousRules {
+		if rule.match(path, agent) {
+			suspicious.flag(entry.IP, rule.reason, 1)
+		}
+	}
+
+	if entry.Status == http.StatusNotFound && suspicious.burst > 0 {
+		suspicious.addNotFound(entry)
+	}
+}
+
+// Result returns all the suspicious clients, see Ranked.
+func (suspicious *Suspicious) Result() interface{} {
+	return suspicious.Ranked(0)
+}
+
+// addNotFound keeps track of the requests answered with 404 Not Found of a client,
+// flagging all of them at once when they make a burst.
+func (suspicious *Suspicious) addNotFound(entry LogEntry) {
+	times := append(suspicious.notFound[entry.IP], entry.Time)
+	start := 0
+	for start < len(times) && entry.Time.Sub(times[start]) > suspicious.burstWindow {
+		start++
+	}
+	times = times[start:]
+
+	if len(times) >= suspicious.burst {
+		suspicious.flag(entry.IP, ReasonNotFoundBurst, int64(len(times)))
+		delete(suspicious.notFound, entry.IP)
+		return
+	}
+	suspicious.notFound[entry.IP] = times
+}
+
+func (suspicious *Suspicious) flag(ip, reason string, count int64) {
+	reasons, ok := suspicious.clients[ip]
+	if !ok {
+		reasons = make(map[string]int64)
+		suspicious.clients[ip] = reasons
+	}
+	reasons[reason] += count
+}
+
+// Ranked returns the n most suspicious clients, the highest score first, or all of them when n is not positive.
+// Ties are ordered by IP.
+func (suspicious *Suspicious) Ranked(n int) []SuspiciousClient {
+	clients := make([]SuspiciousClient, 0, len(suspicious.clients))
+	for ip, reasons := range suspicious.clients {
+		client := SuspiciousClient{IP: ip, Reasons: rank(reasons, 0)}
+		for _, count := range reasons {
+			client.Score += count
+		}
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].Score == clients[j].Score {
+			return clients[i].IP < clients[j].IP
+		}
+		return clients[i].Score > clients[j].Score
+	})
+	if n > 0 && n < len(clients) {
+		clients = clients[:n]
+	}
+
+	return clients
+}
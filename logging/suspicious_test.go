@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const suspiciousDataDir = "test/suspicious"
+
+type suspiciousSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *suspiciousSuite) SetupSuite() {
+	var logs strings.Builder
+	logs.WriteString(`10.0.0.1 - - [03/Mar/2022:02:44:00 +0000] "GET / HTTP/1.1" 200 20 "-" "Mozilla/5.0"
+10.0.0.2 - - [03/Mar/2022:02:44:01 +0000] "GET /static/..%2f..%2fetc/passwd HTTP/1.1" 400 20 "-" "Mozilla/5.0"
+10.0.0.3 - - [03/Mar/2022:02:44:02 +0000] "GET /products?id=1%27+OR+1=1-- HTTP/1.1" 200 20 "-" "sqlmap/1.6#stable"
+10.0.0.4 - - [03/Mar/2022:02:44:03 +0000] "GET /wp-login.php HTTP/1.1" 404 20 "-" "Mozilla/5.0"
+10.0.0.4 - - [03/Mar/2022:02:44:03 +0000] "GET /.env HTTP/1.1" 404 20 "-" "Mozilla/5.0"
+`)
+	// a burst of 404s from a single client
+	for i := 0; i < 5; i++ {
+		logs.WriteString(fmt.Sprintf(`10.0.0.5 - - [03/Mar/2022:02:44:%02d +0000] "GET /page-%d HTTP/1.1" 404 20 "-" "Mozilla/5.0"
+`, 10+i, i))
+	}
+	// the 404s of a client spread over time
+	for i := 0; i < 5; i++ {
+		logs.WriteString(fmt.Sprintf(`10.0.0.6 - - [03/Mar/2022:02:44:%02d +0000] "GET /old-%d HTTP/1.1" 404 20 "-" "Mozilla/5.0"
+`, 20+i*9, i))
+	}
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(suspiciousDataDir, "access.log"), logs.String(), t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(suspiciousDataDir), WithFormat(FormatCombined))
+}
+
+func (s *suspiciousSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(suspiciousDataDir)))
+}
+
+func (s *suspiciousSuite) Test_Suspicious() {
+	suspicious, err := s.logs.Suspicious(context.Background(), 5, 10*time.Second)
+	s.Require().NoError(err)
+
+	s.Equal([]SuspiciousClient{
+		{IP: "10.0.0.5", Score: 5, Reasons: []Count{{Value: ReasonNotFoundBurst, Count: 5}}},
+		{IP: "10.0.0.4", Score: 2, Reasons: []Count{{Value: ReasonProbe, Count: 2}}},
+		{IP: "10.0.0.2", Score: 1, Reasons: []Count{{Value: ReasonPathTraversal, Count: 1}}},
+		// the request is flagged once, although both its path and user agent look like an SQL injection
+		{IP: "10.0.0.3", Score: 1, Reasons: []Count{{Value: ReasonSQLInjection, Count: 1}}},
+	}, suspicious.Ranked(0))
+	s.Len(suspicious.Ranked(1), 1)
+}
+
+func (s *suspiciousSuite) Test_Suspicious_NoBursts() {
+	suspicious := NewSuspicious(0, time.Minute)
+
+	for i := 0; i < 100; i++ {
+		suspicious.Add(LogEntry{IP: "10.0.0.1", Path: "/missing", Status: 404})
+	}
+
+	s.Empty(suspicious.Ranked(10))
+}
+
+func TestSuspicious(t *testing.T) {
+	suite.Run(t, new(suspiciousSuite))
+}
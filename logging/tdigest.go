@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"math"
+	"sort"
+)
+
+// tdigestCompression bounds the number of centroids kept by a tdigest (roughly 2x the compression),
+// trading memory for accuracy. 100 keeps the rank error of the estimated quantiles well under 1%,
+// and even smaller towards the extreme quantiles (e.g. p99).
+const tdigestCompression = 100
+
+// centroid is a cluster of values summarized by their mean and their number (weight).
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// tdigest is a merging t-digest (Dunning & Ertl), estimating the quantiles of a stream of values
+// using constant memory. The values are buffered and merged into centroids, which are kept
+// small near the extreme quantiles and larger around the median, so the tails stay accurate.
+type tdigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min, max    float64
+}
+
+func newTDigest(compression float64) *tdigest {
+	return &tdigest{
+		compression: compression,
+		buffer:      make([]centroid, 0, 5*int(compression)),
+	}
+}
+
+// add adds a value to the digest.
+func (td *tdigest) add(x float64) {
+	if td.count == 0 || x < td.min {
+		td.min = x
+	}
+	if td.count == 0 || x > td.max {
+		td.max = x
+	}
+	td.count++
+	td.buffer = append(td.buffer, centroid{mean: x, weight: 1})
+	if len(td.buffer) == cap(td.buffer) {
+		td.merge()
+	}
+}
+
+// merge merges the buffered values into the centroids.
+func (td *tdigest) merge() {
+	if len(td.buffer) == 0 {
+		return
+	}
+
+	all := make([]centroid, 0, len(td.centroids)+len(td.buffer))
+	all = append(append(all, td.centroids...), td.buffer...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+
+	merged := make([]centroid, 0, len(td.centroids))
+	current, before := all[0], 0.0
+	for _, c := range all[1:] {
+		// centroids are merged as long as they span at most 1 unit of the scale function
+		if td.scale((before+current.weight+c.weight)/td.count)-td.scale(before/td.count) <= 1 {
+			current.weight += c.weight
+			current.mean += (c.mean - current.mean) * c.weight / current.weight
+			continue
+		}
+		merged = append(merged, current)
+		before += current.weight
+		current = c
+	}
+	td.centroids = append(merged, current)
+	td.buffer = td.buffer[:0]
+}
+
+// scale is the k1 scale function of the t-digest, mapping a quantile to the index of its centroid.
+func (td *tdigest) scale(q float64) float64 {
+	return td.compression / (2 * math.Pi) * math.Asin(2*math.Min(q, 1)-1)
+}
+
+// quantile estimates the value of the given quantile (between 0 and 1), 0 when the digest is empty.
+// The values are interpolated between the centers of the centroids, and the extremes are exact.
+func (td *tdigest) quantile(q float64) float64 {
+	td.merge()
+	if len(td.centroids) == 0 {
+		return 0
+	}
+	if len(td.centroids) == 1 {
+		return td.centroids[0].mean
+	}
+
+	index := q * td.count
+	if index < 1 {
+		return td.min
+	}
+	if index > td.count-1 {
+		return td.max
+	}
+
+	before := 0.0
+	for i, c := range td.centroids {
+		center := before + c.weight/2
+		if index < center {
+			if i == 0 {
+				return td.min + (c.mean-td.min)*index/center
+			}
+			prev := td.centroids[i-1]
+			prevCenter := before - prev.weight/2
+			return prev.mean + (c.mean-prev.mean)*(index-prevCenter)/(center-prevCenter)
+		}
+		before += c.weight
+	}
+
+	last := td.centroids[len(td.centroids)-1]
+	lastCenter := td.count - last.weight/2
+	return last.mean + (td.max-last.mean)*(index-lastCenter)/(td.count-lastCenter)
+}
+
+// addDigest adds the values of another digest to the digest, as its centroids, e.g. to estimate the quantiles
+// of the values of several digests together.
+func (td *tdigest) addDigest(other *tdigest) {
+	other.merge()
+	if other.count == 0 {
+		return
+	}
+	if td.count == 0 || other.min < td.min {
+		td.min = other.min
+	}
+	if td.count == 0 || other.max > td.max {
+		td.max = other.max
+	}
+	for _, c := range other.centroids {
+		td.count += c.weight
+		td.buffer = append(td.buffer, c)
+		if len(td.buffer) == cap(td.buffer) {
+			td.merge()
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TimeSeries counts the requests logged within the last N minutes by fixed intervals of time.
+// Only the log lines that could be parsed as requests are taken into account.
+type TimeSeries struct {
+	Start    time.Time
+	Interval time.Duration
+	// Buckets holds one bucket per interval, from Start onwards, including the empty ones.
+	Buckets []Bucket
+}
+
+// Bucket is the number of requests logged within an interval of time.
+type Bucket struct {
+	// Time is the start of the interval.
+	Time     time.Time `json:"time"`
+	Requests int       `json:"requests"`
+	// Errors is the number of requests answered with a server error (5xx).
+	Errors int `json:"errors"`
+}
+
+// NewTimeSeries creates an empty TimeSeries covering the time range between start and end,
+// using buckets of the given interval, aligned on multiples of the interval (e.g. whole minutes).
+// The interval has to be positive.
+func NewTimeSeries(start, end time.Time, interval time.Duration) *TimeSeries {
+	ts := &TimeSeries{
+		Start:    start.Truncate(interval),
+		Interval: interval,
+	}
+	for t := ts.Start; t.Before(end); t = t.Add(interval) {
+		ts.Buckets = append(ts.Buckets, Bucket{Time: t})
+	}
+	return ts
+}
+
+// TimeSeries reads the log entries that happened within the last N minutes and counts them by the given interval.
+// The time series is returned even along with an error (e.g. ErrNoFilesInWindow), counting the entries read until then.
+func (logs *Logs) TimeSeries(ctx context.Context, interval time.Duration) (*TimeSeries, error) {
+	if interval <= 0 {
+		return nil, errors.New("time series interval must be positive")
+	}
+	start := logs.nowMinusT()
+	ts := NewTimeSeries(start, start.Add(logs.cfg.window()), interval)
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		ts.Add(entry)
+		return nil
+	})
+
+	return ts, err
+}
+
+// Add counts a given log entry in the bucket of its time, ignoring the ones that are not requests
+// or that happened outside the time range of the time series (e.g. logs written in the future).
+func (ts *TimeSeries) Add(entry LogEntry) {
+	if entry.IP == "" || entry.Time.Before(ts.Start) {
+		return
+	}
+	idx := int(entry.Time.Sub(ts.Start) / ts.Interval)
+	if idx >= len(ts.Buckets) {
+		return
+	}
+
+	ts.Buckets[idx].Requests++
+	if entry.serverError() {
+		ts.Buckets[idx].Errors++
+	}
+}
+
+// Result returns the TimeSeries itself.
+func (ts *TimeSeries) Result() interface{} {
+	return ts
+}
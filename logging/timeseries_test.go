@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const timeSeriesDataDir = "test/timeseries"
+
+type timeSeriesSuite struct {
+	suite.Suite
+	logs     *Logs
+	testTime time.Time
+}
+
+func (s *timeSeriesSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(timeSeriesDataDir, "http.log"), `10.0.0.1 - frank [03/Mar/2022:02:42:10 +0000] "GET /a HTTP/1.0" 200 20
+10.0.0.2 - frank [03/Mar/2022:02:42:20 +0000] "GET /a HTTP/1.0" 500 20
+this line cannot be parsed
+10.0.0.1 - frank [03/Mar/2022:02:44:30 +0000] "POST /b HTTP/1.1" 502 30
+10.0.0.3 - frank [03/Mar/2022:02:44:40 +0000] "GET /c HTTP/1.1" 404 10
+10.0.0.1 - frank [03/Mar/2022:02:44:50 +0000] "GET /a HTTP/1.0" 200 20
+`, t)
+	s.logs = newTestLogs(s.T(), t, 3*time.Minute, WithDirectory(timeSeriesDataDir))
+	s.testTime = t
+}
+
+func (s *timeSeriesSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(timeSeriesDataDir)))
+}
+
+func (s *timeSeriesSuite) Test_TimeSeries() {
+	ts, err := s.logs.TimeSeries(context.Background(), time.Minute)
+
+	s.Require().NoError(err)
+	start := s.testTime.Add(-3 * time.Minute)
+	s.True(ts.Start.Equal(start))
+	s.Equal([]Bucket{
+		{Time: start, Requests: 2, Errors: 1},
+		{Time: start.Add(time.Minute), Requests: 0, Errors: 0},
+		{Time: start.Add(2 * time.Minute), Requests: 3, Errors: 1},
+	}, ts.Buckets)
+}
+
+func (s *timeSeriesSuite) Test_TimeSeries_InvalidInterval() {
+	_, err := s.logs.TimeSeries(context.Background(), 0)
+
+	s.EqualError(err, "time series interval must be positive")
+}
+
+func (s *timeSeriesSuite) Test_Add() {
+	start := time.Date(2022, time.March, 3, 2, 42, 30, 0, time.UTC)
+	ts := NewTimeSeries(start, start.Add(2*time.Minute), time.Minute)
+
+	ts.Add(LogEntry{IP: "10.0.0.1", Time: start.Add(-time.Minute), Status: 200})
+	ts.Add(LogEntry{IP: "10.0.0.1", Time: start.Add(-10 * time.Second), Status: 200})
+	ts.Add(LogEntry{Time: start.Add(time.Minute)})
+	ts.Add(LogEntry{IP: "10.0.0.1", Time: start.Add(time.Minute), Status: 503})
+	ts.Add(LogEntry{IP: "10.0.0.1", Time: start.Add(time.Hour), Status: 200})
+
+	// the buckets are aligned on whole minutes
+	s.Equal([]Bucket{
+		{Time: start.Truncate(time.Minute), Requests: 1},
+		{Time: start.Truncate(time.Minute).Add(time.Minute), Requests: 1, Errors: 1},
+		{Time: start.Truncate(time.Minute).Add(2 * time.Minute)},
+	}, ts.Buckets)
+}
+
+func (s *timeSeriesSuite) Test_Anomalies() {
+	start := time.Date(2022, time.March, 3, 2, 0, 0, 0, time.UTC)
+	ts := NewTimeSeries(start, start.Add(8*time.Minute), time.Minute)
+	for i, requests := range []int{100, 102, 98, 101, 99, 300, 100, 100} {
+		ts.Buckets[i].Requests = requests
+		ts.Buckets[i].Errors = 1
+	}
+	ts.Buckets[7].Errors = 40
+
+	anomalies := ts.Anomalies(3, 10)
+
+	s.Require().Len(anomalies, 2)
+	s.Equal(start.Add(5*time.Minute), anomalies[0].Time)
+	s.Equal(MetricRequests, anomalies[0].Metric)
+	s.Equal(300.0, anomalies[0].Value)
+	s.Equal(100.0, anomalies[0].Mean)
+	// the standard deviation of the steady traffic is below the one expected from random arrivals
+	s.Equal(10.0, anomalies[0].StdDev)
+	s.Equal(20.0, anomalies[0].Sigma)
+	s.Equal(start.Add(7*time.Minute), anomalies[1].Time)
+	s.Equal(MetricErrorRate, anomalies[1].Metric)
+	s.Equal(0.4, anomalies[1].Value)
+}
+
+func (s *timeSeriesSuite) Test_Anomalies_NotEnoughHistory() {
+	start := time.Date(2022, time.March, 3, 2, 0, 0, 0, time.UTC)
+	ts := NewTimeSeries(start, start.Add(3*time.Minute), time.Minute)
+	ts.Buckets[2].Requests = 1000
+
+	s.Empty(ts.Anomalies(3, 10))
+}
+
+func TestTimeSeries(t *testing.T) {
+	suite.Run(t, new(timeSeriesSuite))
+}
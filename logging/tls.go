@@ -0,0 +1,27 @@
+package logging
+
+import "strings"
+
+// weakTLSProtocols are the deprecated protocol versions (RFC 8996), and weakTLSCiphers the parts of the names
+// of the cipher suites without forward secrecy, authentication or a sound cipher or hash, in OpenSSL's naming.
+var (
+	weakTLSProtocols = []string{"SSLv2", "SSLv3", "TLSv1", "TLSv1.1"}
+	weakTLSCiphers   = []string{"RC4", "DES", "NULL", "EXPORT", "EXP-", "MD5", "ADH", "AECDH", "anon"}
+)
+
+// WeakTLS is a Filter keeping the log entries of the requests negotiating a deprecated protocol version (SSLv2 up to TLSv1.1)
+// or a weak cipher suite (e.g. RC4, 3DES or anonymous ones), e.g. to audit the clients still relying on them
+// before disabling them. Only the log entries of the FormatSSLRequest format have the TLS fields to check.
+func WeakTLS(entry LogEntry) bool {
+	for _, protocol := range weakTLSProtocols {
+		if strings.EqualFold(entry.TLSProtocol, protocol) {
+			return true
+		}
+	}
+	for _, cipher := range weakTLSCiphers {
+		if strings.Contains(entry.TLSCipher, cipher) {
+			return true
+		}
+	}
+	return false
+}
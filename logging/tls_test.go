@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const tlsDataDir = "test/tls"
+
+type tlsSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *tlsSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(tlsDataDir, "ssl_request.log"), `[03/Mar/2022:02:44:10 +0000] 10.0.0.1 TLSv1.3 TLS_AES_256_GCM_SHA384 "GET /a HTTP/1.1" 20
+[03/Mar/2022:02:44:20 +0000] 10.0.0.2 TLSv1 ECDHE-RSA-AES128-SHA "GET /a HTTP/1.1" 20
+[03/Mar/2022:02:44:30 +0000] 10.0.0.3 TLSv1.2 DES-CBC3-SHA "GET /b HTTP/1.1" 10
+[03/Mar/2022:02:44:40 +0000] 10.0.0.1 TLSv1.3 TLS_AES_256_GCM_SHA384 "GET /b HTTP/1.1" -
+this line cannot be parsed
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(tlsDataDir), WithFormat(FormatSSLRequest))
+}
+
+func (s *tlsSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(tlsDataDir)))
+}
+
+func (s *tlsSuite) Test_TopTLS() {
+	top, err := s.logs.Top(context.Background(), TopTLSProtocols, TopTLSCiphers)
+	s.Require().NoError(err)
+
+	s.Equal([]Count{{Value: "TLSv1.3", Count: 2}, {Value: "TLSv1", Count: 1}, {Value: "TLSv1.2", Count: 1}}, top.Ranked(TopTLSProtocols, 0))
+	s.Equal([]Count{{Value: "TLS_AES_256_GCM_SHA384", Count: 2}}, top.Ranked(TopTLSCiphers, 1))
+}
+
+func (s *tlsSuite) Test_WeakTLS() {
+	var ips []string
+	s.Require().NoError(s.logs.ForEach(context.Background(), func(entry LogEntry) error {
+		if WeakTLS(entry) {
+			ips = append(ips, entry.IP)
+		}
+		return nil
+	}))
+	s.Equal([]string{"10.0.0.2", "10.0.0.3"}, ips)
+
+	tests := []struct {
+		protocol, cipher string
+		weak             bool
+	}{
+		{protocol: "TLSv1.3", cipher: "TLS_AES_128_GCM_SHA256"},
+		{protocol: "TLSv1.2", cipher: "ECDHE-ECDSA-CHACHA20-POLY1305"},
+		{protocol: "SSLv3", cipher: "AES256-SHA", weak: true},
+		{protocol: "TLSv1.1", cipher: "AES256-SHA", weak: true},
+		{protocol: "TLSv1.2", cipher: "RC4-SHA", weak: true},
+		{protocol: "TLSv1.2", cipher: "EXP-RC2-CBC-MD5", weak: true},
+		{protocol: "TLSv1.2", cipher: "ADH-AES256-GCM-SHA384", weak: true},
+		{protocol: "TLSv1.2", cipher: "NULL-SHA256", weak: true},
+		{protocol: "-", cipher: "-"},
+		{},
+	}
+	for _, test := range tests {
+		s.Equal(test.weak, WeakTLS(LogEntry{TLSProtocol: test.protocol, TLSCipher: test.cipher}), "%s %s", test.protocol, test.cipher)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	suite.Run(t, new(tlsSuite))
+}
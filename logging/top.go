@@ -0,0 +1,237 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TopField is a field of the log entries which values can be ranked by Top.
+type TopField string
+
+const (
+	// TopIPs ranks the client IPs.
+	TopIPs TopField = "ips"
+	// TopPaths ranks the requested paths.
+	TopPaths TopField = "paths"
+	// TopMethods ranks the request methods, e.g. GET.
+	TopMethods TopField = "methods"
+	// TopProtocols ranks the protocol versions of the requests, e.g. HTTP/1.1.
+	TopProtocols TopField = "protocols"
+	// TopUserAgents ranks the user agents, only known for the FormatCombined format.
+	TopUserAgents TopField = "agents"
+	// TopReferers ranks the referers, only known for the FormatCombined format.
+	TopReferers TopField = "referers"
+	// TopVHosts ranks the virtual hosts, only known for the FormatVHostCombined format.
+	TopVHosts TopField = "vhosts"
+	// TopBots ranks the bots, only known when classifying the log entries (see LogsConfig.Bots).
+	TopBots TopField = "bots"
+	// TopTLSProtocols ranks the TLS protocol versions, e.g. TLSv1.2, only known for the FormatSSLRequest format.
+	TopTLSProtocols TopField = "tls-protocols"
+	// TopTLSCiphers ranks the TLS cipher suites, only known for the FormatSSLRequest format.
+	TopTLSCiphers TopField = "tls-ciphers"
+	// TopHosts ranks the hosts, only known when reading several hosts (see LogsConfig.Hosts).
+	TopHosts TopField = "hosts"
+	// TopBytesByPath ranks the requested paths by the total size of their responses.
+	TopBytesByPath TopField = "bytes-by-path"
+	// TopBytesByIP ranks the client IPs by the total size of the responses they received.
+	TopBytesByIP TopField = "bytes-by-ip"
+)
+
+// topParamPrefix prefixes the names of the fields ranking the values of a parameter of the query strings, see TopParam.
+const topParamPrefix = "param:"
+
+// TopParam ranks the values of a given parameter of the query strings (see LogEntry.Param), e.g. utm_source,
+// its name being param:utm_source.
+func TopParam(name string) TopField {
+	return TopField(topParamPrefix + name)
+}
+
+// value returns the value of the field for a given log entry.
+func (field TopField) value(entry LogEntry) string {
+	switch field {
+	case TopIPs, TopBytesByIP:
+		return entry.IP
+	case TopPaths, TopBytesByPath:
+		return entry.Path
+	case TopMethods:
+		return entry.Method
+	case TopProtocols:
+		return entry.Protocol
+	case TopUserAgents:
+		return entry.UserAgent
+	case TopReferers:
+		return entry.Referer
+	case TopVHosts:
+		return entry.VHost
+	case TopBots:
+		return entry.Bot
+	case TopTLSProtocols:
+		return entry.TLSProtocol
+	case TopTLSCiphers:
+		return entry.TLSCipher
+	case TopHosts:
+		return entry.Host
+	default:
+		if strings.HasPrefix(string(field), topParamPrefix) {
+			return entry.Param(string(field[len(topParamPrefix):]))
+		}
+		return ""
+	}
+}
+
+// weight returns how much a given log entry adds to the count of its value.
+func (field TopField) weight(entry LogEntry) int64 {
+	if field.SumsBytes() {
+		return entry.Size
+	}
+	return 1
+}
+
+// SumsBytes checks whether the field ranks its values by the total size of the responses
+// instead of by the number of log entries.
+func (field TopField) SumsBytes() bool {
+	return field == TopBytesByPath || field == TopBytesByIP
+}
+
+// ParseTopField parses the name of a TopField, e.g. "ips" or "param:utm_source".
+func ParseTopField(name string) (TopField, error) {
+	switch field := TopField(name); field {
+	case TopIPs, TopPaths, TopMethods, TopProtocols, TopUserAgents, TopReferers, TopVHosts, TopBots, TopTLSProtocols, TopTLSCiphers,
+		TopHosts, TopBytesByPath, TopBytesByIP:
+		return field, nil
+	default:
+		if strings.HasPrefix(name, topParamPrefix) && len(name) > len(topParamPrefix) {
+			return field, nil
+		}
+		return "", fmt.Errorf("unsupported top field '%s'", name)
+	}
+}
+
+// Count is the number of log entries sharing the same value of a field,
+// or the total size of their responses for the fields summing bytes (see TopField.SumsBytes).
+type Count struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Top counts the log entries by the values of some of their fields, in order to rank them.
+// Entries without a value for a field (e.g. lines that are not requests) are not counted for it.
+// Bounded by a maximum of values (see NewBoundedTop), it ranks the most frequent ones approximately within
+// constant memory, however many distinct values there are (e.g. the IPs of a month of logs).
+type Top struct {
+	counts map[TopField]map[string]int64
+	totals map[TopField]int64
+	// bounded are the counters of the fields counted within a maximum of values, if any (see NewBoundedTop)
+	bounded map[TopField]*spaceSaving
+}
+
+// NewTop creates an empty Top counting the values of the given fields.
+func NewTop(fields ...TopField) *Top {
+	top := &Top{
+		counts: make(map[TopField]map[string]int64, len(fields)),
+		totals: make(map[TopField]int64, len(fields)),
+	}
+	for _, field := range fields {
+		top.counts[field] = make(map[string]int64)
+	}
+	return top
+}
+
+// NewBoundedTop creates an empty Top counting at most a given number of values of every given field, or every value
+// when not positive (see NewTop). Once a field has that many values, a new value replaces the least frequent one,
+// inheriting its count (space-saving), so the counts are overestimated by MaxError at most, while any value more
+// frequent than that is ranked. Counting a few times more values than ranked keeps the ranks exact in practice.
+func NewBoundedTop(max int, fields ...TopField) *Top {
+	top := NewTop(fields...)
+	if max <= 0 {
+		return top
+	}
+	top.bounded = make(map[TopField]*spaceSaving, len(fields))
+	for _, field := range fields {
+		top.bounded[field] = newSpaceSaving(max)
+	}
+	return top
+}
+
+// Top reads the log entries that happened within the last N minutes and counts the values of the given fields,
+// at most the maximum of values of the configuration of every field, if any (see LogsConfig.MaxTopValues).
+// The counts are returned even along with an error (e.g. ErrNoFilesInWindow), counting the entries read until then.
+func (logs *Logs) Top(ctx context.Context, fields ...TopField) (*Top, error) {
+	top := NewBoundedTop(logs.cfg.MaxTopValues, fields...)
+	err := logs.ForEach(ctx, func(entry LogEntry) error {
+		top.Add(entry)
+		return nil
+	})
+
+	return top, err
+}
+
+// Add counts the values of the fields of a given log entry.
+func (top *Top) Add(entry LogEntry) {
+	for field, counts := range top.counts {
+		if value := field.value(entry); value != "" {
+			weight := field.weight(entry)
+			if bounded, ok := top.bounded[field]; ok {
+				bounded.add(value, weight)
+			} else {
+				counts[value] += weight
+			}
+			top.totals[field] += weight
+		}
+	}
+}
+
+// Result returns all the values of every counted field, ranked, see Ranked.
+func (top *Top) Result() interface{} {
+	result := make(map[TopField][]Count, len(top.counts))
+	for field := range top.counts {
+		result[field] = top.Ranked(field, 0)
+	}
+	return result
+}
+
+// Total returns the sum of the counts of all the values of a field,
+// e.g. the overall size of the responses for TopBytesByPath.
+func (top *Top) Total(field TopField) int64 {
+	return top.totals[field]
+}
+
+// Ranked returns the n most frequent values of a field, the most frequent first,
+// or all of them when n is not positive. Ties are ordered by value.
+func (top *Top) Ranked(field TopField, n int) []Count {
+	if bounded, ok := top.bounded[field]; ok {
+		return rank(bounded.counts(), n)
+	}
+	return rank(top.counts[field], n)
+}
+
+// MaxError returns how much the counts of the values of a field are overestimated at most, 0 when they are exact:
+// when bounded (see NewBoundedTop), the highest count a value inherited from the least frequent one it replaced.
+func (top *Top) MaxError(field TopField) int64 {
+	if bounded, ok := top.bounded[field]; ok {
+		return bounded.maxError()
+	}
+	return 0
+}
+
+// rank returns the n highest counts, the highest first, or all of them when n is not positive.
+// Ties are ordered by value.
+func rank(values map[string]int64, n int) []Count {
+	counts := make([]Count, 0, len(values))
+	for value, count := range values {
+		counts = append(counts, Count{Value: value, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Value < counts[j].Value
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	if n > 0 && n < len(counts) {
+		counts = counts[:n]
+	}
+
+	return counts
+}
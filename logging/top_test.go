@@ -0,0 +1,194 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const topDataDir = "test/top"
+
+type topSuite struct {
+	suite.Suite
+	logs *Logs
+}
+
+func (s *topSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	writeLogFile(s.T(), filepath.Join(topDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20 "-" "curl/7.79.1"
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "GET /a HTTP/1.1" 200 20 "https://example.com/" "Mozilla/5.0"
+10.0.0.1 - - [03/Mar/2022:02:44:30 +0000] "GET /b HTTP/1.1" 404 10 "https://example.com/" "curl/7.79.1"
+10.0.0.1 - - [03/Mar/2022:02:44:40 +0000] "GET /a HTTP/1.1" 200 20 "-" "curl/7.79.1"
+this line cannot be parsed
+`, t)
+	s.logs = newTestLogs(s.T(), t, time.Minute, WithDirectory(topDataDir), WithFormat(FormatCombined))
+}
+
+func (s *topSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(topDataDir)))
+}
+
+func (s *topSuite) Test_Top() {
+	top, err := s.logs.Top(context.Background(), TopIPs, TopPaths, TopUserAgents, TopReferers)
+	s.Require().NoError(err)
+
+	tests := []struct {
+		field    TopField
+		n        int
+		expected []Count
+	}{
+		{
+			field:    TopIPs,
+			n:        10,
+			expected: []Count{{Value: "10.0.0.1", Count: 3}, {Value: "10.0.0.2", Count: 1}},
+		},
+		{
+			field:    TopPaths,
+			n:        1,
+			expected: []Count{{Value: "/a", Count: 3}},
+		},
+		{
+			field:    TopUserAgents,
+			n:        0,
+			expected: []Count{{Value: "curl/7.79.1", Count: 3}, {Value: "Mozilla/5.0", Count: 1}},
+		},
+		{
+			field:    TopReferers,
+			n:        2,
+			expected: []Count{{Value: "-", Count: 2}, {Value: "https://example.com/", Count: 2}},
+		},
+	}
+	for _, test := range tests {
+		s.Run(string(test.field), func() {
+			s.Equal(test.expected, top.Ranked(test.field, test.n))
+		})
+	}
+}
+
+func (s *topSuite) Test_Top_Bytes() {
+	top, err := s.logs.Top(context.Background(), TopBytesByPath, TopBytesByIP)
+	s.Require().NoError(err)
+
+	s.Equal([]Count{{Value: "/a", Count: 60}, {Value: "/b", Count: 10}}, top.Ranked(TopBytesByPath, 0))
+	s.Equal([]Count{{Value: "10.0.0.1", Count: 50}}, top.Ranked(TopBytesByIP, 1))
+	s.Equal(int64(70), top.Total(TopBytesByPath))
+	s.Equal(int64(70), top.Total(TopBytesByIP))
+	s.True(TopBytesByPath.SumsBytes())
+	s.False(TopPaths.SumsBytes())
+}
+
+func (s *topSuite) Test_Top_MethodsAndProtocols() {
+	top := NewTop(TopMethods, TopProtocols)
+	top.Add(LogEntry{IP: "10.0.0.1", Method: "GET", Protocol: "HTTP/1.1"})
+	top.Add(LogEntry{IP: "10.0.0.1", Method: "GET", Protocol: "HTTP/2.0"})
+	top.Add(LogEntry{IP: "10.0.0.2", Method: "POST", Protocol: "HTTP/2.0"})
+	top.Add(LogEntry{IP: "10.0.0.3", Method: "PROPFIND", Protocol: "HTTP/1.0"})
+
+	s.Equal([]Count{{Value: "GET", Count: 2}, {Value: "POST", Count: 1}, {Value: "PROPFIND", Count: 1}}, top.Ranked(TopMethods, 0))
+	s.Equal([]Count{{Value: "HTTP/2.0", Count: 2}, {Value: "HTTP/1.0", Count: 1}, {Value: "HTTP/1.1", Count: 1}}, top.Ranked(TopProtocols, 0))
+}
+
+func (s *topSuite) Test_Top_VHosts() {
+	top := NewTop(TopVHosts)
+	top.Add(LogEntry{IP: "10.0.0.1", VHost: "shop.example.com"})
+	top.Add(LogEntry{IP: "10.0.0.2", VHost: "shop.example.com"})
+	top.Add(LogEntry{IP: "10.0.0.1", VHost: "blog.example.com"})
+	// without a virtual host, e.g. FormatCombined
+	top.Add(LogEntry{IP: "10.0.0.3"})
+
+	s.Equal([]Count{{Value: "shop.example.com", Count: 2}, {Value: "blog.example.com", Count: 1}}, top.Ranked(TopVHosts, 0))
+}
+
+func (s *topSuite) Test_Top_Params() {
+	top := NewTop(TopParam("utm_source"))
+	top.Add(LogEntry{Path: "/?utm_source=newsletter&utm_medium=email"})
+	top.Add(LogEntry{Path: "/pricing?utm_source=newsletter"})
+	top.Add(LogEntry{Path: "/?utm_source=twitter"})
+	// without the parameter
+	top.Add(LogEntry{Path: "/?utm_medium=email"})
+	top.Add(LogEntry{Path: "/"})
+
+	s.Equal([]Count{{Value: "newsletter", Count: 2}, {Value: "twitter", Count: 1}}, top.Ranked(TopParam("utm_source"), 0))
+}
+
+func (s *topSuite) Test_Top_UncountedField() {
+	top := NewTop(TopIPs)
+	top.Add(LogEntry{IP: "10.0.0.1", Path: "/a"})
+
+	s.Empty(top.Ranked(TopPaths, 10))
+}
+
+func (s *topSuite) Test_BoundedTop() {
+	top := NewBoundedTop(100, TopIPs, TopBytesByPath)
+	exact := NewTop(TopIPs)
+	// a few heavy hitters among thousands of IPs seen once, more frequent than the total over the maximum
+	for i := 0; i < 5000; i++ {
+		entries := []LogEntry{{IP: fmt.Sprintf("10.1.%d.%d", i/256, i%256), Path: "/rare", Size: 1}}
+		if i%10 == 0 {
+			entries = append(entries, LogEntry{IP: "10.0.0.1", Path: "/a", Size: 10})
+		}
+		if i%20 == 0 {
+			entries = append(entries, LogEntry{IP: "10.0.0.2", Path: "/b", Size: 10})
+		}
+		for _, entry := range entries {
+			top.Add(entry)
+			exact.Add(entry)
+		}
+	}
+
+	ranked := top.Ranked(TopIPs, 2)
+	s.Require().Len(ranked, 2)
+	s.Equal([]string{"10.0.0.1", "10.0.0.2"}, []string{ranked[0].Value, ranked[1].Value})
+	expected := exact.Ranked(TopIPs, 2)
+	maxErr := top.MaxError(TopIPs)
+	s.Positive(maxErr)
+	for i := range ranked {
+		s.GreaterOrEqual(ranked[i].Count, expected[i].Count)
+		s.LessOrEqual(ranked[i].Count, expected[i].Count+maxErr)
+	}
+	s.Len(top.Ranked(TopIPs, 0), 100)
+	s.Equal(exact.Total(TopIPs), top.Total(TopIPs))
+
+	// fewer values than the maximum are counted exactly
+	s.Equal([]Count{{Value: "/a", Count: 5000}, {Value: "/rare", Count: 5000}, {Value: "/b", Count: 2500}}, top.Ranked(TopBytesByPath, 0))
+	s.Zero(top.MaxError(TopBytesByPath))
+	s.Zero(exact.MaxError(TopIPs))
+}
+
+func (s *topSuite) Test_Top_MaxTopValues() {
+	logs, err := New(WithDirectory(topDataDir), WithWindow(time.Minute), WithFormat(FormatCombined), WithMaxTopValues(1))
+	s.Require().NoError(err)
+	logs.nowMinusT = s.logs.nowMinusT
+	top, err := logs.Top(context.Background(), TopIPs)
+	s.Require().NoError(err)
+	// the single value counted is the last one, inheriting the count of the ones it replaced
+	s.Equal([]Count{{Value: "10.0.0.1", Count: 4}}, top.Ranked(TopIPs, 10))
+	s.Equal(int64(2), top.MaxError(TopIPs))
+
+	_, err = New(WithDirectory(topDataDir), WithMaxTopValues(-1))
+	s.EqualError(err, "invalid max top values: must not be negative")
+}
+
+func (s *topSuite) Test_ParseTopField() {
+	field, err := ParseTopField("agents")
+	s.NoError(err)
+	s.Equal(TopUserAgents, field)
+
+	field, err = ParseTopField("param:utm_source")
+	s.NoError(err)
+	s.Equal(TopParam("utm_source"), field)
+
+	_, err = ParseTopField("cookies")
+	s.EqualError(err, "unsupported top field 'cookies'")
+	_, err = ParseTopField("param:")
+	s.EqualError(err, "unsupported top field 'param:'")
+}
+
+func TestTop(t *testing.T) {
+	suite.Run(t, new(topSuite))
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"context"
+)
+
+// The names of the spans of the phases traced by a Tracer (see LogsConfig.Tracer).
+const (
+	// SpanScan is the span of the listing of the log files of the directory (or the hosts).
+	SpanScan = "logging.scan"
+	// SpanIndex is the span of the lookup of the offset where the time range starts inside a log file,
+	// by its first and last times, its index or a binary search.
+	SpanIndex = "logging.index"
+	// SpanRead is the span of the reading of the lines of a log file, from its offset.
+	SpanRead = "logging.read"
+	// SpanStream is the span of a call streaming the log entries (Print, ForEach, Entries, ...), parent of the others.
+	SpanStream = "logging.stream"
+)
+
+// Tracer starts the spans of the phases of every call of Logs (see LogsConfig.Tracer): listing the log files,
+// looking up the offset of the time range inside every file and reading them, so the services embedding the package
+// see where the time is spent. Its spans are children of the span of the context given to the calls, if any,
+// so a Tracer is typically a small adapter of a tracer of OpenTelemetry, e.g.
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, logging.Span) {
+//		ctx, span := t.tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+// It must be safe for concurrent use when the files are read in parallel (see LogsConfig.Workers).
+type Tracer interface {
+	// Start starts a span of a given name (see SpanScan, ...) as a child of the span of a given context, if any,
+	// returning the context of the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a phase started by a Tracer.
+type Span interface {
+	// End ends the span along with the error the phase failed with, if any, and its attributes
+	// as alternating keys and values, e.g. End(nil, "file", "access.log", "offset", 1024) (see Logger).
+	End(err error, args ...interface{})
+}
+
+// noSpan is the Span of the logs without a Tracer.
+type noSpan struct{}
+
+func (noSpan) End(error, ...interface{}) {}
+
+// startSpan starts a span of a given name with the Tracer of the logs, if any.
+func (logs *Logs) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if logs.cfg.Tracer == nil {
+		return ctx, noSpan{}
+	}
+	return logs.cfg.Tracer.Start(ctx, name)
+}
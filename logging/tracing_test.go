@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const tracingDataDir = "test/tracing"
+
+type tracingSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *tracingSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:10:01:00 +0000")
+	s.testTime = t
+	writeLogFile(s.T(), filepath.Join(tracingDataDir, "access.log.1"), `10.0.0.1 - - [03/Mar/2022:09:00:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:09:59:50 +0000] "GET /b HTTP/1.1" 200 10
+`, t.Add(-time.Minute))
+	writeLogFile(s.T(), filepath.Join(tracingDataDir, "access.log"), `10.0.0.1 - - [03/Mar/2022:10:00:10 +0000] "GET /c HTTP/1.1" 200 10
+10.0.0.1 - - [03/Mar/2022:10:00:50 +0000] "GET /d HTTP/1.1" 200 10
+`, t)
+}
+
+func (s *tracingSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(tracingDataDir)))
+}
+
+// recordingTracer records the spans it starts, along with their parents and attributes.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name, parent string
+	ended        bool
+	err          error
+	attrs        map[string]interface{}
+}
+
+// spanKey is the key of the recorded span of a context.
+type spanKey struct{}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &recordedSpan{name: name}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), &tracerSpan{tracer: t, span: span}
+}
+
+type tracerSpan struct {
+	tracer *recordingTracer
+	span   *recordedSpan
+}
+
+func (s *tracerSpan) End(err error, args ...interface{}) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.span.ended, s.span.err = true, err
+	s.span.attrs = make(map[string]interface{})
+	for i := 0; i+1 < len(args); i += 2 {
+		s.span.attrs[args[i].(string)] = args[i+1]
+	}
+}
+
+// named returns the recorded spans of a given name.
+func (t *recordingTracer) named(name string) []*recordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var spans []*recordedSpan
+	for _, span := range t.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func (s *tracingSuite) Test_Tracer_Print() {
+	tracer := &recordingTracer{}
+	logs, err := New(WithDirectory(tracingDataDir), WithWindow(30*time.Second), WithEnd(s.testTime), WithTracer(tracer))
+	s.Require().NoError(err)
+	// the files are listed once created, not by every call unless refreshed
+	scans := tracer.named(SpanScan)
+	s.Require().Len(scans, 1)
+	s.Equal("", scans[0].parent)
+	s.True(scans[0].ended)
+	s.Equal(2, scans[0].attrs["files"])
+	s.Require().NoError(logs.Print(context.WithValue(context.Background(), spanKey{}, &recordedSpan{name: "request"}), io.Discard))
+
+	streams := tracer.named(SpanStream)
+	s.Require().Len(streams, 1)
+	s.Equal("request", streams[0].parent)
+	s.True(streams[0].ended)
+	s.NoError(streams[0].err)
+
+	// the older file is left out by its modified time, without being indexed
+	indexes := tracer.named(SpanIndex)
+	s.Require().Len(indexes, 1)
+	s.Equal(SpanStream, indexes[0].parent)
+	s.Equal(filepath.Join(tracingDataDir, "access.log"), indexes[0].attrs["file"])
+
+	reads := tracer.named(SpanRead)
+	s.Require().Len(reads, 1)
+	s.Equal(SpanStream, reads[0].parent)
+	s.True(reads[0].ended)
+	s.Equal(filepath.Join(tracingDataDir, "access.log"), reads[0].attrs["file"])
+}
+
+func (s *tracingSuite) Test_Tracer_Reverse() {
+	tracer := &recordingTracer{}
+	logs, err := New(WithDirectory(tracingDataDir), WithWindow(2*time.Hour), WithEnd(s.testTime), WithTracer(tracer))
+	s.Require().NoError(err)
+	n := 0
+	s.Require().NoError(logs.ForEachReverse(context.Background(), func(LogEntry) error {
+		n++
+		if n == 3 {
+			return ErrStop
+		}
+		return nil
+	}))
+	reads := tracer.named(SpanRead)
+	s.Require().Len(reads, 2)
+	for _, read := range reads {
+		s.True(read.ended)
+		// stopping early is no failure
+		s.NoError(read.err)
+	}
+}
+
+func (s *tracingSuite) Test_Tracer_NoFiles() {
+	tracer := &recordingTracer{}
+	logs, err := New(WithDirectory(tracingDataDir), WithWindow(time.Minute), WithEnd(s.testTime.Add(time.Hour)), WithTracer(tracer))
+	s.Require().NoError(err)
+	err = logs.Print(context.Background(), io.Discard)
+	s.True(errors.Is(err, ErrNoFilesInWindow))
+	streams := tracer.named(SpanStream)
+	s.Require().Len(streams, 1)
+	s.True(errors.Is(streams[0].err, ErrNoFilesInWindow))
+}
+
+func TestTracingSuite(t *testing.T) {
+	suite.Run(t, new(tracingSuite))
+}
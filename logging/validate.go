@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// validateMaxExamples is the number of lines of every kind of problem kept by a FileReport as examples.
+const validateMaxExamples = 5
+
+// FileReport is the result of the validation of a log file, see Logs.Validate.
+type FileReport struct {
+	// File is the path of the log file.
+	File string `json:"file"`
+	// Size is the size of the file when validated.
+	Size int64 `json:"size"`
+	// Lines is the number of lines of the file, the invalid ones included.
+	Lines int64 `json:"lines"`
+	// First is the time of the first valid log line and Last the latest one, zero when there are none.
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	// Invalid is the number of lines not matching the log format, InvalidLines holding the first ones,
+	// which are errors of their own (see InvalidLogLineError.Error).
+	Invalid      int64                  `json:"invalid"`
+	InvalidLines []*InvalidLogLineError `json:"-"`
+	// Unsorted is the number of log lines older than a log line before them, UnsortedLines holding the first ones.
+	// A few seconds (MaxSkew) are usually the requests logged once served, see LogsConfig.Tolerance.
+	Unsorted      int64          `json:"unsorted"`
+	UnsortedLines []UnsortedLine `json:"unsorted_lines,omitempty"`
+	// MaxSkew is how much older than the log lines before it an unsorted log line is at most.
+	MaxSkew time.Duration `json:"max_skew"`
+	// Incomplete tells the last line has no trailing newline, which is most likely still being written.
+	// It isn't counted as invalid, nor as a line.
+	Incomplete bool `json:"incomplete"`
+}
+
+// Valid checks whether every line of the file matches the log format, in order.
+func (r FileReport) Valid() bool {
+	return r.Invalid == 0 && r.Unsorted == 0
+}
+
+// UnsortedLine is a log line older than a log line before it, see FileReport.
+type UnsortedLine struct {
+	// LineNumber is the 1-based number of the line inside the log file.
+	LineNumber int64 `json:"line_number"`
+	// Offset is the byte offset of the line inside the log file.
+	Offset int64 `json:"offset"`
+	// Time is the time of the log line, Latest the one of the latest log line before it.
+	Time   time.Time `json:"time"`
+	Latest time.Time `json:"latest"`
+}
+
+// Validate reads every line of the log files of the directory whatever their times, checking they match
+// the log format and are sorted by time, e.g. to vet a directory before relying on its extracts.
+// The reports are sorted the way the files are read, by their modified time, the empty files being left out.
+// A file failing to be read stops the validation with its error, unless removed since listed and skipped (see LogsConfig.SkipVanished).
+func (logs *Logs) Validate(ctx context.Context) ([]FileReport, error) {
+	files, err := logs.files(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]FileReport, 0, len(files))
+	for _, fi := range files {
+		report, err := logs.validateFile(ctx, fi.path)
+		if errors.Is(err, errVanished) {
+			continue
+		}
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// validateFile validates every line of a given log file.
+func (logs *Logs) validateFile(ctx context.Context, path string) (FileReport, error) {
+	file, err := logs.open(path)
+	if err != nil {
+		return FileReport{}, err
+	}
+	defer func() { _ = file.Close() }()
+
+	report := FileReport{File: path}
+	info, err := file.Stat()
+	if err != nil {
+		return report, err
+	}
+	report.Size = info.Size()
+
+	reader := getReader(contextReader{ctx: ctx, r: io.NewSectionReader(file, 0, report.Size)})
+	defer putReader(reader)
+	var offset int64
+	for lineNumber := int64(1); ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return report, err
+		}
+		if line == "" {
+			return report, nil
+		}
+		lineOffset := offset
+		offset += int64(len(line))
+
+		entry, parseErr := file.parseLogEntry(strings.TrimSpace(line))
+		if !strings.HasSuffix(line, "\n") && parseErr != nil {
+			report.Incomplete = true
+			return report, nil
+		}
+		report.Lines++
+		var invalidErr *InvalidLogLineError
+		if errors.As(parseErr, &invalidErr) {
+			report.Invalid++
+			if len(report.InvalidLines) < validateMaxExamples {
+				invalidErr.LineNumber, invalidErr.Offset = int(lineNumber), lineOffset
+				report.InvalidLines = append(report.InvalidLines, invalidErr)
+			}
+			continue
+		}
+
+		switch {
+		case report.First.IsZero():
+			report.First, report.Last = entry.Time, entry.Time
+		case entry.Time.Before(report.Last):
+			report.Unsorted++
+			if skew := report.Last.Sub(entry.Time); skew > report.MaxSkew {
+				report.MaxSkew = skew
+			}
+			if len(report.UnsortedLines) < validateMaxExamples {
+				report.UnsortedLines = append(report.UnsortedLines, UnsortedLine{
+					LineNumber: lineNumber, Offset: lineOffset, Time: entry.Time, Latest: report.Last,
+				})
+			}
+		default:
+			report.Last = entry.Time
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const validateDataDir = "test/validate"
+
+type validateSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *validateSuite) SetupSuite() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	files := []string{
+		`10.0.0.1 - - [03/Mar/2022:01:00:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.2 - - [03/Mar/2022:01:10:00 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:01:20:00 +0000] "GET /c HTTP/1.1" 200 10
+`,
+		`10.0.0.1 - - [03/Mar/2022:02:00:00 +0000] "GET /a HTTP/1.1" 200 10
+not a log line
+10.0.0.2 - - [03/Mar/2022:02:10:00 +0000] "GET /b HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:02:09:57 +0000] "GET /c HTTP/1.1" 200 10
+10.0.0.4 - - [03/Mar/2022:02:09:59 +0000] "GET /d HTTP/1.1" 200 10
+
+10.0.0.5 - - [03/Mar/2022:02:20:00 +0000] "GET /e HTTP/1.1" 200 10
+10.0.0.6 - - [03/Mar/2022:02:2`,
+		"",
+	}
+	for i, logs := range files {
+		name := filepath.Join(validateDataDir, []string{"access.log.1", "access.log", "empty.log"}[i])
+		modTime := t.Add(time.Duration(i-len(files)) * time.Minute)
+		writeLogFile(s.T(), name, logs, modTime)
+	}
+}
+
+func (s *validateSuite) TearDownSuite() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(validateDataDir)))
+}
+
+func (s *validateSuite) Test_Validate() {
+	logs, err := New(WithDirectory(validateDataDir), WithWindow(time.Minute))
+	s.Require().NoError(err)
+
+	reports, err := logs.Validate(context.Background())
+	s.Require().NoError(err)
+
+	// the files are validated whatever the window, the empty one being left out
+	s.Require().Len(reports, 2)
+	valid := reports[0]
+	s.Equal(filepath.Join(validateDataDir, "access.log.1"), valid.File)
+	s.Equal(int64(3), valid.Lines)
+	s.Equal(s.testTime.Add(-105*time.Minute), valid.First)
+	s.Equal(s.testTime.Add(-85*time.Minute), valid.Last)
+	s.True(valid.Valid())
+	s.False(valid.Incomplete)
+
+	invalid := reports[1]
+	s.Equal(filepath.Join(validateDataDir, "access.log"), invalid.File)
+	s.Equal(int64(7), invalid.Lines)
+	s.Equal(s.testTime.Add(-45*time.Minute), invalid.First)
+	s.Equal(s.testTime.Add(-25*time.Minute), invalid.Last)
+	s.False(invalid.Valid())
+	s.True(invalid.Incomplete)
+
+	s.Equal(int64(2), invalid.Invalid)
+	s.Require().Len(invalid.InvalidLines, 2)
+	s.Equal(2, invalid.InvalidLines[0].LineNumber)
+	s.Equal(int64(67), invalid.InvalidLines[0].Offset)
+	s.Equal("not a log line", invalid.InvalidLines[0].Content)
+	s.Equal(6, invalid.InvalidLines[1].LineNumber)
+	s.ErrorIs(invalid.InvalidLines[1], ErrInvalidLogLine)
+
+	s.Equal(int64(2), invalid.Unsorted)
+	s.Equal(3*time.Second, invalid.MaxSkew)
+	s.Equal([]UnsortedLine{
+		{LineNumber: 4, Offset: 149, Time: s.testTime.Add(-35*time.Minute - 3*time.Second), Latest: s.testTime.Add(-35 * time.Minute)},
+		{LineNumber: 5, Offset: 216, Time: s.testTime.Add(-35*time.Minute - time.Second), Latest: s.testTime.Add(-35 * time.Minute)},
+	}, invalid.UnsortedLines)
+}
+
+func (s *validateSuite) Test_Validate_Cancelled() {
+	logs, err := New(WithDirectory(validateDataDir))
+	s.Require().NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	reports, err := logs.Validate(ctx)
+
+	s.ErrorIs(err, context.Canceled)
+	s.Empty(reports)
+}
+
+func TestValidate(t *testing.T) {
+	suite.Run(t, new(validateSuite))
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const vanishedDataDir = "test/vanished"
+
+type vanishedSuite struct {
+	suite.Suite
+	testTime time.Time
+}
+
+func (s *vanishedSuite) SetupTest() {
+	t := parseLogTime(s.T(), "03/Mar/2022:02:45:00 +0000")
+	s.testTime = t
+
+	files := []string{
+		`10.0.0.1 - - [03/Mar/2022:02:10:00 +0000] "GET /a HTTP/1.1" 200 10
+`,
+		`10.0.0.2 - - [03/Mar/2022:02:20:00 +0000] "GET /b HTTP/1.1" 200 10
+`,
+		`10.0.0.3 - - [03/Mar/2022:02:30:00 +0000] "GET /c HTTP/1.1" 200 10
+`,
+	}
+	for i, logs := range files {
+		name := filepath.Join(vanishedDataDir, []string{"access.log.2", "access.log.1", "access.log"}[i])
+		modTime := []time.Time{t.Add(-35 * time.Minute), t.Add(-25 * time.Minute), t.Add(-15 * time.Minute)}[i]
+		writeLogFile(s.T(), name, logs, modTime)
+	}
+}
+
+func (s *vanishedSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(vanishedDataDir)))
+}
+
+// logs lists the log files, then removes access.log.1 the way an aggressive logrotate would before it is read.
+func (s *vanishedSuite) logs(opts ...Option) *Logs {
+	logs, err := New(append([]Option{WithDirectory(vanishedDataDir), WithWindow(time.Hour), WithEnd(s.testTime)}, opts...)...)
+	s.Require().NoError(err)
+	s.Require().NoError(os.Remove(filepath.Join(vanishedDataDir, "access.log.1")))
+	return logs
+}
+
+func (s *vanishedSuite) Test_SkipVanished_Print() {
+	metrics := &Metrics{}
+	logger := &recordingLogger{}
+	logs := s.logs(WithSkipVanished(), WithMetrics(metrics), WithLogger(logger))
+
+	var out bytes.Buffer
+	s.Require().NoError(logs.Print(context.Background(), &out))
+
+	s.Equal(`10.0.0.1 - - [03/Mar/2022:02:10:00 +0000] "GET /a HTTP/1.1" 200 10
+10.0.0.3 - - [03/Mar/2022:02:30:00 +0000] "GET /c HTTP/1.1" 200 10
+`, out.String())
+	snapshot := metrics.Snapshot()
+	s.Equal(int64(2), snapshot.FilesScanned)
+	s.Equal(int64(1), snapshot.FilesVanished)
+	s.Contains(logger.messages, "INFO file vanished path="+filepath.Join(vanishedDataDir, "access.log.1")+
+		" error=open "+filepath.Join(vanishedDataDir, "access.log.1")+": no such file or directory")
+}
+
+func (s *vanishedSuite) Test_SkipVanished_ForEach() {
+	for _, workers := range []int{1, 4} {
+		metrics := &Metrics{}
+		logs := s.logs(WithSkipVanished(), WithMetrics(metrics), WithWorkers(workers))
+
+		var paths []string
+		s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+			paths = append(paths, entry.Path)
+			return nil
+		}))
+
+		s.Equal([]string{"/a", "/c"}, paths, "%d workers", workers)
+		s.Equal(int64(1), metrics.Snapshot().FilesVanished, "%d workers", workers)
+		s.TearDownTest()
+		s.SetupTest()
+	}
+}
+
+func (s *vanishedSuite) Test_SkipVanished_Reverse() {
+	logs := s.logs(WithSkipVanished())
+
+	var paths []string
+	s.Require().NoError(logs.ForEachReverse(context.Background(), func(entry LogEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	}))
+
+	s.Equal([]string{"/c", "/a"}, paths)
+}
+
+func (s *vanishedSuite) Test_Vanished_Fails() {
+	logs := s.logs()
+
+	err := logs.Print(context.Background(), &bytes.Buffer{})
+
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+	s.True(strings.Contains(err.Error(), "access.log.1"))
+}
+
+func TestVanished(t *testing.T) {
+	suite.Run(t, new(vanishedSuite))
+}
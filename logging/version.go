@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// The build metadata, set when linking the binary (see the Makefile), e.g.
+//
+//	go build -ldflags "-X github.com/chill-and-code/apache-log-reader/logging.version=v1.4.0 \
+//		-X github.com/chill-and-code/apache-log-reader/logging.commit=$(git rev-parse --short HEAD) \
+//		-X github.com/chill-and-code/apache-log-reader/logging.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// readBuildInfo reads the module information embedded in the binary, replaced by the tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// BuildInfo identifies the build of the binary, e.g. for bug reports.
+type BuildInfo struct {
+	// Version is the version of the module, set at link time, otherwise the one the module
+	// was required at by the main module (e.g. go install ...@v1.4.0), or "devel" when unknown.
+	Version string
+	// Commit is the VCS revision the binary was built from, empty when unknown.
+	Commit string
+	// Date is the time the binary was built at, empty when unknown.
+	Date string
+	// GoVersion is the version of Go the binary was built with.
+	GoVersion string
+}
+
+// String formats the build information on a single line, e.g. v1.4.0 (commit 1a2b3c4, built 2022-03-04T05:30:00Z, go1.17.8).
+func (b BuildInfo) String() string {
+	details := ""
+	if b.Commit != "" {
+		details += "commit " + b.Commit + ", "
+	}
+	if b.Date != "" {
+		details += "built " + b.Date + ", "
+	}
+	return fmt.Sprintf("%s (%s%s)", b.Version, details, b.GoVersion)
+}
+
+// Build returns the build information of the binary.
+func Build() BuildInfo {
+	return BuildInfo{Version: Version(), Commit: commit, Date: date, GoVersion: runtime.Version()}
+}
+
+// Version returns the version of the module, see BuildInfo.Version.
+func Version() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := readBuildInfo(); ok {
+		const path = "github.com/chill-and-code/apache-log-reader"
+		if info.Main.Path == path && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			return info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == path {
+				return dep.Version
+			}
+		}
+	}
+	return "devel"
+}
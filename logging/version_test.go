@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type versionSuite struct {
+	suite.Suite
+}
+
+func (s *versionSuite) TearDownTest() {
+	version, commit, date = "", "", ""
+	readBuildInfo = debug.ReadBuildInfo
+}
+
+func (s *versionSuite) Test_Version() {
+	buildInfo := func(main string, deps ...*debug.Module) func() (*debug.BuildInfo, bool) {
+		return func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{
+				Main: debug.Module{Path: "github.com/chill-and-code/apache-log-reader", Version: main},
+				Deps: deps,
+			}, true
+		}
+	}
+	for _, test := range []struct {
+		name      string
+		linked    string
+		buildInfo func() (*debug.BuildInfo, bool)
+		expected  string
+	}{
+		{name: "Linked", linked: "v1.4.0", buildInfo: buildInfo("v1.3.0"), expected: "v1.4.0"},
+		{name: "Installed", buildInfo: buildInfo("v1.3.0"), expected: "v1.3.0"},
+		{name: "Devel", buildInfo: buildInfo("(devel)"), expected: "devel"},
+		{name: "Dependency", buildInfo: func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/app", Version: "(devel)"},
+				Deps: []*debug.Module{{Path: "github.com/chill-and-code/apache-log-reader", Version: "v1.2.0"}},
+			}, true
+		}, expected: "v1.2.0"},
+		{name: "Unknown", buildInfo: func() (*debug.BuildInfo, bool) { return nil, false }, expected: "devel"},
+	} {
+		s.Run(test.name, func() {
+			version = test.linked
+			readBuildInfo = test.buildInfo
+			s.Equal(test.expected, Version())
+		})
+	}
+}
+
+func (s *versionSuite) Test_Build() {
+	version, commit, date = "v1.4.0", "1a2b3c4", "2022-03-04T05:30:00Z"
+	build := Build()
+	s.Equal(BuildInfo{Version: "v1.4.0", Commit: "1a2b3c4", Date: "2022-03-04T05:30:00Z", GoVersion: runtime.Version()}, build)
+	s.Equal("v1.4.0 (commit 1a2b3c4, built 2022-03-04T05:30:00Z, "+runtime.Version()+")", build.String())
+
+	version, commit, date = "", "", ""
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+	s.Equal("devel ("+runtime.Version()+")", Build().String())
+}
+
+func TestVersion(t *testing.T) {
+	suite.Run(t, new(versionSuite))
+}
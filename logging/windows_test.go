@@ -0,0 +1,106 @@
+//go:build windows
+// +build windows
+
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const windowsDataDir = "test/windows"
+
+// windowsSuite covers the semantics of the files on Windows, e.g. the logs of an Apache of XAMPP:
+// CRLF line endings, backslashed paths, and files renamed by a rotation while they're read.
+type windowsSuite struct {
+	suite.Suite
+	testTime time.Time
+	logs     string
+	file     string
+}
+
+func (s *windowsSuite) SetupTest() {
+	s.logs = "127.0.0.1 - - [03/Mar/2022:10:00:00 +0000] \"GET /a HTTP/1.1\" 200 1\r\n" +
+		"127.0.0.1 - - [03/Mar/2022:10:01:00 +0000] \"GET /b HTTP/1.1\" 200 1\r\n" +
+		"127.0.0.1 - - [03/Mar/2022:10:02:00 +0000] \"GET /c HTTP/1.1\" 200 1\r\n" +
+		"127.0.0.1 - - [03/Mar/2022:10:03:00 +0000] \"GET /d HTTP/1.1\" 200 1\r\n"
+	s.file = filepath.Join(windowsDataDir, "access.log")
+	s.testTime = parseLogTime(s.T(), "03/Mar/2022:10:05:00 +0000")
+	writeLogFile(s.T(), s.file, s.logs, s.testTime)
+}
+
+func (s *windowsSuite) TearDownTest() {
+	s.Require().NoError(os.RemoveAll(filepath.Dir(windowsDataDir)))
+}
+
+func (s *windowsSuite) Test_IndexTime_CRLF() {
+	f, err := openLogFile(s.file)
+	s.Require().NoError(err)
+	defer func() { _ = f.Close() }()
+
+	offset, err := NewFile(f).IndexTime(context.Background(), s.testTime.Add(-3*time.Minute-30*time.Second))
+	s.Require().NoError(err)
+	s.Equal(int64(strings.Index(s.logs, "127.0.0.1 - - [03/Mar/2022:10:02:00")), offset)
+}
+
+func (s *windowsSuite) Test_Entries_BackslashedPaths() {
+	logs, err := New(WithDirectory(strings.ReplaceAll(windowsDataDir, "/", `\`)), WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	var files []string
+	s.Require().NoError(logs.ForEach(context.Background(), func(entry LogEntry) error {
+		s.NotContains(entry.Line, "\r")
+		files = append(files, entry.File)
+		return nil
+	}))
+	s.Require().Len(files, 4)
+	s.Equal(filepath.Join(windowsDataDir, "access.log"), files[0])
+}
+
+func (s *windowsSuite) Test_RotationWhileReading() {
+	logs, err := New(WithDirectory(windowsDataDir), WithWindow(time.Hour), WithEnd(s.testTime))
+	s.Require().NoError(err)
+	it := logs.Entries(context.Background())
+	defer func() { _ = it.Close() }()
+	s.Require().True(it.Next())
+
+	// the file being read doesn't prevent the rotation from renaming it
+	rotated := filepath.Join(windowsDataDir, "access.log.1")
+	s.Require().NoError(os.Rename(s.file, rotated))
+	s.Require().NoError(os.WriteFile(s.file, nil, 0666))
+
+	paths := []string{it.Entry().Path}
+	for it.Next() {
+		paths = append(paths, it.Entry().Path)
+	}
+	s.Require().NoError(it.Err())
+	s.Equal([]string{"/a", "/b", "/c", "/d"}, paths)
+}
+
+func (s *windowsSuite) Test_FileID_Rename() {
+	id := s.fileID(s.file)
+	s.NotEqual(FileID{}, id)
+
+	rotated := filepath.Join(windowsDataDir, "access.log.1")
+	s.Require().NoError(os.Rename(s.file, rotated))
+	s.Equal(id, s.fileID(rotated))
+}
+
+// fileID returns the identity of the file of a given path.
+func (s *windowsSuite) fileID(name string) FileID {
+	f, err := openLogFile(name)
+	s.Require().NoError(err)
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	s.Require().NoError(err)
+	return fileIDOf(f, info)
+}
+
+func TestWindowsSuite(t *testing.T) {
+	suite.Run(t, new(windowsSuite))
+}
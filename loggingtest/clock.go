@@ -0,0 +1,46 @@
+package loggingtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// Clock is a fake clock, which time only moves when told so, e.g. for the time range of the logs
+// to end at the same time whenever the test runs (see Option). It's safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a Clock set at a given time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by a given duration, e.g. to read the next window of the logs.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set sets the time of the clock.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Option returns the option of the Logs ending their time range at the time of the clock, see logging.WithClock.
+func (c *Clock) Option() logging.Option {
+	return logging.WithClock(c.Now)
+}
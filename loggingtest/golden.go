@@ -0,0 +1,50 @@
+package loggingtest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// UpdateEnv is the environment variable updating the golden files with what the tests got instead of comparing
+// them when set, e.g. LOGGINGTEST_UPDATE=1 go test ./..., the changes being reviewed in the diff.
+const UpdateEnv = "LOGGINGTEST_UPDATE"
+
+// AssertGolden compares what a test got to the content of a golden file (e.g. testdata/window.golden), failing
+// the test with the first line that differs when they don't match. The golden file is written instead when
+// the UpdateEnv environment variable is set, along with its directory.
+func AssertGolden(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("loggingtest: could not create the directory of the golden file: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0666); err != nil {
+			t.Fatalf("loggingtest: could not update the golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("loggingtest: could not read the golden file (%s=1 to write it): %v", UpdateEnv, err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+	gotLines, wantLines := bytes.Split(got, []byte("\n")), bytes.Split(want, []byte("\n"))
+	for i := 0; ; i++ {
+		var gotLine, wantLine []byte
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if !bytes.Equal(gotLine, wantLine) || i >= len(gotLines) || i >= len(wantLines) {
+			t.Errorf("loggingtest: %s differs at line %d (%s=1 to update it):\n got: %q\nwant: %q\n(%d lines got, %d wanted)",
+				path, i+1, UpdateEnv, gotLine, wantLine, len(gotLines), len(wantLines))
+			return
+		}
+	}
+}
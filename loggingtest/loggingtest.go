@@ -0,0 +1,77 @@
+// Package loggingtest helps writing tests against the log reader, e.g. the integration tests of a service embedding it:
+// it writes deterministic directories of rotated log files (see WriteLogDir), gives a fake clock the time range
+// of the logs ends at (see Clock), and compares what's read to golden files (see AssertGolden).
+//
+//	func TestErrors(t *testing.T) {
+//		dir := loggingtest.WriteLogDir(t, 3, from, from.Add(time.Hour))
+//		clock := loggingtest.NewClock(from.Add(time.Hour))
+//		logs := dir.Logs(t, logging.WithWindow(5*time.Minute), clock.Option(), logging.WithFilter(...))
+//		loggingtest.AssertGolden(t, "testdata/errors.golden", loggingtest.Print(t, logs))
+//	}
+package loggingtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/generate"
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// DefaultSeed is the seed the log lines of WriteLogDir are generated with, unless given another one (see generate.WithSeed).
+const DefaultSeed = 1
+
+// LogDir is a directory of rotated log files written by WriteLogDir.
+type LogDir struct {
+	// Dir is the directory of the log files, removed once the test is done.
+	Dir string
+	// Files are the paths of the log files, from the oldest (e.g. access.log.2) to the latest (access.log).
+	Files []string
+	// Format is the format of the log lines.
+	Format logging.Format
+	// From and To are the time range [From, To) of the log lines.
+	From, To time.Time
+}
+
+// WriteLogDir writes the log lines of the time range [from, to) to a given number of files rotated the way logrotate
+// does (see generate.Generator.WriteFiles), inside a temporary directory of the test. The log lines are the same
+// for the same arguments, generated with DefaultSeed unless told otherwise, so what's read from them can be compared
+// to golden files. The test fails right away when the files cannot be written.
+func WriteLogDir(t testing.TB, files int, from, to time.Time, opts ...generate.Option) LogDir {
+	t.Helper()
+	g, err := generate.New(append([]generate.Option{generate.WithSeed(DefaultSeed)}, opts...)...)
+	if err != nil {
+		t.Fatalf("loggingtest: could not create the generator: %v", err)
+	}
+	dir := t.TempDir()
+	paths, err := g.WriteFiles(dir, files, from, to)
+	if err != nil {
+		t.Fatalf("loggingtest: could not write the log files: %v", err)
+	}
+	return LogDir{Dir: dir, Files: paths, Format: g.Format(), From: from, To: to}
+}
+
+// Logs creates the Logs of the directory and its format, configured by the given options (e.g. a window and a clock).
+// The test fails right away when the configuration is invalid.
+func (d LogDir) Logs(t testing.TB, opts ...logging.Option) *logging.Logs {
+	t.Helper()
+	logs, err := logging.New(append([]logging.Option{logging.WithDirectory(d.Dir), logging.WithFormat(d.Format)}, opts...)...)
+	if err != nil {
+		t.Fatalf("loggingtest: could not create the logs: %v", err)
+	}
+	return logs
+}
+
+// Print returns the log lines printed by a given Logs (see logging.Logs.Print), e.g. to compare them to a golden file.
+// Having no log file within the time window is no error, nothing being printed, while any other error fails the test right away.
+func Print(t testing.TB, logs *logging.Logs) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := logs.Print(context.Background(), &buf); err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		t.Fatalf("loggingtest: could not print the logs: %v", err)
+	}
+	return buf.Bytes()
+}
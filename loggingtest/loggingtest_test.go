@@ -0,0 +1,132 @@
+package loggingtest
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/generate"
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type loggingTestSuite struct {
+	suite.Suite
+	from time.Time
+}
+
+func (s *loggingTestSuite) SetupSuite() {
+	s.from = time.Date(2022, time.March, 3, 10, 0, 0, 0, time.UTC)
+}
+
+func (s *loggingTestSuite) Test_WriteLogDir() {
+	dir := WriteLogDir(s.T(), 3, s.from, s.from.Add(3*time.Minute), generate.WithRate(1))
+	s.Require().Len(dir.Files, 3)
+	s.Equal(filepath.Join(dir.Dir, "access.log.2"), dir.Files[0])
+	s.Equal(filepath.Join(dir.Dir, "access.log"), dir.Files[2])
+	s.Equal(logging.FormatCommon, dir.Format)
+
+	// the same arguments write the same log files
+	again := WriteLogDir(s.T(), 3, s.from, s.from.Add(3*time.Minute), generate.WithRate(1))
+	for i := range dir.Files {
+		content, err := os.ReadFile(dir.Files[i])
+		s.Require().NoError(err)
+		s.NotEmpty(content)
+		other, err := os.ReadFile(again.Files[i])
+		s.Require().NoError(err)
+		s.Equal(string(content), string(other))
+	}
+}
+
+func (s *loggingTestSuite) Test_Golden() {
+	dir := WriteLogDir(s.T(), 2, s.from, s.from.Add(2*time.Minute), generate.WithRate(0.2), generate.WithFormat(logging.FormatCombined))
+	clock := NewClock(s.from.Add(90 * time.Second))
+	logs := dir.Logs(s.T(), logging.WithWindow(time.Minute), clock.Option())
+	AssertGolden(s.T(), "testdata/window.golden", Print(s.T(), logs))
+
+	// the window ends at the time of the clock
+	clock.Advance(time.Hour)
+	s.Empty(Print(s.T(), logs))
+}
+
+func (s *loggingTestSuite) Test_Print_SameAsReference() {
+	// the lines printed are the same as the reference's, for random directories and windows, whichever way they're found
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		files := 1 + r.Intn(5)
+		to := s.from.Add(time.Duration(1+r.Intn(30)) * time.Minute)
+		format := []logging.Format{logging.FormatCommon, logging.FormatCombined}[r.Intn(2)]
+		dir := WriteLogDir(s.T(), files, s.from, to, generate.WithSeed(r.Int63()), generate.WithRate(0.1+r.Float64()*5), generate.WithFormat(format))
+		for j := 0; j < 5; j++ {
+			// the windows can start before the logs or end after them, and be shorter than a second
+			start := s.from.Add(time.Duration(r.Int63n(int64(to.Sub(s.from)+2*time.Minute))) - time.Minute)
+			window := time.Duration(1 + r.Int63n(int64(10*time.Minute)))
+			expected := dir.ReferencePrint(s.T(), start, start.Add(window))
+			for name, opt := range map[string]logging.Option{
+				"search":  logging.WithWindow(window),
+				"index":   logging.WithIndex(time.Minute),
+				"mmap":    logging.WithMemoryMap(),
+				"workers": logging.WithWorkers(4),
+				"filter":  logging.WithFilter(func(logging.LogEntry) bool { return true }),
+			} {
+				logs := dir.Logs(s.T(), logging.WithWindow(window), logging.WithEnd(start.Add(window)), opt)
+				s.Require().Equal(string(expected), string(Print(s.T(), logs)), "%s: %d files, window %s from %s", name, files, window, start)
+			}
+		}
+	}
+}
+
+func (s *loggingTestSuite) Test_AssertGolden_Mismatch() {
+	golden := filepath.Join(s.T().TempDir(), "lines.golden")
+	s.Require().NoError(os.WriteFile(golden, []byte("a\nb\n"), 0666))
+	t := &recordingT{TB: s.T()}
+
+	AssertGolden(t, golden, []byte("a\nb\n"))
+	s.Empty(t.errors)
+	AssertGolden(t, golden, []byte("a\nc\n"))
+	s.Require().Len(t.errors, 1)
+	s.Contains(t.errors[0], "differs at line 2")
+	AssertGolden(t, golden, []byte("a\nb\nc\n"))
+	s.Require().Len(t.errors, 2)
+	s.Contains(t.errors[1], "differs at line 3")
+}
+
+func (s *loggingTestSuite) Test_AssertGolden_Update() {
+	golden := filepath.Join(s.T().TempDir(), "new", "lines.golden")
+	s.T().Setenv(UpdateEnv, "1")
+
+	AssertGolden(s.T(), golden, []byte("a\n"))
+
+	content, err := os.ReadFile(golden)
+	s.Require().NoError(err)
+	s.Equal("a\n", string(content))
+}
+
+func (s *loggingTestSuite) Test_Clock() {
+	clock := NewClock(s.from)
+	s.Equal(s.from, clock.Now())
+	clock.Advance(time.Minute)
+	s.Equal(s.from.Add(time.Minute), clock.Now())
+	clock.Set(s.from)
+	s.Equal(s.from, clock.Now())
+}
+
+// recordingT records the errors of a test instead of failing it.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(loggingTestSuite))
+}
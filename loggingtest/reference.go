@@ -0,0 +1,47 @@
+package loggingtest
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ReferencePrint returns the log lines of the directory that happened within the time range [start, end), reading
+// every line of every file from the oldest to the latest and keeping the ones whose time is within the range:
+// it's slow, but obviously correct, so what Logs print for the same window (see Print) is compared to it,
+// whichever way they find the lines (binary search, index, memory map, ...). The lines that cannot be parsed
+// are left out. The test fails right away when the files cannot be read.
+func (d LogDir) ReferencePrint(t testing.TB, start, end time.Time) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, path := range d.Files {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("loggingtest: could not open the log file: %v", err)
+		}
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadString('\n')
+			if trimmed := strings.TrimSpace(line); trimmed != "" {
+				logTime, parseErr := d.Format.ParseTime(trimmed)
+				if parseErr == nil && !logTime.Before(start) && logTime.Before(end) {
+					buf.WriteString(strings.TrimSuffix(line, "\n"))
+					buf.WriteByte('\n')
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				_ = f.Close()
+				t.Fatalf("loggingtest: could not read the log file: %v", err)
+			}
+		}
+		_ = f.Close()
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,251 @@
+// Package logrpc serves the logs of a directory over gRPC, see the LogReader service of logreader.proto.
+package logrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative logreader.proto
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// nextCursorKey is the key of the trailer giving the cursor of the next page of QueryWindow, see Server.page.
+const nextCursorKey = "next-cursor"
+
+// defaultTopStatuses is the number of most frequent status codes returned by GetStats when not given.
+const defaultTopStatuses = 5
+
+// Server implements the LogReader service, reading the logs using a base configuration
+// completed by every request (see Server.logs).
+// The log entries are streamed as the client receives them: a client reading slowly
+// holds the reading of the log files back instead of making them pile up in memory.
+type Server struct {
+	UnimplementedLogReaderServer
+	cfg logging.LogsConfig
+}
+
+// NewServer creates a Server reading the logs using a given base configuration.
+func NewServer(cfg logging.LogsConfig) *Server {
+	return &Server{cfg: cfg}
+}
+
+// QueryWindow streams the log entries within the minutes of the request matching its filter, in order,
+// by pages when given a page size or a cursor (see Server.page).
+func (srv *Server) QueryWindow(req *QueryWindowRequest, stream LogReader_QueryWindowServer) error {
+	if req.GetPageSize() > 0 || req.GetCursor() != "" {
+		return srv.page(req, stream)
+	}
+	if req.GetPageSize() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid page size %d", req.GetPageSize())
+	}
+	logs, err := srv.logs(req.GetMinutes(), req.GetFilter())
+	if err != nil {
+		return err
+	}
+
+	err = logs.ForEach(stream.Context(), func(entry logging.LogEntry) error {
+		return stream.Send(newLogEntry(entry))
+	})
+	if errors.Is(err, logging.ErrNoFilesInWindow) {
+		return nil
+	}
+	return statusError(err)
+}
+
+// page streams a page of the log entries of a given request, of its page size (all the entries left when 0)
+// after its cursor, if any. Every entry is given its cursor, so an interrupted call resumes after the last one
+// received, and the cursor of the next page is sent in the trailer (next-cursor) unless it is the last page.
+func (srv *Server) page(req *QueryWindowRequest, stream LogReader_QueryWindowServer) error {
+	if req.GetPageSize() < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid page size %d", req.GetPageSize())
+	}
+	var after *logging.Cursor
+	end := srv.cfg.End
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+	if token := req.GetCursor(); token != "" {
+		cursor, err := logging.ParseCursor(token)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		after, end = &cursor, cursor.End
+	}
+	size := int(req.GetPageSize())
+	if size == 0 {
+		size = math.MaxInt32
+	}
+
+	// the end of the first page is fixed here, so the cursors of its entries are known while streaming them
+	cfg := srv.cfg
+	cfg.End = end
+	logs, err := logsOf(cfg, req.GetMinutes(), req.GetFilter())
+	if err != nil {
+		return err
+	}
+	next, err := logs.Page(stream.Context(), after, size, func(entry logging.LogEntry) error {
+		msg := newLogEntry(entry)
+		msg.Cursor = logging.CursorOf(entry, end).String()
+		return stream.Send(msg)
+	})
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		return statusError(err)
+	}
+	if next != nil {
+		stream.SetTrailer(metadata.Pairs(nextCursorKey, next.String()))
+	}
+	return nil
+}
+
+// StreamTail streams the log entries matching the filter of the request as they are written,
+// until the call is canceled.
+func (srv *Server) StreamTail(req *StreamTailRequest, stream LogReader_StreamTailServer) error {
+	logs, err := srv.logs(0, req.GetFilter())
+	if err != nil {
+		return err
+	}
+
+	err = logs.Follow(stream.Context(), func(entry logging.LogEntry) error {
+		return stream.Send(newLogEntry(entry))
+	})
+	return statusError(err)
+}
+
+// GetStats returns the summary of the requests within the minutes of the request matching its filter.
+func (srv *Server) GetStats(ctx context.Context, req *GetStatsRequest) (*Stats, error) {
+	top := int(req.GetTop())
+	if top < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid top %d", top)
+	}
+	if top == 0 {
+		top = defaultTopStatuses
+	}
+	logs, err := srv.logs(req.GetMinutes(), req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := logs.Stats(ctx)
+	if err != nil && !errors.Is(err, logging.ErrNoFilesInWindow) {
+		return nil, statusError(err)
+	}
+	return newStats(stats, top), nil
+}
+
+// logs creates the logs of the directory within the given minutes (the ones of the server when 0),
+// keeping the log entries matching the given filter only.
+func (srv *Server) logs(minutes int32, filter *Filter) (*logging.Logs, error) {
+	return logsOf(srv.cfg, minutes, filter)
+}
+
+// logsOf creates the logs of a given base configuration the way Server.logs does.
+func logsOf(cfg logging.LogsConfig, minutes int32, filter *Filter) (*logging.Logs, error) {
+	cfg.Filters = append([]logging.Filter(nil), cfg.Filters...)
+
+	if minutes < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid minutes %d", minutes)
+	}
+	if minutes > 0 {
+		cfg.LastNMinutes = int(minutes)
+	}
+
+	if s := filter.GetStatus(); s != "" {
+		statusFilter, err := logging.StatusFilter(s)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		cfg.Filters = append(cfg.Filters, statusFilter)
+	}
+	if prefix := filter.GetPathPrefix(); prefix != "" {
+		cfg.Filters = append(cfg.Filters, func(entry logging.LogEntry) bool {
+			return strings.HasPrefix(entry.Path, prefix)
+		})
+	}
+	if method := filter.GetMethod(); method != "" {
+		cfg.Filters = append(cfg.Filters, func(entry logging.LogEntry) bool {
+			return strings.EqualFold(entry.Method, method)
+		})
+	}
+	if ip := filter.GetIp(); ip != "" {
+		cfg.Filters = append(cfg.Filters, func(entry logging.LogEntry) bool {
+			return entry.IP == ip
+		})
+	}
+
+	logs, err := logging.NewLogs(cfg)
+	var cfgErr *logging.ConfigError
+	if errors.As(err, &cfgErr) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return logs, statusError(err)
+}
+
+// statusError turns a given error into a gRPC status error, keeping the ones that already are,
+// e.g. the ones returned while sending to the client.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// newLogEntry converts a given log entry into its message.
+func newLogEntry(entry logging.LogEntry) *LogEntry {
+	msg := &LogEntry{
+		Line:      entry.Line,
+		Time:      timestamppb.New(entry.Time),
+		Ip:        entry.IP,
+		Identity:  entry.Identity,
+		User:      entry.User,
+		Method:    entry.Method,
+		Path:      entry.Path,
+		Protocol:  entry.Protocol,
+		Status:    int32(entry.Status),
+		Size:      entry.Size,
+		Referer:   entry.Referer,
+		UserAgent: entry.UserAgent,
+		Vhost:     entry.VHost,
+		Bot:       entry.Bot,
+		File:      entry.File,
+		Offset:    entry.Offset,
+	}
+	if entry.Duration > 0 {
+		msg.Duration = durationpb.New(entry.Duration)
+	}
+	return msg
+}
+
+// newStats converts given stats into their message, along with the given number of most frequent status codes.
+func newStats(stats *logging.Stats, top int) *Stats {
+	msg := &Stats{
+		Requests:          int64(stats.Requests),
+		UniqueIps:         int64(stats.UniqueIPs()),
+		Errors:            int64(stats.Errors),
+		ErrorRate:         stats.ErrorRate(),
+		Bots:              int64(stats.Bots),
+		BotRate:           stats.BotRate(),
+		Bytes:             stats.Bytes,
+		RequestsPerSecond: stats.RequestsPerSecond(),
+		Window:            durationpb.New(stats.Window),
+	}
+	for _, sc := range stats.TopStatuses(top) {
+		msg.TopStatuses = append(msg.TopStatuses, &StatusCount{Status: int32(sc.Status), Count: int64(sc.Count)})
+	}
+	return msg
+}
@@ -0,0 +1,196 @@
+package logrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+const serverDataDir = "test/server"
+
+type serverSuite struct {
+	suite.Suite
+	grpcServer *grpc.Server
+	conn       *grpc.ClientConn
+	client     LogReaderClient
+}
+
+func (s *serverSuite) SetupSuite() {
+	s.Require().NoError(os.MkdirAll(serverDataDir, 0777))
+	s.Require().NoError(os.WriteFile(filepath.Join(serverDataDir, "access.log"), []byte(`10.0.0.1 - - [03/Mar/2022:02:43:10 +0000] "GET /older HTTP/1.1" 200 20
+10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /api/a HTTP/1.1" 200 20
+10.0.0.2 - - [03/Mar/2022:02:44:20 +0000] "PUT /api/b HTTP/1.1" 500 10
+10.0.0.3 - - [03/Mar/2022:02:44:30 +0000] "GET /other HTTP/1.1" 503 30
+`), 0666))
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", "03/Mar/2022:02:45:00 +0000")
+	s.Require().NoError(err)
+	s.Require().NoError(os.Chtimes(filepath.Join(serverDataDir, "access.log"), t, t))
+
+	listener := bufconn.Listen(1 << 20)
+	s.grpcServer = grpc.NewServer()
+	RegisterLogReaderServer(s.grpcServer, NewServer(logging.LogsConfig{
+		Directory:    serverDataDir,
+		LastNMinutes: 1,
+		End:          t,
+	}))
+	go func() {
+		_ = s.grpcServer.Serve(listener)
+	}()
+
+	s.conn, err = grpc.DialContext(context.Background(), "bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	s.Require().NoError(err)
+	s.client = NewLogReaderClient(s.conn)
+}
+
+func (s *serverSuite) TearDownSuite() {
+	s.NoError(s.conn.Close())
+	s.grpcServer.Stop()
+	s.Require().NoError(os.RemoveAll(filepath.Dir(serverDataDir)))
+}
+
+// receive returns the paths of all the log entries of a given stream.
+func (s *serverSuite) receive(stream LogReader_QueryWindowClient) ([]string, error) {
+	var paths []string
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, entry.GetPath())
+	}
+}
+
+func (s *serverSuite) Test_QueryWindow() {
+	tests := []struct {
+		name    string
+		req     *QueryWindowRequest
+		paths   []string
+		errCode codes.Code
+	}{
+		{name: "window of the server", req: &QueryWindowRequest{}, paths: []string{"/api/a", "/api/b", "/other"}},
+		{name: "minutes", req: &QueryWindowRequest{Minutes: 2}, paths: []string{"/older", "/api/a", "/api/b", "/other"}},
+		{name: "status class", req: &QueryWindowRequest{Filter: &Filter{Status: "5xx"}}, paths: []string{"/api/b", "/other"}},
+		{name: "path prefix and method", req: &QueryWindowRequest{Filter: &Filter{PathPrefix: "/api", Method: "put"}}, paths: []string{"/api/b"}},
+		{name: "ip", req: &QueryWindowRequest{Filter: &Filter{Ip: "10.0.0.3"}}, paths: []string{"/other"}},
+		{name: "invalid status", req: &QueryWindowRequest{Filter: &Filter{Status: "abc"}}, errCode: codes.InvalidArgument},
+		{name: "invalid minutes", req: &QueryWindowRequest{Minutes: -1}, errCode: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			stream, err := s.client.QueryWindow(context.Background(), test.req)
+			s.Require().NoError(err)
+
+			paths, err := s.receive(stream)
+
+			s.Equal(test.errCode, status.Code(err))
+			s.Equal(test.paths, paths)
+		})
+	}
+}
+
+func (s *serverSuite) Test_QueryWindow_Pages() {
+	var paths []string
+	req := &QueryWindowRequest{Minutes: 2, PageSize: 3}
+	for pages := 0; ; pages++ {
+		s.Require().Less(pages, 3)
+		stream, err := s.client.QueryWindow(context.Background(), req)
+		s.Require().NoError(err)
+		page, err := s.receive(stream)
+		s.Require().NoError(err)
+		paths = append(paths, page...)
+		next := stream.Trailer().Get(nextCursorKey)
+		if len(next) == 0 {
+			break
+		}
+		req.Cursor = next[0]
+	}
+	s.Equal([]string{"/older", "/api/a", "/api/b", "/other"}, paths)
+
+	// an interrupted call resumes after the last entry received
+	stream, err := s.client.QueryWindow(context.Background(), &QueryWindowRequest{Minutes: 2, PageSize: 2})
+	s.Require().NoError(err)
+	first, err := stream.Recv()
+	s.Require().NoError(err)
+	s.NotEmpty(first.GetCursor())
+	stream, err = s.client.QueryWindow(context.Background(), &QueryWindowRequest{Minutes: 2, Cursor: first.GetCursor()})
+	s.Require().NoError(err)
+	paths, err = s.receive(stream)
+	s.Require().NoError(err)
+	s.Equal([]string{"/api/a", "/api/b", "/other"}, paths)
+
+	for _, req := range []*QueryWindowRequest{{PageSize: -1}, {Cursor: "not a cursor"}} {
+		stream, err := s.client.QueryWindow(context.Background(), req)
+		s.Require().NoError(err)
+		_, err = s.receive(stream)
+		s.Equal(codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func (s *serverSuite) Test_StreamTail() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := s.client.StreamTail(ctx, &StreamTailRequest{Filter: &Filter{Status: "5xx"}})
+	s.Require().NoError(err)
+	// let the server start following the files, from their end
+	time.Sleep(200 * time.Millisecond)
+
+	f, err := os.OpenFile(filepath.Join(serverDataDir, "access.log"), os.O_APPEND|os.O_WRONLY, 0666)
+	s.Require().NoError(err)
+	_, err = f.WriteString(`10.0.0.4 - - [03/Mar/2022:02:45:10 +0000] "GET /d HTTP/1.1" 200 40
+10.0.0.5 - - [03/Mar/2022:02:45:20 +0000] "GET /e HTTP/1.1" 502 50
+`)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+
+	entry, err := stream.Recv()
+	s.Require().NoError(err)
+	s.Equal("/e", entry.GetPath())
+	s.Equal(int32(502), entry.GetStatus())
+	s.Equal("10.0.0.5", entry.GetIp())
+	s.Equal(time.Date(2022, 3, 3, 2, 45, 20, 0, time.UTC), entry.GetTime().AsTime())
+
+	cancel()
+	_, err = stream.Recv()
+	s.Equal(codes.Canceled, status.Code(err))
+}
+
+func (s *serverSuite) Test_GetStats() {
+	stats, err := s.client.GetStats(context.Background(), &GetStatsRequest{Filter: &Filter{PathPrefix: "/api"}, Top: 1})
+
+	s.Require().NoError(err)
+	s.Equal(int64(2), stats.GetRequests())
+	s.Equal(int64(2), stats.GetUniqueIps())
+	s.Equal(int64(1), stats.GetErrors())
+	s.Equal(0.5, stats.GetErrorRate())
+	s.Equal(int64(30), stats.GetBytes())
+	s.Equal(time.Minute, stats.GetWindow().AsDuration())
+	s.Len(stats.GetTopStatuses(), 1)
+
+	_, err = s.client.GetStats(context.Background(), &GetStatsRequest{Top: -1})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(serverSuite))
+}
@@ -0,0 +1,215 @@
+// Package replay replays the GET requests of the log entries against a target server, preserving the (scaled)
+// times between them, so a window of real traffic becomes a load test (e.g. of a staging server).
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// The defaults of a Replayer.
+const (
+	DefaultSpeed       = 1.0
+	DefaultConcurrency = 64
+	DefaultTimeout     = 30 * time.Second
+)
+
+// Source calls the given function for each log entry to replay, e.g. logging.Logs.ForEach.
+type Source func(ctx context.Context, fn func(logging.LogEntry) error) error
+
+// Result sums up the requests replayed by a Replayer.
+type Result struct {
+	// Sent is the number of requests sent, Failed the ones which got no response (e.g. timed out).
+	Sent   int64 `json:"sent"`
+	Failed int64 `json:"failed"`
+	// Skipped is the number of log entries which aren't GET requests of a path, which are never replayed.
+	Skipped int64 `json:"skipped"`
+	// Statuses counts the responses by status.
+	Statuses map[int]int64 `json:"statuses"`
+	// MaxLag is how late a request was sent at most, compared to the (scaled) time between the log entries,
+	// which is when the target or the concurrency cannot keep up.
+	MaxLag time.Duration `json:"max_lag"`
+	// Duration is how long the replay took.
+	Duration time.Duration `json:"duration"`
+}
+
+// Replayer replays the GET requests of log entries against a target server, sending each of them once the time
+// between its log entry and the first one has elapsed, divided by the speed. The times of the log entries being
+// logged to the second, the requests of the same second are sent at once.
+type Replayer struct {
+	target      string
+	client      *http.Client
+	speed       float64
+	concurrency int
+	header      http.Header
+}
+
+// Option configures the Replayer created by New.
+type Option func(r *Replayer)
+
+// WithSpeed sets how many times faster than logged the requests are replayed, e.g. 2 to send them twice as fast,
+// DefaultSpeed by default. A speed of 0 sends them as fast as the concurrency allows.
+func WithSpeed(speed float64) Option {
+	return func(r *Replayer) {
+		r.speed = speed
+	}
+}
+
+// WithConcurrency sets the maximum number of requests in flight, DefaultConcurrency by default.
+func WithConcurrency(n int) Option {
+	return func(r *Replayer) {
+		r.concurrency = n
+	}
+}
+
+// WithClient sets the HTTP client sending the requests, one timing out after DefaultTimeout by default.
+func WithClient(client *http.Client) Option {
+	return func(r *Replayer) {
+		r.client = client
+	}
+}
+
+// WithHeader adds a header to every request replayed, e.g. to authenticate against the target
+// or to tell the replayed requests apart in its logs.
+func WithHeader(key, value string) Option {
+	return func(r *Replayer) {
+		r.header.Add(key, value)
+	}
+}
+
+// New creates a Replayer sending the requests to a given target URL (e.g. http://staging:8080),
+// which path, if any, prefixes the paths of the requests. It returns an error when the target or the options are invalid.
+func New(target string, opts ...Option) (*Replayer, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("target: must be an http or https URL")
+	}
+	r := &Replayer{
+		target:      strings.TrimSuffix(u.String(), "/"),
+		speed:       DefaultSpeed,
+		concurrency: DefaultConcurrency,
+		header:      make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.speed < 0 {
+		return nil, errors.New("speed: must not be negative")
+	}
+	if r.concurrency <= 0 {
+		return nil, errors.New("concurrency: must be positive")
+	}
+	if r.client == nil {
+		// as many connections as requests in flight are kept open, instead of opening new ones all along
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = r.concurrency
+		r.client = &http.Client{Timeout: DefaultTimeout, Transport: transport}
+	}
+	return r, nil
+}
+
+// Replay replays the GET requests of the log entries of a given source until the source is done
+// or the context is done, returning the result once all the requests sent are answered, along with the error
+// of the source, if any. No files within the time window (logging.ErrNoFilesInWindow) just means there's nothing to replay.
+func (r *Replayer) Replay(ctx context.Context, source Source) (Result, error) {
+	result := Result{Statuses: make(map[int]int64)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, r.concurrency)
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	var first time.Time
+	start := time.Now()
+	err := source(ctx, func(entry logging.LogEntry) error {
+		if entry.Method != http.MethodGet || !strings.HasPrefix(entry.Path, "/") {
+			result.Skipped++
+			return nil
+		}
+		if first.IsZero() {
+			first, start = entry.Time, time.Now()
+		}
+		var due time.Time
+		if r.speed > 0 {
+			due = start.Add(time.Duration(float64(entry.Time.Sub(first)) / r.speed))
+			if wait := time.Until(due); wait > 0 {
+				timer.Reset(wait)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case inFlight <- struct{}{}:
+		}
+
+		mu.Lock()
+		result.Sent++
+		if lag := time.Since(due); !due.IsZero() && lag > result.MaxLag {
+			result.MaxLag = lag
+		}
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			status, err := r.send(ctx, entry)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed++
+				return
+			}
+			result.Statuses[status]++
+		}()
+		return nil
+	})
+	wg.Wait()
+	result.Duration = time.Since(start)
+	if errors.Is(err, logging.ErrNoFilesInWindow) {
+		err = nil
+	}
+	return result, err
+}
+
+// send sends the GET request of a given log entry to the target, returning the status of the response.
+// The user agent and the referer of the log entry are sent along, when logged.
+func (r *Replayer) send(ctx context.Context, entry logging.LogEntry) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.target+entry.Path, nil)
+	if err != nil {
+		return 0, err
+	}
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	if entry.UserAgent != "" && entry.UserAgent != "-" {
+		req.Header.Set("User-Agent", entry.UserAgent)
+	}
+	if entry.Referer != "" && entry.Referer != "-" {
+		req.Header.Set("Referer", entry.Referer)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// the body is drained so the connection is reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
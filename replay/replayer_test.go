@@ -0,0 +1,170 @@
+package replay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// request is a request received by the target of the tests.
+type request struct {
+	path, agent, header string
+	at                  time.Time
+}
+
+type replayerSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	mu       sync.Mutex
+	requests []request
+	logTime  time.Time
+}
+
+func (s *replayerSuite) SetupTest() {
+	s.requests = nil
+	s.logTime = time.Date(2022, time.March, 3, 2, 45, 0, 0, time.UTC)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, request{path: r.URL.RequestURI(), agent: r.UserAgent(), header: r.Header.Get("X-Replay"), at: time.Now()})
+		s.mu.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func (s *replayerSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// source returns a Source calling the function for the given log entries.
+func source(entries ...logging.LogEntry) Source {
+	return func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (s *replayerSuite) received() []request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]request(nil), s.requests...)
+}
+
+func (s *replayerSuite) Test_Replay() {
+	r, err := New(s.server.URL+"/", WithSpeed(0), WithConcurrency(1), WithHeader("X-Replay", "yes"))
+	s.Require().NoError(err)
+
+	result, err := r.Replay(context.Background(), source(
+		logging.LogEntry{Method: "GET", Path: "/a?page=2", UserAgent: "curl/7.79.1", Time: s.logTime},
+		logging.LogEntry{Method: "POST", Path: "/login", Time: s.logTime},
+		logging.LogEntry{Method: "GET", Path: "/missing", UserAgent: "-", Time: s.logTime.Add(time.Second)},
+		logging.LogEntry{Invalid: true},
+	))
+
+	s.Require().NoError(err)
+	s.Equal(int64(2), result.Sent)
+	s.Equal(int64(2), result.Skipped)
+	s.Zero(result.Failed)
+	s.Equal(map[int]int64{200: 1, 404: 1}, result.Statuses)
+	requests := s.received()
+	s.Require().Len(requests, 2)
+	s.Equal("/a?page=2", requests[0].path)
+	s.Equal("curl/7.79.1", requests[0].agent)
+	s.Equal("yes", requests[0].header)
+	s.Equal("/missing", requests[1].path)
+	s.NotEqual("-", requests[1].agent)
+}
+
+func (s *replayerSuite) Test_Replay_Speed() {
+	r, err := New(s.server.URL, WithSpeed(10))
+	s.Require().NoError(err)
+
+	// 2 seconds apart, replayed 10 times faster
+	result, err := r.Replay(context.Background(), source(
+		logging.LogEntry{Method: "GET", Path: "/a", Time: s.logTime},
+		logging.LogEntry{Method: "GET", Path: "/b", Time: s.logTime.Add(2 * time.Second)},
+	))
+
+	s.Require().NoError(err)
+	s.Equal(int64(2), result.Sent)
+	requests := s.received()
+	s.Require().Len(requests, 2)
+	s.InDelta(200*time.Millisecond, requests[1].at.Sub(requests[0].at), float64(100*time.Millisecond))
+	s.GreaterOrEqual(result.Duration, 200*time.Millisecond)
+}
+
+func (s *replayerSuite) Test_Replay_Failed() {
+	r, err := New("http://127.0.0.1:1", WithSpeed(0))
+	s.Require().NoError(err)
+
+	result, err := r.Replay(context.Background(), source(logging.LogEntry{Method: "GET", Path: "/a"}))
+
+	s.Require().NoError(err)
+	s.Equal(int64(1), result.Sent)
+	s.Equal(int64(1), result.Failed)
+	s.Empty(result.Statuses)
+}
+
+func (s *replayerSuite) Test_Replay_Cancelled() {
+	r, err := New(s.server.URL)
+	s.Require().NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	// the second request is due in an hour
+	result, err := r.Replay(ctx, source(
+		logging.LogEntry{Method: "GET", Path: "/a", Time: s.logTime},
+		logging.LogEntry{Method: "GET", Path: "/b", Time: s.logTime.Add(time.Hour)},
+	))
+
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(int64(1), result.Sent)
+	s.Len(s.received(), 1)
+}
+
+func (s *replayerSuite) Test_Replay_NoFilesInWindow() {
+	r, err := New(s.server.URL)
+	s.Require().NoError(err)
+
+	result, err := r.Replay(context.Background(), func(context.Context, func(logging.LogEntry) error) error {
+		return logging.ErrNoFilesInWindow
+	})
+
+	s.NoError(err)
+	s.Zero(result.Sent)
+}
+
+func (s *replayerSuite) Test_New_Invalid() {
+	for _, test := range []struct {
+		name     string
+		target   string
+		opts     []Option
+		expected string
+	}{
+		{name: "Scheme", target: "ftp://staging", expected: "target: must be an http or https URL"},
+		{name: "Host", target: "/staging", expected: "target: must be an http or https URL"},
+		{name: "Speed", target: "http://staging", opts: []Option{WithSpeed(-1)}, expected: "speed: must not be negative"},
+		{name: "Concurrency", target: "http://staging", opts: []Option{WithConcurrency(0)}, expected: "concurrency: must be positive"},
+	} {
+		s.Run(test.name, func() {
+			_, err := New(test.target, test.opts...)
+			s.EqualError(err, test.expected)
+		})
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	suite.Run(t, new(replayerSuite))
+}
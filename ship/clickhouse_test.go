@@ -0,0 +1,137 @@
+package ship
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type clickhouseSuite struct {
+	suite.Suite
+	entries []logging.LogEntry
+}
+
+func (s *clickhouseSuite) SetupSuite() {
+	s.entries = []logging.LogEntry{
+		{Line: "a", Time: time.Date(2022, 3, 3, 2, 44, 10, 500000000, time.FixedZone("CET", 3600)), IP: "10.0.0.1", Method: "GET", Path: "/a", Status: 200, Size: 20, Duration: 1500 * time.Microsecond, File: "access.log", Offset: 0},
+		{Line: "b", Time: time.Date(2022, 3, 3, 2, 44, 11, 0, time.UTC), IP: "10.0.0.2", Method: "POST", Path: "/b", Status: 500, File: "access.log", Offset: 2},
+	}
+}
+
+// insertServer starts a server answering the inserts with a given status and body,
+// recording the query, the credentials and the rows it receives.
+func (s *clickhouseSuite) insertServer(status int, body string, received *http.Request, rows *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPost, r.Method)
+		*received = *r
+		scanner := bufio.NewScanner(r.Body)
+		s.Require().True(scanner.Scan())
+		s.Equal("INSERT INTO logs.access_logs ("+clickhouseColumns+") FORMAT JSONEachRow", scanner.Text())
+		for scanner.Scan() {
+			var row map[string]interface{}
+			s.NoError(json.Unmarshal(scanner.Bytes(), &row))
+			*rows = append(*rows, row)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func (s *clickhouseSuite) Test_Write() {
+	var req http.Request
+	var rows []map[string]interface{}
+	server := s.insertServer(http.StatusOK, "", &req, &rows)
+	defer server.Close()
+	ch, err := NewClickHouse("http://default:secret@"+server.Listener.Addr().String()+"/analytics", "logs.access_logs")
+	s.Require().NoError(err)
+
+	err = ch.Write(context.Background(), s.entries)
+
+	s.NoError(err)
+	s.Equal("analytics", req.URL.Query().Get("database"))
+	s.Equal("1", req.URL.Query().Get("input_format_skip_unknown_fields"))
+	s.Equal("default", req.Header.Get("X-ClickHouse-User"))
+	s.Equal("secret", req.Header.Get("X-ClickHouse-Key"))
+	s.Require().Len(rows, 2)
+	s.Equal("2022-03-03T01:44:10.500000Z", rows[0]["time"])
+	s.Equal("10.0.0.1", rows[0]["ip"])
+	s.Equal("GET", rows[0]["method"])
+	s.Equal(float64(20), rows[0]["size"])
+	s.Equal(float64(1500), rows[0]["duration_us"])
+	s.Equal("access.log", rows[0]["file"])
+	s.Equal(float64(500), rows[1]["status"])
+	s.Equal(float64(2), rows[1]["offset"])
+	s.Equal("b", rows[1]["line"])
+	s.NoError(ch.Close())
+}
+
+func (s *clickhouseSuite) Test_Write_Errors() {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		err       string
+		permanent bool
+	}{
+		{
+			name:   "server error",
+			status: http.StatusServiceUnavailable,
+			body:   "Code: 202. DB::Exception: Too many simultaneous queries",
+			err:    "clickhouse insert failed: 503 Service Unavailable: Code: 202. DB::Exception: Too many simultaneous queries",
+		},
+		{
+			name:      "unknown table",
+			status:    http.StatusNotFound,
+			body:      "Code: 60. DB::Exception: Table logs.access_logs doesn't exist",
+			err:       "clickhouse insert failed: 404 Not Found: Code: 60. DB::Exception: Table logs.access_logs doesn't exist",
+			permanent: true,
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			var req http.Request
+			var rows []map[string]interface{}
+			server := s.insertServer(test.status, test.body, &req, &rows)
+			defer server.Close()
+			ch, err := NewClickHouse(server.URL, "logs.access_logs")
+			s.Require().NoError(err)
+
+			err = ch.Write(context.Background(), s.entries)
+
+			s.EqualError(err, test.err)
+			var permanent *permanentError
+			s.Equal(test.permanent, errors.As(err, &permanent))
+		})
+	}
+}
+
+func (s *clickhouseSuite) Test_NewClickHouse_Invalid() {
+	tests := []struct {
+		dsn   string
+		table string
+		err   string
+	}{
+		{dsn: "clickhouse://localhost:9000", table: "access_logs", err: "invalid clickhouse dsn 'clickhouse://localhost:9000': the scheme must be http or https (HTTP interface)"},
+		{dsn: "http:///logs", table: "access_logs", err: "invalid clickhouse dsn 'http:///logs': host is required"},
+		{dsn: "http://localhost:8123", table: "access_logs; DROP TABLE x", err: "invalid clickhouse table 'access_logs; DROP TABLE x'"},
+	}
+	for _, test := range tests {
+		s.Run(test.dsn, func() {
+			_, err := NewClickHouse(test.dsn, test.table)
+			s.EqualError(err, test.err)
+		})
+	}
+}
+
+func TestClickHouse(t *testing.T) {
+	suite.Run(t, new(clickhouseSuite))
+}
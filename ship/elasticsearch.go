@@ -0,0 +1,239 @@
+package ship
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// jodaLayouts maps the Joda date format patterns (used by Logstash and Beats index names)
+// to their Go time layout, longest first.
+var jodaLayouts = []struct {
+	pattern string
+	layout  string
+}{
+	{"yyyy", "2006"},
+	{"yy", "06"},
+	{"MM", "01"},
+	{"dd", "02"},
+	{"HH", "15"},
+	{"mm", "04"},
+	{"ss", "05"},
+}
+
+// Elasticsearch is a Sink indexing the log entries into Elasticsearch or OpenSearch using the bulk API.
+// Every log entry is indexed as its JSON (see logging.LogEntry) along with an @timestamp,
+// under an id derived from its file, offset and line, so retried batches don't duplicate documents.
+type Elasticsearch struct {
+	url    string
+	index  func(time.Time) string
+	client *http.Client
+}
+
+// NewElasticsearch creates an Elasticsearch sink for a given URL (e.g. http://user:password@es:9200)
+// and index name, which may contain the date of the log entries the way Logstash does,
+// e.g. apache-%{+yyyy.MM.dd} for daily indices (in UTC).
+func NewElasticsearch(url, index string) (*Elasticsearch, error) {
+	if url == "" {
+		return nil, fmt.Errorf("invalid elasticsearch url: must not be empty")
+	}
+	indexFn, err := parseIndex(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Elasticsearch{
+		url:    strings.TrimSuffix(url, "/") + "/_bulk",
+		index:  indexFn,
+		client: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// esDocument is the document indexed for a log entry.
+type esDocument struct {
+	Timestamp time.Time `json:"@timestamp"`
+	logging.LogEntry
+}
+
+// bulkResponse is the part of the response of the bulk API telling which documents failed.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// Write indexes a batch of log entries with a single bulk request.
+// Rejected requests and documents (429 Too Many Requests or server errors) are worth retrying,
+// while the other failures (e.g. mapping errors) are permanent.
+func (es *Elasticsearch) Write(ctx context.Context, entries []logging.LogEntry) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, entry := range entries {
+		action := map[string]map[string]string{"index": {"_index": es.index(entry.Time), "_id": documentID(entry)}}
+		if err := enc.Encode(action); err != nil {
+			return Permanent(err)
+		}
+		if err := enc.Encode(esDocument{Timestamp: entry.Time, LogEntry: entry}); err != nil {
+			return Permanent(err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, es.url, &body)
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := es.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("elasticsearch bulk request failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+		if retryableStatus(resp.StatusCode) {
+			return err
+		}
+		return Permanent(err)
+	}
+
+	var bulk bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&bulk); err != nil {
+		return fmt.Errorf("could not read elasticsearch bulk response: %w", err)
+	}
+	if !bulk.Errors {
+		return nil
+	}
+	return bulkError(bulk)
+}
+
+// Close closes the idle connections to Elasticsearch.
+func (es *Elasticsearch) Close() error {
+	es.client.CloseIdleConnections()
+	return nil
+}
+
+// bulkError returns the error of the documents of a bulk response which failed,
+// permanent unless some of them are worth retrying.
+func bulkError(bulk bulkResponse) error {
+	var failed, retryable int
+	var first json.RawMessage
+	for _, item := range bulk.Items {
+		for _, result := range item {
+			if result.Status < 300 {
+				continue
+			}
+			failed++
+			if retryableStatus(result.Status) {
+				retryable++
+			}
+			if first == nil {
+				first = result.Error
+			}
+		}
+	}
+
+	err := fmt.Errorf("elasticsearch failed to index %d of %d documents: %s", failed, len(bulk.Items), first)
+	if retryable > 0 {
+		return err
+	}
+	return Permanent(err)
+}
+
+// retryableStatus checks whether a request answered with a given status code is worth retrying.
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// documentID returns the id of the document of a given log entry, the same every time it's shipped.
+func documentID(entry logging.LogEntry) string {
+	h := sha1.New()
+	_, _ = io.WriteString(h, entry.File+"\x00"+strconv.FormatInt(entry.Offset, 10)+"\x00"+entry.Line)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// indexPart is a part of an index name, either literal text or the date of the log entries.
+type indexPart struct {
+	literal string
+	layout  string
+}
+
+// parseIndex parses an index name containing dates such as %{+yyyy.MM.dd} into a function
+// returning the index name of a given time.
+func parseIndex(index string) (func(time.Time) string, error) {
+	if index == "" {
+		return nil, fmt.Errorf("invalid elasticsearch index: must not be empty")
+	}
+
+	var parts []indexPart
+	rest := index
+	for {
+		start := strings.Index(rest, "%{+")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid elasticsearch index '%s': unterminated date", index)
+		}
+		layout, err := jodaToLayout(rest[start+3 : start+end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid elasticsearch index '%s': %v", index, err)
+		}
+		parts = append(parts, indexPart{literal: rest[:start]}, indexPart{layout: layout})
+		rest = rest[start+end+1:]
+	}
+	parts = append(parts, indexPart{literal: rest})
+
+	return func(t time.Time) string {
+		var sb strings.Builder
+		for _, part := range parts {
+			if part.layout != "" {
+				sb.WriteString(t.UTC().Format(part.layout))
+			} else {
+				sb.WriteString(part.literal)
+			}
+		}
+		return sb.String()
+	}, nil
+}
+
+// jodaToLayout converts a Joda date format (e.g. yyyy.MM.dd) into a Go time layout (e.g. 2006.01.02).
+func jodaToLayout(format string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); {
+		c := format[i]
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+
+		found := false
+		for _, joda := range jodaLayouts {
+			if strings.HasPrefix(format[i:], joda.pattern) {
+				sb.WriteString(joda.layout)
+				i += len(joda.pattern)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("unsupported date format '%s'", format)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,152 @@
+package ship
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type elasticsearchSuite struct {
+	suite.Suite
+	entries []logging.LogEntry
+}
+
+func (s *elasticsearchSuite) SetupSuite() {
+	s.entries = []logging.LogEntry{
+		{Line: "a", Time: time.Date(2022, 3, 3, 23, 59, 0, 0, time.UTC), IP: "10.0.0.1", Path: "/a", Status: 200, File: "access.log", Offset: 0},
+		{Line: "b", Time: time.Date(2022, 3, 4, 0, 1, 0, 0, time.UTC), IP: "10.0.0.2", Path: "/b", Status: 500, File: "access.log", Offset: 2},
+	}
+}
+
+// bulkServer starts a server answering the bulk requests with a given status and body,
+// recording the actions and documents it receives.
+func (s *elasticsearchSuite) bulkServer(status int, body string, lines *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPost, r.Method)
+		s.Equal("/_bulk", r.URL.Path)
+		s.Equal("application/x-ndjson", r.Header.Get("Content-Type"))
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			s.NoError(json.Unmarshal(scanner.Bytes(), &line))
+			*lines = append(*lines, line)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func (s *elasticsearchSuite) Test_Write() {
+	var lines []map[string]interface{}
+	server := s.bulkServer(http.StatusOK, `{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`, &lines)
+	defer server.Close()
+	es, err := NewElasticsearch(server.URL+"/", "apache-%{+yyyy.MM.dd}")
+	s.Require().NoError(err)
+
+	err = es.Write(context.Background(), s.entries)
+
+	s.NoError(err)
+	s.Require().Len(lines, 4)
+	s.Equal("apache-2022.03.03", lines[0]["index"].(map[string]interface{})["_index"])
+	s.Equal("/a", lines[1]["path"])
+	s.Equal("2022-03-03T23:59:00Z", lines[1]["@timestamp"])
+	s.Equal("apache-2022.03.04", lines[2]["index"].(map[string]interface{})["_index"])
+	s.Equal("/b", lines[3]["path"])
+	s.Equal(float64(500), lines[3]["status"])
+	// the ids are the same every time, and different for every entry
+	s.Equal(documentID(s.entries[0]), lines[0]["index"].(map[string]interface{})["_id"])
+	s.NotEqual(documentID(s.entries[0]), documentID(s.entries[1]))
+	s.NoError(es.Close())
+}
+
+func (s *elasticsearchSuite) Test_Write_Errors() {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		err       string
+		permanent bool
+	}{
+		{
+			name:   "rejected request",
+			status: http.StatusTooManyRequests,
+			body:   `{"error":"too many requests"}`,
+			err:    `elasticsearch bulk request failed: 429 Too Many Requests: {"error":"too many requests"}`,
+		},
+		{
+			name:      "bad request",
+			status:    http.StatusBadRequest,
+			body:      `{"error":"bad request"}`,
+			err:       `elasticsearch bulk request failed: 400 Bad Request: {"error":"bad request"}`,
+			permanent: true,
+		},
+		{
+			name:   "rejected documents",
+			status: http.StatusOK,
+			body:   `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}}]}`,
+			err:    `elasticsearch failed to index 1 of 2 documents: {"type":"es_rejected_execution_exception"}`,
+		},
+		{
+			name:      "mapping error",
+			status:    http.StatusOK,
+			body:      `{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}},{"index":{"status":201}}]}`,
+			err:       `elasticsearch failed to index 1 of 2 documents: {"type":"mapper_parsing_exception"}`,
+			permanent: true,
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			var lines []map[string]interface{}
+			server := s.bulkServer(test.status, test.body, &lines)
+			defer server.Close()
+			es, err := NewElasticsearch(server.URL, "apache")
+			s.Require().NoError(err)
+
+			err = es.Write(context.Background(), s.entries)
+
+			s.EqualError(err, test.err)
+			var permanent *permanentError
+			s.Equal(test.permanent, errors.As(err, &permanent))
+		})
+	}
+}
+
+func (s *elasticsearchSuite) Test_ParseIndex() {
+	t := time.Date(2022, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		index string
+		name  string
+		err   string
+	}{
+		{index: "apache", name: "apache"},
+		{index: "apache-%{+yyyy.MM.dd}", name: "apache-2022.03.04"},
+		{index: "%{+yy}-apache-%{+MM-dd.HH:mm:ss}", name: "22-apache-03-04.04:06:07"},
+		{index: "", err: "invalid elasticsearch index: must not be empty"},
+		{index: "apache-%{+yyyy", err: "invalid elasticsearch index 'apache-%{+yyyy': unterminated date"},
+		{index: "apache-%{+YYYY.ww}", err: "invalid elasticsearch index 'apache-%{+YYYY.ww}': unsupported date format 'YYYY.ww'"},
+	}
+	for _, test := range tests {
+		s.Run(test.index, func() {
+			index, err := parseIndex(test.index)
+			if test.err != "" {
+				s.EqualError(err, test.err)
+				return
+			}
+			s.Require().NoError(err)
+			s.Equal(test.name, index(t))
+		})
+	}
+}
+
+func TestElasticsearch(t *testing.T) {
+	suite.Run(t, new(elasticsearchSuite))
+}
@@ -0,0 +1,119 @@
+package ship
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+func init() {
+	logging.RegisterSink("elasticsearch", func(target string) (logging.Sink, error) {
+		// the target is the URL of the cluster, optionally followed by a comma and the index
+		url, index := target, "apache-%{+yyyy.MM.dd}"
+		if i := strings.LastIndexByte(target, ','); i >= 0 {
+			url, index = target[:i], target[i+1:]
+		}
+		es, err := NewElasticsearch(url, index)
+		if err != nil {
+			return nil, err
+		}
+		return NewEntrySink(es, DefaultBatchSize), nil
+	})
+	logging.RegisterSink("kafka", func(target string) (logging.Sink, error) {
+		cfg, err := ParseKafkaConfig(target)
+		if err != nil {
+			return nil, err
+		}
+		kafka, err := NewKafka(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewEntrySink(kafka, DefaultBatchSize), nil
+	})
+	logging.RegisterSink("loki", func(target string) (logging.Sink, error) {
+		loki, err := NewLoki(target, nil)
+		if err != nil {
+			return nil, err
+		}
+		return NewEntrySink(loki, DefaultBatchSize), nil
+	})
+}
+
+// EntrySink is a logging.Sink writing the log entries to a Sink in batches of a given size,
+// the last one when flushed, e.g. to route the log entries of a window to Elasticsearch (see logging.Logs.Route).
+// Unlike a Shipper, it doesn't retry the failed batches.
+type EntrySink struct {
+	sink      Sink
+	batchSize int
+	batch     []logging.LogEntry
+}
+
+// NewEntrySink creates an EntrySink writing to a given Sink in batches of a given size, DefaultBatchSize when not positive.
+func NewEntrySink(sink Sink, batchSize int) *EntrySink {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &EntrySink{sink: sink, batchSize: batchSize, batch: make([]logging.LogEntry, 0, batchSize)}
+}
+
+// Write buffers a given log entry, writing the batch once full.
+func (s *EntrySink) Write(ctx context.Context, entry logging.LogEntry) error {
+	s.batch = append(s.batch, entry)
+	if len(s.batch) < s.batchSize {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+// Flush writes the buffered log entries, if any. They are dropped even when failing to be written.
+func (s *EntrySink) Flush(ctx context.Context) error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	err := s.sink.Write(ctx, s.batch)
+	s.batch = s.batch[:0]
+	return err
+}
+
+// Close flushes the buffered log entries and closes the Sink.
+func (s *EntrySink) Close() error {
+	err := s.Flush(context.Background())
+	if closeErr := s.sink.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// batchSink is a Sink writing the batches to a logging.Sink one log entry at a time, flushing it after every batch.
+type batchSink struct {
+	sink logging.Sink
+}
+
+// NewBatchSink creates a Sink writing to a given logging.Sink, e.g. a custom sink registered with logging.RegisterSink,
+// so a Shipper can ship to it. It's closed along with the Sink when it implements io.Closer.
+// A failed batch may have been partly written before being retried.
+func NewBatchSink(sink logging.Sink) Sink {
+	return batchSink{sink: sink}
+}
+
+func (s batchSink) Write(ctx context.Context, entries []logging.LogEntry) error {
+	for _, entry := range entries {
+		if err := s.sink.Write(ctx, entry); err != nil {
+			return err
+		}
+	}
+	if err := s.sink.Flush(ctx); err != nil {
+		return fmt.Errorf("could not flush sink: %w", err)
+	}
+	return nil
+}
+
+func (s batchSink) Close() error {
+	if closer, ok := s.sink.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
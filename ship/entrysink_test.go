@@ -0,0 +1,74 @@
+package ship
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// the built-in sinks can all be routed to
+var (
+	_ logging.Sink = (*EntrySink)(nil)
+	_ Sink         = (*Loki)(nil)
+)
+
+type entrySinkSuite struct {
+	suite.Suite
+}
+
+func (s *entrySinkSuite) Test_EntrySink() {
+	sink := &fakeSink{}
+	entries := NewEntrySink(sink, 2)
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		s.Require().NoError(entries.Write(context.Background(), logging.LogEntry{Path: path}))
+	}
+	s.Equal([][]string{{"/a", "/b"}}, sink.written())
+	s.Require().NoError(entries.Flush(context.Background()))
+	s.Equal([][]string{{"/a", "/b"}, {"/c"}}, sink.written())
+	// nothing left to write
+	s.Require().NoError(entries.Close())
+	s.Equal(2, sink.writes)
+	s.True(sink.closed)
+}
+
+func (s *entrySinkSuite) Test_EntrySink_Error() {
+	sink := &fakeSink{errs: []error{errors.New("unavailable")}}
+	entries := NewEntrySink(sink, 0)
+
+	s.Require().NoError(entries.Write(context.Background(), logging.LogEntry{Path: "/a"}))
+	s.EqualError(entries.Flush(context.Background()), "unavailable")
+	// the failed batch is dropped
+	s.NoError(entries.Flush(context.Background()))
+	s.Equal(1, sink.writes)
+}
+
+func (s *entrySinkSuite) Test_BatchSink() {
+	sink := &fakeSink{}
+	batches := NewBatchSink(NewEntrySink(sink, 10))
+	shipper := NewShipper(batches, WithBatchSize(2))
+
+	s.Require().NoError(shipper.Ship(context.Background(), sliceSource("/a", "/b", "/c")))
+	s.Require().NoError(batches.Close())
+
+	// every batch of the shipper is flushed
+	s.Equal([][]string{{"/a", "/b"}, {"/c"}}, sink.written())
+	s.True(sink.closed)
+}
+
+func (s *entrySinkSuite) Test_RegisteredSinks() {
+	s.Subset(logging.Sinks(), []string{"elasticsearch", "file", "kafka", "loki", "stdout"})
+	sink, err := logging.NewSink("elasticsearch", "http://es:9200,apache")
+	s.Require().NoError(err)
+	s.Equal("http://es:9200/_bulk", sink.(*EntrySink).sink.(*Elasticsearch).url)
+	_, err = logging.NewSink("loki", "")
+	s.EqualError(err, "invalid loki url: must not be empty")
+}
+
+func TestEntrySink(t *testing.T) {
+	suite.Run(t, new(entrySinkSuite))
+}
@@ -0,0 +1,142 @@
+package ship
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+const (
+	// kafkaClientID identifies the producer to the brokers.
+	kafkaClientID = "apache-log-reader"
+	// kafkaTimeout bounds connecting and every request to a broker.
+	kafkaTimeout = 30 * time.Second
+	// kafkaLinger is how long the records of a partition are held before being published: the entries come
+	// in batches already (see EntrySink), so they're published right away rather than after the default second.
+	kafkaLinger = 10 * time.Millisecond
+)
+
+// KafkaConfig configures a Kafka sink.
+type KafkaConfig struct {
+	// Brokers are the addresses (host:port) of the brokers to bootstrap from.
+	Brokers []string
+	Topic   string
+}
+
+// ParseKafkaConfig parses a Kafka sink specification such as brokers=kafka1:9092,kafka2:9092,topic=access-logs,
+// the values without a key being more brokers.
+func ParseKafkaConfig(spec string) (KafkaConfig, error) {
+	var cfg KafkaConfig
+	key := ""
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		value := part
+		if i := strings.Index(part, "="); i >= 0 {
+			key, value = part[:i], part[i+1:]
+		}
+
+		switch {
+		case value == "":
+			return cfg, fmt.Errorf("invalid kafka '%s': empty value", spec)
+		case key == "brokers":
+			cfg.Brokers = append(cfg.Brokers, value)
+		case key == "topic" && cfg.Topic == "":
+			cfg.Topic = value
+		default:
+			return cfg, fmt.Errorf("invalid kafka '%s': unexpected '%s'", spec, part)
+		}
+	}
+
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return cfg, fmt.Errorf("invalid kafka '%s': brokers and topic are required", spec)
+	}
+	return cfg, nil
+}
+
+// Kafka is a Sink publishing every log entry as a JSON record (see logging.LogEntry) to a Kafka topic,
+// keyed by its client IP: the partition of a record is the one the Java client picks for its key,
+// so the requests of a client stay in order. The records are acknowledged by all the in-sync replicas.
+// Retried batches may publish some records twice (at-least-once delivery).
+type Kafka struct {
+	cfg    KafkaConfig
+	writer *kafka.Writer
+}
+
+// NewKafka creates a Kafka sink for a given configuration, connecting to the brokers on the first write.
+func NewKafka(cfg KafkaConfig) (*Kafka, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, errors.New("invalid kafka: brokers and topic are required")
+	}
+	return &Kafka{
+		cfg: cfg,
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(cfg.Brokers...),
+			Topic:    cfg.Topic,
+			Balancer: &kafka.Murmur2Balancer{},
+			// the failed batches are retried by the shipper, see Sink
+			MaxAttempts:            1,
+			BatchSize:              DefaultBatchSize,
+			BatchTimeout:           kafkaLinger,
+			ReadTimeout:            kafkaTimeout,
+			WriteTimeout:           kafkaTimeout,
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			Transport:              &kafka.Transport{ClientID: kafkaClientID, DialTimeout: kafkaTimeout},
+		},
+	}, nil
+}
+
+// Write publishes a batch of log entries, waiting for all the in-sync replicas to acknowledge them.
+func (k *Kafka) Write(ctx context.Context, entries []logging.LogEntry) error {
+	messages := make([]kafka.Message, 0, len(entries))
+	for _, entry := range entries {
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return Permanent(err)
+		}
+		message := kafka.Message{Value: value, Time: entry.Time}
+		if entry.Time.IsZero() {
+			message.Time = time.Now()
+		}
+		if entry.IP != "" {
+			message.Key = []byte(entry.IP)
+		}
+		messages = append(messages, message)
+	}
+	return k.classify(k.writer.WriteMessages(ctx, messages...))
+}
+
+// Close waits for the records in flight, if any, and closes the connections to the brokers.
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
+
+// classify returns the first error of the records of a batch, permanent unless worth retrying:
+// the errors of the brokers telling so (e.g. a partition moving to another leader), and the ones of the network.
+func (k *Kafka) classify(err error) error {
+	var writeErrors kafka.WriteErrors
+	if errors.As(err, &writeErrors) {
+		for _, recordErr := range writeErrors {
+			if recordErr != nil {
+				err = recordErr
+				break
+			}
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("could not publish to kafka topic %s: %w", k.cfg.Topic, err)
+	var code kafka.Error
+	if errors.As(err, &code) && !code.Temporary() {
+		return Permanent(err)
+	}
+	return err
+}
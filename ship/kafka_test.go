@@ -0,0 +1,205 @@
+package ship
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	metadataAPI "github.com/segmentio/kafka-go/protocol/metadata"
+	produceAPI "github.com/segmentio/kafka-go/protocol/produce"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// fakeBroker is a single Kafka broker leading all the partitions of a topic, answering the metadata
+// and produce requests of the Kafka sink in place of its transport (see kafka.RoundTripper).
+type fakeBroker struct {
+	topic      string
+	partitions int32
+
+	mu      sync.Mutex
+	records map[int32][]fakeRecord
+	// produceErrors are the error codes of the following produce requests
+	produceErrors []kafka.Error
+}
+
+// fakeRecord is a record published to the fakeBroker.
+type fakeRecord struct {
+	key, value []byte
+	time       time.Time
+}
+
+func (b *fakeBroker) RoundTrip(_ context.Context, _ net.Addr, req kafka.Request) (kafka.Response, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch req := req.(type) {
+	case *metadataAPI.Request:
+		topic := metadataAPI.ResponseTopic{Name: b.topic}
+		for p := int32(0); p < b.partitions; p++ {
+			topic.Partitions = append(topic.Partitions, metadataAPI.ResponsePartition{PartitionIndex: p, LeaderID: 1})
+		}
+		return &metadataAPI.Response{Brokers: []metadataAPI.ResponseBroker{{NodeID: 1, Host: "127.0.0.1", Port: 9092}}, Topics: []metadataAPI.ResponseTopic{topic}}, nil
+	case *produceAPI.Request:
+		var code kafka.Error
+		if len(b.produceErrors) > 0 {
+			code, b.produceErrors = b.produceErrors[0], b.produceErrors[1:]
+		}
+		resp := &produceAPI.Response{}
+		for _, topic := range req.Topics {
+			respTopic := produceAPI.ResponseTopic{Topic: topic.Topic}
+			for _, partition := range topic.Partitions {
+				records, err := readRecords(partition.RecordSet.Records)
+				if err != nil {
+					return nil, err
+				}
+				if code == 0 {
+					b.records[partition.Partition] = append(b.records[partition.Partition], records...)
+				}
+				respTopic.Partitions = append(respTopic.Partitions, produceAPI.ResponsePartition{Partition: partition.Partition, ErrorCode: int16(code)})
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		return resp, nil
+	}
+	return nil, errors.New("unexpected kafka request")
+}
+
+// readRecords reads the records of a produce request.
+func readRecords(reader protocol.RecordReader) ([]fakeRecord, error) {
+	var records []fakeRecord
+	for {
+		record, err := reader.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		key, err := protocol.ReadAll(record.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := protocol.ReadAll(record.Value)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fakeRecord{key: key, value: value, time: record.Time})
+	}
+}
+
+func (b *fakeBroker) published() map[int32][]fakeRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	published := make(map[int32][]fakeRecord, len(b.records))
+	for id, records := range b.records {
+		published[id] = append([]fakeRecord(nil), records...)
+	}
+	return published
+}
+
+type kafkaSuite struct {
+	suite.Suite
+	broker *fakeBroker
+	kafka  *Kafka
+}
+
+func (s *kafkaSuite) SetupTest() {
+	s.broker = &fakeBroker{topic: "access-logs", partitions: 3, records: make(map[int32][]fakeRecord)}
+	var err error
+	s.kafka, err = NewKafka(KafkaConfig{Brokers: []string{"kafka1:9092"}, Topic: "access-logs"})
+	s.Require().NoError(err)
+	s.kafka.writer.Transport = s.broker
+}
+
+func (s *kafkaSuite) TearDownTest() {
+	s.NoError(s.kafka.Close())
+}
+
+func (s *kafkaSuite) entries() []logging.LogEntry {
+	t := time.Date(2022, 3, 3, 2, 44, 10, 0, time.UTC)
+	return []logging.LogEntry{
+		{Line: "a", Time: t, IP: "10.0.0.1", Path: "/a", Status: 200},
+		{Line: "b", Time: t.Add(time.Second), IP: "10.0.0.2", Path: "/b", Status: 500},
+		{Line: "c", Time: t.Add(2 * time.Second), IP: "10.0.0.1", Path: "/c", Status: 200},
+	}
+}
+
+func (s *kafkaSuite) Test_Write() {
+	err := s.kafka.Write(context.Background(), s.entries())
+
+	s.Require().NoError(err)
+	var paths []string
+	partitions := make(map[string]int32)
+	for id, records := range s.broker.published() {
+		for _, record := range records {
+			var entry logging.LogEntry
+			s.Require().NoError(json.Unmarshal(record.value, &entry))
+			s.Equal(entry.IP, string(record.key))
+			s.True(entry.Time.Equal(record.time))
+			paths = append(paths, entry.Path)
+			partitions[entry.Path] = id
+		}
+	}
+	s.ElementsMatch([]string{"/a", "/b", "/c"}, paths)
+	// the records of a client are in the partition the Java client picks for its IP (murmur2), in order
+	s.Equal(map[string]int32{"/a": 1, "/b": 0, "/c": 1}, partitions)
+	var ordered []string
+	for _, record := range s.broker.published()[1] {
+		var entry logging.LogEntry
+		s.Require().NoError(json.Unmarshal(record.value, &entry))
+		ordered = append(ordered, entry.Path)
+	}
+	s.Equal([]string{"/a", "/c"}, ordered)
+}
+
+func (s *kafkaSuite) Test_Write_Retriable() {
+	s.broker.produceErrors = []kafka.Error{kafka.NotLeaderForPartition}
+
+	err := s.kafka.Write(context.Background(), s.entries())
+
+	s.Require().Error(err)
+	s.Contains(err.Error(), "could not publish to kafka topic access-logs: ")
+	s.ErrorIs(err, kafka.NotLeaderForPartition)
+	var permanent *permanentError
+	s.False(errors.As(err, &permanent))
+	s.NoError(s.kafka.Write(context.Background(), s.entries()))
+}
+
+func (s *kafkaSuite) Test_Write_Permanent() {
+	s.broker.produceErrors = []kafka.Error{kafka.MessageSizeTooLarge}
+
+	err := s.kafka.Write(context.Background(), s.entries())
+
+	var permanent *permanentError
+	s.True(errors.As(err, &permanent))
+	s.ErrorIs(err, kafka.MessageSizeTooLarge)
+}
+
+func (s *kafkaSuite) Test_ParseKafkaConfig() {
+	cfg, err := ParseKafkaConfig("brokers=kafka1:9092,kafka2:9092,topic=access-logs")
+	s.NoError(err)
+	s.Equal(KafkaConfig{Brokers: []string{"kafka1:9092", "kafka2:9092"}, Topic: "access-logs"}, cfg)
+
+	for spec, msg := range map[string]string{
+		"brokers=kafka1:9092":                "invalid kafka 'brokers=kafka1:9092': brokers and topic are required",
+		"topic=access-logs":                  "invalid kafka 'topic=access-logs': brokers and topic are required",
+		"brokers=kafka1:9092,topic=":         "invalid kafka 'brokers=kafka1:9092,topic=': empty value",
+		"brokers=kafka1:9092,topic=a,b":      "invalid kafka 'brokers=kafka1:9092,topic=a,b': unexpected 'b'",
+		"brokers=kafka1:9092,acks=1,topic=a": "invalid kafka 'brokers=kafka1:9092,acks=1,topic=a': unexpected 'acks=1'",
+	} {
+		_, err := ParseKafkaConfig(spec)
+		s.EqualError(err, msg)
+	}
+}
+
+func TestKafka(t *testing.T) {
+	suite.Run(t, new(kafkaSuite))
+}
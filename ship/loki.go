@@ -0,0 +1,140 @@
+package ship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// DefaultLokiJob is the job label of the log streams pushed to Loki, unless given other labels.
+const DefaultLokiJob = "apache"
+
+// Loki is a Sink pushing the raw log lines to Grafana Loki using its push API, one stream per log file (and level),
+// labelled with the given labels and the base name of the file (filename).
+type Loki struct {
+	url    string
+	labels map[string]string
+	client *http.Client
+}
+
+// NewLoki creates a Loki sink for a given URL (e.g. http://loki:3100) and the labels of the streams,
+// {job="apache"} when none are given.
+func NewLoki(url string, labels map[string]string) (*Loki, error) {
+	if url == "" {
+		return nil, fmt.Errorf("invalid loki url: must not be empty")
+	}
+	if len(labels) == 0 {
+		labels = map[string]string{"job": DefaultLokiJob}
+	}
+	for name := range labels {
+		if name == "" {
+			return nil, fmt.Errorf("invalid loki label: the name must not be empty")
+		}
+	}
+
+	return &Loki{
+		url:    strings.TrimSuffix(url, "/") + "/loki/api/v1/push",
+		labels: labels,
+		client: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// ParseLokiLabels parses the labels of the Loki streams, given as comma-separated name=value pairs, e.g. job=apache,env=prod.
+func ParseLokiLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid loki label '%s': must be name=value", pair)
+		}
+		labels[pair[:i]] = pair[i+1:]
+	}
+	return labels, nil
+}
+
+// lokiStream is a stream of the push request, its values being pairs of a timestamp in nanoseconds and a log line.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Write pushes a batch of log entries with a single request, the entries of each stream sorted by time.
+// Rejected requests (429 Too Many Requests or server errors) are worth retrying, while the other failures are permanent.
+func (l *Loki) Write(ctx context.Context, entries []logging.LogEntry) error {
+	body, err := json.Marshal(map[string][]lokiStream{"streams": l.streams(entries)})
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("loki push request failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+		if retryableStatus(resp.StatusCode) {
+			return err
+		}
+		return Permanent(err)
+	}
+	return nil
+}
+
+// streams groups a batch of log entries by log file into the streams of a push request,
+// and by severity when mapped (see logging.SeverityMap), labelled as their level.
+func (l *Loki) streams(entries []logging.LogEntry) []lokiStream {
+	sorted := make([]logging.LogEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	var streams []lokiStream
+	byFile := make(map[[2]string]int)
+	for _, entry := range sorted {
+		key := [2]string{entry.File, string(entry.Severity)}
+		i, ok := byFile[key]
+		if !ok {
+			labels := make(map[string]string, len(l.labels)+2)
+			for name, value := range l.labels {
+				labels[name] = value
+			}
+			labels["filename"] = filepath.Base(entry.File)
+			if entry.Severity != "" {
+				labels["level"] = string(entry.Severity)
+			}
+			i = len(streams)
+			byFile[key] = i
+			streams = append(streams, lokiStream{Stream: labels})
+		}
+		streams[i].Values = append(streams[i].Values, [2]string{strconv.FormatInt(entry.Time.UnixNano(), 10), entry.Line})
+	}
+	return streams
+}
+
+// Close closes the idle connections to Loki.
+func (l *Loki) Close() error {
+	l.client.CloseIdleConnections()
+	return nil
+}
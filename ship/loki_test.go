@@ -0,0 +1,137 @@
+package ship
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type lokiSuite struct {
+	suite.Suite
+	entries []logging.LogEntry
+}
+
+func (s *lokiSuite) SetupSuite() {
+	s.entries = []logging.LogEntry{
+		{Line: "b", Time: time.Date(2022, 3, 4, 0, 1, 0, 0, time.UTC), File: "/var/log/apache2/access.log"},
+		{Line: "a", Time: time.Date(2022, 3, 3, 23, 59, 0, 0, time.UTC), File: "/var/log/apache2/access.log"},
+		{Line: "c", Time: time.Date(2022, 3, 4, 0, 2, 0, 0, time.UTC), File: "/var/log/apache2/other_vhosts_access.log"},
+	}
+}
+
+// pushServer starts a server answering the push requests with a given status, recording the streams it receives.
+func (s *lokiSuite) pushServer(status int, streams *[]lokiStream) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal(http.MethodPost, r.Method)
+		s.Equal("/loki/api/v1/push", r.URL.Path)
+		s.Equal("application/json", r.Header.Get("Content-Type"))
+		var body struct {
+			Streams []lokiStream `json:"streams"`
+		}
+		s.NoError(json.NewDecoder(r.Body).Decode(&body))
+		*streams = append(*streams, body.Streams...)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("ingester unavailable"))
+	}))
+}
+
+func (s *lokiSuite) Test_Write() {
+	var streams []lokiStream
+	server := s.pushServer(http.StatusNoContent, &streams)
+	defer server.Close()
+	loki, err := NewLoki(server.URL+"/", map[string]string{"job": "web", "env": "prod"})
+	s.Require().NoError(err)
+
+	err = loki.Write(context.Background(), s.entries)
+
+	s.NoError(err)
+	s.Equal([]lokiStream{
+		{
+			Stream: map[string]string{"job": "web", "env": "prod", "filename": "access.log"},
+			Values: [][2]string{{"1646351940000000000", "a"}, {"1646352060000000000", "b"}},
+		},
+		{
+			Stream: map[string]string{"job": "web", "env": "prod", "filename": "other_vhosts_access.log"},
+			Values: [][2]string{{"1646352120000000000", "c"}},
+		},
+	}, streams)
+	s.NoError(loki.Close())
+}
+
+func (s *lokiSuite) Test_Write_Levels() {
+	var streams []lokiStream
+	server := s.pushServer(http.StatusNoContent, &streams)
+	defer server.Close()
+	loki, err := NewLoki(server.URL, nil)
+	s.Require().NoError(err)
+	entries := []logging.LogEntry{
+		{Line: "a", Time: time.Date(2022, 3, 4, 0, 1, 0, 0, time.UTC), File: "access.log", Severity: logging.SeverityInfo},
+		{Line: "b", Time: time.Date(2022, 3, 4, 0, 2, 0, 0, time.UTC), File: "access.log", Severity: logging.SeverityError},
+		{Line: "c", Time: time.Date(2022, 3, 4, 0, 3, 0, 0, time.UTC), File: "access.log", Severity: logging.SeverityInfo},
+	}
+
+	s.Require().NoError(loki.Write(context.Background(), entries))
+
+	s.Equal([]lokiStream{
+		{
+			Stream: map[string]string{"job": DefaultLokiJob, "filename": "access.log", "level": "info"},
+			Values: [][2]string{{"1646352060000000000", "a"}, {"1646352180000000000", "c"}},
+		},
+		{
+			Stream: map[string]string{"job": DefaultLokiJob, "filename": "access.log", "level": "error"},
+			Values: [][2]string{{"1646352120000000000", "b"}},
+		},
+	}, streams)
+	s.NoError(loki.Close())
+}
+
+func (s *lokiSuite) Test_Write_Errors() {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{status: http.StatusTooManyRequests},
+		{status: http.StatusServiceUnavailable},
+		{status: http.StatusBadRequest, permanent: true},
+	}
+	for _, test := range tests {
+		s.Run(http.StatusText(test.status), func() {
+			var streams []lokiStream
+			server := s.pushServer(test.status, &streams)
+			defer server.Close()
+			loki, err := NewLoki(server.URL, nil)
+			s.Require().NoError(err)
+
+			err = loki.Write(context.Background(), s.entries)
+
+			s.Require().Error(err)
+			s.Contains(err.Error(), "loki push request failed: ")
+			var permanent *permanentError
+			s.Equal(test.permanent, errors.As(err, &permanent))
+			s.Equal(DefaultLokiJob, streams[0].Stream["job"])
+		})
+	}
+}
+
+func (s *lokiSuite) Test_ParseLokiLabels() {
+	labels, err := ParseLokiLabels("job=apache,env=prod,empty=")
+	s.NoError(err)
+	s.Equal(map[string]string{"job": "apache", "env": "prod", "empty": ""}, labels)
+
+	_, err = ParseLokiLabels("job")
+	s.EqualError(err, "invalid loki label 'job': must be name=value")
+	_, err = NewLoki("", nil)
+	s.EqualError(err, "invalid loki url: must not be empty")
+}
+
+func TestLoki(t *testing.T) {
+	suite.Run(t, new(lokiSuite))
+}
@@ -0,0 +1,176 @@
+package ship
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type mqttSuite struct {
+	suite.Suite
+	entries []logging.LogEntry
+}
+
+func (s *mqttSuite) SetupSuite() {
+	t := time.Date(2022, 3, 3, 2, 44, 10, 0, time.UTC)
+	s.entries = []logging.LogEntry{
+		{Line: "a", IP: "10.0.0.1", Path: "/a", Status: 200, Size: 20, Time: t},
+		{Line: "not a request", Time: t},
+		{Line: "b", IP: "10.0.0.2", Path: "/b", Status: 503, Size: 10, Time: t.Add(time.Second)},
+	}
+}
+
+// mqttMessage is a message published to the fake broker.
+type mqttMessage struct {
+	topic    string
+	qos      byte
+	payload  []byte
+	username string
+}
+
+// mqttBroker starts a fake MQTT 3.1.1 broker accepting the connections and acknowledging the messages published to it.
+func (s *mqttSuite) mqttBroker() (string, <-chan mqttMessage) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { _ = listener.Close() })
+	messages := make(chan mqttMessage, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveMQTT(conn, messages)
+		}
+	}()
+	return "tcp://" + listener.Addr().String(), messages
+}
+
+// serveMQTT serves a connection of the fake broker.
+func (s *mqttSuite) serveMQTT(conn net.Conn, messages chan<- mqttMessage) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var username string
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return
+		}
+		packet := make([]byte, length)
+		if _, err := io.ReadFull(r, packet); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT: protocol name, level, flags, keep alive, client id, username
+			flags := packet[7]
+			rest := packet[10:]
+			_, rest = mqttString(rest)
+			if flags&0x80 != 0 {
+				username, _ = mqttString(rest)
+			}
+			_, _ = conn.Write([]byte{0x20, 2, 0, 0})
+		case 3: // PUBLISH
+			qos := header >> 1 & 3
+			topic, rest := mqttString(packet)
+			if qos > 0 {
+				_, _ = conn.Write([]byte{0x40, 2, rest[0], rest[1]})
+				rest = rest[2:]
+			}
+			messages <- mqttMessage{topic: topic, qos: qos, payload: rest, username: username}
+		case 12: // PINGREQ
+			_, _ = conn.Write([]byte{0xd0, 0})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+// mqttString reads a length-prefixed string.
+func mqttString(b []byte) (string, []byte) {
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+func (s *mqttSuite) Test_Write_Entries() {
+	broker, messages := s.mqttBroker()
+	u := "tcp://gateway:secret@" + broker[len("tcp://"):]
+	sink, err := NewMQTT(MQTTConfig{Broker: u, Topic: "apache/access", QoS: 1})
+	s.Require().NoError(err)
+	defer sink.Close()
+
+	s.Require().NoError(sink.Write(context.Background(), s.entries))
+
+	for _, expected := range s.entries {
+		msg := <-messages
+		s.Equal("apache/access", msg.topic)
+		s.Equal(byte(1), msg.qos)
+		s.Equal("gateway", msg.username)
+		var entry logging.LogEntry
+		s.Require().NoError(json.Unmarshal(msg.payload, &entry))
+		s.Equal(expected, entry)
+	}
+}
+
+func (s *mqttSuite) Test_Write_Aggregate() {
+	broker, messages := s.mqttBroker()
+	sink, err := NewMQTT(MQTTConfig{Broker: broker, Topic: "apache/stats", Aggregate: true})
+	s.Require().NoError(err)
+	defer sink.Close()
+
+	s.Require().NoError(sink.Write(context.Background(), s.entries))
+	// the batches without requests are not published
+	s.Require().NoError(sink.Write(context.Background(), s.entries[1:2]))
+
+	msg := <-messages
+	s.Equal("apache/stats", msg.topic)
+	s.Equal(byte(0), msg.qos)
+	var aggregate MQTTAggregate
+	s.Require().NoError(json.Unmarshal(msg.payload, &aggregate))
+	s.Equal(int64(2), aggregate.Requests)
+	s.Equal(int64(1), aggregate.Errors)
+	s.Equal(int64(30), aggregate.Bytes)
+	s.Equal(map[string]int64{"2xx": 1, "5xx": 1}, aggregate.Statuses)
+	s.Equal(s.entries[0].Time, aggregate.From)
+	s.Equal(s.entries[2].Time, aggregate.To)
+	select {
+	case msg := <-messages:
+		s.Failf("unexpected message", "%s", msg.payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *mqttSuite) Test_NewMQTT_Invalid() {
+	tests := []struct {
+		cfg MQTTConfig
+		err string
+	}{
+		{cfg: MQTTConfig{Broker: "broker:1883", Topic: "t"}, err: "invalid mqtt broker 'broker:1883': must be a url such as tcp://broker:1883"},
+		{cfg: MQTTConfig{Broker: "http://broker:1883", Topic: "t"}, err: "invalid mqtt broker 'http://broker:1883': the scheme must be tcp, ssl, ws or wss"},
+		{cfg: MQTTConfig{Broker: "tcp://broker:1883"}, err: "invalid mqtt topic: must not be empty"},
+		{cfg: MQTTConfig{Broker: "tcp://broker:1883", Topic: "t", QoS: 2}, err: "invalid mqtt qos 2: must be 0 or 1"},
+	}
+	for _, test := range tests {
+		s.Run(test.err, func() {
+			_, err := NewMQTT(test.cfg)
+			s.EqualError(err, test.err)
+		})
+	}
+}
+
+func TestMQTTSuite(t *testing.T) {
+	suite.Run(t, new(mqttSuite))
+}
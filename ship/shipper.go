@@ -0,0 +1,280 @@
+// Package ship ships log entries to external systems (sinks), in batches and with retries,
+// either the ones within a time window or the ones written from now on (see logging.Logs.Follow).
+package ship
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// The defaults of a Shipper.
+const (
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = time.Second
+	DefaultRetries       = 5
+	DefaultBackoff       = time.Second
+	// DefaultShutdownTimeout is how long the last batch is given to be written once the context is done.
+	DefaultShutdownTimeout = 10 * time.Second
+	// maxBackoff caps the exponential backoff between the retries of a batch.
+	maxBackoff = 30 * time.Second
+)
+
+// Sink writes batches of log entries to an external system.
+type Sink interface {
+	// Write writes a batch of log entries, which must not be retained after returning.
+	// A batch failing with an error is retried, unless the error is permanent (see Permanent),
+	// so sinks should write the same entries idempotently when they can.
+	Write(ctx context.Context, entries []logging.LogEntry) error
+	// Close releases the resources of the sink, once all the batches are written.
+	Close() error
+}
+
+// Source calls the given function for each log entry to ship, e.g. logging.Logs.ForEach or logging.Logs.Follow.
+type Source func(ctx context.Context, fn func(logging.LogEntry) error) error
+
+// Shipper ships the log entries of a Source to a Sink in batches, retrying the failed batches
+// with an exponential backoff.
+type Shipper struct {
+	sink          Sink
+	batchSize     int
+	flushInterval time.Duration
+	retries       int
+	backoff       time.Duration
+	shutdown      time.Duration
+	checkpoints   *logging.Checkpoints
+	// maxRate is the maximum number of log entries written per second, if any, and nextWrite when the next batch may be written
+	maxRate   float64
+	nextWrite time.Time
+	// shipped counts the log entries written, retried the retries of the batches and failed the batches given up on
+	shipped, retried, failed int64
+}
+
+// Option configures the Shipper created by NewShipper.
+type Option func(s *Shipper)
+
+// WithBatchSize sets the maximum number of log entries written at once, DefaultBatchSize by default.
+func WithBatchSize(size int) Option {
+	return func(s *Shipper) {
+		s.batchSize = size
+	}
+}
+
+// WithFlushInterval sets how long the log entries may wait for their batch to be full before being written,
+// DefaultFlushInterval by default, which matters when following the logs.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Shipper) {
+		s.flushInterval = interval
+	}
+}
+
+// WithRetries sets the number of times a failed batch is retried and the backoff before the first retry,
+// doubled for every following one, DefaultRetries and DefaultBackoff by default.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(s *Shipper) {
+		s.retries = retries
+		s.backoff = backoff
+	}
+}
+
+// WithShutdownTimeout sets how long the batch buffered when the context is done (e.g. interrupted)
+// is given to be written, with its retries, DefaultShutdownTimeout by default.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *Shipper) {
+		s.shutdown = timeout
+	}
+}
+
+// WithCheckpoints commits the log entries of every batch written to given checkpoints, saving them right away,
+// so a restart resumes after them (see logging.LogsConfig.Checkpoints): a batch is shipped again when the process
+// stops before its checkpoints are saved, but never skipped.
+func WithCheckpoints(checkpoints *logging.Checkpoints) Option {
+	return func(s *Shipper) {
+		s.checkpoints = checkpoints
+	}
+}
+
+// WithMaxRate limits the number of log entries written to the sink per second, on average, holding the batches back
+// as needed, e.g. so a backfill of old logs doesn't overwhelm the sink. There's no limit when not positive.
+func WithMaxRate(entriesPerSecond float64) Option {
+	return func(s *Shipper) {
+		s.maxRate = entriesPerSecond
+	}
+}
+
+// NewShipper creates a Shipper writing to a given sink, configured by the given options.
+func NewShipper(sink Sink, opts ...Option) *Shipper {
+	s := &Shipper{
+		sink:          sink,
+		batchSize:     DefaultBatchSize,
+		flushInterval: DefaultFlushInterval,
+		retries:       DefaultRetries,
+		backoff:       DefaultBackoff,
+		shutdown:      DefaultShutdownTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = DefaultBatchSize
+	}
+	if s.flushInterval <= 0 {
+		s.flushInterval = DefaultFlushInterval
+	}
+	return s
+}
+
+// Ship writes the log entries of a given source to the sink until the source is done, the context is done
+// or a batch fails (after its retries), returning the error. The source is held back while a batch is written,
+// and no files within the time window (logging.ErrNoFilesInWindow) just means there's nothing to ship.
+// The batch buffered when the context is done is still written, within the shutdown timeout
+// (see WithShutdownTimeout), and the log entries the source didn't pass on yet are dropped.
+// The source is always stopped and done once Ship returns.
+func (s *Shipper) Ship(ctx context.Context, source Source) error {
+	// at most a batch of log entries is buffered while the previous one is written
+	pipe := logging.NewPipe(ctx, source, s.batchSize)
+	defer func() { _ = pipe.Close() }()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	batch := make([]logging.LogEntry, 0, s.batchSize)
+	for {
+		select {
+		case entry, ok := <-pipe.Entries():
+			if !ok {
+				if err := s.flush(ctx, batch); err != nil {
+					return err
+				}
+				err := pipe.Err()
+				if errors.Is(err, logging.ErrNoFilesInWindow) {
+					return nil
+				}
+				return err
+			}
+			batch = append(batch, entry)
+			if len(batch) < s.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if err := s.flush(ctx, batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+	}
+}
+
+// Shipped returns the number of log entries written to the sink so far.
+func (s *Shipper) Shipped() int64 {
+	return atomic.LoadInt64(&s.shipped)
+}
+
+// Retried returns the number of times the batches were written again to the sink once failed so far,
+// which is safe to call while shipping, e.g. to monitor the sink.
+func (s *Shipper) Retried() int64 {
+	return atomic.LoadInt64(&s.retried)
+}
+
+// Failed returns the number of batches which could not be written to the sink, even once retried, so far.
+func (s *Shipper) Failed() int64 {
+	return atomic.LoadInt64(&s.failed)
+}
+
+// flush writes a given batch to the sink, retrying it with an exponential backoff unless the error is permanent.
+// Once the context is done, the batch is given the shutdown timeout to be written instead.
+func (s *Shipper) flush(ctx context.Context, batch []logging.LogEntry) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), s.shutdown)
+		defer cancel()
+	}
+	if err := s.pace(ctx, len(batch)); err != nil {
+		return err
+	}
+
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		err := s.sink.Write(ctx, batch)
+		if err == nil {
+			atomic.AddInt64(&s.shipped, int64(len(batch)))
+			return s.commit(batch)
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= s.retries || ctx.Err() != nil {
+			atomic.AddInt64(&s.failed, 1)
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			atomic.AddInt64(&s.failed, 1)
+			return err
+		case <-time.After(backoff):
+		}
+		atomic.AddInt64(&s.retried, 1)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// pace waits until a batch of a given size may be written, given the maximum rate (see WithMaxRate),
+// or the context is done, returning its error.
+func (s *Shipper) pace(ctx context.Context, size int) error {
+	if s.maxRate <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if wait := s.nextWrite.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	} else {
+		s.nextWrite = now
+	}
+	s.nextWrite = s.nextWrite.Add(time.Duration(float64(size) / s.maxRate * float64(time.Second)))
+	return nil
+}
+
+// commit commits the log entries of a given batch written to the sink to the checkpoints, if any, and saves them.
+func (s *Shipper) commit(batch []logging.LogEntry) error {
+	if s.checkpoints == nil {
+		return nil
+	}
+	s.checkpoints.Commit(batch)
+	if err := s.checkpoints.Save(); err != nil {
+		return fmt.Errorf("could not save checkpoints: %w", err)
+	}
+	return nil
+}
+
+// permanentError is an error retrying a batch cannot fix.
+type permanentError struct {
+	err error
+}
+
+// Permanent marks a given error returned by Sink.Write as permanent, so the batch is not retried.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,241 @@
+package ship
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// fakeSink records the batches written to it, failing the first writes with the given errors.
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]string
+	errs    []error
+	writes  int
+	closed  bool
+}
+
+func (sink *fakeSink) Write(ctx context.Context, entries []logging.LogEntry) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.writes++
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(sink.errs) > 0 {
+		err := sink.errs[0]
+		sink.errs = sink.errs[1:]
+		return err
+	}
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	sink.batches = append(sink.batches, paths)
+	return nil
+}
+
+func (sink *fakeSink) Close() error {
+	sink.closed = true
+	return nil
+}
+
+func (sink *fakeSink) written() [][]string {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return append([][]string(nil), sink.batches...)
+}
+
+// sliceSource returns a Source calling the function for the log entries of the given paths.
+func sliceSource(paths ...string) Source {
+	return func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		for _, path := range paths {
+			if err := fn(logging.LogEntry{Path: path}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+type shipperSuite struct {
+	suite.Suite
+}
+
+func (s *shipperSuite) Test_Ship_Batches() {
+	sink := &fakeSink{}
+	shipper := NewShipper(sink, WithBatchSize(2))
+
+	err := shipper.Ship(context.Background(), sliceSource("/a", "/b", "/c", "/d", "/e"))
+
+	s.NoError(err)
+	s.Equal([][]string{{"/a", "/b"}, {"/c", "/d"}, {"/e"}}, sink.written())
+	s.Equal(int64(5), shipper.Shipped())
+}
+
+func (s *shipperSuite) Test_Ship_NoFilesInWindow() {
+	sink := &fakeSink{}
+	source := func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		return logging.ErrNoFilesInWindow
+	}
+
+	err := NewShipper(sink).Ship(context.Background(), source)
+
+	s.NoError(err)
+	s.Empty(sink.written())
+	s.Zero(sink.writes)
+}
+
+func (s *shipperSuite) Test_Ship_Retries() {
+	sink := &fakeSink{errs: []error{errors.New("unavailable"), errors.New("unavailable")}}
+	shipper := NewShipper(sink, WithRetries(2, time.Millisecond))
+
+	err := shipper.Ship(context.Background(), sliceSource("/a"))
+
+	s.NoError(err)
+	s.Equal(3, sink.writes)
+	s.Equal([][]string{{"/a"}}, sink.written())
+	s.Equal(int64(2), shipper.Retried())
+	s.Zero(shipper.Failed())
+}
+
+func (s *shipperSuite) Test_Ship_RetriesExhausted() {
+	sink := &fakeSink{errs: []error{errors.New("unavailable"), errors.New("unavailable"), errors.New("still unavailable")}}
+	shipper := NewShipper(sink, WithRetries(2, time.Millisecond))
+
+	err := shipper.Ship(context.Background(), sliceSource("/a"))
+
+	s.EqualError(err, "still unavailable")
+	s.Equal(3, sink.writes)
+	s.Zero(shipper.Shipped())
+	s.Equal(int64(2), shipper.Retried())
+	s.Equal(int64(1), shipper.Failed())
+}
+
+func (s *shipperSuite) Test_Ship_Permanent() {
+	sink := &fakeSink{errs: []error{Permanent(errors.New("bad request"))}}
+	shipper := NewShipper(sink, WithRetries(2, time.Millisecond))
+
+	err := shipper.Ship(context.Background(), sliceSource("/a"))
+
+	s.EqualError(err, "bad request")
+	s.Equal(1, sink.writes)
+}
+
+func (s *shipperSuite) Test_Ship_SourceStopped() {
+	sink := &fakeSink{errs: []error{Permanent(errors.New("bad request"))}}
+	shipper := NewShipper(sink, WithBatchSize(1))
+	done := false
+
+	err := shipper.Ship(context.Background(), func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		defer func() { done = true }()
+		for {
+			if err := fn(logging.LogEntry{Path: "/"}); err != nil {
+				return err
+			}
+		}
+	})
+
+	s.EqualError(err, "bad request")
+	// the endless source is stopped and done once Ship returns
+	s.True(done)
+}
+
+func (s *shipperSuite) Test_Ship_FlushInterval() {
+	sink := &fakeSink{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the entries trickle in, like when following the logs
+	source := func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		if err := fn(logging.LogEntry{Path: "/a"}); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- NewShipper(sink, WithFlushInterval(10*time.Millisecond)).Ship(ctx, source)
+	}()
+
+	s.Eventually(func() bool {
+		return len(sink.written()) == 1
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	s.ErrorIs(<-done, context.Canceled)
+	s.Equal([][]string{{"/a"}}, sink.written())
+}
+
+func (s *shipperSuite) Test_Ship_Interrupted() {
+	sink := &fakeSink{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// interrupted before the batch is full or the flush interval elapses
+	source := func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		for _, path := range []string{"/a", "/b"} {
+			if err := fn(logging.LogEntry{Path: path}); err != nil {
+				return err
+			}
+		}
+		cancel()
+		return ctx.Err()
+	}
+	shipper := NewShipper(sink, WithFlushInterval(time.Hour), WithShutdownTimeout(time.Second))
+
+	err := shipper.Ship(ctx, source)
+
+	s.ErrorIs(err, context.Canceled)
+	// the buffered batch is written all the same
+	s.Equal([][]string{{"/a", "/b"}}, sink.written())
+	s.Equal(int64(2), shipper.Shipped())
+}
+
+func (s *shipperSuite) Test_Ship_MaxRate() {
+	sink := &fakeSink{}
+	shipper := NewShipper(sink, WithBatchSize(10), WithMaxRate(200))
+
+	start := time.Now()
+	err := shipper.Ship(context.Background(), sliceSource("/a", "/b", "/c", "/d", "/e", "/f", "/g", "/h", "/i", "/j", "/k", "/l", "/m", "/n", "/o", "/p", "/q", "/r", "/s", "/t", "/u"))
+
+	s.NoError(err)
+	s.Len(sink.written(), 3)
+	// the first 2 batches of 10 entries take 50ms each at 200 entries per second
+	s.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+}
+
+func (s *shipperSuite) Test_Ship_Checkpoints() {
+	name := filepath.Join(s.T().TempDir(), "checkpoints.json")
+	checkpoints, err := logging.LoadCheckpoints(name)
+	s.Require().NoError(err)
+	file := logging.FileID{Device: 1, Inode: 2}
+	source := func(ctx context.Context, fn func(logging.LogEntry) error) error {
+		for i, p := range []string{"/a", "/b", "/c"} {
+			if err := fn(logging.LogEntry{Path: p, File: "access.log", Offset: int64(i * 10), FileID: file}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// the second batch is never written
+	sink := &fakeSink{errs: []error{nil, Permanent(errors.New("bad request"))}}
+	shipper := NewShipper(sink, WithBatchSize(2), WithCheckpoints(checkpoints))
+
+	s.Error(shipper.Ship(context.Background(), source))
+
+	saved, err := logging.LoadCheckpoints(name)
+	s.Require().NoError(err)
+	checkpoint, ok := saved.Get(file)
+	s.Require().True(ok)
+	s.Equal(int64(10), checkpoint.Offset)
+}
+
+func TestShipper(t *testing.T) {
+	suite.Run(t, new(shipperSuite))
+}
@@ -0,0 +1,185 @@
+package ship
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// statsdMaxPacketSize keeps the packets within the MTU of most networks, several metrics sharing a packet.
+const statsdMaxPacketSize = 1432
+
+// StatsDConfig configures a StatsD sink.
+type StatsDConfig struct {
+	// Address is the host:port of the StatsD server or Datadog agent, over UDP.
+	Address string
+	// Prefix is prepended to the names of the metrics, e.g. apache.
+	Prefix string
+	// Tags adds DogStatsD tags to the metrics: the status class (e.g. 5xx), the method and the virtual host.
+	Tags bool
+	// PerEntry emits the metrics of every log entry, along with the time taken to serve its request
+	// when logged, instead of summing the counters of every batch.
+	PerEntry bool
+}
+
+// StatsD is a Sink emitting metrics about the requests to a StatsD server (or a Datadog agent with tags):
+// the counters <prefix>requests, <prefix>errors (5xx) and <prefix>bytes, and the timer <prefix>request_time
+// of every log entry (see StatsDConfig.PerEntry). The log lines that are not requests are ignored.
+type StatsD struct {
+	cfg  StatsDConfig
+	conn net.Conn
+}
+
+// statsdCounters are the counters of the requests sharing the same tags.
+type statsdCounters struct {
+	requests, errors, bytes int64
+}
+
+// NewStatsD creates a StatsD sink for a given configuration.
+func NewStatsD(cfg StatsDConfig) (*StatsD, error) {
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		return nil, fmt.Errorf("invalid statsd address '%s': %v", cfg.Address, err)
+	}
+	return &StatsD{cfg: cfg}, nil
+}
+
+// Write emits the metrics of a batch of log entries, the counters being summed unless emitted per entry,
+// reconnecting on the following write when it fails.
+func (s *StatsD) Write(ctx context.Context, entries []logging.LogEntry) error {
+	if s.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "udp", s.cfg.Address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	var metrics []string
+	if s.cfg.PerEntry {
+		for _, entry := range entries {
+			if entry.IP == "" {
+				continue
+			}
+			tags := s.tags(entry)
+			metrics = append(metrics, s.counters(tags, statsdCounters{requests: 1, errors: errorCount(entry), bytes: entry.Size})...)
+			if entry.Duration > 0 {
+				ms := strconv.FormatFloat(float64(entry.Duration.Microseconds())/1000, 'f', -1, 64)
+				metrics = append(metrics, s.cfg.Prefix+"request_time:"+ms+"|ms"+tags)
+			}
+		}
+	} else {
+		byTags := make(map[string]*statsdCounters)
+		for _, entry := range entries {
+			if entry.IP == "" {
+				continue
+			}
+			tags := s.tags(entry)
+			if byTags[tags] == nil {
+				byTags[tags] = &statsdCounters{}
+			}
+			byTags[tags].requests++
+			byTags[tags].errors += errorCount(entry)
+			byTags[tags].bytes += entry.Size
+		}
+		tagSets := make([]string, 0, len(byTags))
+		for tags := range byTags {
+			tagSets = append(tagSets, tags)
+		}
+		sort.Strings(tagSets)
+		for _, tags := range tagSets {
+			metrics = append(metrics, s.counters(tags, *byTags[tags])...)
+		}
+	}
+
+	if err := s.send(metrics); err != nil {
+		_ = s.Close()
+		return err
+	}
+	return nil
+}
+
+// Close closes the socket to the StatsD server, if any.
+func (s *StatsD) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// counters returns the counter metrics of given counters, with given tags.
+func (s *StatsD) counters(tags string, c statsdCounters) []string {
+	metrics := []string{s.cfg.Prefix + "requests:" + strconv.FormatInt(c.requests, 10) + "|c" + tags}
+	if c.errors > 0 {
+		metrics = append(metrics, s.cfg.Prefix+"errors:"+strconv.FormatInt(c.errors, 10)+"|c"+tags)
+	}
+	if c.bytes > 0 {
+		metrics = append(metrics, s.cfg.Prefix+"bytes:"+strconv.FormatInt(c.bytes, 10)+"|c"+tags)
+	}
+	return metrics
+}
+
+// tags returns the DogStatsD tags of a given log entry (e.g. |#status:5xx,method:get), if enabled.
+func (s *StatsD) tags(entry logging.LogEntry) string {
+	if !s.cfg.Tags {
+		return ""
+	}
+	tags := []string{"status:" + statusClass(entry.Status)}
+	if entry.Method != "" {
+		tags = append(tags, "method:"+strings.ToLower(entry.Method))
+	}
+	if entry.VHost != "" {
+		tags = append(tags, "vhost:"+strings.ToLower(entry.VHost))
+	}
+	return "|#" + strings.Join(tags, ",")
+}
+
+// send sends the given metrics, as many of them per packet as possible, one per line.
+func (s *StatsD) send(metrics []string) error {
+	var packet bytes.Buffer
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := s.conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+
+	for _, metric := range metrics {
+		if packet.Len() > 0 && packet.Len()+1+len(metric) > statsdMaxPacketSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(metric)
+	}
+	return flush()
+}
+
+// errorCount returns 1 for a request answered with a server error (5xx), 0 otherwise.
+func errorCount(entry logging.LogEntry) int64 {
+	if entry.Status >= 500 && entry.Status < 600 {
+		return 1
+	}
+	return 0
+}
+
+// statusClass returns the class of a given status code, e.g. 5xx, or unknown for "-".
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
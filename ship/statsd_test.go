@@ -0,0 +1,117 @@
+package ship
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type statsdSuite struct {
+	suite.Suite
+	entries []logging.LogEntry
+	conn    net.PacketConn
+}
+
+func (s *statsdSuite) SetupSuite() {
+	s.entries = []logging.LogEntry{
+		{IP: "10.0.0.1", Method: "GET", Status: 200, Size: 20, Duration: 1500 * time.Microsecond},
+		{Line: "not a request"},
+		{IP: "10.0.0.2", Method: "GET", Status: 503, Size: 10},
+		{IP: "10.0.0.3", Method: "POST", Status: 201, Size: 5, Duration: 3 * time.Millisecond},
+	}
+}
+
+func (s *statsdSuite) SetupTest() {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.conn = conn
+}
+
+func (s *statsdSuite) TearDownTest() {
+	_ = s.conn.Close()
+}
+
+// write writes the entries to a StatsD sink, returning the metrics received by the server.
+func (s *statsdSuite) write(cfg StatsDConfig) []string {
+	cfg.Address = s.conn.LocalAddr().String()
+	sink, err := NewStatsD(cfg)
+	s.Require().NoError(err)
+	defer sink.Close()
+	s.Require().NoError(sink.Write(context.Background(), s.entries))
+
+	var metrics []string
+	buf := make([]byte, statsdMaxPacketSize)
+	for {
+		_ = s.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return metrics
+		}
+		metrics = append(metrics, strings.Split(string(buf[:n]), "\n")...)
+	}
+}
+
+func (s *statsdSuite) Test_Write_Aggregated() {
+	s.Equal([]string{
+		"apache.requests:3|c",
+		"apache.errors:1|c",
+		"apache.bytes:35|c",
+	}, s.write(StatsDConfig{Prefix: "apache."}))
+}
+
+func (s *statsdSuite) Test_Write_Tags() {
+	s.Equal([]string{
+		"requests:1|c|#status:2xx,method:get",
+		"bytes:20|c|#status:2xx,method:get",
+		"requests:1|c|#status:2xx,method:post",
+		"bytes:5|c|#status:2xx,method:post",
+		"requests:1|c|#status:5xx,method:get",
+		"errors:1|c|#status:5xx,method:get",
+		"bytes:10|c|#status:5xx,method:get",
+	}, s.write(StatsDConfig{Tags: true}))
+}
+
+func (s *statsdSuite) Test_Write_PerEntry() {
+	s.Equal([]string{
+		"apache.requests:1|c",
+		"apache.bytes:20|c",
+		"apache.request_time:1.5|ms",
+		"apache.requests:1|c",
+		"apache.errors:1|c",
+		"apache.bytes:10|c",
+		"apache.requests:1|c",
+		"apache.bytes:5|c",
+		"apache.request_time:3|ms",
+	}, s.write(StatsDConfig{Prefix: "apache.", PerEntry: true}))
+}
+
+func (s *statsdSuite) Test_Write_Packets() {
+	entries := s.entries
+	defer func() { s.entries = entries }()
+	s.entries = nil
+	for i := 0; i < 200; i++ {
+		s.entries = append(s.entries, logging.LogEntry{IP: "10.0.0.1", Status: 200})
+	}
+
+	// the metrics are split into several packets, none of them exceeding the maximum size
+	metrics := s.write(StatsDConfig{Prefix: "apache.", PerEntry: true})
+	s.Len(metrics, 200)
+	for _, metric := range metrics {
+		s.Equal("apache.requests:1|c", metric)
+	}
+}
+
+func (s *statsdSuite) Test_NewStatsD_InvalidAddress() {
+	_, err := NewStatsD(StatsDConfig{Address: "localhost"})
+	s.Error(err)
+}
+
+func TestStatsDSuite(t *testing.T) {
+	suite.Run(t, new(statsdSuite))
+}
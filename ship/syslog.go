@@ -0,0 +1,165 @@
+package ship
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+// The syslog facility of the log entries, see RFC 5424 section 6.2.1.
+const (
+	// syslogFacilityLocal7 is the facility Apache logs to syslog with by default.
+	syslogFacilityLocal7 = 23
+	// syslogTimeout bounds connecting and writing to the syslog server.
+	syslogTimeout = 30 * time.Second
+)
+
+// SyslogConfig configures a Syslog sink.
+type SyslogConfig struct {
+	// Network is udp, tcp or tls.
+	Network string
+	// Address is the host:port of the syslog server.
+	Address string
+	// TLS configures the connection of the tls network, using the system roots when nil.
+	TLS *tls.Config
+}
+
+// ParseSyslogURL parses the URL of a syslog server, such as udp://siem:514, tcp://siem:601 or tls://siem:6514.
+func ParseSyslogURL(rawURL string) (SyslogConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return SyslogConfig{}, fmt.Errorf("invalid syslog url '%s': %v", rawURL, err)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" && u.Scheme != "tls" {
+		return SyslogConfig{}, fmt.Errorf("invalid syslog url '%s': the scheme must be udp, tcp or tls", rawURL)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return SyslogConfig{}, fmt.Errorf("invalid syslog url '%s': host and port are required", rawURL)
+	}
+	return SyslogConfig{Network: u.Scheme, Address: u.Host}, nil
+}
+
+// Syslog is a Sink forwarding every log entry to a syslog server as an RFC 5424 message,
+// which message is the log line, with the local7 facility (the one of Apache) and the severity of the log entry
+// (see logging.SeverityOf), by default depending on the status of the request: error for 5xx, warning for 4xx
+// and informational otherwise.
+// The messages are sent over UDP (RFC 5426), or framed by their length over TCP (RFC 6587) and TLS (RFC 5425).
+type Syslog struct {
+	cfg      SyslogConfig
+	hostname string
+	conn     net.Conn
+}
+
+// NewSyslog creates a Syslog sink for a given configuration, connecting to the server on the first write.
+func NewSyslog(cfg SyslogConfig) (*Syslog, error) {
+	if cfg.Network != "udp" && cfg.Network != "tcp" && cfg.Network != "tls" {
+		return nil, fmt.Errorf("invalid syslog network '%s': must be udp, tcp or tls", cfg.Network)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &Syslog{cfg: cfg, hostname: hostname}, nil
+}
+
+// Write sends a batch of log entries, reconnecting on the following write when it fails.
+func (s *Syslog) Write(ctx context.Context, entries []logging.LogEntry) error {
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	deadline := time.Now().Add(syslogTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = s.conn.SetWriteDeadline(deadline)
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		msg := s.message(entry)
+		if s.cfg.Network == "udp" {
+			// every datagram is a message
+			if _, err := s.conn.Write(msg); err != nil {
+				_ = s.Close()
+				return err
+			}
+			continue
+		}
+		buf.WriteString(strconv.Itoa(len(msg)))
+		buf.WriteByte(' ')
+		buf.Write(msg)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		_ = s.Close()
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the syslog server, if any.
+func (s *Syslog) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// dial connects to the syslog server.
+func (s *Syslog) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	if s.cfg.Network != "tls" {
+		return dialer.DialContext(ctx, s.cfg.Network, s.cfg.Address)
+	}
+
+	cfg := &tls.Config{}
+	if s.cfg.TLS != nil {
+		cfg = s.cfg.TLS.Clone()
+	}
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(s.cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ServerName = host
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", s.cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// message formats a given log entry as an RFC 5424 message.
+func (s *Syslog) message(entry logging.LogEntry) []byte {
+	severity := logging.SeverityOf(entry).SyslogSeverity()
+	timestamp := "-"
+	if !entry.Time.IsZero() {
+		timestamp = entry.Time.Format("2006-01-02T15:04:05.000000Z07:00")
+	}
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	return []byte(fmt.Sprintf("<%d>1 %s %s apache - access - %s",
+		syslogFacilityLocal7*8+severity, timestamp, s.hostname, entry.Line))
+}
@@ -0,0 +1,124 @@
+package ship
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/chill-and-code/apache-log-reader/logging"
+)
+
+type syslogSuite struct {
+	suite.Suite
+	entries  []logging.LogEntry
+	hostname string
+}
+
+func (s *syslogSuite) SetupSuite() {
+	t := time.Date(2022, 3, 3, 2, 44, 10, 0, time.UTC)
+	s.entries = []logging.LogEntry{
+		{Line: `10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20`, Time: t, Status: 200},
+		{Line: `10.0.0.2 - - [03/Mar/2022:02:44:11 +0000] "GET /b HTTP/1.1" 404 20`, Time: t.Add(time.Second), Status: 404},
+		{Line: `10.0.0.3 - - [03/Mar/2022:02:44:12 +0000] "GET /c HTTP/1.1" 503 20`, Time: t.Add(2 * time.Second), Status: 503},
+	}
+	s.hostname, _ = os.Hostname()
+}
+
+// expected returns the messages the entries are sent as.
+func (s *syslogSuite) expected() []string {
+	return []string{
+		"<190>1 2022-03-03T02:44:10.000000Z " + s.hostname + ` apache - access - 10.0.0.1 - - [03/Mar/2022:02:44:10 +0000] "GET /a HTTP/1.1" 200 20`,
+		"<188>1 2022-03-03T02:44:11.000000Z " + s.hostname + ` apache - access - 10.0.0.2 - - [03/Mar/2022:02:44:11 +0000] "GET /b HTTP/1.1" 404 20`,
+		"<187>1 2022-03-03T02:44:12.000000Z " + s.hostname + ` apache - access - 10.0.0.3 - - [03/Mar/2022:02:44:12 +0000] "GET /c HTTP/1.1" 503 20`,
+	}
+}
+
+func (s *syslogSuite) Test_Write_UDP() {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer conn.Close()
+	sink, err := NewSyslog(SyslogConfig{Network: "udp", Address: conn.LocalAddr().String()})
+	s.Require().NoError(err)
+	defer sink.Close()
+
+	s.Require().NoError(sink.Write(context.Background(), s.entries))
+
+	var messages []string
+	buf := make([]byte, 2048)
+	for range s.entries {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		s.Require().NoError(err)
+		messages = append(messages, string(buf[:n]))
+	}
+	s.Equal(s.expected(), messages)
+}
+
+func (s *syslogSuite) Test_Write_TCP() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// the messages are framed by their length (octet counting)
+		r := bufio.NewReader(conn)
+		var messages []string
+		for len(messages) < 3 {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				break
+			}
+			messages = append(messages, string(msg))
+		}
+		received <- messages
+	}()
+	sink, err := NewSyslog(SyslogConfig{Network: "tcp", Address: listener.Addr().String()})
+	s.Require().NoError(err)
+	defer sink.Close()
+
+	s.Require().NoError(sink.Write(context.Background(), s.entries))
+
+	select {
+	case messages := <-received:
+		s.Equal(s.expected(), messages)
+	case <-time.After(time.Second):
+		s.Fail("timed out waiting for the messages")
+	}
+}
+
+func (s *syslogSuite) Test_ParseSyslogURL() {
+	cfg, err := ParseSyslogURL("tls://siem.example.com:6514")
+	s.NoError(err)
+	s.Equal(SyslogConfig{Network: "tls", Address: "siem.example.com:6514"}, cfg)
+
+	for rawURL, msg := range map[string]string{
+		"http://siem:514": "invalid syslog url 'http://siem:514': the scheme must be udp, tcp or tls",
+		"udp://siem":      "invalid syslog url 'udp://siem': host and port are required",
+		"siem:514":        "invalid syslog url 'siem:514': the scheme must be udp, tcp or tls",
+	} {
+		_, err := ParseSyslogURL(rawURL)
+		s.EqualError(err, msg)
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	suite.Run(t, new(syslogSuite))
+}